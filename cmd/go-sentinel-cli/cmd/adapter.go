@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/newbpydev/go-sentinel/internal/cli"
+	"github.com/spf13/cobra"
+)
+
+var adapterCmd = &cobra.Command{
+	Use:   "adapter",
+	Short: "Serve editor extensions a test backend over stdio",
+	Long: `Speak the Test Adapter Protocol over stdin and stdout, so editor
+extensions can discover and run the module's tests through go-sentinel.
+
+Messages are JSON-RPC 2.0 framed with Content-Length headers, as in the
+Language Server Protocol. The adapter answers initialize, discover, run,
+and shutdown requests, and stops at the exit notification or when stdin is
+closed. discover returns each package's tests with the file and line that
+defines them; run sends a testEvent notification as each test starts and
+finishes, with the failure message and the line it was reported on.
+
+Logs go to stderr; nothing but protocol messages is written to stdout.`,
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{requiresGo: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("error getting current directory: %v", err)
+		}
+		runner, err := cli.NewRunner(dir)
+		if err != nil {
+			return fmt.Errorf("error creating runner: %v", err)
+		}
+		if err := cli.NewAdapter(dir, runner, os.Stdin, os.Stdout).Serve(); err != nil {
+			return fmt.Errorf("error serving adapter: %v", err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(adapterCmd)
+}
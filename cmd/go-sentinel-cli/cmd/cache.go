@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/newbpydev/go-sentinel/internal/cli"
+	"github.com/spf13/cobra"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Share the packages proven green at a commit",
+	Long: `Every run records the packages whose tests all passed at the checked-out
+commit, with a hash of the sources they were tested with. CI can export
+what it proved green as an artifact, and developers can import it and run
+with --skip-green to leave out the packages already proven green at the
+same commit. A package is only skipped when its files, the files of the
+module packages it depends on, go.mod, go.sum, and the build flags all
+hash as they did in CI, so local edits are always tested.
+
+Artifacts can be kept in blob storage by naming them with a URL instead of
+a path: s3://bucket/key (AWS_* credentials, AWS_ENDPOINT_URL for compatible
+services) or gs://bucket/key (GOOGLE_OAUTH_ACCESS_TOKEN).`,
+}
+
+var cacheExportCmd = &cobra.Command{
+	Use:   "export <file.json|url>",
+	Short: "Write the packages proven green at a commit to an artifact",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("error getting current directory: %v", err)
+		}
+		commit, _ := cmd.Flags().GetString("commit")
+		if commit == "" {
+			if commit = cli.HeadCommit(dir); commit == "" {
+				return fmt.Errorf("error exporting cache: not in a git repository, pass --commit")
+			}
+		}
+
+		var n int
+		err = cli.WriteBlobFile(args[0], func(dest string) error {
+			n, err = cli.NewGreenCache(dir).Export(commit, dest)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("error exporting cache: %v", err)
+		}
+		fmt.Printf("Exported %d green %s at %s to %s\n", n, plural("package", n), shortCommit(commit), args[0])
+		return nil
+	},
+}
+
+var cacheImportCmd = &cobra.Command{
+	Use:   "import <file.json|url>",
+	Short: "Add the packages proven green in an artifact to the local cache",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("error getting current directory: %v", err)
+		}
+
+		var set *cli.GreenSet
+		err = cli.ReadBlobFile(args[0], func(src string) error {
+			set, err = cli.NewGreenCache(dir).Import(src)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("error importing cache: %v", err)
+		}
+		n := len(set.Packages)
+		fmt.Printf("Imported %d green %s at %s\n", n, plural("package", n), shortCommit(set.Commit))
+		if head := cli.HeadCommit(dir); head != "" && head != set.Commit {
+			fmt.Printf("The checked-out commit is %s; packages are only skipped at %s\n", shortCommit(head), shortCommit(set.Commit))
+		}
+		return nil
+	},
+}
+
+// shortCommit abbreviates a commit hash for display
+func shortCommit(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheExportCmd, cacheImportCmd)
+
+	cacheExportCmd.Flags().String("commit", "", "Commit whose green packages are exported (default the checked-out commit)")
+}
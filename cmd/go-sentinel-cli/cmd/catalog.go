@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/newbpydev/go-sentinel/internal/cli"
+	"github.com/spf13/cobra"
+)
+
+var catalogCmd = &cobra.Command{
+	Use:   "catalog [packages...]",
+	Short: "Browse packages and their tests",
+	Long: `List packages with tests, showing each package's doc comment and its test
+functions with the first line of their doc comments. Packages default to ./...`,
+	Annotations: map[string]string{requiresGo: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		useColors := cli.ColorsEnabled()
+
+		dir, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("error getting current directory: %v", err)
+		}
+		catalogs, err := cli.BuildCatalog(dir, args)
+		if err != nil {
+			return fmt.Errorf("error building catalog: %v", err)
+		}
+		cli.NewRendererWithStyle(os.Stdout, useColors).RenderCatalog(catalogs)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(catalogCmd)
+}
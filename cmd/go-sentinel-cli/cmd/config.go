@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/newbpydev/go-sentinel/internal/cli"
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage the project configuration",
+	Long: `Project settings are read from .gosentinel.yaml or .gosentinel.json in the
+working directory: default packages, test timeout, fail-fast, watch ignore
+globs, coverage, and display settings. Command-line flags override them.`,
+	// Skip loading the configuration, so a broken file can be replaced
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return nil
+	},
+}
+
+var configInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Write a starting configuration file",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+		force, _ := cmd.Flags().GetBool("force")
+
+		dir, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("error getting current directory: %v", err)
+		}
+
+		var name string
+		switch format {
+		case "yaml":
+			name = cli.ConfigFileNameYAML
+		case "json":
+			name = cli.ConfigFileName
+		default:
+			return fmt.Errorf("unknown format %q, want yaml or json", format)
+		}
+
+		// Refuse to leave two configuration files, or to overwrite one
+		existing, err := cli.FindConfigFile(dir)
+		if err != nil {
+			return fmt.Errorf("error finding config: %v", err)
+		}
+		if existing != "" && !force {
+			return fmt.Errorf("%s already exists; use --force to replace it", filepath.Base(existing))
+		}
+		if existing != "" && filepath.Base(existing) != name {
+			if err := os.Remove(existing); err != nil {
+				return fmt.Errorf("error replacing config: %v", err)
+			}
+		}
+
+		if err := os.WriteFile(filepath.Join(dir, name), cli.ConfigTemplate(name), 0644); err != nil {
+			return fmt.Errorf("error writing config: %v", err)
+		}
+		fmt.Printf("Wrote %s\n", name)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configInitCmd)
+	configInitCmd.Flags().String("format", "yaml", "File format: yaml or json")
+	configInitCmd.Flags().Bool("force", false, "Replace an existing configuration file")
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/newbpydev/go-sentinel/internal/cli"
+	"github.com/spf13/cobra"
+)
+
+var coverageCmd = &cobra.Command{
+	Use:   "coverage [packages...]",
+	Short: "Show package and file coverage",
+	Long: `Run the tests of the given packages with coverage and show the statement
+coverage of each package and file, with the line ranges left uncovered.
+Packages default to ./...
+
+Use --profile to read an existing go test -coverprofile file instead of
+running the tests, and --file to show one file's source with its uncovered
+lines marked.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		useColors := cli.ColorsEnabled()
+		sortBy, _ := cmd.Flags().GetString("sort")
+		profile, _ := cmd.Flags().GetString("profile")
+		fileName, _ := cmd.Flags().GetString("file")
+
+		dir, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("error getting current directory: %v", err)
+		}
+		cfg, err := cli.LoadConfig(dir)
+		if err != nil {
+			return fmt.Errorf("error loading config: %v", err)
+		}
+		if !cmd.Flags().Changed("sort") && cfg.Coverage.Sort != "" {
+			sortBy = cfg.Coverage.Sort
+		}
+		if len(args) == 0 {
+			args = cfg.Packages
+		}
+
+		var report *cli.CoverageReport
+		if profile != "" {
+			if report, err = readCoverageProfile(profile); err != nil {
+				return err
+			}
+		} else {
+			if _, err := cli.CheckGoToolchain(); err != nil {
+				cmd.SilenceUsage, cmd.SilenceErrors = true, true
+				return err
+			}
+			report, err = cli.CollectCoverage(dir, args)
+			if err != nil {
+				return fmt.Errorf("error collecting coverage: %v", err)
+			}
+		}
+		if err := report.Sort(sortBy); err != nil {
+			return fmt.Errorf("error sorting coverage: %v", err)
+		}
+
+		renderer := cli.NewRendererWithStyle(os.Stdout, useColors)
+		if fileName == "" {
+			renderer.RenderCoverageReport(report)
+			return nil
+		}
+
+		// Drill into a single file
+		file, err := report.FindFile(fileName)
+		if err != nil {
+			return fmt.Errorf("error finding file: %v", err)
+		}
+		path, err := cli.SourcePath(dir, file.Name)
+		if err != nil {
+			return fmt.Errorf("error locating file: %v", err)
+		}
+		source, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("error reading file: %v", err)
+		}
+		renderer.RenderFileCoverage(file, source)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(coverageCmd)
+	coverageCmd.Flags().String("sort", cli.CoverageSortName, "Order of packages and files: name, or coverage for least covered first (default from config)")
+	coverageCmd.Flags().String("profile", "", "Read this go test -coverprofile file instead of running the tests")
+	coverageCmd.Flags().String("file", "", "Show this file's source with uncovered lines marked, e.g. calc.go or pkg/calc.go")
+}
@@ -0,0 +1,248 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"regexp"
+	"syscall"
+
+	"github.com/newbpydev/go-sentinel/internal/cli"
+	"github.com/spf13/cobra"
+)
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon [packages...]",
+	Short: "Keep test binaries warm for fast runs",
+	Long: `Run a long-lived process that keeps the module's package graph and the
+compiled test binaries of its packages warm. While it runs, go-sentinel run
+attaches to it over a unix socket and starts tests without waiting for go
+test to list and build packages: only the binaries of packages whose files,
+or whose dependencies' files, changed are rebuilt.
+
+The binaries of the given packages, default ./..., are built at startup.
+Identical runs requested while one is in progress, with the same options
+over the same sources, share its results instead of running the tests again.
+Runs needing coverage, a setup timeout, resource limits, isolation, or slow
+test warnings still use go test directly, as does run --no-daemon.
+
+On always-on machines, --idle-release frees the binaries and package graph
+after a period without runs; the next run rebuilds what it needs. --idle-stop
+stops the daemon instead, after which runs use go test directly.
+
+CI bots and editors can start runs on the daemon without waiting for them
+with go-sentinel daemon trigger, which prints the run's ID, and read their
+go test -json events and outcome later with go-sentinel daemon result, or
+follow their output live with go-sentinel daemon tail. go-sentinel daemon
+status checks that the daemon is up.
+
+Stop the daemon with Ctrl-C or go-sentinel daemon stop.`,
+	Annotations: map[string]string{requiresGo: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		idleRelease, _ := cmd.Flags().GetDuration("idle-release")
+		idleStop, _ := cmd.Flags().GetDuration("idle-stop")
+		if idleRelease < 0 || idleStop < 0 {
+			return fmt.Errorf("error parsing idle durations: must not be negative")
+		}
+		dir, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("error getting current directory: %v", err)
+		}
+		socket := cli.DaemonSocket(dir)
+		listener, err := cli.ListenDaemon(socket)
+		if err != nil {
+			return fmt.Errorf("error starting daemon: %v", err)
+		}
+		daemon, err := cli.NewDaemon(dir, os.Stdout)
+		if err != nil {
+			listener.Close()
+			return fmt.Errorf("error starting daemon: %v", err)
+		}
+		daemon.SetIdle(idleRelease, idleStop)
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		fmt.Printf("Building test binaries of %s...\n", dir)
+		if err := daemon.Warm(args); err != nil {
+			listener.Close()
+			return fmt.Errorf("error warming daemon: %v", err)
+		}
+		fmt.Printf("Daemon listening on %s\n", socket)
+		if err := daemon.Serve(ctx, listener); err != nil {
+			return fmt.Errorf("error serving runs: %v", err)
+		}
+		fmt.Println("Daemon stopped")
+		return nil
+	},
+}
+
+var daemonStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop the daemon serving the current module",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("error getting current directory: %v", err)
+		}
+		if err := cli.StopDaemon(dir); errors.Is(err, cli.ErrNoDaemon) {
+			fmt.Println("No daemon is running")
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("error stopping daemon: %v", err)
+		}
+		fmt.Println("Daemon stopped")
+		return nil
+	},
+}
+
+var daemonTriggerCmd = &cobra.Command{
+	Use:   "trigger [packages...]",
+	Short: "Start a run on the daemon and print its run ID",
+	Long: `Start testing the given packages, default ./..., on the daemon serving
+the current module and print the run's ID without waiting for the tests.
+The run's environment has SENTINEL_RUN_ID set to it. Read the run's
+events and outcome with go-sentinel daemon result <id>.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		tests, _ := cmd.Flags().GetStringSlice("run")
+		failFast, _ := cmd.Flags().GetBool("fail-fast")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		dir, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("error getting current directory: %v", err)
+		}
+		opts := cli.RunOptions{Packages: args, Tests: tests, FailFast: failFast, Timeout: timeout}
+		id, err := cli.TriggerDaemonRun(dir, opts)
+		if err != nil {
+			return fmt.Errorf("error triggering run: %v", err)
+		}
+		fmt.Println(id)
+		return nil
+	},
+}
+
+var daemonResultCmd = &cobra.Command{
+	Use:   "result <id>",
+	Short: "Print the events of a run started with daemon trigger",
+	Long: `Print the go test -json events of a run started with go-sentinel daemon
+trigger. The command fails when the run's tests failed, and, unless --wait
+is given, when the run is still in progress, after printing its events so
+far. The daemon keeps the results of its last 100 triggered runs.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		wait, _ := cmd.Flags().GetBool("wait")
+		dir, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("error getting current directory: %v", err)
+		}
+		events, err := cli.DaemonRunResult(dir, args[0], wait)
+		os.Stdout.Write(events)
+		switch {
+		case errors.Is(err, cli.ErrRunInProgress):
+			return fmt.Errorf("run %s is still in progress; use --wait to wait for it", args[0])
+		case err != nil:
+			return fmt.Errorf("error getting run %s: %v", args[0], err)
+		}
+		return nil
+	},
+}
+
+var daemonTailCmd = &cobra.Command{
+	Use:   "tail <id>",
+	Short: "Follow the test output of a run started with daemon trigger",
+	Long: `Print the test output of a run started with go-sentinel daemon trigger as
+it is produced, from the start of the run, until the run finishes. Colors
+in the output are passed through to the terminal. With --grep, only the
+lines matching a regular expression are printed. With --json, the run's
+go test -json events are printed instead, one per line as they arrive, for
+editors and dashboards updating live. The command fails when the run's
+tests failed.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		grep, _ := cmd.Flags().GetString("grep")
+		jsonOutput, _ := cmd.Flags().GetBool("json")
+		grepRE, err := regexp.Compile(grep)
+		if err != nil {
+			return fmt.Errorf("error parsing grep: %v", err)
+		}
+		dir, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("error getting current directory: %v", err)
+		}
+		err = cli.FollowDaemonRun(dir, args[0], func(line []byte) {
+			if jsonOutput {
+				fmt.Printf("%s\n", line)
+				return
+			}
+			var event cli.GoTestEvent
+			if json.Unmarshal(line, &event) == nil && event.Output != "" && grepRE.MatchString(event.Output) {
+				fmt.Print(event.Output)
+			}
+		})
+		if err != nil {
+			return fmt.Errorf("error following run %s: %v", args[0], err)
+		}
+		return nil
+	},
+}
+
+var daemonStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the status of the daemon serving the current module",
+	Long: `Show whether a daemon serves the current module, with its runs in progress
+and warm test binaries. The command fails when no daemon is running, so it
+can serve as a health check; status checks do not keep an idle daemon from
+releasing its binaries or stopping.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		jsonOutput, _ := cmd.Flags().GetBool("json")
+		dir, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("error getting current directory: %v", err)
+		}
+		status, err := cli.QueryDaemon(dir)
+		if err != nil {
+			return fmt.Errorf("error checking daemon: %v", err)
+		}
+		if jsonOutput {
+			data, err := json.MarshalIndent(status, "", "  ")
+			if err != nil {
+				return fmt.Errorf("error encoding status: %v", err)
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+		fmt.Printf("Daemon serving %s (pid %d)\n", status.WorkDir, status.PID)
+		fmt.Printf("  Requests in progress: %d\n", status.Requests)
+		fmt.Printf("  Triggered runs kept:  %d (%d running)\n", status.Triggered, status.Running)
+		if status.Released {
+			fmt.Println("  Warm test binaries:   released while idle")
+		} else {
+			fmt.Printf("  Warm test binaries:   %d\n", status.Binaries)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(daemonCmd)
+	daemonCmd.Flags().Duration("idle-release", 0, "Free the warm test binaries and package graph after this long without runs, 0 for never")
+	daemonCmd.Flags().Duration("idle-stop", 0, "Stop the daemon after this long without runs, 0 for never")
+	daemonCmd.AddCommand(daemonStopCmd)
+
+	daemonTriggerCmd.Flags().StringSlice("run", nil, "Run only the tests matching these regular expressions")
+	daemonTriggerCmd.Flags().Bool("fail-fast", false, "Stop each package's tests at its first failure")
+	daemonTriggerCmd.Flags().Duration("timeout", 0, "Timeout of each package's tests, 0 for go test's default")
+	daemonCmd.AddCommand(daemonTriggerCmd)
+	daemonResultCmd.Flags().Bool("wait", false, "Wait for the run to finish")
+	daemonCmd.AddCommand(daemonResultCmd)
+	daemonTailCmd.Flags().String("grep", "", "Print only the output lines matching this regular expression")
+	daemonTailCmd.Flags().Bool("json", false, "Print the run's go test -json events instead of its output")
+	daemonTailCmd.MarkFlagsMutuallyExclusive("grep", "json")
+	daemonCmd.AddCommand(daemonTailCmd)
+	daemonStatusCmd.Flags().Bool("json", false, "Print the status as JSON")
+	daemonCmd.AddCommand(daemonStatusCmd)
+}
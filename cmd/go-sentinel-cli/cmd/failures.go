@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/newbpydev/go-sentinel/internal/cli"
+	"github.com/spf13/cobra"
+)
+
+var failuresCmd = &cobra.Command{
+	Use:   "failures",
+	Short: "List failures tracked across runs",
+	Long: `Group the failures recorded in the project history by fingerprint, so the
+same underlying failure is listed once with its occurrence count, first and
+last sighting, branches, and the runs it occurred in. Fingerprints ignore
+line numbers, addresses, timings, and paths, so a failure keeps its identity
+as code moves.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		useColors := cli.ColorsEnabled()
+		limit, _ := cmd.Flags().GetInt("limit")
+		runID, _ := cmd.Flags().GetString("run")
+
+		store, loc, err := historyStore(cmd)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		records, err := store.Load()
+		if err != nil {
+			return fmt.Errorf("error loading history: %v", err)
+		}
+
+		issues := cli.FailureIssues(records)
+		if runID != "" {
+			if issues, err = cli.IssuesInRun(issues, records, runID); err != nil {
+				return err
+			}
+		}
+		if limit > 0 && len(issues) > limit {
+			issues = issues[:limit]
+		}
+		renderer := cli.NewRendererWithStyle(os.Stdout, useColors)
+		renderer.SetLocation(loc)
+		renderer.RenderFailureIssues(issues)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(failuresCmd)
+
+	failuresCmd.Flags().IntP("limit", "n", 20, "Maximum number of failures to list, 0 for all")
+	failuresCmd.Flags().String("run", "", "Only list failures that occurred in this run (ID or unambiguous prefix)")
+}
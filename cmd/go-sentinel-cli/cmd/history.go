@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/newbpydev/go-sentinel/internal/cli"
+	"github.com/spf13/cobra"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List recorded test runs",
+	Long: `List test runs recorded in the project history, newest first.
+Bookmarked runs are pinned at the top.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		useColors := cli.ColorsEnabled()
+		limit, _ := cmd.Flags().GetInt("limit")
+
+		store, loc, err := historyStore(cmd)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		records, err := store.Load()
+		if err != nil {
+			return fmt.Errorf("error loading history: %v", err)
+		}
+
+		cli.SortPinned(records)
+		if limit > 0 && len(records) > limit {
+			records = records[:limit]
+		}
+		renderer := cli.NewRendererWithStyle(os.Stdout, useColors)
+		renderer.SetLocation(loc)
+		renderer.RenderHistory(records)
+		return nil
+	},
+}
+
+var noteCmd = &cobra.Command{
+	Use:   "note <run-id> <text>",
+	Short: "Attach a note to a recorded run",
+	Long: `Attach a free-text note to a run in the project history, replacing any
+existing note. The run ID may be abbreviated to any unambiguous prefix.
+An empty note removes it.`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, _, err := historyStore(cmd)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		note := strings.Join(args[1:], " ")
+		rec, err := store.Update(args[0], func(rec *cli.HistoryRecord) {
+			rec.Note = note
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Noted run %s\n", rec.ID)
+		return nil
+	},
+}
+
+var bookmarkCmd = &cobra.Command{
+	Use:   "bookmark <run-id>",
+	Short: "Bookmark a recorded run",
+	Long: `Bookmark a run in the project history. Bookmarked runs are pinned at the
+top of the history listing and are never removed by retention cleanup.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		remove, _ := cmd.Flags().GetBool("remove")
+
+		store, _, err := historyStore(cmd)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		rec, err := store.Update(args[0], func(rec *cli.HistoryRecord) {
+			rec.Bookmarked = !remove
+		})
+		if err != nil {
+			return err
+		}
+		if remove {
+			fmt.Printf("Removed bookmark from run %s\n", rec.ID)
+		} else {
+			fmt.Printf("Bookmarked run %s\n", rec.ID)
+		}
+		return nil
+	},
+}
+
+// historyStore opens the history of the project in the working directory,
+// applying the backend, retention, and time zone settings from its
+// configuration, and returns the store with the zone to report times in
+func historyStore(cmd *cobra.Command) (cli.HistoryBackend, *time.Location, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting current directory: %v", err)
+	}
+	cfg, err := cli.LoadConfig(dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error loading config: %v", err)
+	}
+	loc, err := timezone(cmd, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	store, err := cli.OpenHistory(dir, cfg.History, loc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error opening history: %v", err)
+	}
+	return store, loc, nil
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd, noteCmd, bookmarkCmd)
+
+	historyCmd.Flags().IntP("limit", "n", 20, "Maximum number of runs to list, 0 for all")
+	bookmarkCmd.Flags().Bool("remove", false, "Remove the bookmark instead of adding it")
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/newbpydev/go-sentinel/internal/cli"
+	"github.com/spf13/cobra"
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import <results.xml|results.json>...",
+	Short: "Import external test results into history",
+	Long: `Import test results produced outside go-sentinel, such as JUnit XML
+reports from CI or saved 'go test -json' output, into the project history.
+Each file is recorded as a separate run.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		label, _ := cmd.Flags().GetString("label")
+
+		store, _, err := historyStore(cmd)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		for _, path := range args {
+			run, err := cli.ImportResults(path)
+			if err != nil {
+				return fmt.Errorf("error importing results: %v", err)
+			}
+
+			rec := cli.NewHistoryRecord(run, cli.TriggerImport)
+			rec.Label = label
+			if err := store.Append(rec); err != nil {
+				return fmt.Errorf("error recording history: %v", err)
+			}
+			fmt.Printf("Imported %s as run %s (%d tests, %d failed)\n", path, rec.ID, rec.NumTotal, rec.NumFailed)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+
+	importCmd.Flags().String("label", "", "Label recorded with the imported runs, e.g. 'ci'")
+}
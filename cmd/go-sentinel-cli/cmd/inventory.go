@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/newbpydev/go-sentinel/internal/cli"
+	"github.com/spf13/cobra"
+)
+
+var inventoryCmd = &cobra.Command{
+	Use:     "inventory [packages...]",
+	Aliases: []string{"list"},
+	Short:   "Export an inventory of every test, benchmark, and fuzz target",
+	Long: `List every test function of the project: tests, benchmarks, fuzz targets,
+and examples, with the file and line that defines them, the build constraint
+their file requires, and their owners from the ownership file or CODEOWNERS.
+Subtests started with t.Run or b.Run are listed under their test when their
+names are string literals; subtests named at run time, as in table-driven
+tests, cannot be found without running them.
+
+Test files excluded by build constraints, such as integration tests behind
+-tags=integration, are included, so the inventory proves which tests exist
+without running them. Packages default to ./...
+
+Use --format json for an export other tools can audit.`,
+	Annotations: map[string]string{requiresGo: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+		useColors := cli.ColorsEnabled()
+		if format != "text" && format != "json" {
+			return fmt.Errorf("unknown format %q, want text or json", format)
+		}
+
+		dir, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("error getting current directory: %v", err)
+		}
+		inv, err := cli.BuildInventory(dir, args)
+		if err != nil {
+			return fmt.Errorf("error building inventory: %v", err)
+		}
+		if format == "json" {
+			return inv.WriteJSON(os.Stdout)
+		}
+		cli.NewRendererWithStyle(os.Stdout, useColors).RenderInventory(inv)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(inventoryCmd)
+	inventoryCmd.Flags().String("format", "text", "Output format: text or json")
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/newbpydev/go-sentinel/internal/cli"
+	"github.com/spf13/cobra"
+)
+
+var ownerCmd = &cobra.Command{
+	Use:   "owner <package[:TestName]> [owner...]",
+	Short: "Assign owners to a package or test",
+	Long: `Assign owners to a package or to a single test within it, replacing any
+existing owners. With no owners, the assignment is removed.
+
+Assignments are stored in ` + cli.OwnershipFileName + ` in the project root so
+they can be reviewed and versioned. They take precedence over CODEOWNERS.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return editOwnership(args[0], func(o cli.Ownership, target cli.OwnershipTarget) {
+			o.SetOwners(target, args[1:])
+		})
+	},
+}
+
+var tagCmd = &cobra.Command{
+	Use:   "tag <package[:TestName]> <tag>...",
+	Short: "Tag a package or test",
+	Long: `Add tags to a package or to a single test within it. Tags are stored in
+` + cli.OwnershipFileName + ` alongside owners.`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		remove, _ := cmd.Flags().GetBool("remove")
+		return editOwnership(args[0], func(o cli.Ownership, target cli.OwnershipTarget) {
+			if remove {
+				o.RemoveTags(target, args[1:])
+			} else {
+				o.AddTags(target, args[1:])
+			}
+		})
+	},
+}
+
+// editOwnership applies edit to the ownership of target and saves the result
+func editOwnership(arg string, edit func(cli.Ownership, cli.OwnershipTarget)) error {
+	target, err := cli.ParseOwnershipTarget(arg)
+	if err != nil {
+		return err
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("error getting current directory: %v", err)
+	}
+
+	ownership, err := cli.LoadOwnership(dir)
+	if err != nil {
+		return fmt.Errorf("error loading ownership: %v", err)
+	}
+	edit(ownership, target)
+	if err := ownership.Save(dir); err != nil {
+		return fmt.Errorf("error saving ownership: %v", err)
+	}
+
+	owners, tags := ownership.Lookup(target.Package, target.Test)
+	fmt.Printf("%s: owners [%s] tags [%s]\n", arg, strings.Join(owners, " "), strings.Join(tags, " "))
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(ownerCmd, tagCmd)
+
+	tagCmd.Flags().Bool("remove", false, "Remove the tags instead of adding them")
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/newbpydev/go-sentinel/internal/cli"
+	"github.com/spf13/cobra"
+)
+
+var parallelCmd = &cobra.Command{
+	Use:   "parallel [packages...]",
+	Short: "Find the highest -parallel each package passes at",
+	Long: `Run the tests of each package with the race detector at increasing
+-parallel values, 1, 2, 4, and so on up to --max, stopping at the first
+that fails, to find how many of its parallel tests can safely run at once.
+Packages default to ./...
+
+The highest level each package passed at is saved in
+.go-sentinel/parallel.json, and 'run' passes it to the package's test
+binary from then on; use 'run --no-parallel-limits' to ignore it. The
+report lists the packages that only pass serially, and those that fail
+even then.`,
+	Annotations: map[string]string{requiresGo: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		useColors := cli.ColorsEnabled()
+		highest, _ := cmd.Flags().GetInt("max")
+		count, _ := cmd.Flags().GetInt("count")
+		noRace, _ := cmd.Flags().GetBool("no-race")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		dir, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("error getting current directory: %v", err)
+		}
+		recommendations, err := cli.LoadParallelRecommendations(dir)
+		if err != nil {
+			return fmt.Errorf("error loading parallel recommendations: %v", err)
+		}
+
+		renderer := cli.NewRendererWithStyle(os.Stdout, useColors)
+		recs, err := cli.AnalyzeParallelism(dir, args, cli.ParallelAnalysis{
+			Max:      highest,
+			Count:    count,
+			Race:     !noRace,
+			Progress: renderer.RenderParallelProgress,
+		})
+		if err != nil {
+			return fmt.Errorf("error analyzing packages: %v", err)
+		}
+		renderer.RenderParallelAnalysis(recs)
+		if dryRun {
+			return nil
+		}
+		recommendations.Record(recs)
+		if err := recommendations.Save(); err != nil {
+			return fmt.Errorf("error saving parallel recommendations: %v", err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(parallelCmd)
+
+	parallelCmd.Flags().Int("max", 0, "Highest -parallel to try (default GOMAXPROCS)")
+	parallelCmd.Flags().Int("count", 3, "Times the tests run at each level, to catch intermittent failures")
+	parallelCmd.Flags().Bool("no-race", false, "Run without the race detector, as when cgo is unavailable")
+	parallelCmd.Flags().Bool("dry-run", false, "Report the results without saving them for 'run'")
+}
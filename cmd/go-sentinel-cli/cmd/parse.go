@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/newbpydev/go-sentinel/internal/cli"
+	"github.com/spf13/cobra"
+)
+
+var parseCmd = &cobra.Command{
+	Use:   "parse <file.txt|file.json|file.xml>",
+	Short: "Convert saved go test output into a report",
+	Long: `Parse a saved test output file, such as a CI log, and write it as a
+structured report. The input may be plain 'go test' or 'go test -v' output,
+'go test -json' output, or a JUnit XML report; the format is detected from
+the content. Lines prefixed with CI timestamps are understood.
+
+Output formats:
+  junit  JUnit XML, as written by 'run --report-junit'
+  json   the run as recorded in the project history
+  html   a standalone HTML page`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("output")
+		out, _ := cmd.Flags().GetString("out")
+
+		write, err := parseReportWriter(format)
+		if err != nil {
+			return err
+		}
+		run, err := cli.ImportResults(args[0])
+		if err != nil {
+			return fmt.Errorf("error parsing test output: %v", err)
+		}
+
+		if out == "" {
+			return write(os.Stdout, run)
+		}
+		f, err := os.Create(out)
+		if err != nil {
+			return fmt.Errorf("error creating report: %v", err)
+		}
+		if err := write(f, run); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	},
+}
+
+// parseReportWriter returns the writer of a parse --output format
+func parseReportWriter(format string) (func(io.Writer, *cli.TestRun) error, error) {
+	switch format {
+	case "junit":
+		return cli.WriteJUnitReport, nil
+	case "json":
+		return func(w io.Writer, run *cli.TestRun) error {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(cli.NewHistoryRecord(run, cli.TriggerImport))
+		}, nil
+	case "html":
+		return cli.WriteHTMLReport, nil
+	}
+	return nil, fmt.Errorf("unknown output format %q, want junit, json, or html", format)
+}
+
+func init() {
+	rootCmd.AddCommand(parseCmd)
+
+	parseCmd.Flags().StringP("output", "o", "junit", "Report format: junit, json, or html")
+	parseCmd.Flags().String("out", "", "Write the report to this file instead of standard output")
+}
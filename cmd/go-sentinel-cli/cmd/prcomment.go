@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/newbpydev/go-sentinel/internal/cli"
+	"github.com/spf13/cobra"
+)
+
+var prCommentCmd = &cobra.Command{
+	Use:   "pr-comment",
+	Short: "Post a comparison with the base branch to a GitHub pull request",
+	Long: `Compare a recorded run with a run of the pull request's base branch and
+post the result as a comment: new failures, fixed tests, slow-test
+regressions, and, given coverage profiles, the coverage change. The
+comment is updated on later runs instead of adding new ones.
+
+The head run is the latest in history unless --run selects another. The
+base run is the latest run recorded on the base branch, one selected with
+--base-run, or results imported from --base-results (JUnit XML or go test
+-json output), for CI jobs that do not share history.
+
+On GitHub Actions the token, repository, pull request, and base branch
+default to GITHUB_TOKEN, GITHUB_REPOSITORY, GITHUB_REF, and GITHUB_BASE_REF.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		token, _ := cmd.Flags().GetString("token")
+		repo, _ := cmd.Flags().GetString("repo")
+		pr, _ := cmd.Flags().GetInt("pr")
+		runID, _ := cmd.Flags().GetString("run")
+		baseBranch, _ := cmd.Flags().GetString("base")
+		baseRunID, _ := cmd.Flags().GetString("base-run")
+		baseResults, _ := cmd.Flags().GetString("base-results")
+		coverage, _ := cmd.Flags().GetString("coverage")
+		baseCoverage, _ := cmd.Flags().GetString("base-coverage")
+		slowFactor, _ := cmd.Flags().GetFloat64("slow-factor")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		if token == "" {
+			token = os.Getenv("GITHUB_TOKEN")
+		}
+		if repo == "" {
+			repo = os.Getenv("GITHUB_REPOSITORY")
+		}
+		if pr == 0 {
+			pr = pullRequestFromRef(os.Getenv("GITHUB_REF"))
+		}
+		if baseBranch == "" {
+			baseBranch = os.Getenv("GITHUB_BASE_REF")
+		}
+		if baseBranch == "" {
+			baseBranch = "main"
+		}
+		if !dryRun && (token == "" || repo == "" || pr == 0) {
+			return fmt.Errorf("--token, --repo, and --pr are required outside GitHub Actions")
+		}
+		if (coverage == "") != (baseCoverage == "") {
+			return fmt.Errorf("--coverage and --base-coverage must be given together")
+		}
+
+		store, _, err := historyStore(cmd)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		records, err := store.Load()
+		if err != nil {
+			return fmt.Errorf("error loading history: %v", err)
+		}
+		head, err := cli.RecordByID(records, runID)
+		if err != nil {
+			return err
+		}
+
+		var base *cli.HistoryRecord
+		switch {
+		case baseResults != "":
+			run, err := cli.ImportResults(baseResults)
+			if err != nil {
+				return fmt.Errorf("error importing base results: %v", err)
+			}
+			base = cli.NewHistoryRecord(run, cli.TriggerImport)
+			base.Branch = baseBranch
+		case baseRunID != "":
+			if base, err = cli.RecordByID(records, baseRunID); err != nil {
+				return err
+			}
+		default:
+			if base = cli.LatestOnBranch(records, baseBranch); base == nil {
+				return fmt.Errorf("no runs of %s in history; use --base-run or --base-results", baseBranch)
+			}
+		}
+
+		slow := cli.DefaultSlowRegression()
+		slow.Factor = slowFactor
+		comparison := cli.CompareRuns(base, head, slow)
+		if coverage != "" {
+			headReport, err := readCoverageProfile(coverage)
+			if err != nil {
+				return err
+			}
+			baseReport, err := readCoverageProfile(baseCoverage)
+			if err != nil {
+				return err
+			}
+			comparison.CompareCoverage(baseReport, headReport)
+		}
+
+		body := comparison.Markdown()
+		if dryRun {
+			fmt.Print(body)
+			return nil
+		}
+		url, created, err := cli.NewGitHubClient(repo, token).UpsertComment(pr, body)
+		if err != nil {
+			return fmt.Errorf("error posting comment: %v", err)
+		}
+		if created {
+			fmt.Printf("Posted %s\n", url)
+		} else {
+			fmt.Printf("Updated %s\n", url)
+		}
+		return nil
+	},
+}
+
+// pullRequestFromRef extracts the pull request number from a ref such as
+// refs/pull/123/merge, or returns 0
+func pullRequestFromRef(ref string) int {
+	parts := strings.Split(ref, "/")
+	if len(parts) != 4 || parts[0] != "refs" || parts[1] != "pull" {
+		return 0
+	}
+	n, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// readCoverageProfile reads a go test -coverprofile file, which may be kept
+// in blob storage
+func readCoverageProfile(path string) (*cli.CoverageReport, error) {
+	var report *cli.CoverageReport
+	err := cli.ReadBlobFile(path, func(local string) error {
+		f, err := os.Open(local)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		report, err = cli.ReadCoverageReport(f)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading coverage profile: %v", err)
+	}
+	return report, nil
+}
+
+func init() {
+	rootCmd.AddCommand(prCommentCmd)
+
+	prCommentCmd.Flags().String("token", "", "GitHub token allowed to comment on pull requests (default $GITHUB_TOKEN)")
+	prCommentCmd.Flags().String("repo", "", "Repository as owner/name (default $GITHUB_REPOSITORY)")
+	prCommentCmd.Flags().Int("pr", 0, "Pull request number (default from $GITHUB_REF)")
+	prCommentCmd.Flags().String("run", "", "Run of the pull request (ID or unambiguous prefix), defaulting to the latest")
+	prCommentCmd.Flags().String("base", "", "Base branch (default $GITHUB_BASE_REF, else main)")
+	prCommentCmd.Flags().String("base-run", "", "Run to compare with instead of the latest run of the base branch")
+	prCommentCmd.Flags().String("base-results", "", "JUnit XML or go test -json results of the base branch to compare with")
+	prCommentCmd.Flags().String("coverage", "", "Coverage profile of the pull request, to report the coverage change")
+	prCommentCmd.Flags().String("base-coverage", "", "Coverage profile of the base branch")
+	prCommentCmd.Flags().Float64("slow-factor", cli.DefaultSlowRegression().Factor, "How many times slower than on the base branch a test must be to count as a regression")
+	prCommentCmd.Flags().Bool("dry-run", false, "Print the comment instead of posting it")
+	prCommentCmd.MarkFlagsMutuallyExclusive("base-run", "base-results")
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/newbpydev/go-sentinel/internal/cli"
+	"github.com/spf13/cobra"
+)
+
+var reportCmd = &cobra.Command{
+	Use:   "report [packages...] --html <dir>",
+	Short: "Write a static HTML report of test results and coverage",
+	Long: `Run the tests of the given packages once with coverage and write a static
+HTML report to a directory, for keeping as a CI artifact: the run summary,
+package and test durations, each package's results, failures with their
+source, and a coverage heatmap linking to a page per file with the lines
+the tests ran. Packages default to ./...
+
+Use --from to report on saved test output, such as a CI log, instead of
+running the tests, and --profile to add a go test -coverprofile file.
+
+The report and profile can be kept in blob storage by naming them with a
+URL instead of a path: s3://bucket/prefix (AWS_* credentials,
+AWS_ENDPOINT_URL for compatible services) or gs://bucket/prefix
+(GOOGLE_OAUTH_ACCESS_TOKEN). Each file of the report is uploaded under the
+prefix.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out, _ := cmd.Flags().GetString("html")
+		from, _ := cmd.Flags().GetString("from")
+		profile, _ := cmd.Flags().GetString("profile")
+		if out == "" {
+			return fmt.Errorf("error writing report: --html is required")
+		}
+
+		dir, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("error getting current directory: %v", err)
+		}
+		cfg, err := cli.LoadConfig(dir)
+		if err != nil {
+			return fmt.Errorf("error loading config: %v", err)
+		}
+		if len(args) == 0 {
+			args = cfg.Packages
+		}
+
+		var run *cli.TestRun
+		var coverage *cli.CoverageReport
+		if from != "" {
+			if run, err = cli.ImportResults(from); err != nil {
+				return fmt.Errorf("error parsing test output: %v", err)
+			}
+		} else {
+			if _, err := cli.CheckGoToolchain(); err != nil {
+				cmd.SilenceUsage, cmd.SilenceErrors = true, true
+				return err
+			}
+			if run, coverage, err = cli.CollectReport(dir, args); err != nil {
+				return fmt.Errorf("error running tests: %v", err)
+			}
+		}
+		if profile != "" {
+			if coverage, err = readCoverageProfile(profile); err != nil {
+				return err
+			}
+		}
+
+		err = cli.WriteBlobDir(out, func(local string) error {
+			return cli.WriteHTMLSite(local, dir, run, coverage)
+		})
+		if err != nil {
+			return fmt.Errorf("error writing report: %v", err)
+		}
+		index := filepath.Join(out, "index.html")
+		if cli.IsBlobURL(out) {
+			index = strings.TrimSuffix(out, "/") + "/index.html"
+		}
+		fmt.Printf("%d passed, %d failed, %d skipped; report written to %s\n",
+			run.NumPassed, run.NumFailed, run.NumSkipped, index)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(reportCmd)
+
+	reportCmd.Flags().String("html", "", "Directory the HTML report is written to")
+	reportCmd.Flags().String("from", "", "Report on saved go test output or a JUnit XML report instead of running the tests")
+	reportCmd.Flags().String("profile", "", "go test -coverprofile file to report coverage from")
+}
@@ -1,9 +1,13 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"runtime/debug"
+	"time"
 
+	"github.com/newbpydev/go-sentinel/internal/cli"
 	"github.com/spf13/cobra"
 )
 
@@ -18,18 +22,141 @@ Features:
 - Watch mode for continuous testing
 - Detailed test summaries and statistics
 - Support for parallel test execution`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyTerminal(cmd); err != nil {
+			return err
+		}
+		dir, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("error getting current directory: %v", err)
+		}
+		cfg, err := cli.LoadConfig(dir)
+		if err != nil {
+			return fmt.Errorf("error loading config: %v", err)
+		}
+		if err := applyAccessibility(cmd, cfg); err != nil {
+			return err
+		}
+		offline := cfg.Offline
+		if cmd.Flags().Changed("offline") {
+			offline, _ = cmd.Flags().GetBool("offline")
+		}
+		cli.SetOffline(offline)
+		return applyGoToolchain(cmd, cfg)
+	},
+}
+
+// errSilentFailure makes the command exit with a failure status without
+// printing an error, for output modes that already reported the result
+var errSilentFailure = errors.New("tests failed")
+
+// timezone returns the zone timestamps are reported in: the --timezone flag
+// when given, otherwise the project configuration
+func timezone(cmd *cobra.Command, cfg *cli.Config) (*time.Location, error) {
+	name := cfg.Timezone
+	if flag, _ := cmd.Flags().GetString("timezone"); flag != "" {
+		name = flag
+	}
+	loc, err := cli.LoadTimezone(name)
+	if err != nil {
+		return nil, fmt.Errorf("error loading time zone: %v", err)
+	}
+	return loc, nil
+}
+
+// applyTerminal sets up coloring from --color and CI mode from --ci,
+// which is on by default when no one is likely watching: in CI, or with
+// output piped or logged
+func applyTerminal(cmd *cobra.Command) error {
+	value, _ := cmd.Flags().GetString("color")
+	color, err := cli.ParseColorMode(value)
+	if err != nil {
+		return fmt.Errorf("error parsing color: %v", err)
+	}
+	ci := !cli.Interactive()
+	if cmd.Flags().Changed("ci") {
+		ci, _ = cmd.Flags().GetBool("ci")
+	}
+	cli.SetTerminal(color, ci)
+	return nil
+}
+
+// applyAccessibility sets up the status palette and labels from the
+// --palette and --status-text flags, falling back to the project
+// configuration
+func applyAccessibility(cmd *cobra.Command, cfg *cli.Config) error {
+	name := cfg.Accessibility.Palette
+	if flag, _ := cmd.Flags().GetString("palette"); flag != "" {
+		name = flag
+	}
+	palette, err := cli.PaletteByName(name)
+	if err != nil {
+		return fmt.Errorf("error selecting palette: %v", err)
+	}
+	cli.SetPalette(palette)
+
+	statusText := cfg.Accessibility.StatusText
+	if cmd.Flags().Changed("status-text") {
+		statusText, _ = cmd.Flags().GetBool("status-text")
+	}
+	cli.SetStatusText(statusText)
+	return nil
+}
+
+// requiresGo marks, in a command's annotations, the commands that run the
+// go toolchain
+const requiresGo = "requiresGo"
+
+// applyGoToolchain selects the go command from the --go-bin flag, falling
+// back to the project configuration, and for commands that run it checks
+// up front that it exists and is recent enough
+func applyGoToolchain(cmd *cobra.Command, cfg *cli.Config) error {
+	goBin := cfg.GoBin
+	if flag, _ := cmd.Flags().GetString("go-bin"); flag != "" {
+		goBin = flag
+	}
+	cli.SetGoBinary(goBin)
+	if cmd.Annotations[requiresGo] == "" {
+		return nil
+	}
+	if _, err := cli.CheckGoToolchain(); err != nil {
+		// Printed once, without usage, since the message says what to do
+		cmd.SilenceUsage, cmd.SilenceErrors = true, true
+		return err
+	}
+	return nil
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 func Execute() {
+	// Keep log messages and crash reports for support bundles
+	cli.StartLogFile()
+	defer func() {
+		if p := recover(); p != nil {
+			if report := cli.RecordCrash(p, debug.Stack()); report != "" {
+				fmt.Fprintf(os.Stderr, "go-sentinel crashed; please attach 'go-sentinel support-bundle' to a bug report (crash report saved to %s)\n", report)
+			}
+			panic(p)
+		}
+	}()
+
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintln(os.Stderr, err)
+		if !errors.Is(err, errSilentFailure) {
+			fmt.Fprintln(os.Stderr, err)
+		}
 		os.Exit(1)
 	}
 }
 
 func init() {
 	// Here you will define your flags and configuration settings
-	rootCmd.PersistentFlags().BoolP("color", "c", true, "Enable/disable colored output")
+	rootCmd.PersistentFlags().StringP("color", "c", cli.ColorAuto, "Color output: auto for terminals outside CI, always, or never")
+	rootCmd.PersistentFlags().Lookup("color").NoOptDefVal = cli.ColorAlways
+	rootCmd.PersistentFlags().Bool("ci", false, "Plain output for CI logs: no colors or redrawn lines, and passing packages folded on GitHub Actions (default when not on a terminal or in CI)")
 	rootCmd.PersistentFlags().BoolP("watch", "w", false, "Enable watch mode")
+	rootCmd.PersistentFlags().String("palette", "", "Status colors: default, or colorblind for a deuteranopia-safe blue and orange palette (default from config)")
+	rootCmd.PersistentFlags().Bool("status-text", false, "Show PASS, FAIL, and SKIP beside status icons so statuses do not rely on color")
+	rootCmd.PersistentFlags().String("go-bin", "", "go command tests are run with, by name or path (default from config, else go on the PATH)")
+	rootCmd.PersistentFlags().Bool("offline", false, "Never use the network: go commands run with GOPROXY=off, notifications are not sent, and other connections fail (default from config)")
+	rootCmd.PersistentFlags().String("timezone", "", "Time zone of reported timestamps: local, UTC, or an IANA name (default from config, else local)")
 }
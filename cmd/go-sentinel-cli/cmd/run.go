@@ -1,10 +1,22 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"os"
+	"os/signal"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/mattn/go-isatty"
 	"github.com/newbpydev/go-sentinel/internal/cli"
 	"github.com/spf13/cobra"
 )
@@ -13,7 +25,30 @@ var runCmd = &cobra.Command{
 	Use:   "run [flags] [packages]",
 	Short: "Run tests with beautiful output",
 	Long: `Run Go tests with beautiful, Vitest-style output.
-If no packages are specified, runs tests in the current directory and subdirectories.`,
+If no packages are specified, runs tests in the current directory and subdirectories.
+
+Scripts should use --porcelain instead of parsing the human-readable output,
+which changes between releases. Porcelain v1 writes tab-separated records:
+
+  # go-sentinel porcelain v1
+  test <status> <package> <test> <seconds>
+  pkg  <status> <package> <tests> <passed> <failed> <skipped> <seconds>
+  run  <status> <tests> <passed> <failed> <skipped> <seconds>
+
+Status is pass, fail, or skip. The fields of a version never change; later
+versions may add records, which readers should ignore. In watch mode each run
+ends with its run record.
+
+In watch mode, type a command and press Enter:
+
+  s        show session statistics
+  t        with --trace-watch, show why file events did or did not trigger runs
+  l        list the failures of the last run again
+  y [test] copy the command reproducing the first failure, or the failure
+           of the named test, to the clipboard
+  e [pkg]  edit the go test arguments (-run, -count, -tags, -race, ...) and
+           rerun pkg, by default the first failed package`,
+	Annotations: map[string]string{requiresGo: "true"},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// Get working directory
 		dir, err := os.Getwd()
@@ -22,13 +57,204 @@ If no packages are specified, runs tests in the current directory and subdirecto
 		}
 
 		// Get flags
-		useColors, _ := cmd.Flags().GetBool("color")
+		useColors := cli.ColorsEnabled()
 		watchMode, _ := cmd.Flags().GetBool("watch")
 		failFast, _ := cmd.Flags().GetBool("fail-fast")
 		verbose, _ := cmd.Flags().GetBool("verbose")
+		explainSchedule, _ := cmd.Flags().GetBool("explain-schedule")
+		isolate, _ := cmd.Flags().GetBool("isolate")
+		noDaemon, _ := cmd.Flags().GetBool("no-daemon")
+		matrixSpec, _ := cmd.Flags().GetString("matrix")
+		noHistory, _ := cmd.Flags().GetBool("no-history")
+		noNotify, _ := cmd.Flags().GetBool("no-notify")
+		noSummarize, _ := cmd.Flags().GetBool("no-summarize")
+		quiet, _ := cmd.Flags().GetBool("quiet")
+		summaryOnly, _ := cmd.Flags().GetBool("summary-only")
+		silent, _ := cmd.Flags().GetBool("silent")
+		porcelain, _ := cmd.Flags().GetString("porcelain")
+		seed, _ := cmd.Flags().GetInt64("seed")
+		shuffle, _ := cmd.Flags().GetBool("shuffle")
+		bench, _ := cmd.Flags().GetString("bench")
+		noParallelLimits, _ := cmd.Flags().GetBool("no-parallel-limits")
+		coverage, _ := cmd.Flags().GetBool("coverage")
+		rerunVerbose, _ := cmd.Flags().GetBool("rerun-verbose")
+		retries, _ := cmd.Flags().GetInt("retries")
+		quarantine, _ := cmd.Flags().GetBool("quarantine")
+		watchAll, _ := cmd.Flags().GetBool("watch-all")
+		affectedOnly, _ := cmd.Flags().GetBool("affected-only")
+		junitReport, _ := cmd.Flags().GetString("report-junit")
+		sarifReport, _ := cmd.Flags().GetString("report-sarif")
+		requirements, _ := cmd.Flags().GetStringSlice("req")
+		statsFile, _ := cmd.Flags().GetString("stats-file")
+		metricsPort, _ := cmd.Flags().GetInt("metrics-port")
+		metricsHost, _ := cmd.Flags().GetString("metrics-host")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		setupTimeout, _ := cmd.Flags().GetDuration("setup-timeout")
+		deadline, _ := cmd.Flags().GetDuration("deadline")
+		traceWatch, _ := cmd.Flags().GetBool("trace-watch")
+		replayFixtures, _ := cmd.Flags().GetBool("replay-fixtures")
+		refreshFixtures, _ := cmd.Flags().GetString("refresh-fixtures")
+		updateSnapshots, _ := cmd.Flags().GetString("update-snapshots")
+		pick, _ := cmd.Flags().GetBool("pick")
+		grep, _ := cmd.Flags().GetString("grep")
+		shardIndex, _ := cmd.Flags().GetInt("shard-index")
+		shardTotal, _ := cmd.Flags().GetInt("shard-total")
+		shardByFlag, _ := cmd.Flags().GetString("shard-by")
+		shardPlanPath, _ := cmd.Flags().GetString("shard-plan")
+		skipGreen, _ := cmd.Flags().GetBool("skip-green")
+		resultCache, _ := cmd.Flags().GetBool("result-cache")
+		maxFailures, _ := cmd.Flags().GetInt("max-failures")
+		output, _ := cmd.Flags().GetString("output")
+		reporterFlags, _ := cmd.Flags().GetStringArray("reporter")
+		profileName, _ := cmd.Flags().GetString("profile")
+
+		if output != "text" && output != "json-stream" {
+			return fmt.Errorf("error parsing output: unknown format %q, want text or json-stream", output)
+		}
+		// Add the extra reporters; a JSON stream on stdout replaces the
+		// terminal output as --output json-stream does, and nothing else
+		// may share stdout with a machine-readable stream
+		var reporterSpecs []cli.ReporterSpec
+		streams := 0
+		if porcelain != "" || output == "json-stream" {
+			streams++
+		}
+		for _, value := range reporterFlags {
+			spec, err := cli.ParseReporterSpec(value)
+			if err != nil {
+				return fmt.Errorf("error parsing reporter: %v", err)
+			}
+			if spec.Path == "" {
+				streams++
+			}
+			if spec.Format == cli.ReporterJSONStream && spec.Path == "" {
+				output = "json-stream"
+				continue
+			}
+			reporterSpecs = append(reporterSpecs, spec)
+		}
+		if streams > 1 {
+			return fmt.Errorf("error parsing reporter: only one of --porcelain, --output json-stream, and the reporters without a path may write to stdout")
+		}
+		if porcelain != "" && !cli.ValidPorcelainVersion(porcelain) {
+			return fmt.Errorf("error parsing porcelain: unknown version %q, want %s", porcelain, cli.PorcelainVersion)
+		}
+		if metricsPort != 0 && !watchMode {
+			return fmt.Errorf("error parsing metrics-port: only available in watch mode")
+		}
+		if cmd.Flags().Changed("metrics-host") && metricsPort == 0 {
+			return fmt.Errorf("error parsing metrics-host: requires --metrics-port")
+		}
+		if _, err := regexp.Compile(refreshFixtures); err != nil {
+			return fmt.Errorf("error parsing refresh-fixtures: %v", err)
+		}
+		if _, err := regexp.Compile(updateSnapshots); err != nil {
+			return fmt.Errorf("error parsing update-snapshots: %v", err)
+		}
+		grepRE, err := regexp.Compile(grep)
+		if err != nil {
+			return fmt.Errorf("error parsing grep: %v", err)
+		}
+		if shardTotal < 0 {
+			return fmt.Errorf("error parsing shard-total: must not be negative, got %d", shardTotal)
+		}
+		var shardPlan *cli.ShardPlan
+		if shardPlanPath != "" {
+			if shardPlan, err = cli.ReadShardPlan(shardPlanPath); err != nil {
+				return fmt.Errorf("error parsing shard-plan: %v", err)
+			}
+			if shardTotal > 0 && shardTotal != shardPlan.Total {
+				return fmt.Errorf("error parsing shard-total: the shard plan has %d shards, got %d", shardPlan.Total, shardTotal)
+			}
+			shardTotal = shardPlan.Total
+		}
+		if shardTotal > 0 && (shardIndex < 0 || shardIndex >= shardTotal) {
+			return fmt.Errorf("error parsing shard-index: want 0 to %d for %d shards, got %d", shardTotal-1, shardTotal, shardIndex)
+		}
+		if shardTotal > 0 && watchMode {
+			return fmt.Errorf("error parsing shard-total: not available in watch mode")
+		}
+
+		// Expand the build matrix, if any
+		axes, err := cli.ParseMatrix(matrixSpec)
+		if err != nil {
+			return fmt.Errorf("error parsing matrix: %v", err)
+		}
+		matrix, err := cli.ExpandMatrix(axes)
+		if err != nil {
+			return fmt.Errorf("error parsing matrix: %v", err)
+		}
+
+		// Load project configuration
+		cfg, err := cli.LoadConfig(dir)
+		if err != nil {
+			return fmt.Errorf("error loading config: %v", err)
+		}
+		shardSplit, err := shardBy(shardByFlag, cfg.History)
+		if err != nil {
+			return err
+		}
+
+		// Run as the chosen profile: its packages and timeouts replace the
+		// project's, and its build tags and environment are added to runs
+		var profile cli.ProfileConfig
+		if profileName != "" {
+			if profile, err = cfg.Profile(profileName); err != nil {
+				return fmt.Errorf("error loading config: %v", err)
+			}
+			*cfg = profile.Apply(*cfg)
+		}
 
 		// Create renderer with color setting
 		renderer := cli.NewRendererWithStyle(os.Stdout, useColors)
+		columns, err := cli.ParseColumns(cfg.Columns)
+		if err != nil {
+			return fmt.Errorf("error loading config: %v", err)
+		}
+		renderer.SetColumns(columns)
+		loc, err := timezone(cmd, cfg)
+		if err != nil {
+			return err
+		}
+		renderer.SetLocation(loc)
+
+		// Reduce output for scripting; the exit code still reports failures
+		mode := cli.OutputNormal
+		switch {
+		case silent, porcelain != "", output == "json-stream":
+			mode = cli.OutputSilent
+			cli.SetLogConsole(io.Discard)
+		case quiet:
+			mode = cli.OutputQuiet
+		case summaryOnly:
+			mode = cli.OutputSummaryOnly
+		}
+		renderer.SetOutputMode(mode)
+
+		// Cap the failures rendered in detail, pointing at the full list
+		if maxFailures < 0 {
+			return fmt.Errorf("error parsing max-failures: must not be negative")
+		}
+		hint := "rerun with --max-failures 0 to list them all, or --report-junit <file> to write them to a file"
+		switch {
+		case watchMode:
+			hint = "press 'l' and Enter to list them all"
+		case junitReport != "":
+			hint = "all failures are in " + junitReport
+		}
+		renderer.SetMaxFailures(maxFailures, hint)
+		collapse, _ := cmd.Flags().GetBool("collapse-subtests")
+		renderer.SetCollapseSubtests(collapse)
+
+		// Show runs while they execute: a status line redrawn in place on a
+		// terminal, or a line now and then in logs and CI mode
+		if noProgress, _ := cmd.Flags().GetBool("no-progress"); !noProgress {
+			if !cli.CIMode() && (isatty.IsTerminal(os.Stdout.Fd()) || isatty.IsCygwinTerminal(os.Stdout.Fd())) {
+				renderer.SetProgress(cli.ProgressLive)
+			} else {
+				renderer.SetProgress(cli.ProgressPlain)
+			}
+		}
 
 		// Create and configure runner
 		runner, err := cli.NewRunner(dir)
@@ -37,21 +263,331 @@ If no packages are specified, runs tests in the current directory and subdirecto
 		}
 		defer runner.Stop()
 
+		priority, err := cfg.Process.ProcessPriority()
+		if err != nil {
+			return fmt.Errorf("error loading config: %v", err)
+		}
+		budgets, err := cfg.Budgets.DurationBudgets()
+		if err != nil {
+			return fmt.Errorf("error loading config: %v", err)
+		}
+		if budgets != nil && cmd.Flags().Changed("enforce-budgets") {
+			budgets.Fail, _ = cmd.Flags().GetBool("enforce-budgets")
+		}
+		limits, err := cfg.Limits.PackageLimits()
+		if err != nil {
+			return fmt.Errorf("error loading config: %v", err)
+		}
+		if limits, err = limitFlags(cmd, limits); err != nil {
+			return err
+		}
+		runner.SetWatchIgnore(cfg.Watch.Ignore)
+		runner.SetBuildTags(profile.Tags)
+
+		// Flags override the project configuration
+		if !cmd.Flags().Changed("fail-fast") {
+			failFast = cfg.FailFast
+		}
+		if !cmd.Flags().Changed("result-cache") {
+			resultCache = cfg.ResultCache
+		}
+		if !cmd.Flags().Changed("coverage") {
+			coverage = cfg.Coverage.Watch
+		}
+		if !cmd.Flags().Changed("retries") {
+			retries = cfg.Retry.Count
+		}
+		if !cmd.Flags().Changed("quarantine") {
+			quarantine = cfg.Retry.Quarantine
+		}
+		if retries < 0 {
+			return fmt.Errorf("error parsing retries: must not be negative")
+		}
+		if !cmd.Flags().Changed("timeout") {
+			if timeout, err = cfg.TestTimeout(); err != nil {
+				return fmt.Errorf("error loading config: %v", err)
+			}
+		}
+		if !cmd.Flags().Changed("setup-timeout") {
+			if setupTimeout, err = cfg.TestSetupTimeout(); err != nil {
+				return fmt.Errorf("error loading config: %v", err)
+			}
+		}
+		if !cmd.Flags().Changed("deadline") {
+			if deadline, err = cfg.RunDeadline(); err != nil {
+				return fmt.Errorf("error loading config: %v", err)
+			}
+		}
+		if deadline < 0 {
+			return fmt.Errorf("error parsing deadline: must not be negative")
+		}
+		packageTimeouts, err := cfg.TestPackageTimeouts()
+		if err != nil {
+			return fmt.Errorf("error loading config: %v", err)
+		}
+		if packageTimeouts, err = packageTimeoutFlags(cmd, packageTimeouts); err != nil {
+			return err
+		}
+
 		// Set up run options
 		opts := cli.RunOptions{
-			Watch:    watchMode,
-			FailFast: failFast,
-			Renderer: renderer,
+			Watch:           watchMode,
+			FailFast:        failFast,
+			Timeout:         timeout,
+			SetupTimeout:    setupTimeout,
+			PackageTimeouts: packageTimeouts,
+			Deadline:        deadline,
+			Limits:          limits,
+			ExplainSchedule: explainSchedule,
+			Isolate:         isolate,
+			UseDaemon:       !noDaemon,
+			Renderer:        renderer,
+			BuildFlags:      profile.BuildFlags(),
+			Env:             profile.Environ(),
+			GenerateSteps:   cfg.Generate,
+			BeforeHooks:     profile.Before,
+			AfterHooks:      profile.After,
+			Matrix:          matrix,
+			Health:          cfg.Health.WatchHealth(),
+			Priority:        priority,
+			EnvPolicy:       cfg.Env.EnvPolicy(),
+			Budgets:         budgets,
+			Seed:            seed,
+			Shuffle:         shuffle,
+			Bench:           bench,
+			RerunVerbose:    rerunVerbose,
+			Retries:         retries,
+			SelectTests:     watchMode && !watchAll,
+			AffectedOnly:    watchMode && affectedOnly,
+			JUnitReport:     junitReport,
+			SARIFReport:     sarifReport,
+			Requirements:    requirements,
+			ReplayFixtures:  replayFixtures,
+			RefreshFixtures: refreshFixtures,
+			UpdateSnapshots: updateSnapshots,
+		}
+		if porcelain != "" {
+			opts.Porcelain = os.Stdout
+			opts.PorcelainFormat = porcelain
+		}
+		if output == "json-stream" {
+			opts.Events = append(opts.Events, cli.NewJSONStream(os.Stdout))
+		}
+		if len(reporterSpecs) > 0 {
+			reporters, err := cli.NewReporters(reporterSpecs, dir, os.Stdout)
+			if err != nil {
+				return fmt.Errorf("error creating reporters: %v", err)
+			}
+			defer reporters.Close()
+			opts.Events = append(opts.Events, reporters)
+		}
+
+		// Slow down watch mode while running on battery, report the
+		// coverage of saved files when asked, keep session statistics, and
+		// trace watcher decisions when asked
+		if watchMode {
+			opts.Power = cfg.Power.PowerPolicy()
+			if cmd.Flags().Changed("notify") {
+				cfg.Desktop.Notify, _ = cmd.Flags().GetBool("notify")
+			}
+			opts.Desktop = cfg.Desktop.DesktopNotifier()
+			if coverage {
+				opts.Coverage = cli.NewCoverageTracker()
+			}
+			opts.Stats = cli.NewWatchStats()
+			opts.Stats.MetricsFile = statsFile
+			if metricsPort > 0 {
+				addr := net.JoinHostPort(metricsHost, strconv.Itoa(metricsPort))
+				server, err := opts.Stats.ServeMetrics(addr)
+				if err != nil {
+					return fmt.Errorf("error starting metrics server: %v", err)
+				}
+				defer server.Close()
+				fmt.Printf("Serving watch metrics at http://%s/metrics\n", addr)
+			}
+			opts.Keys = watchKeys()
+			if traceWatch {
+				opts.Trace = cli.NewWatchTrace(0)
+			}
+		}
+
+		// Randomized behavior always runs from a recorded seed so the run
+		// can be reproduced with --seed
+		if shuffle && !cmd.Flags().Changed("seed") {
+			opts.Seed = cli.NewSeed()
+		}
+
+		// Record runs in the project history unless disabled
+		if !noHistory && !cfg.History.Disabled {
+			history, err := cli.OpenHistory(dir, cfg.History, loc)
+			if err != nil {
+				return fmt.Errorf("error opening history: %v", err)
+			}
+			defer history.Close()
+			opts.History = history
+			opts.SlowTests = cfg.SlowTests.SlowTestWarning()
+			opts.Green = cli.NewGreenCache(dir)
+		}
+
+		// Hold packages to the -parallel they were found to pass at
+		if !noParallelLimits {
+			if opts.Parallel, err = cli.LoadParallelRecommendations(dir); err != nil {
+				return fmt.Errorf("error loading parallel recommendations: %v", err)
+			}
+		}
+
+		// Keep a list of the tests that pass only on retry
+		if retries > 0 && quarantine {
+			if opts.Quarantine, err = cli.LoadQuarantine(dir); err != nil {
+				return fmt.Errorf("error loading quarantine: %v", err)
+			}
+		}
+
+		// Route runs to notification channels when rules are declared,
+		// except offline. The last recorded run tells whether this one is a
+		// transition.
+		notifyCfg, err := cli.LoadNotifyConfig(dir)
+		if err != nil {
+			return fmt.Errorf("error loading notification rules: %v", err)
+		}
+		if notifyCfg != nil && !noNotify && !cli.Offline() {
+			opts.Notify = cli.NewNotificationRouter(notifyCfg, dir)
+			if opts.History != nil {
+				if err := opts.Notify.BaselineFromHistory(opts.History); err != nil {
+					return fmt.Errorf("error reading history: %v", err)
+				}
+			}
+		}
+
+		// Explain failures with the team's summarizer, when one is configured
+		if !noSummarize {
+			opts.Summarizer = cfg.Summarizer.FailureSummarizer(dir)
 		}
 
 		// If packages were specified, add them to options
 		if len(args) > 0 {
 			opts.Packages = args
+		} else if len(cfg.Packages) > 0 {
+			opts.Packages = cfg.Packages
 		}
 
-		// Run tests
+		// Let the user narrow the run to the tests picked in a fuzzy finder
+		if pick {
+			catalogs, err := cli.BuildCatalog(dir, opts.Packages)
+			if err != nil {
+				return fmt.Errorf("error listing tests: %v", err)
+			}
+			sel, err := cli.PickTests(cli.TestCandidates(catalogs))
+			if err != nil {
+				return fmt.Errorf("error picking tests: %v", err)
+			}
+			if sel == nil {
+				return nil
+			}
+			opts.Packages = sel.Packages
+			opts.Tests = sel.RunPatterns()
+		}
+
+		// Run the tests named like a pattern wherever they are. Only the
+		// packages declaring a match run, and as -run is shared by them,
+		// the exact names are matched: a name matching in one package
+		// matches the pattern wherever else it is declared.
+		if grep != "" {
+			catalogs, err := cli.BuildCatalog(dir, opts.Packages)
+			if err != nil {
+				return fmt.Errorf("error listing tests: %v", err)
+			}
+			matched := cli.GrepTests(catalogs, grepRE)
+			if len(matched) == 0 {
+				return fmt.Errorf("error selecting tests: no test names match %q", grep)
+			}
+			sel := cli.NewTestSelection(matched)
+			renderer.RenderGrepSelection(grep, sel)
+			opts.Packages = sel.Packages
+			opts.Tests = sel.RunPatterns()
+		}
+
+		// Run this machine's part of a suite split across CI machines. Each
+		// one computes the same plan from the tests in the tree and, when
+		// they share it, the recorded history, or reads the plan they share.
+		if shardTotal > 0 {
+			catalogs, err := cli.BuildCatalog(dir, opts.Packages)
+			if err != nil {
+				return fmt.Errorf("error listing tests: %v", err)
+			}
+			plan := shardPlan
+			if plan != nil {
+				plan.Complete(cli.TestCandidates(catalogs))
+			} else {
+				var records []*cli.HistoryRecord
+				if shardSplit == cli.ShardByDuration && opts.History != nil {
+					if records, err = opts.History.Load(); err != nil {
+						return fmt.Errorf("error loading history: %v", err)
+					}
+				}
+				if plan, err = cli.PlanShards(cli.TestCandidates(catalogs), shardTotal, records); err != nil {
+					return fmt.Errorf("error planning shards: %v", err)
+				}
+			}
+			renderer.RenderShardSelection(plan, shardIndex)
+			shard := plan.Shards[shardIndex]
+			if len(shard.Tests) == 0 {
+				return nil
+			}
+			sel := shard.Selection()
+			opts.Packages = sel.Packages
+			opts.Tests = sel.RunPatterns()
+			opts.ShardIndex, opts.ShardTotal = shardIndex, shardTotal
+		}
+
+		// Serve unchanged packages that passed before without running them
+		if resultCache {
+			opts.ResultCache = cli.NewResultCache(dir)
+		}
+
+		// Leave out the packages CI, or an earlier run, proved green at the
+		// checked-out commit with the same sources
+		if skipGreen && !watchMode {
+			commit := cli.HeadCommit(dir)
+			if commit == "" {
+				return fmt.Errorf("error skipping green packages: not in a git repository")
+			}
+			remaining, skipped, err := cli.NewGreenCache(dir).Filter(commit, opts.Packages, opts.BuildFlags)
+			if err != nil {
+				return fmt.Errorf("error skipping green packages: %v", err)
+			}
+			renderer.RenderGreenSkip(commit, skipped, len(remaining))
+			if len(remaining) == 0 {
+				return nil
+			}
+			if len(skipped) > 0 {
+				opts.Packages = remaining
+			}
+		}
+
+		// Run tests. With hooks to tear down, an interrupt ends a watch
+		// session through its context so the after hooks still run.
 		ctx := context.Background()
+		if watchMode && len(opts.AfterHooks) > 0 {
+			var stop context.CancelFunc
+			ctx, stop = signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+			defer stop()
+		}
 		if err := runner.Run(ctx, opts); err != nil {
+			if (mode != cli.OutputNormal || !verbose) && errors.Is(err, cli.ErrTestsFailed) {
+				// The summary already reported the failures; repeating the
+				// raw output would bury it, and the cap on rendered failures
+				// with it. --verbose keeps the raw output for debugging.
+				cmd.SilenceErrors = true
+				cmd.SilenceUsage = true
+				return errSilentFailure
+			}
+			if errors.Is(err, cli.ErrBudgetExceeded) {
+				// The summary already lists the packages over budget
+				cmd.SilenceErrors = true
+				cmd.SilenceUsage = true
+				return errSilentFailure
+			}
 			if verbose {
 				return fmt.Errorf("error running tests: %v", err)
 			}
@@ -62,10 +598,141 @@ If no packages are specified, runs tests in the current directory and subdirecto
 	},
 }
 
+// watchKeys forwards the lines typed at the terminal as watch mode
+// commands, or returns nil when input is not a terminal
+func watchKeys() <-chan string {
+	if !isatty.IsTerminal(os.Stdin.Fd()) && !isatty.IsCygwinTerminal(os.Stdin.Fd()) {
+		return nil
+	}
+	keys := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			keys <- strings.TrimSpace(scanner.Text())
+		}
+	}()
+	return keys
+}
+
+// limitFlags overrides the default resource limits of the configuration
+// with the --max-* flags given
+func limitFlags(cmd *cobra.Command, limits *cli.PackageLimits) (*cli.PackageLimits, error) {
+	var flags cli.ResourceLimits
+	if value, _ := cmd.Flags().GetString("max-memory"); value != "" {
+		memory, err := cli.ParseByteSize(value)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing max-memory: %v", err)
+		}
+		flags.Memory = memory
+	}
+	flags.Files, _ = cmd.Flags().GetInt("max-files")
+	flags.CPU, _ = cmd.Flags().GetDuration("max-cpu")
+	if flags.Files < 0 || flags.CPU < 0 {
+		return nil, fmt.Errorf("error parsing limits: max-files and max-cpu must not be negative")
+	}
+	if flags.IsZero() {
+		return limits, nil
+	}
+	if limits == nil {
+		limits = &cli.PackageLimits{}
+	}
+	if flags.Memory > 0 {
+		limits.Default.Memory = flags.Memory
+	}
+	if flags.Files > 0 {
+		limits.Default.Files = flags.Files
+	}
+	if flags.CPU > 0 {
+		limits.Default.CPU = flags.CPU
+	}
+	return limits, nil
+}
+
+// packageTimeoutFlags adds the --package-timeout flags given to the
+// package timeouts of the configuration, replacing those of the same
+// pattern
+func packageTimeoutFlags(cmd *cobra.Command, timeouts []cli.PackageTimeout) ([]cli.PackageTimeout, error) {
+	values, _ := cmd.Flags().GetStringArray("package-timeout")
+	for _, value := range values {
+		pattern, duration, ok := strings.Cut(value, "=")
+		timeout, err := time.ParseDuration(duration)
+		if !ok || pattern == "" || err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("error parsing package-timeout %q: want <pattern>=<duration>, such as ./integration/...=20m", value)
+		}
+		timeouts = slices.DeleteFunc(timeouts, func(t cli.PackageTimeout) bool { return t.Pattern == pattern })
+		timeouts = append(timeouts, cli.PackageTimeout{Pattern: pattern, Timeout: timeout})
+	}
+	return timeouts, nil
+}
+
 func init() {
 	rootCmd.AddCommand(runCmd)
 
 	// Add run-specific flags
 	runCmd.Flags().BoolP("verbose", "v", false, "Enable verbose output")
-	runCmd.Flags().BoolP("fail-fast", "f", false, "Stop on first failure")
+	runCmd.Flags().BoolP("fail-fast", "f", false, "Stop on first failure (default from config)")
+	runCmd.Flags().Duration("timeout", 0, "Limit on each test binary, passed to go test -timeout (default from config, else 10m)")
+	runCmd.Flags().StringArray("package-timeout", nil, "Timeout of the packages matching a pattern, as <pattern>=<duration>, overriding --timeout; repeatable (default from config)")
+	runCmd.Flags().Duration("deadline", 0, "Limit on the whole run; packages still running then time out (default from config, else none)")
+	runCmd.Flags().Duration("setup-timeout", 0, "Limit on TestMain setup before the first test of a package starts (default from config, else none)")
+	runCmd.Flags().String("max-memory", "", "Memory each test binary may use, such as 2GB (default from config, else none)")
+	runCmd.Flags().Int("max-files", 0, "Open files each test binary may have (default from config, else none)")
+	runCmd.Flags().Duration("max-cpu", 0, "CPU time each test binary may use (default from config, else none)")
+	runCmd.Flags().Bool("enforce-budgets", false, "Fail the run when a package exceeds its configured duration budget (default from config)")
+	runCmd.Flags().Bool("explain-schedule", false, "Show how packages were selected, ordered, and assigned to workers")
+	runCmd.Flags().Bool("isolate", false, "Run each package in an isolated temporary copy of its directory")
+	runCmd.Flags().Bool("no-daemon", false, "Run go test directly even when a go-sentinel daemon serves the module")
+	runCmd.Flags().Bool("no-history", false, "Do not record this run in the project history")
+	runCmd.Flags().Bool("result-cache", false, "Report packages that passed before with unchanged sources as (cached) without running them (default from config)")
+	runCmd.Flags().Bool("skip-green", false, "Skip the packages proven green at the checked-out commit, as imported with 'cache import' (not in watch mode)")
+	runCmd.Flags().Bool("no-notify", false, "Do not send notifications to the configured channels")
+	runCmd.Flags().Bool("notify", false, "In watch mode, show a desktop notification when the tests start failing or pass again (default from config)")
+	runCmd.Flags().Bool("no-summarize", false, "Do not send failures to the configured summarizer")
+	runCmd.Flags().Bool("no-progress", false, "Do not show which packages are running while tests execute")
+	runCmd.Flags().BoolP("quiet", "q", false, "Print only a one-line summary")
+	runCmd.Flags().Bool("summary-only", false, "Print only the final summary, without per-test output")
+	runCmd.Flags().Bool("silent", false, "Print nothing; report the result through the exit code only")
+	runCmd.Flags().String("porcelain", "", "Print only tab-separated test, pkg, and run records in a format that stays stable across releases, for scripts; the value is the format version")
+	runCmd.Flags().Lookup("porcelain").NoOptDefVal = cli.PorcelainVersion
+	runCmd.Flags().String("output", "text", "Output format: text, or json-stream for newline-delimited JSON events (run_start, test_start, test_pass, test_fail, test_skip, package_end, run_summary)")
+	runCmd.MarkFlagsMutuallyExclusive("porcelain", "output")
+	runCmd.Flags().StringArray("reporter", nil, "Also report each run as format[=path], repeatable: json-stream, junit, sarif, or github for GitHub Actions annotations; json-stream and github write to stdout without a path")
+	runCmd.MarkFlagsMutuallyExclusive("quiet", "summary-only", "silent")
+	runCmd.Flags().Int64("seed", 0, "Seed for randomized behavior such as --shuffle; pass the seed printed by an earlier run to reproduce it")
+	runCmd.Flags().Bool("shuffle", false, "Run tests in random order; the seed is printed in the summary")
+	runCmd.Flags().Bool("no-parallel-limits", false, "Run every package at go's default -parallel, ignoring the limits found by 'go-sentinel parallel'")
+	runCmd.Flags().String("bench", "", "Also run the benchmarks matching this regular expression with -benchmem and record their results for 'trends --benchmarks'")
+	runCmd.Flags().Bool("watch-all", false, "In watch mode, rerun every package on each change instead of only the affected tests")
+	runCmd.Flags().Bool("affected-only", false, "In watch mode, rerun only the changed packages and the packages importing them when the affected tests cannot be narrowed further")
+	runCmd.Flags().Int("retries", 0, "Rerun each failed test up to this many times before it counts as failed (default from config)")
+	runCmd.Flags().Bool("quarantine", false, "Record tests that pass only on retry in .go-sentinel/quarantine.json (default from config)")
+	runCmd.Flags().Bool("rerun-verbose", false, "Rerun each failed test alone with -v -count=1 and show its full output")
+	runCmd.Flags().Bool("coverage", false, "In watch mode, show how each saved file's coverage changed after the rerun (default from config)")
+	runCmd.Flags().Bool("collapse-subtests", false, "Show passing tests without their subtests, which stay counted beside them; in watch mode 'c' toggles this")
+	runCmd.Flags().Int("max-failures", 50, "Render at most this many failed tests in detail, 0 for all; reports still include every failure")
+	runCmd.Flags().Bool("pick", false, "Choose the tests to run in a fuzzy finder; Tab marks tests, Enter runs them")
+	runCmd.Flags().String("grep", "", "Run the tests whose function name matches this regular expression, in every package declaring one")
+	runCmd.Flags().Int("shard-index", 0, "With --shard-total, the shard of the tests to run, from 0")
+	runCmd.Flags().Int("shard-total", 0, "Split the tests into this many shards and run the one chosen with --shard-index")
+	runCmd.Flags().String("shard-by", "", "Split shards by a hash of the test names or by recorded duration (default duration with a shared postgres history, else hash)")
+	runCmd.Flags().String("shard-plan", "", "Run --shard-index of the plan in this file, written by 'shard plan --json', instead of planning shards on this machine")
+	runCmd.Flags().StringSlice("req", nil, "Only run tests annotated with // sentinel:req=<ID> for these requirements")
+	runCmd.Flags().Bool("trace-watch", false, "In watch mode, record every file event and why it did or did not trigger tests; press 't' and Enter to show them")
+	runCmd.Flags().Int("metrics-port", 0, "In watch mode, serve session statistics for Prometheus at /metrics on this port")
+	runCmd.Flags().String("metrics-host", "127.0.0.1", "Address --metrics-port listens on; use 0.0.0.0 to let other machines scrape the metrics")
+	runCmd.Flags().String("stats-file", "", "In watch mode, write session statistics to this file in the Prometheus text format after each run")
+	runCmd.Flags().Bool("replay-fixtures", false, "Only replay HTTP cassettes, failing tests whose cassette is missing instead of recording it")
+	runCmd.Flags().String("refresh-fixtures", "", "Re-record the HTTP cassettes of tests matching this regular expression; all tests when given without a value")
+	runCmd.Flags().Lookup("refresh-fixtures").NoOptDefVal = "."
+	runCmd.Flags().String("update-snapshots", "", "Rewrite the differing snapshots of tests matching this regular expression; all tests when given without a value")
+	runCmd.Flags().Lookup("update-snapshots").NoOptDefVal = "."
+	runCmd.MarkFlagsMutuallyExclusive("replay-fixtures", "refresh-fixtures")
+	runCmd.MarkFlagsMutuallyExclusive("pick", "req", "grep", "shard-total")
+	runCmd.MarkFlagsMutuallyExclusive("pick", "req", "grep", "shard-plan")
+	runCmd.MarkFlagsMutuallyExclusive("shard-by", "shard-plan")
+	runCmd.MarkFlagsMutuallyExclusive("watch-all", "affected-only")
+	runCmd.Flags().String("report-junit", "", "Write a JUnit XML report of each run to this path or s3://, gs:// URL, for CI systems to annotate")
+	runCmd.Flags().String("report-sarif", "", "Write a SARIF report of each run's failures to this path or s3://, gs:// URL, for code scanning and editors to annotate")
+	runCmd.Flags().String("profile", "", "Run as the named profile from the config, with its build tags, packages, environment, and timeouts")
+	runCmd.Flags().String("matrix", "", "Run packages under each combination of build settings, e.g. 'race=[on,off] tags=[fast,slow]'")
 }
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunCmd_ShardEnv(t *testing.T) {
+	dir := t.TempDir()
+	out := t.TempDir()
+	var src strings.Builder
+	src.WriteString("package example\n\nimport (\n\t\"os\"\n\t\"path/filepath\"\n\t\"testing\"\n)\n")
+	for _, name := range []string{"A", "B", "C", "D", "E", "F"} {
+		fmt.Fprintf(&src, "\nfunc Test%s(t *testing.T) {\n\tos.WriteFile(filepath.Join(os.Getenv(\"SHARD_OUT\"), t.Name()), []byte(os.Getenv(\"SENTINEL_SHARD\")), 0644)\n}\n", name)
+	}
+	files := map[string]string{
+		"go.mod":          "module example\n\ngo 1.23\n",
+		"example_test.go": src.String(),
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("SHARD_OUT", out)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	for index := 0; index < 2; index++ {
+		rootCmd.SetArgs([]string{"run", "--no-history", "--color=never", "--shard-total=2", fmt.Sprintf("--shard-index=%d", index)})
+		if err := rootCmd.Execute(); err != nil {
+			t.Fatalf("shard %d: %v", index, err)
+		}
+	}
+
+	// Every test ran once, on a shard it was told about
+	seen := make(map[string]bool)
+	for _, name := range []string{"A", "B", "C", "D", "E", "F"} {
+		data, err := os.ReadFile(filepath.Join(out, "Test"+name))
+		if err != nil {
+			t.Fatalf("Test%s did not run: %v", name, err)
+		}
+		shard := string(data)
+		if shard != "0/2" && shard != "1/2" {
+			t.Errorf("Test%s saw SENTINEL_SHARD=%q, want 0/2 or 1/2", name, shard)
+		}
+		seen[shard] = true
+	}
+	if len(seen) != 2 {
+		t.Errorf("shards seen = %v, want both", seen)
+	}
+}
+
+func TestRunCmd_MetricsHost(t *testing.T) {
+	flag := runCmd.Flags().Lookup("metrics-host")
+	if flag.DefValue != "127.0.0.1" {
+		t.Errorf("metrics-host default = %q, want the loopback address", flag.DefValue)
+	}
+	t.Cleanup(func() {
+		flag.Value.Set(flag.DefValue)
+		flag.Changed = false
+	})
+
+	rootCmd.SetArgs([]string{"run", "--no-history", "--metrics-host=0.0.0.0"})
+	if err := rootCmd.Execute(); err == nil || !strings.Contains(err.Error(), "requires --metrics-port") {
+		t.Errorf("Execute() error = %v, want --metrics-host rejected without --metrics-port", err)
+	}
+}
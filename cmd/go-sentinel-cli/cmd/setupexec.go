@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/newbpydev/go-sentinel/internal/cli"
+	"github.com/spf13/cobra"
+)
+
+// setupExecCmd runs a test binary for go test -exec, stopping it when
+// TestMain setup outlives run --setup-timeout or the binary outlives its
+// package timeout or the run deadline, under the resource limits and
+// -parallel of its package
+var setupExecCmd = &cobra.Command{
+	Use:    cli.SetupExecCommand + " --timeout=<duration> <test binary> [args...]",
+	Short:  "Run a test binary under its resource limits, stopping it when no test starts in time",
+	Hidden: true,
+	Args:   cobra.MinimumNArgs(1),
+	// The test binary runs in its package directory, where the project
+	// configuration the root command loads does not apply
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		if timeout < 0 {
+			return fmt.Errorf("error running test binary: --timeout must not be negative")
+		}
+		// go test runs test binaries in their package directory
+		dir, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("error getting current directory: %v", err)
+		}
+		code, err := cli.RunSetupWatchdog(timeout, cli.LimitsFromEnv(dir), cli.TimeoutFromEnv(dir), args[0], cli.ParallelArgs(dir, args[1:]), os.Stdout, os.Stderr)
+		if err != nil {
+			return fmt.Errorf("error running test binary: %v", err)
+		}
+		os.Exit(code)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(setupExecCmd)
+
+	setupExecCmd.Flags().Duration("timeout", 0, "Limit on setup before the first test starts, 0 for none")
+	// Flags after the test binary belong to it
+	setupExecCmd.Flags().SetInterspersed(false)
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/newbpydev/go-sentinel/internal/cli"
+	"github.com/spf13/cobra"
+)
+
+var shardCmd = &cobra.Command{
+	Use:   "shard",
+	Short: "Split a suite across CI machines",
+	Long: `Tests can be split across the machines of a CI job with
+'run --shard-total N --shard-index I', each machine running shard I of N.
+Every machine must split the suite the same way, or a test may run on no
+shard or on two. By default tests are spread by a hash of their names,
+which only depends on the tree. --shard-by duration balances them by the
+durations recorded in the history instead; it is the default when the
+history is shared by every machine, as a postgres history is, since local
+histories differ between machines. To balance by durations without a
+shared history, write the plan once with 'shard plan --json' and pass it to
+every machine with 'run --shard-plan'. Each machine prints the hash of its
+plan, so mismatches show. Tests of the same name in different packages
+share a shard.`,
+}
+
+var shardPlanCmd = &cobra.Command{
+	Use:   "plan [packages...]",
+	Short: "Show how the tests would be split into shards",
+	Long: `Show the tests each shard would run with 'run --shard-total', and the
+estimated duration of each when the split is by recorded durations.
+Packages default to ./...; use --json for a machine-readable plan.`,
+	Annotations: map[string]string{requiresGo: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		useColors := cli.ColorsEnabled()
+		total, _ := cmd.Flags().GetInt("shard-total")
+		asJSON, _ := cmd.Flags().GetBool("json")
+		by, _ := cmd.Flags().GetString("shard-by")
+
+		dir, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("error getting current directory: %v", err)
+		}
+		catalogs, err := cli.BuildCatalog(dir, args)
+		if err != nil {
+			return fmt.Errorf("error listing tests: %v", err)
+		}
+		cfg, err := cli.LoadConfig(dir)
+		if err != nil {
+			return fmt.Errorf("error loading config: %v", err)
+		}
+		by, err = shardBy(by, cfg.History)
+		if err != nil {
+			return err
+		}
+		var records []*cli.HistoryRecord
+		if by == cli.ShardByDuration {
+			store, _, err := historyStore(cmd)
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+			if records, err = store.Load(); err != nil {
+				return fmt.Errorf("error loading history: %v", err)
+			}
+		}
+		plan, err := cli.PlanShards(cli.TestCandidates(catalogs), total, records)
+		if err != nil {
+			return fmt.Errorf("error planning shards: %v", err)
+		}
+
+		if asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(plan)
+		}
+		cli.NewRendererWithStyle(os.Stdout, useColors).RenderShardPlan(plan)
+		return nil
+	},
+}
+
+// shardBy returns how to split tests into shards: as asked, or by recorded
+// durations only when every machine shares the history
+func shardBy(by string, history cli.HistoryConfig) (string, error) {
+	switch by {
+	case "":
+		if history.Shared() && !history.Disabled {
+			return cli.ShardByDuration, nil
+		}
+		return cli.ShardByHash, nil
+	case cli.ShardByHash, cli.ShardByDuration:
+		return by, nil
+	}
+	return "", fmt.Errorf("error parsing shard-by: want %s or %s, got %q", cli.ShardByHash, cli.ShardByDuration, by)
+}
+
+func init() {
+	rootCmd.AddCommand(shardCmd)
+	shardCmd.AddCommand(shardPlanCmd)
+
+	shardPlanCmd.Flags().Int("shard-total", 0, "Number of shards to split the tests into")
+	shardPlanCmd.Flags().Bool("json", false, "Write the plan as JSON, for run --shard-plan")
+	shardPlanCmd.Flags().String("shard-by", "", "Split by hash of the test names or by recorded duration (default duration with a shared postgres history, else hash)")
+	shardPlanCmd.MarkFlagRequired("shard-total")
+}
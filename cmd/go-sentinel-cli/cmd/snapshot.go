@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/mattn/go-isatty"
+	"github.com/newbpydev/go-sentinel/internal/cli"
+	"github.com/spf13/cobra"
+)
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Manage golden snapshots written by package snapshot",
+	Long: `Manage the golden snapshots tests check with
+github.com/newbpydev/go-sentinel/pkg/snapshot.
+
+When a value no longer matches its snapshot, the test fails and the new value
+is kept beside the snapshot as a .snap.new file. Review the differences with
+'snapshot review', or accept them all with 'snapshot update'. Snapshots can
+also be rewritten while testing with 'run --update-snapshots'.`,
+}
+
+var snapshotUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Accept the new values of mismatched snapshots",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pending, err := pendingSnapshots(cmd)
+		if err != nil {
+			return err
+		}
+		for _, p := range pending {
+			if err := p.Accept(); err != nil {
+				return fmt.Errorf("error updating snapshots: %v", err)
+			}
+			fmt.Printf("updated %s\n", p.Rel)
+		}
+		fmt.Printf("%d %s updated\n", len(pending), pluralSnapshots(len(pending)))
+		return nil
+	},
+}
+
+var snapshotReviewCmd = &cobra.Command{
+	Use:   "review",
+	Short: "Step through mismatched snapshots, accepting or rejecting each",
+	Long: `Show the diff between each mismatched snapshot and its new value, and
+accept (a), reject (r), or skip (s) it. Skipped snapshots stay pending. When
+input is not a terminal, the diffs are printed instead.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pending, err := pendingSnapshots(cmd)
+		if err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			fmt.Println("No mismatched snapshots")
+			return nil
+		}
+
+		if !isatty.IsTerminal(os.Stdin.Fd()) && !isatty.IsCygwinTerminal(os.Stdin.Fd()) {
+			for _, p := range pending {
+				diff, err := cli.FormatSnapshotDiff(p)
+				if err != nil {
+					return fmt.Errorf("error reading snapshot: %v", err)
+				}
+				fmt.Printf("%s\n%s\n", p.Rel, diff)
+			}
+			return nil
+		}
+
+		review, err := cli.ReviewSnapshots(pending)
+		if err != nil {
+			return fmt.Errorf("error reviewing snapshots: %v", err)
+		}
+		pendingLeft := len(pending) - review.Accepted - review.Rejected
+		fmt.Printf("%d accepted, %d rejected, %d still pending\n", review.Accepted, review.Rejected, pendingLeft)
+		return nil
+	},
+}
+
+var snapshotStaleCmd = &cobra.Command{
+	Use:   "stale [packages]",
+	Short: "List snapshots whose test no longer exists",
+	Long: `List the snapshots named after test functions that no longer exist in
+their package, such as after a test was renamed or removed. Snapshots of
+subtests are checked by their parent test only. With --prune, the stale
+snapshots are deleted.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		prune, _ := cmd.Flags().GetBool("prune")
+		dir, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("error getting current directory: %v", err)
+		}
+
+		stale, err := cli.FindStaleSnapshots(dir, args)
+		if err != nil {
+			return fmt.Errorf("error finding stale snapshots: %v", err)
+		}
+		for _, s := range stale {
+			if prune {
+				if err := os.Remove(s.Path); err != nil {
+					return fmt.Errorf("error pruning snapshots: %v", err)
+				}
+				fmt.Printf("removed %s (no %s in %s)\n", s.Rel, s.Test, s.Package)
+			} else {
+				fmt.Printf("%s (no %s in %s)\n", s.Rel, s.Test, s.Package)
+			}
+		}
+		switch {
+		case len(stale) == 0:
+			fmt.Println("No stale snapshots")
+		case prune:
+			fmt.Printf("%d stale %s removed\n", len(stale), pluralSnapshots(len(stale)))
+		default:
+			fmt.Printf("%d stale %s; rerun with --prune to remove them\n", len(stale), pluralSnapshots(len(stale)))
+		}
+		return nil
+	},
+}
+
+// pendingSnapshots returns the pending snapshots under the current
+// directory matching the --run flag
+func pendingSnapshots(cmd *cobra.Command) ([]*cli.PendingSnapshot, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("error getting current directory: %v", err)
+	}
+	var run *regexp.Regexp
+	if pattern, _ := cmd.Flags().GetString("run"); pattern != "" {
+		if run, err = regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("error parsing run: %v", err)
+		}
+	}
+	pending, err := cli.FindPendingSnapshots(dir, run)
+	if err != nil {
+		return nil, fmt.Errorf("error finding snapshots: %v", err)
+	}
+	return pending, nil
+}
+
+// pluralSnapshots returns "snapshot" or "snapshots" for n
+func pluralSnapshots(n int) string {
+	if n == 1 {
+		return "snapshot"
+	}
+	return "snapshots"
+}
+
+func init() {
+	rootCmd.AddCommand(snapshotCmd)
+	snapshotCmd.AddCommand(snapshotUpdateCmd, snapshotReviewCmd, snapshotStaleCmd)
+
+	for _, c := range []*cobra.Command{snapshotUpdateCmd, snapshotReviewCmd} {
+		c.Flags().String("run", "", "Only snapshots whose name, such as TestInvoice/eu-totals, matches this regular expression")
+	}
+	snapshotStaleCmd.Flags().Bool("prune", false, "Delete the stale snapshots")
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/newbpydev/go-sentinel/internal/cli"
+	"github.com/spf13/cobra"
+)
+
+var stateCmd = &cobra.Command{
+	Use:   "state",
+	Short: "Export or import go-sentinel state",
+	Long: `Move accumulated go-sentinel state between machines or seed CI caches.
+State covers everything under .go-sentinel (history, baselines, quarantine
+files) and the package cache metadata for the project.
+
+Archives can be kept in blob storage by naming them with a URL instead of
+a path: s3://bucket/key (AWS_* credentials, AWS_ENDPOINT_URL for compatible
+services) or gs://bucket/key (GOOGLE_OAUTH_ACCESS_TOKEN).`,
+}
+
+var stateExportCmd = &cobra.Command{
+	Use:   "export <file.tar.zst|file.tar.gz|file.tar|url>",
+	Short: "Write the project's state to an archive",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("error getting current directory: %v", err)
+		}
+
+		var n int
+		err = cli.WriteBlobFile(args[0], func(dest string) error {
+			n, err = cli.ExportState(cli.NewStateLocation(dir), dest)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("error exporting state: %v", err)
+		}
+		fmt.Printf("Exported %d %s to %s\n", n, plural("file", n), args[0])
+		return nil
+	},
+}
+
+var stateImportCmd = &cobra.Command{
+	Use:   "import <file.tar.zst|file.tar.gz|file.tar|url>",
+	Short: "Restore the project's state from an archive",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		force, _ := cmd.Flags().GetBool("force")
+
+		dir, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("error getting current directory: %v", err)
+		}
+
+		var n int
+		err = cli.ReadBlobFile(args[0], func(src string) error {
+			n, err = cli.ImportState(cli.NewStateLocation(dir), src, force)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("error importing state: %v", err)
+		}
+		fmt.Printf("Imported %d %s from %s\n", n, plural("file", n), args[0])
+		return nil
+	},
+}
+
+// plural returns word with an "s" appended unless n is 1
+func plural(word string, n int) string {
+	if n == 1 {
+		return word
+	}
+	return word + "s"
+}
+
+func init() {
+	rootCmd.AddCommand(stateCmd)
+	stateCmd.AddCommand(stateExportCmd, stateImportCmd)
+
+	stateImportCmd.Flags().Bool("force", false, "Replace existing state files")
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/newbpydev/go-sentinel/internal/cli"
+	"github.com/spf13/cobra"
+)
+
+var supportBundleCmd = &cobra.Command{
+	Use:   "support-bundle [file.tar.gz|file.tar.zst|file.tar]",
+	Short: "Gather diagnostics for a bug report against go-sentinel",
+	Long: `Write an archive to attach to bug reports against go-sentinel itself. It
+holds the go-sentinel version, environment diagnostics such as the OS, Go
+toolchain, and go env, the project's configuration files, go-sentinel's
+recent log messages, and its last crash reports.
+
+Secrets are redacted: configuration values whose keys name tokens,
+passwords, keys, or URLs, credentials in any other URL, and environment
+variables that look like secrets. Review the archive before sharing it.
+The file defaults to go-sentinel-support-<time>.tar.gz.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("error getting current directory: %v", err)
+		}
+		dest := "go-sentinel-support-" + time.Now().Format("20060102-150405") + ".tar.gz"
+		if len(args) > 0 {
+			dest = args[0]
+		}
+
+		files, err := cli.WriteSupportBundle(dest, dir)
+		if err != nil {
+			return fmt.Errorf("error writing support bundle: %v", err)
+		}
+		fmt.Printf("Wrote %s with %d %s:\n", dest, len(files), plural("file", len(files)))
+		for _, name := range files {
+			fmt.Printf("  %s\n", name)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(supportBundleCmd)
+}
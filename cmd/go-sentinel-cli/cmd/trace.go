@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/newbpydev/go-sentinel/internal/cli"
+	"github.com/spf13/cobra"
+)
+
+var traceCmd = &cobra.Command{
+	Use:   "trace",
+	Short: "Show the requirement traceability matrix of a run",
+	Long: `List the requirements verified by a recorded run and the outcome of each
+test tracing to them. Tests trace to requirements through annotations in
+their doc comments or bodies:
+
+    // sentinel:req=JIRA-123,JIRA-124
+
+The latest run is shown unless --run selects another. Use --csv to export
+the matrix for audit records.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		useColors := cli.ColorsEnabled()
+		runID, _ := cmd.Flags().GetString("run")
+		asCSV, _ := cmd.Flags().GetBool("csv")
+
+		store, loc, err := historyStore(cmd)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		records, err := store.Load()
+		if err != nil {
+			return fmt.Errorf("error loading history: %v", err)
+		}
+		rec, err := cli.RecordByID(records, runID)
+		if err != nil {
+			return err
+		}
+		entries := cli.TraceabilityMatrix(rec)
+		if asCSV {
+			return cli.WriteTraceabilityCSV(os.Stdout, rec, entries)
+		}
+		renderer := cli.NewRendererWithStyle(os.Stdout, useColors)
+		renderer.SetLocation(loc)
+		renderer.RenderTraceability(rec, entries)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(traceCmd)
+
+	traceCmd.Flags().String("run", "", "Run to report on (ID or unambiguous prefix), defaulting to the latest")
+	traceCmd.Flags().Bool("csv", false, "Write the matrix as CSV, one row per requirement and test")
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/newbpydev/go-sentinel/internal/cli"
+	"github.com/spf13/cobra"
+)
+
+var trendsCmd = &cobra.Command{
+	Use:   "trends",
+	Short: "Show pass-rate and duration trends from the run history",
+	Long: `Show how the pass rate and duration of recorded runs changed over time,
+grouped by day, week, or run. With --package, only that package's tests
+and duration are counted. Use --json for a machine-readable series.
+
+With --benchmarks, show the ns/op and allocs/op of each benchmark recorded
+by 'run --bench' instead, with their change from the median of the
+previous --window runs.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		useColors := cli.ColorsEnabled()
+		pkg, _ := cmd.Flags().GetString("package")
+		bucket, _ := cmd.Flags().GetString("by")
+		limit, _ := cmd.Flags().GetInt("limit")
+		asJSON, _ := cmd.Flags().GetBool("json")
+		benchmarks, _ := cmd.Flags().GetBool("benchmarks")
+		window, _ := cmd.Flags().GetInt("window")
+
+		store, loc, err := historyStore(cmd)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		records, err := store.Load()
+		if err != nil {
+			return fmt.Errorf("error loading history: %v", err)
+		}
+
+		if benchmarks {
+			series := cli.BenchmarkTrends(records, pkg)
+			for _, s := range series {
+				if limit > 0 && len(s.Points) > limit {
+					s.Points = s.Points[len(s.Points)-limit:]
+				}
+			}
+			if asJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(series)
+			}
+			renderer := cli.NewRendererWithStyle(os.Stdout, useColors)
+			renderer.RenderBenchmarkTrends(series, window)
+			return nil
+		}
+
+		points, err := cli.Trends(records, pkg, bucket, loc)
+		if err != nil {
+			return err
+		}
+		if limit > 0 && len(points) > limit {
+			points = points[len(points)-limit:]
+		}
+		if asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(points)
+		}
+		renderer := cli.NewRendererWithStyle(os.Stdout, useColors)
+		renderer.SetLocation(loc)
+		renderer.RenderTrends(points, pkg)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(trendsCmd)
+
+	trendsCmd.Flags().String("package", "", "Only count this package (import path)")
+	trendsCmd.Flags().String("by", cli.TrendByDay, "Group runs by day, week, or run")
+	trendsCmd.Flags().IntP("limit", "n", 30, "Maximum number of most recent points to show, 0 for all")
+	trendsCmd.Flags().Bool("json", false, "Write the series as JSON")
+	trendsCmd.Flags().Bool("benchmarks", false, "Show benchmark results recorded by 'run --bench' instead of test results")
+	trendsCmd.Flags().Int("window", cli.DefaultBenchmarkWindow, "With --benchmarks, number of previous runs whose median the latest result is compared with")
+}
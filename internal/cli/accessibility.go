@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Palette holds the colors test statuses are shown in
+type Palette struct {
+	Success string
+	Error   string
+	Warning string
+	Running string
+}
+
+// Palettes selectable by name
+const (
+	PaletteDefault    = "default"
+	PaletteColorblind = "colorblind"
+)
+
+// DefaultPalette is the green and red palette statuses are normally shown in
+var DefaultPalette = Palette{
+	Success: ColorSuccess,
+	Error:   ColorError,
+	Warning: ColorWarning,
+	Running: ColorRunning,
+}
+
+// ColorblindPalette tells statuses apart by blue and orange hues and by
+// brightness, which stay distinct with deuteranopia and protanopia. The
+// colors are from the Okabe-Ito palette.
+var ColorblindPalette = Palette{
+	Success: "#56B4E9", // Sky blue
+	Error:   "#E69F00", // Orange
+	Warning: "#F0E442", // Yellow
+	Running: "#CC79A7", // Reddish purple
+}
+
+// Status labels shown beside icons when status text is enabled
+const (
+	LabelPass    = "PASS"
+	LabelFail    = "FAIL"
+	LabelSkip    = "SKIP"
+	LabelRunning = "RUN "
+)
+
+// Icons used with status text, chosen to differ in shape as well as color
+const (
+	AccessibleIconPass = "✓"
+	AccessibleIconFail = "✗"
+	AccessibleIconSkip = "→"
+)
+
+// statusText is the status text setting of styles created from now on
+var statusText bool
+
+// PaletteByName returns the palette with the given name, the default for ""
+func PaletteByName(name string) (Palette, error) {
+	switch name {
+	case "", PaletteDefault:
+		return DefaultPalette, nil
+	case PaletteColorblind:
+		return ColorblindPalette, nil
+	}
+	return Palette{}, fmt.Errorf("unknown palette %q, want %q or %q", name, PaletteDefault, PaletteColorblind)
+}
+
+// SetPalette changes the colors of every status style. It is meant to be
+// called once at startup, before anything is rendered.
+func SetPalette(p Palette) {
+	successStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(p.Success))
+	errorStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(p.Error))
+	warningStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(p.Warning))
+
+	passedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(p.Success)).SetString(IconPass)
+	failedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(p.Error)).SetString(IconFail)
+	skippedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(p.Warning)).SetString(IconSkip)
+	runningStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(p.Running)).SetString(IconRunning)
+
+	summaryFailedStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color(p.Error))
+	summaryPassedStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color(p.Success))
+	summarySkippedStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color(p.Warning))
+
+	errorMessageStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(p.Error))
+}
+
+// SetStatusText makes styles created from now on show a PASS, FAIL, SKIP,
+// or RUN label beside each status icon, so statuses can be told apart
+// without relying on color
+func SetStatusText(on bool) {
+	statusText = on
+}
+
+// statusLabel returns the shape-distinct icon and text label of a status
+func (s *Style) statusLabel(status TestStatus) string {
+	pass, fail, skip, running := AccessibleIconPass, AccessibleIconFail, AccessibleIconSkip, IconRunning
+	if !s.useIcons || s.isWindows {
+		pass, fail, skip, running = WinIconPass, WinIconFail, ">", WinIconRunning
+	}
+	switch status {
+	case TestStatusPassed:
+		return pass + " " + LabelPass
+	case TestStatusFailed:
+		return fail + " " + LabelFail
+	case TestStatusSkipped:
+		return skip + " " + LabelSkip
+	case TestStatusRunning:
+		return running + " " + LabelRunning
+	}
+	return "      "
+}
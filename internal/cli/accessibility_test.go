@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+func TestSetPalette(t *testing.T) {
+	palette, err := PaletteByName(PaletteColorblind)
+	if err != nil {
+		t.Fatalf("PaletteByName failed: %v", err)
+	}
+	SetPalette(palette)
+	defer SetPalette(DefaultPalette)
+
+	if got := errorStyle.GetForeground(); got != lipgloss.Color(ColorblindPalette.Error) {
+		t.Errorf("error color = %v, want %s", got, ColorblindPalette.Error)
+	}
+	if got := summaryPassedStyle.GetForeground(); got != lipgloss.Color(ColorblindPalette.Success) {
+		t.Errorf("passed summary color = %v, want %s", got, ColorblindPalette.Success)
+	}
+
+	if _, err := PaletteByName("sepia"); err == nil {
+		t.Error("Expected error for an unknown palette")
+	}
+}
+
+func TestStyle_StatusText(t *testing.T) {
+	SetStatusText(true)
+	defer SetStatusText(false)
+
+	s := NewStyle(false)
+	s.useIcons = true
+	s.isWindows = false
+	tests := map[TestStatus]string{
+		TestStatusPassed:  "✓ PASS",
+		TestStatusFailed:  "✗ FAIL",
+		TestStatusSkipped: "→ SKIP",
+	}
+	for status, want := range tests {
+		if got := s.StatusIcon(status); got != want {
+			t.Errorf("StatusIcon(%d) = %q, want %q", status, got, want)
+		}
+	}
+
+	s.useIcons = false
+	if got := s.StatusIcon(TestStatusFailed); got != "x FAIL" {
+		t.Errorf("StatusIcon without icons = %q, want %q", got, "x FAIL")
+	}
+}
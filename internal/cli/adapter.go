@@ -0,0 +1,376 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The adapter speaks JSON-RPC 2.0 framed with Content-Length headers, as
+// the Language Server Protocol does, so editor extensions can reuse their
+// LSP client libraries. Its methods and events follow the Test Adapter
+// API of the VS Code Test Explorer:
+//
+//   - initialize returns the adapter's name and version;
+//   - discover returns the suite tree of the packages matching its
+//     "packages" patterns, with the file and 0-based line of each test;
+//   - run runs the suites and tests whose IDs are in "tests", sending a
+//     testEvent notification for each step before it returns the counts;
+//   - shutdown ends the session, and the exit notification stops the
+//     adapter.
+//
+// Suite IDs are package import paths and test IDs are "<package>#<test>".
+
+// JSON-RPC error codes
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+)
+
+// rpcMessage is a JSON-RPC request, response, or notification
+type rpcMessage struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  any              `json:"result,omitempty"`
+	Error   *rpcError        `json:"error,omitempty"`
+}
+
+// rpcError is the error of a failed request
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// AdapterSuite is a node of the discovered test tree: the root, a package,
+// or a test. Tests have a file and a 0-based line.
+type AdapterSuite struct {
+	Type     string          `json:"type"` // "suite" or "test"
+	ID       string          `json:"id"`
+	Label    string          `json:"label"`
+	File     string          `json:"file,omitempty"` // Absolute path
+	Line     *int            `json:"line,omitempty"`
+	Children []*AdapterSuite `json:"children,omitempty"`
+}
+
+// AdapterEvent is a testEvent notification: the run starting or
+// finishing, a suite starting or completing, or a test's state
+type AdapterEvent struct {
+	Type        string              `json:"type"` // "started", "suite", "test", or "finished"
+	Tests       []string            `json:"tests,omitempty"`
+	Suite       string              `json:"suite,omitempty"`
+	Test        string              `json:"test,omitempty"`
+	State       string              `json:"state,omitempty"`   // running, completed, passed, failed, skipped, or errored
+	Message     string              `json:"message,omitempty"` // Output of a failed test
+	Decorations []AdapterDecoration `json:"decorations,omitempty"`
+}
+
+// AdapterDecoration marks the line a failure was reported on
+type AdapterDecoration struct {
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line"` // 0-based
+	Message string `json:"message"`
+}
+
+// Adapter serves editor extensions a test backend over a JSON-RPC stream
+type Adapter struct {
+	workDir string
+	runner  *Runner
+	in      *bufio.Reader
+	out     io.Writer
+	mu      sync.Mutex        // Serializes writes to out
+	dirs    map[string]string // Directories of discovered packages by import path
+	done    bool
+}
+
+// NewAdapter returns an adapter for the module in workDir reading
+// requests from in and writing responses and events to out
+func NewAdapter(workDir string, runner *Runner, in io.Reader, out io.Writer) *Adapter {
+	return &Adapter{workDir: workDir, runner: runner, in: bufio.NewReader(in), out: out, dirs: make(map[string]string)}
+}
+
+// Serve handles requests until the exit notification or the end of the
+// input
+func (a *Adapter) Serve() error {
+	for !a.done {
+		data, err := readRPCFrame(a.in)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var req rpcMessage
+		if err := json.Unmarshal(data, &req); err != nil {
+			a.reply(nil, nil, &rpcError{Code: rpcParseError, Message: err.Error()})
+			continue
+		}
+		a.handle(&req)
+	}
+	return nil
+}
+
+// handle answers one request, or acts on one notification
+func (a *Adapter) handle(req *rpcMessage) {
+	if req.Method == "" {
+		a.reply(req.ID, nil, &rpcError{Code: rpcInvalidRequest, Message: "method is required"})
+		return
+	}
+	var result any
+	var err error
+	switch req.Method {
+	case "initialize":
+		result = map[string]any{"name": "go-sentinel", "version": strings.TrimSpace(VersionInfo())}
+	case "discover":
+		var params struct {
+			Packages []string `json:"packages"`
+		}
+		if err = decodeParams(req.Params, &params); err == nil {
+			result, err = a.discover(params.Packages)
+		}
+	case "run":
+		var params struct {
+			Tests []string `json:"tests"`
+		}
+		if err = decodeParams(req.Params, &params); err == nil {
+			result, err = a.run(params.Tests)
+		}
+	case "shutdown":
+	case "exit":
+		a.done = true
+		return
+	default:
+		a.reply(req.ID, nil, &rpcError{Code: rpcMethodNotFound, Message: "unknown method " + req.Method})
+		return
+	}
+	if req.ID == nil {
+		return // Notifications get no response
+	}
+	var paramsErr *paramsError
+	switch {
+	case errors.As(err, &paramsErr):
+		a.reply(req.ID, nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()})
+	case err != nil:
+		a.reply(req.ID, nil, &rpcError{Code: rpcInternalError, Message: err.Error()})
+	default:
+		a.reply(req.ID, result, nil)
+	}
+}
+
+// paramsError is a request whose params do not decode
+type paramsError struct{ err error }
+
+func (e *paramsError) Error() string { return "invalid params: " + e.err.Error() }
+
+// decodeParams decodes the params of a request, which may be omitted
+func decodeParams(raw json.RawMessage, v any) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return &paramsError{err}
+	}
+	return nil
+}
+
+// discover returns the tree of the tests of the packages matching
+// patterns, with their positions
+func (a *Adapter) discover(patterns []string) (*AdapterSuite, error) {
+	inv, err := BuildInventory(a.workDir, patterns)
+	if err != nil {
+		return nil, err
+	}
+	root := &AdapterSuite{Type: "suite", ID: "root", Label: "Go"}
+	for _, pkg := range inv.Packages {
+		dir := filepath.Join(a.workDir, filepath.FromSlash(pkg.Dir))
+		a.dirs[pkg.ImportPath] = dir
+		suite := &AdapterSuite{Type: "suite", ID: pkg.ImportPath, Label: pkg.ImportPath}
+		for _, test := range pkg.Tests {
+			if test.Kind != InventoryKindTest && test.Kind != InventoryKindExample {
+				continue
+			}
+			line := test.Line - 1
+			suite.Children = append(suite.Children, &AdapterSuite{
+				Type:  "test",
+				ID:    adapterTestID(pkg.ImportPath, test.Name),
+				Label: test.Name,
+				File:  filepath.Join(dir, test.File),
+				Line:  &line,
+			})
+		}
+		if len(suite.Children) > 0 {
+			root.Children = append(root.Children, suite)
+		}
+	}
+	return root, nil
+}
+
+// adapterTestID returns the ID of a test of a package
+func adapterTestID(pkg, test string) string {
+	return pkg + "#" + test
+}
+
+// run runs the suites and tests with the given IDs, all when there are
+// none, sending their events as they are reported
+func (a *Adapter) run(ids []string) (map[string]int, error) {
+	// Whole packages and single tests run separately, since -run applies
+	// to every package of a go test invocation
+	var whole []string
+	byPackage := make(map[string][]string)
+	var order []string
+	for _, id := range ids {
+		if id == "root" {
+			whole, byPackage, order = nil, nil, nil
+			ids = nil
+			break
+		}
+		pkg, test, ok := strings.Cut(id, "#")
+		if !ok {
+			whole = append(whole, pkg)
+			continue
+		}
+		if _, seen := byPackage[pkg]; !seen {
+			order = append(order, pkg)
+		}
+		byPackage[pkg] = append(byPackage[pkg], test)
+	}
+	var batches []*TestSelection
+	if len(ids) == 0 {
+		batches = append(batches, &TestSelection{})
+	}
+	if len(whole) > 0 {
+		batches = append(batches, &TestSelection{Packages: whole})
+	}
+	for _, pkg := range order {
+		if containsString(whole, pkg) {
+			continue
+		}
+		batches = append(batches, &TestSelection{Packages: []string{pkg}, Tests: byPackage[pkg]})
+	}
+
+	a.notify(AdapterEvent{Type: "started", Tests: ids})
+	counts := map[string]int{"passed": 0, "failed": 0, "skipped": 0}
+	events := &adapterEvents{adapter: a, counts: counts}
+	for _, sel := range batches {
+		opts := RunOptions{Packages: sel.Packages, Tests: sel.RunPatterns(), Events: []EventHandler{events}}
+		if _, err := a.runner.RunOnce(opts); err != nil && !errors.Is(err, ErrTestsFailed) {
+			a.notify(AdapterEvent{Type: "finished"})
+			return nil, err
+		}
+	}
+	a.notify(AdapterEvent{Type: "finished"})
+	return counts, nil
+}
+
+// adapterEvents turns run events into testEvent notifications
+type adapterEvents struct {
+	adapter *Adapter
+	counts  map[string]int
+}
+
+// HandleEvent sends the notification of one run event
+func (h *adapterEvents) HandleEvent(event RunEvent) {
+	switch event.Type {
+	case EventTestStart:
+		h.adapter.notify(AdapterEvent{Type: "test", Test: adapterTestID(event.Suite.Package, event.Test.Name), State: "running"})
+	case EventTestPass, EventTestFail, EventTestSkip:
+		state := map[string]string{EventTestPass: "passed", EventTestFail: "failed", EventTestSkip: "skipped"}[event.Type]
+		h.counts[state]++
+		ev := AdapterEvent{Type: "test", Test: adapterTestID(event.Suite.Package, event.Test.Name), State: state}
+		if event.Type == EventTestFail && event.Test.Error != nil {
+			ev.Message = event.Test.Error.Message
+			if loc := event.Test.Error.Location; loc != nil && loc.Line > 0 {
+				// go test reports files relative to the package directory
+				file := loc.File
+				if dir, ok := h.adapter.dirs[event.Suite.Package]; ok && file != "" && !filepath.IsAbs(file) {
+					file = filepath.Join(dir, file)
+				}
+				ev.Decorations = []AdapterDecoration{{File: file, Line: loc.Line - 1, Message: failureSummary(event.Test.Error.Message)}}
+			}
+		}
+		h.adapter.notify(ev)
+	case EventPackageEnd:
+		// A package that did not build or failed outside its tests has
+		// no test events to show it
+		if event.Suite.BuildOutput != "" || event.Suite.SetupFailed {
+			msg := packageFailureOutput(event.Suite)
+			if event.Suite.BuildOutput != "" {
+				msg = event.Suite.BuildOutput
+			}
+			h.adapter.notify(AdapterEvent{Type: "test", Test: event.Suite.Package, State: "errored", Message: msg})
+		}
+		h.adapter.notify(AdapterEvent{Type: "suite", Suite: event.Suite.Package, State: "completed"})
+	}
+}
+
+// notify sends a testEvent notification
+func (a *Adapter) notify(event AdapterEvent) {
+	params, _ := json.Marshal(event)
+	a.write(rpcMessage{JSONRPC: "2.0", Method: "testEvent", Params: params})
+}
+
+// reply sends the response to a request
+func (a *Adapter) reply(id *json.RawMessage, result any, rpcErr *rpcError) {
+	if id == nil {
+		null := json.RawMessage("null")
+		id = &null
+	}
+	msg := rpcMessage{JSONRPC: "2.0", ID: id, Result: result, Error: rpcErr}
+	if result == nil && rpcErr == nil {
+		msg.Result = json.RawMessage("null")
+	}
+	a.write(msg)
+}
+
+// write sends one framed message
+func (a *Adapter) write(msg rpcMessage) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	fmt.Fprintf(a.out, "Content-Length: %d\r\n\r\n%s", len(data), data)
+}
+
+// readRPCFrame reads the body of one Content-Length framed message
+func readRPCFrame(r *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			if err == io.EOF && line == "" && length < 0 {
+				return nil, io.EOF
+			}
+			return nil, fmt.Errorf("failed to read message header: %w", err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("invalid Content-Length %q", value)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("message without Content-Length")
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, fmt.Errorf("failed to read message: %w", err)
+	}
+	return data, nil
+}
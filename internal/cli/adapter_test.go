@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestAdapterServe(t *testing.T) {
+	var in bytes.Buffer
+	for _, body := range []string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"frobnicate"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"run","params":{"tests":"all"}}`,
+		`not json`,
+		`{"jsonrpc":"2.0","id":4,"method":"shutdown"}`,
+		`{"jsonrpc":"2.0","method":"exit"}`,
+		`{"jsonrpc":"2.0","id":5,"method":"initialize"}`,
+	} {
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+	var out bytes.Buffer
+	if err := NewAdapter(t.TempDir(), nil, &in, &out).Serve(); err != nil {
+		t.Fatalf("Serve failed: %v", err)
+	}
+
+	var responses []map[string]any
+	r := bufio.NewReader(&out)
+	for {
+		data, err := readRPCFrame(r)
+		if err != nil {
+			break
+		}
+		var msg map[string]any
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatalf("invalid response %q: %v", data, err)
+		}
+		responses = append(responses, msg)
+	}
+	if len(responses) != 5 {
+		t.Fatalf("responses = %v, want 5 before exit", responses)
+	}
+	if result, _ := responses[0]["result"].(map[string]any); result["name"] != "go-sentinel" {
+		t.Errorf("initialize result = %v", responses[0]["result"])
+	}
+	for i, code := range map[int]float64{1: rpcMethodNotFound, 2: rpcInvalidParams, 3: rpcParseError} {
+		if rpcErr, _ := responses[i]["error"].(map[string]any); rpcErr["code"] != code {
+			t.Errorf("responses[%d] = %v, want error %v", i, responses[i], code)
+		}
+	}
+	if _, ok := responses[4]["result"]; !ok || responses[4]["id"] != float64(4) {
+		t.Errorf("shutdown response = %v", responses[4])
+	}
+}
+
+func TestReadRPCFrame(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("Content-Type: application/json\r\ncontent-length: 2\r\n\r\n{}"))
+	if data, err := readRPCFrame(r); err != nil || string(data) != "{}" {
+		t.Errorf("readRPCFrame = %q, %v", data, err)
+	}
+	if _, err := readRPCFrame(bufio.NewReader(strings.NewReader("X: 1\r\n\r\n"))); err == nil {
+		t.Error("expected an error for a message without Content-Length")
+	}
+}
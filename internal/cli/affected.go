@@ -0,0 +1,393 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// TestSelection is the part of the project a watch rerun has to test
+type TestSelection struct {
+	Packages []string // Import paths of the packages to test
+	Tests    []string // Top-level tests to run, empty to run every test in Packages
+	Edited   []string // Declarations whose edits selected the tests, when known
+}
+
+// RunPatterns returns the anchored -run patterns for the selected tests
+func (s *TestSelection) RunPatterns() []string {
+	patterns := make([]string, len(s.Tests))
+	for i, test := range s.Tests {
+		patterns[i] = "^" + regexp.QuoteMeta(test) + "$"
+	}
+	return patterns
+}
+
+// selectTests maps changed files to the tests they can affect: the tests
+// a changed file declares, plus the tests of its package that reach its
+// declarations directly or through other declarations of the package.
+// With snapshots of the files as last seen, only the declarations each
+// change edited count, rather than the whole file. It returns nil when the
+// change cannot be narrowed safely and everything in pkgs must rerun: the
+// file is not in a listed package, another listed package imports the
+// changed one, or a file does not parse.
+func selectTests(pkgs []*PackageInfo, changed []string, snapshots *declSnapshots) *TestSelection {
+	byDir := make(map[string]*PackageInfo, len(pkgs))
+	for _, pkg := range pkgs {
+		byDir[filepath.Clean(pkg.Dir)] = pkg
+	}
+	graph := NewDependencyGraph(pkgs)
+
+	sel := &TestSelection{}
+	tests := make(map[string][]string) // Import path to tests, nil for the whole package
+	for _, file := range changed {
+		file = filepath.Clean(file)
+		pkg, ok := byDir[filepath.Dir(file)]
+		if !ok || !strings.HasSuffix(file, ".go") {
+			return nil
+		}
+		// Test files are never imported, but code changes reach importers
+		if !strings.HasSuffix(file, "_test.go") && len(graph.Dependents(pkg.ImportPath)) > 0 {
+			return nil
+		}
+		selected, edited, parsed := testsReaching(pkg, file, snapshots)
+		if !parsed {
+			return nil
+		}
+		sel.Edited = mergeTags(sel.Edited, edited)
+
+		prev, seen := tests[pkg.ImportPath]
+		switch {
+		case seen && prev == nil, len(selected) == 0:
+			tests[pkg.ImportPath] = nil
+		default:
+			tests[pkg.ImportPath] = mergeTags(prev, selected)
+		}
+	}
+
+	// A single -run pattern applies to every package, so filters are only
+	// kept when every package is narrowed
+	narrowed := true
+	for importPath, names := range tests {
+		sel.Packages = append(sel.Packages, importPath)
+		if names == nil {
+			narrowed = false
+		}
+		sel.Tests = append(sel.Tests, names...)
+	}
+	sort.Strings(sel.Packages)
+	if narrowed {
+		sel.Tests = mergeTags(nil, sel.Tests)
+	} else {
+		sel.Tests = nil
+	}
+	return sel
+}
+
+// isRunnableTest reports whether go test -run selects the function;
+// benchmarks only run with -bench
+func isRunnableTest(name string) bool {
+	return isTestFunc(name) && !strings.HasPrefix(name, "Benchmark")
+}
+
+// testsReaching returns the tests declared in the changed file and the
+// tests of pkg that reference its declarations, following references
+// through the package's other declarations. When snapshots hold the file's
+// declarations as last seen, only the declarations the change edited,
+// added, or removed count, and their names are returned as edited. An
+// empty result with true means every test of the package is affected, as
+// when package initialization or TestMain reaches the change.
+func testsReaching(pkg *PackageInfo, changed string, snapshots *declSnapshots) (tests, edited []string, ok bool) {
+	changed = filepath.Clean(changed)
+	fset := token.NewFileSet()
+	src, err := os.ReadFile(changed)
+	if err != nil {
+		return nil, nil, false
+	}
+	changedFile, err := parser.ParseFile(fset, changed, src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, nil, false
+	}
+	current := declarationsOf(fset, changedFile, src)
+	prev := snapshots.get(changed)
+	snapshots.set(changed, current)
+
+	affected := make(map[string]bool)
+	keys := declKeys(changedFile)
+	for i, decl := range changedFile.Decls {
+		if prev != nil {
+			key := keys[i]
+			if old, ok := prev[key]; ok && old.Hash == current[key].Hash {
+				continue
+			}
+			edited = append(edited, current[key].Names...)
+		}
+		for name := range declaredNamesOf(decl) {
+			affected[name] = true
+			if strings.HasSuffix(changed, "_test.go") && isRunnableTest(name) {
+				tests = append(tests, name)
+			}
+		}
+		// Methods are often called implicitly, through interfaces such as
+		// fmt.Stringer, so a changed method affects users of its type
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv != nil {
+			if name := receiverType(fn); name != "" {
+				affected[name] = true
+			}
+		}
+	}
+	// Removed declarations affect whatever still refers to them
+	for key, old := range prev {
+		if _, ok := current[key]; !ok {
+			edited = append(edited, old.Names...)
+			for _, name := range old.Names {
+				affected[name] = true
+			}
+		}
+	}
+	if affected["init"] || affected["TestMain"] {
+		return nil, edited, true
+	}
+
+	// Every top-level declaration of the package, with the identifiers it
+	// references. Names are matched without regard to receivers or scopes,
+	// which can only select too much, never too little.
+	type node struct {
+		name string
+		test bool
+		refs map[string]bool
+	}
+	var nodes []node
+	files := append(append(append([]string{}, pkg.GoFiles...), pkg.TestGoFiles...), pkg.XTestGoFiles...)
+	for _, name := range files {
+		path := filepath.Join(pkg.Dir, name)
+		f := changedFile
+		if path != changed {
+			src, err := os.ReadFile(path)
+			if err != nil {
+				continue // Deleted since the package was listed
+			}
+			if f, err = parser.ParseFile(fset, path, src, parser.SkipObjectResolution); err != nil {
+				return nil, nil, false
+			}
+			if snapshots.get(path) == nil {
+				snapshots.set(path, declarationsOf(fset, f, src))
+			}
+		}
+		isTestFile := strings.HasSuffix(name, "_test.go")
+		for _, decl := range f.Decls {
+			refs := referencedNames(decl)
+			for declName := range declaredNamesOf(decl) {
+				nodes = append(nodes, node{
+					name: declName,
+					test: isTestFile && isRunnableTest(declName),
+					refs: refs,
+				})
+			}
+		}
+	}
+
+	for grew := true; grew; {
+		grew = false
+		for _, n := range nodes {
+			if affected[n.name] {
+				continue
+			}
+			for ref := range n.refs {
+				if affected[ref] {
+					affected[n.name] = true
+					grew = true
+					break
+				}
+			}
+		}
+	}
+
+	if affected["init"] || affected["TestMain"] {
+		return nil, edited, true
+	}
+	for _, n := range nodes {
+		if n.test && affected[n.name] && !containsString(tests, n.name) {
+			tests = append(tests, n.name)
+		}
+	}
+	return tests, edited, true
+}
+
+// declSnapshots remembers the declarations of Go files as last seen, so a
+// saved file can be narrowed to the declarations the save edited
+type declSnapshots struct {
+	mu    sync.Mutex
+	files map[string]map[string]declSnapshot // By file, then declaration key
+}
+
+// declSnapshot is one top-level declaration of a file as last seen
+type declSnapshot struct {
+	Hash  string   // Hash of the declaration's source
+	Names []string // Names the declaration introduces, with the receiver type of a method
+}
+
+// newDeclSnapshots returns empty snapshots
+func newDeclSnapshots() *declSnapshots {
+	return &declSnapshots{files: make(map[string]map[string]declSnapshot)}
+}
+
+// get returns the declarations of file as last seen, nil when unknown or
+// when s is nil
+func (s *declSnapshots) get(file string) map[string]declSnapshot {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.files[file]
+}
+
+// set records the declarations of file
+func (s *declSnapshots) set(file string, decls map[string]declSnapshot) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[file] = decls
+}
+
+// warm records the declarations of the Go files of pkgs not seen yet, so
+// the first save of each can be narrowed too
+func (s *declSnapshots) warm(pkgs []*PackageInfo) {
+	for _, pkg := range pkgs {
+		files := append(append(append([]string{}, pkg.GoFiles...), pkg.TestGoFiles...), pkg.XTestGoFiles...)
+		for _, name := range files {
+			path := filepath.Join(pkg.Dir, name)
+			if s.get(path) != nil {
+				continue
+			}
+			src, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+			fset := token.NewFileSet()
+			f, err := parser.ParseFile(fset, path, src, parser.SkipObjectResolution)
+			if err != nil {
+				continue
+			}
+			s.set(path, declarationsOf(fset, f, src))
+		}
+	}
+}
+
+// declarationsOf returns the top-level declarations of a parsed file by
+// their keys
+func declarationsOf(fset *token.FileSet, f *ast.File, src []byte) map[string]declSnapshot {
+	decls := make(map[string]declSnapshot, len(f.Decls))
+	for i, key := range declKeys(f) {
+		decl := f.Decls[i]
+		start, end := fset.Position(decl.Pos()).Offset, fset.Position(decl.End()).Offset
+		sum := sha256.Sum256(src[start:end])
+		var names []string
+		for name := range declaredNamesOf(decl) {
+			names = append(names, name)
+		}
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv != nil {
+			if name := receiverType(fn); name != "" {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		decls[key] = declSnapshot{Hash: hex.EncodeToString(sum[:8]), Names: names}
+	}
+	return decls
+}
+
+// declKeys returns a key identifying each top-level declaration of a file
+// across edits, such as "func (Num) String" or "var a b". Declarations
+// sharing a key, such as several init functions, are told apart by order.
+func declKeys(f *ast.File) []string {
+	keys := make([]string, len(f.Decls))
+	seen := make(map[string]int)
+	for i, decl := range f.Decls {
+		var key string
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			key = "func " + d.Name.Name
+			if d.Recv != nil {
+				key = "func (" + receiverType(d) + ") " + d.Name.Name
+			}
+		case *ast.GenDecl:
+			var names []string
+			for name := range declaredNamesOf(d) {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			key = strings.TrimSpace(d.Tok.String() + " " + strings.Join(names, " "))
+		}
+		if n := seen[key]; n > 0 {
+			keys[i] = key + "#" + strconv.Itoa(n)
+		} else {
+			keys[i] = key
+		}
+		seen[key]++
+	}
+	return keys
+}
+
+// receiverType returns the name of a method's receiver type
+func receiverType(fn *ast.FuncDecl) string {
+	expr := fn.Recv.List[0].Type
+	for {
+		switch t := expr.(type) {
+		case *ast.StarExpr:
+			expr = t.X
+		case *ast.IndexExpr: // Generic receivers such as List[T]
+			expr = t.X
+		case *ast.IndexListExpr:
+			expr = t.X
+		case *ast.Ident:
+			return t.Name
+		default:
+			return ""
+		}
+	}
+}
+
+// declaredNamesOf returns the names a top-level declaration introduces
+func declaredNamesOf(decl ast.Decl) map[string]bool {
+	names := make(map[string]bool)
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		names[d.Name.Name] = true
+	case *ast.GenDecl:
+		for _, spec := range d.Specs {
+			switch s := spec.(type) {
+			case *ast.TypeSpec:
+				names[s.Name.Name] = true
+			case *ast.ValueSpec:
+				for _, ident := range s.Names {
+					names[ident.Name] = true
+				}
+			}
+		}
+	}
+	return names
+}
+
+// referencedNames returns every identifier used within a declaration
+func referencedNames(decl ast.Decl) map[string]bool {
+	refs := make(map[string]bool)
+	ast.Inspect(decl, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok {
+			refs[ident.Name] = true
+		}
+		return true
+	})
+	return refs
+}
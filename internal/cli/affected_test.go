@@ -0,0 +1,163 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSelectTests(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"parse.go":  "package calc\n\nfunc Parse(s string) int { return len(s) }\n",
+		"eval.go":   "package calc\n\nfunc Eval(s string) int { return Parse(s) * 2 }\n",
+		"num.go":    "package calc\n\ntype Num int\n\nfunc NewNum() Num { return 1 }\n",
+		"format.go": "package calc\n\nfunc (n *Num) String() string { return \"n\" }\n",
+		"other.go":  "package calc\n\nfunc Other() {}\n",
+		"parse_test.go": `package calc
+
+import "testing"
+
+func TestParse(t *testing.T) { Parse("x") }
+
+func BenchmarkParse(b *testing.B) {}
+`,
+		"eval_test.go": `package calc
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestEval(t *testing.T) { Eval(fixture()) }
+
+func TestFormat(t *testing.T) { fmt.Sprint(NewNum()) }
+
+func TestOther(t *testing.T) { Other() }
+`,
+		"helpers_test.go": "package calc\n\nfunc fixture() string { return \"1\" }\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pkg := &PackageInfo{
+		ImportPath:  "example.com/calc",
+		Dir:         dir,
+		GoFiles:     []string{"eval.go", "format.go", "num.go", "other.go", "parse.go"},
+		TestGoFiles: []string{"eval_test.go", "helpers_test.go", "parse_test.go"},
+	}
+	pkgs := []*PackageInfo{pkg}
+
+	tests := []struct {
+		name    string
+		changed []string
+		want    []string
+	}{
+		{"reached through another function", []string{"parse.go"}, []string{"TestEval", "TestParse"}},
+		{"method called implicitly", []string{"format.go"}, []string{"TestFormat"}},
+		{"test file declares tests", []string{"parse_test.go"}, []string{"TestParse"}},
+		{"test helper", []string{"helpers_test.go"}, []string{"TestEval"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var changed []string
+			for _, name := range tt.changed {
+				changed = append(changed, filepath.Join(dir, name))
+			}
+			sel := selectTests(pkgs, changed, nil)
+			if sel == nil {
+				t.Fatal("selectTests returned nil, want a narrowed selection")
+			}
+			if !reflect.DeepEqual(sel.Tests, tt.want) || !reflect.DeepEqual(sel.Packages, []string{"example.com/calc"}) {
+				t.Errorf("selection = %+v, want tests %v in example.com/calc", sel, tt.want)
+			}
+		})
+	}
+
+	t.Run("package with dependents", func(t *testing.T) {
+		user := &PackageInfo{ImportPath: "example.com/app", Dir: t.TempDir(), TestImports: []string{"example.com/calc"}}
+		if sel := selectTests([]*PackageInfo{pkg, user}, []string{filepath.Join(dir, "parse.go")}, nil); sel != nil {
+			t.Errorf("selection = %+v, want nil so importers rerun", sel)
+		}
+	})
+
+	t.Run("package with transitive dependents", func(t *testing.T) {
+		mid := &PackageInfo{ImportPath: "example.com/mid", Dir: t.TempDir(), Imports: []string{"example.com/calc"}}
+		user := &PackageInfo{ImportPath: "example.com/app", Dir: t.TempDir(), Imports: []string{"example.com/mid"}}
+		if sel := selectTests([]*PackageInfo{pkg, mid, user}, []string{filepath.Join(dir, "parse.go")}, nil); sel != nil {
+			t.Errorf("selection = %+v, want nil so importers rerun", sel)
+		}
+	})
+
+	t.Run("init reaches the change", func(t *testing.T) {
+		initFile := filepath.Join(dir, "init.go")
+		if err := os.WriteFile(initFile, []byte("package calc\n\nvar table = map[int]int{}\n\nfunc init() { Other() }\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(initFile)
+		withInit := *pkg
+		withInit.GoFiles = append([]string{"init.go"}, pkg.GoFiles...)
+		sel := selectTests([]*PackageInfo{&withInit}, []string{filepath.Join(dir, "other.go")}, nil)
+		if sel == nil || len(sel.Tests) != 0 {
+			t.Errorf("selection = %+v, want the whole package", sel)
+		}
+	})
+}
+
+func TestTestSelection_RunPatterns(t *testing.T) {
+	sel := &TestSelection{Tests: []string{"TestA", "ExampleB_c"}}
+	if got, want := sel.RunPatterns(), []string{"^TestA$", "^ExampleB_c$"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("RunPatterns = %v, want %v", got, want)
+	}
+}
+
+func TestSelectTests_EditedDeclarations(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("ops.go", "package ops\n\nfunc Add(a, b int) int { return a + b }\n\nfunc Sub(a, b int) int { return a - b }\n")
+	write("ops_test.go", `package ops
+
+import "testing"
+
+func TestAdd(t *testing.T) { Add(1, 2) }
+
+func TestSub(t *testing.T) { Sub(1, 2) }
+`)
+	pkg := &PackageInfo{ImportPath: "example.com/ops", Dir: dir, GoFiles: []string{"ops.go"}, TestGoFiles: []string{"ops_test.go"}}
+	ops := filepath.Join(dir, "ops.go")
+	snapshots := newDeclSnapshots()
+	snapshots.warm([]*PackageInfo{pkg})
+
+	tests := []struct {
+		name       string
+		content    string
+		wantTests  []string
+		wantEdited []string
+	}{
+		{"one function edited", "package ops\n\nfunc Add(a, b int) int { return a + b }\n\n// Sub subtracts\nfunc Sub(a, b int) int { return b - a }\n", []string{"TestSub"}, []string{"Sub"}},
+		{"function removed", "package ops\n\nfunc Sub(a, b int) int { return b - a }\n", []string{"TestAdd"}, []string{"Add"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			write("ops.go", tt.content)
+			sel := selectTests([]*PackageInfo{pkg}, []string{ops}, snapshots)
+			if sel == nil || !reflect.DeepEqual(sel.Tests, tt.wantTests) || !reflect.DeepEqual(sel.Edited, tt.wantEdited) {
+				t.Errorf("selection = %+v, want tests %v for changes to %v", sel, tt.wantTests, tt.wantEdited)
+			}
+		})
+	}
+
+	// Without a snapshot, every declaration of the file counts
+	write("ops.go", "package ops\n\nfunc Add(a, b int) int { return a + b }\n\nfunc Sub(a, b int) int { return a - b }\n")
+	if sel := selectTests([]*PackageInfo{pkg}, []string{ops}, newDeclSnapshots()); sel == nil || !reflect.DeepEqual(sel.Tests, []string{"TestAdd", "TestSub"}) || sel.Edited != nil {
+		t.Errorf("selection = %+v, want both tests", sel)
+	}
+}
@@ -0,0 +1,234 @@
+package cli
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Benchmark is one result line of go test -bench
+type Benchmark struct {
+	Name        string  `json:"name"` // As reported, with the -GOMAXPROCS suffix
+	Iterations  int64   `json:"iterations"`
+	NsPerOp     float64 `json:"nsPerOp"`
+	BytesPerOp  int64   `json:"bytesPerOp,omitempty"`  // With -benchmem
+	AllocsPerOp int64   `json:"allocsPerOp,omitempty"` // With -benchmem
+}
+
+// benchmarkLineRe matches a benchmark result: name, iterations, and the
+// measurements
+var benchmarkLineRe = regexp.MustCompile(`^(Benchmark\S*)\s+(\d+)\s+(\d.*\sns/op.*)$`)
+
+// parseBenchmarkLine returns the benchmark result on a line of test
+// output, or nil
+func parseBenchmarkLine(line string) *Benchmark {
+	m := benchmarkLineRe.FindStringSubmatch(strings.TrimRight(line, "\n"))
+	if m == nil {
+		return nil
+	}
+	b := &Benchmark{Name: m[1]}
+	b.Iterations, _ = strconv.ParseInt(m[2], 10, 64)
+	fields := strings.Fields(m[3])
+	for i := 0; i+1 < len(fields); i += 2 {
+		value, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			continue
+		}
+		switch fields[i+1] {
+		case "ns/op":
+			b.NsPerOp = value
+		case "B/op":
+			b.BytesPerOp = int64(value)
+		case "allocs/op":
+			b.AllocsPerOp = int64(value)
+		}
+	}
+	return b
+}
+
+// Benchmark metrics compared against their baseline
+const (
+	MetricNsPerOp     = "ns/op"
+	MetricAllocsPerOp = "allocs/op"
+)
+
+// value returns the measurement of a metric
+func (b *Benchmark) value(metric string) float64 {
+	if metric == MetricAllocsPerOp {
+		return float64(b.AllocsPerOp)
+	}
+	return b.NsPerOp
+}
+
+// BenchmarkPoint is one recorded measurement of a benchmark
+type BenchmarkPoint struct {
+	RunID       string    `json:"runId"`
+	Start       time.Time `json:"start"`
+	NsPerOp     float64   `json:"nsPerOp"`
+	BytesPerOp  int64     `json:"bytesPerOp,omitempty"`
+	AllocsPerOp int64     `json:"allocsPerOp,omitempty"`
+}
+
+// BenchmarkSeries is the recorded measurements of one benchmark, oldest
+// first
+type BenchmarkSeries struct {
+	Package string            `json:"package"`
+	Name    string            `json:"name"`
+	Points  []*BenchmarkPoint `json:"points"`
+}
+
+// Change returns the relative change of a metric between the latest point
+// and the median of up to window points before it, 0 when there is nothing
+// to compare with
+func (s *BenchmarkSeries) Change(metric string, window int) float64 {
+	if len(s.Points) < 2 {
+		return 0
+	}
+	value := func(p *BenchmarkPoint) float64 {
+		return (&Benchmark{NsPerOp: p.NsPerOp, AllocsPerOp: p.AllocsPerOp}).value(metric)
+	}
+	prev := s.Points[max(0, len(s.Points)-1-window) : len(s.Points)-1]
+	values := make([]float64, len(prev))
+	for i, p := range prev {
+		values[i] = value(p)
+	}
+	baseline := median(values)
+	if baseline == 0 {
+		return 0
+	}
+	return value(s.Points[len(s.Points)-1])/baseline - 1
+}
+
+// BenchmarkTrends returns the recorded measurements of each benchmark,
+// optionally restricted to one package, ordered by package and name
+func BenchmarkTrends(records []*HistoryRecord, pkg string) []*BenchmarkSeries {
+	series := make(map[string]*BenchmarkSeries)
+	for _, rec := range records {
+		for _, p := range rec.Packages {
+			if pkg != "" && p.Package != pkg {
+				continue
+			}
+			for _, b := range p.Benchmarks {
+				key := p.Package + "\x00" + b.Name
+				s := series[key]
+				if s == nil {
+					s = &BenchmarkSeries{Package: p.Package, Name: b.Name}
+					series[key] = s
+				}
+				s.Points = append(s.Points, &BenchmarkPoint{
+					RunID:       rec.ID,
+					Start:       rec.StartTime,
+					NsPerOp:     b.NsPerOp,
+					BytesPerOp:  b.BytesPerOp,
+					AllocsPerOp: b.AllocsPerOp,
+				})
+			}
+		}
+	}
+
+	result := make([]*BenchmarkSeries, 0, len(series))
+	for _, s := range series {
+		sort.SliceStable(s.Points, func(i, j int) bool { return s.Points[i].Start.Before(s.Points[j].Start) })
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Package != result[j].Package {
+			return result[i].Package < result[j].Package
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// BenchmarkRegression is a benchmark measured above its rolling baseline
+type BenchmarkRegression struct {
+	Package  string  `json:"package"`
+	Name     string  `json:"name"`
+	Metric   string  `json:"metric"`   // ns/op or allocs/op
+	Value    float64 `json:"value"`    // Measurement of this run
+	Baseline float64 `json:"baseline"` // Median of the previous measurements
+	Change   float64 `json:"change"`   // Relative change, 0.25 for 25% slower
+}
+
+// String describes the regression in one line
+func (g *BenchmarkRegression) String() string {
+	return fmt.Sprintf("%s %s %s %+.1f%% (%s, baseline %s)", g.Package, g.Name, g.Metric,
+		g.Change*100, formatMetric(g.Value), formatMetric(g.Baseline))
+}
+
+// formatMetric formats a benchmark measurement without needless decimals
+func formatMetric(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// benchmarkHistory keeps the latest measurements of each benchmark, to
+// compare new runs against
+type benchmarkHistory map[string][]*Benchmark
+
+// maxBenchmarkHistory is the number of measurements kept per benchmark,
+// the widest baseline a rule can ask for
+const maxBenchmarkHistory = 50
+
+// add records the benchmarks of a package
+func (h benchmarkHistory) add(pkg string, benchmarks []*Benchmark) {
+	for _, b := range benchmarks {
+		key := pkg + "\x00" + b.Name
+		kept := append(h[key], b)
+		h[key] = kept[max(0, len(kept)-maxBenchmarkHistory):]
+	}
+}
+
+// regressions returns the benchmarks of run whose ns/op or allocs/op
+// exceed the median of up to window earlier measurements by more than
+// threshold, a fraction. Benchmarks without earlier measurements, or whose
+// baseline is zero, are not compared.
+func (h benchmarkHistory) regressions(run *TestRun, window int, threshold float64) []*BenchmarkRegression {
+	var result []*BenchmarkRegression
+	for _, suite := range run.Suites {
+		for _, b := range suite.Benchmarks {
+			prev := h[suite.Package+"\x00"+b.Name]
+			if len(prev) == 0 {
+				continue
+			}
+			prev = prev[max(0, len(prev)-window):]
+			for _, metric := range []string{MetricNsPerOp, MetricAllocsPerOp} {
+				values := make([]float64, len(prev))
+				for i, p := range prev {
+					values[i] = p.value(metric)
+				}
+				baseline := median(values)
+				if baseline == 0 {
+					continue
+				}
+				if change := b.value(metric)/baseline - 1; change > threshold {
+					result = append(result, &BenchmarkRegression{
+						Package:  suite.Package,
+						Name:     b.Name,
+						Metric:   metric,
+						Value:    b.value(metric),
+						Baseline: baseline,
+						Change:   change,
+					})
+				}
+			}
+		}
+	}
+	return result
+}
+
+// median returns the median of values, 0 when there are none
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParser_Benchmarks(t *testing.T) {
+	// go test -json reports benchmarks as output of the last test, with the
+	// name printed before the measurements
+	input := `{"Action":"start","Package":"example.com/bench"}
+{"Action":"run","Package":"example.com/bench","Test":"TestX"}
+{"Action":"output","Package":"example.com/bench","Test":"TestX","Output":"goos: linux\n"}
+{"Action":"output","Package":"example.com/bench","Test":"TestX","Output":"BenchmarkAdd\n"}
+{"Action":"output","Package":"example.com/bench","Test":"TestX","Output":"BenchmarkAdd-8 \t"}
+{"Action":"output","Package":"example.com/bench","Test":"TestX","Output":"1000000\t      1052 ns/op\t      64 B/op\t       2 allocs/op\n"}
+{"Action":"output","Package":"example.com/bench","Test":"TestX","Output":"BenchmarkSub/small-8   \t     100\t         2.5 ns/op\n"}
+{"Action":"pass","Package":"example.com/bench","Test":"TestX","Elapsed":0}
+{"Action":"pass","Package":"example.com/bench","Elapsed":1.2}
+`
+	run, err := NewParser().Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	benchmarks := run.Suites[0].Benchmarks
+	if len(benchmarks) != 2 {
+		t.Fatalf("got %d benchmarks, want 2: %+v", len(benchmarks), benchmarks)
+	}
+	want := Benchmark{Name: "BenchmarkAdd-8", Iterations: 1000000, NsPerOp: 1052, BytesPerOp: 64, AllocsPerOp: 2}
+	if *benchmarks[0] != want {
+		t.Errorf("benchmarks[0] = %+v, want %+v", *benchmarks[0], want)
+	}
+	if benchmarks[1].Name != "BenchmarkSub/small-8" || benchmarks[1].NsPerOp != 2.5 {
+		t.Errorf("benchmarks[1] = %+v, want BenchmarkSub/small-8 at 2.5 ns/op", *benchmarks[1])
+	}
+	if msg := run.Suites[0].Tests[0].Error.Message; strings.Contains(msg, "ns/op") {
+		t.Errorf("benchmark results leaked into test output: %q", msg)
+	}
+}
+
+func TestNotificationRouter_BenchmarkRegressions(t *testing.T) {
+	cfg := &NotifyConfig{
+		Channels: map[string]*NotifyChannel{"hook": {Type: ChannelWebhook, URL: "http://example.com"}},
+		Rules: []*NotifyRule{{
+			Name: "slower", On: NotifyOnBenchmark, Threshold: 20, Window: 3,
+			When: NotifyCriteria{Package: "example.com/**"}, Notify: []string{"hook"},
+		}},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	router := NewNotificationRouter(cfg, t.TempDir())
+	router.branch = func() string { return "main" }
+	router.send = func(*NotifyChannel, *Notification) error { return nil }
+
+	runWith := func(id string, ns float64, allocs int64) *TestRun {
+		return &TestRun{ID: id, Suites: []*TestSuite{{
+			Package:    "example.com/codec",
+			Benchmarks: []*Benchmark{{Name: "BenchmarkEncode", NsPerOp: ns, AllocsPerOp: allocs}},
+		}}}
+	}
+	if n := router.Evaluate(runWith("run-1", 100, 2))["hook"]; n != nil {
+		t.Errorf("hook = %+v, want no notification without earlier results", n)
+	}
+	for _, ns := range []float64{100, 300, 110, 105} {
+		router.Route(runWith("base", ns, 2))
+	}
+
+	// The baseline is the median of 300, 110, and 105, so the outlier does
+	// not hide a slowdown
+	n := router.Evaluate(runWith("run-5", 140, 3))["hook"]
+	if n == nil || len(n.Regressions) != 2 {
+		t.Fatalf("hook = %+v, want ns/op and allocs/op regressions", n)
+	}
+	if g := n.Regressions[0]; g.Metric != MetricNsPerOp || g.Baseline != 110 || g.Value != 140 {
+		t.Errorf("regression = %+v, want 140 ns/op over a baseline of 110", g)
+	}
+	if got := n.Headline(); got != "2 benchmark regressions in run run-5 on main" {
+		t.Errorf("Headline() = %q", got)
+	}
+	if n := router.Evaluate(runWith("run-6", 120, 2))["hook"]; n != nil {
+		t.Errorf("hook = %+v, want no notification within the threshold", n)
+	}
+}
+
+func TestBenchmarkTrends(t *testing.T) {
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	var records []*HistoryRecord
+	for i, ns := range []float64{100, 100, 130} {
+		records = append(records, &HistoryRecord{ID: string(rune('a' + i)), StartTime: start.Add(time.Duration(i) * time.Hour), Packages: []*PackageRecord{
+			{Package: "example.com/codec", Benchmarks: []*Benchmark{{Name: "BenchmarkEncode", NsPerOp: ns}}},
+			{Package: "example.com/other"},
+		}})
+	}
+	series := BenchmarkTrends(records, "")
+	if len(series) != 1 || len(series[0].Points) != 3 {
+		t.Fatalf("BenchmarkTrends() = %+v, want one series of 3 points", series)
+	}
+	if change := series[0].Change(MetricNsPerOp, 5); change < 0.299 || change > 0.301 {
+		t.Errorf("Change() = %v, want 0.3", change)
+	}
+	if got := sparkline([]float64{100, 100, 130}); got != "▁▁█" {
+		t.Errorf("sparkline() = %q", got)
+	}
+}
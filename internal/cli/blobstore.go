@@ -0,0 +1,419 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BlobStore keeps artifacts, reports, and state archives outside the
+// machine, so runs on ephemeral CI workers can persist their outputs
+type BlobStore interface {
+	// Put stores data under key and returns the object's URL
+	Put(key string, data []byte) (string, error)
+	// Get returns the data stored under key
+	Get(key string) ([]byte, error)
+}
+
+// IsBlobURL reports whether s names an object in a blob store rather than
+// a local path
+func IsBlobURL(s string) bool {
+	return strings.HasPrefix(s, "s3://") || strings.HasPrefix(s, "gs://") || strings.HasPrefix(s, "file://")
+}
+
+// OpenBlobURL returns the store holding the object at rawURL and the
+// object's key within it. Supported forms:
+//
+//	s3://bucket/key  Amazon S3 or a compatible service; credentials and
+//	                 region from the standard AWS_* variables, with
+//	                 AWS_ENDPOINT_URL selecting another service
+//	gs://bucket/key  Google Cloud Storage; an OAuth token from
+//	                 GOOGLE_OAUTH_ACCESS_TOKEN
+//	file:///dir/key  A local directory, for testing and shared mounts
+func OpenBlobURL(rawURL string) (BlobStore, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid blob URL %q: %w", rawURL, err)
+	}
+	key := strings.TrimPrefix(u.Path, "/")
+
+	switch u.Scheme {
+	case "s3":
+		if u.Host == "" || key == "" {
+			return nil, "", fmt.Errorf("invalid blob URL %q: want s3://bucket/key", rawURL)
+		}
+		store, err := newS3Store(u.Host)
+		return store, key, err
+	case "gs":
+		if u.Host == "" || key == "" {
+			return nil, "", fmt.Errorf("invalid blob URL %q: want gs://bucket/key", rawURL)
+		}
+		token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN")
+		if token == "" {
+			return nil, "", fmt.Errorf("GOOGLE_OAUTH_ACCESS_TOKEN is required for %s", rawURL)
+		}
+		return &gcsStore{endpoint: "https://storage.googleapis.com", bucket: u.Host, token: token}, key, nil
+	case "file":
+		return &fileStore{dir: filepath.Dir(filepath.FromSlash(u.Path))}, path.Base(u.Path), nil
+	}
+	return nil, "", fmt.Errorf("unsupported blob URL %q: want s3://, gs://, or file://", rawURL)
+}
+
+// blobClient is used for all blob store requests
+var blobClient = &http.Client{Timeout: 5 * time.Minute}
+
+// fileStore keeps objects in a local directory
+type fileStore struct {
+	dir string
+}
+
+func (s *fileStore) Put(key string, data []byte) (string, error) {
+	dest := filepath.Join(s.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return "", fmt.Errorf("failed to store %s: %w", key, err)
+	}
+	if err := os.WriteFile(dest, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to store %s: %w", key, err)
+	}
+	return "file://" + filepath.ToSlash(dest), nil
+}
+
+func (s *fileStore) Get(key string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, filepath.FromSlash(key)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", key, err)
+	}
+	return data, nil
+}
+
+// gcsStore talks to the Google Cloud Storage XML API
+type gcsStore struct {
+	endpoint string
+	bucket   string
+	token    string
+}
+
+func (s *gcsStore) objectURL(key string) string {
+	return s.endpoint + "/" + s.bucket + "/" + escapePath(key)
+}
+
+func (s *gcsStore) Put(key string, data []byte) (string, error) {
+	req, err := http.NewRequest(http.MethodPut, s.objectURL(key), bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	if _, err := doBlobRequest(req); err != nil {
+		return "", fmt.Errorf("failed to store %s: %w", key, err)
+	}
+	return "gs://" + s.bucket + "/" + key, nil
+}
+
+func (s *gcsStore) Get(key string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, s.objectURL(key), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	data, err := doBlobRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", key, err)
+	}
+	return data, nil
+}
+
+// s3Store talks to Amazon S3 or a compatible service, signing requests
+// with AWS Signature Version 4
+type s3Store struct {
+	bucket       string
+	region       string
+	endpoint     string // Custom endpoint for compatible services, addressed path-style
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	now          func() time.Time
+}
+
+// newS3Store creates a store for bucket configured from the environment
+func newS3Store(bucket string) (*s3Store, error) {
+	s := &s3Store{
+		bucket:       bucket,
+		region:       os.Getenv("AWS_REGION"),
+		endpoint:     strings.TrimSuffix(os.Getenv("AWS_ENDPOINT_URL"), "/"),
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		now:          time.Now,
+	}
+	if s.region == "" {
+		s.region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if s.region == "" {
+		s.region = "us-east-1"
+	}
+	if s.accessKey == "" || s.secretKey == "" {
+		return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required for s3:// URLs")
+	}
+	return s, nil
+}
+
+func (s *s3Store) objectURL(key string) string {
+	if s.endpoint != "" {
+		return s.endpoint + "/" + s.bucket + "/" + escapePath(key)
+	}
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.bucket, s.region, escapePath(key))
+}
+
+func (s *s3Store) Put(key string, data []byte) (string, error) {
+	req, err := http.NewRequest(http.MethodPut, s.objectURL(key), bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	s.sign(req, data)
+	if _, err := doBlobRequest(req); err != nil {
+		return "", fmt.Errorf("failed to store %s: %w", key, err)
+	}
+	return "s3://" + s.bucket + "/" + key, nil
+}
+
+func (s *s3Store) Get(key string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, s.objectURL(key), nil)
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req, nil)
+	data, err := doBlobRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", key, err)
+	}
+	return data, nil
+}
+
+// sign adds AWS Signature Version 4 headers to req, covering the host and
+// every header already set on it
+func (s *s3Store) sign(req *http.Request, payload []byte) {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(payload)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature))
+}
+
+// doBlobRequest sends req and returns the response body, treating non-2xx
+// responses as errors
+func doBlobRequest(req *http.Request) ([]byte, error) {
+	resp, err := blobClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg := strings.TrimSpace(string(body))
+		if len(msg) > 200 {
+			msg = msg[:200]
+		}
+		return nil, fmt.Errorf("%s: %s", resp.Status, msg)
+	}
+	return body, nil
+}
+
+// escapePath percent-encodes an object key the way request signatures
+// expect: everything but unreserved characters and slashes
+func escapePath(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// UploadFile copies the local file at src to the blob URL dest and returns
+// the stored object's URL
+func UploadFile(src, dest string) (string, error) {
+	store, key, err := OpenBlobURL(dest)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", src, err)
+	}
+	return store.Put(key, data)
+}
+
+// DownloadFile copies the object at the blob URL src to the local file dest
+func DownloadFile(src, dest string) error {
+	store, key, err := OpenBlobURL(src)
+	if err != nil {
+		return err
+	}
+	data, err := store.Get(key)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(dest, data, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", dest, err)
+	}
+	return nil
+}
+
+// UploadDir copies the files under the local directory src to the blob URL
+// dest, each keyed by its path below src, and returns how many it copied
+func UploadDir(src, dest string) (int, error) {
+	store, prefix, err := OpenBlobURL(strings.TrimSuffix(dest, "/"))
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	err = filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", p, err)
+		}
+		if _, err := store.Put(prefix+"/"+filepath.ToSlash(rel), data); err != nil {
+			return err
+		}
+		n++
+		return nil
+	})
+	return n, err
+}
+
+// WriteBlobFile calls write with the local path to write dest to. When dest
+// is a blob URL, write gets a path in a temporary directory and the file it
+// wrote is uploaded to dest.
+func WriteBlobFile(dest string, write func(path string) error) error {
+	if !IsBlobURL(dest) {
+		return write(dest)
+	}
+	return withBlobTemp(dest, func(local string) error {
+		if err := write(local); err != nil {
+			return err
+		}
+		if _, err := UploadFile(local, dest); err != nil {
+			return fmt.Errorf("failed to upload %s: %w", dest, err)
+		}
+		return nil
+	})
+}
+
+// WriteBlobDir is WriteBlobFile for outputs made of a directory of files,
+// such as HTML reports
+func WriteBlobDir(dest string, write func(dir string) error) error {
+	if !IsBlobURL(dest) {
+		return write(dest)
+	}
+	return withBlobTemp(dest, func(local string) error {
+		if err := write(local); err != nil {
+			return err
+		}
+		if _, err := UploadDir(local, dest); err != nil {
+			return fmt.Errorf("failed to upload %s: %w", dest, err)
+		}
+		return nil
+	})
+}
+
+// ReadBlobFile calls read with the local path to read src from. When src is
+// a blob URL, it is first downloaded to a temporary directory.
+func ReadBlobFile(src string, read func(path string) error) error {
+	if !IsBlobURL(src) {
+		return read(src)
+	}
+	return withBlobTemp(src, func(local string) error {
+		if err := DownloadFile(src, local); err != nil {
+			return fmt.Errorf("failed to download %s: %w", src, err)
+		}
+		return read(local)
+	})
+}
+
+// withBlobTemp calls fn with a path named after the blob URL in a temporary
+// directory, removed when fn returns
+func withBlobTemp(rawURL string, fn func(local string) error) error {
+	tmp, err := os.MkdirTemp("", "go-sentinel-blob-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	return fn(filepath.Join(tmp, path.Base(strings.TrimSuffix(rawURL, "/"))))
+}
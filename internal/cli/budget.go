@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Budget enforcement modes
+const (
+	BudgetWarn = "warn" // Show packages over budget in the summary
+	BudgetFail = "fail" // Also fail the run
+)
+
+// ErrBudgetExceeded is returned, wrapped, when packages ran over their
+// duration budgets and budgets are enforced
+var ErrBudgetExceeded = errors.New("duration budget exceeded")
+
+// PackageBudget limits the duration of the packages matching Pattern: an
+// import path, a path relative to the module such as "pkg/api", or either
+// followed by "/..." to include the packages below it
+type PackageBudget struct {
+	Pattern string
+	Limit   time.Duration
+}
+
+// DurationBudgets holds the duration budgets of packages. When several
+// patterns match a package, the longest one applies.
+type DurationBudgets struct {
+	Budgets []PackageBudget
+	Fail    bool // Exceeding a budget fails the run instead of only warning
+}
+
+// BudgetOverage is a package that ran longer than its budget
+type BudgetOverage struct {
+	Package  string
+	Duration time.Duration
+	Budget   time.Duration
+}
+
+// Over returns how much longer than its budget the package ran
+func (o BudgetOverage) Over() time.Duration {
+	return o.Duration - o.Budget
+}
+
+// apply records the budget of each package of run on its suite
+func (b *DurationBudgets) apply(run *TestRun) {
+	for _, suite := range run.Suites {
+		suite.Budget = b.budgetOf(suiteName(suite))
+	}
+}
+
+// budgetOf returns the budget of a package, 0 for none
+func (b *DurationBudgets) budgetOf(importPath string) time.Duration {
+	var limit time.Duration
+	best := -1
+	for _, budget := range b.Budgets {
+		if len(budget.Pattern) > best && budgetMatches(budget.Pattern, importPath) {
+			limit, best = budget.Limit, len(budget.Pattern)
+		}
+	}
+	return limit
+}
+
+// check returns ErrBudgetExceeded naming the packages of run over budget
+// when budgets are enforced
+func (b *DurationBudgets) check(run *TestRun) error {
+	overages := BudgetOverages(run)
+	if !b.Fail || len(overages) == 0 {
+		return nil
+	}
+	var names []string
+	for _, o := range overages {
+		names = append(names, fmt.Sprintf("%s by %s", o.Package, FormatDurationAdaptive(o.Over())))
+	}
+	return fmt.Errorf("%w: %s", ErrBudgetExceeded, strings.Join(names, ", "))
+}
+
+// BudgetOverages returns the packages of run that exceeded their budgets,
+// largest overage first
+func BudgetOverages(run *TestRun) []BudgetOverage {
+	var overages []BudgetOverage
+	for _, suite := range run.Suites {
+		if suite.Budget > 0 && suite.Duration > suite.Budget {
+			overages = append(overages, BudgetOverage{Package: suiteName(suite), Duration: suite.Duration, Budget: suite.Budget})
+		}
+	}
+	sort.SliceStable(overages, func(i, j int) bool {
+		return overages[i].Over() > overages[j].Over()
+	})
+	return overages
+}
+
+// budgetMatches reports whether a budget pattern covers a package
+func budgetMatches(pattern, importPath string) bool {
+	pattern = strings.TrimPrefix(pattern, "./")
+	if base, ok := strings.CutSuffix(pattern, "/..."); ok {
+		return budgetMatches(base, importPath) || strings.HasPrefix(importPath, base+"/") || strings.Contains(importPath, "/"+base+"/")
+	}
+	return importPath == pattern || strings.HasSuffix(importPath, "/"+pattern)
+}
@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDurationBudgets(t *testing.T) {
+	cfg := BudgetsConfig{
+		Packages: map[string]string{"pkg/api": "90s", "internal/...": "30s", "internal/slow": "2m"},
+		Enforce:  BudgetFail,
+	}
+	budgets, err := cfg.DurationBudgets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := &TestRun{Suites: []*TestSuite{
+		{PackageName: "example.com/app/pkg/api", Duration: 100 * time.Second},
+		{PackageName: "example.com/app/internal/store", Duration: 10 * time.Second},
+		{PackageName: "example.com/app/internal/slow", Duration: 100 * time.Second},
+		{PackageName: "example.com/app/internal/slow/deep", Duration: 45 * time.Second},
+		{PackageName: "example.com/app/cmd", Duration: time.Hour},
+	}}
+	budgets.apply(run)
+
+	want := []time.Duration{90 * time.Second, 30 * time.Second, 2 * time.Minute, 30 * time.Second, 0}
+	for i, suite := range run.Suites {
+		if suite.Budget != want[i] {
+			t.Errorf("%s: budget = %s, want %s", suite.PackageName, suite.Budget, want[i])
+		}
+	}
+
+	overages := BudgetOverages(run)
+	if len(overages) != 2 || overages[0].Package != "example.com/app/internal/slow/deep" || overages[1].Over() != 10*time.Second {
+		t.Errorf("BudgetOverages() = %+v", overages)
+	}
+	if err := budgets.check(run); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("check() = %v, want ErrBudgetExceeded", err)
+	}
+	budgets.Fail = false
+	if err := budgets.check(run); err != nil {
+		t.Errorf("check() when warning = %v", err)
+	}
+
+	rec := NewHistoryRecord(run, TriggerRun)
+	if over := rec.OverBudget(); len(over) != 2 {
+		t.Errorf("OverBudget() = %d packages, want 2", len(over))
+	}
+}
+
+func TestBudgetsConfig_Validate(t *testing.T) {
+	for _, cfg := range []BudgetsConfig{
+		{Packages: map[string]string{"pkg/api": "soon"}},
+		{Packages: map[string]string{"pkg/api": "0s"}},
+		{Packages: map[string]string{"pkg/api": "1s"}, Enforce: "block"},
+	} {
+		if err := (&Config{Budgets: cfg}).Validate(); err == nil {
+			t.Errorf("Validate() accepted %+v", cfg)
+		}
+	}
+}
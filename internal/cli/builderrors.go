@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// buildContextLines is how many lines of source are shown on each side of
+// a compiler error
+const buildContextLines = 2
+
+// compilerErrorRe matches one compiler error of go build output, such as
+// "./a.go:4:13: undefined: c", or the same reported by go vet
+var compilerErrorRe = regexp.MustCompile(`^(?:vet: )?((?:[A-Za-z]:)?[\w./\\-]+\.go):(\d+)(?::(\d+))?: (.+)$`)
+
+// BuildError is one compiler error of a package that did not build
+type BuildError struct {
+	Location *SourceLocation // File as printed by the compiler, with source context once resolved
+	Message  string          // Error text, with any indented detail lines such as "have"/"want"
+}
+
+// BuildErrorGroup is the compiler errors of one file
+type BuildErrorGroup struct {
+	File   string
+	Errors []*BuildError
+}
+
+// ParseBuildErrors extracts the compiler errors from the build output of a
+// package. Package headers ("# example") and summary lines such as "too
+// many errors" are left out.
+func ParseBuildErrors(output string) []*BuildError {
+	var errs []*BuildError
+	var last *BuildError
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if last != nil && strings.HasPrefix(line, "\t") {
+			last.Message += "\n" + strings.TrimSpace(line)
+			continue
+		}
+		last = nil
+		m := compilerErrorRe.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		loc := &SourceLocation{File: strings.ReplaceAll(m[1], `\`, "/")}
+		loc.Line, _ = strconv.Atoi(m[2])
+		loc.Column, _ = strconv.Atoi(m[3])
+		last = &BuildError{Location: loc, Message: m[4]}
+		errs = append(errs, last)
+	}
+	return errs
+}
+
+// GroupBuildErrors groups compiler errors by file, in the order the files
+// were first reported
+func GroupBuildErrors(errs []*BuildError) []*BuildErrorGroup {
+	var groups []*BuildErrorGroup
+	byFile := make(map[string]*BuildErrorGroup)
+	for _, e := range errs {
+		group, ok := byFile[e.Location.File]
+		if !ok {
+			group = &BuildErrorGroup{File: e.Location.File}
+			byFile[e.Location.File] = group
+			groups = append(groups, group)
+		}
+		group.Errors = append(group.Errors, e)
+	}
+	return groups
+}
+
+// addBuildContext attaches the surrounding source to the compiler errors
+// of run. The compiler prints paths relative to dir, where go test ran;
+// errors in files that cannot be read keep only their location.
+func addBuildContext(run *TestRun, dir string) {
+	sources := make(map[string][]string)
+	for _, suite := range run.Suites {
+		for _, e := range suite.BuildErrors {
+			path := filepath.FromSlash(e.Location.File)
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(dir, path)
+			}
+			lines, ok := sources[path]
+			if !ok {
+				lines = readSourceLines(path)
+				sources[path] = lines
+			}
+			e.Location.Snippet, e.Location.StartLine = sourceContext(lines, e.Location.Line, buildContextLines)
+		}
+	}
+}
+
+// readSourceLines returns the lines of a source file, or nil when it cannot
+// be read
+func readSourceLines(path string) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if scanner.Err() != nil {
+		return nil
+	}
+	return lines
+}
+
+// sourceContext returns the lines within radius of line (1-based) and the
+// number of the first, or an empty snippet when line is out of range
+func sourceContext(lines []string, line, radius int) (string, int) {
+	if line < 1 || line > len(lines) {
+		return "", 0
+	}
+	start := max(line-radius, 1)
+	end := min(line+radius, len(lines))
+	return strings.Join(lines[start-1:end], "\n"), start
+}
@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const sampleBuildOutput = `# example [example.test]
+./a.go:4:13: undefined: c
+./b.go:4:9: cannot use a * b (value of type int) as string value in return statement
+./a.go:12:5: not enough return values
+	have ()
+	want (int)
+vet: ./c.go:3:1: missing return
+too many errors
+`
+
+func TestParseBuildErrors(t *testing.T) {
+	errs := ParseBuildErrors(sampleBuildOutput)
+	if len(errs) != 4 {
+		t.Fatalf("got %d errors, want 4", len(errs))
+	}
+	if loc := errs[0].Location; loc.File != "./a.go" || loc.Line != 4 || loc.Column != 13 || errs[0].Message != "undefined: c" {
+		t.Errorf("errs[0] = %+v %q, want ./a.go:4:13 undefined: c", loc, errs[0].Message)
+	}
+	if want := "not enough return values\nhave ()\nwant (int)"; errs[2].Message != want {
+		t.Errorf("errs[2].Message = %q, want the detail lines kept", errs[2].Message)
+	}
+	if errs[3].Location.File != "./c.go" {
+		t.Errorf("errs[3] file = %s, want the vet error's file", errs[3].Location.File)
+	}
+
+	groups := GroupBuildErrors(errs)
+	if len(groups) != 3 || groups[0].File != "./a.go" || len(groups[0].Errors) != 2 || groups[1].File != "./b.go" {
+		t.Errorf("groups = %+v, want a.go's two errors first, then b.go and c.go", groups)
+	}
+}
+
+func TestAddBuildContext(t *testing.T) {
+	dir := t.TempDir()
+	src := "package example\n\nfunc Add(a, b int) int {\n\treturn a + c\n}\n"
+	if err := os.WriteFile(filepath.Join(dir, "a.go"), []byte(src), 0600); err != nil {
+		t.Fatal(err)
+	}
+	suite := &TestSuite{Package: "example", BuildErrors: ParseBuildErrors(sampleBuildOutput)}
+	addBuildContext(&TestRun{Suites: []*TestSuite{suite}}, dir)
+
+	loc := suite.BuildErrors[0].Location
+	if loc.StartLine != 2 || loc.Snippet != "\nfunc Add(a, b int) int {\n\treturn a + c\n}" {
+		t.Errorf("snippet from %d = %q, want lines 2-5", loc.StartLine, loc.Snippet)
+	}
+	if loc := suite.BuildErrors[2].Location; loc.Snippet != "" {
+		t.Errorf("snippet = %q, want none for a line past the end of the file", loc.Snippet)
+	}
+	if loc := suite.BuildErrors[1].Location; loc.Snippet != "" {
+		t.Errorf("snippet = %q, want none for a missing file", loc.Snippet)
+	}
+
+	var buf bytes.Buffer
+	NewRenderer(&buf).RenderSuite(suite)
+	out := buf.String()
+	for _, want := range []string{"Build failed: 4 errors in 3 files", "./a.go", "at ./a.go:4:13", "4 │", "return a + c", "want (int)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output does not contain %q:\n%s", want, out)
+		}
+	}
+}
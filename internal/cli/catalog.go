@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// PackageCatalog documents a package and the tests it contains
+type PackageCatalog struct {
+	ImportPath string
+	Dir        string          // Package directory
+	Doc        string          // Package doc comment
+	Tests      []*CatalogEntry // Tests, benchmarks, fuzz targets, and examples in file order
+}
+
+// CatalogEntry is one test function and the first line of its doc comment
+type CatalogEntry struct {
+	Name    string
+	Summary string
+	File    string // File name within the package directory
+	Line    int
+}
+
+// testFuncPrefixes are the function name prefixes go test runs
+var testFuncPrefixes = []string{"Test", "Benchmark", "Fuzz", "Example"}
+
+// BuildCatalog lists the packages matching patterns that have tests, with
+// their doc comments and test functions
+func BuildCatalog(workDir string, patterns []string) ([]*PackageCatalog, error) {
+	pkgs, err := expandPackagePatterns(workDir, patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	var catalogs []*PackageCatalog
+	for _, pkg := range pkgs {
+		if !pkg.HasTests() {
+			continue
+		}
+		catalog, err := catalogPackage(pkg)
+		if err != nil {
+			return nil, err
+		}
+		catalogs = append(catalogs, catalog)
+	}
+	sort.Slice(catalogs, func(i, j int) bool { return catalogs[i].ImportPath < catalogs[j].ImportPath })
+	return catalogs, nil
+}
+
+// catalogPackage reads the doc comment and test functions of pkg
+func catalogPackage(pkg *PackageInfo) (*PackageCatalog, error) {
+	catalog := &PackageCatalog{ImportPath: pkg.ImportPath, Dir: pkg.Dir}
+	fset := token.NewFileSet()
+
+	// The doc comment conventionally lives in doc.go; otherwise take the
+	// first file that has one
+	files := append([]string{}, pkg.GoFiles...)
+	sort.SliceStable(files, func(i, j int) bool { return files[i] == "doc.go" && files[j] != "doc.go" })
+	for _, name := range files {
+		f, err := parser.ParseFile(fset, filepath.Join(pkg.Dir, name), nil, parser.PackageClauseOnly|parser.ParseComments)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+		}
+		if f.Doc != nil {
+			catalog.Doc = strings.TrimSpace(f.Doc.Text())
+			break
+		}
+	}
+
+	testFiles := append(append([]string{}, pkg.TestGoFiles...), pkg.XTestGoFiles...)
+	for _, name := range testFiles {
+		f, err := parser.ParseFile(fset, filepath.Join(pkg.Dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+		}
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv != nil || !isTestFunc(fn.Name.Name) {
+				continue
+			}
+			catalog.Tests = append(catalog.Tests, &CatalogEntry{
+				Name:    fn.Name.Name,
+				Summary: firstLine(fn.Doc),
+				File:    name,
+				Line:    fset.Position(fn.Pos()).Line,
+			})
+		}
+	}
+	return catalog, nil
+}
+
+// isTestFunc reports whether name is one go test runs: a known prefix not
+// followed by a lowercase letter
+func isTestFunc(name string) bool {
+	for _, prefix := range testFuncPrefixes {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		rest := name[len(prefix):]
+		if rest == "" {
+			return true
+		}
+		r, _ := utf8.DecodeRuneInString(rest)
+		return !unicode.IsLower(r)
+	}
+	return false
+}
+
+// firstLine returns the first line of a doc comment
+func firstLine(doc *ast.CommentGroup) string {
+	if doc == nil {
+		return ""
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(doc.Text()), "\n")
+	return line
+}
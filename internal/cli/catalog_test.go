@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCatalogPackage(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"calc.go": "package calc\n\nfunc Add(a, b int) int { return a + b }\n",
+		"doc.go":  "// Package calc does arithmetic.\n//\n// It is small.\npackage calc\n",
+		"calc_test.go": `package calc
+
+import "testing"
+
+// TestAdd checks addition.
+// Overflow is not covered.
+func TestAdd(t *testing.T) {}
+
+func Testify(t *testing.T) {}
+
+func helper() {}
+
+func BenchmarkAdd(b *testing.B) {}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	catalog, err := catalogPackage(&PackageInfo{
+		ImportPath:  "example.com/calc",
+		Dir:         dir,
+		GoFiles:     []string{"calc.go", "doc.go"},
+		TestGoFiles: []string{"calc_test.go"},
+	})
+	if err != nil {
+		t.Fatalf("catalogPackage failed: %v", err)
+	}
+	if catalog.Doc != "Package calc does arithmetic.\n\nIt is small." {
+		t.Errorf("Doc = %q, want the doc.go comment", catalog.Doc)
+	}
+	if len(catalog.Tests) != 2 {
+		t.Fatalf("Tests = %+v, want TestAdd and BenchmarkAdd", catalog.Tests)
+	}
+	if got := catalog.Tests[0]; got.Name != "TestAdd" || got.Summary != "TestAdd checks addition." || got.Line != 7 {
+		t.Errorf("Tests[0] = %+v, want TestAdd with its first comment line at line 7", got)
+	}
+	if got := catalog.Tests[1]; got.Name != "BenchmarkAdd" || got.Summary != "" {
+		t.Errorf("Tests[1] = %+v, want BenchmarkAdd without a summary", got)
+	}
+}
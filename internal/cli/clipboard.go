@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// CopyToClipboard puts text on the system clipboard with the platform's
+// clipboard command. Where none is installed, as over SSH, it asks the
+// terminal to set the clipboard with an OSC 52 escape sequence written to
+// term. It returns how the text was copied.
+func CopyToClipboard(term io.Writer, text string) (string, error) {
+	for _, args := range clipboardCommands(runtime.GOOS, os.Getenv("WAYLAND_DISPLAY") != "") {
+		if _, err := exec.LookPath(args[0]); err != nil {
+			continue
+		}
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return "", fmt.Errorf("failed to run %s: %w: %s", args[0], err, strings.TrimSpace(string(output)))
+		}
+		return args[0], nil
+	}
+	if term == nil {
+		return "", fmt.Errorf("no clipboard command found")
+	}
+	if _, err := fmt.Fprint(term, osc52(text)); err != nil {
+		return "", err
+	}
+	return "terminal", nil
+}
+
+// clipboardCommands returns the commands that can set the clipboard on
+// goos, in order of preference
+func clipboardCommands(goos string, wayland bool) [][]string {
+	switch goos {
+	case "darwin":
+		return [][]string{{"pbcopy"}}
+	case "windows":
+		return [][]string{{"clip.exe"}}
+	}
+	var commands [][]string
+	if wayland {
+		commands = append(commands, []string{"wl-copy"})
+	}
+	return append(commands, []string{"xclip", "-selection", "clipboard"}, []string{"xsel", "--clipboard", "--input"})
+}
+
+// osc52 returns the escape sequence asking the terminal to set the
+// clipboard to text
+func osc52(text string) string {
+	return "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a"
+}
@@ -0,0 +1,29 @@
+package cli
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestClipboardCommands(t *testing.T) {
+	for _, tt := range []struct {
+		goos    string
+		wayland bool
+		want    string
+	}{
+		{"darwin", false, "[[pbcopy]]"},
+		{"windows", false, "[[clip.exe]]"},
+		{"linux", false, "[[xclip -selection clipboard] [xsel --clipboard --input]]"},
+		{"linux", true, "[[wl-copy] [xclip -selection clipboard] [xsel --clipboard --input]]"},
+	} {
+		if got := fmt.Sprint(clipboardCommands(tt.goos, tt.wayland)); got != tt.want {
+			t.Errorf("clipboardCommands(%s, %v) = %s, want %s", tt.goos, tt.wayland, got, tt.want)
+		}
+	}
+}
+
+func TestOSC52(t *testing.T) {
+	if got := osc52("go test"); got != "\x1b]52;c;Z28gdGVzdA==\a" {
+		t.Errorf("osc52() = %q", got)
+	}
+}
@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Column identifies a field shown for each test in result listings
+type Column string
+
+// Available result columns
+const (
+	ColumnStatus     Column = "status"
+	ColumnName       Column = "name"
+	ColumnPackage    Column = "package"
+	ColumnDuration   Column = "duration"
+	ColumnAttempts   Column = "attempts"
+	ColumnOwner      Column = "owner"
+	ColumnTags       Column = "tags"
+	ColumnLastChange Column = "lastChange"
+)
+
+// AllColumns lists every column in display order
+var AllColumns = []Column{
+	ColumnStatus,
+	ColumnName,
+	ColumnPackage,
+	ColumnDuration,
+	ColumnAttempts,
+	ColumnOwner,
+	ColumnTags,
+	ColumnLastChange,
+}
+
+// DefaultColumns is the column set used when none is configured
+var DefaultColumns = []Column{ColumnStatus, ColumnName, ColumnDuration}
+
+// Title returns the human-readable column name
+func (c Column) Title() string {
+	switch c {
+	case ColumnLastChange:
+		return "last change"
+	default:
+		return string(c)
+	}
+}
+
+// ParseColumns validates configured column names. Names are matched case
+// insensitively and may use "last-change" or "last_change" spellings.
+func ParseColumns(names []string) ([]Column, error) {
+	if len(names) == 0 {
+		return DefaultColumns, nil
+	}
+
+	var columns []Column
+	seen := make(map[Column]bool)
+	for _, name := range names {
+		key := strings.NewReplacer("-", "", "_", "", " ", "").Replace(strings.ToLower(name))
+		var col Column
+		for _, c := range AllColumns {
+			if strings.ToLower(string(c)) == key {
+				col = c
+				break
+			}
+		}
+		if col == "" {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		if !seen[col] {
+			seen[col] = true
+			columns = append(columns, col)
+		}
+	}
+	return columns, nil
+}
+
+// ToggleColumn shows col if it is hidden and hides it if it is shown,
+// keeping the result in AllColumns order
+func ToggleColumn(columns []Column, col Column) []Column {
+	shown := make(map[Column]bool)
+	for _, c := range columns {
+		shown[c] = true
+	}
+	shown[col] = !shown[col]
+
+	var result []Column
+	for _, c := range AllColumns {
+		if shown[c] {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// toggleColumnNumbers toggles the columns numbered, from 1 in AllColumns
+// order, by the space- or comma-separated numbers of arg. It reports
+// whether any column was toggled; unknown numbers are ignored.
+func toggleColumnNumbers(columns []Column, arg string) ([]Column, bool) {
+	toggled := false
+	for _, field := range strings.FieldsFunc(arg, func(r rune) bool { return r == ' ' || r == ',' }) {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 1 || n > len(AllColumns) {
+			continue
+		}
+		columns = ToggleColumn(columns, AllColumns[n-1])
+		toggled = true
+	}
+	return columns, toggled
+}
+
+// hasColumn reports whether col is in columns
+func hasColumn(columns []Column, col Column) bool {
+	for _, c := range columns {
+		if c == col {
+			return true
+		}
+	}
+	return false
+}
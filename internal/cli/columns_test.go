@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseColumns(t *testing.T) {
+	tests := []struct {
+		name    string
+		names   []string
+		want    []Column
+		wantErr bool
+	}{
+		{name: "default", names: nil, want: DefaultColumns},
+		{
+			name:  "mixed spellings",
+			names: []string{"Status", "name", "last-change", "owner", "name"},
+			want:  []Column{ColumnStatus, ColumnName, ColumnLastChange, ColumnOwner},
+		},
+		{name: "unknown", names: []string{"status", "coverage"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseColumns(tt.names)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseColumns(%v) error = %v, wantErr %v", tt.names, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseColumns(%v) = %v, want %v", tt.names, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToggleColumn(t *testing.T) {
+	got := ToggleColumn([]Column{ColumnStatus, ColumnName}, ColumnPackage)
+	want := []Column{ColumnStatus, ColumnName, ColumnPackage}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ToggleColumn() = %v, want %v", got, want)
+	}
+
+	got = ToggleColumn(got, ColumnName)
+	want = []Column{ColumnStatus, ColumnPackage}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ToggleColumn() = %v, want %v", got, want)
+	}
+}
+
+func TestToggleColumnNumbers(t *testing.T) {
+	got, toggled := toggleColumnNumbers(DefaultColumns, "4, 6 9 x")
+	want := []Column{ColumnStatus, ColumnName, ColumnOwner}
+	if !toggled || !reflect.DeepEqual(got, want) {
+		t.Errorf("toggleColumnNumbers() = %v, %v, want %v, true", got, toggled, want)
+	}
+	if _, toggled := toggleColumnNumbers(DefaultColumns, ""); toggled {
+		t.Error("toggleColumnNumbers() without numbers toggled a column")
+	}
+}
+
+func TestRenderer_RenderColumnPicker(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewRendererWithStyle(&buf, false)
+	r.SetColumns([]Column{ColumnStatus, ColumnOwner})
+	r.RenderColumnPicker(nil)
+
+	for _, line := range []string{"1 [x] status", "2 [ ] name", "6 [x] owner", "8 [ ] last change"} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("picker missing %q:\n%s", line, buf.String())
+		}
+	}
+}
+
+func TestCodeOwners_Owners(t *testing.T) {
+	co, err := parseCodeOwners(strings.NewReader(`
+# Default owners
+*                 @core
+/internal/cli/    @cli-team
+docs/             @docs
+parser*           @parsing
+`))
+	if err != nil {
+		t.Fatalf("parseCodeOwners failed: %v", err)
+	}
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"README.md", []string{"@core"}},
+		{"internal/cli", []string{"@cli-team"}},
+		{"internal/cli/runner.go", []string{"@cli-team"}},
+		{"internal/cli/parser.go", []string{"@parsing"}},
+		{"site/docs/index.md", []string{"@docs"}},
+		{"site/index.md", []string{"@core"}},
+		{"docs/index.md", []string{"@docs"}},
+	}
+	for _, tt := range tests {
+		if got := co.Owners(tt.path); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Owners(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRenderer_Columns(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewRendererWithStyle(&buf, false)
+	r.SetColumns([]Column{ColumnName, ColumnAttempts, ColumnOwner, ColumnLastChange})
+
+	r.RenderTestResult(&TestResult{
+		Name:       "TestExample",
+		Package:    "example/widgets",
+		Status:     TestStatusPassed,
+		Attempts:   2,
+		Owner:      "@core",
+		LastChange: time.Now().Add(-3 * time.Hour),
+	})
+
+	got := strings.TrimSpace(buf.String())
+	want := "Example 2 attempts @core changed 3h ago"
+	if got != want {
+		t.Errorf("RenderTestResult() = %q, want %q", got, want)
+	}
+}
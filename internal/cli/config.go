@@ -0,0 +1,818 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Project-level configuration files read from the working directory. A
+// project has at most one of them.
+const (
+	ConfigFileName     = ".gosentinel.json"
+	ConfigFileNameYAML = ".gosentinel.yaml"
+)
+
+// configFileNames are the accepted configuration file names, in order of preference
+var configFileNames = []string{ConfigFileNameYAML, ".gosentinel.yml", ConfigFileName}
+
+// Config holds project-level settings for go-sentinel. Command-line flags
+// override the values given here.
+type Config struct {
+	Packages     []string        `json:"packages,omitempty"`     // Packages tested when none are given, defaulting to ./...
+	GoBin        string          `json:"goBin,omitempty"`        // go command tests are run with, by name or path; "go" on the PATH by default
+	Offline      bool            `json:"offline,omitempty"`      // Never use the network, for air-gapped machines
+	Timeout      string          `json:"timeout,omitempty"`      // go test -timeout for each test binary, e.g. "5m"
+	SetupTimeout string          `json:"setupTimeout,omitempty"` // Limit on TestMain setup before the first test of a package starts, e.g. "30s"
+	Deadline     string          `json:"deadline,omitempty"`     // Limit on a whole run, e.g. "30m"; packages still running then time out
+	FailFast     bool            `json:"failFast,omitempty"`     // Stop on the first failure
+	ResultCache  bool            `json:"resultCache,omitempty"`  // Serve unchanged packages that passed before without running them
+	Retry        RetryConfig     `json:"retry,omitempty"`        // Reruns of failed tests before they count as failed
+	Watch        WatchConfig     `json:"watch,omitempty"`        // Watch mode settings
+	Coverage     CoverageConfig  `json:"coverage,omitempty"`     // Coverage reporting
+	Generate     []GenerateStep  `json:"generate,omitempty"`     // Code generation steps run before affected tests
+	History      HistoryConfig   `json:"history,omitempty"`      // Run history settings
+	Health       HealthConfig    `json:"health,omitempty"`       // Watch mode health checks
+	Columns      []string        `json:"columns,omitempty"`      // Fields shown for each test result
+	Timezone     string          `json:"timezone,omitempty"`     // Zone of reported timestamps: "local" (default), "UTC", or an IANA name
+	SlowTests    SlowTestsConfig `json:"slowTests,omitempty"`    // Live warnings for unusually slow tests
+	Process      ProcessConfig   `json:"process,omitempty"`      // Priority of test processes
+	Power        PowerConfig     `json:"power,omitempty"`        // Watch mode throttling on battery
+	Desktop      DesktopConfig   `json:"desktop,omitempty"`      // Watch mode desktop notifications
+	Env          EnvConfig       `json:"env,omitempty"`          // Environment variables passed to test processes
+	Budgets      BudgetsConfig   `json:"budgets,omitempty"`      // Duration budgets of packages
+	Limits       LimitsConfig    `json:"limits,omitempty"`       // Memory, file descriptor, and CPU limits of test binaries
+
+	// PackageTimeouts override timeout for the packages matching a
+	// pattern, as budgets are keyed, e.g. "./integration/...": "20m"
+	PackageTimeouts map[string]string `json:"packageTimeouts,omitempty"`
+
+	// Profiles are named kinds of test runs, such as "unit", "integration",
+	// or "e2e", selected with run --profile
+	Profiles map[string]ProfileConfig `json:"profiles,omitempty"`
+
+	Summarizer    SummarizerConfig    `json:"summarizer,omitempty"`    // Explains failures with a team-provided model
+	Accessibility AccessibilityConfig `json:"accessibility,omitempty"` // Status display that does not rely on color
+}
+
+// RetryConfig gives failed tests more attempts before they count as failed
+type RetryConfig struct {
+	Count      int  `json:"count,omitempty"`      // Times a failed test is rerun, 0 to disable
+	Quarantine bool `json:"quarantine,omitempty"` // Record the tests that pass only on retry in .go-sentinel/quarantine.json
+}
+
+// WatchConfig tunes which files watch mode reacts to
+type WatchConfig struct {
+	Ignore []string `json:"ignore,omitempty"` // Globs of files and directories not watched, relative to the project, e.g. "**/testdata/**"
+}
+
+// CoverageConfig sets the defaults of coverage reporting
+type CoverageConfig struct {
+	Watch bool   `json:"watch,omitempty"` // In watch mode, show how each saved file's coverage changed
+	Sort  string `json:"sort,omitempty"`  // Order of the coverage command: "name" (default) or "coverage"
+}
+
+// TestTimeout returns the go test timeout, 0 for the go default
+func (c *Config) TestTimeout() (time.Duration, error) {
+	return parseTimeout(c.Timeout)
+}
+
+// TestSetupTimeout returns the limit on TestMain setup, 0 for none
+func (c *Config) TestSetupTimeout() (time.Duration, error) {
+	return parseTimeout(c.SetupTimeout)
+}
+
+// RunDeadline returns the limit on a whole run, 0 for none
+func (c *Config) RunDeadline() (time.Duration, error) {
+	return parseTimeout(c.Deadline)
+}
+
+// TestPackageTimeouts returns the timeout overrides of packages, by
+// pattern
+func (c *Config) TestPackageTimeouts() ([]PackageTimeout, error) {
+	var timeouts []PackageTimeout
+	for pattern, value := range c.PackageTimeouts {
+		timeout, err := parseTimeout(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", pattern, err)
+		}
+		if timeout == 0 {
+			return nil, fmt.Errorf("%s: timeout must be positive", pattern)
+		}
+		timeouts = append(timeouts, PackageTimeout{Pattern: pattern, Timeout: timeout})
+	}
+	sort.Slice(timeouts, func(i, j int) bool {
+		return timeouts[i].Pattern < timeouts[j].Pattern
+	})
+	return timeouts, nil
+}
+
+// parseTimeout parses a configured timeout, 0 when unset
+func parseTimeout(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if timeout < 0 {
+		return 0, fmt.Errorf("must not be negative")
+	}
+	return timeout, nil
+}
+
+// AccessibilityConfig makes test statuses distinguishable without relying
+// on red and green
+type AccessibilityConfig struct {
+	Palette    string `json:"palette,omitempty"`    // Status colors: "default" or "colorblind" (deuteranopia-safe blue and orange)
+	StatusText bool   `json:"statusText,omitempty"` // Show PASS, FAIL, and SKIP beside status icons
+}
+
+// HistoryConfig controls how runs are recorded in history
+type HistoryConfig struct {
+	Disabled    bool   `json:"disabled,omitempty"`    // Do not record runs
+	KeepRepeats bool   `json:"keepRepeats,omitempty"` // Store every identical green watch run separately
+	MaxRuns     int    `json:"maxRuns,omitempty"`     // Records kept before the oldest are dropped, 0 for no limit
+	Backend     string `json:"backend,omitempty"`     // Where runs are stored: "file" (default), "sqlite", or "postgres"
+	PostgresURL string `json:"postgresURL,omitempty"` // Connection string for the postgres backend, defaulting to $SENTINEL_POSTGRES_URL
+	SQLitePath  string `json:"sqlitePath,omitempty"`  // Database of the sqlite backend, relative to the project, defaulting to .go-sentinel/history.db
+	Project     string `json:"project,omitempty"`     // Name separating this project's runs in a shared database, defaulting to the directory name
+}
+
+// History backends selectable in HistoryConfig
+const (
+	HistoryBackendFile     = "file"
+	HistoryBackendSQLite   = "sqlite"
+	HistoryBackendPostgres = "postgres"
+)
+
+// Shared reports whether every machine reads and writes the same history,
+// as with the postgres backend, rather than a history of its own
+func (c HistoryConfig) Shared() bool {
+	return c.Backend == HistoryBackendPostgres
+}
+
+// EnvPostgresURL supplies the postgres connection string when the
+// configuration does not, keeping credentials out of the project file
+const EnvPostgresURL = "SENTINEL_POSTGRES_URL"
+
+// HealthConfig tunes the watch mode health checks. Zero values keep the defaults.
+type HealthConfig struct {
+	Disabled         bool `json:"disabled,omitempty"`         // Never restart the watcher
+	IntervalSeconds  int  `json:"intervalSeconds,omitempty"`  // Seconds between checks
+	FailureThreshold int  `json:"failureThreshold,omitempty"` // Consecutive failed checks before restarting
+	MaxHeapMB        int  `json:"maxHeapMB,omitempty"`        // Heap size considered unhealthy
+	MaxQueuedEvents  int  `json:"maxQueuedEvents,omitempty"`  // Pending file events considered saturated
+	IgnoreStuck      bool `json:"ignoreStuck,omitempty"`      // Do not restart a watcher that misses file changes
+}
+
+// SlowTestsConfig tunes the live warnings for tests running longer than
+// usual. Zero values keep the defaults.
+type SlowTestsConfig struct {
+	Disabled   bool    `json:"disabled,omitempty"`   // Never warn
+	Factor     float64 `json:"factor,omitempty"`     // Multiple of the historical p95 duration that triggers a warning
+	MinSeconds float64 `json:"minSeconds,omitempty"` // Minimum running time before warning
+	MinSamples int     `json:"minSamples,omitempty"` // Passing runs needed before a test is judged
+}
+
+// SlowTestWarning returns the warning policy described by the
+// configuration, or nil when warnings are disabled
+func (c SlowTestsConfig) SlowTestWarning() *SlowTestWarning {
+	if c.Disabled {
+		return nil
+	}
+	policy := DefaultSlowTestWarning()
+	if c.Factor > 0 {
+		policy.Factor = c.Factor
+	}
+	if c.MinSeconds > 0 {
+		policy.MinElapsed = time.Duration(c.MinSeconds * float64(time.Second))
+	}
+	if c.MinSamples > 0 {
+		policy.MinSamples = c.MinSamples
+	}
+	return policy
+}
+
+// ProcessConfig lowers the priority of test processes so large runs, in
+// watch mode especially, do not make the machine unusable
+type ProcessConfig struct {
+	Nice   int    `json:"nice,omitempty"`   // 1 (slightly lower) to 19 (lowest); on Windows 1-9 is below normal, 10-19 idle
+	IdleIO bool   `json:"idleIO,omitempty"` // Idle I/O scheduling class (Linux)
+	CPUs   string `json:"cpus,omitempty"`   // CPUs test processes may use, e.g. "0-3,6"
+}
+
+// ProcessPriority returns the priority settings described by the
+// configuration, or nil when processes run at normal priority
+func (c ProcessConfig) ProcessPriority() (*ProcessPriority, error) {
+	cpus, err := ParseCPUList(c.CPUs)
+	if err != nil {
+		return nil, err
+	}
+	if c.Nice == 0 && !c.IdleIO && len(cpus) == 0 {
+		return nil, nil
+	}
+	return &ProcessPriority{Nice: c.Nice, IdleIO: c.IdleIO, CPUs: cpus}, nil
+}
+
+// PowerConfig tunes how watch mode slows down on battery. Zero values keep
+// the defaults.
+type PowerConfig struct {
+	Disabled        bool `json:"disabled,omitempty"`        // Run at full speed on battery too
+	Parallelism     int  `json:"parallelism,omitempty"`     // Packages tested at once on battery
+	DebounceMs      int  `json:"debounceMs,omitempty"`      // Quiet period after a change before rerunning on battery
+	IntervalSeconds int  `json:"intervalSeconds,omitempty"` // Seconds between power source checks
+}
+
+// PowerPolicy returns the battery policy described by the configuration, or
+// nil when throttling is disabled
+func (c PowerConfig) PowerPolicy() *PowerPolicy {
+	if c.Disabled {
+		return nil
+	}
+	policy := DefaultPowerPolicy()
+	if c.Parallelism > 0 {
+		policy.Parallelism = c.Parallelism
+	}
+	if c.DebounceMs > 0 {
+		policy.Debounce = time.Duration(c.DebounceMs) * time.Millisecond
+	}
+	if c.IntervalSeconds > 0 {
+		policy.Interval = time.Duration(c.IntervalSeconds) * time.Second
+	}
+	return policy
+}
+
+// BudgetsConfig declares how long packages may take, keyed by import path
+// or by path relative to the module, e.g. "pkg/api": "90s"
+type BudgetsConfig struct {
+	Packages map[string]string `json:"packages,omitempty"` // Package pattern to duration budget
+	Enforce  string            `json:"enforce,omitempty"`  // "warn" (default) to report packages over budget, or "fail" to also fail the run
+}
+
+// DurationBudgets returns the budgets described by the configuration, or
+// nil when there are none
+func (c BudgetsConfig) DurationBudgets() (*DurationBudgets, error) {
+	if len(c.Packages) == 0 {
+		return nil, nil
+	}
+	budgets := &DurationBudgets{Fail: c.Enforce == BudgetFail}
+	for pattern, value := range c.Packages {
+		limit, err := parseTimeout(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", pattern, err)
+		}
+		if limit == 0 {
+			return nil, fmt.Errorf("%s: budget must be positive", pattern)
+		}
+		budgets.Budgets = append(budgets.Budgets, PackageBudget{Pattern: pattern, Limit: limit})
+	}
+	sort.Slice(budgets.Budgets, func(i, j int) bool {
+		return budgets.Budgets[i].Pattern < budgets.Budgets[j].Pattern
+	})
+	return budgets, nil
+}
+
+// ResourceLimitsConfig caps what one test binary may use; unset values
+// are unlimited
+type ResourceLimitsConfig struct {
+	Memory string `json:"memory,omitempty"` // Heap and data, e.g. "2GB"
+	Files  int    `json:"files,omitempty"`  // Open file descriptors
+	CPU    string `json:"cpu,omitempty"`    // CPU time, e.g. "5m"
+}
+
+// ResourceLimits returns the limits described by the configuration
+func (c ResourceLimitsConfig) ResourceLimits() (ResourceLimits, error) {
+	var limits ResourceLimits
+	if c.Memory != "" {
+		memory, err := ParseByteSize(c.Memory)
+		if err != nil {
+			return limits, fmt.Errorf("memory: %w", err)
+		}
+		limits.Memory = memory
+	}
+	if c.Files < 0 {
+		return limits, fmt.Errorf("files must not be negative")
+	}
+	limits.Files = c.Files
+	cpu, err := parseTimeout(c.CPU)
+	if err != nil {
+		return limits, fmt.Errorf("cpu: %w", err)
+	}
+	limits.CPU = cpu
+	return limits, nil
+}
+
+// LimitsConfig caps the resources of each test binary. Packages override
+// the defaults, keyed by import path or path relative to the module as
+// budgets are.
+type LimitsConfig struct {
+	ResourceLimitsConfig
+	Packages map[string]ResourceLimitsConfig `json:"packages,omitempty"` // Package pattern to its own limits
+}
+
+// PackageLimits returns the limits described by the configuration, or nil
+// when there are none
+func (c LimitsConfig) PackageLimits() (*PackageLimits, error) {
+	defaults, err := c.ResourceLimits()
+	if err != nil {
+		return nil, err
+	}
+	if defaults.IsZero() && len(c.Packages) == 0 {
+		return nil, nil
+	}
+	limits := &PackageLimits{Default: defaults}
+	for pattern, value := range c.Packages {
+		pkgLimits, err := value.ResourceLimits()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", pattern, err)
+		}
+		limits.Packages = append(limits.Packages, PackageLimit{Pattern: pattern, Limits: pkgLimits})
+	}
+	sort.Slice(limits.Packages, func(i, j int) bool {
+		return limits.Packages[i].Pattern < limits.Packages[j].Pattern
+	})
+	return limits, nil
+}
+
+// ProfileConfig is one kind of test run: the build tags it needs, the
+// packages it covers, the environment it runs in, its timeouts, and the
+// commands setting up and tearing down what it needs. Unset fields keep
+// the project's settings.
+type ProfileConfig struct {
+	Tags            []string          `json:"tags,omitempty"`            // Build tags, passed as go test -tags and used to list packages
+	Packages        []string          `json:"packages,omitempty"`        // Packages tested when none are given, replacing packages
+	Short           bool              `json:"short,omitempty"`           // Run with go test -short
+	Env             map[string]string `json:"env,omitempty"`             // Variables set for the test processes
+	Timeout         string            `json:"timeout,omitempty"`         // Replaces timeout
+	PackageTimeouts map[string]string `json:"packageTimeouts,omitempty"` // Added to packageTimeouts, replacing the same patterns
+	Before          []HookConfig      `json:"before,omitempty"`          // Run before the tests; a failure aborts the run
+	After           []HookConfig      `json:"after,omitempty"`           // Run after the tests, even when they or a before hook failed
+}
+
+// Profile returns the profile called name
+func (c *Config) Profile(name string) (ProfileConfig, error) {
+	profile, ok := c.Profiles[name]
+	if !ok {
+		names := make([]string, 0, len(c.Profiles))
+		for n := range c.Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return profile, fmt.Errorf("unknown profile %q: the config defines no profiles", name)
+		}
+		return profile, fmt.Errorf("unknown profile %q, want one of %s", name, strings.Join(names, ", "))
+	}
+	return profile, nil
+}
+
+// BuildFlags returns the go test flags the profile adds
+func (p ProfileConfig) BuildFlags() []string {
+	var flags []string
+	if len(p.Tags) > 0 {
+		flags = append(flags, "-tags="+strings.Join(p.Tags, ","))
+	}
+	if p.Short {
+		flags = append(flags, "-short")
+	}
+	return flags
+}
+
+// Environ returns the variables the profile sets, as KEY=value sorted by
+// name
+func (p ProfileConfig) Environ() []string {
+	env := make([]string, 0, len(p.Env))
+	for key, value := range p.Env {
+		env = append(env, key+"="+value)
+	}
+	sort.Strings(env)
+	return env
+}
+
+// Apply returns the configuration with the profile's packages and timeouts
+// in place of the project's
+func (p ProfileConfig) Apply(c Config) Config {
+	if len(p.Packages) > 0 {
+		c.Packages = p.Packages
+	}
+	if p.Timeout != "" {
+		c.Timeout = p.Timeout
+	}
+	if len(p.PackageTimeouts) > 0 {
+		timeouts := make(map[string]string, len(c.PackageTimeouts)+len(p.PackageTimeouts))
+		for pattern, timeout := range c.PackageTimeouts {
+			timeouts[pattern] = timeout
+		}
+		for pattern, timeout := range p.PackageTimeouts {
+			timeouts[pattern] = timeout
+		}
+		c.PackageTimeouts = timeouts
+	}
+	return c
+}
+
+// validate checks the profile's tags and timeouts
+func (p ProfileConfig) validate() error {
+	for _, tag := range p.Tags {
+		if tag == "" || strings.ContainsAny(tag, ", \t") {
+			return fmt.Errorf("tags: invalid build tag %q", tag)
+		}
+	}
+	for key := range p.Env {
+		if key == "" || strings.Contains(key, "=") {
+			return fmt.Errorf("env: invalid variable name %q", key)
+		}
+	}
+	if _, err := parseTimeout(p.Timeout); err != nil {
+		return fmt.Errorf("timeout: %w", err)
+	}
+	if _, err := (&Config{PackageTimeouts: p.PackageTimeouts}).TestPackageTimeouts(); err != nil {
+		return fmt.Errorf("packageTimeouts: %w", err)
+	}
+	if err := validateHooks(HookBefore, p.Before); err != nil {
+		return err
+	}
+	return validateHooks(HookAfter, p.After)
+}
+
+// validateHooks checks that every hook of a phase has a command
+func validateHooks(phase string, hooks []HookConfig) error {
+	for i, hook := range hooks {
+		if len(hook.Run) == 0 {
+			return fmt.Errorf("%s hook %d (%s): run command is required", phase, i, hook.Name)
+		}
+	}
+	return nil
+}
+
+// EnvConfig limits the environment variables passed to test processes.
+// Both lists hold globs of variable names, such as "AWS_*".
+type EnvConfig struct {
+	Allow []string `json:"allow,omitempty"` // Only pass these, plus the variables go needs; all when empty
+	Deny  []string `json:"deny,omitempty"`  // Never pass these, even when allowed
+}
+
+// EnvPolicy returns the policy described by the configuration, or nil to
+// pass every variable
+func (c EnvConfig) EnvPolicy() *EnvPolicy {
+	if len(c.Allow) == 0 && len(c.Deny) == 0 {
+		return nil
+	}
+	return &EnvPolicy{Allow: c.Allow, Deny: c.Deny}
+}
+
+// DesktopConfig sets up desktop notifications when watch mode runs go
+// from passing to failing or back
+type DesktopConfig struct {
+	Notify          bool `json:"notify,omitempty"`          // Show desktop notifications
+	ThrottleSeconds int  `json:"throttleSeconds,omitempty"` // Least seconds between notifications, default 10
+}
+
+// DesktopNotifier returns the notifier described by the configuration, or
+// nil when notifications are off
+func (c DesktopConfig) DesktopNotifier() *DesktopNotifier {
+	if !c.Notify {
+		return nil
+	}
+	throttle := DefaultDesktopThrottle
+	if c.ThrottleSeconds > 0 {
+		throttle = time.Duration(c.ThrottleSeconds) * time.Second
+	}
+	return NewDesktopNotifier(throttle)
+}
+
+// SummarizerConfig points failure summaries at a command or HTTP endpoint.
+// Either receives a FailureContext as JSON and replies with a
+// FailureSummary as JSON; commands may also print plain text.
+type SummarizerConfig struct {
+	Command  []string          `json:"command,omitempty"`  // Command run for each failure, e.g. ["./scripts/explain.sh"]
+	URL      string            `json:"url,omitempty"`      // Endpoint each failure is posted to
+	Headers  map[string]string `json:"headers,omitempty"`  // Extra request headers for the endpoint
+	TokenEnv string            `json:"tokenEnv,omitempty"` // Environment variable holding a bearer token for the endpoint
+}
+
+// FailureSummarizer returns the summarizer described by the configuration,
+// or nil when none is configured
+func (c SummarizerConfig) FailureSummarizer(workDir string) FailureSummarizer {
+	switch {
+	case len(c.Command) > 0:
+		return &CommandSummarizer{Command: c.Command, Dir: workDir}
+	case c.URL != "":
+		headers := make(map[string]string, len(c.Headers)+1)
+		for name, value := range c.Headers {
+			headers[name] = value
+		}
+		if c.TokenEnv != "" {
+			headers["Authorization"] = "Bearer " + os.Getenv(c.TokenEnv)
+		}
+		return &HTTPSummarizer{URL: c.URL, Headers: headers, Client: &http.Client{Timeout: defaultSummaryTimeout}}
+	}
+	return nil
+}
+
+// WatchHealth returns the health policy described by the configuration, or
+// nil when health checks are disabled
+func (c HealthConfig) WatchHealth() *WatchHealth {
+	if c.Disabled {
+		return nil
+	}
+	health := DefaultWatchHealth()
+	if c.IntervalSeconds > 0 {
+		health.Interval = time.Duration(c.IntervalSeconds) * time.Second
+	}
+	if c.FailureThreshold > 0 {
+		health.FailureThreshold = c.FailureThreshold
+	}
+	if c.MaxHeapMB > 0 {
+		health.MaxHeapBytes = uint64(c.MaxHeapMB) << 20
+	}
+	if c.MaxQueuedEvents > 0 {
+		health.MaxQueuedEvents = c.MaxQueuedEvents
+	}
+	health.DetectStuck = !c.IgnoreStuck
+	return health
+}
+
+// FindConfigFile returns the path of the configuration file in dir, or ""
+// when there is none. Having more than one is an error, as it would be
+// unclear which applies.
+func FindConfigFile(dir string) (string, error) {
+	var found []string
+	for _, name := range configFileNames {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			found = append(found, name)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return "", nil
+	case 1:
+		return filepath.Join(dir, found[0]), nil
+	}
+	return "", fmt.Errorf("found both %s and %s; keep only one", found[0], found[1])
+}
+
+// LoadConfig reads the configuration file from dir, in YAML or JSON. A
+// missing file yields an empty configuration.
+func LoadConfig(dir string) (*Config, error) {
+	cfg := &Config{}
+
+	file, err := FindConfigFile(dir)
+	if err != nil {
+		return nil, err
+	}
+	if file == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	// YAML files share the JSON field names, so they are converted to JSON
+	// rather than described twice
+	if filepath.Ext(file) != ".json" {
+		if data, err = yamlToJSON(data); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", filepath.Base(file), err)
+		}
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filepath.Base(file), err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate checks the configuration for missing or inconsistent values
+func (c *Config) Validate() error {
+	for i, step := range c.Generate {
+		if len(step.Run) == 0 {
+			return fmt.Errorf("generate step %d (%s): run command is required", i, step.Name)
+		}
+		if len(step.Inputs) == 0 {
+			return fmt.Errorf("generate step %d (%s): at least one input pattern is required", i, step.Name)
+		}
+	}
+	if c.History.MaxRuns < 0 {
+		return fmt.Errorf("history: maxRuns must not be negative")
+	}
+	switch c.History.Backend {
+	case "", HistoryBackendFile, HistoryBackendSQLite, HistoryBackendPostgres:
+	default:
+		return fmt.Errorf("history: unknown backend %q, want %q, %q, or %q", c.History.Backend, HistoryBackendFile, HistoryBackendSQLite, HistoryBackendPostgres)
+	}
+	if c.Health.IntervalSeconds < 0 || c.Health.FailureThreshold < 0 ||
+		c.Health.MaxHeapMB < 0 || c.Health.MaxQueuedEvents < 0 {
+		return fmt.Errorf("health: values must not be negative")
+	}
+	if _, err := ParseColumns(c.Columns); err != nil {
+		return fmt.Errorf("columns: %w", err)
+	}
+	if c.SlowTests.Factor < 0 || c.SlowTests.MinSeconds < 0 || c.SlowTests.MinSamples < 0 {
+		return fmt.Errorf("slowTests: values must not be negative")
+	}
+	if c.SlowTests.Factor > 0 && c.SlowTests.Factor < 1 {
+		return fmt.Errorf("slowTests: factor must be at least 1")
+	}
+	if c.Power.Parallelism < 0 || c.Power.DebounceMs < 0 || c.Power.IntervalSeconds < 0 {
+		return fmt.Errorf("power: values must not be negative")
+	}
+	if c.Retry.Count < 0 {
+		return fmt.Errorf("retry: count must not be negative")
+	}
+	if c.Desktop.ThrottleSeconds < 0 {
+		return fmt.Errorf("desktop: throttleSeconds must not be negative")
+	}
+	if c.Budgets.Enforce != "" && c.Budgets.Enforce != BudgetWarn && c.Budgets.Enforce != BudgetFail {
+		return fmt.Errorf("budgets: enforce must be %s or %s", BudgetWarn, BudgetFail)
+	}
+	if _, err := c.Budgets.DurationBudgets(); err != nil {
+		return fmt.Errorf("budgets: %w", err)
+	}
+	if _, err := c.Limits.PackageLimits(); err != nil {
+		return fmt.Errorf("limits: %w", err)
+	}
+	if policy := c.Env.EnvPolicy(); policy != nil {
+		if err := policy.Validate(); err != nil {
+			return fmt.Errorf("env: %w", err)
+		}
+	}
+	if c.Process.Nice < 0 || c.Process.Nice > 19 {
+		return fmt.Errorf("process: nice must be between 0 and 19")
+	}
+	if _, err := ParseCPUList(c.Process.CPUs); err != nil {
+		return fmt.Errorf("process: cpus: %w", err)
+	}
+	if len(c.Summarizer.Command) > 0 && c.Summarizer.URL != "" {
+		return fmt.Errorf("summarizer: set either command or url, not both")
+	}
+	if c.Summarizer.TokenEnv != "" && c.Summarizer.URL == "" {
+		return fmt.Errorf("summarizer: tokenEnv requires url")
+	}
+	if _, err := PaletteByName(c.Accessibility.Palette); err != nil {
+		return fmt.Errorf("accessibility: %w", err)
+	}
+	if _, err := LoadTimezone(c.Timezone); err != nil {
+		return fmt.Errorf("timezone: %w", err)
+	}
+	if _, err := c.TestTimeout(); err != nil {
+		return fmt.Errorf("timeout: %w", err)
+	}
+	if _, err := c.TestSetupTimeout(); err != nil {
+		return fmt.Errorf("setupTimeout: %w", err)
+	}
+	if _, err := c.RunDeadline(); err != nil {
+		return fmt.Errorf("deadline: %w", err)
+	}
+	if _, err := c.TestPackageTimeouts(); err != nil {
+		return fmt.Errorf("packageTimeouts: %w", err)
+	}
+	for name, profile := range c.Profiles {
+		if err := profile.validate(); err != nil {
+			return fmt.Errorf("profiles: %s: %w", name, err)
+		}
+	}
+	switch c.Coverage.Sort {
+	case "", CoverageSortName, CoverageSortCoverage:
+	default:
+		return fmt.Errorf("coverage: unknown sort %q, want %q or %q", c.Coverage.Sort, CoverageSortName, CoverageSortCoverage)
+	}
+	for _, pattern := range c.Watch.Ignore {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("watch: invalid ignore pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// yamlToJSON converts a YAML document to JSON
+func yamlToJSON(data []byte) ([]byte, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(doc)
+}
+
+// ConfigTemplate returns a starting configuration file in the format its
+// name implies, with the defaults spelled out. The JSON form lacks the
+// comments of the YAML one.
+func ConfigTemplate(name string) []byte {
+	if filepath.Ext(name) != ".json" {
+		return []byte(configTemplateYAML)
+	}
+	data, _ := yamlToJSON([]byte(configTemplateYAML))
+	var out bytes.Buffer
+	json.Indent(&out, data, "", "  ")
+	out.WriteByte('\n')
+	return out.Bytes()
+}
+
+// configTemplateYAML is the starting configuration, with comments
+const configTemplateYAML = `# go-sentinel project configuration. Command-line flags override these values.
+
+# Packages tested when none are given on the command line
+packages:
+  - ./...
+
+# go command tests are run with, by name or path. The go on the PATH when empty.
+# goBin: /usr/local/go/bin/go
+
+# Never use the network, for air-gapped machines: go commands only use the
+# module cache, notifications are not sent, and other connections fail.
+# offline: false
+
+# go test -timeout for each test binary
+timeout: 10m
+
+# Limit on TestMain setup before a package's first test starts; go test's
+# own timeout only starts with the tests. Unlimited when empty.
+# setupTimeout: 1m
+
+# Stop on the first failure
+failFast: false
+
+# Serve packages that passed before from go-sentinel's result cache, marked
+# (cached), while their sources, the module packages they depend on, and
+# the selected tests are unchanged. Unlike go test's cache, it is not
+# invalidated by files or environment variables read outside the module.
+# resultCache: false
+
+retry:
+  # Times a failed test is rerun before it counts as failed. Tests that pass
+  # on a rerun are reported as passed after retry.
+  count: 0
+  # Record the tests that pass only on retry in .go-sentinel/quarantine.json
+  quarantine: true
+
+watch:
+  # Files and directories watch mode ignores, relative to the project
+  ignore:
+    - "**/testdata/**"
+
+coverage:
+  # In watch mode, show how each saved file's coverage changed
+  watch: false
+  # Order of the coverage command: name, or coverage for least covered first
+  sort: name
+
+desktop:
+  # In watch mode, show a desktop notification when the tests start failing
+  # or pass again
+  notify: false
+  # Least seconds between notifications, so rapid edits do not flood them
+  throttleSeconds: 10
+
+budgets:
+  # Longest each package may take, by import path or path in the module;
+  # "/..." includes the packages below. The most specific pattern applies.
+  # packages:
+  #   pkg/api: 90s
+  #   internal/...: 30s
+  # warn lists packages over budget in the summary; fail also fails the run
+  enforce: warn
+
+limits:
+  # Most each test binary may use before it is stopped, unlimited when
+  # empty: memory for its heap and data, open files, and CPU time. Packages
+  # override them by import path or path in the module, as for budgets.
+  # memory: 2GB
+  # files: 1024
+  # cpu: 10m
+  # packages:
+  #   internal/render:
+  #     memory: 512MB
+
+env:
+  # Environment variables test processes may see, as globs of names. With an
+  # allowlist, only those and the variables go needs are passed; all are
+  # passed otherwise. The denylist always wins.
+  # allow: ["CI", "DATABASE_URL"]
+  # deny: ["AWS_*", "*_TOKEN", "*_SECRET*"]
+
+# Time zone of reported timestamps: local, UTC, or an IANA name
+timezone: local
+
+accessibility:
+  # Status colors: default, or colorblind
+  palette: default
+  # Show PASS, FAIL, and SKIP beside status icons
+  statusText: false
+`
@@ -0,0 +1,179 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	t.Run("missing file", func(t *testing.T) {
+		cfg, err := LoadConfig(t.TempDir())
+		if err != nil {
+			t.Fatalf("LoadConfig failed: %v", err)
+		}
+		if len(cfg.Generate) != 0 {
+			t.Errorf("got %d generate steps, want 0", len(cfg.Generate))
+		}
+	})
+
+	t.Run("generate steps", func(t *testing.T) {
+		dir := t.TempDir()
+		data := `{"generate": [{"name": "mocks", "run": ["go", "generate", "./..."], "inputs": ["internal/**/*.go"]}]}`
+		if err := os.WriteFile(filepath.Join(dir, ConfigFileName), []byte(data), 0600); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+
+		cfg, err := LoadConfig(dir)
+		if err != nil {
+			t.Fatalf("LoadConfig failed: %v", err)
+		}
+		if len(cfg.Generate) != 1 {
+			t.Fatalf("got %d generate steps, want 1", len(cfg.Generate))
+		}
+		if cfg.Generate[0].Name != "mocks" {
+			t.Errorf("Name = %s, want mocks", cfg.Generate[0].Name)
+		}
+	})
+
+	t.Run("invalid step", func(t *testing.T) {
+		dir := t.TempDir()
+		data := `{"generate": [{"name": "empty", "inputs": ["*.go"]}]}`
+		if err := os.WriteFile(filepath.Join(dir, ConfigFileName), []byte(data), 0600); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+
+		if _, err := LoadConfig(dir); err == nil {
+			t.Error("Expected error for step without run command")
+		}
+	})
+
+	t.Run("yaml", func(t *testing.T) {
+		dir := t.TempDir()
+		data := "packages: [./internal/...]\ntimeout: 2m\nfailFast: true\nwatch:\n  ignore: [\"**/gen/**\"]\n"
+		if err := os.WriteFile(filepath.Join(dir, ConfigFileNameYAML), []byte(data), 0600); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+
+		cfg, err := LoadConfig(dir)
+		if err != nil {
+			t.Fatalf("LoadConfig failed: %v", err)
+		}
+		if len(cfg.Packages) != 1 || cfg.Packages[0] != "./internal/..." || !cfg.FailFast {
+			t.Errorf("cfg = %+v, want packages and failFast from the file", cfg)
+		}
+		if timeout, _ := cfg.TestTimeout(); timeout != 2*time.Minute {
+			t.Errorf("TestTimeout = %v, want 2m", timeout)
+		}
+		if len(cfg.Watch.Ignore) != 1 || cfg.Watch.Ignore[0] != "**/gen/**" {
+			t.Errorf("Watch.Ignore = %v, want **/gen/**", cfg.Watch.Ignore)
+		}
+	})
+
+	t.Run("profiles", func(t *testing.T) {
+		dir := t.TempDir()
+		data := "packages: [./...]\ntimeout: 2m\npackageTimeouts:\n  ./slow/...: 5m\n" +
+			"profiles:\n  integration:\n    tags: [integration, db]\n    packages: [./store/...]\n    short: true\n" +
+			"    env:\n      DB_URL: postgres://test\n    timeout: 10m\n    packageTimeouts:\n      ./slow/...: 20m\n"
+		if err := os.WriteFile(filepath.Join(dir, ConfigFileNameYAML), []byte(data), 0600); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+
+		cfg, err := LoadConfig(dir)
+		if err != nil {
+			t.Fatalf("LoadConfig failed: %v", err)
+		}
+		profile, err := cfg.Profile("integration")
+		if err != nil {
+			t.Fatalf("Profile failed: %v", err)
+		}
+		if flags := profile.BuildFlags(); len(flags) != 2 || flags[0] != "-tags=integration,db" || flags[1] != "-short" {
+			t.Errorf("BuildFlags = %v, want the tags and -short", flags)
+		}
+		if env := profile.Environ(); len(env) != 1 || env[0] != "DB_URL=postgres://test" {
+			t.Errorf("Environ = %v", env)
+		}
+		applied := profile.Apply(*cfg)
+		if len(applied.Packages) != 1 || applied.Packages[0] != "./store/..." {
+			t.Errorf("Packages = %v, want the profile's", applied.Packages)
+		}
+		if timeout, _ := applied.TestTimeout(); timeout != 10*time.Minute {
+			t.Errorf("TestTimeout = %v, want the profile's 10m", timeout)
+		}
+		if timeouts, _ := applied.TestPackageTimeouts(); len(timeouts) != 1 || timeouts[0].Timeout != 20*time.Minute {
+			t.Errorf("TestPackageTimeouts = %v, want the profile's override", timeouts)
+		}
+		if cfg.Timeout != "2m" || cfg.PackageTimeouts["./slow/..."] != "5m" {
+			t.Errorf("Apply changed the project's config: %+v", cfg)
+		}
+		if _, err := cfg.Profile("e2e"); err == nil || !strings.Contains(err.Error(), "integration") {
+			t.Errorf("Profile(e2e) error = %v, want the known profiles listed", err)
+		}
+	})
+
+	t.Run("invalid profile", func(t *testing.T) {
+		dir := t.TempDir()
+		data := `{"profiles": {"unit": {"tags": ["a,b"]}}}`
+		if err := os.WriteFile(filepath.Join(dir, ConfigFileName), []byte(data), 0600); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+		if _, err := LoadConfig(dir); err == nil {
+			t.Error("Expected error for a tag containing a comma")
+		}
+	})
+
+	t.Run("yaml and json", func(t *testing.T) {
+		dir := t.TempDir()
+		for _, name := range []string{ConfigFileName, ConfigFileNameYAML} {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0600); err != nil {
+				t.Fatalf("Failed to write config: %v", err)
+			}
+		}
+		if _, err := LoadConfig(dir); err == nil {
+			t.Error("Expected error when both files exist")
+		}
+	})
+}
+
+func TestConfigTemplate(t *testing.T) {
+	for _, name := range []string{ConfigFileNameYAML, ConfigFileName} {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, name), ConfigTemplate(name), 0600); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+		cfg, err := LoadConfig(dir)
+		if err != nil {
+			t.Fatalf("LoadConfig(%s template) failed: %v", name, err)
+		}
+		if timeout, _ := cfg.TestTimeout(); timeout != 10*time.Minute || cfg.Coverage.Sort != CoverageSortName {
+			t.Errorf("%s template = %+v, want the defaults", name, cfg)
+		}
+	}
+}
+
+func TestLoadTimezone(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{"", "Local", false},
+		{"local", "Local", false},
+		{"UTC", "UTC", false},
+		{"utc", "UTC", false},
+		{"Asia/Tokyo", "Asia/Tokyo", false},
+		{"Nowhere/Else", "", true},
+	}
+	for _, tt := range tests {
+		loc, err := LoadTimezone(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("LoadTimezone(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err == nil && loc.String() != tt.want {
+			t.Errorf("LoadTimezone(%q) = %s, want %s", tt.name, loc, tt.want)
+		}
+	}
+}
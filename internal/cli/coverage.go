@@ -0,0 +1,224 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CoverageDelta is the change in a saved file's statement coverage
+// between watch reruns
+type CoverageDelta struct {
+	File      string  // Path relative to the project
+	Before    float64 // Percentage before the change, valid when HasBefore
+	After     float64 // Percentage after the change
+	HasBefore bool    // False the first time the file's coverage is seen
+}
+
+// CoverageTracker remembers the statement coverage of every file seen in
+// watch mode, so each rerun can report how the saved files changed
+type CoverageTracker struct {
+	files map[string]float64 // Percentage by profile file name (import path + base name)
+}
+
+// NewCoverageTracker creates an empty tracker
+func NewCoverageTracker() *CoverageTracker {
+	return &CoverageTracker{files: make(map[string]float64)}
+}
+
+// update merges the coverage of a run and returns the deltas for the
+// profile names in changed. Files the run did not cover keep their last
+// known value, since watch reruns often test only some packages.
+func (t *CoverageTracker) update(coverage map[string]float64, changed map[string]string) []CoverageDelta {
+	var deltas []CoverageDelta
+	for name, display := range changed {
+		after, ok := coverage[name]
+		if !ok {
+			continue
+		}
+		before, hasBefore := t.files[name]
+		deltas = append(deltas, CoverageDelta{File: display, Before: before, After: after, HasBefore: hasBefore})
+	}
+	for name, pct := range coverage {
+		t.files[name] = pct
+	}
+	sort.Slice(deltas, func(i, j int) bool { return deltas[i].File < deltas[j].File })
+	return deltas
+}
+
+// coverBlock is one basic block of a coverage profile
+type coverBlock struct {
+	startLine int
+	endLine   int
+	stmts     int
+	covered   bool
+}
+
+// ParseCoverProfile reads a go test -coverprofile file and returns the
+// percentage of statements covered in each file. Blocks repeated across
+// packages are counted once, covered if any package covered them.
+func ParseCoverProfile(r io.Reader) (map[string]float64, error) {
+	blocks, err := parseCoverBlocks(r)
+	if err != nil {
+		return nil, err
+	}
+	result, _ := coverageByFile(blocks)
+	return result, nil
+}
+
+// coverageByFile returns the percentage of statements covered in each file
+// of a profile and in all of them together
+func coverageByFile(blocks map[string]map[string]*coverBlock) (map[string]float64, float64) {
+	result := make(map[string]float64, len(blocks))
+	var allTotal, allCovered int
+	for file, fileBlocks := range blocks {
+		var total, covered int
+		for _, b := range fileBlocks {
+			total += b.stmts
+			if b.covered {
+				covered += b.stmts
+			}
+		}
+		if total > 0 {
+			result[file] = 100 * float64(covered) / float64(total)
+		}
+		allTotal += total
+		allCovered += covered
+	}
+	if allTotal == 0 {
+		return result, 0
+	}
+	return result, 100 * float64(allCovered) / float64(allTotal)
+}
+
+// parseCoverBlocks reads the blocks of a coverage profile by file name and
+// position, merging blocks repeated across packages
+func parseCoverBlocks(r io.Reader) (map[string]map[string]*coverBlock, error) {
+	blocks := make(map[string]map[string]*coverBlock)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "mode:") {
+			continue
+		}
+		// name.go:startLine.startCol,endLine.endCol numStmts count
+		colon := strings.LastIndex(line, ":")
+		if colon < 0 {
+			return nil, fmt.Errorf("invalid coverage profile line %q", line)
+		}
+		fields := strings.Fields(line[colon+1:])
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid coverage profile line %q", line)
+		}
+		startLine, endLine, ok := parseBlockLines(fields[0])
+		if !ok {
+			return nil, fmt.Errorf("invalid coverage profile line %q", line)
+		}
+		stmts, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid coverage profile line %q", line)
+		}
+		count, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid coverage profile line %q", line)
+		}
+
+		file := line[:colon]
+		if blocks[file] == nil {
+			blocks[file] = make(map[string]*coverBlock)
+		}
+		b, ok := blocks[file][fields[0]]
+		if !ok {
+			b = &coverBlock{startLine: startLine, endLine: endLine, stmts: stmts}
+			blocks[file][fields[0]] = b
+		}
+		b.covered = b.covered || count > 0
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read coverage profile: %w", err)
+	}
+	return blocks, nil
+}
+
+// parseBlockLines reads the first and last lines of a block position such
+// as 12.5,14.2
+func parseBlockLines(pos string) (int, int, bool) {
+	start, end, ok := strings.Cut(pos, ",")
+	if !ok {
+		return 0, 0, false
+	}
+	startLine, _, _ := strings.Cut(start, ".")
+	endLine, _, _ := strings.Cut(end, ".")
+	s, err1 := strconv.Atoi(startLine)
+	e, err2 := strconv.Atoi(endLine)
+	if err1 != nil || err2 != nil {
+		return 0, 0, false
+	}
+	return s, e, true
+}
+
+// readCoverProfile parses and removes the profile written by a run,
+// returning the coverage of each file and the total. A missing profile, as
+// when the build failed, yields no coverage.
+func readCoverProfile(path string) (map[string]float64, float64) {
+	defer os.Remove(path)
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0
+	}
+	defer f.Close()
+	blocks, err := parseCoverBlocks(f)
+	if err != nil {
+		return nil, 0
+	}
+	return coverageByFile(blocks)
+}
+
+// coverageNames maps the saved Go files among changed to their names in
+// coverage profiles, with paths relative to the project for display. A
+// saved foo_test.go stands for foo.go, whose coverage it affects.
+func (r *Runner) coverageNames(changed []string, packages []string) map[string]string {
+	names := make(map[string]string)
+	if len(changed) == 0 {
+		return names
+	}
+	pkgs, err := r.pkgCache.Get(packages)
+	if err != nil {
+		return names
+	}
+	importPaths := make(map[string]string, len(pkgs))
+	for _, pkg := range pkgs {
+		importPaths[filepath.Clean(pkg.Dir)] = pkg.ImportPath
+	}
+	for _, file := range changed {
+		if !strings.HasSuffix(file, ".go") {
+			continue
+		}
+		abs := file
+		if !filepath.IsAbs(abs) {
+			abs = filepath.Join(r.workDir, abs)
+		}
+		if strings.HasSuffix(abs, "_test.go") {
+			abs = strings.TrimSuffix(abs, "_test.go") + ".go"
+			if _, err := os.Stat(abs); err != nil {
+				continue
+			}
+		}
+		importPath, ok := importPaths[filepath.Dir(abs)]
+		if !ok {
+			continue
+		}
+		display := abs
+		if rel, err := filepath.Rel(r.workDir, abs); err == nil {
+			display = rel
+		}
+		names[importPath+"/"+filepath.Base(abs)] = display
+	}
+	return names
+}
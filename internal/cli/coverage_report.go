@@ -0,0 +1,244 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Orders a CoverageReport can be sorted in
+const (
+	CoverageSortName     = "name"     // By import path and file name
+	CoverageSortCoverage = "coverage" // Least covered first
+)
+
+// CoverageReport is the statement coverage of a set of packages and their
+// files
+type CoverageReport struct {
+	Packages []*PackageCoverage
+}
+
+// PackageCoverage is the coverage of one package
+type PackageCoverage struct {
+	ImportPath string
+	Statements int
+	Covered    int
+	Files      []*FileCoverage
+}
+
+// Percent returns the percentage of the package's statements covered
+func (p *PackageCoverage) Percent() float64 {
+	return coveragePercent(p.Covered, p.Statements)
+}
+
+// FileCoverage is the coverage of one file and the lines left uncovered
+type FileCoverage struct {
+	Name       string // Name in the profile: import path and base name
+	Statements int
+	Covered    int
+	Uncovered  []LineRange // Lines of uncovered blocks, in order
+	CoveredAt  []LineRange // Lines of covered blocks, in order
+}
+
+// Percent returns the percentage of the file's statements covered
+func (f *FileCoverage) Percent() float64 {
+	return coveragePercent(f.Covered, f.Statements)
+}
+
+// LineRange is an inclusive range of source lines
+type LineRange struct {
+	Start int
+	End   int
+}
+
+// String formats the range as 12-14, or 12 for a single line
+func (l LineRange) String() string {
+	if l.Start == l.End {
+		return fmt.Sprintf("%d", l.Start)
+	}
+	return fmt.Sprintf("%d-%d", l.Start, l.End)
+}
+
+// Contains reports whether line falls within the range
+func (l LineRange) Contains(line int) bool {
+	return line >= l.Start && line <= l.End
+}
+
+// coveragePercent returns covered as a percentage of total, 0 when empty
+func coveragePercent(covered, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(covered) / float64(total)
+}
+
+// ReadCoverageReport reads a go test -coverprofile file into a report
+// grouped by package, sorted by name
+func ReadCoverageReport(r io.Reader) (*CoverageReport, error) {
+	blocks, err := parseCoverBlocks(r)
+	if err != nil {
+		return nil, err
+	}
+
+	byPackage := make(map[string]*PackageCoverage)
+	for name, fileBlocks := range blocks {
+		file := &FileCoverage{Name: name}
+		var covered, uncovered []LineRange
+		for _, b := range fileBlocks {
+			file.Statements += b.stmts
+			if b.covered {
+				file.Covered += b.stmts
+				covered = append(covered, LineRange{Start: b.startLine, End: b.endLine})
+			} else if b.stmts > 0 {
+				uncovered = append(uncovered, LineRange{Start: b.startLine, End: b.endLine})
+			}
+		}
+		file.Uncovered = mergeLineRanges(uncovered)
+		file.CoveredAt = mergeLineRanges(covered)
+
+		importPath := path.Dir(name)
+		pkg, ok := byPackage[importPath]
+		if !ok {
+			pkg = &PackageCoverage{ImportPath: importPath}
+			byPackage[importPath] = pkg
+		}
+		pkg.Statements += file.Statements
+		pkg.Covered += file.Covered
+		pkg.Files = append(pkg.Files, file)
+	}
+
+	report := &CoverageReport{}
+	for _, pkg := range byPackage {
+		report.Packages = append(report.Packages, pkg)
+	}
+	if err := report.Sort(CoverageSortName); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// mergeLineRanges sorts ranges and joins those that overlap or touch
+func mergeLineRanges(ranges []LineRange) []LineRange {
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Start < ranges[j].Start })
+	var merged []LineRange
+	for _, lr := range ranges {
+		if n := len(merged); n > 0 && lr.Start <= merged[n-1].End+1 {
+			if lr.End > merged[n-1].End {
+				merged[n-1].End = lr.End
+			}
+			continue
+		}
+		merged = append(merged, lr)
+	}
+	return merged
+}
+
+// Sort orders the packages, and the files within each, by name or from
+// least to most covered
+func (c *CoverageReport) Sort(by string) error {
+	switch by {
+	case CoverageSortName:
+		sort.Slice(c.Packages, func(i, j int) bool { return c.Packages[i].ImportPath < c.Packages[j].ImportPath })
+		for _, pkg := range c.Packages {
+			sort.Slice(pkg.Files, func(i, j int) bool { return pkg.Files[i].Name < pkg.Files[j].Name })
+		}
+	case CoverageSortCoverage:
+		sort.SliceStable(c.Packages, func(i, j int) bool { return c.Packages[i].Percent() < c.Packages[j].Percent() })
+		for _, pkg := range c.Packages {
+			sort.SliceStable(pkg.Files, func(i, j int) bool { return pkg.Files[i].Percent() < pkg.Files[j].Percent() })
+		}
+	default:
+		return fmt.Errorf("unknown coverage sort %q, want %q or %q", by, CoverageSortName, CoverageSortCoverage)
+	}
+	return nil
+}
+
+// Total returns the covered and total statements across all packages
+func (c *CoverageReport) Total() (covered, statements int) {
+	for _, pkg := range c.Packages {
+		covered += pkg.Covered
+		statements += pkg.Statements
+	}
+	return covered, statements
+}
+
+// FindFile returns the file whose profile name ends with name, such as
+// calc.go or pkg/calc.go
+func (c *CoverageReport) FindFile(name string) (*FileCoverage, error) {
+	name = filepath.ToSlash(name)
+	var matches []*FileCoverage
+	for _, pkg := range c.Packages {
+		for _, file := range pkg.Files {
+			if file.Name == name || strings.HasSuffix(file.Name, "/"+name) {
+				matches = append(matches, file)
+			}
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no coverage for %s", name)
+	case 1:
+		return matches[0], nil
+	}
+	names := make([]string, len(matches))
+	for i, m := range matches {
+		names[i] = m.Name
+	}
+	return nil, fmt.Errorf("%s is ambiguous: %s", name, strings.Join(names, ", "))
+}
+
+// CollectCoverage runs the tests of the packages matching patterns with a
+// coverage profile and reads it into a report. Failing tests still yield
+// coverage; only a run that writes no profile is an error.
+func CollectCoverage(workDir string, patterns []string) (*CoverageReport, error) {
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+	profile, err := os.CreateTemp("", "go-sentinel-cover-*.out")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create coverage profile: %w", err)
+	}
+	profile.Close()
+	defer os.Remove(profile.Name())
+
+	args := append([]string{"test", "-coverprofile=" + profile.Name()}, patterns...)
+	cmd := exec.Command(goBinary, args...)
+	cmd.Dir = workDir
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	runErr := cmd.Run()
+
+	f, err := os.Open(profile.Name())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read coverage profile: %w", err)
+	}
+	defer f.Close()
+	report, err := ReadCoverageReport(f)
+	if err != nil {
+		return nil, err
+	}
+	if len(report.Packages) == 0 && runErr != nil {
+		return nil, fmt.Errorf("failed to collect coverage: %w\n%s", runErr, output.String())
+	}
+	return report, nil
+}
+
+// SourcePath locates a profile file name on disk, through the directory
+// of its package
+func SourcePath(workDir, name string) (string, error) {
+	pkgs, err := expandPackagePatterns(workDir, []string{path.Dir(name)})
+	if err != nil {
+		return "", err
+	}
+	if len(pkgs) != 1 {
+		return "", fmt.Errorf("failed to locate package %s", path.Dir(name))
+	}
+	return filepath.Join(pkgs[0].Dir, path.Base(name)), nil
+}
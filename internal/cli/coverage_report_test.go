@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const reportProfile = `mode: set
+example.com/pkg/calc.go:3.24,3.40 1 1
+example.com/pkg/calc.go:5.24,7.2 2 0
+example.com/pkg/calc.go:8.10,9.3 1 0
+example.com/pkg/calc.go:12.1,14.2 1 0
+example.com/pkg/calc.go:12.1,14.2 1 1
+example.com/pkg/io.go:3.1,4.2 4 1
+example.com/other/util.go:3.1,4.2 1 0
+`
+
+func TestReadCoverageReport(t *testing.T) {
+	report, err := ReadCoverageReport(strings.NewReader(reportProfile))
+	if err != nil {
+		t.Fatalf("ReadCoverageReport failed: %v", err)
+	}
+	if len(report.Packages) != 2 || report.Packages[0].ImportPath != "example.com/other" {
+		t.Fatalf("packages = %+v, want example.com/other then example.com/pkg", report.Packages)
+	}
+	pkg := report.Packages[1]
+	if pkg.Statements != 9 || pkg.Covered != 6 {
+		t.Errorf("pkg coverage = %d of %d statements, want 6 of 9", pkg.Covered, pkg.Statements)
+	}
+
+	calc, err := report.FindFile("calc.go")
+	if err != nil {
+		t.Fatalf("FindFile failed: %v", err)
+	}
+	// Touching uncovered blocks merge; a block covered by any package is covered
+	if len(calc.Uncovered) != 1 || calc.Uncovered[0] != (LineRange{Start: 5, End: 9}) {
+		t.Errorf("calc.go uncovered = %v, want [5-9]", calc.Uncovered)
+	}
+
+	if err := report.Sort(CoverageSortCoverage); err != nil {
+		t.Fatalf("Sort failed: %v", err)
+	}
+	if report.Packages[0].ImportPath != "example.com/other" || pkg.Files[0].Name != "example.com/pkg/calc.go" {
+		t.Errorf("least covered package and file should come first")
+	}
+	if err := report.Sort("size"); err == nil {
+		t.Error("Expected error for an unknown sort order")
+	}
+}
+
+func TestCoverageReport_FindFile(t *testing.T) {
+	report, err := ReadCoverageReport(strings.NewReader(reportProfile + "example.com/other/calc.go:1.1,2.2 1 1\n"))
+	if err != nil {
+		t.Fatalf("ReadCoverageReport failed: %v", err)
+	}
+	if _, err := report.FindFile("calc.go"); err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Errorf("FindFile(calc.go) error = %v, want ambiguous", err)
+	}
+	if file, err := report.FindFile("pkg/calc.go"); err != nil || file.Name != "example.com/pkg/calc.go" {
+		t.Errorf("FindFile(pkg/calc.go) = %v, %v", file, err)
+	}
+	if _, err := report.FindFile("missing.go"); err == nil {
+		t.Error("Expected error for a file without coverage")
+	}
+}
+
+func TestRenderer_RenderFileCoverage(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewRendererWithStyle(&buf, false)
+	file := &FileCoverage{Name: "example.com/pkg/calc.go", Statements: 2, Covered: 1, Uncovered: []LineRange{{Start: 2, End: 2}}}
+	r.RenderFileCoverage(file, []byte("func f() {\n\treturn\n}\n"))
+
+	out := buf.String()
+	if !strings.Contains(out, "2 ✗     return") {
+		t.Errorf("uncovered line not marked:\n%s", out)
+	}
+	if !strings.Contains(out, "1 │ func f() {") {
+		t.Errorf("covered line should be unmarked:\n%s", out)
+	}
+}
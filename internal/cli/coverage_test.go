@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParseCoverProfile(t *testing.T) {
+	profile := `mode: set
+example.com/pkg/calc.go:3.24,3.40 1 1
+example.com/pkg/calc.go:5.24,5.40 1 0
+example.com/pkg/calc.go:7.24,9.2 2 0
+example.com/pkg/calc.go:7.24,9.2 2 1
+example.com/pkg/empty.go:1.1,1.2 0 0
+`
+	coverage, err := ParseCoverProfile(strings.NewReader(profile))
+	if err != nil {
+		t.Fatalf("ParseCoverProfile failed: %v", err)
+	}
+	// A block repeated by another package counts once, covered by either
+	if got := coverage["example.com/pkg/calc.go"]; math.Abs(got-75) > 0.001 {
+		t.Errorf("calc.go coverage = %.1f, want 75.0", got)
+	}
+	if _, ok := coverage["example.com/pkg/empty.go"]; ok {
+		t.Error("Files without statements should have no coverage")
+	}
+
+	if _, err := ParseCoverProfile(strings.NewReader("mode: set\ngarbage\n")); err == nil {
+		t.Error("Expected error for a malformed profile")
+	}
+}
+
+func TestCoverageTracker(t *testing.T) {
+	tracker := NewCoverageTracker()
+	changed := map[string]string{"example.com/pkg/parser.go": "pkg/parser.go"}
+
+	deltas := tracker.update(map[string]float64{"example.com/pkg/parser.go": 78, "example.com/pkg/lexer.go": 90}, changed)
+	if len(deltas) != 1 || deltas[0].HasBefore || deltas[0].After != 78 {
+		t.Errorf("First deltas = %+v, want parser.go at 78%% with no previous value", deltas)
+	}
+
+	// A rerun of another package keeps parser.go's last known value
+	tracker.update(map[string]float64{"example.com/other/a.go": 10}, nil)
+
+	deltas = tracker.update(map[string]float64{"example.com/pkg/parser.go": 83}, changed)
+	if len(deltas) != 1 || !deltas[0].HasBefore || deltas[0].Before != 78 || deltas[0].After != 83 || deltas[0].File != "pkg/parser.go" {
+		t.Errorf("Deltas = %+v, want pkg/parser.go 78%% → 83%%", deltas)
+	}
+}
@@ -0,0 +1,1183 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Daemon keeps the package graph of a module and the compiled test binaries
+// of its packages warm between runs. go-sentinel run attaches to it over a
+// unix socket, and the daemon only rebuilds the binaries of packages whose
+// files, or whose dependencies' files, changed since their last build.
+type Daemon struct {
+	workDir   string
+	binDir    string
+	test2json string // Path of go tool test2json, run directly to skip the go command's startup
+	pkgCache  *PackageCache
+	out       io.Writer // Receives a line per served run, nil for none
+
+	mu       sync.Mutex
+	binaries map[string]*warmBinary   // By import path and build flags
+	building sync.Map                 // Build lock of each binary, by the same key
+	inflight map[string]*coalescedRun // Runs in progress, by runKey
+	detached map[string]*coalescedRun // Runs started in the background, by run ID
+	order    []string                 // Run IDs of detached, oldest first
+
+	// Idle handling: after idleRelease without requests the binaries and
+	// package graph are released, to be rebuilt by the next run, and after
+	// idleStop the daemon stops; 0 disables each
+	idleRelease time.Duration
+	idleStop    time.Duration
+	active      int       // Requests being served
+	lastRequest time.Time // End of the last request, or the start of serving
+	released    bool      // Released since the last request
+}
+
+// coalescedRun is a run in progress that identical requests arriving
+// before it finishes share instead of running the tests again
+type coalescedRun struct {
+	done   chan struct{}
+	mu     sync.Mutex
+	events [][]byte // Events sent so far, replayed to the requests sharing the run
+	code   int
+	err    error
+}
+
+// record keeps events for the requests sharing the run
+func (c *coalescedRun) record(events []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.events = append(c.events, events)
+}
+
+// finished reports whether the run's outcome is recorded
+func (c *coalescedRun) finished() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// maxDetachedRuns bounds the background runs whose results the daemon
+// keeps; the oldest finished ones are dropped first
+const maxDetachedRuns = 100
+
+// warmBinary is a compiled test binary and the fingerprint of the sources
+// it was built from
+type warmBinary struct {
+	path        string
+	fingerprint string
+	noTests     bool   // go test -c wrote nothing, as when build tags exclude every test file
+	buildOutput []byte // Compiler output when the build failed
+}
+
+// daemonRequest is the run a client asks the daemon for. A detached run
+// is started in the background and answered with its run ID at once; a
+// request with Result reports on such a run instead of starting one.
+type daemonRequest struct {
+	Stop        bool          `json:"stop,omitempty"`
+	Status      bool          `json:"status,omitempty"`
+	Detach      bool          `json:"detach,omitempty"`
+	Result      string        `json:"result,omitempty"`
+	Wait        bool          `json:"wait,omitempty"`   // With Result, wait for the run to finish
+	Follow      bool          `json:"follow,omitempty"` // With Result, stream events as they arrive until the run finishes
+	Packages    []string      `json:"packages,omitempty"`
+	Tests       []string      `json:"tests,omitempty"`
+	BuildFlags  []string      `json:"buildFlags,omitempty"`
+	Env         []string      `json:"env,omitempty"`
+	FailFast    bool          `json:"failFast,omitempty"`
+	Shuffle     bool          `json:"shuffle,omitempty"`
+	Seed        int64         `json:"seed,omitempty"`
+	Timeout     time.Duration `json:"timeout,omitempty"`
+	Parallelism int           `json:"parallelism,omitempty"`
+}
+
+// daemonMessage is one line of the daemon's reply: a go test -json event,
+// or, last, the exit code go test would have had, or that a background
+// run is still running
+type daemonMessage struct {
+	Event   json.RawMessage `json:"event,omitempty"`
+	Exit    *int            `json:"exit,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Run     string          `json:"run,omitempty"`
+	Running bool            `json:"running,omitempty"`
+	Status  *DaemonStatus   `json:"status,omitempty"`
+}
+
+// DaemonStatus describes a running daemon, for health checks
+type DaemonStatus struct {
+	WorkDir   string `json:"workDir"`
+	PID       int    `json:"pid"`
+	Requests  int    `json:"requests"`  // Runs and result requests being served
+	Triggered int    `json:"triggered"` // Triggered runs whose results are kept
+	Running   int    `json:"running"`   // Triggered runs in progress
+	Binaries  int    `json:"binaries"`  // Warm test binaries
+	Released  bool   `json:"released"`  // Binaries and package graph released while idle
+}
+
+// ErrNoDaemon is returned when no daemon serves the module
+var ErrNoDaemon = errors.New("no daemon is running")
+
+// ErrRunInProgress is returned for the result of a background run that
+// has not finished
+var ErrRunInProgress = errors.New("run is still in progress")
+
+// NewDaemon creates a daemon for the module in workDir, writing a line per
+// served run to out
+func NewDaemon(workDir string, out io.Writer) (*Daemon, error) {
+	binDir, err := os.MkdirTemp("", "go-sentinel-daemon-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create binary directory: %w", err)
+	}
+	test2json, err := findTest2JSON(binDir)
+	if err != nil {
+		os.RemoveAll(binDir)
+		return nil, err
+	}
+	return &Daemon{
+		workDir:   workDir,
+		binDir:    binDir,
+		test2json: test2json,
+		pkgCache:  NewPackageCache(workDir, defaultPackageCacheDir()),
+		out:       out,
+		binaries:  make(map[string]*warmBinary),
+		inflight:  make(map[string]*coalescedRun),
+		detached:  make(map[string]*coalescedRun),
+	}, nil
+}
+
+// findTest2JSON returns the test2json binary of the go toolchain, building
+// it into dir when the toolchain builds its tools on demand
+func findTest2JSON(dir string) (string, error) {
+	exe := ""
+	if runtime.GOOS == "windows" {
+		exe = ".exe"
+	}
+	toolDir, err := exec.Command(goBinary, "env", "GOTOOLDIR").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to find go tools: %w", err)
+	}
+	path := filepath.Join(strings.TrimSpace(string(toolDir)), "test2json"+exe)
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+	path = filepath.Join(dir, "test2json"+exe)
+	if output, err := exec.Command(goBinary, "build", "-o", path, "cmd/test2json").CombinedOutput(); err != nil {
+		return "", fmt.Errorf("failed to build test2json: %w: %s", err, output)
+	}
+	return path, nil
+}
+
+// DaemonSocket returns the socket the daemon of the module in workDir
+// listens on
+func DaemonSocket(workDir string) string {
+	sum := sha256.Sum256([]byte(workDir))
+	name := "daemon-" + hex.EncodeToString(sum[:8]) + ".sock"
+	if dir := defaultPackageCacheDir(); dir != "" {
+		return filepath.Join(dir, name)
+	}
+	return filepath.Join(os.TempDir(), "go-sentinel-"+name)
+}
+
+// ListenDaemon listens on socket, replacing a socket left behind by a
+// daemon that is no longer running. Only the current user may connect.
+func ListenDaemon(socket string) (net.Listener, error) {
+	if conn, err := net.DialTimeout("unix", socket, time.Second); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("a daemon is already listening on %s", socket)
+	}
+	os.Remove(socket)
+	if err := os.MkdirAll(filepath.Dir(socket), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create socket directory: %w", err)
+	}
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", socket, err)
+	}
+	// Only the daemon's user may start runs or read their results
+	if err := os.Chmod(socket, 0600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to restrict %s: %w", socket, err)
+	}
+	return listener, nil
+}
+
+// Warm lists the module's packages and builds the test binaries of those
+// matching patterns, so the first run attached to the daemon is fast too
+func (d *Daemon) Warm(patterns []string) error {
+	pkgs, err := d.pkgCache.Get(patterns)
+	if err != nil {
+		return err
+	}
+	graph, err := d.moduleGraph()
+	if err != nil {
+		return err
+	}
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	for _, pkg := range pkgs {
+		if !pkg.HasTests() {
+			continue
+		}
+		wg.Add(1)
+		go func(pkg *PackageInfo) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if _, _, output, err := d.binary(pkg, nil, os.Environ(), graph); err != nil {
+				log.Printf("Error building %s: %v\n%s", pkg.ImportPath, err, output)
+			}
+		}(pkg)
+	}
+	wg.Wait()
+	return nil
+}
+
+// SetIdle makes the daemon release its test binaries and package graph
+// after release without requests, and stop after stop; 0 disables each.
+// Released binaries are rebuilt lazily by the runs needing them.
+func (d *Daemon) SetIdle(release, stop time.Duration) {
+	d.idleRelease, d.idleStop = release, stop
+}
+
+// Serve answers runs on listener until ctx is done, a client asks the
+// daemon to stop, or it stays idle past its idle stop, then removes its
+// binaries
+func (d *Daemon) Serve(ctx context.Context, listener net.Listener) error {
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+	defer os.RemoveAll(d.binDir)
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+	d.mu.Lock()
+	d.lastRequest = time.Now()
+	d.mu.Unlock()
+	if d.idleRelease > 0 || d.idleStop > 0 {
+		go d.watchIdle(ctx, stop)
+	}
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to accept connection: %w", err)
+		}
+		go func() {
+			defer conn.Close()
+			if d.handle(conn) {
+				stop()
+			}
+		}()
+	}
+}
+
+// watchIdle releases the daemon's resources or stops it once it has been
+// idle for its idle release or idle stop
+func (d *Daemon) watchIdle(ctx context.Context, stop func()) {
+	interval := d.idleRelease
+	if interval == 0 || (d.idleStop > 0 && d.idleStop < interval) {
+		interval = d.idleStop
+	}
+	ticker := time.NewTicker(max(interval/10, 10*time.Millisecond))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			idle := d.idleFor(now)
+			if d.idleStop > 0 && idle >= d.idleStop {
+				d.logf("idle for %s, stopping", FormatDurationAdaptive(idle))
+				stop()
+				return
+			}
+			if d.idleRelease > 0 && idle >= d.idleRelease {
+				d.release(idle)
+			}
+		}
+	}
+}
+
+// idleFor returns how long no request has been served, 0 while one is
+func (d *Daemon) idleFor(now time.Time) time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.active > 0 {
+		return 0
+	}
+	return now.Sub(d.lastRequest)
+}
+
+// release removes the warm test binaries and drops the package graph from
+// memory, unless a request arrived since the daemon became idle. Runs
+// after it rebuild the binaries they need.
+func (d *Daemon) release(idle time.Duration) {
+	d.mu.Lock()
+	if d.active > 0 || d.released {
+		d.mu.Unlock()
+		return
+	}
+	binaries := d.binaries
+	d.binaries = make(map[string]*warmBinary)
+	d.released = true
+	d.mu.Unlock()
+
+	for _, bin := range binaries {
+		if bin.path != "" {
+			os.Remove(bin.path)
+		}
+	}
+	d.pkgCache.release()
+	debug.FreeOSMemory()
+	noun := "binaries"
+	if len(binaries) == 1 {
+		noun = "binary"
+	}
+	d.logf("idle for %s, released %d test %s", FormatDurationAdaptive(idle), len(binaries), noun)
+}
+
+// logf writes a timestamped line to the daemon's output, if any
+func (d *Daemon) logf(format string, args ...any) {
+	if d.out != nil {
+		fmt.Fprintf(d.out, "%s  %s\n", time.Now().Format("15:04:05"), fmt.Sprintf(format, args...))
+	}
+}
+
+// handle serves one request, reporting whether it asked the daemon to stop
+func (d *Daemon) handle(conn net.Conn) bool {
+	var req daemonRequest
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&req); err != nil {
+		log.Printf("Error reading daemon request: %v", err)
+		return false
+	}
+	enc := json.NewEncoder(conn)
+
+	// Status requests leave the daemon idle, so health checks do not keep
+	// its resources warm
+	if req.Status {
+		code := 0
+		enc.Encode(daemonMessage{Exit: &code, Status: d.status()})
+		return false
+	}
+	d.mu.Lock()
+	d.active++
+	d.released = false
+	d.mu.Unlock()
+	defer func() {
+		d.mu.Lock()
+		d.active--
+		d.lastRequest = time.Now()
+		d.mu.Unlock()
+	}()
+
+	switch {
+	case req.Stop:
+		code := 0
+		enc.Encode(daemonMessage{Exit: &code})
+		return true
+	case req.Result != "":
+		d.sendResult(enc, req.Result, req.Wait, req.Follow)
+		return false
+	case req.Detach:
+		code := 0
+		reply := daemonMessage{Exit: &code}
+		id, err := d.detach(req)
+		if err != nil {
+			code, reply.Error = 2, err.Error()
+		}
+		reply.Run = id
+		enc.Encode(reply)
+		return false
+	}
+
+	var mu sync.Mutex
+	send := func(events []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+		encodeEvents(enc, events)
+	}
+	code, err := d.serve(req, send)
+	reply := daemonMessage{Exit: &code}
+	if err != nil {
+		reply.Error = err.Error()
+	}
+	enc.Encode(reply)
+	return false
+}
+
+// status returns the daemon's current status
+func (d *Daemon) status() *DaemonStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	status := &DaemonStatus{
+		WorkDir:   d.workDir,
+		PID:       os.Getpid(),
+		Requests:  d.active,
+		Triggered: len(d.detached),
+		Binaries:  len(d.binaries),
+		Released:  d.released,
+	}
+	for _, run := range d.detached {
+		if !run.finished() {
+			status.Running++
+		}
+	}
+	return status
+}
+
+// encodeEvents sends each go test -json event of events as a reply line
+func encodeEvents(enc *json.Encoder, events []byte) error {
+	for _, line := range bytes.Split(events, []byte("\n")) {
+		if json.Valid(line) {
+			if err := enc.Encode(daemonMessage{Event: line}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// serve runs a request's tests, or shares an identical run in progress,
+// sending their events as they finish, and logs the run
+func (d *Daemon) serve(req daemonRequest, send func([]byte)) (int, error) {
+	start := time.Now()
+	patterns := req.Packages
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+	var code, built int
+	var err error
+	shared, leader := d.coalesce(d.runKey(req))
+	switch {
+	case shared == nil:
+		code, built, err = d.run(req, send)
+	case leader:
+		code, built, err = d.run(req, func(events []byte) {
+			shared.record(events)
+			send(events)
+		})
+		d.finish(shared, code, err)
+	default:
+		<-shared.done
+		for _, events := range shared.events {
+			send(events)
+		}
+		code, err = shared.code, shared.err
+	}
+
+	if d.out != nil {
+		how := fmt.Sprintf("%d rebuilt", built)
+		if shared != nil && !leader {
+			how = "shared with an identical run"
+		}
+		fmt.Fprintf(d.out, "%s  %s, %s, exit %d in %s\n", time.Now().Format("15:04:05"),
+			strings.Join(patterns, " "), how, code, FormatDurationAdaptive(time.Since(start)))
+	}
+	return code, err
+}
+
+// detach starts a request's run in the background and returns its run
+// ID: the request's SENTINEL_RUN_ID, or a new one added to its
+// environment. The run's events and exit code are kept under the ID for
+// sendResult. The daemon is not idle while the run is in progress.
+func (d *Daemon) detach(req daemonRequest) (string, error) {
+	id := ""
+	for _, kv := range req.Env {
+		if value, ok := strings.CutPrefix(kv, EnvRunID+"="); ok {
+			id = value
+		}
+	}
+	if id == "" {
+		id = newRunID()
+		req.Env = append(daemonEnv(req), EnvRunID+"="+id)
+	}
+
+	run := &coalescedRun{done: make(chan struct{})}
+	d.mu.Lock()
+	if _, ok := d.detached[id]; ok {
+		d.mu.Unlock()
+		return "", fmt.Errorf("run %s already exists", id)
+	}
+	d.detached[id] = run
+	d.order = append(d.order, id)
+	d.evictDetached()
+	d.active++
+	d.released = false
+	d.mu.Unlock()
+
+	go func() {
+		code, err := d.serve(req, run.record)
+		run.code, run.err = code, err
+		close(run.done)
+		d.mu.Lock()
+		d.active--
+		d.lastRequest = time.Now()
+		d.mu.Unlock()
+	}()
+	return id, nil
+}
+
+// evictDetached drops the oldest finished background runs past
+// maxDetachedRuns. d.mu must be held.
+func (d *Daemon) evictDetached() {
+	excess := len(d.order) - maxDetachedRuns
+	kept := d.order[:0]
+	for _, id := range d.order {
+		if excess > 0 && d.detached[id].finished() {
+			delete(d.detached, id)
+			excess--
+			continue
+		}
+		kept = append(kept, id)
+	}
+	d.order = kept
+}
+
+// sendResult replies with the events of a background run so far, then its
+// exit code when it finished or that it is still running. With wait, it
+// waits for the run to finish first; with follow, it also sends the
+// events recorded while waiting, as they arrive.
+func (d *Daemon) sendResult(enc *json.Encoder, id string, wait, follow bool) {
+	d.mu.Lock()
+	run, ok := d.detached[id]
+	d.mu.Unlock()
+	if !ok {
+		code := 2
+		enc.Encode(daemonMessage{Run: id, Exit: &code, Error: fmt.Sprintf("no run %s", id)})
+		return
+	}
+	if wait && !follow {
+		<-run.done
+	}
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	sent, finished := 0, false
+	for {
+		// Checked before the events are copied, so a finished run's are complete
+		finished = run.finished()
+		run.mu.Lock()
+		events := append([][]byte{}, run.events[sent:]...)
+		run.mu.Unlock()
+		for _, e := range events {
+			if err := encodeEvents(enc, e); err != nil {
+				return
+			}
+		}
+		sent += len(events)
+		if finished || !follow {
+			break
+		}
+		select {
+		case <-run.done:
+		case <-ticker.C:
+		}
+	}
+
+	reply := daemonMessage{Run: id, Running: !finished}
+	if finished {
+		code := run.code
+		reply.Exit = &code
+		if run.err != nil {
+			reply.Error = run.err.Error()
+		}
+	}
+	enc.Encode(reply)
+}
+
+// runKey identifies the runs a request may share: the same options and
+// environment, apart from the run ID, over the same sources. It returns ""
+// when the sources cannot be fingerprinted.
+func (d *Daemon) runKey(req daemonRequest) string {
+	patterns := req.Packages
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+	pkgs, err := d.pkgCache.Get(patterns)
+	if err != nil {
+		return ""
+	}
+	graph, err := d.moduleGraph()
+	if err != nil {
+		return ""
+	}
+
+	var env []string
+	for _, kv := range req.Env {
+		if !strings.HasPrefix(kv, EnvRunID+"=") {
+			env = append(env, kv)
+		}
+	}
+	req.Env = env
+	data, err := json.Marshal(req)
+	if err != nil {
+		return ""
+	}
+	h := sha256.New()
+	h.Write(data)
+	buildFlags, _ := splitTestBinaryFlags(req.BuildFlags)
+	for _, pkg := range pkgs {
+		fmt.Fprintf(h, "\x00%s", d.fingerprint(pkg, buildFlags, daemonEnv(req), graph))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// coalesce returns the run in progress for key, and whether the caller
+// started it and must run the tests. Requests without a key share nothing.
+func (d *Daemon) coalesce(key string) (*coalescedRun, bool) {
+	if key == "" {
+		return nil, false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if run, ok := d.inflight[key]; ok {
+		return run, false
+	}
+	run := &coalescedRun{done: make(chan struct{})}
+	d.inflight[key] = run
+	return run, true
+}
+
+// finish records the outcome of a shared run and releases the requests
+// waiting for it; later requests start a new run
+func (d *Daemon) finish(run *coalescedRun, code int, err error) {
+	d.mu.Lock()
+	for key, r := range d.inflight {
+		if r == run {
+			delete(d.inflight, key)
+		}
+	}
+	d.mu.Unlock()
+	run.code, run.err = code, err
+	close(run.done)
+}
+
+// run runs the test binaries of the requested packages, building those
+// that are stale, and returns the exit code go test would have had and how
+// many binaries were rebuilt
+func (d *Daemon) run(req daemonRequest, send func([]byte)) (int, int, error) {
+	patterns := req.Packages
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+	pkgs, err := d.pkgCache.Get(patterns)
+	if err != nil {
+		return 2, 0, err
+	}
+	graph, err := d.moduleGraph()
+	if err != nil {
+		return 2, 0, err
+	}
+	buildFlags, testFlags := splitTestBinaryFlags(req.BuildFlags)
+
+	parallelism := req.Parallelism
+	if parallelism <= 0 {
+		parallelism = runtime.GOMAXPROCS(0)
+	}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	sem := make(chan struct{}, parallelism)
+	code, built := 0, 0
+	for _, pkg := range pkgs {
+		if !pkg.HasTests() {
+			send(noTestFiles(pkg.ImportPath))
+			continue
+		}
+		wg.Add(1)
+		go func(pkg *PackageInfo) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			rebuilt, exit := d.runPackage(pkg, req, buildFlags, testFlags, graph, send)
+			mu.Lock()
+			defer mu.Unlock()
+			if rebuilt {
+				built++
+			}
+			if exit > code {
+				code = exit
+			}
+		}(pkg)
+	}
+	wg.Wait()
+	return code, built, nil
+}
+
+// daemonEnv returns the environment a request's tests are built and run
+// with: its own, or the daemon's when it sends none
+func daemonEnv(req daemonRequest) []string {
+	if len(req.Env) == 0 {
+		return os.Environ()
+	}
+	return req.Env
+}
+
+// runPackage runs the tests of one package through test2json, as go test
+// -json would, sending their events as the tests produce them, and returns
+// whether the binary was rebuilt and the exit code
+func (d *Daemon) runPackage(pkg *PackageInfo, req daemonRequest, buildFlags, testFlags []string, graph *DependencyGraph, send func([]byte)) (bool, int) {
+	env := daemonEnv(req)
+	bin, rebuilt, output, err := d.binary(pkg, buildFlags, env, graph)
+	if err != nil {
+		send(buildFailure(pkg.ImportPath, string(output)))
+		return rebuilt, 1
+	}
+	if bin.noTests {
+		send(noTestFiles(pkg.ImportPath))
+		return rebuilt, 0
+	}
+
+	args := []string{"-p", pkg.ImportPath, "-t", bin.path, "-test.v=test2json"}
+	if req.FailFast {
+		args = append(args, "-test.failfast")
+	}
+	if req.Timeout > 0 {
+		args = append(args, "-test.timeout", req.Timeout.String())
+	}
+	if len(req.Tests) > 0 {
+		args = append(args, "-test.run", strings.Join(req.Tests, "|"))
+	}
+	if req.Shuffle {
+		args = append(args, "-test.shuffle", strconv.FormatInt(req.Seed, 10))
+	}
+	args = append(args, testFlags...)
+
+	// go test runs test binaries in their package directory
+	cmd := exec.Command(d.test2json, args...)
+	cmd.Dir = pkg.Dir
+	cmd.Env = env
+	stdout := &eventStream{send: send}
+	var stderr bytes.Buffer
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	events := withPackageSummary(pkg.ImportPath, stdout.pending)
+	if stderr.Len() > 0 {
+		events = append(events, syntheticOutput(pkg.ImportPath, stderr.String())...)
+	}
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		send(events)
+		return rebuilt, exitErr.ExitCode()
+	case err != nil:
+		send(syntheticFailure(pkg.ImportPath, err.Error()))
+		return rebuilt, 1
+	}
+	send(events)
+	return rebuilt, 0
+}
+
+// eventStream sends the events test2json writes as they arrive, so runs
+// can be followed live. The last complete event is held back with any
+// partial one, since the package summary goes before it.
+type eventStream struct {
+	send    func([]byte)
+	pending []byte
+}
+
+func (s *eventStream) Write(p []byte) (int, error) {
+	s.pending = append(s.pending, p...)
+	end := bytes.LastIndexByte(s.pending, '\n')
+	if end < 0 {
+		return len(p), nil
+	}
+	if i := bytes.LastIndexByte(s.pending[:end], '\n'); i >= 0 {
+		s.send(append([]byte{}, s.pending[:i+1]...))
+		s.pending = append(s.pending[:0], s.pending[i+1:]...)
+	}
+	return len(p), nil
+}
+
+// binary returns the test binary of pkg for the build flags and
+// environment, building it when its sources changed since it was last
+// built. On build failure the compiler output is returned with the error.
+func (d *Daemon) binary(pkg *PackageInfo, buildFlags, env []string, graph *DependencyGraph) (*warmBinary, bool, []byte, error) {
+	key := pkg.ImportPath + "\x00" + strings.Join(buildFlags, "\x00") + "\x00" + strings.Join(buildEnv(env), "\x00")
+	fingerprint := d.fingerprint(pkg, buildFlags, env, graph)
+
+	// Concurrent runs wait for one build of the same binary
+	lock, _ := d.building.LoadOrStore(key, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	d.mu.Lock()
+	bin, ok := d.binaries[key]
+	d.mu.Unlock()
+	if ok && bin.fingerprint == fingerprint {
+		if bin.buildOutput != nil {
+			return nil, false, bin.buildOutput, errors.New("build failed")
+		}
+		return bin, false, nil, nil
+	}
+
+	sum := sha256.Sum256([]byte(key))
+	path := filepath.Join(d.binDir, hex.EncodeToString(sum[:8])+".test")
+	if runtime.GOOS == "windows" {
+		path += ".exe"
+	}
+	os.Remove(path)
+	args := append([]string{"test", "-c", "-o", path}, buildFlags...)
+	build := exec.Command(goBinary, append(args, pkg.ImportPath)...)
+	build.Dir = d.workDir
+	build.Env = env
+	output, err := build.CombinedOutput()
+
+	bin = &warmBinary{path: path, fingerprint: fingerprint}
+	if err != nil {
+		// Remembered so unchanged sources are not rebuilt on every run
+		bin.buildOutput = output
+	} else if _, statErr := os.Stat(path); statErr != nil {
+		bin.noTests = true
+	}
+	d.mu.Lock()
+	d.binaries[key] = bin
+	d.mu.Unlock()
+	if err != nil {
+		return nil, true, output, err
+	}
+	return bin, true, nil, nil
+}
+
+// moduleGraph returns the graph of the module's packages, whose changes
+// make binaries stale; those outside the module only change with go.mod
+// and go.sum
+func (d *Daemon) moduleGraph() (*DependencyGraph, error) {
+	pkgs, err := d.pkgCache.Get([]string{"./..."})
+	if err != nil {
+		return nil, err
+	}
+	return NewDependencyGraph(pkgs), nil
+}
+
+// buildEnvVars globs the variables that change what go test -c builds
+var buildEnvVars = []string{"GO*", "CGO_*", "CC", "CXX"}
+
+// buildEnv returns the entries of env that change the build, sorted, with
+// the machine-specific paths of envSnapshotSkip left out
+func buildEnv(env []string) []string {
+	var vars []string
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		if matchesEnvGlob(buildEnvVars, name) && !containsString(envSnapshotSkip, name) {
+			vars = append(vars, kv)
+		}
+	}
+	sort.Strings(vars)
+	return vars
+}
+
+// fingerprint identifies the sources a test binary is built from: the
+// module's go.mod and go.sum, the build flags and environment, and the
+// size and modification time of the files of the package and of the
+// module packages its code and tests depend on, through test helpers too
+func (d *Daemon) fingerprint(pkg *PackageInfo, buildFlags, env []string, graph *DependencyGraph) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00", d.pkgCache.modHash(), strings.Join(buildFlags, "\x00"), strings.Join(buildEnv(env), "\x00"))
+	dirs := []string{pkg.Dir}
+	deps := graph.TestDependencies(pkg.ImportPath)
+	deps = append(append(append(deps, pkg.Deps...), pkg.TestImports...), pkg.XTestImports...)
+	for _, dep := range deps {
+		if dir, ok := graph.Dir(dep); ok {
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+	for i, dir := range dirs {
+		if i > 0 && dir == dirs[i-1] {
+			continue
+		}
+		files, _ := filepath.Glob(filepath.Join(dir, "*"))
+		for _, file := range files {
+			info, err := os.Stat(file)
+			if err != nil || info.IsDir() {
+				continue
+			}
+			fmt.Fprintf(h, "%s\x00%d\x00%d\x00", file, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// testBinaryFlags are the go test flags read by the test binary rather
+// than the build, passed to warm binaries as -test.<name>
+var testBinaryFlags = []string{
+	"bench", "benchmem", "benchtime", "count", "cpu", "fullpath", "list", "parallel", "short", "skip",
+}
+
+// splitTestBinaryFlags separates go test flags into those that change the
+// build, such as -race or -tags, and those the test binary reads
+func splitTestBinaryFlags(flags []string) (build, test []string) {
+	for i := 0; i < len(flags); i++ {
+		name := strings.TrimLeft(flags[i], "-")
+		name, value, hasValue := strings.Cut(name, "=")
+		if !containsString(testBinaryFlags, name) {
+			build = append(build, flags[i])
+			if !hasValue && takesValue(flags[i]) && i+1 < len(flags) {
+				i++
+				build = append(build, flags[i])
+			}
+			continue
+		}
+		flag := "-test." + name
+		switch {
+		case hasValue:
+			flag += "=" + value
+		case takesValue(flags[i]) && i+1 < len(flags):
+			i++
+			flag += "=" + flags[i]
+		}
+		test = append(test, flag)
+	}
+	return build, test
+}
+
+// withPackageSummary adds the "ok" or "FAIL" line go test prints after a
+// package's tests to the events of a test binary run through test2json,
+// which only the go command writes
+func withPackageSummary(pkg string, events []byte) []byte {
+	trimmed := bytes.TrimRight(events, "\n")
+	i := bytes.LastIndexByte(trimmed, '\n') + 1
+	var last GoTestEvent
+	if err := json.Unmarshal(trimmed[i:], &last); err != nil || last.Test != "" {
+		return events
+	}
+	var line string
+	switch last.Action {
+	case "pass":
+		line = fmt.Sprintf("ok  \t%s\t%.3fs\n", pkg, last.Elapsed)
+	case "fail":
+		line = fmt.Sprintf("FAIL\t%s\t%.3fs\n", pkg, last.Elapsed)
+	default:
+		return events
+	}
+	summary := syntheticEvent(GoTestEvent{Action: "output", Package: pkg, Output: line})
+	out := append(append([]byte{}, trimmed[:i]...), summary...)
+	return append(append(out, trimmed[i:]...), '\n')
+}
+
+// buildFailure renders the events go test -json prints for a package whose
+// test binary did not build
+func buildFailure(pkg, output string) []byte {
+	importPath := pkg + " [" + pkg + ".test]"
+	if first, _, _ := strings.Cut(output, "\n"); strings.HasPrefix(first, "# ") {
+		importPath = strings.TrimPrefix(first, "# ")
+	}
+	var buf bytes.Buffer
+	for _, line := range strings.SplitAfter(output, "\n") {
+		if line != "" {
+			buf.Write(syntheticEvent(GoTestEvent{Action: "build-output", ImportPath: importPath, Output: line}))
+		}
+	}
+	buf.Write(syntheticEvent(GoTestEvent{Action: "build-fail", ImportPath: importPath}))
+	buf.Write(syntheticEvent(GoTestEvent{Action: "start", Package: pkg}))
+	buf.Write(syntheticOutput(pkg, "FAIL\t"+pkg+" [build failed]\n"))
+	buf.Write(syntheticEvent(GoTestEvent{Action: "fail", Package: pkg, FailedBuild: importPath}))
+	return buf.Bytes()
+}
+
+// noTestFiles renders the events go test -json prints for a package
+// without tests
+func noTestFiles(pkg string) []byte {
+	var buf bytes.Buffer
+	buf.Write(syntheticEvent(GoTestEvent{Action: "start", Package: pkg}))
+	buf.Write(syntheticOutput(pkg, "?   \t"+pkg+"\t[no test files]\n"))
+	buf.Write(syntheticEvent(GoTestEvent{Action: "skip", Package: pkg}))
+	return buf.Bytes()
+}
+
+// daemonExitError is the exit status of a run on the daemon, reported like
+// that of go test
+type daemonExitError struct {
+	code int
+}
+
+func (e *daemonExitError) Error() string { return "exit status " + strconv.Itoa(e.code) }
+
+// ExitCode returns the exit code go test would have had
+func (e *daemonExitError) ExitCode() int { return e.code }
+
+// runOnDaemon runs tests on the daemon serving the module, returning their
+// go test -json events. It returns ErrNoDaemon when no daemon answers.
+func (r *Runner) runOnDaemon(opts RunOptions) ([]byte, error) {
+	conn, err := net.DialTimeout("unix", DaemonSocket(r.workDir), 200*time.Millisecond)
+	if err != nil {
+		return nil, ErrNoDaemon
+	}
+	defer conn.Close()
+	return requestDaemon(conn, daemonRequestFor(opts))
+}
+
+// daemonRequestFor returns the request running the tests of opts
+func daemonRequestFor(opts RunOptions) daemonRequest {
+	return daemonRequest{
+		Packages:    opts.Packages,
+		Tests:       opts.Tests,
+		BuildFlags:  opts.BuildFlags,
+		Env:         opts.environ(),
+		FailFast:    opts.FailFast,
+		Shuffle:     opts.Shuffle,
+		Seed:        opts.Seed,
+		Timeout:     opts.Timeout,
+		Parallelism: opts.Parallelism,
+	}
+}
+
+// TriggerDaemonRun starts the tests of opts on the daemon serving the
+// module in workDir without waiting for them, and returns the run's ID
+// for DaemonRunResult
+func TriggerDaemonRun(workDir string, opts RunOptions) (string, error) {
+	conn, err := net.DialTimeout("unix", DaemonSocket(workDir), time.Second)
+	if err != nil {
+		return "", ErrNoDaemon
+	}
+	defer conn.Close()
+	req := daemonRequestFor(opts)
+	req.Detach = true
+	return triggerDaemon(conn, req)
+}
+
+// triggerDaemon sends a detached request over conn and returns the run ID
+// the daemon replies with
+func triggerDaemon(conn net.Conn, req daemonRequest) (string, error) {
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return "", fmt.Errorf("failed to send daemon request: %w", err)
+	}
+	var msg daemonMessage
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&msg); err != nil {
+		return "", fmt.Errorf("failed to read daemon reply: %w", err)
+	}
+	if msg.Error != "" {
+		return "", fmt.Errorf("daemon failed to start run: %s", msg.Error)
+	}
+	return msg.Run, nil
+}
+
+// DaemonRunResult returns the go test -json events of a run started with
+// TriggerDaemonRun, with the error runOnDaemon would have returned for it.
+// Unless wait is set, a run in progress returns its events so far and
+// ErrRunInProgress.
+func DaemonRunResult(workDir, id string, wait bool) ([]byte, error) {
+	conn, err := net.DialTimeout("unix", DaemonSocket(workDir), time.Second)
+	if err != nil {
+		return nil, ErrNoDaemon
+	}
+	defer conn.Close()
+	return requestDaemon(conn, daemonRequest{Result: id, Wait: wait})
+}
+
+// FollowDaemonRun passes each go test -json event of a run started with
+// TriggerDaemonRun to handle as the daemon records it, from the run's
+// first, until the run finishes. It returns the error DaemonRunResult
+// would have.
+func FollowDaemonRun(workDir, id string, handle func(event []byte)) error {
+	conn, err := net.DialTimeout("unix", DaemonSocket(workDir), time.Second)
+	if err != nil {
+		return ErrNoDaemon
+	}
+	defer conn.Close()
+	return streamDaemon(conn, daemonRequest{Result: id, Follow: true}, handle)
+}
+
+// requestDaemon sends a request over conn and collects the reply's events
+func requestDaemon(conn net.Conn, req daemonRequest) ([]byte, error) {
+	var events bytes.Buffer
+	err := streamDaemon(conn, req, func(event []byte) {
+		events.Write(event)
+		events.WriteByte('\n')
+	})
+	return events.Bytes(), err
+}
+
+// streamDaemon sends a request over conn and passes each event of the
+// reply to handle as it arrives
+func streamDaemon(conn net.Conn, req daemonRequest, handle func([]byte)) error {
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return fmt.Errorf("failed to send daemon request: %w", err)
+	}
+	dec := json.NewDecoder(bufio.NewReader(conn))
+	for {
+		var msg daemonMessage
+		if err := dec.Decode(&msg); err != nil {
+			return fmt.Errorf("daemon stopped before the run finished: %w", err)
+		}
+		if msg.Running {
+			return ErrRunInProgress
+		}
+		if msg.Exit == nil {
+			handle(msg.Event)
+			continue
+		}
+		if msg.Error != "" {
+			return fmt.Errorf("daemon failed to run tests: %s", msg.Error)
+		}
+		if *msg.Exit != 0 {
+			return &daemonExitError{code: *msg.Exit}
+		}
+		return nil
+	}
+}
+
+// QueryDaemon returns the status of the daemon serving the module in
+// workDir, or ErrNoDaemon when none answers
+func QueryDaemon(workDir string) (*DaemonStatus, error) {
+	conn, err := net.DialTimeout("unix", DaemonSocket(workDir), time.Second)
+	if err != nil {
+		return nil, ErrNoDaemon
+	}
+	defer conn.Close()
+	return queryDaemon(conn)
+}
+
+// queryDaemon sends a status request over conn and returns the reply's
+// status
+func queryDaemon(conn net.Conn) (*DaemonStatus, error) {
+	if err := json.NewEncoder(conn).Encode(daemonRequest{Status: true}); err != nil {
+		return nil, fmt.Errorf("failed to send daemon request: %w", err)
+	}
+	var msg daemonMessage
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&msg); err != nil {
+		return nil, fmt.Errorf("failed to read daemon reply: %w", err)
+	}
+	if msg.Status == nil {
+		return nil, errors.New("daemon replied without a status")
+	}
+	return msg.Status, nil
+}
+
+// StopDaemon asks the daemon serving the module in workDir to stop
+func StopDaemon(workDir string) error {
+	conn, err := net.DialTimeout("unix", DaemonSocket(workDir), time.Second)
+	if err != nil {
+		return ErrNoDaemon
+	}
+	defer conn.Close()
+	_, err = requestDaemon(conn, daemonRequest{Stop: true})
+	return err
+}
@@ -0,0 +1,456 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplitTestBinaryFlags(t *testing.T) {
+	build, test := splitTestBinaryFlags([]string{"-race", "-count", "3", "-tags", "integration", "-short", "-bench=.", "-ldflags", "-s"})
+	if want := []string{"-race", "-tags", "integration", "-ldflags", "-s"}; !reflect.DeepEqual(build, want) {
+		t.Errorf("build = %q, want %q", build, want)
+	}
+	if want := []string{"-test.count=3", "-test.short", "-test.bench=."}; !reflect.DeepEqual(test, want) {
+		t.Errorf("test = %q, want %q", test, want)
+	}
+}
+
+func TestWithPackageSummary(t *testing.T) {
+	events := []byte(`{"Action":"run","Package":"example.com/a","Test":"TestA"}
+{"Action":"pass","Package":"example.com/a","Test":"TestA"}
+{"Action":"pass","Package":"example.com/a","Elapsed":0.25}
+`)
+	lines := strings.Split(strings.TrimSpace(string(withPackageSummary("example.com/a", events))), "\n")
+	if len(lines) != 4 || !strings.Contains(lines[2], `"Output":"ok  \texample.com/a\t0.250s\n"`) || !strings.Contains(lines[3], `"Elapsed":0.25`) {
+		t.Errorf("events = %q, want the ok line before the package result", lines)
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	var sent []string
+	s := &eventStream{send: func(events []byte) { sent = append(sent, string(events)) }}
+	for _, p := range []string{`{"a":1}`, "\n{\"b\"", ":2}\n{\"c\":3}\n{\"d\"", ":4}\n"} {
+		s.Write([]byte(p))
+	}
+	if want := []string{`{"a":1}` + "\n" + `{"b":2}` + "\n", `{"c":3}` + "\n"}; !reflect.DeepEqual(sent, want) {
+		t.Errorf("sent %q, want %q", sent, want)
+	}
+	if string(s.pending) != `{"d":4}`+"\n" {
+		t.Errorf("pending = %q, want the last event", s.pending)
+	}
+}
+
+func TestListenDaemon(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "sockets", "d.sock")
+	listener, err := ListenDaemon(socket)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	defer listener.Close()
+	info, err := os.Stat(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("socket mode = %v, want owner-only", info.Mode().Perm())
+	}
+	if _, err := ListenDaemon(socket); err == nil || !strings.Contains(err.Error(), "already listening") {
+		t.Errorf("second ListenDaemon() err = %v, want a daemon already listening", err)
+	}
+}
+
+func TestDaemon_RunsWarmBinaries(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds test binaries")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":            "module example.com/warm\n\ngo 1.21\n",
+		"calc/calc.go":      "package calc\n\nfunc Add(a, b int) int { return a + b }\n",
+		"calc/calc_test.go": "package calc\n\nimport \"testing\"\n\nfunc TestAdd(t *testing.T) {\n\tif Add(1, 2) != 3 {\n\t\tt.Fatal(\"wrong sum\")\n\t}\n}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	daemon, err := NewDaemon(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	daemon.pkgCache = NewPackageCache(dir, "")
+	listener, err := net.Listen("unix", filepath.Join(t.TempDir(), "d.sock"))
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go daemon.Serve(ctx, listener)
+
+	run := func() (string, error) {
+		conn, err := net.Dial("unix", listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		output, err := requestDaemon(conn, daemonRequest{Packages: []string{"./..."}})
+		return string(output), err
+	}
+
+	output, err := run()
+	if err != nil || !strings.Contains(output, `"Action":"pass","Package":"example.com/warm/calc","Test":"TestAdd"`) {
+		t.Fatalf("first run: err = %v, output:\n%s", err, output)
+	}
+
+	// Changed sources are rebuilt before the next run
+	later := time.Now().Add(time.Second)
+	source := filepath.Join(dir, "calc/calc.go")
+	if err := os.WriteFile(source, []byte("package calc\n\nfunc Add(a, b int) int { return a - b }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(source, later, later)
+	output, err = run()
+	var exitErr interface{ ExitCode() int }
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 || !strings.Contains(output, "wrong sum") {
+		t.Errorf("run after change: err = %v, output:\n%s", err, output)
+	}
+	if !errors.Is(testError(output, err), ErrTestsFailed) {
+		t.Errorf("testError() = %v, want failed tests", testError(output, err))
+	}
+}
+
+func TestDaemon_CoalescesIdenticalRuns(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds test binaries")
+	}
+	dir := t.TempDir()
+	runs := filepath.Join(dir, "runs.log")
+	files := map[string]string{
+		"go.mod": "module example.com/shared\n\ngo 1.21\n",
+		"slow/slow_test.go": "package slow\n\nimport (\n\t\"os\"\n\t\"testing\"\n\t\"time\"\n)\n\n" +
+			"func TestSlow(t *testing.T) {\n\tf, _ := os.OpenFile(" + strconv.Quote(runs) + ", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)\n" +
+			"\tf.WriteString(\"run\\n\")\n\tf.Close()\n\ttime.Sleep(time.Second)\n}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	daemon, err := NewDaemon(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	daemon.pkgCache = NewPackageCache(dir, "")
+	if err := daemon.Warm([]string{"./..."}); err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("unix", filepath.Join(t.TempDir(), "d.sock"))
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go daemon.Serve(ctx, listener)
+
+	run := func(runID string) string {
+		conn, err := net.Dial("unix", listener.Addr().String())
+		if err != nil {
+			t.Error(err)
+			return ""
+		}
+		defer conn.Close()
+		output, err := requestDaemon(conn, daemonRequest{Packages: []string{"./..."}, Env: append(os.Environ(), EnvRunID+"="+runID)})
+		if err != nil {
+			t.Errorf("run %s: %v", runID, err)
+		}
+		return string(output)
+	}
+	countRuns := func() int {
+		data, _ := os.ReadFile(runs)
+		return strings.Count(string(data), "run\n")
+	}
+
+	// The second request arrives while the first is running and shares it
+	outputs := make(chan string, 2)
+	go func() { outputs <- run("first") }()
+	time.Sleep(300 * time.Millisecond)
+	go func() { outputs <- run("second") }()
+	for i := 0; i < 2; i++ {
+		if output := <-outputs; !strings.Contains(output, `"Action":"pass","Package":"example.com/shared/slow","Test":"TestSlow"`) {
+			t.Errorf("output:\n%s", output)
+		}
+	}
+	if n := countRuns(); n != 1 {
+		t.Errorf("tests ran %d times for two identical concurrent requests, want 1", n)
+	}
+
+	// Once it finished, the same request runs the tests again
+	run("third")
+	if n := countRuns(); n != 2 {
+		t.Errorf("tests ran %d times after a later request, want 2", n)
+	}
+}
+
+func TestDaemon_DetachedRuns(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds test binaries")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/detached\n\ngo 1.21\n",
+		"slow/slow_test.go": "package slow\n\nimport (\n\t\"os\"\n\t\"testing\"\n\t\"time\"\n)\n\n" +
+			"func TestSlow(t *testing.T) {\n\ttime.Sleep(time.Second)\n\tif os.Getenv(\"SENTINEL_RUN_ID\") != \"ci-1\" {\n\t\tt.Fatal(\"wrong run ID\")\n\t}\n}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	daemon, err := NewDaemon(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	daemon.pkgCache = NewPackageCache(dir, "")
+	if err := daemon.Warm([]string{"./..."}); err != nil {
+		t.Fatal(err)
+	}
+	// Listen where FollowDaemonRun looks for the module's daemon
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	listener, err := ListenDaemon(DaemonSocket(dir))
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	defer listener.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go daemon.Serve(ctx, listener)
+
+	dial := func() net.Conn {
+		conn, err := net.Dial("unix", listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+	req := daemonRequest{Detach: true, Packages: []string{"./..."}, Env: append(os.Environ(), EnvRunID+"=ci-1")}
+	id, err := triggerDaemon(dial(), req)
+	if err != nil || id != "ci-1" {
+		t.Fatalf("triggerDaemon() = %q, %v, want ci-1", id, err)
+	}
+	if _, err := triggerDaemon(dial(), req); err == nil {
+		t.Error("triggerDaemon() with a run ID in use succeeded")
+	}
+
+	// The run is in progress until its test finishes
+	if _, err := requestDaemon(dial(), daemonRequest{Result: id}); !errors.Is(err, ErrRunInProgress) {
+		t.Errorf("result while running: err = %v, want ErrRunInProgress", err)
+	}
+	if status, err := queryDaemon(dial()); err != nil || status.Triggered != 1 || status.Running != 1 {
+		t.Errorf("queryDaemon() = %+v, %v, want one triggered run in progress", status, err)
+	}
+	if idle := daemon.idleFor(time.Now().Add(time.Hour)); idle != 0 {
+		t.Errorf("idleFor() = %s while a detached run is in progress, want 0", idle)
+	}
+	var followed []string
+	err = FollowDaemonRun(dir, id, func(event []byte) {
+		followed = append(followed, string(event))
+	})
+	if err != nil || !strings.Contains(strings.Join(followed, "\n"), `"Action":"run","Package":"example.com/detached/slow","Test":"TestSlow"`) {
+		t.Errorf("following the run: err = %v, events:\n%s", err, strings.Join(followed, "\n"))
+	}
+	output, err := requestDaemon(dial(), daemonRequest{Result: id, Wait: true})
+	if err != nil || !strings.Contains(string(output), `"Action":"pass","Package":"example.com/detached/slow","Test":"TestSlow"`) {
+		t.Errorf("result after waiting: err = %v, output:\n%s", err, output)
+	}
+	// Followers get the events of the run unmodified, one per call, as
+	// tail --json prints them
+	if want := strings.Split(strings.TrimSuffix(string(output), "\n"), "\n"); !reflect.DeepEqual(followed, want) {
+		t.Errorf("followed events differ from the run's events:\n%s\nwant:\n%s", strings.Join(followed, "\n"), output)
+	}
+
+	if _, err := requestDaemon(dial(), daemonRequest{Result: "unknown"}); err == nil || !strings.Contains(err.Error(), "no run unknown") {
+		t.Errorf("result of an unknown run: err = %v", err)
+	}
+}
+
+func TestDaemon_ReleasesWhenIdle(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds test binaries")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":            "module example.com/idle\n\ngo 1.21\n",
+		"calc/calc_test.go": "package calc\n\nimport \"testing\"\n\nfunc TestAdd(t *testing.T) {}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	daemon, err := NewDaemon(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	daemon.pkgCache = NewPackageCache(dir, "")
+	daemon.SetIdle(200*time.Millisecond, 0)
+	if err := daemon.Warm([]string{"./..."}); err != nil {
+		t.Fatal(err)
+	}
+	var built []string
+	for _, bin := range daemon.binaries {
+		built = append(built, bin.path)
+	}
+	if len(built) != 1 {
+		t.Fatalf("warmed %d binaries, want 1", len(built))
+	}
+	listener, err := net.Listen("unix", filepath.Join(t.TempDir(), "d.sock"))
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go daemon.Serve(ctx, listener)
+
+	// Idle past the release: the binary is removed
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		daemon.mu.Lock()
+		released := daemon.released
+		daemon.mu.Unlock()
+		if released {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("daemon did not release its binaries while idle")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if _, err := os.Stat(built[0]); !os.IsNotExist(err) {
+		t.Errorf("released binary still exists: %v", err)
+	}
+
+	// Status requests report the release without ending it
+	conn, err := net.Dial("unix", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	status, err := queryDaemon(conn)
+	if err != nil || !status.Released || status.Binaries != 0 || status.WorkDir != dir {
+		t.Errorf("queryDaemon() = %+v, %v, want released in %s", status, err, dir)
+	}
+	daemon.mu.Lock()
+	released := daemon.released
+	daemon.mu.Unlock()
+	if !released {
+		t.Error("status request ended the release")
+	}
+
+	// The next run rebuilds it
+	conn, err = net.Dial("unix", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	output, err := requestDaemon(conn, daemonRequest{Packages: []string{"./..."}})
+	if err != nil || !strings.Contains(string(output), `"Action":"pass","Package":"example.com/idle/calc","Test":"TestAdd"`) {
+		t.Errorf("run after release: err = %v, output:\n%s", err, output)
+	}
+}
+
+func TestDaemon_StopsWhenIdle(t *testing.T) {
+	if testing.Short() {
+		t.Skip("needs the go toolchain")
+	}
+	daemon, err := NewDaemon(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	daemon.SetIdle(0, 100*time.Millisecond)
+	listener, err := net.Listen("unix", filepath.Join(t.TempDir(), "d.sock"))
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- daemon.Serve(context.Background(), listener) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Serve() error = %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("daemon did not stop while idle")
+	}
+}
+
+func TestDaemon_Fingerprint(t *testing.T) {
+	// The tests of a import the helper tu, whose code imports b, which is
+	// not among a's Deps
+	dir := t.TempDir()
+	for _, name := range []string{"go.mod", "a/a_test.go", "tu/tu.go", "b/b.go"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("package x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a := &PackageInfo{ImportPath: "example/a", Dir: filepath.Join(dir, "a"), TestGoFiles: []string{"a_test.go"}, TestImports: []string{"example/tu"}}
+	graph := NewDependencyGraph([]*PackageInfo{
+		a,
+		{ImportPath: "example/tu", Dir: filepath.Join(dir, "tu"), Imports: []string{"example/b"}, Deps: []string{"example/b"}},
+		{ImportPath: "example/b", Dir: filepath.Join(dir, "b")},
+	})
+	d := &Daemon{workDir: dir, pkgCache: NewPackageCache(dir, "")}
+	env := []string{"HOME=/home/dev", "GOFLAGS=-mod=mod", "CGO_ENABLED=1"}
+	before := d.fingerprint(a, nil, env, graph)
+
+	if got := d.fingerprint(a, nil, []string{"GOFLAGS=-mod=mod", "CGO_ENABLED=1", "HOME=/root", "GOCACHE=/tmp"}, graph); got != before {
+		t.Error("fingerprint changed with variables that do not change the build")
+	}
+	for _, changed := range []string{"GOFLAGS=-mod=vendor", "CGO_ENABLED=0", "GOOS=windows"} {
+		if got := d.fingerprint(a, nil, append(env, changed), graph); got == before {
+			t.Errorf("fingerprint unchanged with %s", changed)
+		}
+	}
+
+	later := time.Now().Add(time.Second)
+	source := filepath.Join(dir, "b", "b.go")
+	if err := os.WriteFile(source, []byte("package b // edited\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(source, later, later)
+	if d.fingerprint(a, nil, env, graph) == before {
+		t.Error("fingerprint unchanged after editing a dependency of a test helper")
+	}
+}
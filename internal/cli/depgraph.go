@@ -0,0 +1,150 @@
+package cli
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DependencyGraph records which of a set of packages import each other,
+// from their code or their tests, so a change can be traced to every
+// package whose tests it can affect
+type DependencyGraph struct {
+	byDir       map[string]string          // Package directory to import path
+	dirs        map[string]string          // Import path to package directory
+	importers   map[string]map[string]bool // Import path to the listed packages importing it directly
+	codeImports map[string]map[string]bool // Import path to what its non-test code imports
+	testImports map[string]map[string]bool // Import path to what its test files import
+}
+
+// NewDependencyGraph builds the graph of the packages reported by go list.
+// Imports of packages outside pkgs are not followed.
+func NewDependencyGraph(pkgs []*PackageInfo) *DependencyGraph {
+	g := &DependencyGraph{
+		byDir:       make(map[string]string, len(pkgs)),
+		dirs:        make(map[string]string, len(pkgs)),
+		importers:   make(map[string]map[string]bool),
+		codeImports: make(map[string]map[string]bool, len(pkgs)),
+		testImports: make(map[string]map[string]bool, len(pkgs)),
+	}
+	for _, pkg := range pkgs {
+		g.byDir[filepath.Clean(pkg.Dir)] = pkg.ImportPath
+		g.dirs[pkg.ImportPath] = pkg.Dir
+		g.codeImports[pkg.ImportPath] = make(map[string]bool, len(pkg.Imports))
+		for _, imported := range pkg.Imports {
+			g.codeImports[pkg.ImportPath][imported] = true
+		}
+		g.testImports[pkg.ImportPath] = make(map[string]bool, len(pkg.TestImports)+len(pkg.XTestImports))
+		for _, imported := range append(append([]string{}, pkg.TestImports...), pkg.XTestImports...) {
+			g.testImports[pkg.ImportPath][imported] = true
+		}
+	}
+	for _, pkg := range pkgs {
+		for _, imports := range [][]string{pkg.Imports, pkg.TestImports, pkg.XTestImports} {
+			for _, imported := range imports {
+				if imported == pkg.ImportPath {
+					continue // External tests import the package they test
+				}
+				if g.importers[imported] == nil {
+					g.importers[imported] = make(map[string]bool)
+				}
+				g.importers[imported][pkg.ImportPath] = true
+			}
+		}
+	}
+	return g
+}
+
+// Dependents returns the listed packages importing importPath directly or
+// through other listed packages, sorted
+func (g *DependencyGraph) Dependents(importPath string) []string {
+	affected := make(map[string]bool)
+	expanded := map[string]bool{importPath: true}
+	queue := []string{importPath}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for importer := range g.importers[current] {
+			affected[importer] = true
+			// Test-only imports do not make the change reach the importer's
+			// own importers
+			if !expanded[importer] && g.codeImports[importer][current] {
+				expanded[importer] = true
+				queue = append(queue, importer)
+			}
+		}
+	}
+	delete(affected, importPath)
+	dependents := make([]string, 0, len(affected))
+	for dependent := range affected {
+		dependents = append(dependents, dependent)
+	}
+	sort.Strings(dependents)
+	return dependents
+}
+
+// TestDependencies returns the listed packages the tests of importPath are
+// built from: those its code and test files import, and those their code
+// imports in turn, sorted. Unlike go list's Deps, it follows what test
+// helpers imported only by the tests import.
+func (g *DependencyGraph) TestDependencies(importPath string) []string {
+	deps := make(map[string]bool)
+	var queue []string
+	visit := func(imports map[string]bool) {
+		for imported := range imports {
+			if _, listed := g.dirs[imported]; listed && !deps[imported] {
+				deps[imported] = true
+				queue = append(queue, imported)
+			}
+		}
+	}
+	visit(g.codeImports[importPath])
+	visit(g.testImports[importPath])
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		visit(g.codeImports[current])
+	}
+	delete(deps, importPath)
+	paths := make([]string, 0, len(deps))
+	for dep := range deps {
+		paths = append(paths, dep)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Dir returns the directory of a listed package
+func (g *DependencyGraph) Dir(importPath string) (string, bool) {
+	dir, ok := g.dirs[importPath]
+	return dir, ok
+}
+
+// Affected returns the packages a change to files can affect: the packages
+// containing them and, for changes to non-test code, every package
+// importing those. It returns false when a file is not in a listed
+// package, so the change cannot be traced and everything must rerun.
+func (g *DependencyGraph) Affected(files []string) ([]string, bool) {
+	affected := make(map[string]bool)
+	for _, file := range files {
+		file = filepath.Clean(file)
+		importPath, ok := g.byDir[filepath.Dir(file)]
+		if !ok || !strings.HasSuffix(file, ".go") {
+			return nil, false
+		}
+		affected[importPath] = true
+		// Test files are never imported
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		for _, dependent := range g.Dependents(importPath) {
+			affected[dependent] = true
+		}
+	}
+	pkgs := make([]string, 0, len(affected))
+	for importPath := range affected {
+		pkgs = append(pkgs, importPath)
+	}
+	sort.Strings(pkgs)
+	return pkgs, true
+}
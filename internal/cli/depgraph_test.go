@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDependencyGraph_Affected(t *testing.T) {
+	root := t.TempDir()
+	pkg := func(name string, imports, testImports []string) *PackageInfo {
+		return &PackageInfo{ImportPath: "example.com/" + name, Dir: filepath.Join(root, name), Imports: imports, TestImports: testImports}
+	}
+	// store <- api <- cmd, and mocks imports store only from its tests
+	pkgs := []*PackageInfo{
+		pkg("store", []string{"fmt"}, nil),
+		pkg("api", []string{"example.com/store"}, nil),
+		pkg("cmd", []string{"example.com/api"}, nil),
+		pkg("mocks", nil, []string{"example.com/store"}),
+		pkg("e2e", nil, []string{"example.com/mocks"}),
+		pkg("util", nil, nil),
+	}
+	g := NewDependencyGraph(pkgs)
+
+	tests := []struct {
+		name  string
+		files []string
+		want  []string
+		ok    bool
+	}{
+		{"code change reaches importers", []string{"store/db.go"},
+			[]string{"example.com/api", "example.com/cmd", "example.com/mocks", "example.com/store"}, true},
+		{"test change stays in its package", []string{"store/db_test.go"}, []string{"example.com/store"}, true},
+		{"test-only importers are not followed", []string{"mocks/fake.go"}, []string{"example.com/e2e", "example.com/mocks"}, true},
+		{"leaf package", []string{"util/util.go"}, []string{"example.com/util"}, true},
+		{"unknown package", []string{"tools/gen.go"}, nil, false},
+	}
+	for _, tt := range tests {
+		files := make([]string, len(tt.files))
+		for i, file := range tt.files {
+			files[i] = filepath.Join(root, file)
+		}
+		got, ok := g.Affected(files)
+		if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Affected() = %v, %v, want %v, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestDependencyGraph_TestDependencies(t *testing.T) {
+	root := t.TempDir()
+	// a's tests import the helper tu, whose code imports b; b's tests
+	// import fake, which a's tests never build
+	pkgs := []*PackageInfo{
+		{ImportPath: "example.com/a", Dir: filepath.Join(root, "a"), Imports: []string{"fmt"}, XTestImports: []string{"example.com/a", "example.com/tu"}},
+		{ImportPath: "example.com/tu", Dir: filepath.Join(root, "tu"), Imports: []string{"example.com/b", "testing"}},
+		{ImportPath: "example.com/b", Dir: filepath.Join(root, "b"), TestImports: []string{"example.com/fake"}},
+		{ImportPath: "example.com/fake", Dir: filepath.Join(root, "fake")},
+	}
+	g := NewDependencyGraph(pkgs)
+
+	if got, want := g.TestDependencies("example.com/a"), []string{"example.com/b", "example.com/tu"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("TestDependencies(a) = %v, want %v", got, want)
+	}
+	if got, want := g.TestDependencies("example.com/b"), []string{"example.com/fake"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("TestDependencies(b) = %v, want %v", got, want)
+	}
+	if dir, ok := g.Dir("example.com/tu"); !ok || dir != filepath.Join(root, "tu") {
+		t.Errorf("Dir(tu) = %q, %v", dir, ok)
+	}
+}
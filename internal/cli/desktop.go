@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// DefaultDesktopThrottle is the least time between desktop notifications
+// unless configured otherwise
+const DefaultDesktopThrottle = 10 * time.Second
+
+// desktopSendTimeout bounds how long a notification command may take
+const desktopSendTimeout = 5 * time.Second
+
+// DesktopNotifier shows a desktop notification when watch mode runs go from
+// passing to failing or back. Changes within Throttle of the last
+// notification are held back; the next run after it reports the state
+// then, so rapid edits flipping between the two notify at most once.
+type DesktopNotifier struct {
+	Throttle time.Duration
+
+	send     func(title, body string) error
+	seen     bool // A run has set the baseline state
+	failing  bool // State the user was last told about, or the baseline
+	lastSent time.Time
+}
+
+// NewDesktopNotifier returns a notifier using the platform's notification
+// command, allowing one notification per throttle
+func NewDesktopNotifier(throttle time.Duration) *DesktopNotifier {
+	return &DesktopNotifier{Throttle: throttle, send: sendDesktopNotification}
+}
+
+// Notify notifies about a run if it changed whether the tests pass
+func (n *DesktopNotifier) Notify(run *TestRun) {
+	title, body, ok := n.observe(run, time.Now())
+	if !ok {
+		return
+	}
+	if err := n.send(title, body); err != nil {
+		log.Printf("Error showing desktop notification: %v", err)
+	}
+}
+
+// observe records a run finished at now and returns the notification to
+// show, if any
+func (n *DesktopNotifier) observe(run *TestRun, now time.Time) (title, body string, ok bool) {
+	failing := run.NumFailed > 0
+	if !n.seen {
+		n.seen, n.failing = true, failing
+		return "", "", false
+	}
+	if failing == n.failing || now.Sub(n.lastSent) < n.Throttle {
+		return "", "", false
+	}
+	n.failing, n.lastSent = failing, now
+	if failing {
+		return "Tests failing", desktopFailureBody(run), true
+	}
+	return "Tests passing again", fmt.Sprintf("All %d %s pass", run.NumTotal, pluralize("test", run.NumTotal)), true
+}
+
+// desktopFailureBody names the failed tests, or their count when there are
+// many
+func desktopFailureBody(run *TestRun) string {
+	var names []string
+	for _, test := range run.FailedTests {
+		names = append(names, test.Name)
+	}
+	switch {
+	case len(names) == 0:
+		return fmt.Sprintf("%d %s failed", run.NumFailed, pluralize("package", run.NumFailed))
+	case len(names) <= 3:
+		return strings.Join(names, ", ") + " failed"
+	}
+	return fmt.Sprintf("%s and %d more failed", strings.Join(names[:2], ", "), len(names)-2)
+}
+
+// sendDesktopNotification shows a notification with the platform's command
+func sendDesktopNotification(title, body string) error {
+	args, err := desktopCommand(runtime.GOOS, title, body)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), desktopSendTimeout)
+	defer cancel()
+	if output, err := exec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to run %s: %w: %s", args[0], err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// desktopCommand returns the command showing a notification on goos:
+// osascript on macOS, PowerShell toasts on Windows, and notify-send
+// elsewhere
+func desktopCommand(goos, title, body string) ([]string, error) {
+	switch goos {
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptString(body), appleScriptString(title))
+		return []string{"osascript", "-e", script}, nil
+	case "windows":
+		script := fmt.Sprintf(windowsToastScript, powerShellString(title), powerShellString(body))
+		return []string{"powershell", "-NoProfile", "-NonInteractive", "-Command", script}, nil
+	case "linux", "freebsd", "openbsd", "netbsd":
+		return []string{"notify-send", "--app-name=go-sentinel", title, body}, nil
+	}
+	return nil, fmt.Errorf("desktop notifications are not supported on %s", goos)
+}
+
+// windowsToastScript shows a toast with a title and a body line
+const windowsToastScript = `[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null
+$xml = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$text = $xml.GetElementsByTagName('text')
+$text.Item(0).AppendChild($xml.CreateTextNode(%s)) > $null
+$text.Item(1).AppendChild($xml.CreateTextNode(%s)) > $null
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('go-sentinel').Show([Windows.UI.Notifications.ToastNotification]::new($xml))`
+
+// appleScriptString quotes s as an AppleScript string literal
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// powerShellString quotes s as a PowerShell literal string
+func powerShellString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
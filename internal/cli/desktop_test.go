@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDesktopNotifier_Transitions(t *testing.T) {
+	passing := &TestRun{NumTotal: 4}
+	failing := &TestRun{NumTotal: 4, NumFailed: 1, FailedTests: []*TestResult{{Name: "TestLogin"}}}
+
+	n := &DesktopNotifier{Throttle: 10 * time.Second}
+	start := time.Now()
+	steps := []struct {
+		run   *TestRun
+		after time.Duration
+		title string
+	}{
+		{passing, 0, ""},                            // The first run sets the baseline
+		{passing, time.Second, ""},                  // No change
+		{failing, 2 * time.Second, "Tests failing"}, // Passing to failing
+		{passing, 5 * time.Second, ""},              // Held back by the throttle
+		{failing, 8 * time.Second, ""},              // Back to the state last notified
+		{passing, 30 * time.Second, "Tests passing again"},
+	}
+	for i, step := range steps {
+		title, body, ok := n.observe(step.run, start.Add(step.after))
+		if title != step.title || ok != (step.title != "") {
+			t.Errorf("step %d: observe() = %q, %v, want %q", i, title, ok, step.title)
+		}
+		if title == "Tests failing" && body != "TestLogin failed" {
+			t.Errorf("step %d: body = %q", i, body)
+		}
+	}
+}
+
+func TestDesktopFailureBody(t *testing.T) {
+	run := &TestRun{NumFailed: 5}
+	for _, name := range []string{"TestA", "TestB", "TestC", "TestD"} {
+		run.FailedTests = append(run.FailedTests, &TestResult{Name: name})
+	}
+	if got := desktopFailureBody(run); got != "TestA, TestB and 2 more failed" {
+		t.Errorf("desktopFailureBody() = %q", got)
+	}
+	if got := desktopFailureBody(&TestRun{NumFailed: 1}); got != "1 package failed" {
+		t.Errorf("desktopFailureBody() without tests = %q", got)
+	}
+}
+
+func TestDesktopCommand(t *testing.T) {
+	args, err := desktopCommand("darwin", `Tests "failing"`, `a\b`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"osascript", "-e", `display notification "a\\b" with title "Tests \"failing\""`}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("darwin = %q, want %q", args, want)
+	}
+
+	args, err = desktopCommand("linux", "Tests failing", "TestA failed")
+	if err != nil || !reflect.DeepEqual(args, []string{"notify-send", "--app-name=go-sentinel", "Tests failing", "TestA failed"}) {
+		t.Errorf("linux = %q, %v", args, err)
+	}
+
+	args, err = desktopCommand("windows", "Tests failing", "it's broken")
+	if err != nil || args[0] != "powershell" || !strings.Contains(args[len(args)-1], "'it''s broken'") {
+		t.Errorf("windows = %q, %v", args, err)
+	}
+
+	if _, err := desktopCommand("plan9", "t", "b"); err == nil {
+		t.Error("desktopCommand() on an unsupported system succeeded")
+	}
+}
@@ -64,3 +64,19 @@ func AggregateDurations(durations []time.Duration) time.Duration {
 	}
 	return total
 }
+
+// FormatAge formats the time elapsed since t in the largest whole unit,
+// such as "5m ago" or "3d ago"
+func FormatAge(t time.Time, now time.Time) string {
+	d := now.Sub(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	}
+}
@@ -0,0 +1,191 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// editPrompt is an "edit & rerun" command waiting for the arguments the
+// user typed: go test flags and, optionally, packages
+type editPrompt struct {
+	target string   // Package the command was given, or "" for the packages of the run
+	args   []string // Arguments shown to the user
+}
+
+// startEdit shows the arguments of the last run of target, or the ones
+// last typed for it, and returns the prompt waiting for the new ones. An
+// empty target edits the first failed package of the last run, or the
+// whole run when nothing failed.
+func (r *Runner) startEdit(opts RunOptions, target string) *editPrompt {
+	r.mu.Lock()
+	if target == "" && r.lastRun != nil {
+		for _, suite := range r.lastRun.Suites {
+			if suite.NumFailed > 0 {
+				target = suiteName(suite)
+				break
+			}
+		}
+	}
+	args, ok := r.editedArgs[target]
+	r.mu.Unlock()
+	if !ok {
+		args = editableArgs(opts)
+	}
+
+	prompt := &editPrompt{target: target, args: args}
+	if opts.Renderer != nil {
+		opts.Renderer.RenderEditPrompt(prompt.packages(opts), args)
+	}
+	return prompt
+}
+
+// rerunEdited runs the packages of a prompt with the arguments typed for
+// it; an empty line keeps the ones shown. The arguments are remembered for
+// the next edit of the same target.
+func (r *Runner) rerunEdited(opts RunOptions, prompt *editPrompt, line string) error {
+	args := prompt.args
+	if strings.TrimSpace(line) != "" {
+		var err error
+		if args, err = splitArgs(line); err != nil {
+			log.Printf("Error reading arguments: %v", err)
+			return nil
+		}
+	}
+	edited, err := applyEditedArgs(opts, prompt.packages(opts), args)
+	if err != nil {
+		log.Printf("Error reading arguments: %v", err)
+		return nil
+	}
+
+	r.mu.Lock()
+	if r.editedArgs == nil {
+		r.editedArgs = make(map[string][]string)
+	}
+	r.editedArgs[prompt.target] = args
+	r.mu.Unlock()
+
+	_, err = r.RunOnce(edited)
+	return err
+}
+
+// packages returns the packages the prompt reruns when the typed
+// arguments name none
+func (p *editPrompt) packages(opts RunOptions) []string {
+	if p.target != "" {
+		return []string{p.target}
+	}
+	return opts.Packages
+}
+
+// editableArgs returns the go test arguments of a run the user may edit:
+// its -run pattern and its build flags, such as -count, -tags, or -race
+func editableArgs(opts RunOptions) []string {
+	var args []string
+	if len(opts.Tests) > 0 {
+		args = append(args, "-run", strings.Join(opts.Tests, "|"))
+	}
+	return append(args, opts.BuildFlags...)
+}
+
+// applyEditedArgs returns opts running the tests selected by edited go test
+// arguments. -run selects the tests, arguments that are not flags replace
+// pkgs, and every other flag is passed to go test as is.
+func applyEditedArgs(opts RunOptions, pkgs []string, args []string) (RunOptions, error) {
+	opts.Tests = nil
+	opts.BuildFlags = nil
+	opts.Packages = pkgs
+	opts.ChangedFiles = nil
+	opts.selected = false
+
+	var named []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-run" || arg == "--run":
+			if i+1 == len(args) {
+				return opts, fmt.Errorf("flag needs an argument: %s", arg)
+			}
+			i++
+			opts.Tests = []string{args[i]}
+		case strings.HasPrefix(arg, "-run=") || strings.HasPrefix(arg, "--run="):
+			opts.Tests = []string{arg[strings.Index(arg, "=")+1:]}
+		case arg == "-json" || arg == "-v":
+			// Always passed, since go-sentinel parses the JSON events
+		case strings.HasPrefix(arg, "-"):
+			opts.BuildFlags = append(opts.BuildFlags, arg)
+			// Flags given as "-count 3" keep their value
+			if !strings.Contains(arg, "=") && i+1 < len(args) && takesValue(arg) {
+				i++
+				opts.BuildFlags = append(opts.BuildFlags, args[i])
+			}
+		default:
+			named = append(named, arg)
+		}
+	}
+	if len(named) > 0 {
+		opts.Packages = named
+	}
+	return opts, nil
+}
+
+// valueFlags are the go test flags whose value can follow as a separate
+// argument; boolean flags such as -race cannot take one that way
+var valueFlags = []string{
+	"bench", "benchtime", "count", "coverpkg", "covermode", "cpu", "exec", "ldflags", "gcflags",
+	"list", "mod", "outputdir", "p", "parallel", "shuffle", "skip", "tags", "timeout", "toolexec",
+}
+
+// takesValue reports whether a go test flag takes its value as the next
+// argument
+func takesValue(flag string) bool {
+	return containsString(valueFlags, strings.TrimLeft(flag, "-"))
+}
+
+// splitArgs splits a typed command line into arguments as a POSIX shell
+// would for plain words and quotes, without expanding anything
+func splitArgs(line string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote rune
+	for _, c := range line {
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(c)
+		case c == '\'' || c == '"':
+			quote, inArg = c, true
+		case c == ' ' || c == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(c)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// formatArgs joins arguments into a line splitArgs reads back, quoting
+// those with spaces or shell metacharacters
+func formatArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t'\"|&;()<>$`\\*?[]^") {
+			arg = "'" + strings.ReplaceAll(arg, "'", `'"'"'`) + "'"
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
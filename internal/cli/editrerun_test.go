@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyEditedArgs(t *testing.T) {
+	opts := RunOptions{Tests: []string{"^TestOld$"}, BuildFlags: []string{"-race"}, ChangedFiles: []string{"a.go"}, selected: true}
+	args, err := splitArgs(`-run '^TestLogin$' -count 3 -tags=integration -v ./api/...`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited, err := applyEditedArgs(opts, []string{"example.com/app/store"}, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(edited.Tests, []string{"^TestLogin$"}) {
+		t.Errorf("Tests = %q", edited.Tests)
+	}
+	if !reflect.DeepEqual(edited.BuildFlags, []string{"-count", "3", "-tags=integration"}) {
+		t.Errorf("BuildFlags = %q", edited.BuildFlags)
+	}
+	if !reflect.DeepEqual(edited.Packages, []string{"./api/..."}) {
+		t.Errorf("Packages = %q", edited.Packages)
+	}
+	if edited.ChangedFiles != nil || edited.selected {
+		t.Error("edited run kept the watch selection")
+	}
+
+	edited, _ = applyEditedArgs(opts, []string{"example.com/app/store"}, []string{"-race"})
+	if edited.Tests != nil || !reflect.DeepEqual(edited.Packages, []string{"example.com/app/store"}) {
+		t.Errorf("without -run or packages: Tests = %q, Packages = %q", edited.Tests, edited.Packages)
+	}
+
+	if _, err := applyEditedArgs(opts, nil, []string{"-run"}); err == nil {
+		t.Error("applyEditedArgs() accepted -run without a pattern")
+	}
+}
+
+func TestFormatArgs_RoundTrip(t *testing.T) {
+	args := []string{"-run", "^TestA$|^TestB$", "-count=1", "-ldflags", "-X main.v=it's", ""}
+	line := formatArgs(args)
+	got, err := splitArgs(line)
+	if err != nil {
+		t.Fatalf("splitArgs(%q) error = %v", line, err)
+	}
+	if !reflect.DeepEqual(got, args) {
+		t.Errorf("splitArgs(formatArgs()) = %q, want %q (line %q)", got, args, line)
+	}
+	if _, err := splitArgs(`-run "TestA`); err == nil {
+		t.Error("splitArgs() accepted an unterminated quote")
+	}
+}
@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+// ParseRecipients parses age X25519 public keys ("age1...")
+func ParseRecipients(keys []string) ([]age.Recipient, error) {
+	recipients := make([]age.Recipient, 0, len(keys))
+	for _, key := range keys {
+		r, err := age.ParseX25519Recipient(strings.TrimSpace(key))
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipient %q: %w", key, err)
+		}
+		recipients = append(recipients, r)
+	}
+	return recipients, nil
+}
+
+// EncryptPayload encrypts data to the age public keys and returns it
+// ASCII-armored, so it can travel in chat messages and email bodies. Any
+// holder of a matching identity decrypts it with `age -d -i key.txt`.
+func EncryptPayload(keys []string, data []byte) ([]byte, error) {
+	recipients, err := ParseRecipients(keys)
+	if err != nil {
+		return nil, err
+	}
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("at least one recipient is required")
+	}
+
+	var buf bytes.Buffer
+	aw := armor.NewWriter(&buf)
+	w, err := age.Encrypt(aw, recipients...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt payload: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to encrypt payload: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encrypt payload: %w", err)
+	}
+	if err := aw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encrypt payload: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// DecryptPayload reverses EncryptPayload with an age identity file's
+// contents ("AGE-SECRET-KEY-1..." lines)
+func DecryptPayload(identities string, data []byte) ([]byte, error) {
+	ids, err := age.ParseIdentities(strings.NewReader(identities))
+	if err != nil {
+		return nil, fmt.Errorf("invalid identity: %w", err)
+	}
+	r, err := age.Decrypt(armor.NewReader(bytes.NewReader(data)), ids...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt payload: %w", err)
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt payload: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+)
+
+// EnvPolicy decides which environment variables of go-sentinel are passed
+// on to test processes, so secrets of a CI runner are not exposed to
+// whatever code the tests run. Names are matched against globs such as
+// "AWS_*". Without an allowlist every variable is passed; with one, only
+// the variables it matches and those go itself needs. The denylist is
+// applied last and wins over both.
+type EnvPolicy struct {
+	Allow []string
+	Deny  []string
+}
+
+// essentialEnv globs the variables go and test binaries need to work,
+// passed even when an allowlist leaves them out
+var essentialEnv = []string{
+	"PATH", "HOME", "USER", "LOGNAME", "SHELL", "TMPDIR", "TMP", "TEMP", "LANG", "LC_*", "TZ", "TERM",
+	"GO*", "CGO_*", "CC", "CXX", "PKG_CONFIG*",
+	"SystemRoot", "SYSTEMROOT", "ComSpec", "PATHEXT", "USERPROFILE", "LOCALAPPDATA", "APPDATA", "ProgramData",
+	"SENTINEL_*",
+}
+
+// Validate checks the globs of the policy
+func (p *EnvPolicy) Validate() error {
+	for _, glob := range append(append([]string{}, p.Allow...), p.Deny...) {
+		if _, err := path.Match(glob, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", glob, err)
+		}
+	}
+	return nil
+}
+
+// Passes reports whether a variable is passed to test processes
+func (p *EnvPolicy) Passes(name string) bool {
+	if p == nil {
+		return true
+	}
+	if matchesEnvGlob(p.Deny, name) {
+		return false
+	}
+	return len(p.Allow) == 0 || matchesEnvGlob(p.Allow, name) || matchesEnvGlob(essentialEnv, name)
+}
+
+// Environ returns the environment of go-sentinel as passed to test
+// processes
+func (p *EnvPolicy) Environ() []string {
+	return p.filter(os.Environ())
+}
+
+// filter returns the entries of env the policy passes
+func (p *EnvPolicy) filter(env []string) []string {
+	if p == nil {
+		return env
+	}
+	kept := make([]string, 0, len(env))
+	for _, entry := range env {
+		name, _, _ := strings.Cut(entry, "=")
+		if p.Passes(name) {
+			kept = append(kept, entry)
+		}
+	}
+	return kept
+}
+
+// Withheld returns the names of the variables of go-sentinel's environment
+// the policy keeps from test processes
+func (p *EnvPolicy) Withheld() []string {
+	var names []string
+	for _, entry := range os.Environ() {
+		name, _, _ := strings.Cut(entry, "=")
+		if !p.Passes(name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// matchesEnvGlob reports whether a variable name matches any of globs.
+// Names are case-insensitive on Windows.
+func matchesEnvGlob(globs []string, name string) bool {
+	for _, glob := range globs {
+		if runtime.GOOS == "windows" {
+			glob, name = strings.ToUpper(glob), strings.ToUpper(name)
+		}
+		if ok, _ := path.Match(glob, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEnvPolicy_Filter(t *testing.T) {
+	env := []string{"PATH=/bin", "GOFLAGS=-mod=mod", "CI=true", "AWS_SECRET_ACCESS_KEY=x", "DEPLOY_TOKEN=y", "DATABASE_URL=z"}
+
+	tests := []struct {
+		name   string
+		policy *EnvPolicy
+		want   []string
+	}{
+		{"nil passes all", nil, env},
+		{"deny", &EnvPolicy{Deny: []string{"AWS_*", "*_TOKEN"}},
+			[]string{"PATH=/bin", "GOFLAGS=-mod=mod", "CI=true", "DATABASE_URL=z"}},
+		{"allow keeps essentials", &EnvPolicy{Allow: []string{"CI"}},
+			[]string{"PATH=/bin", "GOFLAGS=-mod=mod", "CI=true"}},
+		{"deny wins over allow", &EnvPolicy{Allow: []string{"CI", "DATABASE_URL"}, Deny: []string{"DATABASE_*"}},
+			[]string{"PATH=/bin", "GOFLAGS=-mod=mod", "CI=true"}},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.filter(env); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: filter() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEnvPolicy_Withheld(t *testing.T) {
+	t.Setenv("SENTINEL_TEST_SECRET", "x")
+	policy := &EnvPolicy{Deny: []string{"SENTINEL_TEST_SECRET"}}
+	if got := policy.Withheld(); !reflect.DeepEqual(got, []string{"SENTINEL_TEST_SECRET"}) {
+		t.Errorf("Withheld() = %q", got)
+	}
+	for _, entry := range policy.Environ() {
+		if entry == "SENTINEL_TEST_SECRET=x" {
+			t.Error("Environ() passed a denied variable")
+		}
+	}
+}
+
+func TestEnvConfig_Validate(t *testing.T) {
+	cfg := &Config{Env: EnvConfig{Deny: []string{"AWS_["}}}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() accepted an invalid pattern")
+	}
+	if (EnvConfig{}).EnvPolicy() != nil {
+		t.Error("empty configuration returned a policy")
+	}
+}
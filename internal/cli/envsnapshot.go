@@ -0,0 +1,190 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// EnvSnapshot describes where the tests of a run ran, so failures seen
+// only in CI can be traced to what differs there
+type EnvSnapshot struct {
+	OS         string            `json:"os"`
+	Arch       string            `json:"arch"`
+	GoVersion  string            `json:"goVersion,omitempty"` // Toolchain the tests ran with
+	GOMAXPROCS int               `json:"gomaxprocs"`
+	NumCPU     int               `json:"numCPU"`
+	Container  string            `json:"container,omitempty"` // Container runtime, such as docker or kubernetes; empty outside one
+	CI         string            `json:"ci,omitempty"`        // CI provider, empty for local runs
+	Vars       map[string]string `json:"vars,omitempty"`      // Variables that change how Go code builds and runs, secrets redacted
+}
+
+// String describes the snapshot on one line, such as "linux/amd64,
+// go1.24.1, GOMAXPROCS=4, docker, github-actions"
+func (e *EnvSnapshot) String() string {
+	parts := []string{e.OS + "/" + e.Arch}
+	if e.GoVersion != "" {
+		parts = append(parts, e.GoVersion)
+	}
+	parts = append(parts, fmt.Sprintf("GOMAXPROCS=%d", e.GOMAXPROCS))
+	if e.Container != "" {
+		parts = append(parts, e.Container)
+	}
+	if e.CI != "" {
+		parts = append(parts, e.CI)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// VarsString lists the variables of the snapshot as NAME=value, by name
+func (e *EnvSnapshot) VarsString() string {
+	names := make([]string, 0, len(e.Vars))
+	for name := range e.Vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		names[i] = name + "=" + e.Vars[name]
+	}
+	return strings.Join(names, " ")
+}
+
+// envSnapshotVars globs the variables kept in snapshots: those that change
+// how Go code is built and run, and the locale and zone tests may depend on
+var envSnapshotVars = []string{"GO*", "CGO_*", "CC", "CXX", "TZ", "LANG", "LC_ALL", "CI"}
+
+// envSnapshotSkip are matching variables left out as machine-specific
+// paths that say nothing about behavior
+var envSnapshotSkip = []string{"GOPATH", "GOROOT", "GOCACHE", "GOMODCACHE", "GOTMPDIR", "GOBIN", "GOENV"}
+
+// secretEnv globs the variable names whose values are never recorded
+var secretEnv = []string{"*TOKEN*", "*SECRET*", "*PASSWORD*", "*PASSWD*", "*KEY*", "*CREDENTIAL*", "*AUTH*"}
+
+// urlUserinfoRe finds the credentials of URLs such as GOPROXY entries
+var urlUserinfoRe = regexp.MustCompile(`://[^/@\s]+@`)
+
+// redactedValue replaces recorded secrets
+const redactedValue = "[redacted]"
+
+// newEnvSnapshot describes the machine go-sentinel runs on and env, the
+// environment of the test processes, whose later entries override
+// earlier ones
+func newEnvSnapshot(env []string, goVersion string) *EnvSnapshot {
+	snap := &EnvSnapshot{
+		OS:         runtime.GOOS,
+		Arch:       runtime.GOARCH,
+		GoVersion:  goVersion,
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		NumCPU:     runtime.NumCPU(),
+		Container:  detectContainer(),
+		CI:         detectCI(),
+	}
+	for _, kv := range env {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || !matchesEnvGlob(envSnapshotVars, name) || containsString(envSnapshotSkip, name) {
+			delete(snap.Vars, name)
+			continue
+		}
+		if snap.Vars == nil {
+			snap.Vars = make(map[string]string)
+		}
+		snap.Vars[name] = scrubEnvValue(name, value)
+	}
+	if n, err := strconv.Atoi(snap.Vars["GOMAXPROCS"]); err == nil && n > 0 {
+		snap.GOMAXPROCS = n
+	}
+	if goos := snap.Vars["GOOS"]; goos != "" {
+		snap.OS = goos
+	}
+	if goarch := snap.Vars["GOARCH"]; goarch != "" {
+		snap.Arch = goarch
+	}
+	return snap
+}
+
+// scrubEnvValue returns the value of a variable as recorded: redacted for
+// names that suggest a secret, and without the credentials of URLs
+func scrubEnvValue(name, value string) string {
+	if matchesEnvGlob(secretEnv, strings.ToUpper(name)) {
+		return redactedValue
+	}
+	return urlUserinfoRe.ReplaceAllString(value, "://"+redactedValue+"@")
+}
+
+// detectContainer returns the container runtime go-sentinel runs in, or
+// an empty string
+func detectContainer() string {
+	switch {
+	case os.Getenv("KUBERNETES_SERVICE_HOST") != "":
+		return "kubernetes"
+	case fileExists("/run/.containerenv"):
+		return "podman"
+	case fileExists("/.dockerenv"):
+		return "docker"
+	}
+	if data, err := os.ReadFile("/proc/1/cgroup"); err == nil {
+		for _, name := range []string{"docker", "kubepods", "containerd", "lxc"} {
+			if strings.Contains(string(data), name) {
+				return name
+			}
+		}
+	}
+	// Set by systemd-nspawn and podman, among others
+	return os.Getenv("container")
+}
+
+// detectCI returns the CI provider go-sentinel runs on, or an empty string
+// for local runs
+func detectCI() string {
+	for _, ci := range []struct{ env, name string }{
+		{"GITHUB_ACTIONS", "github-actions"},
+		{"GITLAB_CI", "gitlab"},
+		{"CIRCLECI", "circleci"},
+		{"BUILDKITE", "buildkite"},
+		{"JENKINS_URL", "jenkins"},
+		{"TF_BUILD", "azure-pipelines"},
+		{"TRAVIS", "travis"},
+		{"TEAMCITY_VERSION", "teamcity"},
+	} {
+		if os.Getenv(ci.env) != "" {
+			return ci.name
+		}
+	}
+	if os.Getenv("CI") != "" {
+		return "ci"
+	}
+	return ""
+}
+
+// fileExists reports whether path exists
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// envSnapshot describes the environment the tests of a run ran in
+func (r *Runner) envSnapshot(opts RunOptions) *EnvSnapshot {
+	env := opts.environ()
+	r.goVersionOnce.Do(func() {
+		r.goVersion = goVersion(r.workDir, env)
+	})
+	return newEnvSnapshot(env, r.goVersion)
+}
+
+// goVersion returns the version of the go command run in workDir with env,
+// or an empty string when it cannot be run
+func goVersion(workDir string, env []string) string {
+	cmd := exec.Command(goBinary, "env", "GOVERSION")
+	cmd.Dir = workDir
+	cmd.Env = env
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,243 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Run event types, in the order a run emits them: run_start, then for
+// each package the start and outcome of each test followed by
+// package_end, and finally run_summary
+const (
+	EventRunStart   = "run_start"
+	EventTestStart  = "test_start"
+	EventTestPass   = "test_pass"
+	EventTestFail   = "test_fail"
+	EventTestSkip   = "test_skip"
+	EventPackageEnd = "package_end"
+	EventRunSummary = "run_summary"
+)
+
+// RunEvent is one step of a run, as seen by every consumer of the run's
+// results. Suite is set on package and test events, Test on test events,
+// and Run and Dirs on run_summary.
+type RunEvent struct {
+	Type  string
+	Time  time.Time
+	Run   *TestRun
+	Suite *TestSuite
+	Test  *TestResult
+	Dirs  map[string]string // Package directories by import path, for reports pointing at files
+}
+
+// EventHandler consumes run events. The renderer and the JSON event
+// stream are both driven this way, so they always report the same run.
+type EventHandler interface {
+	HandleEvent(event RunEvent)
+}
+
+// EventPipeline delivers each run event to its handlers in order
+type EventPipeline []EventHandler
+
+// eventPipeline returns the handlers of a run's events: the renderer,
+// when there is one, followed by opts.Events
+func (opts RunOptions) eventPipeline() EventPipeline {
+	var pipeline EventPipeline
+	if opts.Renderer != nil {
+		pipeline = append(pipeline, opts.Renderer)
+	}
+	return append(pipeline, opts.Events...)
+}
+
+// emit delivers one event to every handler
+func (p EventPipeline) emit(event RunEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	for _, handler := range p {
+		handler.HandleEvent(event)
+	}
+}
+
+// emitResults delivers the test and package events of a parsed run. The
+// test events of each package are delivered in the order they happened,
+// so subtests start and end within their parent test.
+func (p EventPipeline) emitResults(run *TestRun) {
+	for _, suite := range run.Suites {
+		var events []RunEvent
+		for _, test := range suite.Tests {
+			events = append(events, RunEvent{Type: EventTestStart, Time: test.StartTime, Suite: suite, Test: test})
+			var outcome string
+			switch test.Status {
+			case TestStatusPassed:
+				outcome = EventTestPass
+			case TestStatusFailed:
+				outcome = EventTestFail
+			case TestStatusSkipped:
+				outcome = EventTestSkip
+			default:
+				continue // Still running when the package ended, as after a panic
+			}
+			events = append(events, RunEvent{Type: outcome, Time: test.EndTime, Suite: suite, Test: test})
+		}
+		sort.SliceStable(events, func(i, j int) bool {
+			return events[i].Time.Before(events[j].Time)
+		})
+		for _, event := range events {
+			p.emit(event)
+		}
+		p.emit(RunEvent{Type: EventPackageEnd, Time: suite.EndTime, Suite: suite})
+	}
+}
+
+// HandleEvent renders the parts of a run as their events arrive
+func (r *Renderer) HandleEvent(event RunEvent) {
+	switch event.Type {
+	case EventRunStart:
+		r.RenderTestStart(nil)
+	case EventPackageEnd:
+		r.RenderSuite(event.Suite)
+	case EventRunSummary:
+		r.RenderFinalSummary(event.Run)
+	}
+}
+
+// JSONStream writes run events as newline-delimited JSON objects, for
+// other tools to follow a run programmatically. Every object has "type"
+// and "time"; test events add "package", "test", and "elapsed" seconds,
+// and test_fail adds "message", "repro", the shell command rerunning the
+// test alone, and, where known, "file" and "line". package_end has
+// "package", "status", which is timeout for a package stopped at its
+// timeout or the run deadline, and "elapsed", and run_summary has "run",
+// "status", the counts of tests, and "elapsed".
+type JSONStream struct {
+	w  io.Writer
+	mu sync.Mutex
+}
+
+// NewJSONStream returns a stream writing events to w
+func NewJSONStream(w io.Writer) *JSONStream {
+	return &JSONStream{w: w}
+}
+
+// jsonStreamEvent is the JSON form of a run event
+type jsonStreamEvent struct {
+	Type    string    `json:"type"`
+	Time    time.Time `json:"time"`
+	Run     string    `json:"run,omitempty"`
+	Package string    `json:"package,omitempty"`
+	Test    string    `json:"test,omitempty"`
+	Status  string    `json:"status,omitempty"`
+	Message string    `json:"message,omitempty"`
+	File    string    `json:"file,omitempty"`
+	Line    int       `json:"line,omitempty"`
+	Repro   string    `json:"repro,omitempty"`
+	Tests   *int      `json:"tests,omitempty"`
+	Passed  *int      `json:"passed,omitempty"`
+	Failed  *int      `json:"failed,omitempty"`
+	Skipped *int      `json:"skipped,omitempty"`
+	Elapsed *float64  `json:"elapsed,omitempty"`
+}
+
+// HandleEvent writes one event as a line of JSON
+func (s *JSONStream) HandleEvent(event RunEvent) {
+	out := jsonStreamEvent{Type: event.Type, Time: event.Time}
+	if event.Suite != nil {
+		out.Package = suiteName(event.Suite)
+	}
+	switch event.Type {
+	case EventTestPass, EventTestFail, EventTestSkip:
+		out.Test = event.Test.Name
+		out.Elapsed = jsonSeconds(event.Test.Duration)
+		if event.Type == EventTestFail && event.Test.Error != nil {
+			out.Message = failureSummary(event.Test.Error.Message)
+			if loc := event.Test.Error.Location; loc != nil {
+				out.File, out.Line = loc.File, loc.Line
+			}
+		}
+		if event.Type == EventTestFail {
+			out.Repro = event.Test.Repro
+		}
+	case EventTestStart:
+		out.Test = event.Test.Name
+	case EventPackageEnd:
+		out.Status = eventStatus(event.Suite.NumFailed > 0 || event.Suite.SetupFailed, event.Suite.NumPassed > 0)
+		if event.Suite.TimedOut() {
+			out.Status = "timeout"
+		}
+		out.Elapsed = jsonSeconds(event.Suite.Duration)
+		switch {
+		case event.Suite.BuildOutput != "":
+			out.Message = firstCompilerError(event.Suite.BuildOutput)
+		case event.Suite.SetupFailed:
+			out.Message = failureSummary(packageFailureOutput(event.Suite))
+		}
+	case EventRunSummary:
+		// Counted from the tests, since the run's own counters also
+		// include package-level failure lines
+		var tests, passed, failed, skipped int
+		failedPackages := false
+		for _, suite := range event.Run.Suites {
+			for _, test := range suite.Tests {
+				tests++
+				switch test.Status {
+				case TestStatusPassed:
+					passed++
+				case TestStatusFailed:
+					failed++
+				case TestStatusSkipped:
+					skipped++
+				}
+			}
+			failedPackages = failedPackages || suite.NumFailed > 0 || suite.SetupFailed
+		}
+		out.Run = event.Run.ID
+		out.Status = eventStatus(failedPackages, passed > 0)
+		out.Tests, out.Passed, out.Failed, out.Skipped = &tests, &passed, &failed, &skipped
+		out.Elapsed = jsonSeconds(event.Run.Duration)
+	}
+
+	// Unescaped, so reproduction commands read as typed: && and not \u0026
+	var data bytes.Buffer
+	enc := json.NewEncoder(&data)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(out); err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.w.Write(data.Bytes())
+}
+
+// firstCompilerError returns the first error of go build output
+func firstCompilerError(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			return line
+		}
+	}
+	return ""
+}
+
+// eventStatus returns fail when anything failed, pass when anything
+// passed, and skip otherwise
+func eventStatus(failed, passed bool) string {
+	switch {
+	case failed:
+		return "fail"
+	case passed:
+		return "pass"
+	}
+	return "skip"
+}
+
+// jsonSeconds returns a duration in seconds rounded to milliseconds
+func jsonSeconds(d time.Duration) *float64 {
+	seconds := float64(d.Milliseconds()) / 1000
+	return &seconds
+}
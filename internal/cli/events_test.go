@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordedEvents collects the types of the events it handles
+type recordedEvents struct {
+	types []string
+}
+
+func (r *recordedEvents) HandleEvent(event RunEvent) {
+	name := event.Type
+	if event.Test != nil {
+		name += " " + event.Test.Name
+	}
+	r.types = append(r.types, name)
+}
+
+func TestEventPipeline_SharedByRendererAndStream(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+	run := &TestRun{ID: "run-1", Duration: 2 * time.Second, NumFailed: 2, NumPassed: 1, NumTotal: 3, Suites: []*TestSuite{{
+		Package:   "example.com/app",
+		NumFailed: 2,
+		Tests: []*TestResult{
+			{Name: "TestA", Status: TestStatusFailed, StartTime: at(0), EndTime: at(30)},
+			{Name: "TestA/sub", Status: TestStatusFailed, StartTime: at(10), EndTime: at(20), Duration: 10 * time.Millisecond,
+				Error: &TestError{Message: "    a_test.go:12: got 1, want 2\n", Location: &SourceLocation{File: "a_test.go", Line: 12}},
+				Repro: "go test -run '^TestA$/^sub$' -count=1"},
+			{Name: "TestB", Status: TestStatusPassed, StartTime: at(40), EndTime: at(50)},
+		},
+	}}}
+
+	var text, stream bytes.Buffer
+	recorded := &recordedEvents{}
+	opts := RunOptions{Renderer: NewRenderer(&text), Events: []EventHandler{recorded, NewJSONStream(&stream)}}
+	events := opts.eventPipeline()
+	events.emit(RunEvent{Type: EventRunStart})
+	events.emitResults(run)
+	events.emit(RunEvent{Type: EventRunSummary, Run: run})
+
+	want := []string{
+		"run_start", "test_start TestA", "test_start TestA/sub", "test_fail TestA/sub", "test_fail TestA",
+		"test_start TestB", "test_pass TestB", "package_end", "run_summary",
+	}
+	if !reflect.DeepEqual(recorded.types, want) {
+		t.Errorf("events = %q, want %q", recorded.types, want)
+	}
+	if !strings.Contains(text.String(), "Test Files") {
+		t.Errorf("renderer did not render the summary:\n%s", text.String())
+	}
+
+	lines := strings.Split(strings.TrimSpace(stream.String()), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("stream has %d lines, want %d:\n%s", len(lines), len(want), stream.String())
+	}
+	var fail map[string]any
+	if err := json.Unmarshal([]byte(lines[3]), &fail); err != nil {
+		t.Fatal(err)
+	}
+	if fail["type"] != "test_fail" || fail["message"] != "a_test.go:12: got 1, want 2" || fail["file"] != "a_test.go" || fail["line"] != 12.0 ||
+		fail["repro"] != "go test -run '^TestA$/^sub$' -count=1" {
+		t.Errorf("test_fail = %v", fail)
+	}
+	var summary map[string]any
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary["status"] != "fail" || summary["tests"] != 3.0 || summary["failed"] != 2.0 || summary["run"] != "run-1" {
+		t.Errorf("run_summary = %v", summary)
+	}
+}
@@ -0,0 +1,179 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Normalization patterns for failure fingerprints. Order matters: source
+// paths are cut to file names first, and hex and line numbers are
+// rewritten before bare numbers.
+var (
+	fingerprintPath    = regexp.MustCompile(`[^\s:"'()]*[/\\]([^/\\\s:"'()]+\.go)`)
+	fingerprintHex     = regexp.MustCompile(`0x[0-9a-fA-F]+`)
+	fingerprintLine    = regexp.MustCompile(`\.go:\d+`)
+	fingerprintNumber  = regexp.MustCompile(`\d+(\.\d+)?`)
+	fingerprintTempDir = regexp.MustCompile(`(/tmp|/var/folders|\\Temp)[^\s:"']*`)
+	fingerprintSpace   = regexp.MustCompile(`\s+`)
+)
+
+// maxFailureSummary caps the length of the failure line kept in history
+const maxFailureSummary = 120
+
+// FailureFingerprint identifies the underlying cause of a failure so it
+// can be tracked across runs and branches. It hashes the package, the
+// top-level test, and the failure output with volatile details removed:
+// numbers, line numbers, addresses, temporary paths, and goroutine
+// headers. Stack frames are kept by function and file name, so the same
+// panic from the same call path matches even after code moves. Returns ""
+// when the output holds nothing but test framing, as for parents of
+// failed subtests.
+func FailureFingerprint(pkg string, test *TestResult) string {
+	if test.Error == nil {
+		return ""
+	}
+	normalized := normalizeFailure(test.Error.Message)
+	if normalized == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(pkg + "\n" + topLevelTest(test.Name) + "\n" + normalized))
+	return hex.EncodeToString(sum[:6])
+}
+
+// normalizeFailure strips the volatile parts of failure output
+func normalizeFailure(output string) string {
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || isFramingLine(trimmed) {
+			continue
+		}
+		trimmed = fingerprintPath.ReplaceAllString(trimmed, "$1")
+		trimmed = fingerprintTempDir.ReplaceAllString(trimmed, "TMP")
+		trimmed = fingerprintHex.ReplaceAllString(trimmed, "0x")
+		trimmed = fingerprintLine.ReplaceAllString(trimmed, ".go")
+		trimmed = fingerprintNumber.ReplaceAllString(trimmed, "N")
+		trimmed = fingerprintSpace.ReplaceAllString(trimmed, " ")
+		lines = append(lines, trimmed)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// isFramingLine reports whether a line is go test's own framing rather than
+// output describing the failure
+func isFramingLine(line string) bool {
+	if line == "FAIL" || line == "PASS" {
+		return true
+	}
+	for _, prefix := range []string{"=== RUN", "=== PAUSE", "=== CONT", "=== NAME", "--- FAIL", "--- PASS", "--- SKIP", "goroutine ", "FAIL\t", "exit status"} {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// failureSummary returns the first descriptive line of failure output
+func failureSummary(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || isFramingLine(line) {
+			continue
+		}
+		if len(line) > maxFailureSummary {
+			line = line[:maxFailureSummary-1] + "…"
+		}
+		return line
+	}
+	return ""
+}
+
+// FailureIssue is one underlying failure tracked across the runs in history
+type FailureIssue struct {
+	Fingerprint string
+	Package     string
+	Test        string
+	Summary     string // Failure line from the most recent occurrence
+	Occurrences int    // Failing runs
+	FirstSeen   time.Time
+	LastSeen    time.Time
+	Branches    []string // Branches the failure was seen on, where known
+	Envs        []string // Environments the failure was seen in, where recorded
+	Runs        []string // IDs of the runs it occurred in, newest first
+}
+
+// FailureIssues groups the fingerprinted failures in records into issues,
+// most recently seen first
+func FailureIssues(records []*HistoryRecord) []*FailureIssue {
+	byFingerprint := make(map[string]*FailureIssue)
+	for _, rec := range records {
+		for _, pkg := range rec.Packages {
+			for _, test := range pkg.Tests {
+				if test.Fingerprint == "" {
+					continue
+				}
+				issue, ok := byFingerprint[test.Fingerprint]
+				if !ok {
+					issue = &FailureIssue{
+						Fingerprint: test.Fingerprint,
+						Package:     pkg.Package,
+						Test:        topLevelTest(test.Name),
+						FirstSeen:   rec.StartTime,
+					}
+					byFingerprint[test.Fingerprint] = issue
+				}
+				// A run counts once even if several subtests share the cause
+				if len(issue.Runs) > 0 && issue.Runs[0] == rec.ID {
+					continue
+				}
+				issue.Occurrences++
+				issue.Runs = append([]string{rec.ID}, issue.Runs...)
+				if rec.StartTime.Before(issue.FirstSeen) {
+					issue.FirstSeen = rec.StartTime
+				}
+				if !rec.StartTime.Before(issue.LastSeen) {
+					issue.LastSeen = rec.StartTime
+					issue.Summary = test.Failure
+				}
+				if rec.Branch != "" && !containsString(issue.Branches, rec.Branch) {
+					issue.Branches = append(issue.Branches, rec.Branch)
+				}
+				if rec.Env != nil && !containsString(issue.Envs, rec.Env.String()) {
+					issue.Envs = append(issue.Envs, rec.Env.String())
+				}
+			}
+		}
+	}
+
+	issues := make([]*FailureIssue, 0, len(byFingerprint))
+	for _, issue := range byFingerprint {
+		issues = append(issues, issue)
+	}
+	sort.Slice(issues, func(i, j int) bool {
+		if !issues[i].LastSeen.Equal(issues[j].LastSeen) {
+			return issues[i].LastSeen.After(issues[j].LastSeen)
+		}
+		return issues[i].Fingerprint < issues[j].Fingerprint
+	})
+	return issues
+}
+
+// IssuesInRun narrows issues to those occurring in the run identified by
+// id, which may be any unambiguous prefix of a run ID
+func IssuesInRun(issues []*FailureIssue, records []*HistoryRecord, id string) ([]*FailureIssue, error) {
+	rec, err := findRecord(records, id)
+	if err != nil {
+		return nil, err
+	}
+	var result []*FailureIssue
+	for _, issue := range issues {
+		if containsString(issue.Runs, rec.ID) {
+			result = append(result, issue)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFailureFingerprint(t *testing.T) {
+	failure := func(name, message string) *TestResult {
+		return &TestResult{Name: name, Status: TestStatusFailed, Error: &TestError{Message: message}}
+	}
+	panicAt := func(line, addr, dir string) string {
+		return "=== RUN   TestParse\n" +
+			"--- FAIL: TestParse (0.01s)\n" +
+			"panic: runtime error: index out of range [3] with length 3 [recovered]\n" +
+			"goroutine 7 [running]:\n" +
+			"example.com/pkg.parse(0x" + addr + ")\n" +
+			"\t" + dir + "/pkg/parse.go:" + line + " +0x1d\n"
+	}
+
+	base := FailureFingerprint("example.com/pkg", failure("TestParse", panicAt("42", "c000123", "/home/ci/src")))
+	if base == "" {
+		t.Fatal("Expected a fingerprint for a panic")
+	}
+	moved := FailureFingerprint("example.com/pkg", failure("TestParse/case_2", panicAt("57", "c000999", "/Users/dev/code")))
+	if moved != base {
+		t.Errorf("Line numbers, addresses, paths, and subtests should not change the fingerprint: %s != %s", moved, base)
+	}
+	other := FailureFingerprint("example.com/pkg", failure("TestParse", "    parse_test.go:12: want nil error, got EOF\n"))
+	if other == base {
+		t.Error("Different failures should have different fingerprints")
+	}
+	if got := FailureFingerprint("example.com/pkg", failure("TestParse", "=== RUN   TestParse\n--- FAIL: TestParse (0.00s)\n")); got != "" {
+		t.Errorf("Framing-only output should have no fingerprint, got %s", got)
+	}
+}
+
+func TestFailureIssues(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	record := func(id string, hours int, branch string, fingerprints ...string) *HistoryRecord {
+		pkg := &PackageRecord{Package: "example.com/pkg"}
+		for _, fp := range fingerprints {
+			pkg.Tests = append(pkg.Tests, &TestRecord{Name: "TestParse/" + fp, Status: TestStatusFailed, Fingerprint: fp, Failure: "boom " + id})
+		}
+		return &HistoryRecord{ID: id, Branch: branch, StartTime: start.Add(time.Duration(hours) * time.Hour), Packages: []*PackageRecord{pkg}}
+	}
+	records := []*HistoryRecord{
+		record("r1", 0, "main", "aaa", "aaa"), // Two subtests with one cause count once
+		record("r2", 1, "feature", "bbb"),
+		record("r3", 2, "feature", "aaa"),
+		record("r4", 3, "main"),
+	}
+
+	issues := FailureIssues(records)
+	if len(issues) != 2 {
+		t.Fatalf("issues = %+v, want two", issues)
+	}
+	aaa := issues[0]
+	if aaa.Fingerprint != "aaa" || aaa.Occurrences != 2 || aaa.Test != "TestParse" {
+		t.Errorf("issues[0] = %+v, want aaa seen twice in TestParse", aaa)
+	}
+	if !aaa.FirstSeen.Equal(start) || !aaa.LastSeen.Equal(start.Add(2*time.Hour)) || aaa.Summary != "boom r3" {
+		t.Errorf("aaa seen %v to %v with %q, want 12:00 to 14:00 with the latest failure", aaa.FirstSeen, aaa.LastSeen, aaa.Summary)
+	}
+	if len(aaa.Runs) != 2 || aaa.Runs[0] != "r3" || len(aaa.Branches) != 2 {
+		t.Errorf("aaa runs %v branches %v, want [r3 r1] on two branches", aaa.Runs, aaa.Branches)
+	}
+
+	inRun, err := IssuesInRun(issues, records, "r2")
+	if err != nil || len(inRun) != 1 || inRun[0].Fingerprint != "bbb" {
+		t.Errorf("IssuesInRun(r2) = %+v, %v, want only bbb", inRun, err)
+	}
+}
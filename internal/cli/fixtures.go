@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/newbpydev/go-sentinel/pkg/httpreplay"
+	"github.com/newbpydev/go-sentinel/pkg/sentinelio"
+)
+
+// Cassette is an HTTP fixture reported by a test through package httpreplay
+type Cassette struct {
+	Package string
+	Test    string
+	Path    string // Relative to the package directory
+	State   string // httpreplay.StateCreated, StateRefreshed, or StateStale
+}
+
+// CassetteSummary counts the cassettes a run created, refreshed, or found
+// stale
+type CassetteSummary struct {
+	Created   int
+	Refreshed int
+	Stale     []*Cassette
+}
+
+// Empty reports whether the run reported no cassettes
+func (s *CassetteSummary) Empty() bool {
+	return s.Created == 0 && s.Refreshed == 0 && len(s.Stale) == 0
+}
+
+// String describes the counts, e.g. "2 created, 1 stale"
+func (s *CassetteSummary) String() string {
+	var parts []string
+	if s.Created > 0 {
+		parts = append(parts, fmt.Sprintf("%d created", s.Created))
+	}
+	if s.Refreshed > 0 {
+		parts = append(parts, fmt.Sprintf("%d refreshed", s.Refreshed))
+	}
+	if len(s.Stale) > 0 {
+		parts = append(parts, fmt.Sprintf("%d stale", len(s.Stale)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// SummarizeCassettes collects the cassettes reported by the tests of a run
+func SummarizeCassettes(run *TestRun) *CassetteSummary {
+	summary := &CassetteSummary{}
+	for _, suite := range run.Suites {
+		for _, test := range suite.Tests {
+			for _, a := range test.Annotations {
+				if a.Kind != sentinelio.KindCassette {
+					continue
+				}
+				switch a.State {
+				case httpreplay.StateCreated:
+					summary.Created++
+				case httpreplay.StateRefreshed:
+					summary.Refreshed++
+				case httpreplay.StateStale:
+					summary.Stale = append(summary.Stale, &Cassette{Package: suite.Package, Test: test.Name, Path: a.Name, State: a.State})
+				}
+			}
+		}
+	}
+	sort.Slice(summary.Stale, func(i, j int) bool {
+		if summary.Stale[i].Package != summary.Stale[j].Package {
+			return summary.Stale[i].Package < summary.Stale[j].Package
+		}
+		return summary.Stale[i].Path < summary.Stale[j].Path
+	})
+	return summary
+}
+
+// fixtureEnv returns the environment variables setting the httpreplay mode
+// of a run. Missing cassettes are recorded unless ReplayFixtures is set.
+func fixtureEnv(opts RunOptions) []string {
+	mode := httpreplay.ModeRecord
+	if opts.ReplayFixtures {
+		mode = httpreplay.ModeReplay
+	}
+	env := []string{httpreplay.EnvMode + "=" + mode}
+	if opts.RefreshFixtures != "" {
+		env = append(env, httpreplay.EnvRefresh+"="+opts.RefreshFixtures)
+	}
+	return env
+}
@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TestCandidate is a test offered for interactive selection
+type TestCandidate struct {
+	Package string `json:"package"`
+	Test    string `json:"test"`
+}
+
+// text is what a query is matched against, so typing part of the
+// package name narrows the list to its tests
+func (c *TestCandidate) text() string {
+	return c.Package + " " + c.Test
+}
+
+// TestCandidates lists the runnable top-level tests of the catalogs
+func TestCandidates(catalogs []*PackageCatalog) []*TestCandidate {
+	var candidates []*TestCandidate
+	for _, catalog := range catalogs {
+		for _, entry := range catalog.Tests {
+			if isRunnableTest(entry.Name) {
+				candidates = append(candidates, &TestCandidate{Package: catalog.ImportPath, Test: entry.Name})
+			}
+		}
+	}
+	return candidates
+}
+
+// GrepTests returns the runnable tests whose function name matches re,
+// across all packages of the catalogs
+func GrepTests(catalogs []*PackageCatalog, re *regexp.Regexp) []*TestCandidate {
+	var matched []*TestCandidate
+	for _, c := range TestCandidates(catalogs) {
+		if re.MatchString(c.Test) {
+			matched = append(matched, c)
+		}
+	}
+	return matched
+}
+
+// NewTestSelection groups picked tests into the packages and -run
+// patterns to run them with
+func NewTestSelection(picked []*TestCandidate) *TestSelection {
+	sel := &TestSelection{}
+	for _, c := range picked {
+		if !containsString(sel.Packages, c.Package) {
+			sel.Packages = append(sel.Packages, c.Package)
+		}
+		if !containsString(sel.Tests, c.Test) {
+			sel.Tests = append(sel.Tests, c.Test)
+		}
+	}
+	return sel
+}
+
+// fuzzyMatch reports whether the characters of query appear in text in
+// order, ignoring case, with a score favoring consecutive characters and
+// characters starting words. It returns the matched byte offsets of text.
+// Every place the first character occurs is tried, so a package path
+// matching early does not hide a better match in the test name.
+func fuzzyMatch(query, text string) (int, []int, bool) {
+	q := []rune(strings.ToLower(query))
+	if len(q) == 0 {
+		return 0, nil, true
+	}
+	best, bestPositions, found := 0, []int(nil), false
+	prev := rune(0)
+	for start, r := range text {
+		if unicode.ToLower(r) == q[0] {
+			if score, positions, ok := fuzzyMatchFrom(q, text, start, prev); ok && (!found || score > best) {
+				best, bestPositions, found = score, positions, true
+			}
+		}
+		prev = r
+	}
+	return best, bestPositions, found
+}
+
+// fuzzyMatchFrom greedily matches q in text from byte offset start, where
+// before is the character preceding start
+func fuzzyMatchFrom(q []rune, text string, start int, before rune) (int, []int, bool) {
+	score := 0
+	var positions []int
+	prevMatch, prev := -2, before
+	i := 0
+	for offset, r := range text[start:] {
+		offset += start
+		if i < len(q) && unicode.ToLower(r) == q[i] {
+			score++
+			if offset == prevMatch+utf8.RuneLen(prev) {
+				score += 5
+			}
+			if offset == 0 || !unicode.IsLetter(prev) && !unicode.IsDigit(prev) || unicode.IsUpper(r) && unicode.IsLower(prev) {
+				score += 8
+			}
+			positions = append(positions, offset)
+			prevMatch = offset
+			i++
+		}
+		prev = r
+	}
+	if i < len(q) {
+		return 0, nil, false
+	}
+	return score, positions, true
+}
+
+// filterCandidates returns the candidates matching query, best first
+func filterCandidates(candidates []*TestCandidate, query string) []*TestCandidate {
+	if query == "" {
+		return append([]*TestCandidate(nil), candidates...)
+	}
+	type match struct {
+		c     *TestCandidate
+		score int
+	}
+	var matches []match
+	for _, c := range candidates {
+		if score, _, ok := fuzzyMatch(query, c.text()); ok {
+			matches = append(matches, match{c, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return len(matches[i].c.text()) < len(matches[j].c.text())
+	})
+	filtered := make([]*TestCandidate, len(matches))
+	for i, m := range matches {
+		filtered[i] = m.c
+	}
+	return filtered
+}
@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	if _, _, ok := fuzzyMatch("tpa", "example.com/calc TestParse"); !ok {
+		t.Error("tpa should match TestParse")
+	}
+	if _, _, ok := fuzzyMatch("zz", "example.com/calc TestParse"); ok {
+		t.Error("zz should not match")
+	}
+	// Word starts and consecutive characters rank higher than scattered ones
+	boundary, _, _ := fuzzyMatch("parse", "example.com/calc TestParse")
+	scattered, _, _ := fuzzyMatch("parse", "example.com/calc TestPlaceholderScope")
+	if boundary <= scattered {
+		t.Errorf("score of TestParse = %d, want more than TestPlaceholderScope = %d", boundary, scattered)
+	}
+}
+
+func TestFilterCandidates(t *testing.T) {
+	candidates := []*TestCandidate{
+		{Package: "example.com/calc", Test: "TestAdd"},
+		{Package: "example.com/parse", Test: "TestLexer"},
+		{Package: "example.com/calc", Test: "TestParseExpr"},
+	}
+	if got := filterCandidates(candidates, ""); len(got) != 3 {
+		t.Errorf("empty query returned %d candidates, want all 3", len(got))
+	}
+
+	got := filterCandidates(candidates, "parse")
+	if len(got) != 2 || got[0].Test != "TestParseExpr" && got[0].Test != "TestLexer" {
+		t.Fatalf("filterCandidates(parse) = %v, want TestParseExpr and TestLexer", got)
+	}
+
+	// The package name narrows the list too
+	got = filterCandidates(candidates, "calc add")
+	if len(got) != 1 || got[0].Test != "TestAdd" {
+		t.Errorf("filterCandidates(calc add) = %v, want TestAdd", got)
+	}
+}
+
+func TestNewTestSelection(t *testing.T) {
+	sel := NewTestSelection([]*TestCandidate{
+		{Package: "example.com/calc", Test: "TestAdd"},
+		{Package: "example.com/calc", Test: "TestSub"},
+		{Package: "example.com/parse", Test: "TestAdd"},
+	})
+	if want := []string{"example.com/calc", "example.com/parse"}; !reflect.DeepEqual(sel.Packages, want) {
+		t.Errorf("Packages = %v, want %v", sel.Packages, want)
+	}
+	if want := []string{"^TestAdd$", "^TestSub$"}; !reflect.DeepEqual(sel.RunPatterns(), want) {
+		t.Errorf("RunPatterns() = %v, want %v", sel.RunPatterns(), want)
+	}
+}
+
+func TestGrepTests(t *testing.T) {
+	catalogs := []*PackageCatalog{
+		{ImportPath: "example.com/auth", Tests: []*CatalogEntry{{Name: "TestOAuthRefresh"}, {Name: "TestLogin"}, {Name: "BenchmarkOAuthRefresh"}}},
+		{ImportPath: "example.com/api", Tests: []*CatalogEntry{{Name: "TestOAuthTokenRefresh"}}},
+		{ImportPath: "example.com/db", Tests: []*CatalogEntry{{Name: "TestMigrate"}}},
+	}
+	sel := NewTestSelection(GrepTests(catalogs, regexp.MustCompile("OAuth.*Refresh")))
+	if want := []string{"example.com/auth", "example.com/api"}; !reflect.DeepEqual(sel.Packages, want) {
+		t.Errorf("Packages = %v, want %v", sel.Packages, want)
+	}
+	if want := []string{"TestOAuthRefresh", "TestOAuthTokenRefresh"}; !reflect.DeepEqual(sel.Tests, want) {
+		t.Errorf("Tests = %v, want %v", sel.Tests, want)
+	}
+}
+
+func TestPickerModel(t *testing.T) {
+	candidates := []*TestCandidate{
+		{Package: "example.com/calc", Test: "TestAdd"},
+		{Package: "example.com/calc", Test: "TestSub"},
+		{Package: "example.com/calc", Test: "TestMul"},
+	}
+	send := func(m pickerModel, msgs ...tea.Msg) pickerModel {
+		for _, msg := range msgs {
+			next, _ := m.Update(msg)
+			m = next.(pickerModel)
+		}
+		return m
+	}
+
+	// Enter without marks picks the highlighted match
+	m := send(newPickerModel(candidates), tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("sub")}, tea.KeyMsg{Type: tea.KeyEnter})
+	if len(m.picked) != 1 || m.picked[0].Test != "TestSub" {
+		t.Errorf("picked %v, want TestSub", m.picked)
+	}
+
+	// Marked tests are picked in list order
+	m = send(newPickerModel(candidates), tea.KeyMsg{Type: tea.KeyDown}, tea.KeyMsg{Type: tea.KeyTab}, tea.KeyMsg{Type: tea.KeyUp}, tea.KeyMsg{Type: tea.KeyUp}, tea.KeyMsg{Type: tea.KeyTab}, tea.KeyMsg{Type: tea.KeyEnter})
+	if len(m.picked) != 2 || m.picked[0].Test != "TestAdd" || m.picked[1].Test != "TestSub" {
+		t.Errorf("picked %v, want TestAdd and TestSub", m.picked)
+	}
+
+	m = send(newPickerModel(candidates), tea.KeyMsg{Type: tea.KeyEsc})
+	if !m.cancelled || m.picked != nil {
+		t.Errorf("Esc: cancelled = %v, picked = %v", m.cancelled, m.picked)
+	}
+}
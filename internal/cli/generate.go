@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// GenerateStep is a code generation command (go generate, mockgen, protoc, ...)
+// that is rerun whenever one of its input files changes in watch mode
+type GenerateStep struct {
+	Name   string   `json:"name"`
+	Run    []string `json:"run"`           // Command and arguments
+	Inputs []string `json:"inputs"`        // Glob patterns relative to the working directory
+	Dir    string   `json:"dir,omitempty"` // Directory to run in, relative to the working directory
+}
+
+// Matches reports whether a path relative to the working directory is one of
+// the step's inputs
+func (s GenerateStep) Matches(rel string) bool {
+	for _, pattern := range s.Inputs {
+		if matchGlob(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateError reports a failed generation step
+type GenerateError struct {
+	Step   GenerateStep
+	Output string
+	Err    error
+}
+
+// Error implements the error interface
+func (e *GenerateError) Error() string {
+	return fmt.Sprintf("generate step %q failed: %v", e.Step.Name, e.Err)
+}
+
+// Unwrap returns the underlying command error
+func (e *GenerateError) Unwrap() error {
+	return e.Err
+}
+
+// runGenerateSteps runs, in declaration order and with environment env,
+// every step whose inputs include one of the changed files. It stops at the
+// first failing step.
+func (r *Runner) runGenerateSteps(steps []GenerateStep, changedFiles []string, env []string) error {
+	for _, step := range steps {
+		if !r.stepTriggered(step, changedFiles) {
+			continue
+		}
+
+		cmd := exec.Command(step.Run[0], step.Run[1:]...)
+		cmd.Dir = r.workDir
+		if step.Dir != "" {
+			cmd.Dir = absPath(r.workDir, step.Dir)
+		}
+		cmd.Env = env
+
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			return &GenerateError{Step: step, Output: string(output), Err: err}
+		}
+	}
+	return nil
+}
+
+// stepTriggered reports whether any changed file is an input of the step
+func (r *Runner) stepTriggered(step GenerateStep, changedFiles []string) bool {
+	for _, file := range changedFiles {
+		rel, err := filepath.Rel(r.workDir, absPath(r.workDir, file))
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		if step.Matches(filepath.ToSlash(rel)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"*.proto", "api.proto", true},
+		{"*.proto", "proto/api.proto", false},
+		{"proto/*.proto", "proto/api.proto", true},
+		{"**/*.proto", "proto/v1/api.proto", true},
+		{"**/*.proto", "api.proto", true},
+		{"internal/**", "internal/store/store.go", true},
+		{"internal/**/store.go", "internal/store.go", true},
+		{"internal/**/store.go", "internal/a/b/store.go", true},
+		{"internal/**/store.go", "internal/a/b/other.go", false},
+		{"[", "anything", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.path, func(t *testing.T) {
+			if got := matchGlob(tt.pattern, tt.path); got != tt.want {
+				t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunner_RunGenerateSteps(t *testing.T) {
+	dir := t.TempDir()
+	runner, err := NewRunner(dir)
+	if err != nil {
+		t.Fatalf("Failed to create runner: %v", err)
+	}
+	defer runner.Stop()
+
+	steps := []GenerateStep{
+		{Name: "ok", Run: []string{"go", "version"}, Inputs: []string{"**/*.proto"}},
+		{Name: "broken", Run: []string{"go", "no-such-command"}, Inputs: []string{"mocks/*.go"}},
+	}
+
+	// A change matching no inputs runs nothing
+	if err := runner.runGenerateSteps(steps, []string{filepath.Join(dir, "main.go")}, os.Environ()); err != nil {
+		t.Errorf("Expected no error for unrelated change, got: %v", err)
+	}
+
+	// A change matching a working step succeeds
+	if err := runner.runGenerateSteps(steps, []string{filepath.Join(dir, "proto", "api.proto")}, os.Environ()); err != nil {
+		t.Errorf("Expected no error for proto change, got: %v", err)
+	}
+
+	// A change matching a failing step reports a GenerateError
+	err = runner.runGenerateSteps(steps, []string{"mocks/store.go"}, os.Environ())
+	var genErr *GenerateError
+	if !errors.As(err, &genErr) {
+		t.Fatalf("Expected GenerateError, got: %v", err)
+	}
+	if genErr.Step.Name != "broken" {
+		t.Errorf("Step.Name = %s, want broken", genErr.Step.Name)
+	}
+	if genErr.Output == "" {
+		t.Error("Expected command output to be captured")
+	}
+}
+
+func TestRunner_RunGenerateStepsEnv(t *testing.T) {
+	runner, err := NewRunner(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create runner: %v", err)
+	}
+	defer runner.Stop()
+
+	// The go command rejects the run's GOFLAGS, so the step only fails
+	// when it runs with the run's environment
+	steps := []GenerateStep{{Name: "version", Run: []string{"go", "version"}, Inputs: []string{"*.proto"}}}
+	env := append(os.Environ(), "GOFLAGS=-notaflag")
+	var genErr *GenerateError
+	if err := runner.runGenerateSteps(steps, []string{"api.proto"}, env); !errors.As(err, &genErr) {
+		t.Errorf("runGenerateSteps() error = %v, want the step run with the given environment", err)
+	}
+}
+
+func TestRunner_WatchesGenerateInputs(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"main.go", "api.proto", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runner, err := NewRunner(dir)
+	if err != nil {
+		t.Fatalf("Failed to create runner: %v", err)
+	}
+	defer runner.Stop()
+	runner.generate = []GenerateStep{{Name: "protos", Inputs: []string{"*.proto"}}}
+
+	if err := runner.addWatchPaths(); err != nil {
+		t.Fatalf("addWatchPaths() error = %v", err)
+	}
+	var watched []string
+	for _, path := range runner.watcher.WatchList() {
+		watched = append(watched, filepath.Base(path))
+	}
+	sort.Strings(watched)
+	if want := []string{"api.proto", "main.go"}; !reflect.DeepEqual(watched, want) {
+		t.Errorf("watched %v, want %v", watched, want)
+	}
+}
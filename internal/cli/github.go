@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteGitHubAnnotations writes the failures of run as GitHub Actions
+// workflow commands, one ::error per SARIF result, so the job summary and
+// the diff of a pull request show each failure on the line it points at.
+// File names are resolved as in WriteSARIFReport.
+func WriteGitHubAnnotations(w io.Writer, run *TestRun, root string, dirs map[string]string) error {
+	paths := sarifPaths{root: root, dirs: dirs}
+	for _, suite := range run.Suites {
+		for _, result := range paths.suiteResults(suite) {
+			var props []string
+			if len(result.Locations) > 0 {
+				loc := result.Locations[0].PhysicalLocation
+				props = append(props, "file="+githubProperty(loc.ArtifactLocation.URI))
+				if loc.Region != nil {
+					props = append(props, fmt.Sprintf("line=%d", loc.Region.StartLine))
+					if loc.Region.StartColumn > 0 {
+						props = append(props, fmt.Sprintf("col=%d", loc.Region.StartColumn))
+					}
+				}
+			}
+			props = append(props, "title="+githubProperty(sarifRules[result.RuleIndex].description))
+			if _, err := fmt.Fprintf(w, "::error %s::%s\n", strings.Join(props, ","), githubData(result.Message.Text)); err != nil {
+				return fmt.Errorf("failed to write GitHub annotations: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// githubData escapes the message of a workflow command
+func githubData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// githubProperty escapes a property value of a workflow command
+func githubProperty(s string) string {
+	return strings.NewReplacer(":", "%3A", ",", "%2C").Replace(githubData(s))
+}
@@ -0,0 +1,263 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// GreenDirName is the directory inside HistoryDir holding the packages
+// proven green at each commit
+const GreenDirName = "green"
+
+// greenCacheVersion is the format version of green sets
+const greenCacheVersion = 1
+
+// ErrGreenChecksum is returned, wrapped, when a green set does not match
+// its checksum, as when an artifact was truncated or edited
+var ErrGreenChecksum = errors.New("green set does not match its checksum")
+
+// commitRe matches full SHA-1 and SHA-256 commit hashes
+var commitRe = regexp.MustCompile(`^[0-9a-f]{40}([0-9a-f]{24})?$`)
+
+// GreenSet lists the packages whose tests all passed at a commit. Each is
+// stored with a hash of the sources it was tested with, so a package is
+// only skipped where its sources, and those of the module packages it
+// depends on, are byte for byte the same.
+type GreenSet struct {
+	Version  int               `json:"version"`
+	Commit   string            `json:"commit"`
+	Packages map[string]string `json:"packages"` // Source hash by import path
+	Checksum string            `json:"checksum"` // Hash of the commit and packages, verified on import
+}
+
+// checksum hashes the commit and packages of the set
+func (s *GreenSet) checksum() string {
+	paths := make([]string, 0, len(s.Packages))
+	for path := range s.Packages {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\x00%s\x00", s.Version, s.Commit)
+	for _, path := range paths {
+		fmt.Fprintf(h, "%s\x00%s\x00", path, s.Packages[path])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// verify checks that the set is well formed and matches its checksum
+func (s *GreenSet) verify() error {
+	if s.Version != greenCacheVersion {
+		return fmt.Errorf("unsupported green set version %d", s.Version)
+	}
+	if !commitRe.MatchString(s.Commit) {
+		return fmt.Errorf("invalid commit %q", s.Commit)
+	}
+	if s.Checksum != s.checksum() {
+		return ErrGreenChecksum
+	}
+	return nil
+}
+
+// GreenCache keeps the packages proven green at each commit of a module,
+// so CI can publish what it tested and developers can skip those packages
+// at the same commit. Sets are kept in .go-sentinel/green, one per commit.
+type GreenCache struct {
+	sourceHasher
+	dir string
+}
+
+// NewGreenCache creates the green cache of the module in workDir
+func NewGreenCache(workDir string) *GreenCache {
+	return &GreenCache{
+		sourceHasher: newSourceHasher(workDir),
+		dir:          filepath.Join(workDir, HistoryDir, GreenDirName),
+	}
+}
+
+// path returns where the set of commit is kept
+func (c *GreenCache) path(commit string) string {
+	return filepath.Join(c.dir, commit+".json")
+}
+
+// Load returns the set of commit, empty when none was recorded
+func (c *GreenCache) Load(commit string) (*GreenSet, error) {
+	set, err := readGreenSet(c.path(commit))
+	if os.IsNotExist(err) {
+		return &GreenSet{Version: greenCacheVersion, Commit: commit, Packages: make(map[string]string)}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if set.Commit != commit {
+		return nil, fmt.Errorf("failed to load green set: %s holds commit %s", c.path(commit), set.Commit)
+	}
+	return set, nil
+}
+
+// Save writes set, replacing the set of its commit
+func (c *GreenCache) Save(set *GreenSet) error {
+	set.Version = greenCacheVersion
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create green cache directory: %w", err)
+	}
+	return writeGreenSet(set, c.path(set.Commit))
+}
+
+// Export writes the set of commit to dest, returning the number of
+// packages in it
+func (c *GreenCache) Export(commit, dest string) (int, error) {
+	set, err := c.Load(commit)
+	if err != nil {
+		return 0, err
+	}
+	if len(set.Packages) == 0 {
+		return 0, fmt.Errorf("no packages were proven green at %s", commit)
+	}
+	if err := writeGreenSet(set, dest); err != nil {
+		return 0, err
+	}
+	return len(set.Packages), nil
+}
+
+// Import verifies the set in src and merges it into the set of its
+// commit, returning the imported set
+func (c *GreenCache) Import(src string) (*GreenSet, error) {
+	imported, err := readGreenSet(src)
+	if err != nil {
+		return nil, err
+	}
+	set, err := c.Load(imported.Commit)
+	if err != nil {
+		return nil, err
+	}
+	for path, hash := range imported.Packages {
+		set.Packages[path] = hash
+	}
+	if err := c.Save(set); err != nil {
+		return nil, err
+	}
+	return imported, nil
+}
+
+// Filter splits the packages matching patterns into those to run and those
+// proven green at commit, whose sources hash as they did when they passed.
+// Both are returned as import paths.
+func (c *GreenCache) Filter(commit string, patterns, buildFlags []string) (run, skipped []string, err error) {
+	set, err := c.Load(commit)
+	if err != nil {
+		return nil, nil, err
+	}
+	pkgs, graph, err := c.packages(patterns, buildFlags)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, pkg := range pkgs {
+		if hash, ok := set.Packages[pkg.ImportPath]; ok && hash == c.sourceHash(pkg, buildFlags, graph) {
+			skipped = append(skipped, pkg.ImportPath)
+		} else {
+			run = append(run, pkg.ImportPath)
+		}
+	}
+	return run, skipped, nil
+}
+
+// Record adds the packages of run whose tests all ran and passed to the set
+// of commit. Runs narrowed to some tests, or run under a build matrix,
+// prove nothing about whole packages and are ignored, as are runs with
+// uncommitted changes, which would replace the hashes of the commit's
+// sources with those of the edits.
+func (c *GreenCache) Record(commit string, run *TestRun, opts RunOptions) error {
+	if len(opts.Tests) > 0 || len(opts.Requirements) > 0 || len(opts.Matrix) > 0 || !worktreeClean(c.workDir) {
+		return nil
+	}
+	var green []string
+	for _, suite := range run.Suites {
+		if suite.NumFailed == 0 && !suite.SetupFailed && suite.BuildOutput == "" && len(suite.Errors) == 0 {
+			green = append(green, suite.Package)
+		}
+	}
+	if len(green) == 0 {
+		return nil
+	}
+
+	set, err := c.Load(commit)
+	if err != nil {
+		return err
+	}
+	pkgs, graph, err := c.packages(green, opts.BuildFlags)
+	if err != nil {
+		return err
+	}
+	for _, pkg := range pkgs {
+		set.Packages[pkg.ImportPath] = c.sourceHash(pkg, opts.BuildFlags, graph)
+	}
+	return c.Save(set)
+}
+
+// readGreenSet reads and verifies the set in path
+func readGreenSet(path string) (*GreenSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var set GreenSet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if err := set.verify(); err != nil {
+		return nil, fmt.Errorf("failed to verify %s: %w", path, err)
+	}
+	if set.Packages == nil {
+		set.Packages = make(map[string]string)
+	}
+	return &set, nil
+}
+
+// writeGreenSet checksums set and writes it to path
+func writeGreenSet(set *GreenSet, path string) error {
+	set.Checksum = set.checksum()
+	data, err := json.MarshalIndent(set, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode green set: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write green set: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write green set: %w", err)
+	}
+	return nil
+}
+
+// HeadCommit returns the commit checked out in workDir, or an empty string
+// outside a git repository
+func HeadCommit(workDir string) string {
+	cmd := exec.Command("git", "rev-parse", "HEAD")
+	cmd.Dir = workDir
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// worktreeClean reports whether the tracked files in workDir are as
+// committed
+func worktreeClean(workDir string) bool {
+	cmd := exec.Command("git", "status", "--porcelain", "--untracked-files=no")
+	cmd.Dir = workDir
+	out, err := cmd.Output()
+	return err == nil && len(strings.TrimSpace(string(out))) == 0
+}
@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testCommit = "0123456789abcdef0123456789abcdef01234567"
+
+// newTestSourceHasher returns a source hasher of a module with packages a
+// and b, where the tests of b import a
+func newTestSourceHasher(t *testing.T) (sourceHasher, string) {
+	t.Helper()
+	files := map[string]string{
+		"go.mod":      "module example\n",
+		"a/a.go":      "package a\n",
+		"b/b_test.go": "package b\n",
+	}
+	return newTestModuleHasher(t, files, func(dir string) []*PackageInfo {
+		return []*PackageInfo{
+			{ImportPath: "example/a", Dir: filepath.Join(dir, "a")},
+			{ImportPath: "example/b", Dir: filepath.Join(dir, "b"), TestGoFiles: []string{"b_test.go"}, TestImports: []string{"example/a"}},
+		}
+	})
+}
+
+// newTestModuleHasher returns a source hasher of a module of files, whose
+// packages are listed as pkgs returns them for the module's directory
+func newTestModuleHasher(t *testing.T, files map[string]string, pkgs func(dir string) []*PackageInfo) (sourceHasher, string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := sourceHasher{workDir: dir, pkgCache: NewPackageCache(dir, "")}
+	h.pkgCache.list = func(string, []string) ([]*PackageInfo, error) {
+		return pkgs(dir), nil
+	}
+	return h, dir
+}
+
+// newTestGreenCache returns a green cache of the module of
+// newTestSourceHasher
+func newTestGreenCache(t *testing.T) (*GreenCache, string) {
+	t.Helper()
+	h, dir := newTestSourceHasher(t)
+	return &GreenCache{sourceHasher: h, dir: filepath.Join(dir, HistoryDir, GreenDirName)}, dir
+}
+
+func TestGreenCache_Filter(t *testing.T) {
+	c, dir := newTestGreenCache(t)
+	pkgs, graph, err := c.packages(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := &GreenSet{Commit: testCommit, Packages: map[string]string{
+		"example/a": c.sourceHash(pkgs[0], nil, graph),
+		"example/b": c.sourceHash(pkgs[1], nil, graph),
+	}}
+	if err := c.Save(set); err != nil {
+		t.Fatal(err)
+	}
+
+	run, skipped, err := c.Filter(testCommit, nil, nil)
+	if err != nil || len(run) != 0 || len(skipped) != 2 {
+		t.Fatalf("Filter() = %v, %v, %v; want both packages skipped", run, skipped, err)
+	}
+	if run, _, _ := c.Filter(testCommit, nil, []string{"-race"}); len(run) != 2 {
+		t.Errorf("run with other build flags = %v, want both packages", run)
+	}
+
+	// b depends on a, so an edit of a reaches both
+	if err := os.WriteFile(filepath.Join(dir, "a", "a.go"), []byte("package a // edited\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if run, _, _ := c.Filter(testCommit, nil, nil); strings.Join(run, " ") != "example/a example/b" {
+		t.Errorf("run after edit = %v, want both packages", run)
+	}
+}
+
+func TestGreenCache_FilterTestHelperDependencies(t *testing.T) {
+	// The tests of a import the helper tu, whose code imports b, which is
+	// not among a's Deps
+	files := map[string]string{
+		"go.mod":      "module example\n",
+		"a/a_test.go": "package a\n",
+		"tu/tu.go":    "package tu\n",
+		"b/b.go":      "package b\n",
+	}
+	h, dir := newTestModuleHasher(t, files, func(dir string) []*PackageInfo {
+		return []*PackageInfo{
+			{ImportPath: "example/a", Dir: filepath.Join(dir, "a"), TestGoFiles: []string{"a_test.go"}, TestImports: []string{"example/tu"}},
+			{ImportPath: "example/b", Dir: filepath.Join(dir, "b")},
+			{ImportPath: "example/tu", Dir: filepath.Join(dir, "tu"), Imports: []string{"example/b"}, Deps: []string{"example/b"}},
+		}
+	})
+	c := &GreenCache{sourceHasher: h, dir: filepath.Join(dir, HistoryDir, GreenDirName)}
+	pkgs, graph, err := c.packages(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Save(&GreenSet{Commit: testCommit, Packages: map[string]string{"example/a": c.sourceHash(pkgs[0], nil, graph)}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, skipped, _ := c.Filter(testCommit, []string{"./a"}, nil); len(skipped) != 1 {
+		t.Fatalf("skipped = %v, want example/a", skipped)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "b", "b.go"), []byte("package b // edited\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, skipped, _ := c.Filter(testCommit, []string{"./a"}, nil); len(skipped) != 0 {
+		t.Errorf("skipped after editing b = %v, want example/a to run", skipped)
+	}
+}
+
+func TestGreenCache_ImportVerifiesChecksum(t *testing.T) {
+	c, _ := newTestGreenCache(t)
+	artifact := filepath.Join(t.TempDir(), "green.json")
+	if err := writeGreenSet(&GreenSet{Version: greenCacheVersion, Commit: testCommit, Packages: map[string]string{"example/a": "h1"}}, artifact); err != nil {
+		t.Fatal(err)
+	}
+	set, err := c.Import(artifact)
+	if err != nil || len(set.Packages) != 1 {
+		t.Fatalf("Import() = %v, %v; want the set", set, err)
+	}
+
+	data, _ := os.ReadFile(artifact)
+	if err := os.WriteFile(artifact, []byte(strings.Replace(string(data), `"h1"`, `"h2"`, 1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Import(artifact); !errors.Is(err, ErrGreenChecksum) {
+		t.Errorf("Import() of an edited artifact = %v, want ErrGreenChecksum", err)
+	}
+}
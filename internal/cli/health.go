@@ -0,0 +1,225 @@
+package cli
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchEventBuffer is the capacity of the file watcher's event queue
+const watchEventBuffer = 1024
+
+// TriggerRestart marks history records noting a supervised watch restart
+const TriggerRestart = "restart"
+
+// stuckGrace is how long a file modification may go without a watcher
+// event before the watcher is considered stuck
+const stuckGrace = 2 * time.Second
+
+// WatchHealth configures the health checks run periodically in watch mode.
+// When checks fail on FailureThreshold consecutive intervals the watcher is
+// restarted instead of being left to silently degrade.
+type WatchHealth struct {
+	Interval         time.Duration // How often to run the checks
+	FailureThreshold int           // Consecutive failed checks before restarting
+	MaxHeapBytes     uint64        // Heap usage considered unhealthy, 0 to disable
+	MaxQueuedEvents  int           // Pending watcher events considered saturated, 0 to disable
+	DetectStuck      bool          // Compare file modification times against received events
+
+	Restarts        int // Watcher restarts so far, for any reason
+	StuckRecoveries int // Restarts caused by modifications the watcher missed
+
+	failures      int                  // Consecutive failed checks
+	watcherErrors int                  // Watcher errors since the last check
+	mtimes        map[string]time.Time // Go file modification times at the last check
+	events        map[string]time.Time // Time of the last event received per file
+}
+
+// DefaultWatchHealth returns the default watch health policy
+func DefaultWatchHealth() *WatchHealth {
+	return &WatchHealth{
+		Interval:         30 * time.Second,
+		FailureThreshold: 3,
+		MaxHeapBytes:     1 << 30,
+		MaxQueuedEvents:  watchEventBuffer * 9 / 10,
+		DetectStuck:      true,
+	}
+}
+
+// recordEvent notes that the watcher reported a change to path
+func (h *WatchHealth) recordEvent(path string, at time.Time) {
+	if h.events == nil {
+		h.events = make(map[string]time.Time)
+	}
+	h.events[path] = at
+}
+
+// missedChanges returns the Go files below workDir that were modified since
+// the last check without the watcher reporting them, as happens when editors
+// replace files by swapping inodes or inotify watches are dropped. The first
+// call only records a baseline.
+func (h *WatchHealth) missedChanges(workDir string, now time.Time) []string {
+	if !h.DetectStuck {
+		return nil
+	}
+	current := scanGoFiles(workDir)
+	if h.mtimes == nil {
+		h.mtimes = current
+		return nil
+	}
+
+	var missed []string
+	for path, mtime := range current {
+		prev, ok := h.mtimes[path]
+		if ok && prev.Equal(mtime) {
+			continue
+		}
+		if now.Sub(mtime) < stuckGrace {
+			// The event may still be queued; look again at the next check
+			current[path] = prev
+			if !ok {
+				delete(current, path)
+			}
+			continue
+		}
+		// Events follow the write, but mtime granularity varies by filesystem
+		if event, ok := h.events[path]; !ok || event.Before(mtime.Add(-time.Second)) {
+			missed = append(missed, path)
+		}
+	}
+	h.mtimes = current
+	sort.Strings(missed)
+	return missed
+}
+
+// scanGoFiles returns the modification times of the Go files the watcher
+// covers below workDir
+func scanGoFiles(workDir string) map[string]time.Time {
+	mtimes := make(map[string]time.Time)
+	err := filepath.WalkDir(workDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != workDir && (strings.HasPrefix(d.Name(), ".") || d.Name() == "vendor") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(d.Name(), ".go") {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			mtimes[path] = info.ModTime()
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error scanning files for watch health: %v", err)
+	}
+	return mtimes
+}
+
+// recordWatcherError counts an error reported by the file watcher
+func (h *WatchHealth) recordWatcherError() {
+	h.watcherErrors++
+}
+
+// check runs all health checks and returns the problems found. It reports
+// restart as true once problems have persisted for FailureThreshold checks.
+func (h *WatchHealth) check(watcher *fsnotify.Watcher) (problems []string, restart bool) {
+	if h.watcherErrors > 0 {
+		problems = append(problems, fmt.Sprintf("watcher stalled: %d %s since last check",
+			h.watcherErrors, pluralize("error", h.watcherErrors)))
+	}
+	h.watcherErrors = 0
+
+	if h.MaxHeapBytes > 0 {
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		if m.HeapAlloc > h.MaxHeapBytes {
+			problems = append(problems, fmt.Sprintf("memory above threshold: %s > %s",
+				formatBytes(m.HeapAlloc), formatBytes(h.MaxHeapBytes)))
+		}
+	}
+
+	if h.MaxQueuedEvents > 0 && len(watcher.Events) >= h.MaxQueuedEvents {
+		problems = append(problems, fmt.Sprintf("event queue saturated: %d of %d pending",
+			len(watcher.Events), cap(watcher.Events)))
+	}
+
+	if len(problems) == 0 {
+		h.failures = 0
+		return nil, false
+	}
+	h.failures++
+	if h.failures >= h.FailureThreshold {
+		h.failures = 0
+		return problems, true
+	}
+	return problems, false
+}
+
+// newWatcher creates a file watcher with a buffered event queue
+func newWatcher() (*fsnotify.Watcher, error) {
+	watcher, err := fsnotify.NewBufferedWatcher(watchEventBuffer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	return watcher, nil
+}
+
+// restartWatcher replaces the file watcher and drops in-memory caches while
+// preserving run options, build tags, and history. The reason is noted in
+// history.
+func (r *Runner) restartWatcher(opts RunOptions, problems []string) error {
+	watcher, err := newWatcher()
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	old := r.watcher
+	r.watcher = watcher
+	if err := old.Close(); err != nil {
+		r.mu.Unlock()
+		return fmt.Errorf("failed to close watcher: %w", err)
+	}
+	if err := r.addWatchPaths(); err != nil {
+		r.mu.Unlock()
+		return err
+	}
+
+	// Drop cached state that may have grown or gone stale; it is rebuilt lazily
+	r.pkgCache.release()
+	r.mu.Unlock()
+	debug.FreeOSMemory()
+
+	reason := strings.Join(problems, "; ")
+	if opts.Health != nil {
+		opts.Health.Restarts++
+	}
+	log.Printf("Watcher re-initialized: %s", reason)
+	if opts.Renderer != nil {
+		opts.Renderer.RenderWatchRestart(reason)
+	}
+	if opts.History != nil {
+		note := &HistoryRecord{
+			ID:        newRunID(),
+			Trigger:   TriggerRestart,
+			StartTime: time.Now(),
+			Note:      "watch restarted: " + reason,
+		}
+		if err := opts.History.Append(note); err != nil {
+			return err
+		}
+	}
+	return nil
+}
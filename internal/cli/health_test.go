@@ -0,0 +1,166 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestWatchHealth_Check(t *testing.T) {
+	watcher, err := fsnotify.NewBufferedWatcher(4)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	defer watcher.Close()
+
+	health := &WatchHealth{FailureThreshold: 2}
+
+	if problems, restart := health.check(watcher); len(problems) != 0 || restart {
+		t.Errorf("Healthy check = %v, %v, want no problems", problems, restart)
+	}
+
+	health.recordWatcherError()
+	problems, restart := health.check(watcher)
+	if len(problems) != 1 || restart {
+		t.Fatalf("First failed check = %v, %v, want one problem without restart", problems, restart)
+	}
+	if !strings.Contains(problems[0], "watcher stalled") {
+		t.Errorf("Problem = %q, want watcher stalled", problems[0])
+	}
+
+	health.recordWatcherError()
+	if _, restart := health.check(watcher); !restart {
+		t.Error("Expected restart after reaching the failure threshold")
+	}
+
+	// A healthy check in between resets the count
+	health.recordWatcherError()
+	health.check(watcher)
+	health.check(watcher)
+	health.recordWatcherError()
+	if _, restart := health.check(watcher); restart {
+		t.Error("Expected consecutive failure count to reset after a healthy check")
+	}
+
+	health = &WatchHealth{FailureThreshold: 1, MaxHeapBytes: 1}
+	problems, restart = health.check(watcher)
+	if !restart || len(problems) != 1 || !strings.Contains(problems[0], "memory above threshold") {
+		t.Errorf("Heap check = %v, %v, want memory problem and restart", problems, restart)
+	}
+}
+
+func TestHealthConfig_WatchHealth(t *testing.T) {
+	if h := (HealthConfig{Disabled: true}).WatchHealth(); h != nil {
+		t.Errorf("Disabled config = %+v, want nil", h)
+	}
+
+	h := (HealthConfig{IntervalSeconds: 5, MaxHeapMB: 256}).WatchHealth()
+	def := DefaultWatchHealth()
+	if h.Interval.Seconds() != 5 {
+		t.Errorf("Interval = %v, want 5s", h.Interval)
+	}
+	if h.MaxHeapBytes != 256<<20 {
+		t.Errorf("MaxHeapBytes = %d, want %d", h.MaxHeapBytes, 256<<20)
+	}
+	if h.FailureThreshold != def.FailureThreshold {
+		t.Errorf("FailureThreshold = %d, want default %d", h.FailureThreshold, def.FailureThreshold)
+	}
+}
+
+func TestRunner_RestartWatcher(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	runner, err := NewRunner(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to create runner: %v", err)
+	}
+	defer runner.Stop()
+
+	runner.SetBuildTags([]string{"integration"})
+	cache := runner.pkgCache
+
+	old := runner.watcher
+	var buf bytes.Buffer
+	opts := RunOptions{
+		Renderer: NewRendererWithStyle(&buf, false),
+		History:  NewHistoryStore(tmpDir),
+	}
+	if err := runner.restartWatcher(opts, []string{"watcher stalled"}); err != nil {
+		t.Fatalf("restartWatcher failed: %v", err)
+	}
+
+	if runner.watcher == old {
+		t.Error("Expected watcher to be replaced")
+	}
+	if len(runner.watcher.WatchList()) == 0 {
+		t.Error("Expected watch paths to be re-added")
+	}
+	if runner.pkgCache != cache || runner.pkgCache.tags != "integration" {
+		t.Errorf("package cache tags = %q, want the integration tag kept", runner.pkgCache.tags)
+	}
+	if !strings.Contains(buf.String(), "watcher stalled") {
+		t.Errorf("Output %q does not mention the restart reason", buf.String())
+	}
+
+	records, err := opts.History.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(records) != 1 || records[0].Trigger != TriggerRestart || !strings.Contains(records[0].Note, "watcher stalled") {
+		t.Errorf("History = %+v, want one restart note", records)
+	}
+}
+
+func TestWatchHealth_MissedChanges(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.go")
+	other := filepath.Join(dir, "b.go")
+	for _, path := range []string{file, other} {
+		if err := os.WriteFile(path, []byte("package a\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+
+	health := DefaultWatchHealth()
+	now := time.Now()
+	if missed := health.missedChanges(dir, now); len(missed) != 0 {
+		t.Fatalf("First check = %v, want a baseline only", missed)
+	}
+
+	// Both files change, only one is reported by the watcher
+	modified := now.Add(time.Minute)
+	for _, path := range []string{file, other} {
+		if err := os.Chtimes(path, modified, modified); err != nil {
+			t.Fatalf("Failed to touch file: %v", err)
+		}
+	}
+	health.recordEvent(other, modified)
+
+	// Too recent to judge: the event may still be queued
+	if missed := health.missedChanges(dir, modified.Add(time.Second)); len(missed) != 0 {
+		t.Errorf("Check within grace period = %v, want none", missed)
+	}
+	missed := health.missedChanges(dir, modified.Add(time.Minute))
+	if len(missed) != 1 || missed[0] != file {
+		t.Errorf("missedChanges = %v, want [%s]", missed, file)
+	}
+	if missed := health.missedChanges(dir, modified.Add(2*time.Minute)); len(missed) != 0 {
+		t.Errorf("Repeated check = %v, want changes reported once", missed)
+	}
+
+	health.DetectStuck = false
+	if err := os.Chtimes(file, now, now); err != nil {
+		t.Fatalf("Failed to touch file: %v", err)
+	}
+	if missed := health.missedChanges(dir, modified.Add(3*time.Minute)); len(missed) != 0 {
+		t.Errorf("Disabled detection = %v, want none", missed)
+	}
+}
@@ -0,0 +1,562 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HistoryDir is the project-local directory holding go-sentinel state
+const HistoryDir = ".go-sentinel"
+
+// HistoryFileName is the run history file inside HistoryDir
+const HistoryFileName = "history.jsonl"
+
+// Run triggers recorded in history
+const (
+	TriggerRun   = "run"
+	TriggerWatch = "watch"
+)
+
+// HistoryRecord is a stored summary of a single test run
+type HistoryRecord struct {
+	ID          string           `json:"id"`
+	Trigger     string           `json:"trigger"`
+	Label       string           `json:"label,omitempty"`  // Source of imported results, such as "ci"
+	Seed        int64            `json:"seed,omitempty"`   // Seed to pass to --seed to reproduce the run
+	Branch      string           `json:"branch,omitempty"` // Branch tested, where known
+	StartTime   time.Time        `json:"startTime"`
+	Duration    time.Duration    `json:"duration"`
+	NumTotal    int              `json:"numTotal"`
+	NumPassed   int              `json:"numPassed"`
+	NumFailed   int              `json:"numFailed"`
+	NumSkipped  int              `json:"numSkipped"`
+	TestFuncs   int              `json:"testFuncs"`            // Top-level test functions across all packages
+	Assertions  int              `json:"assertions,omitempty"` // Reported assertions, where detectable
+	Packages    []*PackageRecord `json:"packages"`
+	RepeatCount int              `json:"repeatCount,omitempty"` // Identical runs collapsed into this record
+	LastRunAt   time.Time        `json:"lastRunAt,omitempty"`   // Start of the most recent collapsed run
+	Note        string           `json:"note,omitempty"`        // Free-text note from the user, or a description of a non-run event
+	Bookmarked  bool             `json:"bookmarked,omitempty"`  // Pinned in listings and exempt from retention
+	Env         *EnvSnapshot     `json:"env,omitempty"`         // Environment the tests ran in
+
+	Coverage      map[string]float64 `json:"coverage,omitempty"`      // Statement coverage percentage by file, when collected
+	CoverageTotal float64            `json:"coverageTotal,omitempty"` // Statement coverage percentage of all files, valid when Coverage is set
+}
+
+// PackageRecord is the stored result of one package in a run
+type PackageRecord struct {
+	Package    string        `json:"package"`
+	Duration   time.Duration `json:"duration"`
+	TestFuncs  int           `json:"testFuncs"`
+	Assertions int           `json:"assertions,omitempty"`
+	Budget     time.Duration `json:"budget,omitempty"` // Duration budget in force when the run was recorded
+	Tests      []*TestRecord `json:"tests"`
+	Benchmarks []*Benchmark  `json:"benchmarks,omitempty"` // Results of go test -bench
+}
+
+// OverBudget reports whether the package ran longer than its budget
+func (p *PackageRecord) OverBudget() bool {
+	return p.Budget > 0 && p.Duration > p.Budget
+}
+
+// TestRecord is the stored result of one test in a run
+type TestRecord struct {
+	Name        string        `json:"name"`
+	Status      TestStatus    `json:"status"`
+	Duration    time.Duration `json:"duration"`
+	Fingerprint string        `json:"fingerprint,omitempty"` // Identity of the failure's cause, see FailureFingerprint
+	Failure     string        `json:"failure,omitempty"`     // First line of the failure output
+
+	Requirements []string `json:"requirements,omitempty"` // Requirements the test traces to
+}
+
+// NewHistoryRecord summarizes a completed run for storage
+func NewHistoryRecord(run *TestRun, trigger string) *HistoryRecord {
+	rec := &HistoryRecord{
+		ID:         run.ID,
+		Seed:       run.Seed,
+		Trigger:    trigger,
+		Env:        run.Env,
+		StartTime:  run.StartTime,
+		Duration:   run.Duration,
+		NumTotal:   run.NumTotal,
+		NumPassed:  run.NumPassed,
+		NumFailed:  run.NumFailed,
+		NumSkipped: run.NumSkipped,
+	}
+	if run.Coverage != nil {
+		rec.Coverage = run.Coverage
+		rec.CoverageTotal = run.CoverageTotal
+	}
+	for _, suite := range run.Suites {
+		pkg := &PackageRecord{
+			Package:    suite.Package,
+			Duration:   suite.Duration,
+			TestFuncs:  suite.NumTestFuncs(),
+			Assertions: suite.NumAssertions(),
+			Budget:     suite.Budget,
+			Benchmarks: suite.Benchmarks,
+		}
+		rec.TestFuncs += pkg.TestFuncs
+		rec.Assertions += pkg.Assertions
+		for _, test := range suite.Tests {
+			tr := &TestRecord{Name: test.Name, Status: test.Status, Duration: test.Duration, Requirements: test.Requirements}
+			if test.Status == TestStatusFailed {
+				tr.Fingerprint = FailureFingerprint(suite.Package, test)
+				if tr.Fingerprint != "" {
+					tr.Failure = failureSummary(test.Error.Message)
+				}
+			}
+			pkg.Tests = append(pkg.Tests, tr)
+		}
+		rec.Packages = append(rec.Packages, pkg)
+	}
+	return rec
+}
+
+// Signature fingerprints the set of tests, their outcomes, and assertion
+// counts, ignoring timing
+func (h *HistoryRecord) Signature() string {
+	var lines []string
+	for _, pkg := range h.Packages {
+		lines = append(lines, fmt.Sprintf("%s\t\t%d", pkg.Package, pkg.Assertions))
+		for _, test := range pkg.Tests {
+			lines = append(lines, fmt.Sprintf("%s\t%s\t%d", pkg.Package, test.Name, test.Status))
+		}
+	}
+	sort.Strings(lines)
+
+	sum := sha256.New()
+	for _, line := range lines {
+		sum.Write([]byte(line))
+		sum.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// Fingerprints returns the distinct failure fingerprints in the run, in
+// result order
+func (h *HistoryRecord) Fingerprints() []string {
+	var fingerprints []string
+	for _, pkg := range h.Packages {
+		for _, test := range pkg.Tests {
+			if test.Fingerprint != "" && !containsString(fingerprints, test.Fingerprint) {
+				fingerprints = append(fingerprints, test.Fingerprint)
+			}
+		}
+	}
+	return fingerprints
+}
+
+// OverBudget returns the packages of the run that exceeded their duration
+// budgets
+func (h *HistoryRecord) OverBudget() []*PackageRecord {
+	var over []*PackageRecord
+	for _, pkg := range h.Packages {
+		if pkg.OverBudget() {
+			over = append(over, pkg)
+		}
+	}
+	return over
+}
+
+// Runs returns the number of runs this record stands for
+func (h *HistoryRecord) Runs() int {
+	return h.RepeatCount + 1
+}
+
+// HistoryBackend stores run records. HistoryStore keeps them in a JSON
+// lines file inside the project, SQLiteHistory in a database file, and
+// PostgresHistory in a shared database.
+type HistoryBackend interface {
+	// Append stores a run record, applying compaction and retention
+	Append(rec *HistoryRecord) error
+	// Load returns all stored records, oldest first
+	Load() ([]*HistoryRecord, error)
+	// Update applies fn to the record identified by an ID or unambiguous
+	// ID prefix and saves the result
+	Update(id string, fn func(*HistoryRecord)) (*HistoryRecord, error)
+	// Prune drops the oldest records that are not bookmarked until at most
+	// max remain
+	Prune(max int) (int, error)
+	// Close releases any resources held by the backend
+	Close() error
+}
+
+// OpenHistory returns the history backend selected by cfg for the project
+// rooted at dir, with the retention and time zone settings applied
+func OpenHistory(dir string, cfg HistoryConfig, loc *time.Location) (HistoryBackend, error) {
+	switch cfg.Backend {
+	case "", HistoryBackendFile:
+		store := NewHistoryStore(dir)
+		store.CompactWatchRuns = !cfg.KeepRepeats
+		store.MaxRuns = cfg.MaxRuns
+		store.Location = loc
+		return store, nil
+	case HistoryBackendSQLite:
+		path := filepath.Join(dir, HistoryDir, SQLiteHistoryFileName)
+		if cfg.SQLitePath != "" {
+			path = absPath(dir, cfg.SQLitePath)
+		}
+		store, err := OpenSQLiteHistory(path, historyProject(dir, cfg))
+		if err != nil {
+			return nil, err
+		}
+		store.CompactWatchRuns = !cfg.KeepRepeats
+		store.MaxRuns = cfg.MaxRuns
+		store.Location = loc
+		return store, nil
+	case HistoryBackendPostgres:
+		dsn := cfg.PostgresURL
+		if dsn == "" {
+			dsn = os.Getenv(EnvPostgresURL)
+		}
+		if dsn == "" {
+			return nil, fmt.Errorf("history: postgresURL or %s is required for the postgres backend", EnvPostgresURL)
+		}
+		store, err := OpenPostgresHistory(dsn, historyProject(dir, cfg))
+		if err != nil {
+			return nil, err
+		}
+		store.CompactWatchRuns = !cfg.KeepRepeats
+		store.MaxRuns = cfg.MaxRuns
+		store.Location = loc
+		return store, nil
+	}
+	return nil, fmt.Errorf("history: unknown backend %q", cfg.Backend)
+}
+
+// historyProject returns the name separating the project's runs in a
+// database, defaulting to the name of its directory
+func historyProject(dir string, cfg HistoryConfig) string {
+	if cfg.Project != "" {
+		return cfg.Project
+	}
+	return filepath.Base(dir)
+}
+
+// HistoryStore persists run records as JSON lines
+type HistoryStore struct {
+	path string
+
+	// MaxRuns caps the number of stored records. When exceeded, the oldest
+	// records that are not bookmarked are dropped. Zero keeps everything.
+	MaxRuns int
+
+	// CompactWatchRuns collapses consecutive identical all-green watch runs
+	// into a single record with a repeat count. Failures and transitions
+	// are always kept as separate records.
+	CompactWatchRuns bool
+
+	// Location is the time zone timestamps are stored in. Stored times
+	// always carry their UTC offset; nil keeps local time.
+	Location *time.Location
+}
+
+// NewHistoryStore creates a store for the project rooted at dir
+func NewHistoryStore(dir string) *HistoryStore {
+	return &HistoryStore{
+		path:             filepath.Join(dir, HistoryDir, HistoryFileName),
+		CompactWatchRuns: true,
+	}
+}
+
+// Path returns the location of the history file
+func (s *HistoryStore) Path() string {
+	return s.path
+}
+
+// Close is a no-op; the history file is only held open during writes
+func (s *HistoryStore) Close() error {
+	return nil
+}
+
+// Append stores a run record, collapsing it into the previous record when
+// the compaction policy allows, and applies the retention limit
+func (s *HistoryStore) Append(rec *HistoryRecord) error {
+	if err := s.appendRecord(rec); err != nil {
+		return err
+	}
+	if s.MaxRuns > 0 {
+		if _, err := s.Prune(s.MaxRuns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendRecord writes rec at the end of the history file
+func (s *HistoryStore) appendRecord(rec *HistoryRecord) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	f, err := os.OpenFile(s.path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open history: %w", err)
+	}
+	defer f.Close()
+
+	last, offset, err := readLastRecord(f)
+	if err != nil {
+		return err
+	}
+
+	if s.Location != nil {
+		rec.StartTime = rec.StartTime.In(s.Location)
+	}
+
+	if s.CompactWatchRuns && last != nil && canCompact(last, rec) {
+		last.RepeatCount += rec.Runs()
+		last.LastRunAt = rec.StartTime
+		if err := f.Truncate(offset); err != nil {
+			return fmt.Errorf("failed to compact history: %w", err)
+		}
+		rec = last
+	}
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to encode history record: %w", err)
+	}
+	end, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	if _, err := f.WriteAt(append(data, '\n'), end); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
+
+// Update applies fn to the record identified by id, which may be any
+// unambiguous prefix of a run ID, and saves the result
+func (s *HistoryStore) Update(id string, fn func(*HistoryRecord)) (*HistoryRecord, error) {
+	records, err := s.Load()
+	if err != nil {
+		return nil, err
+	}
+	rec, err := findRecord(records, id)
+	if err != nil {
+		return nil, err
+	}
+	fn(rec)
+	if err := s.save(records); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// Prune drops the oldest records that are not bookmarked until at most max
+// remain, and returns how many were removed. Bookmarked records are always
+// kept, even when they alone exceed max.
+func (s *HistoryStore) Prune(max int) (int, error) {
+	records, err := s.Load()
+	if err != nil {
+		return 0, err
+	}
+	excess := len(records) - max
+	if excess <= 0 {
+		return 0, nil
+	}
+
+	kept := make([]*HistoryRecord, 0, max)
+	removed := 0
+	for _, rec := range records {
+		if removed < excess && !rec.Bookmarked {
+			removed++
+			continue
+		}
+		kept = append(kept, rec)
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, s.save(kept)
+}
+
+// save atomically replaces the history file with records
+func (s *HistoryStore) save(records []*HistoryRecord) error {
+	var buf bytes.Buffer
+	for _, rec := range records {
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return fmt.Errorf("failed to encode history record: %w", err)
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
+
+// findRecord returns the record whose ID is id or, failing that, the only
+// record whose ID starts with id
+func findRecord(records []*HistoryRecord, id string) (*HistoryRecord, error) {
+	if id == "" {
+		return nil, fmt.Errorf("run ID is required")
+	}
+	var match *HistoryRecord
+	for _, rec := range records {
+		if rec.ID == id {
+			return rec, nil
+		}
+		if strings.HasPrefix(rec.ID, id) {
+			if match != nil {
+				return nil, fmt.Errorf("run ID %q is ambiguous", id)
+			}
+			match = rec
+		}
+	}
+	if match == nil {
+		return nil, fmt.Errorf("no run with ID %q in history", id)
+	}
+	return match, nil
+}
+
+// RecordByID returns the record identified by id, which may be any
+// unambiguous prefix of a run ID, or the latest record when id is empty
+func RecordByID(records []*HistoryRecord, id string) (*HistoryRecord, error) {
+	if id != "" {
+		return findRecord(records, id)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no runs in history")
+	}
+	return records[len(records)-1], nil
+}
+
+// LatestOnBranch returns the most recent run of a branch, or nil when the
+// branch has none. Restart notes are not runs and are skipped.
+func LatestOnBranch(records []*HistoryRecord, branch string) *HistoryRecord {
+	for i := len(records) - 1; i >= 0; i-- {
+		if records[i].Branch == branch && records[i].Trigger != TriggerRestart {
+			return records[i]
+		}
+	}
+	return nil
+}
+
+// SortPinned orders records for display: bookmarked records first, then
+// the rest, each group newest first
+func SortPinned(records []*HistoryRecord) {
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].Bookmarked != records[j].Bookmarked {
+			return records[i].Bookmarked
+		}
+		return records[i].StartTime.After(records[j].StartTime)
+	})
+}
+
+// Load returns all stored records, oldest first
+func (s *HistoryStore) Load() ([]*HistoryRecord, error) {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+	defer f.Close()
+
+	var records []*HistoryRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var rec HistoryRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue // Skip corrupt lines rather than losing all history
+		}
+		records = append(records, &rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading history: %w", err)
+	}
+	return records, nil
+}
+
+// canCompact reports whether rec repeats last: both are watch runs, both
+// are fully green, and they ran the same tests with the same outcomes. Runs
+// the user has annotated are never merged into, and runs with benchmark
+// results are kept for their measurements.
+func canCompact(last, rec *HistoryRecord) bool {
+	return last.Trigger == TriggerWatch && rec.Trigger == TriggerWatch &&
+		!last.Bookmarked && last.Note == "" &&
+		last.NumFailed == 0 && rec.NumFailed == 0 &&
+		!last.hasBenchmarks() && !rec.hasBenchmarks() &&
+		last.Signature() == rec.Signature()
+}
+
+// hasBenchmarks reports whether the run recorded benchmark results
+func (h *HistoryRecord) hasBenchmarks() bool {
+	for _, pkg := range h.Packages {
+		if len(pkg.Benchmarks) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// readLastRecord returns the final record in the file and the offset at
+// which its line starts
+func readLastRecord(f *os.File) (*HistoryRecord, int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read history: %w", err)
+	}
+	size := info.Size()
+	if size == 0 {
+		return nil, 0, nil
+	}
+
+	// Scan backwards in chunks for the newline preceding the last line
+	const chunk = 4096
+	end := size
+	last := make([]byte, 1)
+	if _, err := f.ReadAt(last, size-1); err == nil && last[0] == '\n' {
+		end = size - 1
+	}
+	start := int64(0)
+	for pos := end; pos > 0; {
+		n := int64(chunk)
+		if pos < n {
+			n = pos
+		}
+		buf := make([]byte, n)
+		if _, err := f.ReadAt(buf, pos-n); err != nil {
+			return nil, 0, fmt.Errorf("failed to read history: %w", err)
+		}
+		if idx := bytes.LastIndexByte(buf, '\n'); idx >= 0 {
+			start = pos - n + int64(idx) + 1
+			break
+		}
+		pos -= n
+	}
+
+	line := make([]byte, end-start)
+	if _, err := f.ReadAt(line, start); err != nil {
+		return nil, 0, fmt.Errorf("failed to read history: %w", err)
+	}
+	var rec HistoryRecord
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return nil, size, nil // Never merge into a corrupt line
+	}
+	return &rec, start, nil
+}
@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq" // Also registers the "postgres" database/sql driver
+)
+
+// postgresMigrations create and evolve the history schema. Each entry is
+// applied once, in order, and recorded in sentinel_schema_migrations;
+// append new steps rather than editing released ones.
+var postgresMigrations = []string{
+	// 1: runs, keyed by project so one database can serve many projects,
+	// with the full record kept alongside columns for querying
+	`CREATE TABLE sentinel_runs (
+		seq          BIGSERIAL PRIMARY KEY,
+		project      TEXT NOT NULL,
+		id           TEXT NOT NULL,
+		trigger      TEXT NOT NULL,
+		label        TEXT NOT NULL DEFAULT '',
+		start_time   TIMESTAMPTZ NOT NULL,
+		duration_ns  BIGINT NOT NULL,
+		num_total    INTEGER NOT NULL,
+		num_passed   INTEGER NOT NULL,
+		num_failed   INTEGER NOT NULL,
+		num_skipped  INTEGER NOT NULL,
+		repeat_count INTEGER NOT NULL DEFAULT 0,
+		note         TEXT NOT NULL DEFAULT '',
+		bookmarked   BOOLEAN NOT NULL DEFAULT FALSE,
+		record       JSONB NOT NULL,
+		UNIQUE (project, id)
+	)`,
+	// 2: per-test results, for flakiness and duration queries across runs
+	`CREATE TABLE sentinel_results (
+		run_seq     BIGINT NOT NULL REFERENCES sentinel_runs (seq) ON DELETE CASCADE,
+		package     TEXT NOT NULL,
+		test        TEXT NOT NULL,
+		status      SMALLINT NOT NULL,
+		duration_ns BIGINT NOT NULL
+	)`,
+	// 3-5: lookups by time range, by test, and from a run to its results
+	`CREATE INDEX sentinel_runs_project_start ON sentinel_runs (project, start_time)`,
+	`CREATE INDEX sentinel_results_test ON sentinel_results (package, test)`,
+	`CREATE INDEX sentinel_results_run ON sentinel_results (run_seq)`,
+	// 6-7: failure fingerprints, for tracking a failure across runs
+	`ALTER TABLE sentinel_results ADD COLUMN fingerprint TEXT NOT NULL DEFAULT ''`,
+	`CREATE INDEX sentinel_results_fingerprint ON sentinel_results (fingerprint) WHERE fingerprint <> ''`,
+	// 8-9: requirement traceability, for audit queries by requirement
+	`ALTER TABLE sentinel_results ADD COLUMN requirements TEXT[] NOT NULL DEFAULT '{}'`,
+	`CREATE INDEX sentinel_results_requirements ON sentinel_results USING GIN (requirements)`,
+	// 10-11: coverage, in total on the run, NULL when not collected, and
+	// per file
+	`ALTER TABLE sentinel_runs ADD COLUMN coverage_total DOUBLE PRECISION`,
+	`CREATE TABLE sentinel_coverage (
+		run_seq BIGINT NOT NULL REFERENCES sentinel_runs (seq) ON DELETE CASCADE,
+		file    TEXT NOT NULL,
+		percent DOUBLE PRECISION NOT NULL
+	)`,
+	// 12: from a run to its coverage
+	`CREATE INDEX sentinel_coverage_run ON sentinel_coverage (run_seq)`,
+}
+
+// postgresMigrationLock serializes migrations between processes
+// connecting to the same database at once
+const postgresMigrationLock = 0x73656e74
+
+// postgresDialect stores history in PostgreSQL
+var postgresDialect = &sqlDialect{
+	name:       "postgres",
+	migrations: postgresMigrations,
+	appliedAt:  "TIMESTAMPTZ NOT NULL DEFAULT now()",
+	lock: func(tx *sql.Tx, project string) error {
+		if project == "" {
+			_, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, postgresMigrationLock)
+			return err
+		}
+		_, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1))`, project)
+		return err
+	},
+	numbered:  true,
+	timestamp: func(t time.Time) any { return t },
+	record:    func(data []byte) any { return data },
+	requirements: func(reqs []string) (any, error) {
+		return pq.Array(reqs), nil
+	},
+}
+
+// PostgresHistory persists run records in a PostgreSQL database shared by
+// several machines or projects
+type PostgresHistory struct {
+	sqlHistory
+}
+
+// OpenPostgresHistory connects to the database at dsn, applies any pending
+// migrations, and returns a store for project's runs
+func OpenPostgresHistory(dsn, project string) (*PostgresHistory, error) {
+	connector, err := pq.NewConnector(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open postgres history: %w", err)
+	}
+	connector.Dialer(offlineDialer{})
+	db := sql.OpenDB(connector)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to postgres history: %w", err)
+	}
+	store, err := openSQLHistory(db, postgresDialect, project)
+	if err != nil {
+		return nil, err
+	}
+	return &PostgresHistory{store}, nil
+}
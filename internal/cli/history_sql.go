@@ -0,0 +1,343 @@
+package cli
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sqlDialect describes what differs between the SQL databases run history
+// is stored in; the queries themselves are shared
+type sqlDialect struct {
+	name       string   // Database name, for error messages
+	migrations []string // Schema steps, applied once each and in order
+	appliedAt  string   // Column definition of when a migration was applied
+
+	// lock serializes the writers of project's runs within tx, or
+	// migrations when project is empty. Nil when beginning a transaction
+	// already does.
+	lock func(tx *sql.Tx, project string) error
+
+	numbered     bool                             // Placeholders are $1, $2, ... rather than ?
+	timestamp    func(t time.Time) any            // Column value of a run's start time
+	record       func(data []byte) any            // Column value of a run's JSON record
+	requirements func(reqs []string) (any, error) // Column value of a test's requirements
+}
+
+// bind rewrites the ? placeholders of query for the dialect
+func (d *sqlDialect) bind(query string) string {
+	if !d.numbered {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// sqlHistory persists run records, their per-test results, and their
+// coverage in an SQL database, keyed by project so one database can serve
+// many projects
+type sqlHistory struct {
+	db      *sql.DB
+	dialect *sqlDialect
+	project string
+
+	// MaxRuns caps the number of stored records for the project, as in
+	// HistoryStore. Zero keeps everything.
+	MaxRuns int
+
+	// CompactWatchRuns collapses consecutive identical all-green watch
+	// runs into a single record, as in HistoryStore
+	CompactWatchRuns bool
+
+	// Location is the time zone timestamps are stored in; nil keeps local
+	// time
+	Location *time.Location
+}
+
+// openSQLHistory applies the migrations db has not seen yet and returns a
+// store for project's runs. db is closed when that fails.
+func openSQLHistory(db *sql.DB, dialect *sqlDialect, project string) (sqlHistory, error) {
+	s := sqlHistory{db: db, dialect: dialect, project: project, CompactWatchRuns: true}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return sqlHistory{}, err
+	}
+	return s, nil
+}
+
+// migrate applies the migrations the database has not seen yet
+func (s *sqlHistory) migrate() error {
+	d := s.dialect
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to migrate %s history: %w", d.name, err)
+	}
+	defer tx.Rollback()
+
+	if d.lock != nil {
+		if err := d.lock(tx, ""); err != nil {
+			return fmt.Errorf("failed to migrate %s history: %w", d.name, err)
+		}
+	}
+	if _, err := tx.Exec(`CREATE TABLE IF NOT EXISTS sentinel_schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at ` + d.appliedAt + `
+	)`); err != nil {
+		return fmt.Errorf("failed to migrate %s history: %w", d.name, err)
+	}
+
+	var current int
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM sentinel_schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("failed to migrate %s history: %w", d.name, err)
+	}
+	if current > len(d.migrations) {
+		return fmt.Errorf("%s history schema version %d is newer than this go-sentinel supports (%d)", d.name, current, len(d.migrations))
+	}
+	for i := current; i < len(d.migrations); i++ {
+		if _, err := tx.Exec(d.migrations[i]); err != nil {
+			return fmt.Errorf("failed to apply %s history migration %d: %w", d.name, i+1, err)
+		}
+		if _, err := tx.Exec(d.bind(`INSERT INTO sentinel_schema_migrations (version) VALUES (?)`), i+1); err != nil {
+			return fmt.Errorf("failed to apply %s history migration %d: %w", d.name, i+1, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to migrate %s history: %w", d.name, err)
+	}
+	return nil
+}
+
+// Close closes the database connection
+func (s *sqlHistory) Close() error {
+	return s.db.Close()
+}
+
+// Append stores a run record, collapsing it into the project's previous
+// record when the compaction policy allows, and applies the retention limit
+func (s *sqlHistory) Append(rec *HistoryRecord) error {
+	if s.Location != nil {
+		rec.StartTime = rec.StartTime.In(s.Location)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Concurrent writers for one project would otherwise race on compaction
+	if s.dialect.lock != nil {
+		if err := s.dialect.lock(tx, s.project); err != nil {
+			return fmt.Errorf("failed to write history: %w", err)
+		}
+	}
+
+	if s.CompactWatchRuns {
+		var seq int64
+		var data []byte
+		err := tx.QueryRow(s.dialect.bind(`SELECT seq, record FROM sentinel_runs WHERE project = ? ORDER BY seq DESC LIMIT 1`), s.project).Scan(&seq, &data)
+		if err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("failed to read history: %w", err)
+		}
+		if err == nil {
+			var last HistoryRecord
+			if json.Unmarshal(data, &last) == nil && canCompact(&last, rec) {
+				last.RepeatCount += rec.Runs()
+				last.LastRunAt = rec.StartTime
+				if err := s.updateRun(tx, seq, &last); err != nil {
+					return err
+				}
+				return s.commit(tx)
+			}
+		}
+	}
+
+	if err := s.insertRun(tx, rec); err != nil {
+		return err
+	}
+	return s.commit(tx)
+}
+
+// commit finishes an append and applies the retention limit
+func (s *sqlHistory) commit(tx *sql.Tx) error {
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	if s.MaxRuns > 0 {
+		if _, err := s.Prune(s.MaxRuns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertRun stores rec as a new run with its per-test results and coverage
+func (s *sqlHistory) insertRun(tx *sql.Tx, rec *HistoryRecord) error {
+	d := s.dialect
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to encode history record: %w", err)
+	}
+	var coverageTotal sql.NullFloat64
+	if rec.Coverage != nil {
+		coverageTotal = sql.NullFloat64{Float64: rec.CoverageTotal, Valid: true}
+	}
+	var seq int64
+	err = tx.QueryRow(d.bind(`INSERT INTO sentinel_runs
+		(project, id, trigger, label, start_time, duration_ns, num_total, num_passed, num_failed, num_skipped,
+		 repeat_count, note, bookmarked, record, coverage_total)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING seq`),
+		s.project, rec.ID, rec.Trigger, rec.Label, d.timestamp(rec.StartTime), int64(rec.Duration),
+		rec.NumTotal, rec.NumPassed, rec.NumFailed, rec.NumSkipped,
+		rec.RepeatCount, rec.Note, rec.Bookmarked, d.record(data), coverageTotal).Scan(&seq)
+	if err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+
+	results, err := tx.Prepare(d.bind(`INSERT INTO sentinel_results (run_seq, package, test, status, duration_ns, fingerprint, requirements) VALUES (?, ?, ?, ?, ?, ?, ?)`))
+	if err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	defer results.Close()
+	for _, pkg := range rec.Packages {
+		for _, test := range pkg.Tests {
+			reqs, err := d.requirements(requirementsOrEmpty(test.Requirements))
+			if err != nil {
+				return fmt.Errorf("failed to encode history record: %w", err)
+			}
+			if _, err := results.Exec(seq, pkg.Package, test.Name, int(test.Status), int64(test.Duration), test.Fingerprint, reqs); err != nil {
+				return fmt.Errorf("failed to write history: %w", err)
+			}
+		}
+	}
+
+	coverage, err := tx.Prepare(d.bind(`INSERT INTO sentinel_coverage (run_seq, file, percent) VALUES (?, ?, ?)`))
+	if err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	defer coverage.Close()
+	for file, percent := range rec.Coverage {
+		if _, err := coverage.Exec(seq, file, percent); err != nil {
+			return fmt.Errorf("failed to write history: %w", err)
+		}
+	}
+	return nil
+}
+
+// updateRun replaces the stored record for the run at seq. Results are left
+// alone: updates only touch annotations and repeat counts, never outcomes.
+func (s *sqlHistory) updateRun(tx *sql.Tx, seq int64, rec *HistoryRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to encode history record: %w", err)
+	}
+	_, err = tx.Exec(s.dialect.bind(`UPDATE sentinel_runs SET repeat_count = ?, note = ?, bookmarked = ?, record = ? WHERE seq = ?`),
+		rec.RepeatCount, rec.Note, rec.Bookmarked, s.dialect.record(data), seq)
+	if err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
+
+// Load returns all of the project's records, oldest first
+func (s *sqlHistory) Load() ([]*HistoryRecord, error) {
+	rows, err := s.db.Query(s.dialect.bind(`SELECT record FROM sentinel_runs WHERE project = ? ORDER BY seq`), s.project)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	defer rows.Close()
+
+	var records []*HistoryRecord
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to read history: %w", err)
+		}
+		var rec HistoryRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			continue // Skip records written by an incompatible version
+		}
+		records = append(records, &rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading history: %w", err)
+	}
+	return records, nil
+}
+
+// Update applies fn to the record identified by id, which may be any
+// unambiguous prefix of a run ID, and saves the result
+func (s *sqlHistory) Update(id string, fn func(*HistoryRecord)) (*HistoryRecord, error) {
+	records, err := s.Load()
+	if err != nil {
+		return nil, err
+	}
+	rec, err := findRecord(records, id)
+	if err != nil {
+		return nil, err
+	}
+	fn(rec)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to write history: %w", err)
+	}
+	defer tx.Rollback()
+	var seq int64
+	if err := tx.QueryRow(s.dialect.bind(`SELECT seq FROM sentinel_runs WHERE project = ? AND id = ?`), s.project, rec.ID).Scan(&seq); err != nil {
+		return nil, fmt.Errorf("failed to write history: %w", err)
+	}
+	if err := s.updateRun(tx, seq, rec); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to write history: %w", err)
+	}
+	return rec, nil
+}
+
+// Prune drops the project's oldest records that are not bookmarked until at
+// most max remain, and returns how many were removed
+func (s *sqlHistory) Prune(max int) (int, error) {
+	var total int
+	if err := s.db.QueryRow(s.dialect.bind(`SELECT COUNT(*) FROM sentinel_runs WHERE project = ?`), s.project).Scan(&total); err != nil {
+		return 0, fmt.Errorf("failed to read history: %w", err)
+	}
+	excess := total - max
+	if excess <= 0 {
+		return 0, nil
+	}
+	res, err := s.db.Exec(s.dialect.bind(`DELETE FROM sentinel_runs WHERE seq IN (
+		SELECT seq FROM sentinel_runs WHERE project = ? AND NOT bookmarked ORDER BY seq LIMIT ?
+	)`), s.project, excess)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune history: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune history: %w", err)
+	}
+	return int(n), nil
+}
+
+// requirementsOrEmpty stores tests without requirements as an empty array
+// rather than NULL
+func requirementsOrEmpty(reqs []string) []string {
+	if reqs == nil {
+		return []string{}
+	}
+	return reqs
+}
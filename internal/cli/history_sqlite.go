@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite" // Registers the "sqlite" database/sql driver
+)
+
+// SQLiteHistoryFileName is the database of the sqlite backend inside
+// HistoryDir
+const SQLiteHistoryFileName = "history.db"
+
+// sqliteMigrations create and evolve the history schema, mirroring
+// postgresMigrations. Each entry is applied once, in order, and recorded
+// in sentinel_schema_migrations; append new steps rather than editing
+// released ones.
+var sqliteMigrations = []string{
+	// 1: runs, keyed by project so one database file can serve many
+	// projects, with the full record kept alongside columns for querying
+	`CREATE TABLE sentinel_runs (
+		seq          INTEGER PRIMARY KEY AUTOINCREMENT,
+		project      TEXT NOT NULL,
+		id           TEXT NOT NULL,
+		trigger      TEXT NOT NULL,
+		label        TEXT NOT NULL DEFAULT '',
+		start_time   TEXT NOT NULL,
+		duration_ns  INTEGER NOT NULL,
+		num_total    INTEGER NOT NULL,
+		num_passed   INTEGER NOT NULL,
+		num_failed   INTEGER NOT NULL,
+		num_skipped  INTEGER NOT NULL,
+		repeat_count INTEGER NOT NULL DEFAULT 0,
+		note         TEXT NOT NULL DEFAULT '',
+		bookmarked   INTEGER NOT NULL DEFAULT 0,
+		record       TEXT NOT NULL,
+		UNIQUE (project, id)
+	)`,
+	// 2: per-test results, for flakiness and duration queries across runs.
+	// Requirements are a JSON array, queryable with json_each.
+	`CREATE TABLE sentinel_results (
+		run_seq      INTEGER NOT NULL REFERENCES sentinel_runs (seq) ON DELETE CASCADE,
+		package      TEXT NOT NULL,
+		test         TEXT NOT NULL,
+		status       INTEGER NOT NULL,
+		duration_ns  INTEGER NOT NULL,
+		fingerprint  TEXT NOT NULL DEFAULT '',
+		requirements TEXT NOT NULL DEFAULT '[]'
+	)`,
+	// 3-6: lookups by time range, by test, from a run to its results, and
+	// by failure fingerprint
+	`CREATE INDEX sentinel_runs_project_start ON sentinel_runs (project, start_time)`,
+	`CREATE INDEX sentinel_results_test ON sentinel_results (package, test)`,
+	`CREATE INDEX sentinel_results_run ON sentinel_results (run_seq)`,
+	`CREATE INDEX sentinel_results_fingerprint ON sentinel_results (fingerprint) WHERE fingerprint <> ''`,
+	// 7-9: coverage, in total on the run, NULL when not collected, and
+	// per file, with the lookup from a run to it
+	`ALTER TABLE sentinel_runs ADD COLUMN coverage_total REAL`,
+	`CREATE TABLE sentinel_coverage (
+		run_seq INTEGER NOT NULL REFERENCES sentinel_runs (seq) ON DELETE CASCADE,
+		file    TEXT NOT NULL,
+		percent REAL NOT NULL
+	)`,
+	`CREATE INDEX sentinel_coverage_run ON sentinel_coverage (run_seq)`,
+}
+
+// sqliteDialect stores history in SQLite. Writers take the database lock
+// when their transaction begins, so no explicit lock is needed.
+var sqliteDialect = &sqlDialect{
+	name:       "sqlite",
+	migrations: sqliteMigrations,
+	appliedAt:  "TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP",
+	timestamp:  func(t time.Time) any { return t.Format(time.RFC3339Nano) },
+	record:     func(data []byte) any { return string(data) },
+	requirements: func(reqs []string) (any, error) {
+		data, err := json.Marshal(reqs)
+		return string(data), err
+	},
+}
+
+// SQLiteHistory persists run records in an SQLite database file, for
+// projects whose history outgrows the JSON lines file but that have no
+// shared database server. Queries run against the same schema as
+// PostgresHistory.
+type SQLiteHistory struct {
+	sqlHistory
+}
+
+// OpenSQLiteHistory opens or creates the database at path, applies any
+// pending migrations, and returns a store for project's runs
+func OpenSQLiteHistory(path, project string) (*SQLiteHistory, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	// Writers take the lock when their transaction begins, so concurrent
+	// runs wait their turn instead of failing midway through compaction
+	query := url.Values{}
+	query.Add("_pragma", "busy_timeout(5000)")
+	query.Add("_pragma", "foreign_keys(1)")
+	query.Add("_pragma", "journal_mode(WAL)")
+	query.Set("_txlock", "immediate")
+	db, err := sql.Open("sqlite", "file:"+filepath.ToSlash(path)+"?"+query.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite history: %w", err)
+	}
+	store, err := openSQLHistory(db, sqliteDialect, project)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLiteHistory{store}, nil
+}
@@ -0,0 +1,339 @@
+package cli
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// historyRun builds a single-package run with the given test outcomes
+func historyRun(id string, start time.Time, statuses map[string]TestStatus) *TestRun {
+	suite := &TestSuite{Package: "example.com/pkg"}
+	run := &TestRun{ID: id, StartTime: start, Suites: []*TestSuite{suite}}
+	for name, status := range statuses {
+		suite.Tests = append(suite.Tests, &TestResult{Name: name, Status: status, Duration: time.Millisecond})
+		run.NumTotal++
+		if status == TestStatusFailed {
+			run.NumFailed++
+		} else {
+			run.NumPassed++
+		}
+	}
+	return run
+}
+
+func TestHistoryStore_CompactWatchRuns(t *testing.T) {
+	store := NewHistoryStore(t.TempDir())
+	now := time.Now()
+
+	green := map[string]TestStatus{"TestA": TestStatusPassed, "TestB": TestStatusPassed}
+	red := map[string]TestStatus{"TestA": TestStatusPassed, "TestB": TestStatusFailed}
+
+	appends := []struct {
+		id       string
+		trigger  string
+		statuses map[string]TestStatus
+	}{
+		{"1", TriggerWatch, green},
+		{"2", TriggerWatch, green}, // collapsed into 1
+		{"3", TriggerWatch, green}, // collapsed into 1
+		{"4", TriggerWatch, red},   // failure kept
+		{"5", TriggerWatch, red},   // failures are never collapsed
+		{"6", TriggerWatch, green}, // transition kept
+		{"7", TriggerRun, green},   // explicit runs are never collapsed
+	}
+	for i, a := range appends {
+		rec := NewHistoryRecord(historyRun(a.id, now.Add(time.Duration(i)*time.Second), a.statuses), a.trigger)
+		if err := store.Append(rec); err != nil {
+			t.Fatalf("Append(%s) failed: %v", a.id, err)
+		}
+	}
+
+	records, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	wantIDs := []string{"1", "4", "5", "6", "7"}
+	if len(records) != len(wantIDs) {
+		t.Fatalf("got %d records, want %d", len(records), len(wantIDs))
+	}
+	for i, id := range wantIDs {
+		if records[i].ID != id {
+			t.Errorf("record %d: ID = %s, want %s", i, records[i].ID, id)
+		}
+	}
+	if records[0].Runs() != 3 {
+		t.Errorf("collapsed record Runs() = %d, want 3", records[0].Runs())
+	}
+	if !records[0].LastRunAt.Equal(now.Add(2 * time.Second)) {
+		t.Errorf("LastRunAt = %v, want start of run 3", records[0].LastRunAt)
+	}
+}
+
+func TestHistoryStore_KeepRepeats(t *testing.T) {
+	store := NewHistoryStore(t.TempDir())
+	store.CompactWatchRuns = false
+
+	green := map[string]TestStatus{"TestA": TestStatusPassed}
+	for i := 0; i < 3; i++ {
+		if err := store.Append(NewHistoryRecord(historyRun("id", time.Now(), green), TriggerWatch)); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+
+	records, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(records) != 3 {
+		t.Errorf("got %d records, want 3 with compaction disabled", len(records))
+	}
+}
+
+func TestHistoryStore_NotesAndBookmarks(t *testing.T) {
+	store := NewHistoryStore(t.TempDir())
+	now := time.Now()
+	green := map[string]TestStatus{"TestA": TestStatusPassed}
+
+	for i, id := range []string{"run-a1", "run-a2", "run-b1", "run-c1"} {
+		rec := NewHistoryRecord(historyRun(id, now.Add(time.Duration(i)*time.Second), green), TriggerRun)
+		if err := store.Append(rec); err != nil {
+			t.Fatalf("Append(%s) failed: %v", id, err)
+		}
+	}
+
+	if _, err := store.Update("run-a", func(*HistoryRecord) {}); err == nil {
+		t.Error("Expected error for ambiguous run ID prefix")
+	}
+	if _, err := store.Update("missing", func(*HistoryRecord) {}); err == nil {
+		t.Error("Expected error for unknown run ID")
+	}
+
+	rec, err := store.Update("run-a1", func(rec *HistoryRecord) {
+		rec.Note = "before dependency upgrade"
+		rec.Bookmarked = true
+	})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if rec.ID != "run-a1" {
+		t.Errorf("Updated run %s, want run-a1", rec.ID)
+	}
+
+	// Retention drops the oldest runs but keeps the bookmark
+	removed, err := store.Prune(2)
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("Prune removed %d records, want 2", removed)
+	}
+
+	records, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	SortPinned(records)
+	var ids []string
+	for _, rec := range records {
+		ids = append(ids, rec.ID)
+	}
+	if len(ids) != 2 || ids[0] != "run-a1" || ids[1] != "run-c1" {
+		t.Errorf("Records after prune = %v, want [run-a1 run-c1]", ids)
+	}
+	if records[0].Note != "before dependency upgrade" || !records[0].Bookmarked {
+		t.Errorf("Bookmarked record = %+v, want note and bookmark preserved", records[0])
+	}
+}
+
+func TestOpenHistory(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenHistory(dir, HistoryConfig{KeepRepeats: true, MaxRuns: 5}, time.UTC)
+	if err != nil {
+		t.Fatalf("OpenHistory failed: %v", err)
+	}
+	file, ok := store.(*HistoryStore)
+	if !ok {
+		t.Fatalf("Default backend = %T, want *HistoryStore", store)
+	}
+	if file.CompactWatchRuns || file.MaxRuns != 5 || file.Location != time.UTC {
+		t.Errorf("Settings not applied: %+v", file)
+	}
+
+	t.Setenv(EnvPostgresURL, "")
+	if _, err := OpenHistory(dir, HistoryConfig{Backend: HistoryBackendPostgres}, nil); err == nil {
+		t.Error("Expected an error when the postgres backend has no connection string")
+	}
+	if _, err := OpenHistory(dir, HistoryConfig{Backend: "mysql"}, nil); err == nil {
+		t.Error("Expected an error for an unknown backend")
+	}
+}
+
+func TestSQLiteHistory(t *testing.T) {
+	dir := t.TempDir()
+	backend, err := OpenHistory(dir, HistoryConfig{Backend: HistoryBackendSQLite, MaxRuns: 3}, nil)
+	if err != nil {
+		t.Fatalf("OpenHistory failed: %v", err)
+	}
+	store, ok := backend.(*SQLiteHistory)
+	if !ok {
+		t.Fatalf("sqlite backend = %T, want *SQLiteHistory", backend)
+	}
+	defer store.Close()
+	if _, err := os.Stat(filepath.Join(dir, HistoryDir, SQLiteHistoryFileName)); err != nil {
+		t.Fatalf("database not created in the project: %v", err)
+	}
+
+	// Reopening must find the schema already migrated
+	again, err := OpenSQLiteHistory(filepath.Join(dir, HistoryDir, SQLiteHistoryFileName), "other")
+	if err != nil {
+		t.Fatalf("Second OpenSQLiteHistory failed: %v", err)
+	}
+	defer again.Close()
+
+	now := time.Now()
+	green := map[string]TestStatus{"TestA": TestStatusPassed}
+	for _, id := range []string{"1", "2", "3"} {
+		if err := store.Append(NewHistoryRecord(historyRun(id, now, green), TriggerWatch)); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+	store.Append(NewHistoryRecord(historyRun("4", now, green), TriggerRun))
+
+	records, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(records) != 2 || records[0].RepeatCount != 2 || records[1].ID != "4" {
+		t.Fatalf("records = %+v, want run 1 with two repeats then run 4", records)
+	}
+	if other, _ := again.Load(); len(other) != 0 {
+		t.Errorf("another project sees %d records", len(other))
+	}
+	var results int
+	store.db.QueryRow(`SELECT COUNT(*) FROM sentinel_results`).Scan(&results)
+	if results != 2 {
+		t.Errorf("stored %d test results, want one per stored run", results)
+	}
+
+	if _, err := store.Update("1", func(rec *HistoryRecord) { rec.Bookmarked = true }); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	removed, err := store.Prune(1)
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	records, _ = store.Load()
+	if removed != 1 || len(records) != 1 || records[0].ID != "1" {
+		t.Errorf("Prune removed %d leaving %+v, want the bookmarked run kept", removed, records)
+	}
+	store.db.QueryRow(`SELECT COUNT(*) FROM sentinel_results`).Scan(&results)
+	if results != 1 {
+		t.Errorf("%d test results left, want those of pruned runs deleted with them", results)
+	}
+}
+
+func TestSQLiteHistory_Coverage(t *testing.T) {
+	store, err := OpenSQLiteHistory(filepath.Join(t.TempDir(), SQLiteHistoryFileName), "project")
+	if err != nil {
+		t.Fatalf("OpenSQLiteHistory failed: %v", err)
+	}
+	defer store.Close()
+
+	run := historyRun("1", time.Now(), map[string]TestStatus{"TestA": TestStatusPassed})
+	run.Coverage = map[string]float64{"calc.go": 80, "parse.go": 50}
+	run.CoverageTotal = 65
+	if err := store.Append(NewHistoryRecord(run, TriggerRun)); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	if err := store.Append(NewHistoryRecord(historyRun("2", time.Now(), nil), TriggerRun)); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+
+	var total sql.NullFloat64
+	var files int
+	store.db.QueryRow(`SELECT coverage_total FROM sentinel_runs WHERE id = '1'`).Scan(&total)
+	store.db.QueryRow(`SELECT COUNT(*) FROM sentinel_coverage c JOIN sentinel_runs r ON r.seq = c.run_seq WHERE r.id = '1'`).Scan(&files)
+	if !total.Valid || total.Float64 != 65 || files != 2 {
+		t.Errorf("stored total %+v and %d files, want 65%% over 2 files", total, files)
+	}
+	store.db.QueryRow(`SELECT coverage_total FROM sentinel_runs WHERE id = '2'`).Scan(&total)
+	if total.Valid {
+		t.Errorf("run without coverage stored total %v, want NULL", total.Float64)
+	}
+
+	records, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if records[0].CoverageTotal != 65 || records[0].Coverage["calc.go"] != 80 {
+		t.Errorf("loaded coverage %v (total %v), want it kept in the record", records[0].Coverage, records[0].CoverageTotal)
+	}
+}
+
+func TestSQLDialect_Bind(t *testing.T) {
+	query := `SELECT seq FROM sentinel_runs WHERE project = ? AND id = ?`
+	if got := sqliteDialect.bind(query); got != query {
+		t.Errorf("sqlite bind() = %q, want the query unchanged", got)
+	}
+	want := `SELECT seq FROM sentinel_runs WHERE project = $1 AND id = $2`
+	if got := postgresDialect.bind(query); got != want {
+		t.Errorf("postgres bind() = %q, want %q", got, want)
+	}
+}
+
+// TestPostgresHistory runs against a real database named by
+// SENTINEL_TEST_POSTGRES_URL and is skipped without one
+func TestPostgresHistory(t *testing.T) {
+	dsn := os.Getenv("SENTINEL_TEST_POSTGRES_URL")
+	if dsn == "" {
+		t.Skip("SENTINEL_TEST_POSTGRES_URL not set")
+	}
+	project := fmt.Sprintf("test-%d", time.Now().UnixNano())
+	store, err := OpenPostgresHistory(dsn, project)
+	if err != nil {
+		t.Fatalf("OpenPostgresHistory failed: %v", err)
+	}
+	defer store.Close()
+	defer store.db.Exec(`DELETE FROM sentinel_runs WHERE project = $1`, project)
+
+	// Reopening must find the schema already migrated
+	again, err := OpenPostgresHistory(dsn, project)
+	if err != nil {
+		t.Fatalf("Second OpenPostgresHistory failed: %v", err)
+	}
+	again.Close()
+
+	now := time.Now()
+	green := map[string]TestStatus{"TestA": TestStatusPassed}
+	for _, id := range []string{"1", "2", "3"} {
+		if err := store.Append(NewHistoryRecord(historyRun(id, now, green), TriggerWatch)); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+	store.Append(NewHistoryRecord(historyRun("4", now, green), TriggerRun))
+
+	records, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(records) != 2 || records[0].RepeatCount != 2 || records[1].ID != "4" {
+		t.Fatalf("records = %+v, want run 1 with two repeats then run 4", records)
+	}
+
+	if _, err := store.Update("1", func(rec *HistoryRecord) { rec.Bookmarked = true }); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	removed, err := store.Prune(1)
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	records, _ = store.Load()
+	if removed != 1 || len(records) != 1 || records[0].ID != "1" {
+		t.Errorf("Prune removed %d leaving %+v, want the bookmarked run kept", removed, records)
+	}
+}
@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Hook phases
+const (
+	HookBefore = "before"
+	HookAfter  = "after"
+)
+
+// HookConfig is a command run around a profile's tests, such as starting
+// the database integration tests need and tearing it down again
+type HookConfig struct {
+	Name string   `json:"name"`
+	Run  []string `json:"run"`           // Command and arguments
+	Dir  string   `json:"dir,omitempty"` // Directory to run in, relative to the working directory
+}
+
+// label returns the hook's name, or its command when it has none
+func (h HookConfig) label() string {
+	if h.Name != "" {
+		return h.Name
+	}
+	return strings.Join(h.Run, " ")
+}
+
+// HookResult is one hook that ran and its captured output
+type HookResult struct {
+	Hook     HookConfig
+	Phase    string
+	Output   string
+	Duration time.Duration
+	Err      error
+}
+
+// HookError reports a failed hook
+type HookError struct {
+	Result *HookResult
+}
+
+// Error implements the error interface
+func (e *HookError) Error() string {
+	return fmt.Sprintf("%s hook %q failed: %v", e.Result.Phase, e.Result.Hook.label(), e.Result.Err)
+}
+
+// Unwrap returns the underlying command error
+func (e *HookError) Unwrap() error {
+	return e.Result.Err
+}
+
+// runHooks runs the hooks of a phase in declaration order with the test
+// environment, rendering each as it finishes. Before hooks stop at the
+// first failure, since the tests cannot run without what they set up;
+// after hooks all run, so one failed teardown does not leave the rest
+// behind.
+func (r *Runner) runHooks(phase string, hooks []HookConfig, opts RunOptions) error {
+	var errs []error
+	for _, hook := range hooks {
+		cmd := exec.Command(hook.Run[0], hook.Run[1:]...)
+		cmd.Dir = r.workDir
+		if hook.Dir != "" {
+			cmd.Dir = absPath(r.workDir, hook.Dir)
+		}
+		cmd.Env = append(os.Environ(), opts.Env...)
+
+		start := time.Now()
+		output, err := cmd.CombinedOutput()
+		result := &HookResult{Hook: hook, Phase: phase, Output: string(output), Duration: time.Since(start), Err: err}
+		if opts.Renderer != nil {
+			opts.Renderer.RenderHook(result)
+		}
+		if err != nil {
+			errs = append(errs, &HookError{Result: result})
+			if phase == HookBefore {
+				break
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRunner_RunHooks(t *testing.T) {
+	runner, err := NewRunner(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create runner: %v", err)
+	}
+	defer runner.Stop()
+
+	var buf bytes.Buffer
+	opts := RunOptions{
+		Renderer: NewRendererWithStyle(&buf, false),
+		BeforeHooks: []HookConfig{
+			{Name: "db-up", Run: []string{"go", "version"}},
+			{Name: "migrate", Run: []string{"go", "no-such-command"}},
+			{Name: "seed", Run: []string{"go", "version"}},
+		},
+		AfterHooks: []HookConfig{
+			{Name: "db-down", Run: []string{"go", "version"}},
+		},
+	}
+
+	// The failed setup aborts the run, and the teardown still runs
+	err = runner.Run(context.Background(), opts)
+	var hookErr *HookError
+	if !errors.As(err, &hookErr) {
+		t.Fatalf("Run() error = %v, want a HookError", err)
+	}
+	if hookErr.Result.Hook.Name != "migrate" || hookErr.Result.Phase != HookBefore {
+		t.Errorf("HookError = %v, want the migrate before hook", hookErr)
+	}
+	if !strings.Contains(err.Error(), `before hook "migrate" failed`) {
+		t.Errorf("error = %q, want the failed hook named", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{"✓ before hook db-up", "(1 line of output)", "BEFORE HOOK FAILED: migrate", "$ go no-such-command", "unknown command", "✓ after hook db-down"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "seed") {
+		t.Errorf("before hooks continued past the failure:\n%s", out)
+	}
+	if strings.Contains(out, "go version go") {
+		t.Errorf("output of a passing hook is not collapsed:\n%s", out)
+	}
+}
+
+func TestProfileConfig_ValidateHooks(t *testing.T) {
+	profile := ProfileConfig{After: []HookConfig{{Name: "db-down"}}}
+	if err := profile.validate(); err == nil || !strings.Contains(err.Error(), "after hook 0 (db-down)") {
+		t.Errorf("validate() = %v, want the hook without a command", err)
+	}
+}
@@ -0,0 +1,524 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// htmlReportStyle is shared by the pages of HTML reports
+const htmlReportStyle = `<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em auto; max-width: 70em; color: #24292f; }
+h1 { font-size: 1.5em; }
+h2 { font-size: 1.15em; margin-top: 2em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: 0.3em 0.6em; border-bottom: 1px solid #d0d7de; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+pre { background: #f6f8fa; padding: 0.8em; overflow-x: auto; font-size: 0.85em; }
+.passed { color: #1a7f37; }
+.failed { color: #cf222e; font-weight: bold; }
+.skipped { color: #9a6700; }
+.summary span { margin-right: 1.5em; }
+.dim { color: #656d76; }
+.bars td { border: none; padding: 0.15em 0.6em; }
+.bar { height: 0.9em; background: #54aeff; min-width: 1px; }
+.bar.failed { background: #ff8182; }
+.tiles { display: flex; flex-wrap: wrap; gap: 0.3em; margin-bottom: 1em; }
+.tile { display: block; width: 9em; padding: 0.4em; font-size: 0.8em; color: #24292f; text-decoration: none; overflow: hidden; text-overflow: ellipsis; white-space: nowrap; }
+.source { font-family: ui-monospace, SFMono-Regular, Menlo, monospace; font-size: 0.85em; white-space: pre; }
+.source td { border: none; padding: 0 0.6em; }
+.source td.num { color: #656d76; user-select: none; }
+.hl { background: #ffebe9; }
+.cov { background: #dafbe1; }
+.unc { background: #ffebe9; }
+.part { background: #fff8c5; }
+</style>`
+
+// htmlReportTemplate renders a self-contained report page: the run
+// summary, package and test durations, a coverage heatmap when coverage
+// was collected, a table per package, and the output of each failed test
+var htmlReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+` + htmlReportStyle + `
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="summary">
+<span>{{.Total}} tests</span>
+<span class="passed">{{.Passed}} passed</span>
+<span class="failed">{{.Failed}} failed</span>
+<span class="skipped">{{.Skipped}} skipped</span>
+<span>{{.Duration}}</span>
+{{- if .Coverage}}<span>{{.Coverage}} coverage</span>{{end}}
+{{- if .Start}}<span>started {{.Start}}</span>{{end}}
+</p>
+{{- if .Env}}
+<p class="dim">{{.Env}}</p>
+{{- end}}
+{{- if .PackageBars}}
+<h2>Package durations</h2>
+<table class="bars">
+{{- range .PackageBars}}
+<tr><td>{{.Name}}</td><td class="num">{{.Duration}}</td><td style="width: 50%"><div class="bar {{.Status}}" style="width: {{.Width}}%"></div></td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if .SlowTests}}
+<h2>Slowest tests</h2>
+<table class="bars">
+{{- range .SlowTests}}
+<tr><td>{{.Name}}</td><td class="num">{{.Duration}}</td><td style="width: 50%"><div class="bar {{.Status}}" style="width: {{.Width}}%"></div></td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if .CoveragePackages}}
+<h2>Coverage</h2>
+{{- range .CoveragePackages}}
+<h3>{{.Name}} <span class="dim">{{.Percent}}</span></h3>
+<div class="tiles">
+{{- range .Files}}
+<a class="tile" href="{{.Link}}" title="{{.Title}}" style="background: hsl({{.Hue}}, 70%, 82%)">{{.Name}}<br>{{.Percent}}</a>
+{{- end}}
+</div>
+{{- end}}
+{{- end}}
+{{range .Packages}}
+<h2 class="{{.Status}}">{{.Name}}</h2>
+<table>
+<tr><th>Test</th><th>Status</th><th>Duration</th></tr>
+{{- range .Tests}}
+<tr><td>{{.Name}}</td><td class="{{.Status}}">{{.Status}}</td><td class="num">{{.Duration}}</td></tr>
+{{- end}}
+</table>
+{{- range .Failures}}
+<h3 class="failed">{{.Name}}</h3>
+<pre>{{.Output}}</pre>
+{{- if .Location}}
+<p class="dim">at {{.Location}}</p>
+{{- end}}
+{{- if .Snippet}}
+<table class="source">
+{{- range .Snippet}}
+<tr{{if .Class}} class="{{.Class}}"{{end}}><td class="num">{{.Number}}</td><td>{{.Text}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- end}}
+{{- end}}
+</body>
+</html>
+`))
+
+// htmlCoverageTemplate renders the source of one file with the lines its
+// tests ran, did not run, or partly ran
+var htmlCoverageTemplate = template.Must(template.New("coverage").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Name}}</title>
+` + htmlReportStyle + `
+</head>
+<body>
+<p><a href="../index.html">← Report</a></p>
+<h1>{{.Name}}</h1>
+<p class="summary"><span>{{.Percent}} of {{.Statements}} statements covered</span>
+<span class="cov">covered</span><span class="unc">not covered</span><span class="part">partly covered</span></p>
+<table class="source">
+{{- range .Lines}}
+<tr{{if .Class}} class="{{.Class}}"{{end}}><td class="num">{{.Number}}</td><td>{{.Text}}</td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+// htmlReport is the data of htmlReportTemplate
+type htmlReport struct {
+	Title                          string
+	Total, Passed, Failed, Skipped int
+	Duration                       string
+	Start                          string
+	Env                            string // Environment the tests ran in, when recorded
+	Coverage                       string // Total statement coverage, when collected
+	PackageBars                    []htmlReportBar
+	SlowTests                      []htmlReportBar
+	CoveragePackages               []htmlCoveragePackage
+	Packages                       []htmlReportPackage
+}
+
+// htmlReportPackage is one package section of an HTML report
+type htmlReportPackage struct {
+	Name     string
+	Status   string
+	Tests    []htmlReportTest
+	Failures []htmlReportTest // Failed tests and package failures, with output
+}
+
+// htmlReportTest is one test row of an HTML report
+type htmlReportTest struct {
+	Name     string
+	Status   string
+	Duration string
+	Output   string
+
+	Location string           // Where the failure was reported, for failures
+	Snippet  []htmlReportLine // Source around Location, when it could be read
+	loc      *SourceLocation
+	pkg      string
+}
+
+// htmlReportLine is a numbered source line of an HTML report, with the
+// class highlighting it, if any
+type htmlReportLine struct {
+	Number int
+	Text   string
+	Class  string
+}
+
+// htmlReportBar is one bar of a duration chart
+type htmlReportBar struct {
+	Name     string
+	Duration string
+	Status   string
+	Width    string // Percentage of the longest bar
+}
+
+// htmlCoveragePackage is the coverage heatmap of one package
+type htmlCoveragePackage struct {
+	Name    string
+	Percent string
+	Files   []htmlCoverageTile
+}
+
+// htmlCoverageTile is one file of a coverage heatmap, linking to its page
+type htmlCoverageTile struct {
+	Name    string
+	Title   string
+	Percent string
+	Link    string
+	Hue     int // From red at no coverage to green at full coverage
+}
+
+// htmlCoveragePage is the data of htmlCoverageTemplate
+type htmlCoveragePage struct {
+	Name       string
+	Percent    string
+	Statements int
+	Lines      []htmlReportLine
+}
+
+// htmlSlowTests is the number of tests in the slowest tests chart
+const htmlSlowTests = 10
+
+// htmlSnippetLines is the number of lines shown around a failure
+const htmlSnippetLines = 3
+
+// WriteHTMLReport writes run as a standalone HTML page, for sharing
+// results with people who do not read JUnit XML
+func WriteHTMLReport(w io.Writer, run *TestRun) error {
+	report := newHTMLReport(run)
+	if err := htmlReportTemplate.Execute(w, report); err != nil {
+		return fmt.Errorf("failed to write HTML report: %w", err)
+	}
+	return nil
+}
+
+// WriteHTMLSite writes a static HTML report of run to dir, for keeping as
+// a CI artifact: index.html with the summary, durations, results, and
+// failures with their source, plus a coverage heatmap linking to a page
+// per file with the lines that ran when coverage is not nil. Sources are
+// read from the module in workDir.
+func WriteHTMLSite(dir, workDir string, run *TestRun, coverage *CoverageReport) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create report directory: %w", err)
+	}
+	report := newHTMLReport(run)
+	pkgDirs := reportPackageDirs(workDir, run, coverage)
+
+	for i := range report.Packages {
+		for j := range report.Packages[i].Failures {
+			addHTMLSnippet(&report.Packages[i].Failures[j], workDir, pkgDirs)
+		}
+	}
+
+	if coverage != nil {
+		covered, statements := coverage.Total()
+		report.Coverage = fmt.Sprintf("%.1f%%", coveragePercent(covered, statements))
+		if err := os.MkdirAll(filepath.Join(dir, "coverage"), 0755); err != nil {
+			return fmt.Errorf("failed to create report directory: %w", err)
+		}
+		n := 0
+		for _, pkg := range coverage.Packages {
+			section := htmlCoveragePackage{Name: pkg.ImportPath, Percent: fmt.Sprintf("%.1f%%", pkg.Percent())}
+			for _, file := range pkg.Files {
+				n++
+				link := path.Join("coverage", strconv.Itoa(n)+".html")
+				page := htmlCoveragePage{Name: file.Name, Percent: fmt.Sprintf("%.1f%%", file.Percent()), Statements: file.Statements}
+				if pkgDir, ok := pkgDirs[pkg.ImportPath]; ok {
+					page.Lines = coverageLines(readSourceLines(filepath.Join(pkgDir, path.Base(file.Name))), file)
+				}
+				if err := writeHTMLFile(filepath.Join(dir, filepath.FromSlash(link)), htmlCoverageTemplate, page); err != nil {
+					return err
+				}
+				section.Files = append(section.Files, htmlCoverageTile{
+					Name:    path.Base(file.Name),
+					Title:   fmt.Sprintf("%s: %d of %d statements", file.Name, file.Covered, file.Statements),
+					Percent: page.Percent,
+					Link:    link,
+					Hue:     int(file.Percent() * 1.2),
+				})
+			}
+			report.CoveragePackages = append(report.CoveragePackages, section)
+		}
+	}
+
+	return writeHTMLFile(filepath.Join(dir, "index.html"), htmlReportTemplate, report)
+}
+
+// CollectReport runs the tests of the packages matching patterns once with
+// a coverage profile, for a report of their results and coverage. Failing
+// tests are part of the report rather than an error; coverage is nil when
+// no profile was written, as when nothing built.
+func CollectReport(workDir string, patterns []string) (*TestRun, *CoverageReport, error) {
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+	profile, err := os.CreateTemp("", "go-sentinel-cover-*.out")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create coverage profile: %w", err)
+	}
+	profile.Close()
+	defer os.Remove(profile.Name())
+
+	start := time.Now()
+	env := offlineEnviron(os.Environ())
+	args := append([]string{"test", "-json", "-coverprofile=" + profile.Name()}, patterns...)
+	cmd := exec.Command(goBinary, args...)
+	cmd.Dir = workDir
+	cmd.Env = env
+	output, runErr := cmd.CombinedOutput()
+
+	run, err := NewParser().Parse(bytes.NewReader(output))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse test output: %w", err)
+	}
+	if len(run.Suites) == 0 && runErr != nil {
+		return nil, nil, fmt.Errorf("failed to run tests: %w\n%s", runErr, output)
+	}
+	run.ID = newRunID()
+	run.StartTime, run.EndTime = start, time.Now()
+	run.Duration = run.EndTime.Sub(start)
+	run.Env = newEnvSnapshot(env, goVersion(workDir, env))
+	addBuildContext(run, workDir)
+
+	var coverage *CoverageReport
+	if f, err := os.Open(profile.Name()); err == nil {
+		defer f.Close()
+		if report, err := ReadCoverageReport(f); err == nil && len(report.Packages) > 0 {
+			coverage = report
+		}
+	}
+	return run, coverage, nil
+}
+
+// newHTMLReport collects the data of an HTML report of run
+func newHTMLReport(run *TestRun) htmlReport {
+	report := htmlReport{Title: "go-sentinel test report", Duration: FormatDurationAdaptive(run.Duration)}
+	if !run.StartTime.IsZero() {
+		report.Start = run.StartTime.Format(time.RFC3339)
+	}
+	if run.Env != nil {
+		report.Env = run.Env.String()
+	}
+
+	var slow []*TestResult
+	var longest time.Duration
+	for _, suite := range run.Suites {
+		pkg := htmlReportPackage{Name: suiteName(suite), Status: statusName(TestStatusPassed)}
+		for _, test := range suite.Tests {
+			row := htmlReportTest{Name: test.Name, Status: statusName(test.Status), Duration: FormatDurationPrecise(test.Duration), pkg: suite.Package}
+			report.Total++
+			switch test.Status {
+			case TestStatusFailed:
+				report.Failed++
+				pkg.Status = row.Status
+				if test.Error != nil {
+					row.Output = strings.TrimRight(test.Error.Message, "\n")
+					row.loc = test.Error.Location
+				}
+				pkg.Failures = append(pkg.Failures, row)
+			case TestStatusSkipped:
+				report.Skipped++
+			default:
+				report.Passed++
+			}
+			pkg.Tests = append(pkg.Tests, row)
+			if !strings.Contains(test.Name, "/") {
+				slow = append(slow, test)
+			}
+		}
+
+		// As in the JUnit report, a package failing outside any test is
+		// shown with its output so it does not look green. Compiler errors
+		// are shown one by one with their source.
+		if len(suite.BuildErrors) > 0 {
+			pkg.Status = statusName(TestStatusFailed)
+			for _, e := range suite.BuildErrors {
+				pkg.Failures = append(pkg.Failures, htmlReportTest{Name: "build failed", Output: e.Message, loc: e.Location})
+			}
+		} else if pkg.Status != statusName(TestStatusFailed) && len(suite.Errors) > 0 {
+			pkg.Status = statusName(TestStatusFailed)
+			pkg.Failures = append(pkg.Failures, htmlReportTest{Name: junitPackageFailure, Output: packageFailureOutput(suite)})
+		}
+		for i := range pkg.Failures {
+			addHTMLSnippet(&pkg.Failures[i], "", nil)
+		}
+		report.Packages = append(report.Packages, pkg)
+		longest = max(longest, suite.Duration)
+	}
+
+	// Charts only say something with more than one bar
+	if len(run.Suites) > 1 && longest > 0 {
+		suites := append([]*TestSuite{}, run.Suites...)
+		sort.SliceStable(suites, func(i, j int) bool { return suites[i].Duration > suites[j].Duration })
+		for _, suite := range suites {
+			status := statusName(TestStatusPassed)
+			if suite.NumFailed > 0 {
+				status = statusName(TestStatusFailed)
+			}
+			report.PackageBars = append(report.PackageBars, newHTMLReportBar(suiteName(suite), suite.Duration, longest, status))
+		}
+	}
+	sort.SliceStable(slow, func(i, j int) bool { return slow[i].Duration > slow[j].Duration })
+	if len(slow) > 1 && slow[0].Duration > 0 {
+		for _, test := range slow[:min(len(slow), htmlSlowTests)] {
+			report.SlowTests = append(report.SlowTests, newHTMLReportBar(test.Package+" "+test.Name, test.Duration, slow[0].Duration, statusName(test.Status)))
+		}
+	}
+	return report
+}
+
+// newHTMLReportBar returns a bar of a duration chart whose longest bar
+// lasted longest
+func newHTMLReportBar(name string, d, longest time.Duration, status string) htmlReportBar {
+	return htmlReportBar{
+		Name:     name,
+		Duration: FormatDurationAdaptive(d),
+		Status:   status,
+		Width:    strconv.FormatFloat(float64(d)/float64(longest)*100, 'f', 1, 64),
+	}
+}
+
+// addHTMLSnippet fills in where a failure was reported and, when the file
+// can be read, the source around it. Test failures name files relative to
+// their package, found in pkgDirs; compiler errors name them relative to
+// workDir.
+func addHTMLSnippet(row *htmlReportTest, workDir string, pkgDirs map[string]string) {
+	loc := row.loc
+	if loc == nil || loc.File == "" {
+		return
+	}
+	row.Location = loc.File
+	if loc.Line > 0 {
+		row.Location += ":" + strconv.Itoa(loc.Line)
+	}
+
+	snippet, start := loc.Snippet, loc.StartLine
+	if snippet == "" && pkgDirs != nil {
+		file := filepath.FromSlash(loc.File)
+		if dir, ok := pkgDirs[row.pkg]; ok && !filepath.IsAbs(file) && row.pkg != "" {
+			file = filepath.Join(dir, file)
+		} else if !filepath.IsAbs(file) {
+			file = filepath.Join(workDir, file)
+		}
+		snippet, start = sourceContext(readSourceLines(file), loc.Line, htmlSnippetLines)
+	}
+	if snippet == "" {
+		return
+	}
+	row.Snippet = nil
+	for i, text := range strings.Split(strings.TrimRight(snippet, "\n"), "\n") {
+		line := htmlReportLine{Number: start + i, Text: text}
+		if line.Number == loc.Line {
+			line.Class = "hl"
+		}
+		row.Snippet = append(row.Snippet, line)
+	}
+}
+
+// reportPackageDirs returns the directories of the packages of run and of
+// coverage by import path, or what is known of them when the go command
+// cannot list them
+func reportPackageDirs(workDir string, run *TestRun, coverage *CoverageReport) map[string]string {
+	var paths []string
+	for _, suite := range run.Suites {
+		paths = append(paths, suite.Package)
+	}
+	if coverage != nil {
+		for _, pkg := range coverage.Packages {
+			paths = append(paths, pkg.ImportPath)
+		}
+	}
+	dirs := make(map[string]string)
+	if len(paths) == 0 {
+		return dirs
+	}
+	pkgs, _ := expandPackagePatterns(workDir, paths)
+	for _, pkg := range pkgs {
+		dirs[pkg.ImportPath] = pkg.Dir
+	}
+	return dirs
+}
+
+// coverageLines returns the lines of a file, marked covered, not covered,
+// or partly covered when both covered and uncovered blocks touch them
+func coverageLines(lines []string, file *FileCoverage) []htmlReportLine {
+	in := func(ranges []LineRange, line int) bool {
+		for _, r := range ranges {
+			if r.Contains(line) {
+				return true
+			}
+		}
+		return false
+	}
+	result := make([]htmlReportLine, len(lines))
+	for i, text := range lines {
+		line := htmlReportLine{Number: i + 1, Text: text}
+		covered, uncovered := in(file.CoveredAt, line.Number), in(file.Uncovered, line.Number)
+		switch {
+		case covered && uncovered:
+			line.Class = "part"
+		case covered:
+			line.Class = "cov"
+		case uncovered:
+			line.Class = "unc"
+		}
+		result[i] = line
+	}
+	return result
+}
+
+// writeHTMLFile renders data with tmpl to the file at path
+func writeHTMLFile(path string, tmpl *template.Template, data any) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	if err := tmpl.Execute(f, data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return f.Close()
+}
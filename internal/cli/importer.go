@@ -0,0 +1,212 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// TriggerImport marks history records ingested from external result files
+const TriggerImport = "import"
+
+// junitSuites is the root of a JUnit XML report. Reports may use either a
+// <testsuites> wrapper or a single <testsuite> root element.
+type junitSuites struct {
+	XMLName xml.Name     `xml:""`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+// junitSuite is a <testsuite> element
+type junitSuite struct {
+	Name      string          `xml:"name,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Time      float64         `xml:"time,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+	Suites    []junitSuite    `xml:"testsuite"` // Nested suites, as produced by some tools
+}
+
+// junitTestCase is a <testcase> element
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure"`
+	Error     *junitProblem `xml:"error"`
+	Skipped   *junitProblem `xml:"skipped"`
+	SystemOut string        `xml:"system-out"`
+}
+
+// junitProblem is a <failure>, <error>, or <skipped> element
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// ImportResults reads a JUnit XML, go test -json, or plain go test output
+// file produced outside go-sentinel and converts it to a test run. The
+// format is detected from the file content.
+func ImportResults(path string) (*TestRun, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read results: %w", err)
+	}
+
+	var run *TestRun
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		run, err = parseJUnit(trimmed)
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		run, err = parseGoTestJSON(trimmed)
+	default:
+		run, err = parseGoTestText(trimmed)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(run.Suites) == 0 {
+		return nil, fmt.Errorf("%s: no test results found", path)
+	}
+
+	// Fall back to the file time when the results carry no timestamps
+	if run.StartTime.IsZero() {
+		if info, err := os.Stat(path); err == nil {
+			run.StartTime = info.ModTime().Add(-run.Duration)
+		}
+	}
+	run.EndTime = run.StartTime.Add(run.Duration)
+	run.ID = newRunID()
+	return run, nil
+}
+
+// parseGoTestJSON converts go test -json output, taking run timing from
+// the event timestamps rather than the time of import
+func parseGoTestJSON(data []byte) (*TestRun, error) {
+	run, err := NewParser().Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	var start, end time.Time
+	for _, suite := range run.Suites {
+		if !suite.StartTime.IsZero() && (start.IsZero() || suite.StartTime.Before(start)) {
+			start = suite.StartTime
+		}
+		if suite.EndTime.After(end) {
+			end = suite.EndTime
+		}
+	}
+	run.StartTime = start
+	if !start.IsZero() && end.After(start) {
+		run.Duration = end.Sub(start)
+	}
+	return run, nil
+}
+
+// parseJUnit converts a JUnit XML report. Each suite becomes a package.
+func parseJUnit(data []byte) (*TestRun, error) {
+	var root junitSuites
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("invalid JUnit XML: %w", err)
+	}
+
+	var suites []junitSuite
+	switch root.XMLName.Local {
+	case "testsuites":
+		suites = root.Suites
+	case "testsuite":
+		var single junitSuite
+		if err := xml.Unmarshal(data, &single); err != nil {
+			return nil, fmt.Errorf("invalid JUnit XML: %w", err)
+		}
+		suites = []junitSuite{single}
+	default:
+		return nil, fmt.Errorf("invalid JUnit XML: unexpected root element <%s>", root.XMLName.Local)
+	}
+
+	run := &TestRun{}
+	var addSuite func(s junitSuite)
+	addSuite = func(s junitSuite) {
+		for _, nested := range s.Suites {
+			addSuite(nested)
+		}
+		if len(s.Cases) == 0 {
+			return
+		}
+
+		suite := &TestSuite{Package: s.Name, PackageName: s.Name}
+		if t, err := time.Parse("2006-01-02T15:04:05", strings.TrimSuffix(s.Timestamp, "Z")); err == nil {
+			suite.StartTime = t
+			if run.StartTime.IsZero() || t.Before(run.StartTime) {
+				run.StartTime = t
+			}
+		}
+		for _, tc := range s.Cases {
+			test := junitTestResult(tc)
+			if suite.Package == "" {
+				suite.Package = tc.Classname
+				suite.PackageName = tc.Classname
+			}
+			test.Package = suite.Package
+			suite.Tests = append(suite.Tests, test)
+			suite.NumTotal++
+			switch test.Status {
+			case TestStatusFailed:
+				suite.NumFailed++
+			case TestStatusSkipped:
+				suite.NumSkipped++
+			default:
+				suite.NumPassed++
+			}
+			suite.Duration += test.Duration
+		}
+		if s.Time > 0 {
+			suite.Duration = DurationFromSeconds(s.Time)
+		}
+
+		run.Suites = append(run.Suites, suite)
+		run.NumTotal += suite.NumTotal
+		run.NumPassed += suite.NumPassed
+		run.NumFailed += suite.NumFailed
+		run.NumSkipped += suite.NumSkipped
+		run.Duration += suite.Duration
+		for _, test := range suite.Tests {
+			if test.Status == TestStatusFailed {
+				run.FailedTests = append(run.FailedTests, test)
+			}
+		}
+	}
+	for _, s := range suites {
+		addSuite(s)
+	}
+	return run, nil
+}
+
+// junitTestResult converts a single JUnit test case
+func junitTestResult(tc junitTestCase) *TestResult {
+	test := &TestResult{
+		Name:     tc.Name,
+		Status:   TestStatusPassed,
+		Duration: DurationFromSeconds(tc.Time),
+	}
+
+	problem := tc.Failure
+	if problem == nil {
+		problem = tc.Error
+	}
+	switch {
+	case problem != nil:
+		test.Status = TestStatusFailed
+		message := strings.TrimSpace(problem.Text)
+		if message == "" {
+			message = problem.Message
+		}
+		test.Error = &TestError{Message: message}
+	case tc.Skipped != nil:
+		test.Status = TestStatusSkipped
+	}
+	return test
+}
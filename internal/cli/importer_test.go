@@ -0,0 +1,157 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImportResults_JUnit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.xml")
+	report := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="example.com/api" tests="3" time="1.5" timestamp="2024-03-01T10:00:00">
+    <testcase classname="example.com/api" name="TestGet" time="0.5"></testcase>
+    <testcase classname="example.com/api" name="TestPost" time="0.75">
+      <failure message="status 500">want 200, got 500</failure>
+    </testcase>
+    <testcase classname="example.com/api" name="TestSlow" time="0"><skipped message="short mode"/></testcase>
+  </testsuite>
+</testsuites>`
+	if err := os.WriteFile(path, []byte(report), 0644); err != nil {
+		t.Fatalf("Failed to write report: %v", err)
+	}
+
+	run, err := ImportResults(path)
+	if err != nil {
+		t.Fatalf("ImportResults failed: %v", err)
+	}
+
+	if run.NumTotal != 3 || run.NumPassed != 1 || run.NumFailed != 1 || run.NumSkipped != 1 {
+		t.Errorf("counts = %d total, %d passed, %d failed, %d skipped, want 3/1/1/1",
+			run.NumTotal, run.NumPassed, run.NumFailed, run.NumSkipped)
+	}
+	if want := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC); !run.StartTime.Equal(want) {
+		t.Errorf("StartTime = %v, want %v", run.StartTime, want)
+	}
+	if run.Duration != 1500*time.Millisecond {
+		t.Errorf("Duration = %v, want 1.5s", run.Duration)
+	}
+	failed := run.Suites[0].Tests[1]
+	if failed.Status != TestStatusFailed || failed.Error == nil || failed.Error.Message != "want 200, got 500" {
+		t.Errorf("failed test = %+v, want failure message", failed)
+	}
+	if run.ID == "" {
+		t.Error("Expected imported run to get an ID")
+	}
+}
+
+func TestImportResults_GoTestJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.json")
+	events := `{"Time":"2024-03-01T10:00:00Z","Action":"start","Package":"example.com/pkg"}
+{"Time":"2024-03-01T10:00:00.1Z","Action":"run","Package":"example.com/pkg","Test":"TestA"}
+{"Time":"2024-03-01T10:00:00.2Z","Action":"pass","Package":"example.com/pkg","Test":"TestA","Elapsed":0.1}
+{"Time":"2024-03-01T10:00:02Z","Action":"pass","Package":"example.com/pkg","Elapsed":2}
+`
+	if err := os.WriteFile(path, []byte(events), 0644); err != nil {
+		t.Fatalf("Failed to write results: %v", err)
+	}
+
+	run, err := ImportResults(path)
+	if err != nil {
+		t.Fatalf("ImportResults failed: %v", err)
+	}
+	if run.NumPassed != 1 {
+		t.Errorf("NumPassed = %d, want 1", run.NumPassed)
+	}
+	if want := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC); !run.StartTime.Equal(want) {
+		t.Errorf("StartTime = %v, want %v", run.StartTime, want)
+	}
+	if run.Duration != 2*time.Second {
+		t.Errorf("Duration = %v, want 2s", run.Duration)
+	}
+}
+
+func TestImportResults_Unrecognized(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.txt")
+	if err := os.WriteFile(path, []byte("ok  example.com/pkg 0.1s\n"), 0644); err != nil {
+		t.Fatalf("Failed to write results: %v", err)
+	}
+	if _, err := ImportResults(path); err == nil {
+		t.Error("Expected error for unrecognized format")
+	}
+}
+
+func TestImportResults_GoTestText(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ci.log")
+	log := "=== RUN   TestA\n" +
+		"--- PASS: TestA (0.10s)\n" +
+		"=== RUN   TestB\n" +
+		"=== RUN   TestB/sub\n" +
+		"    b_test.go:12: want 1, got 2\n" +
+		"--- FAIL: TestB (0.20s)\n" +
+		"    --- FAIL: TestB/sub (0.20s)\n" +
+		"FAIL\n" +
+		"FAIL\texample.com/one\t0.350s\n" +
+		"--- FAIL: TestC (0.00s)\n" +
+		"    c_test.go:5: boom\n" +
+		"FAIL\n" +
+		"FAIL\texample.com/two\t1.5s\n" +
+		"ok  \texample.com/three\t(cached)\n" +
+		"?   \texample.com/four\t[no test files]\n"
+	if err := os.WriteFile(path, []byte(log), 0644); err != nil {
+		t.Fatalf("Failed to write log: %v", err)
+	}
+
+	run, err := ImportResults(path)
+	if err != nil {
+		t.Fatalf("ImportResults failed: %v", err)
+	}
+	if len(run.Suites) != 4 {
+		t.Fatalf("got %d packages, want 4", len(run.Suites))
+	}
+	one := run.Suites[1]
+	if one.Package != "example.com/one" || len(one.Tests) != 3 || one.Duration != 350*time.Millisecond {
+		t.Errorf("example.com/one = %s with %d tests in %v, want 3 tests in 350ms", one.Package, len(one.Tests), one.Duration)
+	}
+	sub := one.Tests[2]
+	if sub.Name != "TestB/sub" || sub.Status != TestStatusFailed {
+		t.Errorf("subtest = %s %v, want TestB/sub failed", sub.Name, sub.Status)
+	}
+	if sub.Error == nil || !strings.Contains(sub.Error.Message, "want 1, got 2") {
+		t.Errorf("TestB/sub output = %+v, want the logged failure", sub.Error)
+	}
+
+	// Without -v, failed tests appear only through their result line
+	two := run.Suites[3]
+	if len(two.Tests) != 1 || two.Tests[0].Error == nil || !strings.Contains(two.Tests[0].Error.Message, "boom") {
+		t.Errorf("example.com/two tests = %+v, want TestC with its output", two.Tests)
+	}
+	if run.Duration != 1850*time.Millisecond {
+		t.Errorf("Duration = %v, want the sum of package times", run.Duration)
+	}
+}
+
+func TestImportResults_GoTestTextTimestamps(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ci.log")
+	log := "2024-03-01T10:00:00.0000000Z === RUN   TestA\n" +
+		"2024-03-01T10:00:01.0000000Z --- PASS: TestA (1.00s)\n" +
+		"2024-03-01T10:00:01.0000000Z PASS\n" +
+		"2024-03-01T10:00:03.0000000Z ok  \texample.com/pkg\t3.0s\n"
+	if err := os.WriteFile(path, []byte(log), 0644); err != nil {
+		t.Fatalf("Failed to write log: %v", err)
+	}
+
+	run, err := ImportResults(path)
+	if err != nil {
+		t.Fatalf("ImportResults failed: %v", err)
+	}
+	if want := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC); !run.StartTime.Equal(want) {
+		t.Errorf("StartTime = %v, want %v", run.StartTime, want)
+	}
+	if run.NumPassed != 1 || run.Duration != 3*time.Second {
+		t.Errorf("passed %d in %v, want 1 in 3s", run.NumPassed, run.Duration)
+	}
+}
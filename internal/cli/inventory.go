@@ -0,0 +1,434 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/build/constraint"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// InventoryVersion is the version of the inventory JSON format. Fields may
+// be added within a version; none are removed or change meaning.
+const InventoryVersion = 1
+
+// Kinds of test functions in an inventory
+const (
+	InventoryKindTest      = "test"
+	InventoryKindBenchmark = "benchmark"
+	InventoryKindFuzz      = "fuzz"
+	InventoryKindExample   = "example"
+)
+
+// Inventory lists every test function of a project, including those only
+// built under build constraints, for audits proving which tests exist
+type Inventory struct {
+	Version     int                 `json:"version"`
+	GeneratedAt time.Time           `json:"generatedAt"`
+	Packages    []*InventoryPackage `json:"packages"`
+}
+
+// InventoryPackage is a package with tests and its owners
+type InventoryPackage struct {
+	ImportPath string           `json:"importPath"`
+	Dir        string           `json:"dir"` // Slash path relative to the project root
+	Owners     []string         `json:"owners,omitempty"`
+	Tests      []*InventoryTest `json:"tests"`
+}
+
+// InventoryTest is one test, benchmark, fuzz target, or example
+type InventoryTest struct {
+	Name       string   `json:"name"`
+	Kind       string   `json:"kind"`
+	File       string   `json:"file"` // File name within the package directory
+	Line       int      `json:"line"`
+	Constraint string   `json:"constraint,omitempty"` // Build constraint of the file, such as "integration && linux"
+	Tags       []string `json:"tags,omitempty"`       // Build tags named by the constraint
+	Owners     []string `json:"owners,omitempty"`
+
+	Subtests []*InventorySubtest `json:"subtests,omitempty"` // Subtests found in the source, see staticSubtests
+}
+
+// InventorySubtest is a subtest started with t.Run or b.Run under a name
+// known without running the test
+type InventorySubtest struct {
+	Name string `json:"name"` // Full name as go test reports it, such as "TestParse/empty_input"
+	Line int    `json:"line"`
+}
+
+// Count returns the number of tests of a kind in the inventory
+func (inv *Inventory) Count(kind string) int {
+	n := 0
+	for _, pkg := range inv.Packages {
+		for _, test := range pkg.Tests {
+			if test.Kind == kind {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// WriteJSON writes the inventory as indented JSON
+func (inv *Inventory) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(inv); err != nil {
+		return fmt.Errorf("failed to write inventory: %w", err)
+	}
+	return nil
+}
+
+// BuildInventory lists the tests of the packages matching patterns,
+// including test files excluded by build constraints, with the owners
+// assigned in the ownership file or, failing that, CODEOWNERS
+func BuildInventory(workDir string, patterns []string) (*Inventory, error) {
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+	pkgs, err := listPackagesLenient(workDir, patterns)
+	if err != nil {
+		return nil, err
+	}
+	excluded, err := excludedTestPackages(workDir, patterns, pkgs)
+	if err != nil {
+		return nil, err
+	}
+	pkgs = append(pkgs, excluded...)
+	ownership, err := LoadOwnership(workDir)
+	if err != nil {
+		return nil, err
+	}
+	codeOwners, err := LoadCodeOwners(workDir)
+	if err != nil {
+		log.Printf("Error loading CODEOWNERS: %v", err)
+		codeOwners = &CodeOwners{}
+	}
+
+	inv := &Inventory{Version: InventoryVersion, GeneratedAt: time.Now().UTC(), Packages: []*InventoryPackage{}}
+	for _, pkg := range pkgs {
+		entry, err := inventoryPackage(workDir, pkg, ownership, codeOwners)
+		if err != nil {
+			return nil, err
+		}
+		if len(entry.Tests) > 0 {
+			inv.Packages = append(inv.Packages, entry)
+		}
+	}
+	sort.Slice(inv.Packages, func(i, j int) bool { return inv.Packages[i].ImportPath < inv.Packages[j].ImportPath })
+	return inv, nil
+}
+
+// inventoryPackage lists the test functions of every test file of pkg
+func inventoryPackage(workDir string, pkg *PackageInfo, ownership Ownership, codeOwners *CodeOwners) (*InventoryPackage, error) {
+	dir := mustRel(workDir, pkg.Dir)
+	entry := &InventoryPackage{ImportPath: pkg.ImportPath, Dir: filepath.ToSlash(dir)}
+	entry.Owners, _ = ownership.Lookup(pkg.ImportPath, "")
+	if len(entry.Owners) == 0 {
+		entry.Owners = codeOwners.Owners(entry.Dir)
+	}
+
+	var files []string
+	files = append(files, pkg.TestGoFiles...)
+	files = append(files, pkg.XTestGoFiles...)
+	for _, name := range pkg.IgnoredGoFiles {
+		if strings.HasSuffix(name, "_test.go") {
+			files = append(files, name)
+		}
+	}
+	sort.Strings(files)
+
+	fset := token.NewFileSet()
+	for _, name := range files {
+		f, err := parser.ParseFile(fset, filepath.Join(pkg.Dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+		}
+		expr, tags := fileConstraint(f, name)
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv != nil || !isTestFunc(fn.Name.Name) {
+				continue
+			}
+			test := &InventoryTest{
+				Name:       fn.Name.Name,
+				Kind:       inventoryKind(fn.Name.Name),
+				File:       name,
+				Line:       fset.Position(fn.Pos()).Line,
+				Constraint: expr,
+				Tags:       tags,
+				Subtests:   staticSubtests(fset, fn),
+			}
+			test.Owners, _ = ownership.Lookup(pkg.ImportPath, test.Name)
+			if len(test.Owners) == 0 {
+				test.Owners = codeOwners.Owners(filepath.ToSlash(filepath.Join(dir, name)))
+			}
+			entry.Tests = append(entry.Tests, test)
+		}
+	}
+	return entry, nil
+}
+
+// excludedTestPackages returns the packages under the directory patterns
+// ("./...", "./dir/...", or "./dir") that go list leaves out because build
+// constraints exclude every file, such as a directory of end-to-end tests
+// all behind a build tag
+func excludedTestPackages(workDir string, patterns []string, listed []*PackageInfo) ([]*PackageInfo, error) {
+	seen := make(map[string]bool)
+	for _, pkg := range listed {
+		seen[pkg.Dir] = true
+	}
+
+	var dirs []string
+	for _, pattern := range patterns {
+		if pattern != "." && !strings.HasPrefix(pattern, "./") {
+			continue // Import paths name packages go list already found
+		}
+		root, recursive := strings.CutSuffix(pattern, "/...")
+		root = filepath.Join(workDir, root)
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || !d.IsDir() {
+				return err
+			}
+			name := d.Name()
+			if path != root && (!recursive || name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			if path != root {
+				if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+					return filepath.SkipDir // Another module
+				}
+			}
+			if seen[path] {
+				return nil
+			}
+			if tests, _ := filepath.Glob(filepath.Join(path, "*_test.go")); len(tests) > 0 {
+				seen[path] = true
+				dirs = append(dirs, "./"+filepath.ToSlash(mustRel(workDir, path)))
+			}
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to find test packages: %w", err)
+		}
+	}
+	if len(dirs) == 0 {
+		return nil, nil
+	}
+	return listPackagesLenient(workDir, dirs)
+}
+
+// listPackagesLenient is expandPackagePatterns for packages that may not
+// build: go list -e reports the files their build constraints exclude
+// instead of failing
+func listPackagesLenient(workDir string, patterns []string) ([]*PackageInfo, error) {
+	cmd := exec.Command(goBinary, append([]string{"list", "-e", "-json"}, patterns...)...)
+	cmd.Dir = workDir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list packages: %w: %s", err, stderr.String())
+	}
+	pkgs, err := decodePackageList(bytes.NewReader(output))
+	if err != nil {
+		return nil, err
+	}
+	for _, pkg := range pkgs {
+		// Packages that exist have a directory, however broken they are
+		if pkg.Dir == "" && pkg.Error != nil {
+			return nil, fmt.Errorf("failed to list packages: %s", pkg.Error.Err)
+		}
+	}
+	return pkgs, nil
+}
+
+// mustRel returns path relative to base, or path itself when it has no
+// relative form
+func mustRel(base, path string) string {
+	rel, err := filepath.Rel(base, path)
+	if err != nil {
+		return path
+	}
+	return rel
+}
+
+// inventoryKind returns the kind of a test function from its prefix
+func inventoryKind(name string) string {
+	switch {
+	case strings.HasPrefix(name, "Benchmark"):
+		return InventoryKindBenchmark
+	case strings.HasPrefix(name, "Fuzz"):
+		return InventoryKindFuzz
+	case strings.HasPrefix(name, "Example"):
+		return InventoryKindExample
+	}
+	return InventoryKindTest
+}
+
+// staticSubtests returns the subtests fn starts with a string literal
+// name, including those nested in them. Subtests named at run time, as
+// table-driven tests do, are left out along with any nested in them.
+func staticSubtests(fset *token.FileSet, fn *ast.FuncDecl) []*InventorySubtest {
+	var subtests []*InventorySubtest
+	seen := make(map[string]bool)
+	var walk func(body ast.Node, parent string)
+	walk = func(body ast.Node, parent string) {
+		ast.Inspect(body, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok || len(call.Args) != 2 {
+				return true
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok || sel.Sel.Name != "Run" {
+				return true
+			}
+			lit, ok := call.Args[1].(*ast.FuncLit)
+			if !ok || !isSubtestFunc(lit) {
+				return true
+			}
+			name, ok := call.Args[0].(*ast.BasicLit)
+			if !ok || name.Kind != token.STRING {
+				return false
+			}
+			value, err := strconv.Unquote(name.Value)
+			if err != nil {
+				return false
+			}
+			full := parent + "/" + rewriteSubtestName(value)
+			if !seen[full] {
+				seen[full] = true
+				subtests = append(subtests, &InventorySubtest{Name: full, Line: fset.Position(call.Pos()).Line})
+			}
+			walk(lit.Body, full)
+			return false
+		})
+	}
+	if fn.Body != nil {
+		walk(fn.Body, fn.Name.Name)
+	}
+	return subtests
+}
+
+// isSubtestFunc reports whether lit has the signature of a subtest:
+// one parameter of type *testing.T or *testing.B, under any import name
+func isSubtestFunc(lit *ast.FuncLit) bool {
+	params := lit.Type.Params.List
+	if len(params) != 1 || len(params[0].Names) > 1 {
+		return false
+	}
+	star, ok := params[0].Type.(*ast.StarExpr)
+	if !ok {
+		return false
+	}
+	sel, ok := star.X.(*ast.SelectorExpr)
+	return ok && (sel.Sel.Name == "T" || sel.Sel.Name == "B")
+}
+
+// rewriteSubtestName rewrites a subtest name as go test reports it:
+// spaces become underscores and unprintable characters are escaped
+func rewriteSubtestName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case unicode.IsSpace(r):
+			b.WriteByte('_')
+		case !strconv.IsPrint(r):
+			quoted := strconv.QuoteRune(r)
+			b.WriteString(quoted[1 : len(quoted)-1])
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// fileConstraint returns the build constraint of a Go file, combining its
+// //go:build line with the GOOS and GOARCH suffixes of its name, and the
+// tags the constraint names
+func fileConstraint(f *ast.File, name string) (string, []string) {
+	var parts []string
+	for _, group := range f.Comments {
+		if group.Pos() >= f.Package {
+			break
+		}
+		for _, c := range group.List {
+			if !constraint.IsGoBuild(c.Text) {
+				continue
+			}
+			if expr, err := constraint.Parse(c.Text); err == nil {
+				parts = append(parts, expr.String())
+			}
+		}
+	}
+	parts = append(parts, fileNameConstraints(name)...)
+
+	var tags []string
+	for _, part := range parts {
+		expr, err := constraint.Parse("//go:build " + part)
+		if err != nil {
+			continue
+		}
+		expr.Eval(func(tag string) bool {
+			if !containsString(tags, tag) {
+				tags = append(tags, tag)
+			}
+			return true
+		})
+	}
+	sort.Strings(tags)
+
+	if len(parts) > 1 {
+		for i, part := range parts {
+			if strings.ContainsAny(part, "|") {
+				parts[i] = "(" + part + ")"
+			}
+		}
+	}
+	return strings.Join(parts, " && "), tags
+}
+
+// fileNameConstraints returns the GOOS and GOARCH a file name restricts
+// the file to, as in foo_linux_test.go or foo_windows_amd64_test.go
+func fileNameConstraints(name string) []string {
+	fields := strings.Split(strings.TrimSuffix(strings.TrimSuffix(name, ".go"), "_test"), "_")
+	if len(fields) < 2 {
+		return nil
+	}
+	last := fields[len(fields)-1]
+	if len(fields) >= 3 && knownOS[fields[len(fields)-2]] && knownArch[last] {
+		return []string{fields[len(fields)-2], last}
+	}
+	if knownOS[last] || knownArch[last] {
+		return []string{last}
+	}
+	return nil
+}
+
+// knownOS and knownArch are the GOOS and GOARCH values go build recognizes
+// in file names
+var (
+	knownOS = map[string]bool{
+		"aix": true, "android": true, "darwin": true, "dragonfly": true, "freebsd": true, "hurd": true,
+		"illumos": true, "ios": true, "js": true, "linux": true, "nacl": true, "netbsd": true, "openbsd": true,
+		"plan9": true, "solaris": true, "wasip1": true, "windows": true, "zos": true,
+	}
+	knownArch = map[string]bool{
+		"386": true, "amd64": true, "arm": true, "arm64": true, "loong64": true, "mips": true, "mipsle": true,
+		"mips64": true, "mips64le": true, "ppc64": true, "ppc64le": true, "riscv64": true, "s390x": true, "wasm": true,
+	}
+)
@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestInventoryPackage(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"db_test.go":             "// Copyright\n\n//go:build integration && !short\n\npackage db\n\nimport \"testing\"\n\nfunc TestQuery(t *testing.T) {}\n\nfunc BenchmarkQuery(b *testing.B) {}\n",
+		"db_linux_amd64_test.go": "//go:build race || cgo\n\npackage db\n\nimport \"testing\"\n\nfunc FuzzParse(f *testing.F) {}\n",
+		"db_ext_test.go":         "package db_test\n\nfunc ExampleOpen() {}\n\nfunc helper() {}\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ownership := Ownership{"example.com/app/db": {Owners: []string{"@data"}, Tests: map[string]*TestOwnership{
+		"FuzzParse": {Owners: []string{"@security"}},
+	}}}
+	pkg, err := inventoryPackage(filepath.Dir(dir), &PackageInfo{
+		ImportPath:     "example.com/app/db",
+		Dir:            dir,
+		XTestGoFiles:   []string{"db_ext_test.go"},
+		IgnoredGoFiles: []string{"db_linux_amd64_test.go", "db_test.go"},
+	}, ownership, &CodeOwners{})
+	if err != nil {
+		t.Fatalf("inventoryPackage failed: %v", err)
+	}
+	if !reflect.DeepEqual(pkg.Owners, []string{"@data"}) || pkg.Dir != filepath.Base(dir) {
+		t.Errorf("package = %+v", pkg)
+	}
+
+	want := []InventoryTest{
+		{Name: "ExampleOpen", Kind: InventoryKindExample, File: "db_ext_test.go", Line: 3, Owners: []string{"@data"}},
+		{Name: "FuzzParse", Kind: InventoryKindFuzz, File: "db_linux_amd64_test.go", Line: 7,
+			Constraint: "(race || cgo) && linux && amd64", Tags: []string{"amd64", "cgo", "linux", "race"}, Owners: []string{"@security"}},
+		{Name: "TestQuery", Kind: InventoryKindTest, File: "db_test.go", Line: 9,
+			Constraint: "integration && !short", Tags: []string{"integration", "short"}, Owners: []string{"@data"}},
+		{Name: "BenchmarkQuery", Kind: InventoryKindBenchmark, File: "db_test.go", Line: 11,
+			Constraint: "integration && !short", Tags: []string{"integration", "short"}, Owners: []string{"@data"}},
+	}
+	if len(pkg.Tests) != len(want) {
+		t.Fatalf("Tests = %d, want %d", len(pkg.Tests), len(want))
+	}
+	for i, test := range pkg.Tests {
+		if !reflect.DeepEqual(*test, want[i]) {
+			t.Errorf("Tests[%d] = %+v, want %+v", i, *test, want[i])
+		}
+	}
+}
+
+func TestFileNameConstraints(t *testing.T) {
+	tests := map[string][]string{
+		"a_test.go":               nil,
+		"linux_test.go":           nil,
+		"a_linux_test.go":         {"linux"},
+		"a_arm64_test.go":         {"arm64"},
+		"a_windows_amd64_test.go": {"windows", "amd64"},
+		"a_amd64_linux_test.go":   {"linux"},
+	}
+	for name, want := range tests {
+		if got := fileNameConstraints(name); !reflect.DeepEqual(got, want) {
+			t.Errorf("fileNameConstraints(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestStaticSubtests(t *testing.T) {
+	src := `package parse
+
+import (
+	"testing"
+	tst "testing"
+)
+
+func TestParse(t *testing.T) {
+	t.Run("empty input", func(t *testing.T) {
+		t.Run("with\ttab", func(t *testing.T) {})
+	})
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Run("hidden", func(t *testing.T) {})
+		})
+	}
+	t.Run("aliased", func(st *tst.T) {})
+	t.Run("empty input", func(t *testing.T) {})
+	cmd.Run("not a test", func() {})
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "parse_test.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn := f.Decls[1].(*ast.FuncDecl)
+
+	got := staticSubtests(fset, fn)
+	want := []*InventorySubtest{
+		{Name: "TestParse/empty_input", Line: 9},
+		{Name: "TestParse/empty_input/with_tab", Line: 10},
+		{Name: "TestParse/aliased", Line: 17},
+	}
+	if !reflect.DeepEqual(got, want) {
+		for _, sub := range got {
+			t.Logf("got %+v", *sub)
+		}
+		t.Errorf("staticSubtests() = %d subtests, want %v", len(got), want)
+	}
+}
@@ -0,0 +1,239 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// runIsolated runs every selected package in its own temporary workspace so
+// that tests writing into their working directory cannot collide. Each
+// package's test binary is compiled in place (sharing the build and module
+// caches), the package directory is copied into the workspace, and the binary
+// is run from there through test2json. The combined JSON output is returned;
+// the error is the first failing package's exit error. Package timeouts
+// and the deadline count from start.
+func (r *Runner) runIsolated(opts RunOptions, start time.Time) ([]byte, error) {
+	pkgs, err := r.pkgCache.Get(opts.Packages)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := os.MkdirTemp("", "go-sentinel-isolate-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create isolated workspace: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(root); err != nil {
+			log.Printf("Error removing isolated workspace: %v", err)
+		}
+	}()
+
+	outputs := make([][]byte, len(pkgs))
+	errs := make([]error, len(pkgs))
+	sem := make(chan struct{}, opts.parallelism())
+	var wg sync.WaitGroup
+	for i, pkg := range pkgs {
+		if !pkg.HasTests() {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, pkg *PackageInfo) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			outputs[i], errs[i] = r.runPackageIsolated(filepath.Join(root, strconv.Itoa(i)), pkg, opts, start)
+		}(i, pkg)
+	}
+	wg.Wait()
+
+	var combined bytes.Buffer
+	var firstErr error
+	for i := range pkgs {
+		combined.Write(outputs[i])
+		if errs[i] != nil && firstErr == nil {
+			firstErr = errs[i]
+		}
+	}
+	return combined.Bytes(), firstErr
+}
+
+// runPackageIsolated compiles and runs a single package's tests inside dir
+func (r *Runner) runPackageIsolated(dir string, pkg *PackageInfo, opts RunOptions, start time.Time) ([]byte, error) {
+	binary := filepath.Join(dir, "pkg.test")
+	if runtime.GOOS == "windows" {
+		binary += ".exe"
+	}
+
+	// Compile the test binary from the real package directory
+	buildArgs := append([]string{"test", "-c", "-o", binary}, opts.BuildFlags...)
+	build := exec.Command(goBinary, append(buildArgs, pkg.ImportPath)...)
+	build.Dir = r.workDir
+	build.Env = opts.environ()
+	if output, err := combinedOutput(build, opts.Priority, nil); err != nil {
+		return syntheticFailure(pkg.ImportPath, string(output)), err
+	}
+
+	// Copy the package (including testdata) into the workspace
+	workspace := filepath.Join(dir, "work")
+	if err := copyDir(pkg.Dir, workspace); err != nil {
+		return syntheticFailure(pkg.ImportPath, err.Error()), err
+	}
+
+	args := []string{"tool", "test2json", "-p", pkg.ImportPath, "-t"}
+	env := opts.environ()
+	if opts.SetupTimeout > 0 || opts.Limits != nil || opts.enforcesTimeouts() {
+		watchdog, err := setupWatchdog(opts.SetupTimeout)
+		if err != nil {
+			return syntheticFailure(pkg.ImportPath, err.Error()), err
+		}
+		args = append(args, watchdog...)
+	}
+	if opts.Limits != nil {
+		// The binary runs in the workspace, not the package directory
+		limits, err := limitsEnvFor(map[string]ResourceLimits{workspace: opts.Limits.forBuild(opts.BuildFlags).For(pkg.ImportPath)})
+		if err != nil {
+			return syntheticFailure(pkg.ImportPath, err.Error()), err
+		}
+		env = append(env, limits)
+	}
+	if opts.enforcesTimeouts() {
+		timeouts, err := timeoutsEnvFor(map[string]BinaryTimeout{workspace: opts.binaryTimeout(pkg.ImportPath, start)})
+		if err != nil {
+			return syntheticFailure(pkg.ImportPath, err.Error()), err
+		}
+		env = append(env, timeouts)
+	}
+	args = append(args, binary, "-test.v=test2json")
+	if opts.FailFast {
+		args = append(args, "-test.failfast")
+	}
+	if timeout := opts.isolatedTimeout(); timeout > 0 {
+		args = append(args, "-test.timeout", timeout.String())
+	}
+	if len(opts.Tests) > 0 {
+		args = append(args, "-test.run", strings.Join(opts.Tests, "|"))
+	}
+	if opts.Shuffle {
+		args = append(args, "-test.shuffle", strconv.FormatInt(opts.Seed, 10))
+	}
+	if opts.Bench != "" {
+		args = append(args, "-test.bench", opts.Bench, "-test.benchmem")
+	}
+	if opts.Parallel != nil {
+		if n := opts.Parallel.For(pkg.ImportPath); n > 0 {
+			args = append(args, "-test.parallel", strconv.Itoa(n))
+		}
+	}
+
+	cmd := exec.Command(goBinary, args...)
+	cmd.Dir = workspace
+	cmd.Env = env
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := startCommand(cmd, opts.Priority); err != nil {
+		return nil, err
+	}
+	err := cmd.Wait()
+	output := stdout.Bytes()
+	if stderr.Len() > 0 {
+		output = append(output, syntheticOutput(pkg.ImportPath, stderr.String())...)
+	}
+	return output, err
+}
+
+// syntheticFailure renders a package-level failure as go test -json events
+func syntheticFailure(pkg, message string) []byte {
+	var buf bytes.Buffer
+	buf.Write(syntheticEvent(GoTestEvent{Action: "start", Package: pkg}))
+	buf.Write(syntheticOutput(pkg, message))
+	buf.Write(syntheticEvent(GoTestEvent{Action: "fail", Package: pkg}))
+	return buf.Bytes()
+}
+
+// syntheticOutput renders text as package-level output events
+func syntheticOutput(pkg, text string) []byte {
+	var buf bytes.Buffer
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if line != "" {
+			buf.Write(syntheticEvent(GoTestEvent{Action: "output", Package: pkg, Output: line}))
+		}
+	}
+	return buf.Bytes()
+}
+
+// syntheticEvent encodes a single go test -json event line
+func syntheticEvent(event GoTestEvent) []byte {
+	event.Time = time.Now()
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil
+	}
+	return append(data, '\n')
+}
+
+// copyDir recursively copies the contents of src into dst, skipping hidden
+// directories and nested modules
+func copyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if d.IsDir() {
+			if path != src {
+				if strings.HasPrefix(d.Name(), ".") {
+					return filepath.SkipDir
+				}
+				if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+					return filepath.SkipDir
+				}
+			}
+			return os.MkdirAll(target, 0755)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		return copyFile(path, target)
+	})
+}
+
+// copyFile copies a single file, preserving its permission bits
+func copyFile(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunner_Isolate(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example\n\ngo 1.23\n"), 0600); err != nil {
+		t.Fatalf("Failed to create go.mod: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "testdata"), 0755); err != nil {
+		t.Fatalf("Failed to create testdata: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "testdata", "input.txt"), []byte("fixture"), 0600); err != nil {
+		t.Fatalf("Failed to write fixture: %v", err)
+	}
+
+	// The test reads a fixture and writes into its working directory
+	testFile := filepath.Join(dir, "example_test.go")
+	err := os.WriteFile(testFile, []byte(`package example
+
+import (
+	"os"
+	"testing"
+)
+
+func TestWritesToCWD(t *testing.T) {
+	data, err := os.ReadFile("testdata/input.txt")
+	if err != nil || string(data) != "fixture" {
+		t.Fatalf("fixture not available: %v", err)
+	}
+	if err := os.WriteFile("output.txt", data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+`), 0600)
+	if err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	runner, err := NewRunner(dir)
+	if err != nil {
+		t.Fatalf("Failed to create runner: %v", err)
+	}
+	defer runner.Stop()
+	runner.pkgCache = NewPackageCache(dir, "")
+
+	var out strings.Builder
+	err = runner.Run(context.Background(), RunOptions{
+		Isolate:  true,
+		Renderer: NewRendererWithStyle(&out, false),
+	})
+	if err != nil {
+		t.Fatalf("Expected isolated run to pass, got: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "output.txt")); !os.IsNotExist(err) {
+		t.Error("Expected test output to be written to the isolated workspace, not the package directory")
+	}
+	if !strings.Contains(out.String(), "1 passed") {
+		t.Errorf("Expected output to report 1 passed test, got:\n%s", out.String())
+	}
+}
+
+func TestSyntheticFailure(t *testing.T) {
+	output := syntheticFailure("example.com/broken", "broken.go:3:1: syntax error\n")
+
+	run, err := NewParser().Parse(strings.NewReader(string(output)))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(run.Suites) != 1 {
+		t.Fatalf("got %d suites, want 1", len(run.Suites))
+	}
+	if run.Suites[0].Package != "example.com/broken" {
+		t.Errorf("Package = %s, want example.com/broken", run.Suites[0].Package)
+	}
+	if run.Suites[0].EndTime.IsZero() {
+		t.Error("Expected package end time to be recorded")
+	}
+}
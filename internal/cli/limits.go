@@ -0,0 +1,308 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// resourceLimitMessage starts the line written when a test binary fails
+// at one of its resource limits
+const resourceLimitMessage = "sentinel: test binary exceeded its"
+
+// limitsEnv passes the resource limits of each package directory to the
+// go test -exec wrapper, which only knows the directory it is run in
+const limitsEnv = "GO_SENTINEL_LIMITS"
+
+// ResourceLimits caps what one test binary may use, so a runaway test
+// cannot exhaust the machine. Zero values are unlimited.
+type ResourceLimits struct {
+	Memory int64         `json:"memory,omitempty"` // Bytes of heap and data
+	Files  int           `json:"files,omitempty"`  // Open file descriptors
+	CPU    time.Duration `json:"cpu,omitempty"`    // CPU time
+}
+
+// IsZero reports whether no limit is set
+func (l ResourceLimits) IsZero() bool {
+	return l == ResourceLimits{}
+}
+
+// merge returns l with the limits set in override replacing its own
+func (l ResourceLimits) merge(override ResourceLimits) ResourceLimits {
+	if override.Memory > 0 {
+		l.Memory = override.Memory
+	}
+	if override.Files > 0 {
+		l.Files = override.Files
+	}
+	if override.CPU > 0 {
+		l.CPU = override.CPU
+	}
+	return l
+}
+
+// String describes the limits, such as "memory 512MB, files 256"
+func (l ResourceLimits) String() string {
+	var parts []string
+	if l.Memory > 0 {
+		parts = append(parts, "memory "+formatByteSize(l.Memory))
+	}
+	if l.Files > 0 {
+		parts = append(parts, "files "+strconv.Itoa(l.Files))
+	}
+	if l.CPU > 0 {
+		parts = append(parts, "CPU "+l.CPU.String())
+	}
+	return strings.Join(parts, ", ")
+}
+
+// PackageLimit overrides resource limits for the packages matching a
+// pattern, in the forms duration budgets accept
+type PackageLimit struct {
+	Pattern string
+	Limits  ResourceLimits
+}
+
+// PackageLimits are the resource limits of the test binaries of a run
+type PackageLimits struct {
+	Default  ResourceLimits
+	Packages []PackageLimit
+}
+
+// For returns the limits of a package; the limits of the longest pattern
+// matching it override the defaults
+func (p *PackageLimits) For(importPath string) ResourceLimits {
+	limits := p.Default
+	best := -1
+	var override ResourceLimits
+	for _, pkg := range p.Packages {
+		if len(pkg.Pattern) > best && budgetMatches(pkg.Pattern, importPath) {
+			best, override = len(pkg.Pattern), pkg.Limits
+		}
+	}
+	return limits.merge(override)
+}
+
+// env returns the variable passing the limits of each package to the
+// go test -exec wrapper
+func (p *PackageLimits) env(pkgs []*PackageInfo) (string, error) {
+	byDir := make(map[string]ResourceLimits)
+	for _, pkg := range pkgs {
+		if limits := p.For(pkg.ImportPath); !limits.IsZero() {
+			byDir[pkg.Dir] = limits
+		}
+	}
+	return limitsEnvFor(byDir)
+}
+
+// forBuild returns the limits of test binaries built with flags. The race
+// detector's shadow memory does not fit under a memory limit, so race
+// binaries would all exit without output; their memory limits are dropped.
+func (p *PackageLimits) forBuild(flags []string) *PackageLimits {
+	if !raceBuild(flags) {
+		return p
+	}
+	limits := &PackageLimits{Default: p.Default}
+	limits.Default.Memory = 0
+	dropped := p.Default.Memory > 0
+	for _, pkg := range p.Packages {
+		dropped = dropped || pkg.Limits.Memory > 0
+		pkg.Limits.Memory = 0
+		limits.Packages = append(limits.Packages, pkg)
+	}
+	if dropped {
+		raceLimitsWarning.Do(func() {
+			log.Printf("Ignoring memory limits: the race detector needs more memory than they allow")
+		})
+	}
+	return limits
+}
+
+// raceBuild reports whether go test flags build with the race detector
+func raceBuild(flags []string) bool {
+	race := false
+	for _, flag := range flags {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(flag, "-"), "=")
+		if !strings.HasPrefix(flag, "-") || name != "race" {
+			continue
+		}
+		race = !hasValue
+		if hasValue {
+			race, _ = strconv.ParseBool(value)
+		}
+	}
+	return race
+}
+
+// limitsWarning warns once that resource limits are ignored
+var limitsWarning sync.Once
+
+// raceLimitsWarning warns once that memory limits are ignored in race
+// builds
+var raceLimitsWarning sync.Once
+
+// limitsEnv returns the environment assigning the test binaries of a run
+// the resource limits of their packages
+func (r *Runner) limitsEnv(opts RunOptions) ([]string, error) {
+	if opts.Limits == nil {
+		return nil, nil
+	}
+	if !limitsSupported {
+		limitsWarning.Do(func() {
+			log.Printf("Ignoring resource limits: they are not supported on this system")
+		})
+	}
+	pkgs, err := r.pkgCache.Get(opts.Packages)
+	if err != nil {
+		return nil, err
+	}
+	env, err := opts.Limits.forBuild(opts.BuildFlags).env(pkgs)
+	if err != nil {
+		return nil, err
+	}
+	return []string{env}, nil
+}
+
+// limitsEnvFor encodes the limits of each directory for limitsEnv
+func limitsEnvFor(byDir map[string]ResourceLimits) (string, error) {
+	data, err := json.Marshal(byDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode resource limits: %w", err)
+	}
+	return limitsEnv + "=" + string(data), nil
+}
+
+// LimitsFromEnv returns the resource limits go-sentinel assigned to the
+// test binary of a package directory when it started go test
+func LimitsFromEnv(dir string) ResourceLimits {
+	var byDir map[string]ResourceLimits
+	if err := json.Unmarshal([]byte(os.Getenv(limitsEnv)), &byDir); err != nil {
+		return ResourceLimits{}
+	}
+	return byDir[dir]
+}
+
+// ParseByteSize parses a size such as "512MB", "2GiB", or "1048576". KB,
+// MB, and GB are powers of 1024, like their KiB, MiB, and GiB forms.
+func ParseByteSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	upper := strings.ToUpper(s)
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{
+		{"GIB", 1 << 30}, {"MIB", 1 << 20}, {"KIB", 1 << 10},
+		{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+		{"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+	} {
+		if strings.HasSuffix(upper, unit.suffix) {
+			upper = strings.TrimSpace(strings.TrimSuffix(upper, unit.suffix))
+			multiplier = unit.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(upper, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * multiplier, nil
+}
+
+// formatByteSize formats a size in the largest unit dividing it evenly
+func formatByteSize(n int64) string {
+	switch {
+	case n >= 1<<30 && n%(1<<30) == 0:
+		return strconv.FormatInt(n>>30, 10) + "GB"
+	case n >= 1<<20 && n%(1<<20) == 0:
+		return strconv.FormatInt(n>>20, 10) + "MB"
+	case n >= 1<<10 && n%(1<<10) == 0:
+		return strconv.FormatInt(n>>10, 10) + "KB"
+	}
+	return strconv.FormatInt(n, 10) + "B"
+}
+
+// limitWatch passes output through while watching it for the messages
+// of a test binary that ran out of memory or file descriptors
+type limitWatch struct {
+	mu          sync.Mutex
+	tail        []byte // End of the previous write, for messages split across writes
+	outOfMemory bool
+	outOfFiles  bool
+}
+
+// watch returns a writer passing output to w through the watch
+func (l *limitWatch) watch(w io.Writer) *limitWatchWriter {
+	return &limitWatchWriter{watch: l, w: w}
+}
+
+// limitWatchWriter is one output stream passing through a limitWatch
+type limitWatchWriter struct {
+	watch *limitWatch
+	w     io.Writer
+}
+
+func (w *limitWatchWriter) Write(p []byte) (int, error) {
+	w.watch.scan(p)
+	return w.w.Write(p)
+}
+
+// scan records the messages of exhausted resources in p
+func (l *limitWatch) scan(p []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	text := append(l.tail, p...)
+	// "fatal error: runtime: out of memory" on Unix, "fatal error: out of
+	// memory" on Windows
+	if bytes.Contains(text, []byte("out of memory")) || bytes.Contains(text, []byte("cannot allocate memory")) {
+		l.outOfMemory = true
+	}
+	if bytes.Contains(text, []byte("too many open files")) {
+		l.outOfFiles = true
+	}
+	if len(text) > 64 {
+		text = text[len(text)-64:]
+	}
+	l.tail = append([]byte{}, text...)
+}
+
+// exceeded returns the limits a failed test binary ran into, judged from
+// its output and the CPU time it used
+func (l *limitWatch) exceeded(limits ResourceLimits, cpu time.Duration) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var hit []string
+	if limits.Memory > 0 && l.outOfMemory {
+		hit = append(hit, "memory limit of "+formatByteSize(limits.Memory))
+	}
+	if limits.Files > 0 && l.outOfFiles {
+		hit = append(hit, "file descriptor limit of "+strconv.Itoa(limits.Files))
+	}
+	if limits.CPU > 0 && cpu >= limits.CPU {
+		hit = append(hit, "CPU time limit of "+limits.CPU.String())
+	}
+	sort.Strings(hit)
+	return hit
+}
+
+// HitResourceLimit reports whether a package failed at one of its
+// resource limits
+func (s *TestSuite) HitResourceLimit() bool {
+	if strings.Contains(s.Output, resourceLimitMessage) {
+		return true
+	}
+	for _, test := range s.Tests {
+		if test.Error != nil && strings.Contains(test.Error.Message, resourceLimitMessage) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package cli
+
+import "os/exec"
+
+// limitsSupported reports whether startLimited applies resource limits
+const limitsSupported = false
+
+// startLimited starts cmd without limits; resource limits are not
+// supported on this platform
+func startLimited(cmd *exec.Cmd, limits ResourceLimits) error {
+	return cmd.Start()
+}
@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"1048576", 1 << 20},
+		{"512MB", 512 << 20},
+		{"2GiB", 2 << 30},
+		{"64 k", 64 << 10},
+		{"100B", 100},
+	}
+	for _, tt := range tests {
+		if got, err := ParseByteSize(tt.in); err != nil || got != tt.want {
+			t.Errorf("ParseByteSize(%q) = %d, %v, want %d", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{"", "MB", "-1GB", "1.5GB", "12 parsecs"} {
+		if _, err := ParseByteSize(in); err == nil {
+			t.Errorf("ParseByteSize(%q) succeeded, want an error", in)
+		}
+	}
+}
+
+func TestPackageLimits_For(t *testing.T) {
+	limits := &PackageLimits{
+		Default: ResourceLimits{Memory: 1 << 30, Files: 256},
+		Packages: []PackageLimit{
+			{Pattern: "example.com/app/...", Limits: ResourceLimits{Files: 1024}},
+			{Pattern: "example.com/app/render", Limits: ResourceLimits{Memory: 64 << 20}},
+		},
+	}
+	tests := []struct {
+		pkg  string
+		want ResourceLimits
+	}{
+		{"example.com/other", ResourceLimits{Memory: 1 << 30, Files: 256}},
+		{"example.com/app/api", ResourceLimits{Memory: 1 << 30, Files: 1024}},
+		{"example.com/app/render", ResourceLimits{Memory: 64 << 20, Files: 256}},
+	}
+	for _, tt := range tests {
+		if got := limits.For(tt.pkg); got != tt.want {
+			t.Errorf("For(%q) = %+v, want %+v", tt.pkg, got, tt.want)
+		}
+	}
+}
+
+func TestPackageLimits_ForBuild(t *testing.T) {
+	limits := &PackageLimits{
+		Default: ResourceLimits{Memory: 1 << 30, Files: 256},
+		Packages: []PackageLimit{
+			{Pattern: "example.com/app/render", Limits: ResourceLimits{Memory: 64 << 20, CPU: time.Minute}},
+		},
+	}
+	if got := limits.forBuild([]string{"-tags=integration"}); got != limits {
+		t.Errorf("forBuild() without -race = %+v, want the limits unchanged", got)
+	}
+	if got := limits.forBuild([]string{"-race", "-race=false"}); got != limits {
+		t.Errorf("forBuild(-race=false) = %+v, want the limits unchanged", got)
+	}
+
+	race := limits.forBuild([]string{"-race"})
+	if got, want := race.For("example.com/other"), (ResourceLimits{Files: 256}); got != want {
+		t.Errorf("For(other) with -race = %+v, want %+v", got, want)
+	}
+	if got, want := race.For("example.com/app/render"), (ResourceLimits{Files: 256, CPU: time.Minute}); got != want {
+		t.Errorf("For(render) with -race = %+v, want %+v", got, want)
+	}
+	if limits.Default.Memory != 1<<30 || limits.Packages[0].Limits.Memory != 64<<20 {
+		t.Errorf("forBuild(-race) changed the original limits: %+v", limits)
+	}
+}
+
+func TestLimitsFromEnv(t *testing.T) {
+	env, err := limitsEnvFor(map[string]ResourceLimits{"/src/app": {Memory: 512 << 20, CPU: time.Minute}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, value, _ := strings.Cut(env, "=")
+	t.Setenv(name, value)
+	if got := LimitsFromEnv("/src/app"); got != (ResourceLimits{Memory: 512 << 20, CPU: time.Minute}) {
+		t.Errorf("LimitsFromEnv() = %+v", got)
+	}
+	if got := LimitsFromEnv("/src/other"); !got.IsZero() {
+		t.Errorf("LimitsFromEnv() of another directory = %+v, want none", got)
+	}
+}
+
+func TestLimitWatch_Exceeded(t *testing.T) {
+	limits := ResourceLimits{Memory: 64 << 20, Files: 16, CPU: 2 * time.Second}
+	watch := &limitWatch{}
+	watch.scan([]byte("fatal error: runtime: out"))
+	watch.scan([]byte(" of memory\n"))
+	if got := watch.exceeded(limits, time.Second); len(got) != 1 || got[0] != "memory limit of 64MB" {
+		t.Errorf("exceeded() = %q, want the memory limit", got)
+	}
+	if got := watch.exceeded(ResourceLimits{Files: 16}, time.Second); len(got) != 0 {
+		t.Errorf("exceeded() without a memory limit = %q, want none", got)
+	}
+
+	watch = &limitWatch{}
+	watch.scan([]byte("open data.txt: too many open files\n"))
+	got := watch.exceeded(limits, 3*time.Second)
+	if want := "CPU time limit of 2s and file descriptor limit of 16"; strings.Join(got, " and ") != want {
+		t.Errorf("exceeded() = %q, want %q", got, want)
+	}
+}
+
+func TestRunSetupWatchdog_MemoryLimit(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("memory limits are enforced through RLIMIT_DATA on linux")
+	}
+	if raceEnabled {
+		t.Skip("the race detector's shadow memory does not fit under a memory limit")
+	}
+	code, out := runLimitedHelper(t, "allocate", 0, ResourceLimits{Memory: 256 << 20})
+	if code != 1 || !strings.Contains(out, resourceLimitMessage+" memory limit of 256MB") {
+		t.Errorf("exit code = %d, output = %q, want the memory limit reported", code, out)
+	}
+
+	code, out = runLimitedHelper(t, "allocate", 0, ResourceLimits{Memory: 4 << 30})
+	if code != 0 || !strings.Contains(out, "64 chunks allocated") {
+		t.Errorf("exit code = %d, output = %q, want the allocation to succeed under a larger limit", code, out)
+	}
+}
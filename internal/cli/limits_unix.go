@@ -0,0 +1,64 @@
+//go:build linux || darwin || freebsd
+
+package cli
+
+import (
+	"log"
+	"os/exec"
+	"sync"
+	"syscall"
+)
+
+// limitsSupported reports whether startLimited applies resource limits
+const limitsSupported = true
+
+// rlimitMu keeps the lowered limits of one start from leaking into another
+var rlimitMu sync.Mutex
+
+// startLimited starts cmd with its soft resource limits lowered to limits,
+// which the test binary and every process it starts inherit. Memory is
+// limited with RLIMIT_DATA, which the go runtime's heap counts against,
+// rather than the address space it reserves up front.
+func startLimited(cmd *exec.Cmd, limits ResourceLimits) error {
+	if limits.IsZero() {
+		return cmd.Start()
+	}
+	rlimitMu.Lock()
+	defer rlimitMu.Unlock()
+
+	var restore []func()
+	defer func() {
+		for _, fn := range restore {
+			fn()
+		}
+	}()
+	lower := func(resource int, value uint64) {
+		var saved syscall.Rlimit
+		if err := syscall.Getrlimit(resource, &saved); err != nil {
+			log.Printf("Error reading resource limit: %v", err)
+			return
+		}
+		lowered := saved
+		if value < lowered.Max {
+			lowered.Cur = value
+		} else {
+			lowered.Cur = lowered.Max
+		}
+		if err := syscall.Setrlimit(resource, &lowered); err != nil {
+			log.Printf("Error setting resource limit: %v", err)
+			return
+		}
+		restore = append(restore, func() { syscall.Setrlimit(resource, &saved) })
+	}
+	if limits.Memory > 0 {
+		lower(syscall.RLIMIT_DATA, uint64(limits.Memory))
+	}
+	if limits.Files > 0 {
+		lower(syscall.RLIMIT_NOFILE, uint64(limits.Files))
+	}
+	if limits.CPU > 0 {
+		// Whole seconds, rounded up so short limits are not zero
+		lower(syscall.RLIMIT_CPU, uint64((limits.CPU+999_999_999)/1_000_000_000))
+	}
+	return cmd.Start()
+}
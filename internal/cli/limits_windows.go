@@ -0,0 +1,106 @@
+//go:build windows
+
+package cli
+
+import (
+	"fmt"
+	"os/exec"
+	"syscall"
+	"unsafe"
+)
+
+// limitsSupported reports whether startLimited applies resource limits
+const limitsSupported = true
+
+// Job object limit flags and information classes
+const (
+	jobObjectLimitProcessTime         = 0x00000002
+	jobObjectLimitProcessMemory       = 0x00000100
+	jobObjectLimitKillOnJobClose      = 0x00002000
+	jobObjectExtendedLimitInformation = 9
+	processSetQuotaAndTerminate       = 0x0100 | 0x0001
+)
+
+var (
+	procCreateJobObjectW         = kernel32.NewProc("CreateJobObjectW")
+	procSetInformationJobObject  = kernel32.NewProc("SetInformationJobObject")
+	procAssignProcessToJobObject = kernel32.NewProc("AssignProcessToJobObject")
+)
+
+// jobObjectBasicLimitInformation is JOBOBJECT_BASIC_LIMIT_INFORMATION
+type jobObjectBasicLimitInformation struct {
+	PerProcessUserTimeLimit int64
+	PerJobUserTimeLimit     int64
+	LimitFlags              uint32
+	MinimumWorkingSetSize   uintptr
+	MaximumWorkingSetSize   uintptr
+	ActiveProcessLimit      uint32
+	Affinity                uintptr
+	PriorityClass           uint32
+	SchedulingClass         uint32
+}
+
+// ioCounters is IO_COUNTERS
+type ioCounters struct {
+	ReadOperationCount  uint64
+	WriteOperationCount uint64
+	OtherOperationCount uint64
+	ReadTransferCount   uint64
+	WriteTransferCount  uint64
+	OtherTransferCount  uint64
+}
+
+// jobObjectExtendedLimit is JOBOBJECT_EXTENDED_LIMIT_INFORMATION
+type jobObjectExtendedLimit struct {
+	BasicLimitInformation jobObjectBasicLimitInformation
+	IoInfo                ioCounters
+	ProcessMemoryLimit    uintptr
+	JobMemoryLimit        uintptr
+	PeakProcessMemoryUsed uintptr
+	PeakJobMemoryUsed     uintptr
+}
+
+// startLimited starts cmd in a job object limiting the memory and CPU time
+// of each of its processes; processes the test binary starts join the job.
+// Windows has no per-process limit on open handles, so Files is ignored.
+func startLimited(cmd *exec.Cmd, limits ResourceLimits) error {
+	if limits.Memory <= 0 && limits.CPU <= 0 {
+		return cmd.Start()
+	}
+
+	job, _, err := procCreateJobObjectW.Call(0, 0)
+	if job == 0 {
+		return fmt.Errorf("failed to create job object: %w", err)
+	}
+	var info jobObjectExtendedLimit
+	// Closing the job when go-sentinel exits stops whatever is left of it
+	info.BasicLimitInformation.LimitFlags = jobObjectLimitKillOnJobClose
+	if limits.Memory > 0 {
+		info.BasicLimitInformation.LimitFlags |= jobObjectLimitProcessMemory
+		info.ProcessMemoryLimit = uintptr(limits.Memory)
+	}
+	if limits.CPU > 0 {
+		info.BasicLimitInformation.LimitFlags |= jobObjectLimitProcessTime
+		info.BasicLimitInformation.PerProcessUserTimeLimit = int64(limits.CPU / 100) // 100ns units
+	}
+	if r, _, err := procSetInformationJobObject.Call(job, jobObjectExtendedLimitInformation,
+		uintptr(unsafe.Pointer(&info)), unsafe.Sizeof(info)); r == 0 {
+		syscall.CloseHandle(syscall.Handle(job))
+		return fmt.Errorf("failed to set job limits: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		syscall.CloseHandle(syscall.Handle(job))
+		return err
+	}
+	handle, err := syscall.OpenProcess(processSetQuotaAndTerminate, false, uint32(cmd.Process.Pid))
+	if err != nil {
+		return fmt.Errorf("failed to open test process: %w", err)
+	}
+	defer syscall.CloseHandle(handle)
+	if r, _, err := procAssignProcessToJobObject.Call(job, uintptr(handle)); r == 0 {
+		return fmt.Errorf("failed to limit test process: %w", err)
+	}
+	// The job handle stays open, and the job alive, until the wrapper exits
+	return nil
+}
@@ -0,0 +1,215 @@
+package cli
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// matrixAxisRe matches a single matrix axis such as race=[on,off]
+var matrixAxisRe = regexp.MustCompile(`([A-Za-z][\w.]*)=\[([^\]]*)\]`)
+
+// MatrixAxis is a build setting and the values it takes in a matrix run
+type MatrixAxis struct {
+	Name   string
+	Values []string
+}
+
+// MatrixCell is one combination of matrix axis values
+type MatrixCell struct {
+	Label      string   // Human-readable settings, e.g. "race=on tags=fast"
+	BuildFlags []string // go test flags for this combination
+	Env        []string // Environment variables for this combination
+}
+
+// MatrixResult holds the outcome of running one matrix cell
+type MatrixResult struct {
+	Cell MatrixCell
+	Run  *TestRun
+	Err  error
+}
+
+// ParseMatrix parses a matrix specification of the form
+// 'race=[on,off] tags=[fast,slow]' into its axes
+func ParseMatrix(spec string) ([]MatrixAxis, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+
+	matches := matrixAxisRe.FindAllStringSubmatchIndex(spec, -1)
+	var axes []MatrixAxis
+	pos := 0
+	for _, m := range matches {
+		if strings.TrimSpace(spec[pos:m[0]]) != "" {
+			return nil, fmt.Errorf("invalid matrix near %q", spec[pos:m[0]])
+		}
+		pos = m[1]
+
+		axis := MatrixAxis{Name: spec[m[2]:m[3]]}
+		for _, value := range strings.Split(spec[m[4]:m[5]], ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				axis.Values = append(axis.Values, value)
+			}
+		}
+		if len(axis.Values) == 0 {
+			return nil, fmt.Errorf("matrix axis %q has no values", axis.Name)
+		}
+		axes = append(axes, axis)
+	}
+	if strings.TrimSpace(spec[pos:]) != "" {
+		return nil, fmt.Errorf("invalid matrix near %q", spec[pos:])
+	}
+	return axes, nil
+}
+
+// ExpandMatrix returns every combination of axis values as a matrix cell
+func ExpandMatrix(axes []MatrixAxis) ([]MatrixCell, error) {
+	if len(axes) == 0 {
+		return nil, nil
+	}
+
+	cells := []MatrixCell{{}}
+	for _, axis := range axes {
+		var next []MatrixCell
+		for _, cell := range cells {
+			for _, value := range axis.Values {
+				flags, env, err := matrixSetting(axis.Name, value)
+				if err != nil {
+					return nil, err
+				}
+				label := fmt.Sprintf("%s=%s", axis.Name, value)
+				if cell.Label != "" {
+					label = cell.Label + " " + label
+				}
+				next = append(next, MatrixCell{
+					Label:      label,
+					BuildFlags: append(append([]string{}, cell.BuildFlags...), flags...),
+					Env:        append(append([]string{}, cell.Env...), env...),
+				})
+			}
+		}
+		cells = next
+	}
+	return cells, nil
+}
+
+// matrixSetting translates an axis value into go test flags and environment
+// variables. Multiple build tags are joined with '+', e.g. tags=[unit+fast].
+func matrixSetting(name, value string) ([]string, []string, error) {
+	switch {
+	case name == "race":
+		on, err := parseSwitch(name, value)
+		if err != nil || !on {
+			return nil, nil, err
+		}
+		return []string{"-race"}, nil, nil
+	case name == "cgo":
+		on, err := parseSwitch(name, value)
+		if err != nil {
+			return nil, nil, err
+		}
+		if on {
+			return nil, []string{"CGO_ENABLED=1"}, nil
+		}
+		return nil, []string{"CGO_ENABLED=0"}, nil
+	case name == "tags":
+		if value == "none" {
+			return nil, nil, nil
+		}
+		return []string{"-tags=" + strings.ReplaceAll(value, "+", ",")}, nil, nil
+	case name == "cpu":
+		return []string{"-cpu=" + strings.ReplaceAll(value, "+", ",")}, nil, nil
+	case name == "goexperiment":
+		return nil, []string{"GOEXPERIMENT=" + strings.ReplaceAll(value, "+", ",")}, nil
+	case strings.HasPrefix(name, "env."):
+		return nil, []string{strings.TrimPrefix(name, "env.") + "=" + value}, nil
+	}
+	return nil, nil, fmt.Errorf("unknown matrix axis %q (supported: race, cgo, tags, cpu, goexperiment, env.NAME)", name)
+}
+
+// parseSwitch parses an on/off axis value
+func parseSwitch(name, value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "on", "true", "1", "yes":
+		return true, nil
+	case "off", "false", "0", "no":
+		return false, nil
+	}
+	return false, fmt.Errorf("matrix axis %q expects on/off, got %q", name, value)
+}
+
+// RunMatrix runs the selected packages once per matrix cell and renders a
+// per-package result table. The other consumers of the run's events, its
+// reports, history, and notifications see the cells' runs merged into one.
+func (r *Runner) RunMatrix(opts RunOptions) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	events := opts.eventPipeline()
+	events.emit(RunEvent{Type: EventRunStart})
+
+	var results []*MatrixResult
+	failed := 0
+	for _, cell := range opts.Matrix {
+		cellOpts := opts
+		cellOpts.BuildFlags = append(append([]string{}, opts.BuildFlags...), cell.BuildFlags...)
+		cellOpts.Env = append(append([]string{}, opts.Env...), cell.Env...)
+
+		run, output, err := r.execute(cellOpts)
+		if err != nil {
+			failed++
+			err = testError(output, err)
+		}
+		results = append(results, &MatrixResult{Cell: cell, Run: run, Err: err})
+	}
+
+	// The renderer shows the table in place of each package's results
+	run, dirs := matrixReportRun(results, r.packageDirs(opts.Packages))
+	consumers := EventPipeline(opts.Events)
+	consumers.emitResults(run)
+	consumers.emit(RunEvent{Type: EventRunSummary, Run: run, Dirs: dirs})
+	if opts.Renderer != nil {
+		opts.Renderer.RenderMatrix(results)
+	}
+	r.reportRun(run, opts, dirs)
+
+	if failed > 0 {
+		return fmt.Errorf("%w in %d of %d matrix combinations", ErrTestsFailed, failed, len(opts.Matrix))
+	}
+	return nil
+}
+
+// matrixReportRun merges the runs of every cell into one for reporting,
+// with each package suite labeled by the cell's settings. It also returns
+// the package directories of pkgDirs by the labeled names.
+func matrixReportRun(results []*MatrixResult, pkgDirs map[string]string) (*TestRun, map[string]string) {
+	merged := &TestRun{ID: newRunID()}
+	dirs := make(map[string]string)
+	for _, res := range results {
+		if res.Run == nil {
+			continue
+		}
+		if merged.StartTime.IsZero() {
+			merged.StartTime = res.Run.StartTime
+		}
+		if res.Run.EndTime.After(merged.EndTime) {
+			merged.EndTime = res.Run.EndTime
+		}
+		merged.Duration += res.Run.Duration
+		merged.NumTotal += res.Run.NumTotal
+		merged.NumPassed += res.Run.NumPassed
+		merged.NumFailed += res.Run.NumFailed
+		merged.NumSkipped += res.Run.NumSkipped
+		merged.FailedTests = append(merged.FailedTests, res.Run.FailedTests...)
+		for _, suite := range res.Run.Suites {
+			labeled := *suite
+			labeled.PackageName = fmt.Sprintf("%s [%s]", suiteName(suite), res.Cell.Label)
+			if dir, ok := pkgDirs[suiteName(suite)]; ok {
+				dirs[labeled.PackageName] = dir
+			}
+			merged.Suites = append(merged.Suites, &labeled)
+		}
+	}
+	return merged, dirs
+}
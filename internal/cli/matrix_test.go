@@ -0,0 +1,176 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMatrix(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    []MatrixAxis
+		wantErr bool
+	}{
+		{
+			name: "empty",
+			spec: "",
+			want: nil,
+		},
+		{
+			name: "two axes",
+			spec: "race=[on,off] tags=[fast, slow]",
+			want: []MatrixAxis{
+				{Name: "race", Values: []string{"on", "off"}},
+				{Name: "tags", Values: []string{"fast", "slow"}},
+			},
+		},
+		{
+			name: "env axis",
+			spec: "env.DB=[sqlite,postgres]",
+			want: []MatrixAxis{
+				{Name: "env.DB", Values: []string{"sqlite", "postgres"}},
+			},
+		},
+		{
+			name:    "garbage between axes",
+			spec:    "race=[on] oops tags=[fast]",
+			wantErr: true,
+		},
+		{
+			name:    "no values",
+			spec:    "race=[]",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMatrix(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseMatrix(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseMatrix(%q) = %v, want %v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandMatrix(t *testing.T) {
+	axes, err := ParseMatrix("race=[on,off] tags=[fast,unit+slow] goexperiment=[loopvar]")
+	if err != nil {
+		t.Fatalf("ParseMatrix failed: %v", err)
+	}
+
+	cells, err := ExpandMatrix(axes)
+	if err != nil {
+		t.Fatalf("ExpandMatrix failed: %v", err)
+	}
+
+	want := []MatrixCell{
+		{Label: "race=on tags=fast goexperiment=loopvar", BuildFlags: []string{"-race", "-tags=fast"}, Env: []string{"GOEXPERIMENT=loopvar"}},
+		{Label: "race=on tags=unit+slow goexperiment=loopvar", BuildFlags: []string{"-race", "-tags=unit,slow"}, Env: []string{"GOEXPERIMENT=loopvar"}},
+		{Label: "race=off tags=fast goexperiment=loopvar", BuildFlags: []string{"-tags=fast"}, Env: []string{"GOEXPERIMENT=loopvar"}},
+		{Label: "race=off tags=unit+slow goexperiment=loopvar", BuildFlags: []string{"-tags=unit,slow"}, Env: []string{"GOEXPERIMENT=loopvar"}},
+	}
+	if !reflect.DeepEqual(cells, want) {
+		t.Errorf("ExpandMatrix() = %+v, want %+v", cells, want)
+	}
+
+	if _, err := ExpandMatrix([]MatrixAxis{{Name: "bogus", Values: []string{"x"}}}); err == nil {
+		t.Error("Expected error for unknown axis")
+	}
+	if _, err := ExpandMatrix([]MatrixAxis{{Name: "race", Values: []string{"maybe"}}}); err == nil {
+		t.Error("Expected error for invalid switch value")
+	}
+}
+
+func TestRunner_RunMatrixEvents(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example\n\ngo 1.23\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// The test only fails in one cell
+	err := os.WriteFile(filepath.Join(dir, "mode_test.go"), []byte(`package example
+
+import (
+	"os"
+	"testing"
+)
+
+func TestMode(t *testing.T) {
+	if os.Getenv("MODE") == "b" {
+		t.Fatal("mode b")
+	}
+}
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runner, err := NewRunner(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer runner.Stop()
+	cells, err := ExpandMatrix([]MatrixAxis{{Name: "env.MODE", Values: []string{"a", "b"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := &recordedEvents{}
+	var porcelain bytes.Buffer
+	err = runner.RunMatrix(RunOptions{Matrix: cells, Events: []EventHandler{events}, Porcelain: &porcelain})
+	if !errors.Is(err, ErrTestsFailed) {
+		t.Fatalf("RunMatrix() error = %v, want ErrTestsFailed", err)
+	}
+
+	want := []string{
+		EventRunStart,
+		EventTestStart + " TestMode", EventTestPass + " TestMode", EventPackageEnd,
+		EventTestStart + " TestMode", EventTestFail + " TestMode", EventPackageEnd,
+		EventRunSummary,
+	}
+	if !reflect.DeepEqual(events.types, want) {
+		t.Errorf("events = %v, want %v", events.types, want)
+	}
+	for _, record := range []string{"pkg\tpass\texample [env.MODE=a]\t", "pkg\tfail\texample [env.MODE=b]\t", "run\tfail\t2\t1\t1\t0\t"} {
+		if !strings.Contains(porcelain.String(), record) {
+			t.Errorf("porcelain output missing %q:\n%s", record, porcelain.String())
+		}
+	}
+}
+
+func TestMatrixReportRun(t *testing.T) {
+	results := []*MatrixResult{
+		{Cell: MatrixCell{Label: "race=on"}, Run: &TestRun{NumTotal: 2, NumPassed: 2, Suites: []*TestSuite{{Package: "example.com/app"}}}},
+		{Cell: MatrixCell{Label: "race=off"}, Err: errors.New("build failed")},
+		{Cell: MatrixCell{Label: "cgo=off"}, Run: &TestRun{NumTotal: 2, NumPassed: 1, NumFailed: 1, Suites: []*TestSuite{{Package: "example.com/app"}}}},
+	}
+	run, dirs := matrixReportRun(results, map[string]string{"example.com/app": "/src/app"})
+
+	if run.NumTotal != 4 || run.NumPassed != 3 || run.NumFailed != 1 {
+		t.Errorf("counts = %d total, %d passed, %d failed, want 4, 3, 1", run.NumTotal, run.NumPassed, run.NumFailed)
+	}
+	var names []string
+	for _, suite := range run.Suites {
+		names = append(names, suiteName(suite))
+	}
+	wantNames := []string{"example.com/app [race=on]", "example.com/app [cgo=off]"}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("suites = %v, want %v", names, wantNames)
+	}
+	wantDirs := map[string]string{"example.com/app [race=on]": "/src/app", "example.com/app [cgo=off]": "/src/app"}
+	if !reflect.DeepEqual(dirs, wantDirs) {
+		t.Errorf("dirs = %v, want %v", dirs, wantDirs)
+	}
+}
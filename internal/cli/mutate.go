@@ -0,0 +1,384 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Mutation operators
+const (
+	MutateConditionals = "conditionals" // Invert comparisons, as == to !=
+	MutateArithmetic   = "arithmetic"   // Swap arithmetic operators, as + to -
+	MutateStatements   = "statements"   // Delete call statements
+)
+
+// MutationOperators lists the mutation operators in the order they are
+// applied
+var MutationOperators = []string{MutateConditionals, MutateArithmetic, MutateStatements}
+
+// Outcomes of a mutant
+const (
+	MutantKilled       = "killed"       // A test failed
+	MutantSurvived     = "survived"     // Every test passed
+	MutantTimedOut     = "timed-out"    // The tests did not finish in time, counted as killed
+	MutantUncompilable = "uncompilable" // The mutated code did not build
+	MutantUncovered    = "uncovered"    // No package with tests imports the mutated package
+)
+
+// invertedOperators are the comparisons conditional mutations swap
+var invertedOperators = map[token.Token]token.Token{
+	token.EQL: token.NEQ, token.NEQ: token.EQL,
+	token.LSS: token.GEQ, token.GEQ: token.LSS,
+	token.GTR: token.LEQ, token.LEQ: token.GTR,
+	token.LAND: token.LOR, token.LOR: token.LAND,
+}
+
+// swappedOperators are the operators arithmetic mutations swap
+var swappedOperators = map[token.Token]token.Token{
+	token.ADD: token.SUB, token.SUB: token.ADD,
+	token.MUL: token.QUO, token.QUO: token.MUL, token.REM: token.MUL,
+	token.ADD_ASSIGN: token.SUB_ASSIGN, token.SUB_ASSIGN: token.ADD_ASSIGN,
+	token.INC: token.DEC, token.DEC: token.INC,
+}
+
+// Mutant is one change to a source file
+type Mutant struct {
+	Package     string        `json:"package"`
+	File        string        `json:"file"` // Relative to the module root
+	Line        int           `json:"line"`
+	Column      int           `json:"column"`
+	Operator    string        `json:"operator"`
+	Description string        `json:"description"` // As "== → !="
+	Outcome     string        `json:"outcome,omitempty"`
+	Tested      []string      `json:"tested,omitempty"` // Packages whose tests ran against the mutant
+	Duration    time.Duration `json:"duration,omitempty"`
+
+	path        string // Absolute path of the file
+	start, end  int    // Byte range replaced
+	replacement string
+}
+
+// MutationAnalysis configures a mutation testing run
+type MutationAnalysis struct {
+	Operators []string      // Operators applied, every one when empty
+	Timeout   time.Duration // Limit on the tests of each mutant, 5 times the unmutated run when 0
+	DryRun    bool          // List the mutants without running tests
+
+	// Progress is told the outcome of each mutant, nil for none
+	Progress func(m *Mutant)
+}
+
+// MutationReport is the outcome of a mutation testing run
+type MutationReport struct {
+	Mutants []*Mutant `json:"mutants"`
+}
+
+// Count returns the number of mutants with an outcome
+func (r *MutationReport) Count(outcome string) int {
+	n := 0
+	for _, m := range r.Mutants {
+		if m.Outcome == outcome {
+			n++
+		}
+	}
+	return n
+}
+
+// Score returns the share of tested mutants the tests killed, from 0 to 1
+func (r *MutationReport) Score() float64 {
+	killed := r.Count(MutantKilled) + r.Count(MutantTimedOut)
+	tested := killed + r.Count(MutantSurvived)
+	if tested == 0 {
+		return 0
+	}
+	return float64(killed) / float64(tested)
+}
+
+// Survivors returns the mutants no test killed, by file and line
+func (r *MutationReport) Survivors() []*Mutant {
+	var survivors []*Mutant
+	for _, m := range r.Mutants {
+		if m.Outcome == MutantSurvived {
+			survivors = append(survivors, m)
+		}
+	}
+	return survivors
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *MutationReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// MutatePackages applies mutations to the non-test source of the packages
+// matching patterns and runs, for each mutant, the tests of the packages
+// that can kill it: its own package and the packages importing it. The
+// source files are never modified; mutants are compiled through
+// go test -overlay.
+func MutatePackages(workDir string, patterns []string, a MutationAnalysis) (*MutationReport, error) {
+	operators := a.Operators
+	if len(operators) == 0 {
+		operators = MutationOperators
+	}
+	for _, op := range operators {
+		if !containsString(MutationOperators, op) {
+			return nil, fmt.Errorf("unknown mutation operator %q, want one of %s", op, strings.Join(MutationOperators, ", "))
+		}
+	}
+
+	targets, err := expandPackagePatterns(workDir, patterns)
+	if err != nil {
+		return nil, err
+	}
+	// The packages able to kill a mutant may lie outside the patterns
+	all, err := expandPackagePatterns(workDir, []string{"./..."})
+	if err != nil {
+		return nil, err
+	}
+	graph := NewDependencyGraph(all)
+	withTests := make(map[string]bool)
+	for _, pkg := range all {
+		withTests[pkg.ImportPath] = pkg.HasTests()
+	}
+
+	report := &MutationReport{}
+	for _, pkg := range targets {
+		for _, name := range pkg.GoFiles {
+			mutants, err := findMutants(filepath.Join(pkg.Dir, name), operators)
+			if err != nil {
+				return nil, err
+			}
+			for _, m := range mutants {
+				m.Package = pkg.ImportPath
+				if rel, err := filepath.Rel(workDir, m.path); err == nil {
+					m.File = filepath.ToSlash(rel)
+				}
+				for _, candidate := range append([]string{pkg.ImportPath}, graph.Dependents(pkg.ImportPath)...) {
+					if withTests[candidate] {
+						m.Tested = append(m.Tested, candidate)
+					}
+				}
+			}
+			report.Mutants = append(report.Mutants, mutants...)
+		}
+	}
+	if a.DryRun {
+		return report, nil
+	}
+
+	tmp, err := os.MkdirTemp("", "go-sentinel-mutate-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create mutant directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	// The tests must pass unmutated, or every mutant would count as killed
+	timeouts := make(map[string]time.Duration)
+	for _, m := range report.Mutants {
+		key := strings.Join(m.Tested, " ")
+		if _, done := timeouts[key]; done || len(m.Tested) == 0 {
+			continue
+		}
+		started := time.Now()
+		output, err := runMutantTests(workDir, "", m.Tested, 0)
+		if err != nil {
+			return nil, fmt.Errorf("tests of %s fail without mutations:\n%s", key, output)
+		}
+		timeouts[key] = a.Timeout
+		if a.Timeout <= 0 {
+			timeouts[key] = max(5*time.Since(started), 10*time.Second)
+		}
+	}
+
+	for i, m := range report.Mutants {
+		if len(m.Tested) == 0 {
+			m.Outcome = MutantUncovered
+		} else {
+			overlay, err := writeMutantOverlay(tmp, i, m)
+			if err != nil {
+				return nil, err
+			}
+			started := time.Now()
+			output, err := runMutantTests(workDir, overlay, m.Tested, timeouts[strings.Join(m.Tested, " ")])
+			m.Duration = time.Since(started)
+			m.Outcome = mutantOutcome(output, err)
+		}
+		if a.Progress != nil {
+			a.Progress(m)
+		}
+	}
+	return report, nil
+}
+
+// findMutants returns the mutants of a source file, in source order
+func findMutants(path string, operators []string) ([]*Mutant, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	var mutants []*Mutant
+	add := func(operator string, pos token.Pos, end token.Pos, replacement, description string) {
+		position := fset.Position(pos)
+		mutants = append(mutants, &Mutant{
+			File: path, Line: position.Line, Column: position.Column,
+			Operator: operator, Description: description,
+			path: path, start: position.Offset, end: fset.Position(end).Offset, replacement: replacement,
+		})
+	}
+	swap := func(operator string, pos token.Pos, from, to token.Token) {
+		add(operator, pos, pos+token.Pos(len(from.String())), to.String(), from.String()+" → "+to.String())
+	}
+	conditionals := containsString(operators, MutateConditionals)
+	arithmetic := containsString(operators, MutateArithmetic)
+	statements := containsString(operators, MutateStatements)
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.GenDecl:
+			// Constant expressions are evaluated at compile time, where a
+			// mutant often overflows or divides by zero
+			return n.Tok != token.CONST
+		case *ast.BinaryExpr:
+			if to, ok := invertedOperators[n.Op]; ok && conditionals {
+				swap(MutateConditionals, n.OpPos, n.Op, to)
+			} else if to, ok := swappedOperators[n.Op]; ok && arithmetic && !isStringLiteral(n.X) && !isStringLiteral(n.Y) {
+				swap(MutateArithmetic, n.OpPos, n.Op, to)
+			}
+		case *ast.AssignStmt:
+			if to, ok := swappedOperators[n.Tok]; ok && arithmetic {
+				swap(MutateArithmetic, n.TokPos, n.Tok, to)
+			}
+		case *ast.IncDecStmt:
+			if arithmetic {
+				swap(MutateArithmetic, n.TokPos, n.Tok, swappedOperators[n.Tok])
+			}
+		case *ast.BlockStmt:
+			if !statements {
+				return true
+			}
+			for _, stmt := range n.List {
+				if expr, ok := stmt.(*ast.ExprStmt); ok {
+					if _, ok := expr.X.(*ast.CallExpr); ok && !isPanicCall(expr.X) {
+						add(MutateStatements, stmt.Pos(), stmt.End(), "", "delete "+firstSourceLine(string(src[fset.Position(stmt.Pos()).Offset:fset.Position(stmt.End()).Offset])))
+					}
+				}
+			}
+		}
+		return true
+	})
+	sort.SliceStable(mutants, func(i, j int) bool { return mutants[i].start < mutants[j].start })
+	return mutants, nil
+}
+
+// isStringLiteral reports whether an expression is a string literal, so
+// that + is concatenation rather than arithmetic
+func isStringLiteral(expr ast.Expr) bool {
+	lit, ok := expr.(*ast.BasicLit)
+	return ok && lit.Kind == token.STRING
+}
+
+// isPanicCall reports whether an expression calls panic, whose deletion
+// leaves functions without a terminating statement
+func isPanicCall(expr ast.Expr) bool {
+	call := expr.(*ast.CallExpr)
+	ident, ok := call.Fun.(*ast.Ident)
+	return ok && ident.Name == "panic"
+}
+
+// firstSourceLine returns the first line of s, shortened with an ellipsis
+// when it continues
+func firstSourceLine(s string) string {
+	if line, _, more := strings.Cut(s, "\n"); more {
+		return strings.TrimSpace(line) + " …"
+	}
+	return strings.TrimSpace(s)
+}
+
+// writeMutantOverlay writes the mutated source of a mutant and the
+// go test -overlay file replacing the original with it, returning the
+// path of the overlay file
+func writeMutantOverlay(dir string, i int, m *Mutant) (string, error) {
+	src, err := os.ReadFile(m.path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", m.path, err)
+	}
+	mutated := make([]byte, 0, len(src)+len(m.replacement))
+	mutated = append(mutated, src[:m.start]...)
+	mutated = append(mutated, m.replacement...)
+	mutated = append(mutated, src[m.end:]...)
+
+	source := filepath.Join(dir, fmt.Sprintf("mutant-%d.go", i))
+	if err := os.WriteFile(source, mutated, 0644); err != nil {
+		return "", fmt.Errorf("failed to write mutant: %w", err)
+	}
+	data, err := json.Marshal(map[string]map[string]string{"Replace": {m.path: source}})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode overlay: %w", err)
+	}
+	overlay := filepath.Join(dir, fmt.Sprintf("mutant-%d.json", i))
+	if err := os.WriteFile(overlay, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write overlay: %w", err)
+	}
+	return overlay, nil
+}
+
+// runMutantTests runs the tests of pkgs with an overlay, stopping at the
+// first failure, and returns their combined output
+func runMutantTests(workDir, overlay string, pkgs []string, timeout time.Duration) (string, error) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	// vet is skipped: mutants are meant to be wrong, and it only slows
+	// each run
+	args := []string{"test", "-count=1", "-failfast", "-vet=off"}
+	if overlay != "" {
+		args = append(args, "-overlay="+overlay)
+	}
+	cmd := exec.CommandContext(ctx, goBinary, append(args, pkgs...)...)
+	cmd.Dir = workDir
+	cmd.Env = offlineEnviron(os.Environ())
+	// Test binaries outliving a killed go command must not hold the output
+	// open
+	cmd.WaitDelay = time.Second
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return string(output), ctx.Err()
+	}
+	return string(output), err
+}
+
+// mutantOutcome classifies the result of running the tests of a mutant
+func mutantOutcome(output string, err error) string {
+	switch {
+	case err == nil:
+		return MutantSurvived
+	case err == context.DeadlineExceeded:
+		return MutantTimedOut
+	case strings.Contains(output, "[build failed]") || strings.Contains(output, "[setup failed]"):
+		return MutantUncompilable
+	default:
+		return MutantKilled
+	}
+}
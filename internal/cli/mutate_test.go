@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFindMutants(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "calc.go")
+	src := `package calc
+
+const limit = 1 << 4 * 2
+
+func Clamp(n int) int {
+	if n > limit && n != 0 {
+		n -= 1
+	}
+	record("clamp" + "ed")
+	panic(n * 2)
+}
+
+func record(s string) {}
+`
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mutants, err := findMutants(path, MutationOperators)
+	if err != nil {
+		t.Fatalf("findMutants failed: %v", err)
+	}
+	var got []string
+	for _, m := range mutants {
+		got = append(got, m.Operator+" "+m.Description)
+	}
+	want := []string{
+		"conditionals > → <=",
+		"conditionals && → ||",
+		"conditionals != → ==",
+		"arithmetic -= → +=",
+		"statements delete record(\"clamp\" + \"ed\")",
+		"arithmetic * → /",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("mutants =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if mutants[0].Line != 6 || mutants[0].Column != 7 {
+		t.Errorf("first mutant at %d:%d, want 6:7", mutants[0].Line, mutants[0].Column)
+	}
+
+	only, err := findMutants(path, []string{MutateStatements})
+	if err != nil || len(only) != 1 {
+		t.Errorf("statement mutants = %d, %v; want 1", len(only), err)
+	}
+}
+
+func TestWriteMutantOverlay(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "calc.go")
+	if err := os.WriteFile(path, []byte("package calc\n\nfunc Less(a, b int) bool { return a < b }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mutants, err := findMutants(path, []string{MutateConditionals})
+	if err != nil || len(mutants) != 1 {
+		t.Fatalf("findMutants = %v, %v", mutants, err)
+	}
+
+	overlay, err := writeMutantOverlay(dir, 0, mutants[0])
+	if err != nil {
+		t.Fatalf("writeMutantOverlay failed: %v", err)
+	}
+	data, err := os.ReadFile(overlay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"Replace"`) {
+		t.Errorf("overlay = %s, want a Replace map", data)
+	}
+	mutated, err := os.ReadFile(filepath.Join(dir, "mutant-0.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(mutated), "return a >= b") {
+		t.Errorf("mutated source = %s, want a >= b", mutated)
+	}
+	if original, _ := os.ReadFile(path); !strings.Contains(string(original), "return a < b") {
+		t.Errorf("original source was modified: %s", original)
+	}
+}
+
+func TestMutationReport(t *testing.T) {
+	report := &MutationReport{Mutants: []*Mutant{
+		{Outcome: mutantOutcome("ok  \texample.com/calc\t0.1s", nil)},
+		{Outcome: mutantOutcome("--- FAIL: TestLess", errors.New("exit status 1"))},
+		{Outcome: mutantOutcome("FAIL\texample.com/calc [build failed]", errors.New("exit status 1"))},
+		{Outcome: mutantOutcome("", context.DeadlineExceeded)},
+		{Outcome: MutantUncovered},
+	}}
+	for i, want := range []string{MutantSurvived, MutantKilled, MutantUncompilable, MutantTimedOut, MutantUncovered} {
+		if report.Mutants[i].Outcome != want {
+			t.Errorf("Mutants[%d] = %s, want %s", i, report.Mutants[i].Outcome, want)
+		}
+	}
+	if score := report.Score(); score < 0.66 || score > 0.67 {
+		t.Errorf("Score() = %v, want 2 of 3", score)
+	}
+	if len(report.Survivors()) != 1 {
+		t.Errorf("Survivors() = %v, want 1", report.Survivors())
+	}
+}
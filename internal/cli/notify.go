@@ -0,0 +1,767 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// NotifyFileName is the project file declaring notification channels and
+// the rules routing failures to them
+const NotifyFileName = ".gosentinel-notify.yaml"
+
+// Failure categories used by notification rules
+const (
+	CategoryAssertion     = "assertion"
+	CategoryPanic         = "panic"
+	CategoryTimeout       = "timeout"
+	CategoryBuild         = "build"
+	CategoryRace          = "race"
+	CategoryResourceLimit = "resource-limit"
+)
+
+// Events firing notification rules
+const (
+	NotifyOnFailure    = "failure"    // Failed tests match the rule; the default
+	NotifyOnComplete   = "complete"   // Every run
+	NotifyOnTransition = "transition" // The tests started failing or pass again
+	NotifyOnFlaky      = "flaky"      // Tests matching the rule passed only on retry
+	NotifyOnBenchmark  = "benchmark"  // Benchmarks matching the rule regressed beyond its threshold
+)
+
+// Defaults of benchmark rules
+const (
+	DefaultBenchmarkThreshold = 10 // Percent above the baseline
+	DefaultBenchmarkWindow    = 5  // Earlier runs whose median is the baseline
+)
+
+// Channel types
+const (
+	ChannelSlack   = "slack"
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+)
+
+// NotifyConfig is the content of the notification rules file
+type NotifyConfig struct {
+	Channels map[string]*NotifyChannel `yaml:"channels"`
+	Rules    []*NotifyRule             `yaml:"rules"`
+}
+
+// NotifyChannel describes where a notification is delivered
+type NotifyChannel struct {
+	Type string `yaml:"type"` // slack, email, or webhook
+
+	URL string `yaml:"url,omitempty"` // Slack incoming webhook or generic webhook URL
+
+	SMTP        string   `yaml:"smtp,omitempty"`        // Mail server as host:port
+	From        string   `yaml:"from,omitempty"`        // Sender address
+	To          []string `yaml:"to,omitempty"`          // Recipient addresses
+	Username    string   `yaml:"username,omitempty"`    // SMTP user, empty for no authentication
+	PasswordEnv string   `yaml:"passwordEnv,omitempty"` // Environment variable holding the SMTP password
+
+	// EncryptTo lists age public keys ("age1..."). When set, the failure
+	// details are encrypted to them before leaving the machine and only the
+	// run ID and failure count are sent in the clear.
+	EncryptTo []string `yaml:"encryptTo,omitempty"`
+
+	// Template is a text/template for the message, executed with the
+	// Notification. It replaces the default summary in Slack and email
+	// messages and is sent as "message" in webhook payloads.
+	Template string `yaml:"template,omitempty"`
+}
+
+// NotifyRule routes runs matching its conditions to channels. Empty
+// conditions match everything. Rules on complete and transition report
+// the failures matching their conditions along with the run summary.
+type NotifyRule struct {
+	Name   string         `yaml:"name"`
+	On     string         `yaml:"on,omitempty"` // Event firing the rule, failure by default
+	When   NotifyCriteria `yaml:"when"`
+	Notify []string       `yaml:"notify"`
+
+	// Benchmark rules fire when a benchmark's ns/op or allocs/op exceeds
+	// the median of its last Window recorded runs by more than Threshold
+	// percent, 5 runs and 10 percent by default
+	Threshold float64 `yaml:"threshold,omitempty"`
+	Window    int     `yaml:"window,omitempty"`
+}
+
+// event returns the event firing the rule
+func (r *NotifyRule) event() string {
+	if r.On == "" {
+		return NotifyOnFailure
+	}
+	return r.On
+}
+
+// benchmarkLimits returns the window and threshold, as a fraction, of a
+// benchmark rule
+func (r *NotifyRule) benchmarkLimits() (int, float64) {
+	window, threshold := r.Window, r.Threshold
+	if window == 0 {
+		window = DefaultBenchmarkWindow
+	}
+	if threshold == 0 {
+		threshold = DefaultBenchmarkThreshold
+	}
+	return window, threshold / 100
+}
+
+// NotifyCriteria are the conditions of a rule; all set conditions must match
+type NotifyCriteria struct {
+	Package  string `yaml:"package,omitempty"`  // Import path glob, ** matches across segments
+	Owner    string `yaml:"owner,omitempty"`    // One of the test's owners
+	Category string `yaml:"category,omitempty"` // Failure category, "|"-separated alternatives; ignored for flaky tests
+	Branch   string `yaml:"branch,omitempty"`   // Branch glob
+}
+
+// LoadNotifyConfig reads the notification rules from dir. It returns nil
+// when the project has no rules file.
+func LoadNotifyConfig(dir string) (*NotifyConfig, error) {
+	data, err := os.ReadFile(filepath.Join(dir, NotifyFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notification rules: %w", err)
+	}
+
+	cfg := &NotifyConfig{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", NotifyFileName, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", NotifyFileName, err)
+	}
+	return cfg, nil
+}
+
+// Validate checks that rules refer to defined, complete channels
+func (c *NotifyConfig) Validate() error {
+	for name, ch := range c.Channels {
+		switch ch.Type {
+		case ChannelSlack, ChannelWebhook:
+			if ch.URL == "" {
+				return fmt.Errorf("channel %s: url is required", name)
+			}
+		case ChannelEmail:
+			if ch.SMTP == "" || ch.From == "" || len(ch.To) == 0 {
+				return fmt.Errorf("channel %s: smtp, from, and to are required", name)
+			}
+		default:
+			return fmt.Errorf("channel %s: unknown type %q", name, ch.Type)
+		}
+		if _, err := ParseRecipients(ch.EncryptTo); err != nil {
+			return fmt.Errorf("channel %s: encryptTo: %w", name, err)
+		}
+		if _, err := ch.template(); err != nil {
+			return fmt.Errorf("channel %s: template: %w", name, err)
+		}
+	}
+	for i, rule := range c.Rules {
+		switch rule.event() {
+		case NotifyOnFailure, NotifyOnComplete, NotifyOnTransition, NotifyOnFlaky, NotifyOnBenchmark:
+		default:
+			return fmt.Errorf("rule %d (%s): unknown event %q", i, rule.Name, rule.On)
+		}
+		if rule.Threshold < 0 || rule.Window < 0 || rule.Window > maxBenchmarkHistory {
+			return fmt.Errorf("rule %d (%s): threshold must not be negative and window at most %d runs", i, rule.Name, maxBenchmarkHistory)
+		}
+		if len(rule.Notify) == 0 {
+			return fmt.Errorf("rule %d (%s): at least one channel is required", i, rule.Name)
+		}
+		for _, name := range rule.Notify {
+			if _, ok := c.Channels[name]; !ok {
+				return fmt.Errorf("rule %d (%s): unknown channel %q", i, rule.Name, name)
+			}
+		}
+	}
+	return nil
+}
+
+// Failure is a failed test as seen by notification rules
+type Failure struct {
+	Package  string   `json:"package"`
+	Test     string   `json:"test"`
+	Category string   `json:"category"`
+	Owners   []string `json:"owners,omitempty"`
+	Message  string   `json:"message,omitempty"`
+}
+
+// FlakyTest is a test that failed and then passed when retried
+type FlakyTest struct {
+	Package  string   `json:"package"`
+	Test     string   `json:"test"`
+	Attempts int      `json:"attempts"` // Attempt that passed
+	Owners   []string `json:"owners,omitempty"`
+}
+
+// PackageSummary is the outcome of one package in a notification
+type PackageSummary struct {
+	Package  string        `json:"package"`
+	Duration time.Duration `json:"duration"`
+	Passed   int           `json:"passed"`
+	Failed   int           `json:"failed"`
+}
+
+// Transitions reported in notifications
+const (
+	TransitionFailing = "failing"
+	TransitionPassing = "passing"
+)
+
+// Notification is the message delivered to one channel after a run
+type Notification struct {
+	RunID       string                 `json:"runId"`
+	Branch      string                 `json:"branch,omitempty"`
+	Rules       []string               `json:"rules"`
+	Events      []string               `json:"events"`               // Events that fired the rules
+	Status      string                 `json:"status"`               // "passed" or "failed"
+	Transition  string                 `json:"transition,omitempty"` // "failing" or "passing" when the run changed whether the tests pass
+	Duration    time.Duration          `json:"duration"`
+	Total       int                    `json:"total"`
+	Passed      int                    `json:"passed"`
+	Failed      int                    `json:"failed"`
+	Skipped     int                    `json:"skipped"`
+	Coverage    *float64               `json:"coverage,omitempty"` // Total statement coverage percentage, when collected
+	Packages    []*PackageSummary      `json:"packages,omitempty"`
+	Failures    []*Failure             `json:"failures"`
+	Flaky       []*FlakyTest           `json:"flaky,omitempty"`
+	Regressions []*BenchmarkRegression `json:"regressions,omitempty"` // Benchmarks slower or allocating more than their baseline
+	Message     string                 `json:"message,omitempty"`     // Text from the channel's template
+}
+
+// newNotification returns a notification summarizing run, without any
+// rules, failures, or flaky tests yet
+func newNotification(run *TestRun, branch, transition string) *Notification {
+	n := &Notification{
+		RunID:      run.ID,
+		Branch:     branch,
+		Status:     "passed",
+		Transition: transition,
+		Duration:   run.Duration,
+		Total:      run.NumTotal,
+		Passed:     run.NumPassed,
+		Failed:     run.NumFailed,
+		Skipped:    run.NumSkipped,
+	}
+	if run.NumFailed > 0 {
+		n.Status = "failed"
+	}
+	if run.Coverage != nil {
+		coverage := run.CoverageTotal
+		n.Coverage = &coverage
+	}
+	for _, suite := range run.Suites {
+		n.Packages = append(n.Packages, &PackageSummary{
+			Package:  suite.Package,
+			Duration: suite.Duration,
+			Passed:   suite.NumPassed,
+			Failed:   suite.NumFailed,
+		})
+	}
+	return n
+}
+
+// Headline describes the notification in one line
+func (n *Notification) Headline() string {
+	var b strings.Builder
+	switch {
+	case n.Transition == TransitionFailing:
+		fmt.Fprintf(&b, "Tests started failing in run %s", n.RunID)
+	case n.Transition == TransitionPassing:
+		fmt.Fprintf(&b, "Tests pass again in run %s", n.RunID)
+	case len(n.Failures) > 0:
+		fmt.Fprintf(&b, "%d %s in run %s", len(n.Failures), pluralize("test failure", len(n.Failures)), n.RunID)
+	case len(n.Flaky) > 0 && !containsString(n.Events, NotifyOnComplete):
+		fmt.Fprintf(&b, "%d %s passed only on retry in run %s", len(n.Flaky), pluralize("test", len(n.Flaky)), n.RunID)
+	case len(n.Regressions) > 0 && !containsString(n.Events, NotifyOnComplete):
+		fmt.Fprintf(&b, "%d benchmark %s in run %s", len(n.Regressions), pluralize("regression", len(n.Regressions)), n.RunID)
+	default:
+		fmt.Fprintf(&b, "Run %s %s", n.RunID, n.Status)
+	}
+	if n.Branch != "" {
+		fmt.Fprintf(&b, " on %s", n.Branch)
+	}
+	return b.String()
+}
+
+// Summary returns a short human-readable description of the notification
+func (n *Notification) Summary() string {
+	var b strings.Builder
+	b.WriteString(n.Headline())
+	b.WriteString("\n")
+	// Rules on failures alone report just the failures
+	if len(n.Events) != 1 || n.Events[0] != NotifyOnFailure {
+		fmt.Fprintf(&b, "%d passed, %d failed, %d skipped in %s", n.Passed, n.Failed, n.Skipped, FormatDurationAdaptive(n.Duration))
+		if n.Coverage != nil {
+			fmt.Fprintf(&b, ", %.1f%% coverage", *n.Coverage)
+		}
+		b.WriteString("\n")
+	}
+	for _, f := range n.Failures {
+		fmt.Fprintf(&b, "• %s %s (%s)", f.Package, f.Test, f.Category)
+		if len(f.Owners) > 0 {
+			fmt.Fprintf(&b, " %s", strings.Join(f.Owners, " "))
+		}
+		b.WriteString("\n")
+	}
+	for _, f := range n.Flaky {
+		fmt.Fprintf(&b, "• %s %s (passed on attempt %d)", f.Package, f.Test, f.Attempts)
+		if len(f.Owners) > 0 {
+			fmt.Fprintf(&b, " %s", strings.Join(f.Owners, " "))
+		}
+		b.WriteString("\n")
+	}
+	for _, g := range n.Regressions {
+		fmt.Fprintf(&b, "• %s\n", g)
+	}
+	return b.String()
+}
+
+// NotificationRouter evaluates rules on run completion and delivers the
+// resulting notifications
+type NotificationRouter struct {
+	config *NotifyConfig
+	branch func() string
+
+	seen       bool             // A run or the history has set the baseline state
+	failing    bool             // Whether the previous run failed
+	benchmarks benchmarkHistory // Earlier results of each benchmark, for benchmark rules
+
+	// send delivers a notification; replaced in tests
+	send func(ch *NotifyChannel, n *Notification) error
+}
+
+// NewNotificationRouter creates a router for the rules in cfg. The branch
+// is taken from CI environment variables or the git checkout in workDir.
+func NewNotificationRouter(cfg *NotifyConfig, workDir string) *NotificationRouter {
+	return &NotificationRouter{
+		config:     cfg,
+		branch:     func() string { return currentBranch(workDir) },
+		benchmarks: make(benchmarkHistory),
+		send:       sendNotification,
+	}
+}
+
+// SetBaseline records whether the run before the next one failed, so the
+// next run can fire transition rules. Without a baseline the first run
+// only sets one.
+func (r *NotificationRouter) SetBaseline(failing bool) {
+	r.seen, r.failing = true, failing
+}
+
+// BaselineFromHistory sets the baseline from the latest run recorded on
+// the router's branch, for single runs such as in CI, and the baselines of
+// benchmark rules from the benchmark results recorded on it
+func (r *NotificationRouter) BaselineFromHistory(history HistoryBackend) error {
+	records, err := history.Load()
+	if err != nil {
+		return err
+	}
+	branch := r.branch()
+	for _, rec := range records {
+		if rec.Branch != "" && branch != "" && rec.Branch != branch {
+			continue
+		}
+		for _, pkg := range rec.Packages {
+			r.benchmarks.add(pkg.Package, pkg.Benchmarks)
+		}
+	}
+	for i := len(records) - 1; i >= 0; i-- {
+		rec := records[i]
+		if rec.Trigger != TriggerRun && rec.Trigger != TriggerWatch {
+			continue
+		}
+		if rec.Branch != "" && branch != "" && rec.Branch != branch {
+			continue
+		}
+		r.SetBaseline(rec.NumFailed > 0)
+		return nil
+	}
+	return nil
+}
+
+// Route delivers notifications for run and returns the errors of
+// deliveries that failed
+func (r *NotificationRouter) Route(run *TestRun) []error {
+	notifications := r.Evaluate(run)
+	r.SetBaseline(run.NumFailed > 0)
+	for _, suite := range run.Suites {
+		r.benchmarks.add(suite.Package, suite.Benchmarks)
+	}
+
+	var names []string
+	for name := range notifications {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		if err := r.send(r.config.Channels[name], notifications[name]); err != nil {
+			errs = append(errs, fmt.Errorf("channel %s: %w", name, err))
+		}
+	}
+	return errs
+}
+
+// transition returns whether run changed whether the tests pass since the
+// baseline
+func (r *NotificationRouter) transition(run *TestRun) string {
+	failing := run.NumFailed > 0
+	switch {
+	case !r.seen || failing == r.failing:
+		return ""
+	case failing:
+		return TransitionFailing
+	}
+	return TransitionPassing
+}
+
+// Evaluate matches the run against the rules and returns the notification
+// for each channel that has something to report. It does not move the
+// baseline of transition rules; Route does.
+func (r *NotificationRouter) Evaluate(run *TestRun) map[string]*Notification {
+	branch := r.branch()
+	transition := r.transition(run)
+	failures := runFailures(run)
+	flaky := flakyTests(run)
+
+	result := make(map[string]*Notification)
+	picked := make(map[string]map[any]bool)
+	for _, rule := range r.config.Rules {
+		if rule.When.Branch != "" && !matchGlob(rule.When.Branch, branch) {
+			continue
+		}
+		var matched []*Failure
+		for _, f := range failures {
+			if rule.When.matches(f, branch) {
+				matched = append(matched, f)
+			}
+		}
+		var matchedFlaky []*FlakyTest
+		var matchedRegressions []*BenchmarkRegression
+		switch rule.event() {
+		case NotifyOnFailure:
+			if len(matched) == 0 {
+				continue
+			}
+		case NotifyOnTransition:
+			if transition == "" {
+				continue
+			}
+		case NotifyOnFlaky:
+			matched = nil
+			for _, f := range flaky {
+				if rule.When.matches(&Failure{Package: f.Package, Owners: f.Owners}, branch) {
+					matchedFlaky = append(matchedFlaky, f)
+				}
+			}
+			if len(matchedFlaky) == 0 {
+				continue
+			}
+		case NotifyOnBenchmark:
+			matched = nil
+			window, threshold := rule.benchmarkLimits()
+			for _, g := range r.benchmarks.regressions(run, window, threshold) {
+				if rule.When.matches(&Failure{Package: g.Package}, branch) {
+					matchedRegressions = append(matchedRegressions, g)
+				}
+			}
+			if len(matchedRegressions) == 0 {
+				continue
+			}
+		}
+
+		for _, name := range rule.Notify {
+			n, ok := result[name]
+			if !ok {
+				n = newNotification(run, branch, transition)
+				result[name] = n
+				picked[name] = make(map[any]bool)
+			}
+			if !containsString(n.Rules, rule.Name) {
+				n.Rules = append(n.Rules, rule.Name)
+			}
+			if !containsString(n.Events, rule.event()) {
+				n.Events = append(n.Events, rule.event())
+			}
+			for _, f := range matched {
+				picked[name][f] = true
+			}
+			for _, f := range matchedFlaky {
+				picked[name][f] = true
+			}
+			for _, g := range matchedRegressions {
+				n.Regressions = appendRegression(n.Regressions, g)
+			}
+		}
+	}
+
+	// List tests in run order whichever rules picked them
+	for name, n := range result {
+		for _, f := range failures {
+			if picked[name][f] {
+				n.Failures = append(n.Failures, f)
+			}
+		}
+		for _, f := range flaky {
+			if picked[name][f] {
+				n.Flaky = append(n.Flaky, f)
+			}
+		}
+	}
+	return result
+}
+
+// appendRegression adds g to regressions unless another rule already
+// reported the same benchmark metric
+func appendRegression(regressions []*BenchmarkRegression, g *BenchmarkRegression) []*BenchmarkRegression {
+	for _, have := range regressions {
+		if have.Package == g.Package && have.Name == g.Name && have.Metric == g.Metric {
+			return regressions
+		}
+	}
+	return append(regressions, g)
+}
+
+// runFailures returns the failed tests of run
+func runFailures(run *TestRun) []*Failure {
+	var failures []*Failure
+	for _, suite := range run.Suites {
+		for _, test := range suite.Tests {
+			if test.Status != TestStatusFailed {
+				continue
+			}
+			failure := &Failure{
+				Package:  suite.Package,
+				Test:     test.Name,
+				Category: FailureCategory(test),
+				Owners:   strings.Fields(test.Owner),
+			}
+			if test.Error != nil {
+				failure.Message = strings.TrimSpace(test.Error.Message)
+			}
+			failures = append(failures, failure)
+		}
+	}
+	return failures
+}
+
+// flakyTests returns the tests of run that passed only on retry
+func flakyTests(run *TestRun) []*FlakyTest {
+	var flaky []*FlakyTest
+	for _, suite := range run.Suites {
+		for _, test := range suite.Tests {
+			if test.PassedOnRetry {
+				flaky = append(flaky, &FlakyTest{
+					Package:  suite.Package,
+					Test:     test.Name,
+					Attempts: test.Attempts,
+					Owners:   strings.Fields(test.Owner),
+				})
+			}
+		}
+	}
+	return flaky
+}
+
+// matches reports whether a failure on branch satisfies all set criteria
+func (c NotifyCriteria) matches(f *Failure, branch string) bool {
+	if c.Package != "" && !matchGlob(c.Package, f.Package) {
+		return false
+	}
+	if c.Owner != "" && !containsString(f.Owners, c.Owner) {
+		return false
+	}
+	if c.Category != "" && f.Category != "" && !containsString(strings.Split(c.Category, "|"), f.Category) {
+		return false
+	}
+	if c.Branch != "" && !matchGlob(c.Branch, branch) {
+		return false
+	}
+	return true
+}
+
+// FailureCategory classifies a failed test from its output
+func FailureCategory(test *TestResult) string {
+	if test.Error == nil {
+		return CategoryAssertion
+	}
+	msg := test.Error.Message
+	switch {
+	case strings.Contains(msg, resourceLimitMessage) || strings.Contains(msg, "fatal error: runtime: out of memory") || strings.Contains(msg, "fatal error: out of memory"):
+		return CategoryResourceLimit
+	case strings.Contains(msg, "WARNING: DATA RACE") || strings.Contains(msg, "race detected during execution"):
+		return CategoryRace
+	case strings.Contains(msg, "panic: test timed out") || strings.Contains(msg, "test timed out after"):
+		return CategoryTimeout
+	case strings.Contains(msg, "panic:"):
+		return CategoryPanic
+	case strings.Contains(msg, "[build failed]") || strings.Contains(msg, "[setup failed]"):
+		return CategoryBuild
+	}
+	return CategoryAssertion
+}
+
+// currentBranch returns the branch being tested, preferring CI variables
+// since CI checkouts are often detached
+func currentBranch(workDir string) string {
+	for _, env := range []string{"GITHUB_HEAD_REF", "GITHUB_REF_NAME", "CI_COMMIT_REF_NAME", "BRANCH_NAME"} {
+		if branch := os.Getenv(env); branch != "" {
+			return branch
+		}
+	}
+	cmd := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD")
+	cmd.Dir = workDir
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// notifyClient is used for Slack and webhook deliveries
+var notifyClient = &http.Client{Timeout: 10 * time.Second}
+
+// template parses the channel's message template, nil when it has none
+func (ch *NotifyChannel) template() (*template.Template, error) {
+	if ch.Template == "" {
+		return nil, nil
+	}
+	return template.New("message").Funcs(template.FuncMap{
+		"duration":  FormatDurationAdaptive,
+		"pluralize": pluralize,
+	}).Parse(ch.Template)
+}
+
+// message returns the text of n for the channel: its template executed
+// with n, or the summary
+func (ch *NotifyChannel) message(n *Notification) (string, error) {
+	tmpl, err := ch.template()
+	if err != nil || tmpl == nil {
+		return n.Summary(), err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, n); err != nil {
+		return "", fmt.Errorf("failed to execute message template: %w", err)
+	}
+	return b.String(), nil
+}
+
+// sendNotification delivers n through the channel
+func sendNotification(ch *NotifyChannel, n *Notification) error {
+	text, err := ch.message(n)
+	if err != nil {
+		return err
+	}
+	if ch.Template != "" {
+		n.Message = text
+	}
+	if len(ch.EncryptTo) > 0 {
+		return sendEncrypted(ch, n, text)
+	}
+	switch ch.Type {
+	case ChannelSlack:
+		return postJSON(ch.URL, map[string]string{"text": text})
+	case ChannelWebhook:
+		return postJSON(ch.URL, n)
+	case ChannelEmail:
+		return sendMail(ch, n.Headline(), text)
+	}
+	return fmt.Errorf("unknown channel type %q", ch.Type)
+}
+
+// sendEncrypted delivers n with its details encrypted to the channel's
+// recipients. Webhooks receive the encrypted JSON payload; Slack and email
+// receive the encrypted message under the clear-text headline, which names
+// only the run, branch, and counts.
+func sendEncrypted(ch *NotifyChannel, n *Notification, text string) error {
+	plain := []byte(text)
+	if ch.Type == ChannelWebhook {
+		data, err := json.Marshal(n)
+		if err != nil {
+			return fmt.Errorf("failed to encode notification: %w", err)
+		}
+		plain = data
+	}
+	sealed, err := EncryptPayload(ch.EncryptTo, plain)
+	if err != nil {
+		return err
+	}
+
+	switch ch.Type {
+	case ChannelSlack:
+		text := fmt.Sprintf("%s (encrypted)\n```\n%s```", n.Headline(), sealed)
+		return postJSON(ch.URL, map[string]string{"text": text})
+	case ChannelWebhook:
+		return post(ch.URL, EncryptedContentType, sealed)
+	case ChannelEmail:
+		return sendMail(ch, n.Headline(), string(sealed))
+	}
+	return fmt.Errorf("unknown channel type %q", ch.Type)
+}
+
+// EncryptedContentType is the content type of encrypted webhook payloads:
+// an ASCII-armored age file wrapping the usual JSON notification
+const EncryptedContentType = "application/vnd.age+armor"
+
+// sendMail emails body to the channel's recipients
+func sendMail(ch *NotifyChannel, subject, body string) error {
+	if err := checkOnline(ch.SMTP); err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if ch.Username != "" {
+		host, _, _ := strings.Cut(ch.SMTP, ":")
+		auth = smtp.PlainAuth("", ch.Username, os.Getenv(ch.PasswordEnv), host)
+	}
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: go-sentinel: %s\r\n\r\n%s",
+		ch.From, strings.Join(ch.To, ", "), subject, body)
+	return smtp.SendMail(ch.SMTP, auth, ch.From, ch.To, []byte(msg))
+}
+
+// postJSON sends payload as a JSON POST request
+func postJSON(url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+	return post(url, "application/json", body)
+}
+
+// post sends body as a POST request, treating non-2xx responses as errors
+func post(url, contentType string, body []byte) error {
+	resp, err := notifyClient.Post(url, contentType, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to send notification: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to send notification: %s", resp.Status)
+	}
+	return nil
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
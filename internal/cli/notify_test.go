@@ -0,0 +1,323 @@
+package cli
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+)
+
+func TestFailureCategory(t *testing.T) {
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{"    foo_test.go:12: want 1, got 2\n", CategoryAssertion},
+		{"panic: runtime error: index out of range [recovered]\n", CategoryPanic},
+		{"panic: test timed out after 10m0s\n", CategoryTimeout},
+		{"==================\nWARNING: DATA RACE\n", CategoryRace},
+		{"FAIL\texample.com/pkg [build failed]\n", CategoryBuild},
+		{"sentinel: test binary exceeded its memory limit of 64MB\n", CategoryResourceLimit},
+		{"fatal error: runtime: out of memory\n", CategoryResourceLimit},
+	}
+	for _, tt := range tests {
+		test := &TestResult{Status: TestStatusFailed, Error: &TestError{Message: tt.message}}
+		if got := FailureCategory(test); got != tt.want {
+			t.Errorf("FailureCategory(%q) = %s, want %s", tt.message, got, tt.want)
+		}
+	}
+}
+
+func TestNotificationRouter_Route(t *testing.T) {
+	var received []Notification
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n Notification
+		if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+			t.Errorf("Failed to decode webhook payload: %v", err)
+		}
+		received = append(received, n)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	rules := `
+channels:
+  api-hook:
+    type: webhook
+    url: ` + server.URL + `
+  panics:
+    type: webhook
+    url: ` + server.URL + `
+rules:
+  - name: api team
+    when:
+      package: example.com/api/**
+      owner: "@api-team"
+      branch: main
+    notify: [api-hook]
+  - name: any panic
+    when:
+      category: panic|race
+    notify: [panics]
+`
+	if err := os.WriteFile(filepath.Join(dir, NotifyFileName), []byte(rules), 0644); err != nil {
+		t.Fatalf("Failed to write rules: %v", err)
+	}
+	cfg, err := LoadNotifyConfig(dir)
+	if err != nil {
+		t.Fatalf("LoadNotifyConfig failed: %v", err)
+	}
+
+	router := NewNotificationRouter(cfg, dir)
+	router.branch = func() string { return "main" }
+
+	run := &TestRun{ID: "run-1", NumFailed: 3, Suites: []*TestSuite{
+		{Package: "example.com/api/users", Tests: []*TestResult{
+			{Name: "TestCreate", Status: TestStatusFailed, Owner: "@api-team", Error: &TestError{Message: "want 201"}},
+			{Name: "TestList", Status: TestStatusPassed, Owner: "@api-team"},
+		}},
+		{Package: "example.com/worker", Tests: []*TestResult{
+			{Name: "TestJob", Status: TestStatusFailed, Error: &TestError{Message: "panic: nil map"}},
+			{Name: "TestQueue", Status: TestStatusFailed, Error: &TestError{Message: "want 3"}},
+		}},
+	}}
+
+	notifications := router.Evaluate(run)
+	if len(notifications) != 2 {
+		t.Fatalf("got %d notifications, want 2", len(notifications))
+	}
+	if n := notifications["api-hook"]; len(n.Failures) != 1 || n.Failures[0].Test != "TestCreate" {
+		t.Errorf("api-hook failures = %+v, want TestCreate", n.Failures)
+	}
+	if n := notifications["panics"]; len(n.Failures) != 1 || n.Failures[0].Test != "TestJob" {
+		t.Errorf("panics failures = %+v, want TestJob", n.Failures)
+	}
+
+	if errs := router.Route(run); len(errs) != 0 {
+		t.Fatalf("Route errors: %v", errs)
+	}
+	if len(received) != 2 {
+		t.Errorf("webhook received %d notifications, want 2", len(received))
+	}
+
+	// Rules restricted to another branch do not fire
+	router.branch = func() string { return "feature/x" }
+	if _, ok := router.Evaluate(run)["api-hook"]; ok {
+		t.Error("Expected branch condition to exclude the api-hook rule")
+	}
+}
+
+func TestNotificationRouter_Events(t *testing.T) {
+	dir := t.TempDir()
+	rules := `
+channels:
+  slack:
+    type: slack
+    url: http://example.com/slack
+    template: "{{.Status}} {{.Passed}}/{{.Total}}{{range .Flaky}} flaky:{{.Test}}{{end}}"
+  hook:
+    type: webhook
+    url: http://example.com/hook
+rules:
+  - name: every run
+    on: complete
+    notify: [slack]
+  - name: status changes
+    on: transition
+    notify: [hook]
+  - name: flaky workers
+    on: flaky
+    when:
+      package: example.com/worker
+    notify: [hook]
+`
+	if err := os.WriteFile(filepath.Join(dir, NotifyFileName), []byte(rules), 0644); err != nil {
+		t.Fatalf("Failed to write rules: %v", err)
+	}
+	cfg, err := LoadNotifyConfig(dir)
+	if err != nil {
+		t.Fatalf("LoadNotifyConfig failed: %v", err)
+	}
+	router := NewNotificationRouter(cfg, dir)
+	router.branch = func() string { return "main" }
+	var sent []*Notification
+	router.send = func(ch *NotifyChannel, n *Notification) error {
+		sent = append(sent, n)
+		return nil
+	}
+
+	passing := &TestRun{ID: "run-1", NumTotal: 2, NumPassed: 2, Suites: []*TestSuite{
+		{Package: "example.com/worker", NumPassed: 2, Tests: []*TestResult{
+			{Name: "TestJob", Status: TestStatusPassed, PassedOnRetry: true, Attempts: 2},
+			{Name: "TestQueue", Status: TestStatusPassed},
+		}},
+	}}
+	failing := &TestRun{ID: "run-2", NumTotal: 2, NumPassed: 1, NumFailed: 1, Suites: []*TestSuite{
+		{Package: "example.com/worker", NumPassed: 1, NumFailed: 1, Tests: []*TestResult{
+			{Name: "TestJob", Status: TestStatusFailed, Error: &TestError{Message: "want 3"}},
+			{Name: "TestQueue", Status: TestStatusPassed},
+		}},
+	}}
+
+	// The first run only sets the transition baseline
+	notifications := router.Evaluate(passing)
+	if n := notifications["slack"]; n == nil || n.Status != "passed" || n.Total != 2 || len(n.Packages) != 1 {
+		t.Errorf("slack = %+v, want a summary of the passing run", n)
+	}
+	if n := notifications["hook"]; n == nil || len(n.Flaky) != 1 || n.Flaky[0].Attempts != 2 || n.Transition != "" {
+		t.Errorf("hook = %+v, want TestJob as flaky and no transition", n)
+	}
+	if errs := router.Route(passing); len(errs) != 0 {
+		t.Fatalf("Route errors: %v", errs)
+	}
+
+	sent = nil
+	if errs := router.Route(failing); len(errs) != 0 {
+		t.Fatalf("Route errors: %v", errs)
+	}
+	if len(sent) != 2 {
+		t.Fatalf("sent %d notifications, want 2", len(sent))
+	}
+	hook := sent[0]
+	if hook.Transition != TransitionFailing || len(hook.Failures) != 1 || hook.Failures[0].Test != "TestJob" {
+		t.Errorf("hook = %+v, want the transition to failing with TestJob", hook)
+	}
+	if got := hook.Headline(); got != "Tests started failing in run run-2 on main" {
+		t.Errorf("Headline() = %q", got)
+	}
+
+	// The same state again is no transition
+	if _, ok := router.Evaluate(failing)["hook"]; ok {
+		t.Error("Expected no transition for a second failing run")
+	}
+
+	text, err := cfg.Channels["slack"].message(newNotification(passing, "main", ""))
+	if err != nil || text != "passed 2/2" {
+		t.Errorf("message() = %q, %v, want the template output", text, err)
+	}
+	n := newNotification(passing, "main", "")
+	n.Flaky = flakyTests(passing)
+	if text, _ := cfg.Channels["slack"].message(n); text != "passed 2/2 flaky:TestJob" {
+		t.Errorf("message() = %q, want the flaky test listed", text)
+	}
+}
+
+func TestNotificationRouter_BaselineFromHistory(t *testing.T) {
+	dir := t.TempDir()
+	history, err := OpenHistory(dir, HistoryConfig{}, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer history.Close()
+	for _, rec := range []*HistoryRecord{
+		{ID: "a", Trigger: TriggerRun, Branch: "main", NumFailed: 1},
+		{ID: "b", Trigger: TriggerRun, Branch: "feature/x"},
+	} {
+		if err := history.Append(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &NotifyConfig{
+		Channels: map[string]*NotifyChannel{"hook": {Type: ChannelWebhook, URL: "http://example.com"}},
+		Rules:    []*NotifyRule{{Name: "changes", On: NotifyOnTransition, Notify: []string{"hook"}}},
+	}
+	router := NewNotificationRouter(cfg, dir)
+	router.branch = func() string { return "main" }
+	if err := router.BaselineFromHistory(history); err != nil {
+		t.Fatalf("BaselineFromHistory failed: %v", err)
+	}
+	n := router.Evaluate(&TestRun{ID: "c", NumTotal: 1, NumPassed: 1})["hook"]
+	if n == nil || n.Transition != TransitionPassing {
+		t.Errorf("hook = %+v, want the main branch passing again", n)
+	}
+}
+
+func TestNotifyConfig_Validate(t *testing.T) {
+	cfg := &NotifyConfig{
+		Channels: map[string]*NotifyChannel{"hook": {Type: ChannelWebhook, URL: "http://example.com"}},
+		Rules:    []*NotifyRule{{Name: "all", Notify: []string{"missing"}}},
+	}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected error for rule referencing an unknown channel")
+	}
+
+	cfg.Rules[0].Notify = []string{"hook"}
+	cfg.Channels["mail"] = &NotifyChannel{Type: ChannelEmail, SMTP: "localhost:25"}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected error for incomplete email channel")
+	}
+
+	delete(cfg.Channels, "mail")
+	cfg.Rules[0].On = "deploy"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected error for an unknown event")
+	}
+
+	cfg.Rules[0].On = NotifyOnComplete
+	cfg.Channels["hook"].Template = "{{.Status"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected error for an invalid template")
+	}
+}
+
+func TestSendNotification_Encrypted(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var contentType string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	ch := &NotifyChannel{Type: ChannelWebhook, URL: server.URL, EncryptTo: []string{identity.Recipient().String()}}
+	n := &Notification{RunID: "run-1", Failures: []*Failure{{Package: "example.com/pkg", Test: "TestSecret", Message: "ssn 123-45-6789"}}}
+	if err := sendNotification(ch, n); err != nil {
+		t.Fatalf("sendNotification failed: %v", err)
+	}
+
+	if contentType != EncryptedContentType {
+		t.Errorf("Content-Type = %q, want %q", contentType, EncryptedContentType)
+	}
+	if strings.Contains(string(body), "123-45-6789") {
+		t.Fatal("Payload leaked the failure message in the clear")
+	}
+	plain, err := DecryptPayload(identity.String(), body)
+	if err != nil {
+		t.Fatalf("DecryptPayload failed: %v", err)
+	}
+	var got Notification
+	if err := json.Unmarshal(plain, &got); err != nil || got.Failures[0].Message != "ssn 123-45-6789" {
+		t.Errorf("Decrypted payload = %s (%v), want the notification", plain, err)
+	}
+
+	// Slack shows the headline of the notification in the clear
+	slack := &NotifyChannel{Type: ChannelSlack, URL: server.URL, EncryptTo: ch.EncryptTo}
+	n = &Notification{RunID: "run-2", Branch: "main", Status: "passed", Transition: TransitionPassing}
+	if err := sendNotification(slack, n); err != nil {
+		t.Fatalf("sendNotification to Slack failed: %v", err)
+	}
+	var message map[string]string
+	if err := json.Unmarshal(body, &message); err != nil {
+		t.Fatalf("Slack payload = %s: %v", body, err)
+	}
+	if want := "Tests pass again in run run-2 on main (encrypted)\n"; !strings.HasPrefix(message["text"], want) {
+		t.Errorf("Slack text = %q, want it to start with %q", message["text"], want)
+	}
+
+	cfg := &NotifyConfig{Channels: map[string]*NotifyChannel{"hook": {Type: ChannelWebhook, URL: server.URL, EncryptTo: []string{"not-a-key"}}}}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected error for an invalid encryption key")
+	}
+}
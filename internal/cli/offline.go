@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrOffline is returned, wrapped, when something tries to reach another
+// machine while go-sentinel runs offline
+var ErrOffline = errors.New("network access is disabled in offline mode")
+
+// offline is set by SetOffline
+var offline bool
+
+// SetOffline makes go-sentinel run without the network, for air-gapped
+// machines. The go command is kept to the module cache with GOPROXY=off
+// and GOTOOLCHAIN=local, and connections to anything but the loopback
+// interface fail with ErrOffline instead of waiting on a network that is
+// not there. It is meant to be called once at startup.
+func SetOffline(on bool) {
+	offline = on
+	if !on {
+		return
+	}
+	// Every go command go-sentinel starts inherits the settings
+	env := os.Environ()
+	for _, kv := range offlineEnviron(env)[len(env):] {
+		name, value, _ := strings.Cut(kv, "=")
+		os.Setenv(name, value)
+	}
+	if transport, ok := http.DefaultTransport.(*http.Transport); ok {
+		transport = transport.Clone()
+		transport.DialContext = offlineDialer{}.DialContext
+		http.DefaultTransport = transport
+	}
+}
+
+// Offline reports whether go-sentinel runs offline
+func Offline() bool {
+	return offline
+}
+
+// offlineEnviron returns env with the go command settings of offline mode
+// appended, overriding earlier values, or env unchanged when online.
+// -mod=mod replaces any -mod flag in GOFLAGS.
+func offlineEnviron(env []string) []string {
+	if !offline {
+		return env
+	}
+	var flags []string
+	for _, kv := range env {
+		if value, ok := strings.CutPrefix(kv, "GOFLAGS="); ok {
+			flags = strings.Fields(value)
+		}
+	}
+	kept := []string{}
+	for _, flag := range flags {
+		if !strings.HasPrefix(flag, "-mod=") {
+			kept = append(kept, flag)
+		}
+	}
+	kept = append(kept, "-mod=mod")
+	return append(env, "GOFLAGS="+strings.Join(kept, " "), "GOPROXY=off", "GOTOOLCHAIN=local")
+}
+
+// checkOnline returns an error wrapping ErrOffline when offline and address
+// (host:port, or a bare host) is not on the loopback interface
+func checkOnline(address string) error {
+	if !offline {
+		return nil
+	}
+	host := address
+	if h, _, err := net.SplitHostPort(address); err == nil {
+		host = h
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("%w: refusing to connect to %s", ErrOffline, address)
+}
+
+// offlineDialer dials only loopback addresses and Unix sockets when offline
+type offlineDialer struct{}
+
+func (offlineDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if network != "unix" {
+		if err := checkOnline(address); err != nil {
+			return nil, err
+		}
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, network, address)
+}
+
+// Dial and DialTimeout serve database drivers
+func (d offlineDialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+func (d offlineDialer) DialTimeout(network, address string, timeout time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return d.DialContext(ctx, network, address)
+}
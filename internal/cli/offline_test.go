@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOfflineEnviron(t *testing.T) {
+	env := []string{"PATH=/bin", "GOFLAGS=-mod=vendor -tags=integration"}
+	if got := offlineEnviron(env); len(got) != len(env) {
+		t.Fatalf("offlineEnviron() = %v, want env unchanged when online", got)
+	}
+
+	offline = true
+	defer func() { offline = false }()
+	got := strings.Join(offlineEnviron(env)[len(env):], " ")
+	if want := "GOFLAGS=-tags=integration -mod=mod GOPROXY=off GOTOOLCHAIN=local"; got != want {
+		t.Errorf("offlineEnviron() appended %q, want %q", got, want)
+	}
+}
+
+func TestOfflineDialer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	offline = true
+	defer func() { offline = false }()
+	client := &http.Client{Transport: &http.Transport{DialContext: offlineDialer{}.DialContext}}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get(%s) error = %v, want loopback connections allowed", server.URL, err)
+	}
+	resp.Body.Close()
+
+	if _, err := client.Get("http://192.0.2.1/"); !errors.Is(err, ErrOffline) {
+		t.Errorf("Get() error = %v, want ErrOffline", err)
+	}
+	if err := checkOnline("smtp.example.com:25"); !errors.Is(err, ErrOffline) {
+		t.Errorf("checkOnline() error = %v, want ErrOffline", err)
+	}
+	if err := checkOnline("localhost:5432"); err != nil {
+		t.Errorf("checkOnline(localhost) error = %v, want nil", err)
+	}
+}
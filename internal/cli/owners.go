@@ -0,0 +1,178 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// codeOwnersPaths are the locations searched for a CODEOWNERS file, in order
+var codeOwnersPaths = []string{
+	"CODEOWNERS",
+	filepath.Join(".github", "CODEOWNERS"),
+	filepath.Join("docs", "CODEOWNERS"),
+}
+
+// CodeOwners maps repository paths to owners using CODEOWNERS rules
+type CodeOwners struct {
+	rules []ownerRule
+}
+
+// ownerRule is a single CODEOWNERS line
+type ownerRule struct {
+	pattern string
+	owners  []string
+}
+
+// LoadCodeOwners reads the CODEOWNERS file of the repository rooted at dir.
+// A repository without one yields an empty set of rules.
+func LoadCodeOwners(dir string) (*CodeOwners, error) {
+	for _, name := range codeOwnersPaths {
+		f, err := os.Open(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		defer f.Close()
+		return parseCodeOwners(f)
+	}
+	return &CodeOwners{}, nil
+}
+
+// parseCodeOwners parses CODEOWNERS content
+func parseCodeOwners(r io.Reader) (*CodeOwners, error) {
+	co := &CodeOwners{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		co.rules = append(co.rules, ownerRule{pattern: fields[0], owners: fields[1:]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read CODEOWNERS: %w", err)
+	}
+	return co, nil
+}
+
+// Owners returns the owners of a slash-separated path relative to the
+// repository root. As in CODEOWNERS, the last matching rule wins.
+func (c *CodeOwners) Owners(path string) []string {
+	path = strings.TrimPrefix(filepath.ToSlash(path), "./")
+	for i := len(c.rules) - 1; i >= 0; i-- {
+		if ownerPatternMatches(c.rules[i].pattern, path) {
+			return c.rules[i].owners
+		}
+	}
+	return nil
+}
+
+// ownerPatternMatches applies gitignore-style matching: a pattern without a
+// leading or inner slash matches at any depth, and a pattern matching a
+// directory also matches everything beneath it.
+func ownerPatternMatches(pattern, path string) bool {
+	anchored := strings.HasPrefix(pattern, "/") || strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+	pattern = strings.Trim(pattern, "/")
+	if pattern == "*" {
+		return true
+	}
+	if !anchored {
+		pattern = "**/" + pattern
+	}
+	return matchGlob(pattern, path) || matchGlob(pattern+"/**", path)
+}
+
+// testMetadata resolves owner and last change information for packages
+type testMetadata struct {
+	workDir string
+	owners  *CodeOwners
+}
+
+// newTestMetadata creates a resolver for the repository at workDir
+func newTestMetadata(workDir string) *testMetadata {
+	owners, err := LoadCodeOwners(workDir)
+	if err != nil {
+		owners = &CodeOwners{}
+	}
+	return &testMetadata{workDir: workDir, owners: owners}
+}
+
+// annotate fills in the metadata requested by columns for every test in
+// run. Owners and tags assigned in the ownership file take precedence over
+// CODEOWNERS. dirs maps package import paths to their directories.
+func (m *testMetadata) annotate(run *TestRun, columns []Column, dirs map[string]string) {
+	wantOwner := hasColumn(columns, ColumnOwner)
+	wantTags := hasColumn(columns, ColumnTags)
+	wantChange := hasColumn(columns, ColumnLastChange)
+	if !wantOwner && !wantTags && !wantChange {
+		return
+	}
+
+	ownership, err := LoadOwnership(m.workDir)
+	if err != nil {
+		log.Printf("Error loading ownership: %v", err)
+		ownership = Ownership{}
+	}
+	for _, suite := range run.Suites {
+		for _, test := range suite.Tests {
+			owners, tags := ownership.Lookup(suite.Package, test.Name)
+			if wantOwner && len(owners) > 0 {
+				test.Owner = strings.Join(owners, " ")
+			}
+			if wantTags {
+				test.Tags = tags
+			}
+		}
+	}
+
+	for _, suite := range run.Suites {
+		dir, ok := dirs[suite.Package]
+		if !ok {
+			continue
+		}
+		rel, err := filepath.Rel(m.workDir, dir)
+		if err != nil {
+			continue
+		}
+
+		var owner string
+		var changed time.Time
+		if wantOwner {
+			owner = strings.Join(m.owners.Owners(rel), " ")
+		}
+		if wantChange {
+			changed = m.lastChangeOf(rel)
+		}
+		for _, test := range suite.Tests {
+			if test.Owner == "" {
+				test.Owner = owner
+			}
+			test.LastChange = changed
+		}
+	}
+}
+
+// lastChangeOf returns the time of the last commit touching path, or the
+// zero time when it is unknown
+func (m *testMetadata) lastChangeOf(path string) time.Time {
+	var t time.Time
+	cmd := exec.Command("git", "log", "-1", "--format=%ct", "--", path)
+	cmd.Dir = m.workDir
+	if out, err := cmd.Output(); err == nil {
+		if secs, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64); err == nil {
+			t = time.Unix(secs, 0)
+		}
+	}
+	return t
+}
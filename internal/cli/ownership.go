@@ -0,0 +1,211 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// OwnershipFileName is the versionable file holding owners and tags
+// assigned to packages and tests, kept in the project root
+const OwnershipFileName = ".gosentinel-owners.yaml"
+
+// Ownership maps package import paths to their assigned owners and tags
+type Ownership map[string]*PackageOwnership
+
+// PackageOwnership holds the owners and tags of a package and of
+// individual tests within it
+type PackageOwnership struct {
+	Owners []string                  `yaml:"owners,omitempty"`
+	Tags   []string                  `yaml:"tags,omitempty"`
+	Tests  map[string]*TestOwnership `yaml:"tests,omitempty"`
+}
+
+// TestOwnership holds the owners and tags of a single test
+type TestOwnership struct {
+	Owners []string `yaml:"owners,omitempty"`
+	Tags   []string `yaml:"tags,omitempty"`
+}
+
+// OwnershipTarget identifies a package, or a test within it, written as
+// "pkg" or "pkg:TestName"
+type OwnershipTarget struct {
+	Package string
+	Test    string
+}
+
+// ParseOwnershipTarget parses a "pkg" or "pkg:TestName" target
+func ParseOwnershipTarget(s string) (OwnershipTarget, error) {
+	pkg, test, _ := strings.Cut(s, ":")
+	if pkg == "" {
+		return OwnershipTarget{}, fmt.Errorf("invalid target %q: want package or package:TestName", s)
+	}
+	return OwnershipTarget{Package: pkg, Test: test}, nil
+}
+
+// LoadOwnership reads the ownership file from dir. A missing file yields
+// empty ownership.
+func LoadOwnership(dir string) (Ownership, error) {
+	data, err := os.ReadFile(filepath.Join(dir, OwnershipFileName))
+	if os.IsNotExist(err) {
+		return Ownership{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ownership: %w", err)
+	}
+
+	o := Ownership{}
+	if err := yaml.Unmarshal(data, &o); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", OwnershipFileName, err)
+	}
+	return o, nil
+}
+
+// Save writes the ownership file to dir. Keys are sorted so the file diffs
+// cleanly under version control.
+func (o Ownership) Save(dir string) error {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(o); err != nil {
+		return fmt.Errorf("failed to encode ownership: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return fmt.Errorf("failed to encode ownership: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, OwnershipFileName), buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write ownership: %w", err)
+	}
+	return nil
+}
+
+// SetOwners replaces the owners of target. An empty list removes them.
+func (o Ownership) SetOwners(target OwnershipTarget, owners []string) {
+	if target.Test == "" {
+		o.pkg(target.Package).Owners = owners
+	} else {
+		o.test(target).Owners = owners
+	}
+	o.prune(target.Package)
+}
+
+// AddTags adds tags to target, ignoring ones it already has
+func (o Ownership) AddTags(target OwnershipTarget, tags []string) {
+	if target.Test == "" {
+		p := o.pkg(target.Package)
+		p.Tags = mergeTags(p.Tags, tags)
+	} else {
+		t := o.test(target)
+		t.Tags = mergeTags(t.Tags, tags)
+	}
+}
+
+// RemoveTags removes tags from target
+func (o Ownership) RemoveTags(target OwnershipTarget, tags []string) {
+	drop := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		drop[tag] = true
+	}
+	keep := func(existing []string) []string {
+		var result []string
+		for _, tag := range existing {
+			if !drop[tag] {
+				result = append(result, tag)
+			}
+		}
+		return result
+	}
+
+	if target.Test == "" {
+		p := o.pkg(target.Package)
+		p.Tags = keep(p.Tags)
+	} else {
+		t := o.test(target)
+		t.Tags = keep(t.Tags)
+	}
+	o.prune(target.Package)
+}
+
+// Lookup returns the owners and tags of a test. Test owners replace the
+// package owners; tags from both levels are combined.
+func (o Ownership) Lookup(pkg, test string) (owners, tags []string) {
+	p, ok := o[pkg]
+	if !ok {
+		return nil, nil
+	}
+	owners, tags = p.Owners, p.Tags
+	if t, ok := p.Tests[topLevelTest(test)]; ok {
+		if len(t.Owners) > 0 {
+			owners = t.Owners
+		}
+		tags = mergeTags(tags, t.Tags)
+	}
+	return owners, tags
+}
+
+// pkg returns the entry for a package, creating it if needed
+func (o Ownership) pkg(name string) *PackageOwnership {
+	p, ok := o[name]
+	if !ok {
+		p = &PackageOwnership{}
+		o[name] = p
+	}
+	return p
+}
+
+// test returns the entry for a test, creating it if needed
+func (o Ownership) test(target OwnershipTarget) *TestOwnership {
+	p := o.pkg(target.Package)
+	if p.Tests == nil {
+		p.Tests = make(map[string]*TestOwnership)
+	}
+	t, ok := p.Tests[target.Test]
+	if !ok {
+		t = &TestOwnership{}
+		p.Tests[target.Test] = t
+	}
+	return t
+}
+
+// prune drops empty entries for a package so the file stays minimal
+func (o Ownership) prune(name string) {
+	p, ok := o[name]
+	if !ok {
+		return
+	}
+	for test, t := range p.Tests {
+		if len(t.Owners) == 0 && len(t.Tags) == 0 {
+			delete(p.Tests, test)
+		}
+	}
+	if len(p.Owners) == 0 && len(p.Tags) == 0 && len(p.Tests) == 0 {
+		delete(o, name)
+	}
+}
+
+// mergeTags returns the sorted union of two tag lists
+func mergeTags(a, b []string) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	var result []string
+	for _, tag := range append(append([]string{}, a...), b...) {
+		if !seen[tag] {
+			seen[tag] = true
+			result = append(result, tag)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// topLevelTest returns the test function a subtest belongs to
+func topLevelTest(name string) string {
+	if i := strings.Index(name, "/"); i >= 0 {
+		return name[:i]
+	}
+	return name
+}
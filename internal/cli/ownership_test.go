@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestOwnership_EditAndLookup(t *testing.T) {
+	dir := t.TempDir()
+	o := Ownership{}
+
+	pkg := OwnershipTarget{Package: "example.com/api"}
+	upload := OwnershipTarget{Package: "example.com/api", Test: "TestUpload"}
+	o.SetOwners(pkg, []string{"@api-team"})
+	o.AddTags(pkg, []string{"http"})
+	o.SetOwners(upload, []string{"@storage"})
+	o.AddTags(upload, []string{"slow", "http"})
+
+	if err := o.Save(dir); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded, err := LoadOwnership(dir)
+	if err != nil {
+		t.Fatalf("LoadOwnership failed: %v", err)
+	}
+
+	tests := []struct {
+		test       string
+		wantOwners []string
+		wantTags   []string
+	}{
+		{"TestList", []string{"@api-team"}, []string{"http"}},
+		{"TestUpload", []string{"@storage"}, []string{"http", "slow"}},
+		{"TestUpload/large_file", []string{"@storage"}, []string{"http", "slow"}},
+	}
+	for _, tt := range tests {
+		owners, tags := loaded.Lookup("example.com/api", tt.test)
+		if !reflect.DeepEqual(owners, tt.wantOwners) || !reflect.DeepEqual(tags, tt.wantTags) {
+			t.Errorf("Lookup(%s) = %v, %v, want %v, %v", tt.test, owners, tags, tt.wantOwners, tt.wantTags)
+		}
+	}
+
+	// Removing everything from a test drops its entry
+	loaded.SetOwners(upload, nil)
+	loaded.RemoveTags(upload, []string{"slow", "http"})
+	if _, ok := loaded["example.com/api"].Tests["TestUpload"]; ok {
+		t.Error("Expected empty test entry to be pruned")
+	}
+}
+
+func TestLoadOwnership_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, OwnershipFileName), []byte("example.com/api: [unclosed"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := LoadOwnership(dir); err == nil {
+		t.Error("Expected error for invalid YAML")
+	}
+}
+
+func TestParseOwnershipTarget(t *testing.T) {
+	got, err := ParseOwnershipTarget("example.com/api:TestUpload")
+	if err != nil || got != (OwnershipTarget{Package: "example.com/api", Test: "TestUpload"}) {
+		t.Errorf("ParseOwnershipTarget() = %+v, %v", got, err)
+	}
+	if _, err := ParseOwnershipTarget(":TestUpload"); err == nil {
+		t.Error("Expected error for missing package")
+	}
+}
@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+)
+
+// PackageInfo describes a Go package as reported by 'go list -json'
+type PackageInfo struct {
+	ImportPath   string   `json:"ImportPath"`
+	Name         string   `json:"Name"`
+	Dir          string   `json:"Dir"`
+	GoFiles      []string `json:"GoFiles,omitempty"`
+	TestGoFiles  []string `json:"TestGoFiles,omitempty"`
+	XTestGoFiles []string `json:"XTestGoFiles,omitempty"`
+	Imports      []string `json:"Imports,omitempty"`
+	Deps         []string `json:"Deps,omitempty"`
+	TestImports  []string `json:"TestImports,omitempty"`
+	XTestImports []string `json:"XTestImports,omitempty"`
+
+	// IgnoredGoFiles are excluded by build constraints, such as test files
+	// only built with -tags=integration
+	IgnoredGoFiles []string `json:"IgnoredGoFiles,omitempty"`
+	// Error is why 'go list -e' could not load the package
+	Error *PackageError `json:"Error,omitempty"`
+}
+
+// PackageError is a package loading error reported by 'go list -e'
+type PackageError struct {
+	Err string `json:"Err"`
+}
+
+// HasTests reports whether the package contains any test files
+func (p *PackageInfo) HasTests() bool {
+	return len(p.TestGoFiles) > 0 || len(p.XTestGoFiles) > 0
+}
+
+// expandPackagePatterns resolves package patterns (such as ./...) into the
+// packages they match by shelling out to 'go list -json'
+func expandPackagePatterns(workDir string, patterns []string) ([]*PackageInfo, error) {
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+
+	args := append([]string{"list", "-json"}, patterns...)
+	cmd := exec.Command(goBinary, args...)
+	cmd.Dir = workDir
+	cmd.Env = os.Environ()
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list packages: %w: %s", err, stderr.String())
+	}
+
+	return decodePackageList(bytes.NewReader(output))
+}
+
+// decodePackageList decodes the stream of JSON objects printed by 'go list -json'
+func decodePackageList(r io.Reader) ([]*PackageInfo, error) {
+	var pkgs []*PackageInfo
+	dec := json.NewDecoder(r)
+	for {
+		var pkg PackageInfo
+		if err := dec.Decode(&pkg); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("error decoding package list: %w", err)
+		}
+		pkgs = append(pkgs, &pkg)
+	}
+	return pkgs, nil
+}
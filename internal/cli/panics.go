@@ -0,0 +1,147 @@
+package cli
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// panicContextLines is how many lines of source are shown on each side of
+// the project frame a panic is reported at
+const panicContextLines = 2
+
+// panicFrameRe matches the file line of a stack frame, such as
+// "\t/src/app/calc.go:8 +0x36"
+var panicFrameRe = regexp.MustCompile(`^\s+((?:[A-Za-z]:)?[^\s:]+\.(?:go|s)):(\d+)(?: \+0x[0-9a-f]+)?$`)
+
+// TestPanic is the panic a test died of, parsed from its output
+type TestPanic struct {
+	Message   string        // Panic value, without the "panic: " prefix
+	Goroutine string        // Header of the panicking goroutine, as "goroutine 7 [running]"
+	Frames    []*PanicFrame // Stack of the panicking goroutine, innermost first
+}
+
+// PanicFrame is one call of a panic's stack
+type PanicFrame struct {
+	Function string // Function called, as printed in the trace: "example.com/calc.Nth(...)"
+	File     string // Source file as printed, or relative to the project for project frames
+	Line     int
+	Project  bool // File lies in the project rather than the standard library or a dependency
+}
+
+// ParsePanic extracts the panic and the stack of the panicking goroutine
+// from test output, or returns nil when the test did not panic. Timeouts,
+// which go test reports as panics too, are left to their own category.
+func ParsePanic(output string) *TestPanic {
+	lines := strings.Split(strings.ReplaceAll(output, "\r\n", "\n"), "\n")
+	start := -1
+	for i, line := range lines {
+		if strings.HasPrefix(line, "panic: ") && !strings.HasPrefix(line, "panic: test timed out") {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return nil
+	}
+
+	// The panic value runs until the blank line before the goroutine
+	p := &TestPanic{}
+	var message []string
+	i := start
+	for ; i < len(lines) && strings.TrimSpace(lines[i]) != "" && !strings.HasPrefix(lines[i], "goroutine "); i++ {
+		message = append(message, lines[i])
+	}
+	p.Message = strings.TrimPrefix(strings.Join(message, "\n"), "panic: ")
+	if j := strings.LastIndex(p.Message, " [recovered"); j > 0 && strings.HasSuffix(p.Message, "]") {
+		p.Message = p.Message[:j]
+	}
+
+	// The goroutine that panicked is printed first, as function and file
+	// line pairs ending at the next blank line
+	for ; i < len(lines) && !strings.HasPrefix(lines[i], "goroutine "); i++ {
+	}
+	if i == len(lines) {
+		return p
+	}
+	p.Goroutine = strings.TrimSuffix(strings.TrimSpace(lines[i]), ":")
+	for i++; i+1 < len(lines) && strings.TrimSpace(lines[i]) != ""; i++ {
+		m := panicFrameRe.FindStringSubmatch(lines[i+1])
+		if m == nil {
+			continue
+		}
+		frame := &PanicFrame{Function: strings.TrimSpace(lines[i]), File: filepath.ToSlash(m[1])}
+		frame.Line, _ = strconv.Atoi(m[2])
+		p.Frames = append(p.Frames, frame)
+		i++
+	}
+	return p
+}
+
+// ProjectFrame returns the innermost frame in the project: where the
+// panic surfaced in code the user can change
+func (p *TestPanic) ProjectFrame() *PanicFrame {
+	for _, frame := range p.Frames {
+		if frame.Project {
+			return frame
+		}
+	}
+	return nil
+}
+
+// addPanicContext parses the panics of failed tests in run, marks the
+// frames in files under dir as the project's, with their paths made
+// relative to it, and points each test's error at the innermost project
+// frame with its surrounding source
+func addPanicContext(run *TestRun, dir string) {
+	sources := make(map[string][]string)
+	for _, suite := range run.Suites {
+		for _, test := range suite.Tests {
+			if test.Status != TestStatusFailed || test.Error == nil {
+				continue
+			}
+			p := ParsePanic(test.Error.Message)
+			if p == nil {
+				continue
+			}
+			for _, frame := range p.Frames {
+				if rel, ok := projectPath(dir, frame.File); ok {
+					frame.File, frame.Project = rel, true
+				}
+			}
+			test.Panic = p
+			frame := p.ProjectFrame()
+			if frame == nil {
+				continue
+			}
+			path := filepath.Join(dir, filepath.FromSlash(frame.File))
+			lines, ok := sources[path]
+			if !ok {
+				lines = readSourceLines(path)
+				sources[path] = lines
+			}
+			loc := &SourceLocation{File: frame.File, Line: frame.Line}
+			loc.Snippet, loc.StartLine = sourceContext(lines, frame.Line, panicContextLines)
+			test.Error.Location = loc
+		}
+	}
+}
+
+// projectPath returns file as a slash path relative to dir when it lies
+// there and outside the module cache
+func projectPath(dir, file string) (string, bool) {
+	path := filepath.FromSlash(file)
+	if !filepath.IsAbs(path) || strings.Contains(file, "/pkg/mod/") {
+		return "", false
+	}
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return "", false
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", false
+	}
+	return rel, true
+}
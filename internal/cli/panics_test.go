@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// samplePanic is the output of a test panicking in project code, with the
+// project rooted at /src/app
+const samplePanic = `=== RUN   TestNth
+--- FAIL: TestNth (0.00s)
+panic: runtime error: index out of range [5] with length 2 [recovered, repanicked]
+
+goroutine 7 [running]:
+testing.tRunner.func1.2({0x6c90e0, 0x3a52ce5f20f0})
+	/usr/local/go/src/testing/testing.go:2123 +0x232
+testing.tRunner.func1()
+	/usr/local/go/src/testing/testing.go:2126 +0x329
+panic({0x6c90e0?, 0x3a52ce5f20f0?})
+	/usr/local/go/src/runtime/panic.go:859 +0x125
+example.com/app/calc.Nth(...)
+	/src/app/calc/calc.go:8
+example.com/app/calc.TestNth(0x3a52ce670488?)
+	/src/app/calc/calc_test.go:8 +0x36
+testing.tRunner(0x3a52ce670488, 0x6d4818)
+	/usr/local/go/src/testing/testing.go:2193 +0xea
+created by testing.(*T).Run in goroutine 1
+	/usr/local/go/src/testing/testing.go:2258 +0x4d4
+
+goroutine 1 [chan receive]:
+testing.(*T).Run(0x3a52ce670300, {0x6a1b2c, 0x7}, 0x6d4818)
+	/usr/local/go/src/testing/testing.go:2261 +0x4f4
+`
+
+func TestParsePanic(t *testing.T) {
+	p := ParsePanic(samplePanic)
+	if p == nil {
+		t.Fatal("ParsePanic() = nil, want the panic")
+	}
+	if p.Message != "runtime error: index out of range [5] with length 2" {
+		t.Errorf("Message = %q", p.Message)
+	}
+	if p.Goroutine != "goroutine 7 [running]" {
+		t.Errorf("Goroutine = %q", p.Goroutine)
+	}
+	if len(p.Frames) != 7 {
+		t.Fatalf("got %d frames, want the 7 of the panicking goroutine", len(p.Frames))
+	}
+	if f := p.Frames[3]; f.Function != "example.com/app/calc.Nth(...)" || f.File != "/src/app/calc/calc.go" || f.Line != 8 {
+		t.Errorf("Frames[3] = %+v, want calc.Nth at calc.go:8", f)
+	}
+
+	if p := ParsePanic("--- FAIL: TestA\n    a_test.go:3: got 1\n"); p != nil {
+		t.Errorf("ParsePanic() = %+v for an assertion failure, want nil", p)
+	}
+	if p := ParsePanic("panic: test timed out after 10m0s\n"); p != nil {
+		t.Errorf("ParsePanic() = %+v for a timeout, want nil", p)
+	}
+}
+
+func TestAddPanicContext(t *testing.T) {
+	dir := t.TempDir()
+	src := "package calc\n\nimport \"sort\"\n\n// Nth returns the nth smallest value\nfunc Nth(xs []int, n int) int {\n\tsort.Ints(xs)\n\treturn xs[n]\n}\n"
+	if err := os.MkdirAll(filepath.Join(dir, "calc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "calc", "calc.go"), []byte(src), 0600); err != nil {
+		t.Fatal(err)
+	}
+	output := strings.ReplaceAll(samplePanic, "/src/app", filepath.ToSlash(dir))
+	test := &TestResult{Name: "TestNth", Status: TestStatusFailed, Error: &TestError{Message: output}}
+	addPanicContext(&TestRun{Suites: []*TestSuite{{Package: "example.com/app/calc", Tests: []*TestResult{test}}}}, dir)
+
+	if test.Panic == nil {
+		t.Fatal("Panic = nil, want the parsed panic")
+	}
+	frame := test.Panic.ProjectFrame()
+	if frame == nil || frame.File != "calc/calc.go" || frame.Line != 8 {
+		t.Fatalf("ProjectFrame() = %+v, want calc/calc.go:8", frame)
+	}
+	if test.Panic.Frames[0].Project {
+		t.Errorf("testing frame marked as the project's")
+	}
+	loc := test.Error.Location
+	if loc == nil || loc.File != "calc/calc.go" || loc.StartLine != 6 || !strings.Contains(loc.Snippet, "return xs[n]") {
+		t.Errorf("Location = %+v, want the source around calc.go:8", loc)
+	}
+
+	var buf bytes.Buffer
+	r := NewRendererWithStyle(&buf, false)
+	r.RenderTestResult(test)
+	out := buf.String()
+	for _, want := range []string{"PANIC", "→ panic: runtime error: index out of range", "… 3 frames in testing, runtime", "example.com/app/calc.Nth  calc/calc.go:8", "8 │     return xs[n]"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "goroutine 1") {
+		t.Errorf("output shows other goroutines:\n%s", out)
+	}
+}
@@ -0,0 +1,276 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParallelFileName holds the highest -parallel each package passed at in
+// the last 'go-sentinel parallel' analysis, in the history directory
+const ParallelFileName = "parallel.json"
+
+// parallelVersion is the format of the parallel recommendations file
+const parallelVersion = 1
+
+// parallelEnv passes the -parallel of each package directory to the
+// go test -exec wrapper, as limitsEnv does for resource limits
+const parallelEnv = "GO_SENTINEL_PARALLEL"
+
+// Outcomes of a package in a parallel-safety analysis
+const (
+	ParallelSafe       = "safe"        // Passed at every level tried
+	ParallelLimited    = "limited"     // Passed up to a level, failed above it
+	ParallelSerialOnly = "serial-only" // Passed only with -parallel 1
+	ParallelFailing    = "failing"     // Failed even with -parallel 1
+)
+
+// ParallelRecommendation is the outcome of a package in a parallel-safety
+// analysis
+type ParallelRecommendation struct {
+	Package    string    `json:"package"`
+	Outcome    string    `json:"outcome"`
+	Parallel   int       `json:"parallel,omitempty"` // Highest -parallel the package passed at; 0 when safe at any
+	FailedAt   int       `json:"failedAt,omitempty"` // Lowest -parallel the package failed at
+	Failure    string    `json:"failure,omitempty"`  // First line of the failure, such as a data race
+	AnalyzedAt time.Time `json:"analyzedAt"`
+}
+
+// ParallelRecommendations are the -parallel levels the scheduler gives the
+// analyzed packages
+type ParallelRecommendations struct {
+	path     string
+	Packages map[string]*ParallelRecommendation
+}
+
+// parallelFile is the on-disk form of ParallelRecommendations
+type parallelFile struct {
+	Version  int                       `json:"version"`
+	Packages []*ParallelRecommendation `json:"packages"`
+}
+
+// LoadParallelRecommendations reads the recommendations of the project at
+// workDir. A project never analyzed has none.
+func LoadParallelRecommendations(workDir string) (*ParallelRecommendations, error) {
+	p := &ParallelRecommendations{
+		path:     filepath.Join(workDir, HistoryDir, ParallelFileName),
+		Packages: make(map[string]*ParallelRecommendation),
+	}
+	data, err := os.ReadFile(p.path)
+	if os.IsNotExist(err) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read parallel recommendations: %w", err)
+	}
+	var file parallelFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", ParallelFileName, err)
+	}
+	for _, rec := range file.Packages {
+		p.Packages[rec.Package] = rec
+	}
+	return p, nil
+}
+
+// Record replaces the recommendations of the analyzed packages
+func (p *ParallelRecommendations) Record(recs []*ParallelRecommendation) {
+	for _, rec := range recs {
+		p.Packages[rec.Package] = rec
+	}
+}
+
+// Save writes the recommendations
+func (p *ParallelRecommendations) Save() error {
+	recs := make([]*ParallelRecommendation, 0, len(p.Packages))
+	for _, rec := range p.Packages {
+		recs = append(recs, rec)
+	}
+	sort.Slice(recs, func(i, j int) bool { return recs[i].Package < recs[j].Package })
+	data, err := json.MarshalIndent(parallelFile{Version: parallelVersion, Packages: recs}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode parallel recommendations: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(p.path), 0755); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+	tmp := p.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write parallel recommendations: %w", err)
+	}
+	if err := os.Rename(tmp, p.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write parallel recommendations: %w", err)
+	}
+	return nil
+}
+
+// For returns the -parallel a package runs with, 0 for go's default
+func (p *ParallelRecommendations) For(importPath string) int {
+	if rec, ok := p.Packages[importPath]; ok && (rec.Outcome == ParallelLimited || rec.Outcome == ParallelSerialOnly) {
+		return rec.Parallel
+	}
+	return 0
+}
+
+// env returns the variable passing the -parallel of each package to the
+// go test -exec wrapper, empty when no package is limited
+func (p *ParallelRecommendations) env(pkgs []*PackageInfo) (string, error) {
+	byDir := make(map[string]int)
+	for _, pkg := range pkgs {
+		if n := p.For(pkg.ImportPath); n > 0 {
+			byDir[pkg.Dir] = n
+		}
+	}
+	if len(byDir) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(byDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode parallel recommendations: %w", err)
+	}
+	return parallelEnv + "=" + string(data), nil
+}
+
+// parallelEnv returns the environment assigning the test binaries of a run
+// the -parallel recommended for their packages, nil when none is limited
+func (r *Runner) parallelEnv(opts RunOptions) ([]string, error) {
+	if opts.Parallel == nil || len(opts.Parallel.Packages) == 0 {
+		return nil, nil
+	}
+	pkgs, err := r.pkgCache.Get(opts.Packages)
+	if err != nil {
+		return nil, err
+	}
+	env, err := opts.Parallel.env(pkgs)
+	if err != nil || env == "" {
+		return nil, err
+	}
+	return []string{env}, nil
+}
+
+// ParallelArgs adds the -parallel go-sentinel recommends for the package
+// in dir to the arguments of its test binary, unless they already set one
+func ParallelArgs(dir string, args []string) []string {
+	var byDir map[string]int
+	if err := json.Unmarshal([]byte(os.Getenv(parallelEnv)), &byDir); err != nil || byDir[dir] == 0 {
+		return args
+	}
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-test.parallel") {
+			return args
+		}
+	}
+	return append(args, "-test.parallel="+strconv.Itoa(byDir[dir]))
+}
+
+// ParallelAnalysis configures a parallel-safety analysis
+type ParallelAnalysis struct {
+	Max   int  // Highest -parallel tried, GOMAXPROCS when 0
+	Count int  // Runs of the tests at each level, as go test -count; 1 when 0
+	Race  bool // Run with the race detector, so data races fail the tests
+
+	// Progress is told the outcome of each level tried, nil for none
+	Progress func(pkg string, parallel int, passed bool)
+}
+
+// levels returns the -parallel values tried: 1, then doubling up to Max
+func (a ParallelAnalysis) levels() []int {
+	highest := a.Max
+	if highest <= 0 {
+		highest = runtime.GOMAXPROCS(0)
+	}
+	levels := []int{1}
+	for n := 2; n < highest; n *= 2 {
+		levels = append(levels, n)
+	}
+	if highest > 1 {
+		levels = append(levels, highest)
+	}
+	return levels
+}
+
+// AnalyzeParallelism runs the tests of each package matching patterns at
+// increasing -parallel levels, stopping at the first that fails, to find
+// the highest level each package passes at. Packages without tests are
+// left out.
+func AnalyzeParallelism(workDir string, patterns []string, a ParallelAnalysis) ([]*ParallelRecommendation, error) {
+	pkgs, err := expandPackagePatterns(workDir, patterns)
+	if err != nil {
+		return nil, err
+	}
+	count := max(a.Count, 1)
+	levels := a.levels()
+	env := offlineEnviron(os.Environ())
+	if a.Race {
+		env = append(env, "GORACE=halt_on_error=1")
+	}
+
+	var recs []*ParallelRecommendation
+	for _, pkg := range pkgs {
+		if len(pkg.TestGoFiles)+len(pkg.XTestGoFiles) == 0 {
+			continue
+		}
+		rec := &ParallelRecommendation{Package: pkg.ImportPath, Outcome: ParallelSafe, AnalyzedAt: time.Now()}
+		for _, level := range levels {
+			args := []string{"test", "-count=" + strconv.Itoa(count), "-parallel=" + strconv.Itoa(level)}
+			if a.Race {
+				args = append(args, "-race")
+			}
+			cmd := exec.Command(goBinary, append(args, pkg.ImportPath)...)
+			cmd.Dir = workDir
+			cmd.Env = env
+			output, runErr := cmd.CombinedOutput()
+			if a.Progress != nil {
+				a.Progress(pkg.ImportPath, level, runErr == nil)
+			}
+			if runErr == nil {
+				rec.Parallel = level
+				continue
+			}
+
+			rec.FailedAt = level
+			rec.Failure = parallelFailure(string(output))
+			switch {
+			case level == 1:
+				rec.Outcome, rec.Parallel = ParallelFailing, 0
+			case rec.Parallel == 1:
+				rec.Outcome = ParallelSerialOnly
+			default:
+				rec.Outcome = ParallelLimited
+			}
+			break
+		}
+		if rec.Outcome == ParallelSafe {
+			rec.Parallel = 0
+		}
+		recs = append(recs, rec)
+	}
+	return recs, nil
+}
+
+// parallelFailure returns the line best describing why a package failed:
+// a data race if one was detected, else the first failing test
+func parallelFailure(output string) string {
+	var first string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "WARNING: DATA RACE") || strings.Contains(line, "race detected during execution") {
+			return "data race"
+		}
+		if first == "" && strings.HasPrefix(line, "--- FAIL") {
+			first = line
+		}
+	}
+	if first == "" {
+		first = failureSummary(output)
+	}
+	return first
+}
@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParallelAnalysis_Levels(t *testing.T) {
+	for _, tt := range []struct {
+		max  int
+		want string
+	}{
+		{1, "[1]"},
+		{4, "[1 2 4]"},
+		{6, "[1 2 4 6]"},
+	} {
+		if got := fmt.Sprint(ParallelAnalysis{Max: tt.max}.levels()); got != tt.want {
+			t.Errorf("levels() with max %d = %s, want %s", tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestParallelRecommendations_Args(t *testing.T) {
+	dir := t.TempDir()
+	recs, err := LoadParallelRecommendations(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recs.Record([]*ParallelRecommendation{
+		{Package: "example.com/db", Outcome: ParallelSerialOnly, Parallel: 1, FailedAt: 2, Failure: "data race"},
+		{Package: "example.com/api", Outcome: ParallelLimited, Parallel: 4, FailedAt: 8},
+		{Package: "example.com/util", Outcome: ParallelSafe},
+	})
+	if err := recs.Save(); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadParallelRecommendations(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.For("example.com/db") != 1 || loaded.For("example.com/api") != 4 || loaded.For("example.com/util") != 0 {
+		t.Errorf("loaded recommendations = %+v, want db at 1, api at 4, util unlimited", loaded.Packages)
+	}
+
+	env, err := loaded.env([]*PackageInfo{
+		{ImportPath: "example.com/db", Dir: "/src/db"},
+		{ImportPath: "example.com/util", Dir: "/src/util"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, value, _ := strings.Cut(env, "=")
+	t.Setenv(name, value)
+	if got := ParallelArgs("/src/db", []string{"-test.v=test2json"}); strings.Join(got, " ") != "-test.v=test2json -test.parallel=1" {
+		t.Errorf("ParallelArgs() = %v, want -test.parallel=1 added", got)
+	}
+	if got := ParallelArgs("/src/db", []string{"-test.parallel=3"}); len(got) != 1 {
+		t.Errorf("ParallelArgs() = %v, want the explicit -parallel kept", got)
+	}
+	if got := ParallelArgs("/src/util", nil); len(got) != 0 {
+		t.Errorf("ParallelArgs() = %v, want nothing for an unlimited package", got)
+	}
+}
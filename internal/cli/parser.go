@@ -11,6 +11,8 @@ import (
 	"sort"
 	"strings"
 	"time"
+
+	"github.com/newbpydev/go-sentinel/pkg/sentinelio"
 )
 
 // GoTestEvent represents the JSON output from 'go test -json'
@@ -21,11 +23,18 @@ type GoTestEvent struct {
 	Test    string    `json:"Test,omitempty"`
 	Output  string    `json:"Output,omitempty"`
 	Elapsed float64   `json:"Elapsed,omitempty"`
+
+	ImportPath  string `json:"ImportPath,omitempty"`  // Package being built, on build-output events
+	FailedBuild string `json:"FailedBuild,omitempty"` // Package that did not build, on package fail events
 }
 
 var (
 	// Regular expressions for parsing test output
 	errorLocationRe = regexp.MustCompile(`(?m)^\s*([\w./-]+\.go):(\d+)(?::(\d+))?:`)
+
+	// benchmarkNameRe matches the name of a benchmark printed before its
+	// measurements
+	benchmarkNameRe = regexp.MustCompile(`^(Benchmark\S*)\s+$`)
 )
 
 // Parser handles parsing of go test -json output
@@ -33,6 +42,9 @@ type Parser struct {
 	currentRun   *TestRun
 	currentSuite *TestSuite
 	suites       map[string]*TestSuite
+	buildOutput  map[string]string // Compiler output by the package being built
+
+	benchmarkNames map[string]string // Benchmark awaiting its measurements, by package
 }
 
 // NewParser creates a new parser instance
@@ -53,6 +65,8 @@ func (p *Parser) Parse(r io.Reader) (*TestRun, error) {
 		NumSkipped: 0,
 	}
 	p.suites = make(map[string]*TestSuite)
+	p.buildOutput = make(map[string]string)
+	p.benchmarkNames = make(map[string]string)
 
 	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
@@ -92,6 +106,8 @@ func (p *Parser) handleEvent(event *GoTestEvent) error {
 		return p.handleTestSkip(event)
 	case "output":
 		return p.handleTestOutput(event)
+	case "build-output":
+		p.buildOutput[event.ImportPath] += event.Output
 	}
 	return nil
 }
@@ -135,6 +151,7 @@ func (p *Parser) handleTestStart(event *GoTestEvent) error {
 
 	test := &TestResult{
 		Name:      event.Test,
+		Package:   event.Package,
 		Status:    TestStatusRunning,
 		StartTime: event.Time,
 	}
@@ -157,6 +174,7 @@ func (p *Parser) handleTestRun(event *GoTestEvent) error {
 
 	test := &TestResult{
 		Name:      event.Test,
+		Package:   event.Package,
 		Status:    TestStatusRunning,
 		StartTime: event.Time,
 	}
@@ -168,6 +186,10 @@ func (p *Parser) handleTestRun(event *GoTestEvent) error {
 
 // handleTestPass processes a test pass event
 func (p *Parser) handleTestPass(event *GoTestEvent) error {
+	if event.Test == "" {
+		return p.handlePackageEnd(event)
+	}
+
 	test := p.findTest(event.Test)
 	if test == nil {
 		return nil
@@ -186,6 +208,10 @@ func (p *Parser) handleTestPass(event *GoTestEvent) error {
 
 // handleTestFail processes a test fail event
 func (p *Parser) handleTestFail(event *GoTestEvent) error {
+	if event.Test == "" {
+		return p.handlePackageEnd(event)
+	}
+
 	test := p.findTest(event.Test)
 	if test == nil {
 		return nil
@@ -210,8 +236,29 @@ func (p *Parser) handleTestFail(event *GoTestEvent) error {
 	return nil
 }
 
+// handlePackageEnd records the end of a package's execution
+func (p *Parser) handlePackageEnd(event *GoTestEvent) error {
+	suite, exists := p.suites[event.Package]
+	if !exists {
+		return nil
+	}
+	suite.EndTime = event.Time
+	if event.FailedBuild != "" {
+		suite.BuildOutput = p.buildOutput[event.FailedBuild]
+		suite.BuildErrors = ParseBuildErrors(suite.BuildOutput)
+	}
+	if event.Action == "fail" && isSetupFailure(suite) {
+		suite.SetupFailed = true
+	}
+	return nil
+}
+
 // handleTestSkip processes a test skip event
 func (p *Parser) handleTestSkip(event *GoTestEvent) error {
+	if event.Test == "" {
+		return p.handlePackageEnd(event)
+	}
+
 	test := p.findTest(event.Test)
 	if test == nil {
 		return nil
@@ -230,8 +277,25 @@ func (p *Parser) handleTestSkip(event *GoTestEvent) error {
 
 // handleTestOutput processes a test output event
 func (p *Parser) handleTestOutput(event *GoTestEvent) error {
+	// Structured annotations are metadata, not output
+	if annotation, ok := sentinelio.Parse(event.Output); ok {
+		p.handleAnnotation(event, annotation)
+		return nil
+	}
+
+	// Benchmark results are reported as output of whichever test ran last
+	if p.handleBenchmarkOutput(event) {
+		return nil
+	}
+
 	if event.Test == "" {
 		// Package-level output
+		if suite, exists := p.suites[event.Package]; exists {
+			suite.Output += event.Output
+			if strings.Contains(event.Output, "(cached)") {
+				suite.Cached = true
+			}
+		}
 		if p.currentSuite != nil && strings.Contains(event.Output, "FAIL") {
 			p.currentSuite.NumFailed++
 			p.currentRun.NumFailed++
@@ -264,6 +328,52 @@ func (p *Parser) handleTestOutput(event *GoTestEvent) error {
 	return nil
 }
 
+// handleBenchmarkOutput records the benchmark result in an output event
+// and reports whether the event was one. The name of a benchmark is
+// printed before it runs, so it usually arrives in an event of its own,
+// without a newline, ahead of the measurements.
+func (p *Parser) handleBenchmarkOutput(event *GoTestEvent) bool {
+	suite, exists := p.suites[event.Package]
+	if !exists {
+		return false
+	}
+	if name := benchmarkNameRe.FindStringSubmatch(event.Output); name != nil {
+		p.benchmarkNames[event.Package] = name[1]
+		return true
+	}
+	line := event.Output
+	if name, ok := p.benchmarkNames[event.Package]; ok && !strings.HasPrefix(line, "Benchmark") {
+		line = name + "\t" + line
+	}
+	bench := parseBenchmarkLine(line)
+	if bench == nil {
+		return false
+	}
+	delete(p.benchmarkNames, event.Package)
+	suite.Benchmarks = append(suite.Benchmarks, bench)
+	return true
+}
+
+// handleAnnotation attaches an annotation to its test, or to the package
+// when it was emitted outside a test
+func (p *Parser) handleAnnotation(event *GoTestEvent, annotation sentinelio.Annotation) {
+	if annotation.Kind == sentinelio.KindAssertions {
+		if test := p.findTest(event.Test); test != nil {
+			test.Assertions += int(annotation.Value)
+		}
+		return
+	}
+	if event.Test == "" {
+		if suite, exists := p.suites[event.Package]; exists {
+			suite.Annotations = append(suite.Annotations, annotation)
+		}
+		return
+	}
+	if test := p.findTest(event.Test); test != nil {
+		test.Annotations = append(test.Annotations, annotation)
+	}
+}
+
 // finalize processes any remaining test results and updates statistics
 func (p *Parser) finalize() {
 	// Sort suites by package name for consistent output
@@ -234,3 +234,72 @@ func TestParser_ProcessEvent_Timing(t *testing.T) {
 		t.Errorf("Duration = %v, want %v", test.Duration, 100*time.Millisecond)
 	}
 }
+
+func TestParser_Annotations(t *testing.T) {
+	input := `{"Action":"start","Package":"example.com/pkg/foo"}
+{"Action":"output","Package":"example.com/pkg/foo","Output":"##sentinel[link] {\"name\":\"Setup log\",\"url\":\"https://ci.example.com/1\"}\n"}
+{"Action":"run","Package":"example.com/pkg/foo","Test":"TestImport"}
+{"Action":"output","Package":"example.com/pkg/foo","Test":"TestImport","Output":"    import_test.go:12: ##sentinel[metric] {\"name\":\"rows\",\"value\":1200,\"unit\":\"rows\"}\n"}
+{"Action":"output","Package":"example.com/pkg/foo","Test":"TestImport","Output":"    import_test.go:20: import failed\n"}
+{"Action":"fail","Package":"example.com/pkg/foo","Test":"TestImport","Elapsed":0.1}
+`
+
+	run, err := NewParser().Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	suite := run.Suites[0]
+	if len(suite.Annotations) != 1 || suite.Annotations[0].URL != "https://ci.example.com/1" {
+		t.Errorf("suite annotations = %+v, want setup log link", suite.Annotations)
+	}
+
+	test := suite.Tests[0]
+	if len(test.Annotations) != 1 {
+		t.Fatalf("got %d test annotations, want 1", len(test.Annotations))
+	}
+	if test.Annotations[0].Name != "rows" || test.Annotations[0].Value != 1200 {
+		t.Errorf("annotation = %+v, want rows metric", test.Annotations[0])
+	}
+	if strings.Contains(test.Error.Message, "##sentinel") {
+		t.Errorf("annotation leaked into error message: %q", test.Error.Message)
+	}
+
+	var out strings.Builder
+	NewRendererWithStyle(&out, false).RenderTestResult(test)
+	if !strings.Contains(out.String(), "rows: 1200 rows") {
+		t.Errorf("expected rendered failure details to include metric, got:\n%s", out.String())
+	}
+}
+
+func TestParser_TestCounts(t *testing.T) {
+	input := `{"Action":"start","Package":"example.com/pkg/foo"}
+{"Action":"run","Package":"example.com/pkg/foo","Test":"TestA"}
+{"Action":"run","Package":"example.com/pkg/foo","Test":"TestA/sub"}
+{"Action":"output","Package":"example.com/pkg/foo","Test":"TestA/sub","Output":"    a_test.go:9: ##sentinel[assertions] {\"value\":4}\n"}
+{"Action":"pass","Package":"example.com/pkg/foo","Test":"TestA/sub","Elapsed":0}
+{"Action":"output","Package":"example.com/pkg/foo","Test":"TestA","Output":"    a_test.go:12: ##sentinel[assertions] {\"value\":2}\n"}
+{"Action":"pass","Package":"example.com/pkg/foo","Test":"TestA","Elapsed":0}
+{"Action":"run","Package":"example.com/pkg/foo","Test":"TestB"}
+{"Action":"pass","Package":"example.com/pkg/foo","Test":"TestB","Elapsed":0}
+{"Action":"pass","Package":"example.com/pkg/foo","Elapsed":0.1}
+`
+
+	run, err := NewParser().Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	suite := run.Suites[0]
+	if got := suite.NumTestFuncs(); got != 2 {
+		t.Errorf("NumTestFuncs() = %d, want 2", got)
+	}
+	if got := suite.NumAssertions(); got != 6 {
+		t.Errorf("NumAssertions() = %d, want 6", got)
+	}
+
+	rec := NewHistoryRecord(run, TriggerRun)
+	if rec.TestFuncs != 2 || rec.Assertions != 6 {
+		t.Errorf("history record counts = %d funcs, %d assertions, want 2, 6", rec.TestFuncs, rec.Assertions)
+	}
+}
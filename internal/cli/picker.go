@@ -0,0 +1,210 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// pickerModel is a fuzzy finder over tests: typing filters the list, Tab
+// marks tests, and Enter picks the marked tests or the highlighted one
+type pickerModel struct {
+	input      textinput.Model
+	candidates []*TestCandidate
+	matches    []*TestCandidate
+	marked     map[*TestCandidate]bool
+	cursor     int
+	offset     int // First visible match
+	height     int // Visible rows of matches
+	picked     []*TestCandidate
+	cancelled  bool
+}
+
+// defaultPickerHeight is the number of matches shown before the terminal
+// size is known
+const defaultPickerHeight = 15
+
+var (
+	pickerCursorStyle = lipgloss.NewStyle().Bold(true)
+	pickerMatchStyle  = lipgloss.NewStyle().Bold(true).Underline(true)
+)
+
+// newPickerModel creates a picker over candidates
+func newPickerModel(candidates []*TestCandidate) pickerModel {
+	input := textinput.New()
+	input.Prompt = "> "
+	input.Placeholder = "type part of a test or package name"
+	input.Focus()
+	return pickerModel{
+		input:      input,
+		candidates: candidates,
+		matches:    candidates,
+		marked:     make(map[*TestCandidate]bool),
+		height:     defaultPickerHeight,
+	}
+}
+
+// Init implements tea.Model
+func (m pickerModel) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+// Update implements tea.Model
+func (m pickerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		// Leave room for the prompt, the count, and the help line
+		m.height = max(msg.Height-4, 1)
+		m.scroll()
+		return m, nil
+
+	case tea.KeyMsg:
+		switch msg.Type {
+		case tea.KeyCtrlC, tea.KeyEsc:
+			m.cancelled = true
+			return m, tea.Quit
+		case tea.KeyEnter:
+			m.picked = m.pick()
+			return m, tea.Quit
+		case tea.KeyUp, tea.KeyCtrlP:
+			m.move(-1)
+			return m, nil
+		case tea.KeyDown, tea.KeyCtrlN:
+			m.move(1)
+			return m, nil
+		case tea.KeyTab:
+			if len(m.matches) > 0 {
+				c := m.matches[m.cursor]
+				m.marked[c] = !m.marked[c]
+				m.move(1)
+			}
+			return m, nil
+		case tea.KeyCtrlA:
+			for _, c := range m.matches {
+				m.marked[c] = true
+			}
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	query := m.input.Value()
+	m.input, cmd = m.input.Update(msg)
+	if m.input.Value() != query {
+		m.matches = filterCandidates(m.candidates, m.input.Value())
+		m.cursor, m.offset = 0, 0
+	}
+	return m, cmd
+}
+
+// move moves the cursor by delta matches, keeping it visible
+func (m *pickerModel) move(delta int) {
+	m.cursor = min(max(m.cursor+delta, 0), max(len(m.matches)-1, 0))
+	m.scroll()
+}
+
+// scroll keeps the cursor within the visible rows
+func (m *pickerModel) scroll() {
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	if m.cursor >= m.offset+m.height {
+		m.offset = m.cursor - m.height + 1
+	}
+}
+
+// pick returns the marked tests in list order, or the highlighted test
+// when none are marked
+func (m pickerModel) pick() []*TestCandidate {
+	var picked []*TestCandidate
+	for _, c := range m.candidates {
+		if m.marked[c] {
+			picked = append(picked, c)
+		}
+	}
+	if len(picked) == 0 && len(m.matches) > 0 {
+		picked = append(picked, m.matches[m.cursor])
+	}
+	return picked
+}
+
+// View implements tea.Model
+func (m pickerModel) View() string {
+	if m.picked != nil || m.cancelled {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(m.input.View() + "\n")
+	b.WriteString(dimStyle.Render(fmt.Sprintf("  %d/%d tests, %d marked", len(m.matches), len(m.candidates), len(m.markedTests()))) + "\n")
+
+	end := min(m.offset+m.height, len(m.matches))
+	for i := m.offset; i < end; i++ {
+		c := m.matches[i]
+		cursor, mark := "  ", " "
+		if i == m.cursor {
+			cursor = pickerCursorStyle.Render("› ")
+		}
+		if m.marked[c] {
+			mark = successStyle.Render("●")
+		}
+		b.WriteString(cursor + mark + " " + m.highlight(c) + "\n")
+	}
+	b.WriteString(dimStyle.Render("  ↑/↓ move · Tab mark · Ctrl+A mark all · Enter run · Esc cancel"))
+	return b.String()
+}
+
+// markedTests returns the marked tests
+func (m pickerModel) markedTests() []*TestCandidate {
+	var marked []*TestCandidate
+	for c, ok := range m.marked {
+		if ok {
+			marked = append(marked, c)
+		}
+	}
+	return marked
+}
+
+// highlight renders a candidate with the characters matching the query
+// emphasized
+func (m pickerModel) highlight(c *TestCandidate) string {
+	text := c.text()
+	_, positions, _ := fuzzyMatch(m.input.Value(), text)
+	matched := make(map[int]bool, len(positions))
+	for _, p := range positions {
+		matched[p] = true
+	}
+
+	var b strings.Builder
+	for offset, r := range text {
+		s := string(r)
+		switch {
+		case matched[offset]:
+			b.WriteString(pickerMatchStyle.Render(s))
+		case offset < len(c.Package):
+			b.WriteString(dimStyle.Render(s))
+		default:
+			b.WriteString(s)
+		}
+	}
+	return b.String()
+}
+
+// PickTests lets the user choose tests with a fuzzy finder. It returns the
+// selection to run, or nil when the user cancelled.
+func PickTests(candidates []*TestCandidate) (*TestSelection, error) {
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no tests to pick from")
+	}
+	final, err := tea.NewProgram(newPickerModel(candidates)).Run()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run test picker: %w", err)
+	}
+	m := final.(pickerModel)
+	if m.cancelled || len(m.picked) == 0 {
+		return nil, nil
+	}
+	return NewTestSelection(m.picked), nil
+}
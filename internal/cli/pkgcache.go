@@ -0,0 +1,292 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// PackageCache caches the package graph reported by 'go list' so reruns do
+// not have to shell out on every invocation. Entries are keyed by the
+// contents of go.mod/go.sum and the modification times of the module's
+// directories and Go files; only packages whose files changed are relisted.
+type PackageCache struct {
+	workDir   string
+	cacheFile string // Empty when the cache is kept in memory only
+	mu        sync.Mutex
+	entries   map[string]*packageCacheEntry
+	tags      string // Build tags packages are listed with, comma-separated
+
+	// list resolves patterns into packages; replaced in tests
+	list func(workDir string, patterns []string) ([]*PackageInfo, error)
+}
+
+// packageCacheEntry holds the resolved packages for one set of patterns
+type packageCacheEntry struct {
+	ModHash  string           `json:"modHash"`
+	Mtimes   map[string]int64 `json:"mtimes"`
+	Packages []*PackageInfo   `json:"packages"`
+}
+
+// NewPackageCache creates a package cache for workDir. When cacheDir is not
+// empty the cache is persisted there and reused across invocations.
+func NewPackageCache(workDir, cacheDir string) *PackageCache {
+	c := &PackageCache{
+		workDir: workDir,
+		entries: make(map[string]*packageCacheEntry),
+		list:    expandPackagePatterns,
+	}
+	if cacheDir != "" {
+		c.cacheFile = packageCacheFile(workDir, cacheDir)
+		c.load()
+	}
+	return c
+}
+
+// packageCacheVersion changes whenever PackageInfo gains fields, so caches
+// written by older versions are not mistaken for complete ones
+const packageCacheVersion = "v3"
+
+// packageCacheFile returns where the package cache for workDir is persisted
+func packageCacheFile(workDir, cacheDir string) string {
+	sum := sha256.Sum256([]byte(workDir))
+	return filepath.Join(cacheDir, "packages-"+packageCacheVersion+"-"+hex.EncodeToString(sum[:8])+".json")
+}
+
+// defaultPackageCacheDir returns the user-level cache directory for go-sentinel
+func defaultPackageCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "go-sentinel")
+}
+
+// Get returns the packages matching patterns, using cached results when the
+// module has not changed
+func (c *PackageCache) Get(patterns []string) ([]*PackageInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.getLocked(c.tags, patterns)
+}
+
+// getWithTags is Get for packages built with tags instead of the cache's
+// own, as a run's -tags build flags select
+func (c *PackageCache) getWithTags(tags, patterns []string) ([]*PackageInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.getLocked(strings.Join(tags, ","), patterns)
+}
+
+// getLocked returns the packages matching patterns as built with tags,
+// comma-separated
+func (c *PackageCache) getLocked(tags string, patterns []string) ([]*PackageInfo, error) {
+
+	if c.entries == nil {
+		// Released while idle: read back what was persisted
+		c.entries = make(map[string]*packageCacheEntry)
+		if c.cacheFile != "" {
+			c.load()
+		}
+	}
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+	if tags != "" {
+		patterns = append([]string{"-tags=" + tags}, patterns...)
+	}
+	key := strings.Join(patterns, " ")
+	modHash := c.modHash()
+	mtimes := c.scanMtimes()
+
+	entry, ok := c.entries[key]
+	if ok && entry.ModHash == modHash {
+		changed, structural := diffMtimes(entry.Mtimes, mtimes)
+		if len(changed) == 0 {
+			return entry.Packages, nil
+		}
+		if !structural {
+			if pkgs, ok := c.refresh(tags, entry.Packages, changed); ok {
+				c.store(key, &packageCacheEntry{ModHash: modHash, Mtimes: mtimes, Packages: pkgs})
+				return pkgs, nil
+			}
+		}
+	}
+
+	pkgs, err := c.list(c.workDir, patterns)
+	if err != nil {
+		return nil, err
+	}
+	c.store(key, &packageCacheEntry{ModHash: modHash, Mtimes: mtimes, Packages: pkgs})
+	return pkgs, nil
+}
+
+// refresh relists only the packages containing changed files. It reports
+// false when a package's imports changed, since the dependency sets of its
+// importers would then be stale and a full reload is required.
+func (c *PackageCache) refresh(tags string, pkgs []*PackageInfo, changed []string) ([]*PackageInfo, bool) {
+	byDir := make(map[string]int, len(pkgs))
+	for i, pkg := range pkgs {
+		byDir[pkg.Dir] = i
+	}
+
+	dirs := make(map[string]bool)
+	for _, file := range changed {
+		dir := filepath.Dir(file)
+		if _, ok := byDir[dir]; !ok {
+			return nil, false
+		}
+		dirs[dir] = true
+	}
+
+	var patterns []string
+	if tags != "" {
+		patterns = append(patterns, "-tags="+tags)
+	}
+	for dir := range dirs {
+		patterns = append(patterns, dir)
+	}
+	updated, err := c.list(c.workDir, patterns)
+	if err != nil {
+		return nil, false
+	}
+
+	result := make([]*PackageInfo, len(pkgs))
+	copy(result, pkgs)
+	for _, pkg := range updated {
+		i, ok := byDir[pkg.Dir]
+		if !ok || !reflect.DeepEqual(pkg.Imports, pkgs[i].Imports) {
+			return nil, false
+		}
+		// Dependencies are unchanged when imports are unchanged
+		pkg.Deps = pkgs[i].Deps
+		result[i] = pkg
+	}
+	return result, true
+}
+
+// store records an entry and persists the cache if a cache file is configured
+func (c *PackageCache) store(key string, entry *packageCacheEntry) {
+	c.entries[key] = entry
+	if c.cacheFile == "" {
+		return
+	}
+
+	data, err := json.Marshal(c.entries)
+	if err != nil {
+		log.Printf("Error encoding package cache: %v", err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(c.cacheFile), 0755); err != nil {
+		log.Printf("Error creating package cache directory: %v", err)
+		return
+	}
+	if err := os.WriteFile(c.cacheFile, data, 0600); err != nil {
+		log.Printf("Error writing package cache: %v", err)
+	}
+}
+
+// SetBuildTags lists packages as built with tags, so the files behind the
+// build constraints of a profile count as their packages' files
+func (c *PackageCache) SetBuildTags(tags []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tags = strings.Join(tags, ",")
+}
+
+// release drops the package graph from memory; the next Get reads it back
+// from the persisted cache, or lists the packages again
+func (c *PackageCache) release() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = nil
+}
+
+// load reads a previously persisted cache; a missing or corrupt file is ignored
+func (c *PackageCache) load() {
+	data, err := os.ReadFile(c.cacheFile)
+	if err != nil {
+		return
+	}
+	entries := make(map[string]*packageCacheEntry)
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return
+	}
+	c.entries = entries
+}
+
+// modHash fingerprints go.mod and go.sum
+func (c *PackageCache) modHash() string {
+	h := sha256.New()
+	for _, name := range []string{"go.mod", "go.sum", "go.work"} {
+		data, err := os.ReadFile(filepath.Join(c.workDir, name))
+		if err != nil {
+			continue
+		}
+		h.Write([]byte(name))
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// scanMtimes records the modification times of directories and Go files
+// below the working directory, skipping the same directories as the watcher
+func (c *PackageCache) scanMtimes() map[string]int64 {
+	mtimes := make(map[string]int64)
+	err := filepath.WalkDir(c.workDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != c.workDir && (strings.HasPrefix(d.Name(), ".") || d.Name() == "vendor" || d.Name() == "testdata") {
+				return filepath.SkipDir
+			}
+		} else if !strings.HasSuffix(d.Name(), ".go") {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			mtimes[path] = info.ModTime().UnixNano()
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error scanning module for package cache: %v", err)
+	}
+	return mtimes
+}
+
+// diffMtimes returns the Go files whose modification time changed. It
+// reports structural changes (directories changed, files added or removed)
+// separately since they can add or remove packages.
+func diffMtimes(before, after map[string]int64) (changed []string, structural bool) {
+	if len(before) != len(after) {
+		structural = true
+	}
+	for path, mtime := range after {
+		prev, ok := before[path]
+		if !ok {
+			structural = true
+			continue
+		}
+		if prev == mtime {
+			continue
+		}
+		if !strings.HasSuffix(path, ".go") {
+			structural = true
+			continue
+		}
+		changed = append(changed, path)
+	}
+	if structural && len(changed) == 0 {
+		// Force the caller to notice the change
+		changed = append(changed, "")
+	}
+	return changed, structural
+}
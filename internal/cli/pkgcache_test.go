@@ -0,0 +1,158 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPackageCache_Get(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(rel, content string) {
+		path := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatalf("Failed to write %s: %v", rel, err)
+		}
+	}
+	writeFile("go.mod", "module example\n\ngo 1.23\n")
+	writeFile("a/a.go", "package a\n")
+
+	var calls [][]string
+	imports := map[string][]string{}
+	cache := NewPackageCache(dir, "")
+	cache.list = func(workDir string, patterns []string) ([]*PackageInfo, error) {
+		calls = append(calls, patterns)
+		var dirs []string
+		if len(patterns) == 1 && patterns[0] == "./..." {
+			entries, _ := os.ReadDir(workDir)
+			for _, e := range entries {
+				if e.IsDir() {
+					dirs = append(dirs, filepath.Join(workDir, e.Name()))
+				}
+			}
+		} else {
+			dirs = patterns
+		}
+		var pkgs []*PackageInfo
+		for _, d := range dirs {
+			pkgs = append(pkgs, &PackageInfo{
+				ImportPath: "example/" + filepath.Base(d),
+				Dir:        d,
+				Imports:    imports[filepath.Base(d)],
+			})
+		}
+		return pkgs, nil
+	}
+
+	get := func() []*PackageInfo {
+		pkgs, err := cache.Get([]string{"./..."})
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		return pkgs
+	}
+	touch := func(rel string) {
+		future := time.Now().Add(time.Duration(len(calls)+1) * time.Minute)
+		if err := os.Chtimes(filepath.Join(dir, rel), future, future); err != nil {
+			t.Fatalf("Failed to touch %s: %v", rel, err)
+		}
+	}
+
+	// First call lists the module
+	if pkgs := get(); len(pkgs) != 1 || len(calls) != 1 {
+		t.Fatalf("got %d packages after %d lists, want 1 after 1", len(pkgs), len(calls))
+	}
+
+	// Unchanged module is served from the cache
+	get()
+	if len(calls) != 1 {
+		t.Errorf("got %d lists, want cache hit", len(calls))
+	}
+
+	// Editing a file relists only its package
+	touch("a/a.go")
+	get()
+	if len(calls) != 2 || calls[1][0] != filepath.Join(dir, "a") {
+		t.Errorf("calls = %v, want incremental relist of a", calls)
+	}
+
+	// Changing imports forces a full reload
+	imports["a"] = []string{"fmt"}
+	touch("a/a.go")
+	get()
+	if len(calls) != 4 || calls[3][0] != "./..." {
+		t.Errorf("calls = %v, want full reload after import change", calls)
+	}
+
+	// Adding a package forces a full reload
+	writeFile("b/b.go", "package b\n")
+	if pkgs := get(); len(pkgs) != 2 {
+		t.Errorf("got %d packages, want 2", len(pkgs))
+	}
+
+	// Editing go.mod invalidates everything
+	before := len(calls)
+	writeFile("go.mod", "module example\n\ngo 1.24\n")
+	get()
+	if len(calls) != before+1 || calls[len(calls)-1][0] != "./..." {
+		t.Errorf("calls = %v, want full reload after go.mod change", calls)
+	}
+}
+
+func TestPackageCache_Persistence(t *testing.T) {
+	dir := t.TempDir()
+	cacheDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example\n"), 0600); err != nil {
+		t.Fatalf("Failed to write go.mod: %v", err)
+	}
+
+	calls := 0
+	list := func(workDir string, patterns []string) ([]*PackageInfo, error) {
+		calls++
+		return []*PackageInfo{{ImportPath: "example", Dir: workDir}}, nil
+	}
+
+	first := NewPackageCache(dir, cacheDir)
+	first.list = list
+	if _, err := first.Get(nil); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+
+	second := NewPackageCache(dir, cacheDir)
+	second.list = list
+	pkgs, err := second.Get(nil)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("got %d lists, want persisted cache to be reused", calls)
+	}
+	if len(pkgs) != 1 || pkgs[0].ImportPath != "example" {
+		t.Errorf("got %v, want persisted example package", pkgs)
+	}
+}
+
+func TestPackageCache_BuildTags(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example\n\ngo 1.23\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var calls [][]string
+	cache := NewPackageCache(dir, "")
+	cache.list = func(workDir string, patterns []string) ([]*PackageInfo, error) {
+		calls = append(calls, patterns)
+		return []*PackageInfo{{ImportPath: "example", Dir: workDir}}, nil
+	}
+
+	cache.Get(nil)
+	cache.SetBuildTags([]string{"integration", "e2e"})
+	cache.Get(nil)
+	cache.Get(nil)
+	if len(calls) != 2 || len(calls[1]) != 2 || calls[1][0] != "-tags=integration,e2e" || calls[1][1] != "./..." {
+		t.Errorf("calls = %v, want the module listed again with the tags, then served from the cache", calls)
+	}
+}
@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// PorcelainVersion is the latest porcelain format version. The fields of
+// a version never change; new fields or records get a new version, and
+// old versions keep being written on request.
+//
+// Porcelain v1 is line-oriented and tab-separated. Each run is written as
+// a header line, one record per test, one per package, and a closing run
+// record:
+//
+//	# go-sentinel porcelain v1
+//	test <status> <package> <test> <seconds>
+//	pkg <status> <package> <tests> <passed> <failed> <skipped> <seconds>
+//	run <status> <tests> <passed> <failed> <skipped> <seconds>
+//
+// Status is pass, fail, or skip. A test that never finished, as when its
+// binary panicked or timed out, is a fail; a package is a fail when any of
+// its tests failed or it failed outside any test, and a skip when it has
+// no tests. Seconds have millisecond precision. Subtests are named as in
+// go test, parent/child. Lines starting with # are comments, and readers
+// should ignore records they do not know.
+const PorcelainVersion = "v1"
+
+// ValidPorcelainVersion reports whether a porcelain format version can be
+// written
+func ValidPorcelainVersion(version string) bool {
+	return version == PorcelainVersion
+}
+
+// WritePorcelain writes run in the porcelain format of version, for
+// scripts that must not break when the human-readable output changes
+func WritePorcelain(w io.Writer, run *TestRun, version string) error {
+	if !ValidPorcelainVersion(version) {
+		return fmt.Errorf("unknown porcelain version %q, want %s", version, PorcelainVersion)
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# go-sentinel porcelain %s\n", version)
+
+	var total porcelainCounts
+	for _, suite := range run.Suites {
+		pkg := porcelainName(suiteName(suite))
+		var counts porcelainCounts
+		for _, test := range suite.Tests {
+			status := porcelainStatus(test.Status)
+			counts.add(status)
+			writePorcelainRecord(bw, "test", status, pkg, porcelainName(test.Name), porcelainSeconds(test.Duration))
+		}
+		status := counts.status()
+		if status != "fail" && (suite.SetupFailed || len(suite.Errors) > 0) {
+			status = "fail"
+		}
+		if status == "fail" {
+			total.failedPackages++
+		}
+		total.merge(counts)
+		writePorcelainRecord(bw, "pkg", status, pkg, counts.fields(), porcelainSeconds(suite.Duration))
+	}
+
+	status := total.status()
+	if total.failedPackages > 0 {
+		status = "fail"
+	}
+	writePorcelainRecord(bw, "run", status, total.fields(), porcelainSeconds(run.Duration))
+	return bw.Flush()
+}
+
+// porcelainCounts tallies test statuses for pkg and run records
+type porcelainCounts struct {
+	tests, passed, failed, skipped int
+	failedPackages                 int
+}
+
+func (c *porcelainCounts) add(status string) {
+	c.tests++
+	switch status {
+	case "pass":
+		c.passed++
+	case "fail":
+		c.failed++
+	case "skip":
+		c.skipped++
+	}
+}
+
+func (c *porcelainCounts) merge(o porcelainCounts) {
+	c.tests += o.tests
+	c.passed += o.passed
+	c.failed += o.failed
+	c.skipped += o.skipped
+}
+
+// status returns fail when any test failed, pass when any passed, and skip
+// otherwise
+func (c *porcelainCounts) status() string {
+	switch {
+	case c.failed > 0:
+		return "fail"
+	case c.passed > 0:
+		return "pass"
+	}
+	return "skip"
+}
+
+// fields returns the tests, passed, failed, and skipped fields
+func (c *porcelainCounts) fields() string {
+	return fmt.Sprintf("%d\t%d\t%d\t%d", c.tests, c.passed, c.failed, c.skipped)
+}
+
+// porcelainStatus maps a test status to its porcelain name
+func porcelainStatus(s TestStatus) string {
+	switch s {
+	case TestStatusPassed:
+		return "pass"
+	case TestStatusSkipped:
+		return "skip"
+	}
+	return "fail"
+}
+
+// porcelainSeconds formats a duration in seconds with millisecond precision
+func porcelainSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// writePorcelainRecord writes one tab-separated record
+func writePorcelainRecord(w io.Writer, fields ...string) {
+	fmt.Fprintln(w, strings.Join(fields, "\t"))
+}
+
+// porcelainName returns a package or test name as a single field. Go names
+// cannot hold tabs or newlines, but replacing them keeps every record on
+// one line with a fixed number of fields regardless.
+func porcelainName(name string) string {
+	return strings.NewReplacer("\t", " ", "\n", " ").Replace(name)
+}
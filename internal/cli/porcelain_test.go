@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestWritePorcelain(t *testing.T) {
+	run := &TestRun{
+		Duration: 1500 * time.Millisecond,
+		Suites: []*TestSuite{
+			{
+				Package:  "example.com/api",
+				Duration: 1200 * time.Millisecond,
+				Tests: []*TestResult{
+					{Name: "TestGet", Status: TestStatusPassed, Duration: 250 * time.Millisecond},
+					{Name: "TestGet/missing", Status: TestStatusFailed, Duration: 1234567 * time.Microsecond},
+					{Name: "TestSlow", Status: TestStatusSkipped},
+					{Name: "TestHang", Status: TestStatusRunning},
+				},
+			},
+			{Package: "example.com/db", SetupFailed: true, Output: "panic: connection refused\n"},
+			{Package: "example.com/empty"},
+		},
+	}
+
+	// The v1 format must not change; this is the contract scripts rely on
+	want := "# go-sentinel porcelain v1\n" +
+		"test\tpass\texample.com/api\tTestGet\t0.250\n" +
+		"test\tfail\texample.com/api\tTestGet/missing\t1.235\n" +
+		"test\tskip\texample.com/api\tTestSlow\t0.000\n" +
+		"test\tfail\texample.com/api\tTestHang\t0.000\n" +
+		"pkg\tfail\texample.com/api\t4\t1\t2\t1\t1.200\n" +
+		"pkg\tfail\texample.com/db\t0\t0\t0\t0\t0.000\n" +
+		"pkg\tskip\texample.com/empty\t0\t0\t0\t0\t0.000\n" +
+		"run\tfail\t4\t1\t2\t1\t1.500\n"
+
+	var buf bytes.Buffer
+	if err := WritePorcelain(&buf, run, PorcelainVersion); err != nil {
+		t.Fatalf("WritePorcelain() error = %v", err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("WritePorcelain() =\n%s\nwant\n%s", got, want)
+	}
+
+	if err := WritePorcelain(&buf, run, "v2"); err == nil {
+		t.Error("WritePorcelain() with an unknown version succeeded")
+	}
+}
+
+func TestWritePorcelain_SetupFailureFailsRun(t *testing.T) {
+	run := &TestRun{Suites: []*TestSuite{
+		{Package: "example.com/ok", Tests: []*TestResult{{Name: "TestA", Status: TestStatusPassed}}},
+		{Package: "example.com/db", SetupFailed: true},
+	}}
+	var buf bytes.Buffer
+	if err := WritePorcelain(&buf, run, PorcelainVersion); err != nil {
+		t.Fatalf("WritePorcelain() error = %v", err)
+	}
+	if !bytes.HasSuffix(buf.Bytes(), []byte("run\tfail\t1\t1\t0\t0\t0.000\n")) {
+		t.Errorf("WritePorcelain() = %q, want a failed run record", buf.String())
+	}
+}
@@ -0,0 +1,126 @@
+package cli
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// PowerSource is what the machine is currently running on
+type PowerSource int
+
+// Power sources
+const (
+	PowerUnknown PowerSource = iota // No battery, or the source cannot be determined
+	PowerAC
+	PowerBattery
+)
+
+// String returns a readable name for the power source
+func (s PowerSource) String() string {
+	switch s {
+	case PowerAC:
+		return "AC power"
+	case PowerBattery:
+		return "battery"
+	}
+	return "unknown"
+}
+
+// PowerPolicy throttles watch mode while the machine runs on battery and
+// restores full speed when it is plugged in again
+type PowerPolicy struct {
+	Interval    time.Duration // How often the power source is checked
+	Parallelism int           // Packages tested at once on battery
+	Debounce    time.Duration // Quiet period after a change before rerunning on battery
+
+	// detect reports the current power source; replaced in tests
+	detect func() PowerSource
+}
+
+// DefaultPowerPolicy returns the default battery policy: half the CPUs and
+// a two second debounce
+func DefaultPowerPolicy() *PowerPolicy {
+	return &PowerPolicy{
+		Interval:    30 * time.Second,
+		Parallelism: max(1, runtime.NumCPU()/2),
+		Debounce:    2 * time.Second,
+		detect:      DetectPowerSource,
+	}
+}
+
+// throttle returns the parallelism and debounce to use on source, given the
+// unthrottled values
+func (p *PowerPolicy) throttle(source PowerSource, parallelism int, debounce time.Duration) (int, time.Duration) {
+	if source != PowerBattery {
+		return parallelism, debounce
+	}
+	if parallelism == 0 || p.Parallelism < parallelism {
+		parallelism = p.Parallelism
+	}
+	return parallelism, max(debounce, p.Debounce)
+}
+
+// DetectPowerSource reports whether the machine runs on battery
+func DetectPowerSource() PowerSource {
+	switch runtime.GOOS {
+	case "linux":
+		return powerSourceFromSysfs("/sys/class/power_supply")
+	case "darwin":
+		out, err := exec.Command("pmset", "-g", "batt").Output()
+		if err != nil {
+			return PowerUnknown
+		}
+		return powerSourceFromPmset(string(out))
+	}
+	return platformPowerSource()
+}
+
+// powerSourceFromSysfs reads the Linux power supply class directory
+func powerSourceFromSysfs(dir string) PowerSource {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return PowerUnknown
+	}
+
+	read := func(supply, name string) string {
+		data, _ := os.ReadFile(filepath.Join(dir, supply, name))
+		return strings.TrimSpace(string(data))
+	}
+	hasBattery, online := false, false
+	for _, entry := range entries {
+		switch read(entry.Name(), "type") {
+		case "Battery":
+			if read(entry.Name(), "scope") == "Device" {
+				// Batteries of mice and keyboards say nothing about the machine
+				continue
+			}
+			hasBattery = true
+			if read(entry.Name(), "status") == "Discharging" {
+				return PowerBattery
+			}
+		case "Mains", "USB", "USB_C":
+			if read(entry.Name(), "online") == "1" {
+				online = true
+			}
+		}
+	}
+	if online || hasBattery {
+		return PowerAC
+	}
+	return PowerUnknown
+}
+
+// powerSourceFromPmset parses the output of 'pmset -g batt'
+func powerSourceFromPmset(out string) PowerSource {
+	switch {
+	case strings.Contains(out, "'Battery Power'"):
+		return PowerBattery
+	case strings.Contains(out, "'AC Power'"):
+		return PowerAC
+	}
+	return PowerUnknown
+}
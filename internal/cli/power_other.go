@@ -0,0 +1,9 @@
+//go:build !windows
+
+package cli
+
+// platformPowerSource is only needed on Windows; Linux and macOS are
+// handled without system calls
+func platformPowerSource() PowerSource {
+	return PowerUnknown
+}
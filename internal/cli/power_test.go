@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPowerSourceFromSysfs(t *testing.T) {
+	write := func(dir, supply string, files map[string]string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Join(dir, supply), 0755); err != nil {
+			t.Fatalf("Failed to create supply: %v", err)
+		}
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(dir, supply, name), []byte(content+"\n"), 0644); err != nil {
+				t.Fatalf("Failed to write %s: %v", name, err)
+			}
+		}
+	}
+
+	tests := []struct {
+		name     string
+		supplies map[string]map[string]string
+		want     PowerSource
+	}{
+		{"desktop", nil, PowerUnknown},
+		{"discharging", map[string]map[string]string{
+			"AC":   {"type": "Mains", "online": "0"},
+			"BAT0": {"type": "Battery", "status": "Discharging"},
+		}, PowerBattery},
+		{"charging", map[string]map[string]string{
+			"AC":   {"type": "Mains", "online": "1"},
+			"BAT0": {"type": "Battery", "status": "Charging"},
+		}, PowerAC},
+		{"mouse battery only", map[string]map[string]string{
+			"hid-mouse": {"type": "Battery", "scope": "Device", "status": "Discharging"},
+		}, PowerUnknown},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		for supply, files := range tt.supplies {
+			write(dir, supply, files)
+		}
+		if got := powerSourceFromSysfs(dir); got != tt.want {
+			t.Errorf("%s: powerSourceFromSysfs = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPowerSourceFromPmset(t *testing.T) {
+	if got := powerSourceFromPmset("Now drawing from 'Battery Power'\n -InternalBattery-0 85%; discharging"); got != PowerBattery {
+		t.Errorf("battery output = %v, want battery", got)
+	}
+	if got := powerSourceFromPmset("Now drawing from 'AC Power'\n"); got != PowerAC {
+		t.Errorf("AC output = %v, want AC power", got)
+	}
+}
+
+func TestPowerPolicy_Throttle(t *testing.T) {
+	policy := &PowerPolicy{Parallelism: 2, Debounce: 2 * time.Second}
+
+	if p, d := policy.throttle(PowerAC, 0, 0); p != 0 || d != 0 {
+		t.Errorf("On AC = %d, %v, want unthrottled", p, d)
+	}
+	if p, d := policy.throttle(PowerBattery, 0, 0); p != 2 || d != 2*time.Second {
+		t.Errorf("On battery = %d, %v, want 2, 2s", p, d)
+	}
+	if p, _ := policy.throttle(PowerBattery, 1, 0); p != 1 {
+		t.Errorf("On battery with lower configured parallelism = %d, want 1", p)
+	}
+}
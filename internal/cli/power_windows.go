@@ -0,0 +1,38 @@
+//go:build windows
+
+package cli
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var procGetSystemPowerStatus = syscall.NewLazyDLL("kernel32.dll").NewProc("GetSystemPowerStatus")
+
+// systemPowerStatus mirrors the Win32 SYSTEM_POWER_STATUS structure
+type systemPowerStatus struct {
+	ACLineStatus        byte
+	BatteryFlag         byte
+	BatteryLifePercent  byte
+	SystemStatusFlag    byte
+	BatteryLifeTime     uint32
+	BatteryFullLifeTime uint32
+}
+
+// platformPowerSource asks Windows for the AC line status
+func platformPowerSource() PowerSource {
+	var status systemPowerStatus
+	if r, _, _ := procGetSystemPowerStatus.Call(uintptr(unsafe.Pointer(&status))); r == 0 {
+		return PowerUnknown
+	}
+	const noBattery = 128
+	switch {
+	case status.BatteryFlag&noBattery != 0:
+		return PowerUnknown
+	case status.ACLineStatus == 0:
+		return PowerBattery
+	case status.ACLineStatus == 1:
+		return PowerAC
+	}
+	return PowerUnknown
+}
@@ -0,0 +1,301 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RunComparison describes how a pull request's run differs from a run of
+// its base branch
+type RunComparison struct {
+	Base, Head *HistoryRecord
+
+	NewFailures     []*TestChange // Failing in head but not in base
+	Fixed           []*TestChange // Failing in base and passing in head
+	SlowRegressions []*TestChange // Passing in both and markedly slower in head
+
+	Coverage *CoverageDelta // Total statement coverage, without a file; nil when not compared
+}
+
+// TestChange is a test whose outcome or duration differs between two runs
+type TestChange struct {
+	Package      string
+	Test         string
+	BaseDuration time.Duration
+	HeadDuration time.Duration
+	Failure      string // First line of the head failure, for new failures
+}
+
+// SlowRegression sets when a test counts as slower than on the base branch
+type SlowRegression struct {
+	Factor      float64       // Minimum ratio of head to base duration
+	MinIncrease time.Duration // Minimum absolute increase, so fast tests do not flap
+}
+
+// DefaultSlowRegression returns the default slow-test regression policy
+func DefaultSlowRegression() SlowRegression {
+	return SlowRegression{Factor: 1.5, MinIncrease: 100 * time.Millisecond}
+}
+
+// CompareRuns compares the top-level tests of a head run with those of a
+// base run
+func CompareRuns(base, head *HistoryRecord, slow SlowRegression) *RunComparison {
+	c := &RunComparison{Base: base, Head: head}
+
+	type key struct{ pkg, test string }
+	baseTests := make(map[key]*TestRecord)
+	for _, pkg := range base.Packages {
+		for _, test := range pkg.Tests {
+			if topLevelTest(test.Name) == test.Name {
+				baseTests[key{pkg.Package, test.Name}] = test
+			}
+		}
+	}
+
+	for _, pkg := range head.Packages {
+		for _, test := range pkg.Tests {
+			if topLevelTest(test.Name) != test.Name {
+				continue
+			}
+			change := &TestChange{Package: pkg.Package, Test: test.Name, HeadDuration: test.Duration}
+			prev := baseTests[key{pkg.Package, test.Name}]
+			if prev != nil {
+				change.BaseDuration = prev.Duration
+			}
+			switch {
+			case test.Status == TestStatusFailed && (prev == nil || prev.Status != TestStatusFailed):
+				change.Failure = test.Failure
+				c.NewFailures = append(c.NewFailures, change)
+			case test.Status == TestStatusPassed && prev != nil && prev.Status == TestStatusFailed:
+				c.Fixed = append(c.Fixed, change)
+			case test.Status == TestStatusPassed && prev != nil && prev.Status == TestStatusPassed &&
+				prev.Duration > 0 &&
+				float64(test.Duration) >= slow.Factor*float64(prev.Duration) &&
+				test.Duration-prev.Duration >= slow.MinIncrease:
+				c.SlowRegressions = append(c.SlowRegressions, change)
+			}
+		}
+	}
+
+	sort.Slice(c.SlowRegressions, func(i, j int) bool {
+		a, b := c.SlowRegressions[i], c.SlowRegressions[j]
+		return a.HeadDuration-a.BaseDuration > b.HeadDuration-b.BaseDuration
+	})
+	return c
+}
+
+// CompareCoverage sets the coverage delta from the reports of both runs
+func (c *RunComparison) CompareCoverage(base, head *CoverageReport) {
+	baseCovered, baseTotal := base.Total()
+	headCovered, headTotal := head.Total()
+	c.Coverage = &CoverageDelta{
+		Before:    coveragePercent(baseCovered, baseTotal),
+		After:     coveragePercent(headCovered, headTotal),
+		HasBefore: true,
+	}
+}
+
+// prCommentMarker identifies the comment go-sentinel keeps updated
+const prCommentMarker = "<!-- go-sentinel:pr-comment -->"
+
+// maxCommentRows caps each table, keeping the comment readable
+const maxCommentRows = 20
+
+// Markdown renders the comparison as a pull request comment
+func (c *RunComparison) Markdown() string {
+	var b strings.Builder
+	b.WriteString(prCommentMarker + "\n")
+	status := "✅"
+	if c.Head.NumFailed > 0 {
+		status = "❌"
+	}
+	fmt.Fprintf(&b, "### %s go-sentinel: %d passed, %d failed, %d skipped\n\n",
+		status, c.Head.NumPassed, c.Head.NumFailed, c.Head.NumSkipped)
+
+	base := c.Base.ID
+	if c.Base.Branch != "" {
+		base = fmt.Sprintf("`%s` (%s)", c.Base.Branch, c.Base.ID)
+	}
+	fmt.Fprintf(&b, "Compared with %s.\n\n", base)
+
+	b.WriteString("| | |\n|---|---|\n")
+	fmt.Fprintf(&b, "| New failures | %d |\n", len(c.NewFailures))
+	fmt.Fprintf(&b, "| Fixed tests | %d |\n", len(c.Fixed))
+	fmt.Fprintf(&b, "| Slow-test regressions | %d |\n", len(c.SlowRegressions))
+	if c.Coverage != nil {
+		fmt.Fprintf(&b, "| Coverage | %.1f%% (%+.1f%%) |\n", c.Coverage.After, c.Coverage.After-c.Coverage.Before)
+	}
+	fmt.Fprintf(&b, "| Duration | %s |\n", FormatDurationAdaptive(c.Head.Duration))
+
+	if len(c.NewFailures) > 0 {
+		b.WriteString("\n#### New failures\n\n| Test | Package | Failure |\n|---|---|---|\n")
+		for i, t := range c.NewFailures {
+			if i == maxCommentRows {
+				fmt.Fprintf(&b, "\n…and %d more\n", len(c.NewFailures)-i)
+				break
+			}
+			fmt.Fprintf(&b, "| `%s` | `%s` | %s |\n", t.Test, t.Package, markdownCell(t.Failure))
+		}
+	}
+	if len(c.Fixed) > 0 {
+		b.WriteString("\n#### Fixed\n\n| Test | Package |\n|---|---|\n")
+		for i, t := range c.Fixed {
+			if i == maxCommentRows {
+				fmt.Fprintf(&b, "\n…and %d more\n", len(c.Fixed)-i)
+				break
+			}
+			fmt.Fprintf(&b, "| `%s` | `%s` |\n", t.Test, t.Package)
+		}
+	}
+	if len(c.SlowRegressions) > 0 {
+		b.WriteString("\n#### Slower tests\n\n| Test | Package | Base | Head |\n|---|---|---|---|\n")
+		for i, t := range c.SlowRegressions {
+			if i == maxCommentRows {
+				fmt.Fprintf(&b, "\n…and %d more\n", len(c.SlowRegressions)-i)
+				break
+			}
+			fmt.Fprintf(&b, "| `%s` | `%s` | %s | %s |\n", t.Test, t.Package,
+				FormatDurationAdaptive(t.BaseDuration), FormatDurationAdaptive(t.HeadDuration))
+		}
+	}
+	return b.String()
+}
+
+// markdownCell makes text safe to place in a table cell
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// GitHubClient posts pull request comments through the GitHub REST API
+type GitHubClient struct {
+	API    string // API root, e.g. https://api.github.com
+	Token  string
+	Repo   string // owner/name
+	Client *http.Client
+}
+
+// NewGitHubClient returns a client for repo, honoring GITHUB_API_URL for
+// GitHub Enterprise
+func NewGitHubClient(repo, token string) *GitHubClient {
+	api := os.Getenv("GITHUB_API_URL")
+	if api == "" {
+		api = "https://api.github.com"
+	}
+	return &GitHubClient{API: strings.TrimSuffix(api, "/"), Token: token, Repo: repo, Client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// githubComment is the part of a GitHub issue comment used here
+type githubComment struct {
+	ID      int64      `json:"id"`
+	Body    string     `json:"body"`
+	HTMLURL string     `json:"html_url"`
+	User    githubUser `json:"user"`
+}
+
+type githubUser struct {
+	Login string `json:"login"`
+}
+
+// githubActionsBot writes the comments made with a GitHub Actions
+// workflow's GITHUB_TOKEN, which cannot look itself up through /user
+const githubActionsBot = "github-actions[bot]"
+
+// UpsertComment updates the go-sentinel comment on a pull request, or
+// creates it when there is none. It returns the comment's URL and whether
+// it was created.
+func (g *GitHubClient) UpsertComment(pr int, body string) (string, bool, error) {
+	existing, err := g.findComment(pr)
+	if err != nil {
+		return "", false, err
+	}
+	var comment githubComment
+	if existing != nil {
+		err = g.do(http.MethodPatch, fmt.Sprintf("/repos/%s/issues/comments/%d", g.Repo, existing.ID), map[string]string{"body": body}, &comment)
+	} else {
+		err = g.do(http.MethodPost, fmt.Sprintf("/repos/%s/issues/%d/comments", g.Repo, pr), map[string]string{"body": body}, &comment)
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return comment.HTMLURL, existing == nil, nil
+}
+
+// login returns the login of the user the token authenticates as
+func (g *GitHubClient) login() (string, error) {
+	var user githubUser
+	if err := g.do(http.MethodGet, "/user", nil, &user); err != nil {
+		if os.Getenv("GITHUB_ACTIONS") == "true" {
+			return githubActionsBot, nil
+		}
+		return "", err
+	}
+	return user.Login, nil
+}
+
+// findComment returns the comment carrying the go-sentinel marker written
+// by the authenticated user, or nil. Markers quoted or pasted by others are
+// left alone.
+func (g *GitHubClient) findComment(pr int) (*githubComment, error) {
+	login, err := g.login()
+	if err != nil {
+		return nil, err
+	}
+	for page := 1; ; page++ {
+		var comments []*githubComment
+		path := fmt.Sprintf("/repos/%s/issues/%d/comments?per_page=100&page=%d", g.Repo, pr, page)
+		if err := g.do(http.MethodGet, path, nil, &comments); err != nil {
+			return nil, err
+		}
+		for _, c := range comments {
+			if c.User.Login == login && strings.Contains(c.Body, prCommentMarker) {
+				return c, nil
+			}
+		}
+		if len(comments) < 100 {
+			return nil, nil
+		}
+	}
+}
+
+// do sends an API request and decodes the response into out
+func (g *GitHubClient) do(method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, g.API+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Content-Type", "application/json")
+	if g.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+g.Token)
+	}
+
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach GitHub: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("GitHub returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode GitHub response: %w", err)
+	}
+	return nil
+}
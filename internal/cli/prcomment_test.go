@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompareRuns(t *testing.T) {
+	base := &HistoryRecord{ID: "base", Branch: "main", Packages: []*PackageRecord{{
+		Package: "example.com/calc",
+		Tests: []*TestRecord{
+			{Name: "TestAdd", Status: TestStatusPassed, Duration: 100 * time.Millisecond},
+			{Name: "TestSub", Status: TestStatusFailed},
+			{Name: "TestMul", Status: TestStatusPassed, Duration: 200 * time.Millisecond},
+			{Name: "TestDiv", Status: TestStatusPassed, Duration: 10 * time.Millisecond},
+		},
+	}}}
+	head := &HistoryRecord{ID: "head", NumPassed: 3, NumFailed: 2, Packages: []*PackageRecord{{
+		Package: "example.com/calc",
+		Tests: []*TestRecord{
+			{Name: "TestAdd", Status: TestStatusFailed, Failure: "calc_test.go:9: got 3 | want 4"},
+			{Name: "TestAdd/negative", Status: TestStatusFailed},
+			{Name: "TestSub", Status: TestStatusPassed},
+			{Name: "TestMul", Status: TestStatusPassed, Duration: 500 * time.Millisecond},
+			{Name: "TestDiv", Status: TestStatusPassed, Duration: 50 * time.Millisecond},
+		},
+	}}}
+
+	c := CompareRuns(base, head, DefaultSlowRegression())
+	if len(c.NewFailures) != 1 || c.NewFailures[0].Test != "TestAdd" {
+		t.Errorf("NewFailures = %+v, want TestAdd only", c.NewFailures)
+	}
+	if len(c.Fixed) != 1 || c.Fixed[0].Test != "TestSub" {
+		t.Errorf("Fixed = %+v, want TestSub", c.Fixed)
+	}
+	// TestDiv is 5x slower but only by 40ms
+	if len(c.SlowRegressions) != 1 || c.SlowRegressions[0].Test != "TestMul" {
+		t.Errorf("SlowRegressions = %+v, want TestMul", c.SlowRegressions)
+	}
+
+	body := c.Markdown()
+	for _, want := range []string{prCommentMarker, "`main` (base)", "| New failures | 1 |", "got 3 \\| want 4", "#### Slower tests"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("comment missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestGitHubClient_UpsertComment(t *testing.T) {
+	t.Setenv("GITHUB_ACTIONS", "")
+	var posted, patched int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/user":
+			json.NewEncoder(w).Encode(githubUser{Login: "sentinel-bot"})
+		case r.Method == http.MethodGet && r.URL.Path == "/repos/o/r/issues/7/comments":
+			comments := []githubComment{
+				{ID: 1, Body: "LGTM", User: githubUser{Login: "alice"}},
+				{ID: 3, Body: "> " + prCommentMarker + "\nquoted", User: githubUser{Login: "alice"}},
+			}
+			if posted > 0 {
+				comments = append(comments, githubComment{ID: 2, Body: prCommentMarker + "\nold", User: githubUser{Login: "sentinel-bot"}})
+			}
+			json.NewEncoder(w).Encode(comments)
+		case r.Method == http.MethodPost && r.URL.Path == "/repos/o/r/issues/7/comments":
+			posted++
+			json.NewEncoder(w).Encode(githubComment{ID: 2, HTMLURL: "https://github.com/o/r/pull/7#c2"})
+		case r.Method == http.MethodPatch && r.URL.Path == "/repos/o/r/issues/comments/2":
+			patched++
+			json.NewEncoder(w).Encode(githubComment{ID: 2, HTMLURL: "https://github.com/o/r/pull/7#c2"})
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	g := &GitHubClient{API: server.URL, Token: "secret", Repo: "o/r", Client: server.Client()}
+	if _, created, err := g.UpsertComment(7, prCommentMarker+"\nnew"); err != nil || !created {
+		t.Fatalf("first UpsertComment: created = %v, err = %v", created, err)
+	}
+	if url, created, err := g.UpsertComment(7, prCommentMarker+"\nnewer"); err != nil || created || url == "" {
+		t.Fatalf("second UpsertComment: url = %q, created = %v, err = %v", url, created, err)
+	}
+	if posted != 1 || patched != 1 {
+		t.Errorf("posted %d, patched %d, want one of each", posted, patched)
+	}
+}
+
+func TestGitHubClient_Login(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"Resource not accessible by integration"}`, http.StatusForbidden)
+	}))
+	defer server.Close()
+	g := &GitHubClient{API: server.URL, Token: "ghs_token", Repo: "o/r", Client: server.Client()}
+
+	t.Setenv("GITHUB_ACTIONS", "true")
+	if login, err := g.login(); err != nil || login != githubActionsBot {
+		t.Errorf("login() in GitHub Actions = %q, %v, want %q", login, err, githubActionsBot)
+	}
+	t.Setenv("GITHUB_ACTIONS", "")
+	if _, err := g.login(); err == nil {
+		t.Error("Expected error when the token cannot look up its user outside GitHub Actions")
+	}
+}
@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ProcessPriority lowers the priority of test processes so long runs leave
+// the machine usable. Settings are inherited by everything go test starts.
+type ProcessPriority struct {
+	Nice   int   // Niceness from 1 (slightly lower) to 19 (lowest); on Windows 1-9 is below normal, 10-19 idle
+	IdleIO bool  // Only use the disk when it is otherwise idle (Linux)
+	CPUs   []int // CPUs the processes may run on, empty for all
+}
+
+// ParseCPUList parses a CPU list such as "0-3,6"
+func ParseCPUList(s string) ([]int, error) {
+	seen := make(map[int]bool)
+	var cpus []int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(part, "-")
+		first, err := strconv.Atoi(lo)
+		if err != nil || first < 0 {
+			return nil, fmt.Errorf("invalid CPU %q", part)
+		}
+		last := first
+		if isRange {
+			if last, err = strconv.Atoi(hi); err != nil || last < first {
+				return nil, fmt.Errorf("invalid CPU range %q", part)
+			}
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			if !seen[cpu] {
+				seen[cpu] = true
+				cpus = append(cpus, cpu)
+			}
+		}
+	}
+	sort.Ints(cpus)
+	return cpus, nil
+}
+
+// formatCPUList formats CPUs as a comma-separated list
+func formatCPUList(cpus []int) string {
+	parts := make([]string, len(cpus))
+	for i, cpu := range cpus {
+		parts[i] = strconv.Itoa(cpu)
+	}
+	return strings.Join(parts, ",")
+}
+
+// startCommand starts cmd with the priority settings applied. A nil
+// priority starts cmd unchanged.
+func startCommand(cmd *exec.Cmd, priority *ProcessPriority) error {
+	if priority != nil {
+		priority.prepare(cmd)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	if priority != nil {
+		if err := priority.started(cmd); err != nil {
+			log.Printf("Error applying process priority: %v", err)
+		}
+	}
+	return nil
+}
+
+// combinedOutput runs cmd like cmd.CombinedOutput with the priority
+// settings applied, also copying the output to tee when it is not nil
+func combinedOutput(cmd *exec.Cmd, priority *ProcessPriority, tee io.Writer) ([]byte, error) {
+	var buf bytes.Buffer
+	// A single writer for both streams keeps exec from writing concurrently
+	var w io.Writer = &buf
+	if tee != nil {
+		w = io.MultiWriter(&buf, tee)
+	}
+	cmd.Stdout = w
+	cmd.Stderr = w
+
+	if err := startCommand(cmd, priority); err != nil {
+		return nil, err
+	}
+	err := cmd.Wait()
+	return buf.Bytes(), err
+}
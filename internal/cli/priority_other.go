@@ -0,0 +1,13 @@
+//go:build !unix && !windows
+
+package cli
+
+import "os/exec"
+
+// prepare is a no-op; process priority is not supported on this platform
+func (p *ProcessPriority) prepare(cmd *exec.Cmd) {}
+
+// started is a no-op; process priority is not supported on this platform
+func (p *ProcessPriority) started(cmd *exec.Cmd) error {
+	return nil
+}
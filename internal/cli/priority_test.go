@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"os/exec"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseCPUList(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    []int
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"0-3,6", []int{0, 1, 2, 3, 6}, false},
+		{"6, 2-3, 3", []int{2, 3, 6}, false},
+		{"3-1", nil, true},
+		{"a", nil, true},
+		{"-1", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseCPUList(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseCPUList(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseCPUList(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestProcessPriority_Nice(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows uses priority classes instead of nice")
+	}
+	if _, err := exec.LookPath("nice"); err != nil {
+		t.Skip("nice is not installed")
+	}
+
+	base, err := exec.Command("nice").Output()
+	if err != nil {
+		t.Fatalf("Failed to read niceness: %v", err)
+	}
+	baseNice, _ := strconv.Atoi(strings.TrimSpace(string(base)))
+
+	cmd := exec.Command("sh", "-c", "nice")
+	output, err := combinedOutput(cmd, &ProcessPriority{Nice: 7}, nil)
+	if err != nil {
+		t.Fatalf("Failed to run command: %v\n%s", err, output)
+	}
+	want := min(baseNice+7, 19)
+	if got, _ := strconv.Atoi(strings.TrimSpace(string(output))); got != want {
+		t.Errorf("Niceness of the test process = %d, want %d", got, want)
+	}
+}
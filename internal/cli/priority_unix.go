@@ -0,0 +1,59 @@
+//go:build unix
+
+package cli
+
+import (
+	"log"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+// warnOnce avoids repeating the same warning on every watch run
+var warnOnce sync.Map
+
+// prepare wraps cmd with nice, ionice, and taskset, so the settings apply
+// from the first instruction and to every process go test starts
+func (p *ProcessPriority) prepare(cmd *exec.Cmd) {
+	var prefix []string
+	if len(p.CPUs) > 0 {
+		prefix = p.wrapper(prefix, runtime.GOOS == "linux", "taskset", "-c", formatCPUList(p.CPUs))
+	}
+	if p.IdleIO {
+		prefix = p.wrapper(prefix, runtime.GOOS == "linux", "ionice", "-c", "3")
+	}
+	if p.Nice > 0 {
+		prefix = p.wrapper(prefix, true, "nice", "-n", strconv.Itoa(p.Nice))
+	}
+	if len(prefix) == 0 {
+		return
+	}
+
+	path, err := exec.LookPath(prefix[0])
+	if err != nil {
+		return
+	}
+	cmd.Args = append(prefix, cmd.Args...)
+	cmd.Path = path
+}
+
+// wrapper appends a wrapper command to prefix when it is supported and
+// installed, and warns once otherwise
+func (p *ProcessPriority) wrapper(prefix []string, supported bool, args ...string) []string {
+	tool := args[0]
+	if supported {
+		if _, err := exec.LookPath(tool); err == nil {
+			return append(prefix, args...)
+		}
+	}
+	if _, warned := warnOnce.LoadOrStore(tool, true); !warned {
+		log.Printf("Ignoring process priority setting: %s is not available on this system", tool)
+	}
+	return prefix
+}
+
+// started is a no-op; the wrappers already applied everything
+func (p *ProcessPriority) started(cmd *exec.Cmd) error {
+	return nil
+}
@@ -0,0 +1,63 @@
+//go:build windows
+
+package cli
+
+import (
+	"fmt"
+	"os/exec"
+	"syscall"
+	"unsafe"
+)
+
+// Process creation flags selecting a priority class
+const (
+	idlePriorityClass        = 0x00000040
+	belowNormalPriorityClass = 0x00004000
+)
+
+var (
+	kernel32                   = syscall.NewLazyDLL("kernel32.dll")
+	procSetProcessAffinityMask = kernel32.NewProc("SetProcessAffinityMask")
+)
+
+// prepare creates the process in a lower priority class, which processes
+// it starts inherit. IdleIO has no equivalent for child processes.
+func (p *ProcessPriority) prepare(cmd *exec.Cmd) {
+	if p.Nice <= 0 {
+		return
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	if p.Nice >= 10 {
+		cmd.SysProcAttr.CreationFlags |= idlePriorityClass
+	} else {
+		cmd.SysProcAttr.CreationFlags |= belowNormalPriorityClass
+	}
+}
+
+// started pins the new process to the configured CPUs; processes it starts
+// afterwards inherit the affinity
+func (p *ProcessPriority) started(cmd *exec.Cmd) error {
+	if len(p.CPUs) == 0 {
+		return nil
+	}
+	var mask uintptr
+	for _, cpu := range p.CPUs {
+		if cpu >= int(8*unsafe.Sizeof(mask)) {
+			return fmt.Errorf("CPU %d is out of range", cpu)
+		}
+		mask |= 1 << cpu
+	}
+
+	const processSetInformation = 0x0200
+	handle, err := syscall.OpenProcess(processSetInformation, false, uint32(cmd.Process.Pid))
+	if err != nil {
+		return fmt.Errorf("failed to open test process: %w", err)
+	}
+	defer syscall.CloseHandle(handle)
+	if r, _, err := procSetProcessAffinityMask.Call(uintptr(handle), mask); r == 0 {
+		return fmt.Errorf("failed to set CPU affinity: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+//go:build !unix
+
+package cli
+
+import (
+	"os"
+	"os/exec"
+	"time"
+)
+
+// setProcessGroup does nothing where process groups are not supported
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// stopProcessGroup kills a started command; the processes it started are
+// left to the job object of its resource limits, when it has one
+func stopProcessGroup(cmd *exec.Cmd, grace time.Duration) {
+	cmd.Process.Kill()
+}
+
+// signalProcessGroup kills a started command, which cannot be sent other
+// signals here
+func signalProcessGroup(cmd *exec.Cmd, sig os.Signal) {
+	cmd.Process.Kill()
+}
+
+// killProcessGroup does nothing where process groups are not supported
+func killProcessGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,43 @@
+//go:build unix
+
+package cli
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// setProcessGroup starts cmd in a process group of its own, so everything
+// it starts can be stopped with it
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// stopProcessGroup asks a started command to quit, which makes a go
+// binary dump its goroutines, and kills its process group after grace
+// if it has not quit by then
+func stopProcessGroup(cmd *exec.Cmd, grace time.Duration) {
+	cmd.Process.Signal(syscall.SIGQUIT)
+	time.AfterFunc(grace, func() { killProcessGroup(cmd) })
+}
+
+// signalProcessGroup sends sig to every process in the process group of a
+// started command
+func signalProcessGroup(cmd *exec.Cmd, sig os.Signal) {
+	if sig, ok := sig.(syscall.Signal); ok {
+		syscall.Kill(-cmd.Process.Pid, sig)
+		return
+	}
+	cmd.Process.Signal(sig)
+}
+
+// killProcessGroup kills the processes left in the process group of a
+// command, such as servers its tests started
+func killProcessGroup(cmd *exec.Cmd) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,76 @@
+//go:build unix
+
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRunSetupWatchdog_ForwardsInterrupt(t *testing.T) {
+	t.Setenv("GO_SENTINEL_SETUP_HELPER", "serve")
+	reader, writer := io.Pipe()
+	done := make(chan int, 1)
+	go func() {
+		timeout := BinaryTimeout{Timeout: time.Minute}
+		code, err := RunSetupWatchdog(time.Minute, ResourceLimits{}, timeout, os.Args[0], []string{"-test.run=^TestSetupWatchdogHelper$"}, writer, io.Discard)
+		if err != nil {
+			t.Errorf("RunSetupWatchdog() error = %v", err)
+		}
+		writer.Close()
+		done <- code
+	}()
+	servers := make(chan int, 1)
+	go func() {
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			if pid, ok := strings.CutPrefix(scanner.Text(), "server "); ok {
+				n, _ := strconv.Atoi(pid)
+				servers <- n
+			}
+		}
+	}()
+
+	var server int
+	select {
+	case server = <-servers:
+	case <-time.After(30 * time.Second):
+		t.Fatal("test binary did not start its server")
+	}
+	// Interrupt the watchdog, as Ctrl-C or a cancelled CI job would
+	syscall.Kill(os.Getpid(), syscall.SIGINT)
+	select {
+	case code := <-done:
+		if code == 0 {
+			t.Error("exit code = 0 after an interrupt")
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatal("interrupt did not stop the test binary")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !processGone(server) {
+		if time.Now().After(deadline) {
+			syscall.Kill(server, syscall.SIGKILL)
+			t.Fatal("server started by the test binary survived the interrupt")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// processGone reports whether a process has exited, counting zombies
+// waiting to be reaped as exited
+func processGone(pid int) bool {
+	if err := syscall.Kill(pid, 0); err != nil {
+		return true
+	}
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	return err == nil && strings.Contains(string(data), ") Z ")
+}
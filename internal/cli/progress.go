@@ -0,0 +1,230 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProgressMode controls how a renderer shows a run while its tests execute
+type ProgressMode int
+
+const (
+	// ProgressOff shows nothing until the run's results arrive
+	ProgressOff ProgressMode = iota
+	// ProgressLive redraws one status line in place, for terminals
+	ProgressLive
+	// ProgressPlain prints a status line now and then, for logs where
+	// lines cannot be redrawn
+	ProgressPlain
+)
+
+const (
+	// progressInterval is how often the live status line is redrawn
+	progressInterval = 100 * time.Millisecond
+	// progressPlainInterval is how often status lines are printed when
+	// they cannot be redrawn: often enough to show a long run is alive
+	progressPlainInterval = 30 * time.Second
+	// progressRunningShown is how many running packages are named
+	progressRunningShown = 3
+	// progressWidth caps the status line when the terminal width is
+	// unknown, since a wrapped line cannot be redrawn
+	progressWidth = 80
+)
+
+// progressFrames animate the live status line
+var progressFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// runProgress follows go test -json output as it is written and shows
+// how many packages finished, which are running, and the time elapsed.
+// Packages are seen as go test prints them: the output of packages
+// running alongside the one being streamed is buffered by go test, so
+// they appear when they finish.
+type runProgress struct {
+	out   io.Writer
+	mode  ProgressMode
+	width int
+	total int // Packages in the run, 0 when unknown
+
+	mu        sync.Mutex
+	partial   []byte
+	running   map[string]time.Time
+	finished  int
+	failed    int
+	start     time.Time
+	frame     int
+	drawn     bool // A live status line is on screen
+	lastPlain time.Time
+	done      chan struct{}
+	stopped   chan struct{}
+	now       func() time.Time
+}
+
+// newRunProgress creates progress for a run of total packages written to out
+func newRunProgress(out io.Writer, mode ProgressMode, width, total int) *runProgress {
+	if width <= 0 {
+		width = progressWidth
+	}
+	return &runProgress{
+		out:     out,
+		mode:    mode,
+		width:   width,
+		total:   total,
+		running: make(map[string]time.Time),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+		now:     time.Now,
+	}
+}
+
+// Write consumes test output, tracking when packages start and finish.
+// Lines that are not JSON events are ignored.
+func (p *runProgress) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.partial = append(p.partial, b...)
+	for {
+		i := bytes.IndexByte(p.partial, '\n')
+		if i < 0 {
+			break
+		}
+		line := p.partial[:i]
+		p.partial = p.partial[i+1:]
+
+		var event GoTestEvent
+		if err := json.Unmarshal(line, &event); err != nil || event.Test != "" || event.Package == "" {
+			continue
+		}
+		switch event.Action {
+		case "start", "run":
+			if _, ok := p.running[event.Package]; !ok {
+				p.running[event.Package] = p.now()
+			}
+		case "pass", "fail", "skip":
+			delete(p.running, event.Package)
+			p.finished++
+			if event.Action == "fail" {
+				p.failed++
+			}
+		}
+	}
+	return len(b), nil
+}
+
+// begin starts showing progress until stop is called
+func (p *runProgress) begin() {
+	p.start = p.now()
+	p.lastPlain = p.start
+	interval := progressInterval
+	if p.mode == ProgressPlain {
+		interval = time.Second
+	}
+	go func() {
+		defer close(p.stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.done:
+				return
+			case <-ticker.C:
+				p.tick()
+			}
+		}
+	}()
+}
+
+// stop stops showing progress and clears the live status line
+func (p *runProgress) stop() {
+	close(p.done)
+	<-p.stopped
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clearLocked()
+}
+
+// tick redraws the live status line, or prints a plain one when it is due
+func (p *runProgress) tick() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	switch p.mode {
+	case ProgressLive:
+		p.frame = (p.frame + 1) % len(progressFrames)
+		line := truncateLine(progressFrames[p.frame]+" "+p.status(now), p.width-1)
+		fmt.Fprintf(p.out, "\r\x1b[2K%s", line)
+		p.drawn = true
+	case ProgressPlain:
+		if now.Sub(p.lastPlain) >= progressPlainInterval {
+			fmt.Fprintf(p.out, "%s\n", p.status(now))
+			p.lastPlain = now
+		}
+	}
+}
+
+// interrupt runs write, which prints to the same output, with the live
+// status line cleared; it is redrawn at the next tick
+func (p *runProgress) interrupt(write func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clearLocked()
+	write()
+}
+
+// clearLocked erases the live status line
+func (p *runProgress) clearLocked() {
+	if p.drawn {
+		fmt.Fprint(p.out, "\r\x1b[2K")
+		p.drawn = false
+	}
+}
+
+// status describes the run so far, as "Running 3/12 packages · 1 failed ·
+// api, store · 4.2s"
+func (p *runProgress) status(now time.Time) string {
+	parts := []string{fmt.Sprintf("Running %d/%d packages", p.finished, max(p.total, p.finished+len(p.running)))}
+	if p.failed > 0 {
+		parts = append(parts, fmt.Sprintf("%d failed", p.failed))
+	}
+	if len(p.running) > 0 {
+		// Longest running first: those are the ones holding the run up
+		pkgs := make([]string, 0, len(p.running))
+		for pkg := range p.running {
+			pkgs = append(pkgs, pkg)
+		}
+		sort.Slice(pkgs, func(i, j int) bool {
+			if !p.running[pkgs[i]].Equal(p.running[pkgs[j]]) {
+				return p.running[pkgs[i]].Before(p.running[pkgs[j]])
+			}
+			return pkgs[i] < pkgs[j]
+		})
+		names := make([]string, 0, progressRunningShown)
+		for _, pkg := range pkgs[:min(len(pkgs), progressRunningShown)] {
+			names = append(names, path.Base(pkg))
+		}
+		running := strings.Join(names, ", ")
+		if extra := len(pkgs) - len(names); extra > 0 {
+			running += fmt.Sprintf(" +%d more", extra)
+		}
+		parts = append(parts, running)
+	}
+	parts = append(parts, now.Sub(p.start).Truncate(100*time.Millisecond).String())
+	return strings.Join(parts, " · ")
+}
+
+// truncateLine shortens line to width runes, marking the cut with an
+// ellipsis
+func truncateLine(line string, width int) string {
+	runes := []rune(line)
+	if len(runes) <= width || width < 1 {
+		return line
+	}
+	return string(runes[:width-1]) + "…"
+}
@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunProgress(t *testing.T) {
+	var buf bytes.Buffer
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	p := newRunProgress(&buf, ProgressLive, 0, 5)
+	p.now = func() time.Time { return now }
+	p.start = now
+
+	events := []string{
+		`{"Action":"start","Package":"example.com/app/api"}`,
+		`{"Action":"run","Package":"example.com/app/api","Test":"TestLogin"}`,
+		`{"Action":"start","Package":"example.com/app/store"}`,
+		`{"Action":"fail","Package":"example.com/app/api"}`,
+		`{"Action":"start","Package":"example.com/app/cache"}`,
+		`{"Action":"start","Package":"example.com/app/queue"}`,
+		`{"Action":"start","Package":"example.com/app/mail"}`,
+	}
+	// Events split across writes are put back together
+	out := strings.Join(events, "\n") + "\n"
+	p.Write([]byte(out[:40]))
+	p.Write([]byte(out[40:]))
+
+	now = now.Add(4250 * time.Millisecond)
+	want := "Running 1/5 packages · 1 failed · cache, mail, queue +1 more · 4.2s"
+	if got := p.status(now); got != want {
+		t.Errorf("status() = %q, want %q", got, want)
+	}
+
+	// The live line is redrawn in place, within the width, and cleared
+	p.tick()
+	if line := buf.String(); !strings.HasPrefix(line, "\r\x1b[2K") || strings.Contains(line, "\n") || len([]rune(line)) > 4+progressWidth-1 {
+		t.Errorf("live line = %q", line)
+	}
+	buf.Reset()
+	p.clearLocked()
+	if buf.String() != "\r\x1b[2K" {
+		t.Errorf("clear wrote %q", buf.String())
+	}
+}
+
+func TestRunProgress_Plain(t *testing.T) {
+	var buf bytes.Buffer
+	now := time.Now()
+	p := newRunProgress(&buf, ProgressPlain, 0, 2)
+	p.now = func() time.Time { return now }
+	p.start, p.lastPlain = now, now
+
+	now = now.Add(progressPlainInterval / 2)
+	p.tick()
+	if buf.Len() != 0 {
+		t.Errorf("printed %q before the interval", buf.String())
+	}
+	now = now.Add(progressPlainInterval)
+	p.tick()
+	if got := buf.String(); got != "Running 0/2 packages · 45s\n" {
+		t.Errorf("plain line = %q", got)
+	}
+}
+
+func TestRenderer_WritesAboveProgress(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewRendererWithStyle(&buf, false)
+	r.SetProgress(ProgressLive)
+	if !r.showsProgress() {
+		t.Fatal("showsProgress() = false with live progress")
+	}
+	p := r.startProgress(1)
+	p.tick()
+	r.writeln("slow test")
+	r.stopProgress()
+
+	if got := buf.String(); !strings.Contains(got, "\r\x1b[2Kslow test\n") {
+		t.Errorf("output = %q, want the status line cleared before the warning", got)
+	}
+	if r.progress != nil {
+		t.Error("progress still set after stopProgress")
+	}
+
+	r.SetOutputMode(OutputQuiet)
+	if r.showsProgress() {
+		t.Error("showsProgress() = true in quiet mode")
+	}
+}
@@ -4,24 +4,65 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"path"
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/newbpydev/go-sentinel/pkg/sentinelio"
 )
 
 // Renderer handles the display of test results
 type Renderer struct {
-	out    io.Writer
-	style  *Style
-	width  int
-	height int
+	out     io.Writer
+	style   *Style
+	width   int
+	height  int
+	columns []Column // Fields shown for each test, DefaultColumns when empty
+	mode    OutputMode
+	loc     *time.Location // Zone of rendered timestamps, local time when nil
+
+	maxFailures   int    // Failed tests rendered in detail per run, 0 for all
+	moreFailures  string // How to see the failures beyond maxFailures
+	failuresShown int    // Failed tests rendered in detail in the current run
+
+	collapseSubtests bool // Hide the subtests of passing tests
+
+	progressMode ProgressMode
+	progress     *runProgress // Progress of the run executing, nil between runs
 }
 
-// write is a helper method to handle write errors
+// OutputMode controls how much a renderer prints
+type OutputMode int
+
+// Output modes, from most to least verbose
+const (
+	// OutputNormal prints progressive per-test output and the full summary
+	OutputNormal OutputMode = iota
+	// OutputSummaryOnly prints only the final summary
+	OutputSummaryOnly
+	// OutputQuiet prints only a one-line summary
+	OutputQuiet
+	// OutputSilent prints nothing
+	OutputSilent
+)
+
+// write is a helper method to handle write errors. Output written while
+// a run's progress is shown, such as slow test warnings, goes above the
+// live status line.
 func (r *Renderer) write(format string, args ...interface{}) {
+	if r.progress != nil {
+		r.progress.interrupt(func() { r.writeOut(format, args...) })
+		return
+	}
+	r.writeOut(format, args...)
+}
+
+// writeOut writes to the output, logging write errors
+func (r *Renderer) writeOut(format string, args ...interface{}) {
 	if _, err := fmt.Fprintf(r.out, format, args...); err != nil {
 		log.Printf("Error writing to output: %v", err)
 	}
@@ -84,12 +125,62 @@ func (r *Renderer) renderSummary(run *TestRun) {
 	// Format summaries with consistent spacing and color
 	r.writeln(r.style.FormatTestSummary("Test Files", failedFiles, passedFiles, 0, len(run.Suites)))
 	r.writeln(r.style.FormatTestSummary("Tests", run.NumFailed, run.NumPassed, run.NumSkipped, run.NumTotal))
+	r.writeln(r.style.FormatCount("Suite Size", formatTestCounts(run.Suites...)))
+	if n := countSetupFailures(run); n > 0 {
+		r.writeln(r.style.FormatCount("Setup", fmt.Sprintf("%d %s failed before any test ran", n, pluralize("package", n))))
+	}
+	if timedOut := TimedOutSuites(run); len(timedOut) > 0 {
+		r.writeln(r.style.FormatCount("Timeouts", fmt.Sprintf("%d %s timed out", len(timedOut), pluralize("package", len(timedOut)))))
+		for _, suite := range timedOut {
+			reason := strings.TrimPrefix(strings.TrimSpace(timeoutLine(suite)), timeoutMessage)
+			r.writeln("%s", dimStyle.Render(fmt.Sprintf("  %s timed out%s", suite.Package, reason)))
+		}
+	}
+	if retried := RetriedTests(run); len(retried) > 0 {
+		r.writeln(r.style.FormatCount("Retried", fmt.Sprintf("%d %s passed after retry", len(retried), pluralize("test", len(retried)))))
+		for _, test := range retried {
+			r.writeln("%s", dimStyle.Render(fmt.Sprintf("  %s %s passed on attempt %d", test.Package, test.Name, test.Attempts)))
+		}
+	}
+	if overages := BudgetOverages(run); len(overages) > 0 {
+		r.writeln(r.style.FormatCount("Budgets", fmt.Sprintf("%d %s over budget", len(overages), pluralize("package", len(overages)))))
+		for _, o := range overages {
+			r.writeln("%s", dimStyle.Render(fmt.Sprintf("  %s took %s, budget %s (+%s)", o.Package, FormatDurationAdaptive(o.Duration), FormatDurationAdaptive(o.Budget), FormatDurationAdaptive(o.Over()))))
+		}
+	}
+	if run.Seed != 0 {
+		r.writeln(r.style.FormatCount("Seed", strconv.FormatInt(run.Seed, 10)))
+	}
+	if run.NumFailed > 0 && run.Env != nil {
+		r.writeln(r.style.FormatCount("Environment", run.Env.String()))
+		if vars := run.Env.VarsString(); vars != "" {
+			r.writeln("%s", dimStyle.Render("  "+vars))
+		}
+	}
+	if cassettes := SummarizeCassettes(run); !cassettes.Empty() {
+		r.writeln(r.style.FormatCount("Cassettes", cassettes.String()))
+		for _, c := range cassettes.Stale {
+			r.writeln("%s", dimStyle.Render(fmt.Sprintf("  stale: %s %s (%s)", c.Package, c.Path, c.Test)))
+		}
+		if len(cassettes.Stale) > 0 {
+			r.writeln("%s", dimStyle.Render("  Rerun with --refresh-fixtures to re-record stale cassettes"))
+		}
+	}
+	if snapshots := SummarizeSnapshots(run); !snapshots.Empty() {
+		r.writeln(r.style.FormatCount("Snapshots", snapshots.String()))
+		for _, s := range snapshots.Mismatched {
+			r.writeln("%s", dimStyle.Render(fmt.Sprintf("  mismatched: %s %s (%s)", s.Package, s.Path, s.Test)))
+		}
+		if len(snapshots.Mismatched) > 0 {
+			r.writeln("%s", dimStyle.Render("  Run go-sentinel snapshot review to accept or reject the new values"))
+		}
+	}
 
 	// Add total duration and (if possible) heap usage
 	r.writeln("")
-	r.writeln(r.style.FormatTimestamp("Start at", run.StartTime))
+	r.writeln(r.style.FormatTimestamp("Start at", r.inZone(run.StartTime)))
 	if !run.EndTime.IsZero() {
-		r.writeln(r.style.FormatTimestamp("End at", run.EndTime))
+		r.writeln(r.style.FormatTimestamp("End at", r.inZone(run.EndTime)))
 	}
 
 	totalDuration := run.Duration
@@ -120,35 +211,221 @@ func (r *Renderer) renderSummary(run *TestRun) {
 		r.writeln("")
 		r.writeln(r.style.FormatErrorHeader(" FAILED Tests "))
 		r.writeln("")
-		for _, suite := range run.Suites {
-			if suite.NumFailed > 0 {
+		r.renderFailedTests(run, r.maxFailures)
+	}
+}
+
+// renderFailedTests lists the failed tests of a run by package, with the
+// first line of each failure, up to limit tests when limit is positive
+func (r *Renderer) renderFailedTests(run *TestRun, limit int) {
+	causes := FindRootCauses(run)
+	r.renderRootCauses(causes)
+
+	shown, hidden := 0, 0
+	for _, suite := range run.Suites {
+		if suite.NumFailed == 0 {
+			continue
+		}
+		if suite.SetupFailed {
+			r.renderFailedSetup(suite, causes)
+			continue
+		}
+		suiteShown := false
+		for _, test := range suite.Tests {
+			if test.Status != TestStatusFailed {
+				continue
+			}
+			if limit > 0 && shown >= limit {
+				hidden++
+				continue
+			}
+			if !suiteShown {
 				r.writeln(r.style.FormatFailedSuite(suite.FilePath))
-				for _, test := range suite.Tests {
-					if test.Status == TestStatusFailed {
-						testName := test.Name
-						if strings.Contains(testName, "/") {
-							parts := strings.Split(testName, "/")
-							testName = parts[len(parts)-1]
-						}
-						r.writeln("    %s", r.style.FormatFailedTest(testName))
-						if test.Error != nil {
-							if test.Error.Message != "" {
-								msg := strings.TrimSpace(test.Error.Message)
-								if idx := strings.Index(msg, "\n"); idx > 0 {
-									msg = msg[:idx]
-								}
-								r.writeln("    %s", r.style.FormatErrorMessage(msg))
-							}
-							if test.Error.Location != nil {
-								r.writeln("    %s", r.style.FormatErrorLocation(test.Error.Location))
-							}
-						}
-						r.writeln("")
+				suiteShown = true
+			}
+			shown++
+			testName := test.Name
+			if strings.Contains(testName, "/") {
+				parts := strings.Split(testName, "/")
+				testName = parts[len(parts)-1]
+			}
+			r.writeln("    %s", r.style.FormatFailedTest(testName))
+			if n := rootCauseOf(causes, test); n > 0 {
+				r.writeln("    %s", dimStyle.Render(fmt.Sprintf("likely root cause %d above", n)))
+			} else if test.Error != nil {
+				if test.Error.Message != "" {
+					msg := strings.TrimSpace(test.Error.Message)
+					if test.Panic != nil {
+						msg = "panic: " + test.Panic.Message
 					}
+					if idx := strings.Index(msg, "\n"); idx > 0 {
+						msg = msg[:idx]
+					}
+					r.writeln("    %s", r.style.FormatErrorMessage(msg))
+				}
+				if test.Error.Location != nil {
+					r.writeln("    %s", r.style.FormatErrorLocation(test.Error.Location))
 				}
 			}
+			r.renderAnnotations(test.Annotations, 2)
+			if test.Repro != "" {
+				r.writeln("    %s", dimStyle.Render("$ "+test.Repro))
+			}
+			r.writeln("")
+		}
+	}
+	r.renderMoreFailures(hidden)
+}
+
+// renderFailedSetup lists a package that failed before any test ran,
+// with the first line of what it printed
+func (r *Renderer) renderFailedSetup(suite *TestSuite, causes []*RootCause) {
+	r.writeln(r.style.FormatFailedSuite(suite.FilePath))
+	r.writeln("    %s", r.style.FormatErrorMessage(setupFailureReason(suite)))
+	cause := 0
+	for i, c := range causes {
+		if c.MatchesSetup(suite) {
+			cause = i + 1
+			break
+		}
+	}
+	if cause > 0 {
+		r.writeln("    %s", dimStyle.Render(fmt.Sprintf("likely root cause %d above", cause)))
+	} else if line := failureSummary(suite.Output); line != "" {
+		r.writeln("    %s", dimStyle.Render(line))
+	}
+	r.writeln("")
+}
+
+// countSetupFailures returns the packages of a run that failed before any
+// test ran
+func countSetupFailures(run *TestRun) int {
+	n := 0
+	for _, suite := range run.Suites {
+		if suite.SetupFailed {
+			n++
+		}
+	}
+	return n
+}
+
+// renderRootCauses shows first errors shared by many failures once,
+// before the failures themselves
+func (r *Renderer) renderRootCauses(causes []*RootCause) {
+	for i, cause := range causes {
+		title := "Likely root cause"
+		if len(causes) > 1 {
+			title = fmt.Sprintf("Likely root cause %d", i+1)
+		}
+		if cause.Hint != "" {
+			title += ": " + cause.Hint
+		}
+		r.writeln("  %s", warningStyle.Bold(true).Render(title))
+		r.writeln("    %s", r.style.FormatErrorMessage(cause.Error))
+		var affected []string
+		if cause.Tests > 0 {
+			affected = append(affected, fmt.Sprintf("%s failed %s", formatThousands(cause.Tests), pluralize("test", cause.Tests)))
+		}
+		if setups := cause.failures - cause.Tests; setups > 0 {
+			affected = append(affected, fmt.Sprintf("%d %s failing outside any test", setups, pluralize("package", setups)))
+		}
+		pkgs := cause.Packages
+		if len(pkgs) > 3 {
+			pkgs = append(pkgs[:3:3], fmt.Sprintf("%d more", len(cause.Packages)-3))
+		}
+		r.writeln("    %s", dimStyle.Render(fmt.Sprintf("%s in %s", strings.Join(affected, " and "), strings.Join(pkgs, ", "))))
+		r.writeln("")
+	}
+}
+
+// rootCauseOf returns the 1-based number of the root cause a failed test
+// shares, or 0
+func rootCauseOf(causes []*RootCause, test *TestResult) int {
+	for i, cause := range causes {
+		if cause.Matches(test) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// renderMoreFailures notes failed tests left out by the failure cap
+func (r *Renderer) renderMoreFailures(hidden int) {
+	if hidden == 0 {
+		return
+	}
+	line := fmt.Sprintf("  and %s more failed %s", formatThousands(hidden), pluralize("test", hidden))
+	if r.moreFailures != "" {
+		line += " — " + r.moreFailures
+	}
+	r.writeln("%s", warningStyle.Render(line))
+	r.writeln("")
+}
+
+// RenderFailedTests lists every failed test of a run, regardless of the
+// failure cap
+func (r *Renderer) RenderFailedTests(run *TestRun) {
+	r.writeln("%s", r.style.FormatErrorHeader(" FAILED Tests "))
+	r.writeln("")
+	if run == nil || run.NumFailed == 0 {
+		r.writeln("  No failed tests in the last run")
+		r.writeln("")
+		return
+	}
+	r.renderFailedTests(run, 0)
+}
+
+// RenderTestTrees shows the tests of a run again, after subtests were
+// collapsed or expanded
+func (r *Renderer) RenderTestTrees(run *TestRun) {
+	state := "expanded"
+	if r.collapseSubtests {
+		state = "collapsed"
+	}
+	r.writeln("%s", dimStyle.Render("Subtests of passing tests are now "+state))
+	r.writeln("")
+	if run == nil {
+		return
+	}
+	r.failuresShown = 0
+	for _, suite := range run.Suites {
+		r.RenderSuite(suite)
+	}
+}
+
+// RenderColumnPicker lists every column, numbered and marked when shown,
+// followed by the tests of run again when the columns changed
+func (r *Renderer) RenderColumnPicker(run *TestRun) {
+	r.writeln("%s", r.style.FormatHeader(" COLUMNS "))
+	columns := r.Columns()
+	for i, col := range AllColumns {
+		mark := " "
+		if hasColumn(columns, col) {
+			mark = "x"
 		}
+		r.writeln("  %d [%s] %s", i+1, mark, col.Title())
+	}
+	r.writeln("%s", dimStyle.Render("Type 'o' and column numbers, e.g. 'o 3 6', then Enter to toggle them"))
+	r.writeln("")
+	if run == nil {
+		return
+	}
+	r.failuresShown = 0
+	for _, suite := range run.Suites {
+		r.RenderSuite(suite)
+	}
+}
+
+// formatThousands formats n with comma thousands separators
+func formatThousands(n int) string {
+	if n < 0 {
+		return "-" + formatThousands(-n)
+	}
+	s := strconv.Itoa(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
 	}
+	return s
 }
 
 // renderHeader renders the test run header
@@ -187,6 +464,9 @@ func (r *Renderer) renderSuite(suite *TestSuite) {
 	if suite.Duration > 0 {
 		headerParts = append(headerParts, FormatDurationPrecise(suite.Duration))
 	}
+	if suite.Cached {
+		headerParts = append(headerParts, "(cached)")
+	}
 
 	// Add heap info if available
 	var heapInfo string
@@ -223,8 +503,8 @@ func (r *Renderer) renderSuite(suite *TestSuite) {
 	fmt.Fprintln(r.out, headerStyle.Render(headerText))
 
 	// Render test results
-	for _, test := range suite.Tests {
-		r.RenderTestResult(test)
+	if hidden := r.renderTestTree(BuildTestTree(suite.Tests), suite.Package, 1); hidden > 0 {
+		r.writeln("%s", dimStyle.Render(fmt.Sprintf("  … %s more failed %s not shown", formatThousands(hidden), pluralize("test", hidden))))
 	}
 
 	// Add spacing after test results
@@ -236,6 +516,21 @@ func (r *Renderer) renderSuite(suite *TestSuite) {
 	runtime.ReadMemStats(&memAfter)
 }
 
+// formatTestCounts describes the number of test functions and, when any
+// were reported, assertions in the given suites
+func formatTestCounts(suites ...*TestSuite) string {
+	funcs, assertions := 0, 0
+	for _, suite := range suites {
+		funcs += suite.NumTestFuncs()
+		assertions += suite.NumAssertions()
+	}
+	text := fmt.Sprintf("%d test %s", funcs, pluralize("func", funcs))
+	if assertions > 0 {
+		text += fmt.Sprintf(" | %d %s", assertions, pluralize("assertion", assertions))
+	}
+	return text
+}
+
 // pluralize returns the plural form of a word if count != 1
 func pluralize(word string, count int) string {
 	if count == 1 {
@@ -318,16 +613,52 @@ func isCommonDir(dir string) bool {
 
 // RenderTestResult renders a single test result
 func (r *Renderer) RenderTestResult(result *TestResult) {
+	r.renderTestLine(result, formatTestName(result.Name), strings.Count(result.Name, "/")+1, "")
+}
+
+// renderTestLine renders a test under the given name, indented to its
+// depth in the subtest tree, followed by its failure details. The note, if
+// any, follows the name.
+func (r *Renderer) renderTestLine(result *TestResult, name string, depth int, note string) {
 	// Format test name with icon and color
 	icon := r.style.StatusIcon(result.Status)
 
-	// Format the test name
-	name := formatTestName(result.Name)
-
-	// Format duration with right alignment
-	duration := ""
-	if result.Status != TestStatusRunning && result.Status != TestStatusPending {
-		duration = FormatDurationPrecise(result.Duration)
+	// Build the configured columns
+	var cells []string
+	for _, col := range r.Columns() {
+		switch col {
+		case ColumnStatus:
+			cells = append(cells, icon)
+		case ColumnName:
+			if note != "" {
+				name += " " + note
+			}
+			cells = append(cells, name)
+		case ColumnPackage:
+			cells = append(cells, formatFilePath(result.Package))
+		case ColumnDuration:
+			duration := ""
+			if result.Status != TestStatusRunning && result.Status != TestStatusPending {
+				duration = FormatDurationPrecise(result.Duration)
+			}
+			cells = append(cells, duration)
+		case ColumnAttempts:
+			if result.Attempts > 0 {
+				cells = append(cells, fmt.Sprintf("%d %s", result.Attempts, pluralize("attempt", result.Attempts)))
+			}
+		case ColumnOwner:
+			if result.Owner != "" {
+				cells = append(cells, result.Owner)
+			}
+		case ColumnTags:
+			for _, tag := range result.Tags {
+				cells = append(cells, "#"+tag)
+			}
+		case ColumnLastChange:
+			if !result.LastChange.IsZero() {
+				cells = append(cells, "changed "+FormatAge(result.LastChange, time.Now()))
+			}
+		}
 	}
 
 	// Choose color for test name and icon
@@ -343,20 +674,97 @@ func (r *Renderer) RenderTestResult(result *TestResult) {
 		style = dimStyle.Copy()
 	}
 
-	// Calculate indentation based on test hierarchy
-	indent := "  "
-	if strings.Contains(result.Name, "/") {
-		parts := strings.Split(result.Name, "/")
-		indent = strings.Repeat("  ", len(parts))
+	// Format the line with proper spacing and indentation
+	line := strings.Repeat("  ", depth) + strings.Join(cells, " ")
+	badge := ""
+	if result.Panic != nil {
+		badge = " " + r.style.FormatErrorHeader(" PANIC ")
+	}
+	r.out.Write([]byte(style.Render(line) + badge + "\n"))
+
+	// Format error if present; panics get their condensed stack instead
+	// of the raw goroutine dump
+	if result.Panic != nil {
+		r.renderPanic(result, depth)
+	} else if result.Error != nil {
+		r.renderError(result.Error, depth)
+	}
+
+	// Annotations are part of the failure details
+	if result.Status == TestStatusFailed {
+		r.renderAnnotations(result.Annotations, depth)
 	}
+}
 
-	// Format the line with proper spacing and indentation
-	line := fmt.Sprintf("%s%s %s %s", indent, icon, name, duration)
-	r.out.Write([]byte(style.Render(line) + "\n"))
+// renderTestTree renders tests with their subtests nested below them,
+// each named by its last element. Parents show their rolled-up status and
+// duration; with collapsed subtests, only failing parents are expanded.
+// It returns the number of failed tests left out beyond the failure cap.
+func (r *Renderer) renderTestTree(nodes []*TestNode, pkg string, depth int) int {
+	hidden := 0
+	for _, node := range nodes {
+		if node.Status == TestStatusFailed {
+			if r.maxFailures > 0 && r.failuresShown >= r.maxFailures {
+				hidden += 1 + node.Failed
+				continue
+			}
+			r.failuresShown++
+		}
+
+		display := TestResult{Name: node.Name, Package: pkg}
+		if node.Test != nil {
+			display = *node.Test
+		}
+		display.Status, display.Duration = node.Status, node.Duration
+		name := formatTestName(node.Name)
+		if depth > 1 {
+			name = formatSubtestPart(node.Name[strings.LastIndex(node.Name, "/")+1:])
+		}
+		r.renderTestLine(&display, name, depth, subtestNote(node))
+
+		if len(node.Children) > 0 && (!r.collapseSubtests || node.Status == TestStatusFailed) {
+			hidden += r.renderTestTree(node.Children, pkg, depth+1)
+		}
+	}
+	return hidden
+}
+
+// subtestNote summarizes the subtests below a node, such as "(4 subtests,
+// 1 failed)", or is empty for a test without subtests
+func subtestNote(node *TestNode) string {
+	n := node.Subtests()
+	if n == 0 {
+		return ""
+	}
+	note := fmt.Sprintf("(%d %s", n, pluralize("subtest", n))
+	if node.Failed > 0 {
+		note += fmt.Sprintf(", %d failed", node.Failed)
+	}
+	if node.Skipped > 0 {
+		note += fmt.Sprintf(", %d skipped", node.Skipped)
+	}
+	return note + ")"
+}
 
-	// Format error if present
-	if result.Error != nil {
-		r.renderError(result.Error, strings.Count(result.Name, "/")+1)
+// renderAnnotations renders links, metrics, and sections emitted by a test
+func (r *Renderer) renderAnnotations(annotations []sentinelio.Annotation, depth int) {
+	indent := strings.Repeat("  ", depth)
+	for _, a := range annotations {
+		switch a.Kind {
+		case sentinelio.KindLink:
+			r.writeln("%s", dimStyle.Render(fmt.Sprintf("%s  ↗ %s: %s", indent, a.Name, a.URL)))
+		case sentinelio.KindMetric:
+			value := strconv.FormatFloat(a.Value, 'f', -1, 64)
+			if a.Unit != "" {
+				value += " " + a.Unit
+			}
+			r.writeln("%s", dimStyle.Render(fmt.Sprintf("%s  ◆ %s: %s", indent, a.Name, value)))
+		case sentinelio.KindSection:
+			r.writeln("%s", dimStyle.Render(fmt.Sprintf("%s  ▸ %s", indent, a.Title)))
+			for _, line := range strings.Split(strings.TrimRight(a.Body, "\n"), "\n") {
+				r.writeln("%s    %s", indent, line)
+			}
+		}
 	}
 }
 
@@ -370,12 +778,8 @@ func formatTestName(name string) string {
 		parts := strings.Split(name, "/")
 		// Format each part
 		for i, part := range parts {
-			// For subtest parts, keep the original casing if it looks intentional
-			if i > 0 && (strings.Contains(part, "_") || strings.Contains(part, " ")) {
-				parts[i] = formatTestPart(part)
-			} else if i > 0 {
-				// For clean subtest names, just trim Test prefix
-				parts[i] = strings.TrimPrefix(part, "Test")
+			if i > 0 {
+				parts[i] = formatSubtestPart(part)
 			} else {
 				// Format the main test name
 				parts[i] = formatTestPart(part)
@@ -388,6 +792,16 @@ func formatTestName(name string) string {
 	return formatTestPart(name)
 }
 
+// formatSubtestPart formats one element of a subtest name
+func formatSubtestPart(part string) string {
+	// Keep the original casing if it looks intentional
+	if strings.Contains(part, "_") || strings.Contains(part, " ") {
+		return formatTestPart(part)
+	}
+	// For clean subtest names, just trim Test prefix
+	return strings.TrimPrefix(part, "Test")
+}
+
 // formatTestPart formats a single part of a test name
 func formatTestPart(part string) string {
 	// Handle empty parts
@@ -569,21 +983,25 @@ func (r *Renderer) renderError(err *TestError, depth int) {
 	if err.Location != nil {
 		// Format location in Vitest style
 		locLine := fmt.Sprintf("%s  at %s:%d", indent, err.Location.File, err.Location.Line)
+		if err.Location.Column > 0 {
+			locLine += fmt.Sprintf(":%d", err.Location.Column)
+		}
 		r.out.Write([]byte(dimStyle.Render(locLine) + "\n"))
 
 		// Show code snippet if available
 		if err.Location.Snippet != "" {
 			// Format snippet with line numbers and highlighting
-			snippetLines := strings.Split(strings.TrimSpace(err.Location.Snippet), "\n")
+			snippetLines := strings.Split(strings.TrimRight(err.Location.Snippet, "\n"), "\n")
 			startLine := err.Location.StartLine
+			width := len(strconv.Itoa(startLine + len(snippetLines) - 1))
 			for i, line := range snippetLines {
 				lineNum := startLine + i
 				// Highlight the error line
 				if lineNum == err.Location.Line {
-					snippetLine := fmt.Sprintf("%s    %d │ %s", indent, lineNum, line)
+					snippetLine := fmt.Sprintf("%s    %*d │ %s", indent, width, lineNum, line)
 					r.out.Write([]byte(errorStyle.Render(snippetLine) + "\n"))
 				} else {
-					snippetLine := fmt.Sprintf("%s    %d │ %s", indent, lineNum, line)
+					snippetLine := fmt.Sprintf("%s    %*d │ %s", indent, width, lineNum, line)
 					r.out.Write([]byte(dimStyle.Render(snippetLine) + "\n"))
 				}
 			}
@@ -605,12 +1023,175 @@ func (r *Renderer) renderError(err *TestError, depth int) {
 	}
 }
 
+// renderPanic renders the panic a test died of: its value, the project's
+// frames of the stack with the innermost highlighted, runs of standard
+// library and dependency frames collapsed to one line each, and the source
+// around the innermost project frame
+func (r *Renderer) renderPanic(result *TestResult, depth int) {
+	indent := strings.Repeat("  ", depth)
+	p := result.Panic
+	for _, line := range strings.Split("panic: "+p.Message, "\n") {
+		r.writeln("%s", errorStyle.Render(fmt.Sprintf("%s→ %s", indent, line)))
+	}
+
+	innermost := p.ProjectFrame()
+	var collapsed []*PanicFrame
+	flush := func() {
+		if len(collapsed) == 0 {
+			return
+		}
+		r.writeln("%s", dimStyle.Render(fmt.Sprintf("%s    … %d %s in %s", indent, len(collapsed), pluralize("frame", len(collapsed)), strings.Join(framePackages(collapsed), ", "))))
+		collapsed = nil
+	}
+	for _, frame := range p.Frames {
+		if !frame.Project {
+			collapsed = append(collapsed, frame)
+			continue
+		}
+		flush()
+		line := fmt.Sprintf("%s    %s  %s:%d", indent, frameFunction(frame.Function), frame.File, frame.Line)
+		if frame == innermost {
+			r.writeln("%s", errorStyle.Bold(true).Render(line))
+		} else {
+			r.writeln("%s", line)
+		}
+	}
+	flush()
+
+	if result.Error != nil && result.Error.Location != nil {
+		r.renderError(&TestError{Location: result.Error.Location}, depth)
+	}
+}
+
+// frameFunction returns the function of a stack frame without its
+// argument list
+func frameFunction(function string) string {
+	if strings.HasSuffix(function, ")") {
+		if i := strings.LastIndex(function, "("); i > 0 {
+			return function[:i]
+		}
+	}
+	return function
+}
+
+// framePackages returns the packages of stack frames, in the order they
+// first appear
+func framePackages(frames []*PanicFrame) []string {
+	var pkgs []string
+	seen := make(map[string]bool)
+	for _, frame := range frames {
+		function := frameFunction(strings.TrimPrefix(frame.Function, "created by "))
+		pkg := "runtime" // Builtins such as panic itself
+		slash := strings.LastIndex(function, "/") + 1
+		if i := strings.Index(function[slash:], "."); i >= 0 {
+			pkg = function[:slash+i]
+		}
+		if !seen[pkg] {
+			seen[pkg] = true
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	return pkgs
+}
+
 // RenderTestStart renders the start of a test run
 func (r *Renderer) RenderTestStart(_ *TestRun) {
+	r.failuresShown = 0
+	if r.mode != OutputNormal {
+		return
+	}
 	// Add a blank line before test output
 	r.writeln("")
 }
 
+// SetOutputMode sets how much the renderer prints
+func (r *Renderer) SetOutputMode(mode OutputMode) {
+	r.mode = mode
+	if mode == OutputSilent {
+		r.out = io.Discard
+	}
+}
+
+// OutputMode returns how much the renderer prints
+func (r *Renderer) OutputMode() OutputMode {
+	return r.mode
+}
+
+// SetProgress chooses how runs are shown while their tests execute. It
+// only applies in OutputNormal mode; the other modes stay quiet until the
+// results arrive.
+func (r *Renderer) SetProgress(mode ProgressMode) {
+	r.progressMode = mode
+}
+
+// showsProgress reports whether runs are shown while their tests execute
+func (r *Renderer) showsProgress() bool {
+	return r != nil && r.progressMode != ProgressOff && r.mode == OutputNormal
+}
+
+// startProgress begins showing a run of total packages, 0 when unknown,
+// and returns the writer its go test output is copied to
+func (r *Renderer) startProgress(total int) *runProgress {
+	r.progress = newRunProgress(r.out, r.progressMode, r.width, total)
+	r.progress.begin()
+	return r.progress
+}
+
+// stopProgress stops showing the run's progress, if shown
+func (r *Renderer) stopProgress() {
+	if r == nil || r.progress == nil {
+		return
+	}
+	r.progress.stop()
+	r.progress = nil
+}
+
+// SetMaxFailures caps the failed tests rendered in detail per run at n, or
+// renders all of them when n is 0. The hint, if any, tells the user how
+// to see the rest.
+func (r *Renderer) SetMaxFailures(n int, hint string) {
+	r.maxFailures = n
+	r.moreFailures = hint
+}
+
+// SetCollapseSubtests hides the subtests of passing tests, leaving their
+// counts beside the parent, or shows every subtest when false. Failing
+// tests are always expanded down to their failures.
+func (r *Renderer) SetCollapseSubtests(collapse bool) {
+	r.collapseSubtests = collapse
+}
+
+// CollapseSubtests reports whether the subtests of passing tests are hidden
+func (r *Renderer) CollapseSubtests() bool {
+	return r.collapseSubtests
+}
+
+// SetLocation sets the time zone timestamps are rendered in
+func (r *Renderer) SetLocation(loc *time.Location) {
+	r.loc = loc
+}
+
+// inZone converts t to the renderer's time zone
+func (r *Renderer) inZone(t time.Time) time.Time {
+	if r.loc == nil {
+		return t.Local()
+	}
+	return t.In(r.loc)
+}
+
+// SetColumns chooses the fields shown for each test
+func (r *Renderer) SetColumns(columns []Column) {
+	r.columns = columns
+}
+
+// Columns returns the fields shown for each test
+func (r *Renderer) Columns() []Column {
+	if len(r.columns) == 0 {
+		return DefaultColumns
+	}
+	return r.columns
+}
+
 // SetDimensions sets the terminal dimensions
 func (r *Renderer) SetDimensions(width, height int) {
 	r.width = width
@@ -622,6 +1203,11 @@ func (r *Renderer) RenderWatchHeader() {
 	r.writeln("%s", r.style.FormatHeader(" WATCH MODE "))
 	r.writeln(" Press 'a' to run all tests")
 	r.writeln(" Press 'f' to run only failed tests")
+	r.writeln(" Press 's' and Enter for session statistics")
+	r.writeln(" Press 't' and Enter for the watcher trace (with --trace-watch)")
+	r.writeln(" Press 'l' and Enter to list every failure of the last run")
+	r.writeln(" Press 'c' and Enter to collapse or expand subtests")
+	r.writeln(" Press 'o' and Enter to choose the columns shown for each test")
 	r.writeln(" Press 'q' to quit")
 	r.writeln("")
 }
@@ -631,68 +1217,1055 @@ func (r *Renderer) RenderFileChange(path string) {
 	r.writeln("\nFile changed: %s\n", path)
 }
 
-// Helper functions
-
-// RenderFinalSummary renders the final test summary
-func (r *Renderer) RenderFinalSummary(run *TestRun) {
-	// Use the consolidated summary rendering
-	r.renderSummary(run)
+// RenderWatchStats shows the cadence of the watch session
+func (r *Renderer) RenderWatchStats(stats WatchStatsSnapshot) {
+	r.writeln("%s", r.style.FormatHeader(" SESSION "))
+	r.writeln("%s", r.style.FormatCount("Runs", fmt.Sprintf("%d (%d after changes)", stats.Runs, stats.Reruns)))
+	latency := "-"
+	if stats.Reruns > 0 {
+		latency = FormatDurationAdaptive(stats.AverageLatency)
+	}
+	r.writeln("%s", r.style.FormatCount("Latency", latency+" average from save to results"))
+	r.writeln("%s", r.style.FormatCount("Tests", fmt.Sprintf("%d run, %d failed, after %d changed %s", stats.Tests, stats.Failures, stats.FileChanges, pluralize("file", stats.FileChanges))))
+	r.writeln("%s", r.style.FormatCount("Cache hits", fmt.Sprintf("%.0f%% of packages", 100*stats.CacheHitRate)))
+	r.writeln("%s", r.style.FormatCount("Time saved", fmt.Sprintf("%s by %d affected-only %s", FormatDurationAdaptive(stats.TimeSaved), stats.SelectedRuns, pluralize("rerun", stats.SelectedRuns))))
+	r.writeln("%s", r.style.FormatCount("Uptime", FormatDurationAdaptive(stats.Uptime)))
+	r.writeln("")
 }
 
-// RenderTestSummary is deprecated and should not be used
-func (r *Renderer) RenderTestSummary(run *TestRun) {
-	// This function is deprecated and should not be used
-	// Use RenderFinalSummary instead
+// RenderWatchTrace shows the recorded watcher decisions, oldest first
+func (r *Renderer) RenderWatchTrace(entries []WatchTraceEntry) {
+	r.writeln("%s", r.style.FormatHeader(" WATCH TRACE "))
+	if len(entries) == 0 {
+		r.writeln("  No file events yet")
+	}
+	for _, e := range entries {
+		line := fmt.Sprintf("  %s  %-8s  ", r.inZone(e.Time).Format("15:04:05.000"), e.Stage)
+		if e.Path != "" {
+			line += e.Path + ": "
+		}
+		line += e.Message
+		if e.Stage == TraceRun {
+			r.writeln("%s", line)
+		} else {
+			r.writeln("%s", dimStyle.Render(line))
+		}
+	}
+	r.writeln("")
 }
 
-// RenderProgress renders the current test progress
-func (r *Renderer) RenderProgress(run *TestRun) {
-	completed := run.NumPassed + run.NumFailed + run.NumSkipped
-	if run.NumTotal == 0 {
-		return
+// RenderTestSelection shows which tests a watch rerun was narrowed to
+func (r *Renderer) RenderTestSelection(sel *TestSelection) {
+	target := strings.Join(sel.Packages, ", ")
+	if len(sel.Tests) > 0 {
+		target = fmt.Sprintf("%s in %s", strings.Join(sel.Tests, ", "), target)
 	}
+	if len(sel.Edited) > 0 {
+		target += " for changes to " + strings.Join(sel.Edited, ", ")
+	}
+	r.writeln("%s", dimStyle.Render(" ↻ Rerunning "+target))
+}
 
-	percentage := float64(completed) / float64(run.NumTotal) * 100
-	r.write("Running tests... %.0f%% (%d/%d)\n", percentage, completed, run.NumTotal)
+// RenderEditPrompt shows the go test arguments an "edit & rerun" command
+// starts from and asks for the new ones
+func (r *Renderer) RenderEditPrompt(pkgs []string, args []string) {
+	target := "all packages"
+	if len(pkgs) > 0 {
+		target = strings.Join(pkgs, ", ")
+	}
+	r.writeln("%s", dimStyle.Render(" ✎ Rerun "+target+" with:"))
+	r.writeln("   %s", formatArgs(args))
+	r.writeln("%s", dimStyle.Render("   Type the arguments to use, or press Enter to keep them"))
 }
 
-// RenderSuiteSummary renders a test suite summary
-func (r *Renderer) RenderSuiteSummary(suite *TestSuite) {
-	// Only show summary for suites with failures
-	if suite.NumFailed == 0 {
+// RenderGrepSelection shows the tests a --grep pattern selected
+func (r *Renderer) RenderGrepSelection(pattern string, sel *TestSelection) {
+	if r.mode != OutputNormal {
 		return
 	}
-
-	r.writeln("Suite")
-	r.writeln("  %s", suite.FilePath)
-	r.writeln("  Total: %d", suite.NumTotal)
-	r.writeln("  Passed: %d", suite.NumPassed)
-	r.writeln("  Failed: %d", suite.NumFailed)
-	r.writeln("  Skipped: %d", suite.NumSkipped)
-	r.writeln("  Time: %s", FormatDurationPrecise(suite.Duration))
-	r.writeln("")
+	r.writeln("%s", dimStyle.Render(fmt.Sprintf(" ⌕ %d %s matching %s in %d %s: %s", len(sel.Tests), pluralize("test", len(sel.Tests)), pattern, len(sel.Packages), pluralize("package", len(sel.Packages)), strings.Join(sel.Tests, ", "))))
 }
 
-// RenderSuite renders a test suite
-func (r *Renderer) RenderSuite(suite *TestSuite) {
-	// Print suite header
-	if _, err := fmt.Fprintf(r.out, "%s\n", r.style.FormatHeader(fmt.Sprintf(" %s ", suite.Package))); err != nil {
-		log.Printf("Error writing suite header: %v", err)
+// RenderShardSelection shows which part of a sharded suite this run covers
+func (r *Renderer) RenderShardSelection(plan *ShardPlan, index int) {
+	if r.mode != OutputNormal {
+		return
+	}
+	shard := plan.Shards[index]
+	sel := shard.Selection()
+	line := fmt.Sprintf(" ⌗ Shard %d of %d: %d %s in %d %s, split by %s", index, plan.Total,
+		len(shard.Tests), pluralize("test", len(shard.Tests)), len(sel.Packages), pluralize("package", len(sel.Packages)), plan.By)
+	if shard.Estimate > 0 {
+		line += ", about " + FormatDurationAdaptive(shard.Estimate)
 	}
+	line += ", plan " + plan.Hash()
+	if plan.Unplanned > 0 {
+		line += fmt.Sprintf(" with %d unplanned %s added by name", plan.Unplanned, pluralize("test", plan.Unplanned))
+	}
+	r.writeln("%s", dimStyle.Render(line))
+}
 
-	// Test results
-	for _, result := range suite.Tests {
-		r.RenderTestResult(result)
+// RenderShardPlan lists the tests of each shard of a plan
+func (r *Renderer) RenderShardPlan(plan *ShardPlan) {
+	r.writeln("%s", r.style.FormatHeader(fmt.Sprintf(" SHARDS  %d, split by %s, plan %s ", plan.Total, plan.By, plan.Hash())))
+	for _, shard := range plan.Shards {
+		r.writeln("")
+		sel := shard.Selection()
+		counts := fmt.Sprintf("%d %s in %d %s", len(shard.Tests), pluralize("test", len(shard.Tests)), len(sel.Packages), pluralize("package", len(sel.Packages)))
+		if shard.Estimate > 0 {
+			counts += ", about " + FormatDurationAdaptive(shard.Estimate)
+		}
+		r.writeln("  Shard %d  %s", shard.Index, r.style.FormatBreakdownText(counts))
+		pkg := ""
+		for _, test := range shard.Tests {
+			if test.Package != pkg {
+				pkg = test.Package
+				r.writeln("    %s", pkg)
+			}
+			r.writeln("      %s", test.Test)
+		}
 	}
+}
 
-	// Suite errors
-	if len(suite.Errors) > 0 {
-		r.renderErrors(suite.Errors)
+// RenderGreenSkip shows the packages left out of a run as already proven
+// green at commit
+func (r *Renderer) RenderGreenSkip(commit string, skipped []string, remaining int) {
+	if r.mode != OutputNormal || len(skipped) == 0 {
+		return
 	}
+	if len(commit) > 12 {
+		commit = commit[:12]
+	}
+	r.writeln("%s", dimStyle.Render(fmt.Sprintf(" ✓ Skipping %d %s proven green at %s, %d left to run", len(skipped), pluralize("package", len(skipped)), commit, remaining)))
+}
 
+// CopyRepro copies the reproduction command of the first failed test of a
+// run whose name contains name, or of the first failed test when name is
+// empty, and shows it
+func (r *Renderer) CopyRepro(run *TestRun, name string) {
+	test, repro, ok := FindRepro(run, name)
+	if !ok {
+		if name == "" {
+			r.writeln("  No failed tests in the last run")
+		} else {
+			r.writeln("  No failed test matching %q in the last run", name)
+		}
+		return
+	}
+	r.writeln("  %s", repro)
+	if via, err := CopyToClipboard(r.out, repro); err != nil {
+		r.writeln("  %s", errorStyle.Render("Could not copy the command of "+test+": "+err.Error()))
+	} else {
+		r.writeln("  %s", dimStyle.Render(fmt.Sprintf("Copied the command of %s (%s)", test, via)))
+	}
+}
+
+// RenderPowerMode shows the power source and the resulting watch speed
+func (r *Renderer) RenderPowerMode(source PowerSource, parallelism int, debounce time.Duration) {
+	status := "full speed"
+	if source == PowerBattery {
+		status = fmt.Sprintf("%d %s at once, %s debounce", parallelism, pluralize("package", parallelism), FormatDurationAdaptive(debounce))
+	}
+	r.writeln("%s", dimStyle.Render(fmt.Sprintf(" ⚡ On %s: %s", source, status)))
+}
+
+// RenderWatchRestart displays a notice that the watcher restarted itself
+func (r *Renderer) RenderWatchRestart(reason string) {
+	r.writeln("\n%s", r.style.FormatErrorHeader(" WATCH RESTARTED "))
+	r.writeln(" %s\n", reason)
+}
+
+// RenderSlowTest warns that a test is still running well past its usual duration
+func (r *Renderer) RenderSlowTest(pkg, test string, elapsed, p95 time.Duration) {
+	if r.mode != OutputNormal {
+		return
+	}
+	msg := fmt.Sprintf("%s running %.0fx longer than usual (%s, p95 %s) in %s",
+		test, float64(elapsed)/float64(p95), FormatDurationAdaptive(elapsed), FormatDurationAdaptive(p95), pkg)
+	if r.style.useColors {
+		msg = warningStyle.Render(msg)
+	}
+	r.writeln(" %s %s", r.style.StatusIcon(TestStatusSkipped), msg)
+}
+
+// RenderVerboseReruns shows the verbose output of failed tests rerun alone
+func (r *Renderer) RenderVerboseReruns(reruns []*VerboseRerun) {
+	if r.mode != OutputNormal || len(reruns) == 0 {
+		return
+	}
+	r.writeln("%s", r.style.FormatHeader(" VERBOSE RERUNS "))
+	for _, rerun := range reruns {
+		status, outcome := TestStatusFailed, "failed again"
+		if rerun.Passed {
+			status, outcome = TestStatusPassed, "passed when run alone"
+		}
+		r.writeln("")
+		r.writeln(" %s %s %s", r.style.StatusIcon(status), rerun.Test, dimStyle.Render(fmt.Sprintf("(%s) %s", rerun.Package, outcome)))
+		for _, line := range strings.Split(rerun.Output, "\n") {
+			r.writeln("    %s", line)
+		}
+	}
+	r.writeln("")
+}
+
+// RenderFailureSummaries shows what the configured summarizer made of
+// each failure
+func (r *Renderer) RenderFailureSummaries(summaries []*FailureSummary) {
+	if r.mode != OutputNormal || len(summaries) == 0 {
+		return
+	}
+	r.writeln("%s", r.style.FormatHeader(" FAILURE SUMMARIES "))
+	for _, summary := range summaries {
+		r.writeln("")
+		r.writeln(" %s %s %s", r.style.StatusIcon(TestStatusFailed), summary.Test, dimStyle.Render(fmt.Sprintf("(%s)", summary.Package)))
+		if summary.Err != nil {
+			r.writeln("    %s", dimStyle.Render(fmt.Sprintf("no summary: %v", summary.Err)))
+			continue
+		}
+		for _, line := range strings.Split(summary.Summary, "\n") {
+			r.writeln("    %s", line)
+		}
+		if summary.Suggestion != "" {
+			r.writeln("")
+			for i, line := range strings.Split(summary.Suggestion, "\n") {
+				if i == 0 {
+					line = successStyle.Render("Suggestion: ") + line
+				}
+				r.writeln("    %s", line)
+			}
+		}
+	}
+	r.writeln("")
+}
+
+// RenderCoverageDeltas shows the coverage of saved files and how it changed
+func (r *Renderer) RenderCoverageDeltas(deltas []CoverageDelta) {
+	if r.mode != OutputNormal || len(deltas) == 0 {
+		return
+	}
+	for _, d := range deltas {
+		msg := fmt.Sprintf("%s: %.1f%%", d.File, d.After)
+		if d.HasBefore {
+			msg = fmt.Sprintf("%s: %.1f%% → %.1f%%", d.File, d.Before, d.After)
+		}
+		if r.style.useColors && d.HasBefore {
+			switch {
+			case d.After > d.Before:
+				msg = successStyle.Render(msg)
+			case d.After < d.Before:
+				msg = errorStyle.Render(msg)
+			}
+		}
+		r.writeln(" 📊 %s", msg)
+	}
+}
+
+// Helper functions
+
+// RenderFinalSummary renders the final test summary
+func (r *Renderer) RenderFinalSummary(run *TestRun) {
+	if r.mode == OutputQuiet {
+		r.writeln("%s", summaryLine(run))
+		return
+	}
+	// Use the consolidated summary rendering
+	r.renderSummary(run)
+}
+
+// summaryLine condenses a run into a single line for scripting
+func summaryLine(run *TestRun) string {
+	status := "PASS"
+	if run.NumFailed > 0 {
+		status = "FAIL"
+	}
+	parts := []string{fmt.Sprintf("%d passed", run.NumPassed)}
+	if run.NumFailed > 0 {
+		parts = append([]string{fmt.Sprintf("%d failed", run.NumFailed)}, parts...)
+	}
+	if run.NumSkipped > 0 {
+		parts = append(parts, fmt.Sprintf("%d skipped", run.NumSkipped))
+	}
+	return fmt.Sprintf("%s %s (%d) in %s", status, strings.Join(parts, " | "), run.NumTotal, FormatDurationAdaptive(run.Duration))
+}
+
+// RenderTestSummary is deprecated and should not be used
+func (r *Renderer) RenderTestSummary(run *TestRun) {
+	// This function is deprecated and should not be used
+	// Use RenderFinalSummary instead
+}
+
+// RenderProgress renders the current test progress
+func (r *Renderer) RenderProgress(run *TestRun) {
+	completed := run.NumPassed + run.NumFailed + run.NumSkipped
+	if run.NumTotal == 0 {
+		return
+	}
+
+	percentage := float64(completed) / float64(run.NumTotal) * 100
+	r.write("Running tests... %.0f%% (%d/%d)\n", percentage, completed, run.NumTotal)
+}
+
+// RenderSuiteSummary renders a test suite summary
+func (r *Renderer) RenderSuiteSummary(suite *TestSuite) {
+	// Only show summary for suites with failures
+	if suite.NumFailed == 0 {
+		return
+	}
+
+	r.writeln("Suite")
+	r.writeln("  %s", suite.FilePath)
+	r.writeln("  Total: %d", suite.NumTotal)
+	r.writeln("  Passed: %d", suite.NumPassed)
+	r.writeln("  Failed: %d", suite.NumFailed)
+	r.writeln("  Skipped: %d", suite.NumSkipped)
+	r.writeln("  Time: %s", FormatDurationPrecise(suite.Duration))
+	r.writeln("")
+}
+
+// RenderGenerateFailure renders a failed generation step the same way as a build failure
+func (r *Renderer) RenderGenerateFailure(err *GenerateError) {
+	r.writeln("")
+	r.writeln(r.style.FormatErrorHeader(fmt.Sprintf(" GENERATE FAILED: %s ", err.Step.Name)))
+	r.writeln("  %s", r.style.FormatBreakdownText("$ "+strings.Join(err.Step.Run, " ")))
+	r.writeln("")
+
+	output := err.Output
+	if strings.TrimSpace(output) == "" {
+		output = err.Err.Error()
+	}
+	r.renderError(&TestError{
+		Message:  output,
+		Location: NewParser().extractSourceLocation(output),
+	}, 1)
+	r.writeln("")
+}
+
+// RenderHook renders a hook that ran: collapsed to one line with the size
+// of its output when it succeeded, and with its output in full, like a
+// build failure, when it failed
+func (r *Renderer) RenderHook(result *HookResult) {
+	if result.Err == nil {
+		if r.mode != OutputNormal {
+			return
+		}
+		line := fmt.Sprintf(" ✓ %s hook %s  %s", result.Phase, result.Hook.label(), FormatDurationPrecise(result.Duration))
+		if n := countLines(result.Output); n > 0 {
+			line += fmt.Sprintf("  (%d %s of output)", n, pluralize("line", n))
+		}
+		r.writeln("%s", dimStyle.Render(line))
+		return
+	}
+
+	r.writeln("")
+	r.writeln(r.style.FormatErrorHeader(fmt.Sprintf(" %s HOOK FAILED: %s ", strings.ToUpper(result.Phase), result.Hook.label())))
+	r.writeln("  %s", r.style.FormatBreakdownText("$ "+strings.Join(result.Hook.Run, " ")))
+	r.writeln("")
+	output := strings.TrimRight(result.Output, "\n")
+	if strings.TrimSpace(output) == "" {
+		output = result.Err.Error()
+	}
+	for _, line := range strings.Split(output, "\n") {
+		r.writeln("    %s", line)
+	}
+	r.writeln("")
+}
+
+// countLines returns the number of lines of output, counting a last line
+// without a newline
+func countLines(output string) int {
+	output = strings.TrimRight(output, "\n")
+	if strings.TrimSpace(output) == "" {
+		return 0
+	}
+	return strings.Count(output, "\n") + 1
+}
+
+// RenderMatrix renders a compact per-package table of matrix run results
+func (r *Renderer) RenderMatrix(results []*MatrixResult) {
+	r.writeln("%s", r.style.FormatHeader(" MATRIX "))
+	r.writeln("")
+
+	// Collect packages in order of first appearance and align labels
+	var packages []string
+	seen := make(map[string]bool)
+	width := 0
+	for _, result := range results {
+		if len(result.Cell.Label) > width {
+			width = len(result.Cell.Label)
+		}
+		if result.Run == nil {
+			continue
+		}
+		for _, suite := range result.Run.Suites {
+			if !seen[suite.Package] {
+				seen[suite.Package] = true
+				packages = append(packages, suite.Package)
+			}
+		}
+	}
+
+	for _, pkg := range packages {
+		r.writeln("  %s", pkg)
+		for _, result := range results {
+			var suite *TestSuite
+			if result.Run != nil {
+				for _, s := range result.Run.Suites {
+					if s.Package == pkg {
+						suite = s
+						break
+					}
+				}
+			}
+			if suite == nil {
+				r.writeln("    %-*s  %s", width, result.Cell.Label, r.style.FormatBreakdownText("not run"))
+				continue
+			}
+
+			status := TestStatusPassed
+			if suite.NumFailed > 0 {
+				status = TestStatusFailed
+			} else if suite.NumTotal > 0 && suite.NumSkipped == suite.NumTotal {
+				status = TestStatusSkipped
+			}
+			counts := fmt.Sprintf("%d passed", suite.NumPassed)
+			if suite.NumFailed > 0 {
+				counts = fmt.Sprintf("%d failed | %s", suite.NumFailed, counts)
+			}
+			if suite.NumSkipped > 0 {
+				counts += fmt.Sprintf(" | %d skipped", suite.NumSkipped)
+			}
+			r.writeln("    %-*s  %s %s  %s", width, result.Cell.Label, r.style.StatusIcon(status), counts,
+				r.style.FormatBreakdownText(FormatDurationPrecise(suite.Duration)))
+		}
+		r.writeln("")
+	}
+
+	// Combinations that produced no parseable results at all
+	for _, result := range results {
+		if result.Run == nil && result.Err != nil {
+			msg := strings.TrimSpace(result.Err.Error())
+			if idx := strings.Index(msg, "\n"); idx > 0 {
+				msg = msg[:idx]
+			}
+			r.writeln("  %s %s: %s", r.style.StatusIcon(TestStatusFailed), result.Cell.Label, r.style.FormatErrorMessage(msg))
+		}
+	}
+}
+
+// RenderHistory renders stored runs, one per line, with bookmarks marked
+func (r *Renderer) RenderHistory(records []*HistoryRecord) {
+	r.writeln("%s", r.style.FormatHeader(" HISTORY "))
+	if len(records) == 0 {
+		r.writeln("  No runs recorded yet")
+		r.writeln("")
+		return
+	}
+
+	for _, rec := range records {
+		mark := " "
+		if rec.Bookmarked {
+			mark = "★"
+		}
+		status := "pass"
+		if rec.NumFailed > 0 {
+			status = "FAIL"
+		}
+		if rec.Trigger == TriggerRestart {
+			status = "-"
+		}
+		line := fmt.Sprintf("%s %-24s  %s  %-7s  %-4s  %3d/%-3d  %-8s",
+			mark, rec.ID, r.inZone(rec.StartTime).Format(dateTimeLayout), rec.Trigger,
+			status, rec.NumPassed, rec.NumTotal, FormatDurationAdaptive(rec.Duration))
+		if rec.RepeatCount > 0 {
+			line += fmt.Sprintf("  ×%d", rec.Runs())
+		}
+		if rec.Label != "" {
+			line += fmt.Sprintf("  [%s]", rec.Label)
+		}
+		r.writeln("%s", strings.TrimRight(line, " "))
+		if rec.Note != "" {
+			r.writeln("  %s", dimStyle.Render(rec.Note))
+		}
+		if fingerprints := rec.Fingerprints(); len(fingerprints) > 0 {
+			r.writeln("  %s", dimStyle.Render("failures: "+strings.Join(fingerprints, " ")))
+		}
+		if over := rec.OverBudget(); len(over) > 0 {
+			var pkgs []string
+			for _, pkg := range over {
+				pkgs = append(pkgs, fmt.Sprintf("%s (+%s)", pkg.Package, FormatDurationAdaptive(pkg.Duration-pkg.Budget)))
+			}
+			r.writeln("  %s", dimStyle.Render("over budget: "+strings.Join(pkgs, ", ")))
+		}
+	}
+	r.writeln("")
+}
+
+// RenderTrends renders the pass rate and mean duration of each bucket of
+// runs, oldest first
+func (r *Renderer) RenderTrends(points []*TrendPoint, pkg string) {
+	header := " TRENDS "
+	if pkg != "" {
+		header = " TRENDS " + pkg + " "
+	}
+	r.writeln("%s", r.style.FormatHeader(header))
+	r.writeln("")
+	if len(points) == 0 {
+		r.writeln("  No runs recorded yet")
+		r.writeln("")
+		return
+	}
+
+	for _, p := range points {
+		r.writeln("  %s  %4d %-4s  %s  %s",
+			r.inZone(p.Start).Format(dateTimeLayout), p.Runs, pluralize("run", p.Runs),
+			r.coverageBar(p.PassRate*100), FormatDurationAdaptive(p.MeanDuration))
+	}
+	r.writeln("")
+}
+
+// RenderParallelProgress shows the outcome of one level of a
+// parallel-safety analysis as it completes
+func (r *Renderer) RenderParallelProgress(pkg string, parallel int, passed bool) {
+	status := TestStatusPassed
+	if !passed {
+		status = TestStatusFailed
+	}
+	r.writeln(" %s %s %s", r.style.StatusIcon(status), pkg, dimStyle.Render(fmt.Sprintf("-parallel %d", parallel)))
+}
+
+// RenderParallelAnalysis renders the outcome of a parallel-safety
+// analysis, with the packages that only pass serially or fail even then
+// listed last
+func (r *Renderer) RenderParallelAnalysis(recs []*ParallelRecommendation) {
+	r.writeln("")
+	r.writeln("%s", r.style.FormatHeader(" PARALLEL SAFETY "))
+	r.writeln("")
+	if len(recs) == 0 {
+		r.writeln("  No packages with tests")
+		r.writeln("")
+		return
+	}
+	var sorted []*ParallelRecommendation
+	for _, outcome := range []string{ParallelSafe, ParallelLimited, ParallelSerialOnly, ParallelFailing} {
+		for _, rec := range recs {
+			if rec.Outcome == outcome {
+				sorted = append(sorted, rec)
+			}
+		}
+	}
+	for _, rec := range sorted {
+		var verdict string
+		switch rec.Outcome {
+		case ParallelSafe:
+			verdict = successStyle.Render("safe at any -parallel tried")
+		case ParallelLimited:
+			verdict = warningStyle.Render(fmt.Sprintf("safe up to -parallel %d", rec.Parallel))
+		case ParallelSerialOnly:
+			verdict = errorStyle.Render("only passes serially")
+		case ParallelFailing:
+			verdict = errorStyle.Render("fails even serially")
+		}
+		r.writeln("  %-50s %s", rec.Package, verdict)
+		if rec.Failure != "" {
+			r.writeln("  %-50s %s", "", dimStyle.Render(fmt.Sprintf("at -parallel %d: %s", rec.FailedAt, rec.Failure)))
+		}
+	}
+	r.writeln("")
+}
+
+// RenderMutantProgress renders the outcome of one mutant as it is tested
+func (r *Renderer) RenderMutantProgress(m *Mutant) {
+	var status TestStatus
+	switch m.Outcome {
+	case MutantKilled, MutantTimedOut:
+		status = TestStatusPassed
+	case MutantSurvived:
+		status = TestStatusFailed
+	default:
+		status = TestStatusSkipped
+	}
+	r.writeln(" %s %s:%d %s %s", r.style.StatusIcon(status), m.File, m.Line, m.Description, dimStyle.Render(m.Outcome))
+}
+
+// RenderMutationReport renders the outcome of a mutation testing run: the
+// counts, the mutation score, and the surviving mutants with their
+// positions
+func (r *Renderer) RenderMutationReport(report *MutationReport, dryRun bool) {
+	r.writeln("")
+	r.writeln("%s", r.style.FormatHeader(" MUTATION TESTING "))
+	r.writeln("")
+	if len(report.Mutants) == 0 {
+		r.writeln("  No mutants")
+		r.writeln("")
+		return
+	}
+	if dryRun {
+		for _, m := range report.Mutants {
+			tested := dimStyle.Render("no tests")
+			if len(m.Tested) > 0 {
+				tested = dimStyle.Render("tested by " + strings.Join(m.Tested, ", "))
+			}
+			r.writeln("  %s:%d:%d %s %s", m.File, m.Line, m.Column, m.Description, tested)
+		}
+		r.writeln("")
+		r.writeln("  %d mutants", len(report.Mutants))
+		r.writeln("")
+		return
+	}
+
+	if survivors := report.Survivors(); len(survivors) > 0 {
+		r.writeln("  %s", errorStyle.Render("Surviving mutants"))
+		for _, m := range survivors {
+			r.writeln("    %s:%d:%d %s %s", m.File, m.Line, m.Column, m.Description, dimStyle.Render(m.Operator))
+		}
+		r.writeln("")
+	}
+	r.writeln("  Killed        %d", report.Count(MutantKilled)+report.Count(MutantTimedOut))
+	r.writeln("  Survived      %d", report.Count(MutantSurvived))
+	r.writeln("  Uncompilable  %d", report.Count(MutantUncompilable))
+	r.writeln("  Uncovered     %d", report.Count(MutantUncovered))
+	score := fmt.Sprintf("%.1f%%", report.Score()*100)
+	if report.Count(MutantSurvived) == 0 {
+		score = successStyle.Render(score)
+	} else {
+		score = warningStyle.Render(score)
+	}
+	r.writeln("  Score         %s", score)
+	r.writeln("")
+}
+
+// RenderBenchmarks renders the benchmark results of a run, per package
+func (r *Renderer) RenderBenchmarks(run *TestRun) {
+	if r.mode != OutputNormal {
+		return
+	}
+	header := false
+	for _, suite := range run.Suites {
+		if len(suite.Benchmarks) == 0 {
+			continue
+		}
+		if !header {
+			r.writeln("%s", r.style.FormatHeader(" BENCHMARKS "))
+			header = true
+		}
+		r.writeln("")
+		r.writeln(" %s", suite.Package)
+		for _, b := range suite.Benchmarks {
+			r.writeln("   %-40s %14s ns/op %10d B/op %8d allocs/op", b.Name, formatMetric(b.NsPerOp), b.BytesPerOp, b.AllocsPerOp)
+		}
+	}
+	if header {
+		r.writeln("")
+	}
+}
+
+// RenderBenchmarkTrends renders each benchmark's latest ns/op and allocs/op
+// with their change from the median of up to window earlier runs and a
+// sparkline of its ns/op history
+func (r *Renderer) RenderBenchmarkTrends(series []*BenchmarkSeries, window int) {
+	r.writeln("%s", r.style.FormatHeader(" BENCHMARK TRENDS "))
+	r.writeln("")
+	if len(series) == 0 {
+		r.writeln("  No benchmark results recorded yet; run with --bench to record them")
+		r.writeln("")
+		return
+	}
+
+	pkg := ""
+	for _, s := range series {
+		if s.Package != pkg {
+			pkg = s.Package
+			r.writeln(" %s", pkg)
+		}
+		latest := s.Points[len(s.Points)-1]
+		values := make([]float64, len(s.Points))
+		for i, p := range s.Points {
+			values[i] = p.NsPerOp
+		}
+		r.writeln("   %-40s %14s ns/op %s %8d allocs/op %s  %s", s.Name,
+			formatMetric(latest.NsPerOp), r.benchmarkChange(s.Change(MetricNsPerOp, window)),
+			latest.AllocsPerOp, r.benchmarkChange(s.Change(MetricAllocsPerOp, window)),
+			sparkline(values))
+	}
+	r.writeln("")
+}
+
+// benchmarkChange formats a relative change of a benchmark, red when it
+// got slower and green when faster
+func (r *Renderer) benchmarkChange(change float64) string {
+	text := fmt.Sprintf("%+7.1f%%", change*100)
+	if !r.style.useColors {
+		return text
+	}
+	switch {
+	case change > 0:
+		return errorStyle.Render(text)
+	case change < 0:
+		return successStyle.Render(text)
+	}
+	return dimStyle.Render(text)
+}
+
+// sparkline draws values as a line of block characters, scaled between
+// their minimum and maximum
+func sparkline(values []float64) string {
+	const blocks = "▁▂▃▄▅▆▇█"
+	levels := []rune(blocks)
+	if len(values) == 0 {
+		return ""
+	}
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		lo, hi = min(lo, v), max(hi, v)
+	}
+	var b strings.Builder
+	for _, v := range values {
+		level := 0
+		if hi > lo {
+			level = int((v - lo) / (hi - lo) * float64(len(levels)-1))
+		}
+		b.WriteRune(levels[level])
+	}
+	return b.String()
+}
+
+// coverageBarWidth is the number of cells in a coverage bar
+const coverageBarWidth = 20
+
+// RenderCoverageReport renders the coverage of each package and its files
+// with bars, and the uncovered lines of each file
+func (r *Renderer) RenderCoverageReport(report *CoverageReport) {
+	r.writeln("%s", r.style.FormatHeader(" COVERAGE "))
+	if len(report.Packages) == 0 {
+		r.writeln("  No coverage collected")
+		r.writeln("")
+		return
+	}
+	for _, pkg := range report.Packages {
+		r.writeln("")
+		r.writeln(" %s  %s", r.coverageBar(pkg.Percent()), pkg.ImportPath)
+		for _, file := range pkg.Files {
+			line := fmt.Sprintf("   %s  %s", r.coverageBar(file.Percent()), path.Base(file.Name))
+			if len(file.Uncovered) > 0 {
+				ranges := make([]string, len(file.Uncovered))
+				for i, lr := range file.Uncovered {
+					ranges[i] = lr.String()
+				}
+				line += "  " + dimStyle.Render("uncovered: "+strings.Join(ranges, ", "))
+			}
+			r.writeln("%s", line)
+		}
+	}
+	covered, statements := report.Total()
+	r.writeln("")
+	r.writeln("%s", r.style.FormatCount("Coverage", fmt.Sprintf("%.1f%% (%d of %d statements)", coveragePercent(covered, statements), covered, statements)))
+	r.writeln("")
+}
+
+// RenderFileCoverage renders a file's source with its uncovered lines
+// marked
+func (r *Renderer) RenderFileCoverage(file *FileCoverage, source []byte) {
+	r.writeln("%s", r.style.FormatHeader(" "+file.Name+" "))
+	r.writeln(" %s", r.coverageBar(file.Percent()))
+	r.writeln("")
+	lines := strings.Split(strings.TrimRight(string(source), "\n"), "\n")
+	next := 0
+	for i, text := range lines {
+		n := i + 1
+		text = strings.ReplaceAll(text, "\t", "    ") // Styled and plain lines must indent alike
+		for next < len(file.Uncovered) && file.Uncovered[next].End < n {
+			next++
+		}
+		if next < len(file.Uncovered) && file.Uncovered[next].Contains(n) {
+			r.writeln(" %s", errorStyle.Render(fmt.Sprintf("%4d ✗ %s", n, text)))
+			continue
+		}
+		r.writeln(" %s %s", dimStyle.Render(fmt.Sprintf("%4d │", n)), text)
+	}
 	r.writeln("")
 }
 
+// coverageBar formats a percentage with a bar, colored by how well
+// covered it is
+func (r *Renderer) coverageBar(pct float64) string {
+	filled := int(pct/100*coverageBarWidth + 0.5)
+	bar := strings.Repeat("█", filled) + strings.Repeat("░", coverageBarWidth-filled)
+	text := fmt.Sprintf("%5.1f%% %s", pct, bar)
+	if !r.style.useColors {
+		return text
+	}
+	switch {
+	case pct >= 80:
+		return successStyle.Render(text)
+	case pct >= 50:
+		return warningStyle.Render(text)
+	}
+	return errorStyle.Render(text)
+}
+
+// RenderCatalog renders each package's doc comment and its tests with the
+// first line of their doc comments
+func (r *Renderer) RenderCatalog(catalogs []*PackageCatalog) {
+	r.writeln("%s", r.style.FormatHeader(" CATALOG "))
+	if len(catalogs) == 0 {
+		r.writeln("  No packages with tests")
+		r.writeln("")
+		return
+	}
+
+	for _, catalog := range catalogs {
+		r.writeln("")
+		r.writeln("  %s", catalog.ImportPath)
+		for _, line := range strings.Split(catalog.Doc, "\n") {
+			if line != "" {
+				r.writeln("    %s", dimStyle.Render(line))
+			}
+		}
+
+		width := 0
+		for _, test := range catalog.Tests {
+			if len(test.Name) > width {
+				width = len(test.Name)
+			}
+		}
+		r.writeln("")
+		for _, test := range catalog.Tests {
+			line := fmt.Sprintf("    %-*s  %s", width, test.Name, test.Summary)
+			r.writeln("%s", strings.TrimRight(line, " "))
+		}
+	}
+	r.writeln("")
+}
+
+// RenderInventory renders the test functions of each package with the
+// build constraint, owners, and subtests of each
+func (r *Renderer) RenderInventory(inv *Inventory) {
+	r.writeln("%s", r.style.FormatHeader(" INVENTORY "))
+	if len(inv.Packages) == 0 {
+		r.writeln("  No packages with tests")
+		r.writeln("")
+		return
+	}
+
+	for _, pkg := range inv.Packages {
+		r.writeln("")
+		line := "  " + pkg.ImportPath
+		if len(pkg.Owners) > 0 {
+			line += "  " + dimStyle.Render(strings.Join(pkg.Owners, " "))
+		}
+		r.writeln("%s", line)
+
+		// Subtests are shown under their test, named relative to it
+		width := 0
+		for _, test := range pkg.Tests {
+			width = max(width, len(test.Name))
+			for _, sub := range test.Subtests {
+				width = max(width, len(sub.Name)-len(test.Name)+1) // Two spaces deeper, less the slash
+			}
+		}
+		for _, test := range pkg.Tests {
+			details := []string{fmt.Sprintf("%s:%d", test.File, test.Line)}
+			if test.Kind != InventoryKindTest {
+				details = append(details, test.Kind)
+			}
+			if test.Constraint != "" {
+				details = append(details, "//go:build "+test.Constraint)
+			}
+			if len(test.Owners) > 0 && strings.Join(test.Owners, " ") != strings.Join(pkg.Owners, " ") {
+				details = append(details, strings.Join(test.Owners, " "))
+			}
+			r.writeln("    %-*s  %s", width, test.Name, dimStyle.Render(strings.Join(details, "  ")))
+			for _, sub := range test.Subtests {
+				r.writeln("      %-*s  %s", width-2, strings.TrimPrefix(sub.Name, test.Name+"/"), dimStyle.Render(fmt.Sprintf("line %d", sub.Line)))
+			}
+		}
+	}
+
+	r.writeln("")
+	tests, benchmarks := inv.Count(InventoryKindTest), inv.Count(InventoryKindBenchmark)
+	fuzz, examples := inv.Count(InventoryKindFuzz), inv.Count(InventoryKindExample)
+	r.writeln("  %d %s, %d %s, %d fuzz %s, %d %s", tests, pluralize("test", tests), benchmarks, pluralize("benchmark", benchmarks),
+		fuzz, pluralize("target", fuzz), examples, pluralize("example", examples))
+	r.writeln("")
+}
+
+// RenderFailureIssues renders failures tracked across runs, one issue per
+// underlying cause
+func (r *Renderer) RenderFailureIssues(issues []*FailureIssue) {
+	r.writeln("%s", r.style.FormatHeader(" FAILURES "))
+	if len(issues) == 0 {
+		r.writeln("  No failures recorded")
+		r.writeln("")
+		return
+	}
+
+	for _, issue := range issues {
+		line := fmt.Sprintf("  %s  ×%-3d %s %s", issue.Fingerprint, issue.Occurrences, issue.Package, issue.Test)
+		if len(issue.Branches) > 0 {
+			line += fmt.Sprintf("  [%s]", strings.Join(issue.Branches, ", "))
+		}
+		r.writeln("%s", line)
+		if issue.Summary != "" {
+			r.writeln("    %s", r.style.FormatErrorMessage(issue.Summary))
+		}
+		r.writeln("    %s", dimStyle.Render(fmt.Sprintf("first %s, last %s",
+			r.inZone(issue.FirstSeen).Format(dateTimeLayout), r.inZone(issue.LastSeen).Format(dateTimeLayout))))
+		for _, env := range issue.Envs {
+			r.writeln("    %s", dimStyle.Render("env: "+env))
+		}
+		runs := issue.Runs
+		more := ""
+		if len(runs) > 3 {
+			more = fmt.Sprintf(" (+%d more)", len(runs)-3)
+			runs = runs[:3]
+		}
+		r.writeln("    %s", dimStyle.Render("runs: "+strings.Join(runs, " ")+more))
+	}
+	r.writeln("")
+}
+
+// RenderTraceability renders the traceability matrix of a recorded run:
+// each requirement, whether its tests all passed, and their outcomes
+func (r *Renderer) RenderTraceability(rec *HistoryRecord, entries []*TraceEntry) {
+	r.writeln("%s", r.style.FormatHeader(" TRACEABILITY "))
+	r.writeln("  %s", dimStyle.Render(fmt.Sprintf("run %s, %s", rec.ID, r.inZone(rec.StartTime).Format(dateTimeLayout))))
+	if len(entries) == 0 {
+		r.writeln("  No tests trace to requirements")
+		r.writeln("")
+		return
+	}
+
+	verified := 0
+	for _, entry := range entries {
+		status := TestStatusFailed
+		if entry.Verified() {
+			status = TestStatusPassed
+			verified++
+		}
+		r.writeln("")
+		r.writeln(" %s %s", r.style.StatusIcon(status), entry.Requirement)
+		for _, test := range entry.Tests {
+			r.writeln("    %s %s %s", r.style.StatusIcon(test.Status), test.Test, dimStyle.Render(fmt.Sprintf("(%s)", test.Package)))
+		}
+	}
+	r.writeln("")
+	r.writeln("%s", r.style.FormatCount("Verified", fmt.Sprintf("%d of %d requirements", verified, len(entries))))
+	r.writeln("")
+}
+
+// RenderSchedule renders how packages were selected, ordered, and assigned to workers
+func (r *Renderer) RenderSchedule(schedule *Schedule) {
+	r.writeln("%s", r.style.FormatHeader(" SCHEDULE "))
+	r.writeln("  go test -p %d | %d %s | %d cached",
+		schedule.Parallelism, len(schedule.Entries), pluralize("package", len(schedule.Entries)), schedule.NumCached())
+	r.writeln("")
+
+	// Align the package column to the longest package name
+	width := len("Package")
+	for _, entry := range schedule.Entries {
+		if len(entry.Package) > width {
+			width = len(entry.Package)
+		}
+	}
+
+	r.writeln("  %3s  %-6s  %-*s  %-5s  %-8s  %s", "#", "Worker", width, "Package", "Cache", "Duration", "Reason")
+	for _, entry := range schedule.Entries {
+		order, worker, duration := "-", "-", "-"
+		if entry.Order > 0 {
+			order = strconv.Itoa(entry.Order)
+			worker = strconv.Itoa(entry.Worker)
+			duration = FormatDurationPrecise(entry.Duration)
+		}
+		cache := "miss"
+		if entry.Cached {
+			cache = "hit"
+		}
+		reason := string(entry.Reason)
+		if entry.Detail != "" {
+			reason = fmt.Sprintf("%s (%s)", reason, entry.Detail)
+		}
+		r.writeln("  %3s  %-6s  %-*s  %-5s  %-8s  %s", order, worker, width, entry.Package, cache, duration, reason)
+	}
+	r.writeln("")
+}
+
+// RenderSuite renders a test suite
+func (r *Renderer) RenderSuite(suite *TestSuite) {
+	if r.mode != OutputNormal {
+		return
+	}
+
+	// Print suite header
+	header := r.style.FormatHeader(fmt.Sprintf(" %s ", suite.Package))
+	counts := r.style.FormatBreakdownText(formatTestCounts(suite))
+	if suite.Cached {
+		counts += " " + dimStyle.Render("(cached)")
+	}
+	if suite.TimedOut() {
+		counts += " " + r.style.FormatErrorHeader(" TIMEOUT ")
+	}
+
+	// In CI logs that fold groups, passing packages are folded under their
+	// header so the failures stand out
+	folded := githubLogGroups() && suite.NumFailed == 0 && !suite.SetupFailed && len(suite.BuildErrors) == 0 && len(suite.Errors) == 0
+	prefix := ""
+	if folded {
+		prefix = "::group::"
+		defer r.writeln("::endgroup::")
+	}
+	if _, err := fmt.Fprintf(r.out, "%s%s %s\n", prefix, header, counts); err != nil {
+		log.Printf("Error writing suite header: %v", err)
+	}
+
+	// Test results, leaving out failures beyond the cap so thousands of
+	// them do not flood the terminal
+	if hidden := r.renderTestTree(BuildTestTree(suite.Tests), suite.Package, 1); hidden > 0 {
+		r.writeln("%s", dimStyle.Render(fmt.Sprintf("  … %s more failed %s not shown", formatThousands(hidden), pluralize("test", hidden))))
+	}
+
+	// Suite errors, or everything a package printed when it failed before
+	// any test ran, since its FAIL line alone says nothing
+	if len(suite.BuildErrors) > 0 {
+		r.renderBuildErrors(suite.BuildErrors)
+	} else if suite.SetupFailed {
+		r.renderSetupFailure(suite)
+	} else if len(suite.Errors) > 0 {
+		r.renderErrors(suite.Errors)
+	}
+	if suite.NumFailed > 0 {
+		r.renderAnnotations(suite.Annotations, 1)
+	}
+
+	r.writeln("")
+}
+
+// renderBuildErrors renders the compiler errors of a package that did not
+// build, grouped by file, each with its source like a test failure
+func (r *Renderer) renderBuildErrors(errs []*BuildError) {
+	groups := GroupBuildErrors(errs)
+	r.writeln("%s", errorStyle.Render(fmt.Sprintf("  ✗ Build failed: %d %s in %d %s",
+		len(errs), pluralize("error", len(errs)), len(groups), pluralize("file", len(groups)))))
+	for _, group := range groups {
+		r.writeln("")
+		r.writeln("%s", r.style.FormatFailedSuite(group.File))
+		for _, e := range group.Errors {
+			r.renderError(&TestError{Message: e.Message, Location: e.Location}, 2)
+		}
+	}
+}
+
+// renderSetupFailure renders the output of a package that failed before
+// any test ran, as when TestMain panics, exits, or times out
+func (r *Renderer) renderSetupFailure(suite *TestSuite) {
+	r.writeln("%s", errorStyle.Render("  ✗ "+setupFailureReason(suite)))
+	for _, line := range strings.Split(strings.TrimRight(suite.Output, "\n"), "\n") {
+		if strings.TrimSpace(line) == "" || isFramingLine(strings.TrimSpace(line)) {
+			continue
+		}
+		r.writeln("%s", dimStyle.Render("    "+line))
+	}
+}
+
 // RenderTest renders a test result
 func (r *Renderer) RenderTest(test *TestResult, indent string) {
 	// Print test name
@@ -2,6 +2,7 @@ package cli
 
 import (
 	"bytes"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -295,3 +296,110 @@ func TestRenderer_RenderSuiteSummary(t *testing.T) {
 		t.Errorf("Output should be empty for suite with no failures: %q", output)
 	}
 }
+
+func TestRenderer_OutputModes(t *testing.T) {
+	suite := &TestSuite{
+		Package:   "pkg/foo",
+		Tests:     []*TestResult{{Name: "TestA", Status: TestStatusPassed}, {Name: "TestB", Status: TestStatusFailed}},
+		NumTotal:  2,
+		NumPassed: 1,
+		NumFailed: 1,
+	}
+	run := &TestRun{
+		Suites:    []*TestSuite{suite},
+		NumTotal:  2,
+		NumPassed: 1,
+		NumFailed: 1,
+		Duration:  1500 * time.Millisecond,
+	}
+
+	tests := []struct {
+		mode    OutputMode
+		want    string
+		notWant string
+	}{
+		{OutputNormal, "Test Files", ""},
+		{OutputSummaryOnly, "Test Files", "pkg/foo"},
+		{OutputQuiet, "FAIL 1 failed | 1 passed (2) in 1.50s\n", ""},
+		{OutputSilent, "", ""},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		r := NewRendererWithStyle(&buf, false)
+		r.SetOutputMode(tt.mode)
+
+		r.RenderTestStart(run)
+		r.RenderSuite(suite)
+		r.RenderFinalSummary(run)
+
+		got := buf.String()
+		switch tt.mode {
+		case OutputQuiet, OutputSilent:
+			if got != tt.want {
+				t.Errorf("mode %d: output = %q, want %q", tt.mode, got, tt.want)
+			}
+		default:
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("mode %d: output missing %q:\n%s", tt.mode, tt.want, got)
+			}
+			if tt.notWant != "" && strings.Contains(got, tt.notWant) {
+				t.Errorf("mode %d: output should not contain %q:\n%s", tt.mode, tt.notWant, got)
+			}
+		}
+	}
+}
+
+func TestRenderer_Timezone(t *testing.T) {
+	tokyo, err := LoadTimezone("Asia/Tokyo")
+	if err != nil {
+		t.Skipf("Time zone database unavailable: %v", err)
+	}
+	start := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+
+	var buf bytes.Buffer
+	renderer := NewRendererWithStyle(&buf, false)
+	renderer.SetLocation(tokyo)
+	renderer.RenderHistory([]*HistoryRecord{{ID: "run-1", Trigger: TriggerRun, StartTime: start}})
+	if !strings.Contains(buf.String(), "2024-03-01 21:30 +09:00") {
+		t.Errorf("History should show the start in the configured zone, got:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	renderer.SetLocation(time.UTC)
+	renderer.RenderFinalSummary(&TestRun{StartTime: start, EndTime: start.Add(time.Second)})
+	if !strings.Contains(buf.String(), "12:30:00 +00:00") {
+		t.Errorf("Summary should show the start in UTC with its offset, got:\n%s", buf.String())
+	}
+}
+
+func TestRenderer_MaxFailures(t *testing.T) {
+	suite := &TestSuite{Package: "example.com/fixture", FilePath: "fixture_test.go"}
+	for i := 0; i < 1205; i++ {
+		suite.Tests = append(suite.Tests, &TestResult{Name: "TestCase" + strconv.Itoa(i), Status: TestStatusFailed, Error: &TestError{Message: "fixture broken"}})
+	}
+	suite.NumFailed = len(suite.Tests)
+	run := &TestRun{Suites: []*TestSuite{suite}, NumFailed: suite.NumFailed, NumTotal: suite.NumFailed}
+
+	var buf bytes.Buffer
+	renderer := NewRendererWithStyle(&buf, false)
+	renderer.SetMaxFailures(5, "press 'l' and Enter to list them all")
+	renderer.RenderTestStart(run)
+	renderer.RenderSuite(suite)
+	renderer.RenderFinalSummary(run)
+
+	out := buf.String()
+	if strings.Contains(out, "TestCase5") {
+		t.Errorf("Failures beyond the cap should not be rendered:\n%s", out)
+	}
+	for _, want := range []string{"… 1,200 more failed tests not shown", "and 1,200 more failed tests — press 'l' and Enter to list them all"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Output missing %q:\n%s", want, out)
+		}
+	}
+
+	buf.Reset()
+	renderer.RenderFailedTests(run)
+	if !strings.Contains(buf.String(), "TestCase1204") || strings.Contains(buf.String(), "more failed") {
+		t.Errorf("RenderFailedTests should list every failure:\n%s", buf.String())
+	}
+}
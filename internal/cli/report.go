@@ -0,0 +1,235 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// junitTimestamp is the timestamp layout of the JUnit schema, which has no
+// time zone
+const junitTimestamp = "2006-01-02T15:04:05"
+
+// junitPackageFailure names the test case reporting a package that failed
+// outside any test, as when it does not build or TestMain exits early
+const junitPackageFailure = "[package failed]"
+
+// junitReport is the <testsuites> root written by WriteJUnitReport. The
+// import side reads reports with the looser junitSuites.
+type junitReport struct {
+	XMLName   xml.Name           `xml:"testsuites"`
+	Name      string             `xml:"name,attr"`
+	Tests     int                `xml:"tests,attr"`
+	Failures  int                `xml:"failures,attr"`
+	Errors    int                `xml:"errors,attr"`
+	Skipped   int                `xml:"skipped,attr"`
+	Time      string             `xml:"time,attr"`
+	Timestamp string             `xml:"timestamp,attr,omitempty"`
+	Suites    []junitReportSuite `xml:"testsuite"`
+}
+
+// junitReportSuite is a <testsuite> element for one package
+type junitReportSuite struct {
+	Name       string            `xml:"name,attr"`
+	Tests      int               `xml:"tests,attr"`
+	Failures   int               `xml:"failures,attr"`
+	Errors     int               `xml:"errors,attr"`
+	Skipped    int               `xml:"skipped,attr"`
+	Time       string            `xml:"time,attr"`
+	Timestamp  string            `xml:"timestamp,attr,omitempty"`
+	Properties []junitProperty   `xml:"properties>property,omitempty"`
+	Cases      []junitReportCase `xml:"testcase"`
+}
+
+// junitProperty is a <property> of a suite
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// junitReportCase is a <testcase> element
+type junitReportCase struct {
+	Name      string       `xml:"name,attr"`
+	Classname string       `xml:"classname,attr"`
+	Time      string       `xml:"time,attr"`
+	File      string       `xml:"file,attr,omitempty"`
+	Line      int          `xml:"line,attr,omitempty"`
+	Failure   *junitOutput `xml:"failure,omitempty"`
+	Error     *junitOutput `xml:"error,omitempty"`
+	Skipped   *junitOutput `xml:"skipped,omitempty"`
+}
+
+// junitOutput is a <failure>, <error>, or <skipped> element, with the test
+// output kept readable in a CDATA section
+type junitOutput struct {
+	Message string `xml:"message,attr,omitempty"`
+	Text    string `xml:",cdata"`
+}
+
+// WriteJUnitReport writes run as JUnit XML, with a suite per package and a
+// test case per test and subtest, so CI systems can annotate pipelines
+// with the results
+func WriteJUnitReport(w io.Writer, run *TestRun) error {
+	report := junitReport{Name: "go-sentinel", Time: junitSeconds(run.Duration)}
+	if !run.StartTime.IsZero() {
+		report.Timestamp = run.StartTime.Format(junitTimestamp)
+	}
+	for _, suite := range run.Suites {
+		s := junitSuiteOf(run, suite)
+		report.Tests += s.Tests
+		report.Failures += s.Failures
+		report.Errors += s.Errors
+		report.Skipped += s.Skipped
+		report.Suites = append(report.Suites, s)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("failed to write JUnit report: %w", err)
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return fmt.Errorf("failed to write JUnit report: %w", err)
+	}
+	if _, err := io.WriteString(w, "\n"); err != nil {
+		return fmt.Errorf("failed to write JUnit report: %w", err)
+	}
+	return nil
+}
+
+// WriteJUnitFile writes run as a JUnit XML report at path, creating its
+// directory when needed. The report replaces any previous one at once, so
+// a CI step reading it never sees a partial file.
+func WriteJUnitFile(path string, run *TestRun) error {
+	var buf bytes.Buffer
+	if err := WriteJUnitReport(&buf, run); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create report directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write JUnit report: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write JUnit report: %w", err)
+	}
+	return nil
+}
+
+// junitSuiteOf converts one package's results. Counts are taken from the
+// tests themselves, since the suite's own counters also include
+// package-level failure lines.
+func junitSuiteOf(run *TestRun, suite *TestSuite) junitReportSuite {
+	pkg := suiteName(suite)
+	s := junitReportSuite{Name: pkg, Time: junitSeconds(suite.Duration)}
+	if !suite.StartTime.IsZero() {
+		s.Timestamp = suite.StartTime.Format(junitTimestamp)
+	}
+	if run.ID != "" {
+		s.Properties = append(s.Properties, junitProperty{Name: "sentinel.run", Value: run.ID})
+	}
+	if run.Seed != 0 {
+		s.Properties = append(s.Properties, junitProperty{Name: "sentinel.seed", Value: strconv.FormatInt(run.Seed, 10)})
+	}
+	if run.Env != nil {
+		s.Properties = append(s.Properties, junitProperty{Name: "sentinel.env", Value: run.Env.String()})
+		names := make([]string, 0, len(run.Env.Vars))
+		for name := range run.Env.Vars {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			s.Properties = append(s.Properties, junitProperty{Name: "sentinel.env." + name, Value: run.Env.Vars[name]})
+		}
+	}
+
+	failedTests := false
+	for _, test := range suite.Tests {
+		tc := junitReportCase{Name: test.Name, Classname: pkg, Time: junitSeconds(test.Duration)}
+		if test.Error != nil && test.Error.Location != nil {
+			tc.File = test.Error.Location.File
+			tc.Line = test.Error.Location.Line
+		}
+		switch test.Status {
+		case TestStatusFailed:
+			failedTests = true
+			s.Failures++
+			tc.Failure = junitFailureOf(test)
+		case TestStatusSkipped:
+			s.Skipped++
+			tc.Skipped = &junitOutput{}
+		}
+		s.Tests++
+		s.Cases = append(s.Cases, tc)
+	}
+
+	// A package can fail without any failing test; report it as an error
+	// so the pipeline does not show the package as green
+	if !failedTests && len(suite.Errors) > 0 {
+		text := packageFailureOutput(suite)
+		s.Tests++
+		s.Errors++
+		s.Cases = append(s.Cases, junitReportCase{
+			Name:      junitPackageFailure,
+			Classname: pkg,
+			Time:      junitSeconds(0),
+			Error:     &junitOutput{Message: failureSummary(text), Text: text},
+		})
+	}
+	return s
+}
+
+// packageFailureOutput returns the output explaining a package that failed
+// outside any test: everything it printed when TestMain failed during
+// setup, or its FAIL lines otherwise
+func packageFailureOutput(suite *TestSuite) string {
+	if suite.SetupFailed && strings.TrimSpace(suite.Output) != "" {
+		return strings.TrimRight(suite.Output, "\n")
+	}
+	var output []string
+	for _, e := range suite.Errors {
+		output = append(output, strings.TrimRight(e.Message, "\n"))
+	}
+	return strings.Join(output, "\n")
+}
+
+// suiteName returns the import path of a suite's package, falling back to
+// the short name when the path is unknown
+func suiteName(suite *TestSuite) string {
+	if suite.PackageName != "" {
+		return suite.PackageName
+	}
+	return suite.Package
+}
+
+// junitFailureOf describes a failed test, with its first descriptive line
+// as the message and its full output as the text
+func junitFailureOf(test *TestResult) *junitOutput {
+	if test.Error == nil {
+		return &junitOutput{Message: "Failed"}
+	}
+	text := strings.TrimRight(test.Error.Message, "\n")
+	if test.Error.Expected != "" || test.Error.Actual != "" {
+		text += fmt.Sprintf("\nexpected: %s\nactual: %s", test.Error.Expected, test.Error.Actual)
+	}
+	message := failureSummary(test.Error.Message)
+	if message == "" {
+		message = "Failed"
+	}
+	return &junitOutput{Message: message, Text: text}
+}
+
+// junitSeconds formats a duration as the decimal seconds JUnit expects
+func junitSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
@@ -0,0 +1,178 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteJUnitReport_RoundTrip(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	run := &TestRun{
+		ID:        "run-1",
+		Seed:      42,
+		StartTime: start,
+		Duration:  1500 * time.Millisecond,
+		Suites: []*TestSuite{
+			{
+				Package:     "calc",
+				PackageName: "example.com/calc",
+				StartTime:   start,
+				Duration:    time.Second,
+				Tests: []*TestResult{
+					{Name: "TestAdd", Status: TestStatusPassed, Duration: 250 * time.Millisecond},
+					{Name: "TestDiv", Status: TestStatusFailed, Duration: 10 * time.Millisecond, Error: &TestError{
+						Message:  "=== RUN   TestDiv\n    calc_test.go:12: want 2, got 3\n--- FAIL: TestDiv (0.01s)\n",
+						Location: &SourceLocation{File: "calc_test.go", Line: 12},
+					}},
+					{Name: "TestDiv/zero", Status: TestStatusSkipped},
+				},
+			},
+			{
+				Package:     "broken",
+				PackageName: "example.com/broken",
+				Errors:      []*TestError{{Message: "FAIL\texample.com/broken [build failed]\n"}},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteJUnitReport(&buf, run); err != nil {
+		t.Fatalf("WriteJUnitReport failed: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`<testsuites name="go-sentinel" tests="4" failures="1" errors="1" skipped="1" time="1.500" timestamp="2024-03-01T12:00:00">`,
+		`<property name="sentinel.seed" value="42"></property>`,
+		`<testcase name="TestDiv" classname="example.com/calc" time="0.010" file="calc_test.go" line="12">`,
+		`<failure message="calc_test.go:12: want 2, got 3">`,
+		`<testcase name="[package failed]" classname="example.com/broken"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report missing %q:\n%s", want, out)
+		}
+	}
+
+	parsed, err := parseJUnit(buf.Bytes())
+	if err != nil {
+		t.Fatalf("report does not parse back: %v", err)
+	}
+	if parsed.NumTotal != 4 || parsed.NumPassed != 1 || parsed.NumFailed != 2 || parsed.NumSkipped != 1 {
+		t.Errorf("round trip counts = %d total, %d passed, %d failed, %d skipped", parsed.NumTotal, parsed.NumPassed, parsed.NumFailed, parsed.NumSkipped)
+	}
+	if got := parsed.Suites[0].Tests[1].Error.Message; !strings.Contains(got, "want 2, got 3") {
+		t.Errorf("failure output lost in round trip: %q", got)
+	}
+}
+
+func TestWriteJUnitFile_CreatesDirectory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reports", "junit.xml")
+	run := &TestRun{Suites: []*TestSuite{{Package: "calc", Tests: []*TestResult{{Name: "TestAdd", Status: TestStatusPassed}}}}}
+
+	if err := WriteJUnitFile(path, run); err != nil {
+		t.Fatalf("WriteJUnitFile failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("report not written: %v", err)
+	}
+	if !strings.HasPrefix(string(data), "<?xml") {
+		t.Errorf("report has no XML header:\n%s", data)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind")
+	}
+}
+
+func TestWriteHTMLReport(t *testing.T) {
+	run := &TestRun{
+		Duration: 2 * time.Second,
+		Suites: []*TestSuite{
+			{Package: "example.com/calc", Tests: []*TestResult{
+				{Name: "TestAdd", Status: TestStatusPassed},
+				{Name: "TestDiv", Status: TestStatusFailed, Error: &TestError{Message: "calc_test.go:9: got <nil>\n"}},
+			}},
+			{Package: "example.com/build", Errors: []*TestError{{Message: "FAIL\texample.com/build [build failed]\n"}}},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteHTMLReport(&buf, run); err != nil {
+		t.Fatalf("WriteHTMLReport failed: %v", err)
+	}
+	page := buf.String()
+	for _, want := range []string{
+		`<span class="failed">1 failed</span>`,
+		`<td class="failed">failed</td>`,
+		"calc_test.go:9: got &lt;nil&gt;",
+		`<h2 class="failed">example.com/build</h2>`,
+		junitPackageFailure,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("report missing %q:\n%s", want, page)
+		}
+	}
+}
+
+func TestWriteHTMLSite(t *testing.T) {
+	workDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workDir, "a.go"), []byte("package a\n\nfunc A() int {\n\treturn b\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run := &TestRun{
+		Duration: 2 * time.Second,
+		Suites: []*TestSuite{
+			{Package: "example.com/calc", Duration: time.Second, Tests: []*TestResult{
+				{Name: "TestAdd", Status: TestStatusPassed, Duration: time.Second},
+				{Name: "TestSub", Status: TestStatusPassed, Duration: time.Millisecond},
+			}},
+			{Package: "example.com/a", BuildErrors: []*BuildError{
+				{Location: &SourceLocation{File: "./a.go", Line: 4, Column: 9}, Message: "undefined: b"},
+			}},
+		},
+	}
+	coverage, err := ReadCoverageReport(strings.NewReader("mode: set\nexample.com/calc/calc.go:3.20,5.2 1 1\nexample.com/calc/calc.go:7.20,9.2 1 0\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "report")
+	if err := WriteHTMLSite(dir, workDir, run, coverage); err != nil {
+		t.Fatalf("WriteHTMLSite failed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := string(data)
+	for _, want := range []string{
+		"Package durations",
+		"Slowest tests",
+		"50.0% coverage",
+		`href="coverage/1.html"`,
+		"at ./a.go:4",
+		`<tr class="hl"><td class="num">4</td><td>	return b</td></tr>`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("report missing %q:\n%s", want, page)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "coverage", "1.html")); err != nil {
+		t.Errorf("coverage page not written: %v", err)
+	}
+}
+
+func TestCoverageLines(t *testing.T) {
+	file := &FileCoverage{CoveredAt: []LineRange{{Start: 2, End: 3}}, Uncovered: []LineRange{{Start: 3, End: 4}}}
+	lines := coverageLines([]string{"a", "b", "c", "d", "e"}, file)
+	var classes []string
+	for _, line := range lines {
+		classes = append(classes, line.Class)
+	}
+	if got := strings.Join(classes, ","); got != ",cov,part,unc," {
+		t.Errorf("classes = %s, want ,cov,part,unc,", got)
+	}
+}
@@ -0,0 +1,136 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Reporter formats, as named by --reporter
+const (
+	ReporterJSONStream = "json-stream"
+	ReporterJUnit      = "junit"
+	ReporterSARIF      = "sarif"
+	ReporterGitHub     = "github"
+)
+
+// ReporterSpec names one extra output of a run: a format and the file it
+// is written to, empty for standard output
+type ReporterSpec struct {
+	Format string
+	Path   string
+}
+
+// ParseReporterSpec parses format[=path]. JUnit and SARIF reports need a
+// path; the JSON stream and GitHub annotations default to standard output.
+func ParseReporterSpec(spec string) (ReporterSpec, error) {
+	format, path, _ := strings.Cut(spec, "=")
+	s := ReporterSpec{Format: strings.TrimSpace(format), Path: strings.TrimSpace(path)}
+	switch s.Format {
+	case ReporterJSONStream, ReporterGitHub:
+	case ReporterJUnit, ReporterSARIF:
+		if s.Path == "" {
+			return s, fmt.Errorf("%s reporter needs a path, as in %s=report.xml", s.Format, s.Format)
+		}
+	default:
+		return s, fmt.Errorf("unknown format %q, want %s, %s, %s, or %s", s.Format, ReporterJSONStream, ReporterJUnit, ReporterSARIF, ReporterGitHub)
+	}
+	return s, nil
+}
+
+// Reporters delivers the events of each run to several reporters at once,
+// so one run can be followed in the terminal while its JSON stream, JUnit
+// and SARIF reports, and GitHub annotations are written, instead of
+// rerunning the tests for each format. Streams write as events arrive;
+// reports are written when the run's summary arrives.
+type Reporters struct {
+	handlers []EventHandler
+	files    []*os.File
+}
+
+// NewReporters opens the outputs of specs. Outputs without a path go to
+// stdout; paths of the reports are relative to the working directory, and
+// failures in them are resolved against root.
+func NewReporters(specs []ReporterSpec, root string, stdout io.Writer) (*Reporters, error) {
+	r := &Reporters{}
+	for _, spec := range specs {
+		switch spec.Format {
+		case ReporterJUnit:
+			r.handlers = append(r.handlers, reportWriter{spec.Format, func(event RunEvent) error {
+				return WriteJUnitFile(spec.Path, event.Run)
+			}})
+		case ReporterSARIF:
+			r.handlers = append(r.handlers, reportWriter{spec.Format, func(event RunEvent) error {
+				return WriteSARIFFile(spec.Path, event.Run, root, event.Dirs)
+			}})
+		default:
+			w := stdout
+			if spec.Path != "" {
+				f, err := r.create(spec.Path)
+				if err != nil {
+					r.Close()
+					return nil, err
+				}
+				w = f
+			}
+			if spec.Format == ReporterJSONStream {
+				r.handlers = append(r.handlers, NewJSONStream(w))
+				continue
+			}
+			r.handlers = append(r.handlers, reportWriter{spec.Format, func(event RunEvent) error {
+				return WriteGitHubAnnotations(w, event.Run, root, event.Dirs)
+			}})
+		}
+	}
+	return r, nil
+}
+
+// create opens a streamed output, replacing any previous one
+func (r *Reporters) create(path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create report directory: %w", err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create report: %w", err)
+	}
+	r.files = append(r.files, f)
+	return f, nil
+}
+
+// HandleEvent delivers one event to every reporter
+func (r *Reporters) HandleEvent(event RunEvent) {
+	for _, handler := range r.handlers {
+		handler.HandleEvent(event)
+	}
+}
+
+// Close closes the files the reporters stream to
+func (r *Reporters) Close() error {
+	var errs []error
+	for _, f := range r.files {
+		errs = append(errs, f.Close())
+	}
+	r.files = nil
+	return errors.Join(errs...)
+}
+
+// reportWriter writes a report of each run when its summary arrives
+type reportWriter struct {
+	format string
+	write  func(event RunEvent) error
+}
+
+// HandleEvent writes the report of a finished run
+func (w reportWriter) HandleEvent(event RunEvent) {
+	if event.Type != EventRunSummary {
+		return
+	}
+	if err := w.write(event); err != nil {
+		log.Printf("Error writing %s report: %v", w.format, err)
+	}
+}
@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseReporterSpec(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    ReporterSpec
+		wantErr bool
+	}{
+		{spec: "json-stream", want: ReporterSpec{Format: ReporterJSONStream}},
+		{spec: "junit=out/report.xml", want: ReporterSpec{Format: ReporterJUnit, Path: "out/report.xml"}},
+		{spec: "github", want: ReporterSpec{Format: ReporterGitHub}},
+		{spec: "sarif", wantErr: true},
+		{spec: "tap=out.tap", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseReporterSpec(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseReporterSpec(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("ParseReporterSpec(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestReporters(t *testing.T) {
+	root := t.TempDir()
+	events := strings.Join([]string{
+		`{"Action":"start","Package":"example.com/app/api"}`,
+		`{"Action":"run","Package":"example.com/app/api","Test":"TestLogin"}`,
+		`{"Action":"output","Package":"example.com/app/api","Test":"TestLogin","Output":"    login_test.go:21: status = 500, want 200\n"}`,
+		`{"Action":"fail","Package":"example.com/app/api","Test":"TestLogin"}`,
+		`{"Action":"fail","Package":"example.com/app/api"}`,
+	}, "\n")
+	run, err := NewParser().Parse(strings.NewReader(events))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	specs := []ReporterSpec{
+		{Format: ReporterJSONStream, Path: filepath.Join(root, "out", "events.jsonl")},
+		{Format: ReporterJUnit, Path: filepath.Join(root, "out", "junit.xml")},
+		{Format: ReporterSARIF, Path: filepath.Join(root, "out", "report.sarif")},
+		{Format: ReporterGitHub},
+	}
+	var stdout bytes.Buffer
+	reporters, err := NewReporters(specs, root, &stdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pipeline := EventPipeline{reporters}
+	pipeline.emit(RunEvent{Type: EventRunStart})
+	pipeline.emitResults(run)
+	pipeline.emit(RunEvent{Type: EventRunSummary, Run: run, Dirs: map[string]string{"example.com/app/api": filepath.Join(root, "api")}})
+	if err := reporters.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	stream, err := os.ReadFile(specs[0].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(stream), "\n"); lines != 5 || !strings.Contains(string(stream), `"type":"test_fail"`) {
+		t.Errorf("JSON stream has %d events:\n%s", lines, stream)
+	}
+	for _, spec := range specs[1:3] {
+		if data, err := os.ReadFile(spec.Path); err != nil || !bytes.Contains(data, []byte("TestLogin")) {
+			t.Errorf("%s report = %q, %v, want the failure", spec.Format, data, err)
+		}
+	}
+	want := "::error file=api/login_test.go,line=21,title=A test reported a failed check::TestLogin failed: login_test.go:21: status = 500, want 200\n"
+	if stdout.String() != want {
+		t.Errorf("GitHub annotations = %q, want %q", stdout.String(), want)
+	}
+}
+
+func TestGitHubEscaping(t *testing.T) {
+	if got := githubData("50% done\nnext"); got != "50%25 done%0Anext" {
+		t.Errorf("githubData() = %q", got)
+	}
+	if got := githubProperty("a:b,c"); got != "a%3Ab%2Cc" {
+		t.Errorf("githubProperty() = %q", got)
+	}
+}
@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"log"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ReproCommand returns the shell command rerunning one test exactly as the
+// run did, from the directory go-sentinel ran in: it changes to the
+// package's directory and runs the test alone, uncached, with the run's
+// build flags
+func ReproCommand(relDir, test string, opts RunOptions) string {
+	args := []string{shellQuote(goBinary), "test", "-run", shellQuote(ReproRunPattern(test)), "-count=1"}
+	for _, flag := range opts.BuildFlags {
+		args = append(args, shellQuote(flag))
+	}
+	if opts.Shuffle && opts.Seed != 0 {
+		args = append(args, "-shuffle="+strconv.FormatInt(opts.Seed, 10))
+	}
+	command := strings.Join(args, " ")
+	if relDir == "" || relDir == "." {
+		return command
+	}
+	return "cd " + shellQuote(filepath.ToSlash(relDir)) + " && " + command
+}
+
+// ReproRunPattern returns the -run pattern matching exactly one test or
+// subtest: each level of the name anchored and with its regular
+// expression characters escaped
+func ReproRunPattern(test string) string {
+	levels := strings.Split(test, "/")
+	for i, level := range levels {
+		levels[i] = "^" + regexp.QuoteMeta(level) + "$"
+	}
+	return strings.Join(levels, "/")
+}
+
+// shellSafe matches the words a POSIX shell reads literally
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// shellQuote quotes a word for a POSIX shell, leaving it unquoted when
+// nothing in it is special
+func shellQuote(s string) string {
+	if shellSafe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// annotateRepros sets the reproduction command of each failed test of a
+// run
+func (r *Runner) annotateRepros(run *TestRun, opts RunOptions) {
+	if run.NumFailed == 0 {
+		return
+	}
+	pkgs, err := r.pkgCache.Get(opts.Packages)
+	if err != nil {
+		log.Printf("Error resolving reproduction commands: %v", err)
+		return
+	}
+	dirs := make(map[string]string, len(pkgs))
+	for _, pkg := range pkgs {
+		if rel, err := filepath.Rel(r.workDir, pkg.Dir); err == nil {
+			dirs[pkg.ImportPath] = rel
+		} else {
+			dirs[pkg.ImportPath] = pkg.Dir
+		}
+	}
+	for _, suite := range run.Suites {
+		dir, ok := dirs[suite.Package]
+		if !ok {
+			continue
+		}
+		for _, test := range suite.Tests {
+			if test.Status == TestStatusFailed && test.Name != "" {
+				test.Repro = ReproCommand(dir, test.Name, opts)
+			}
+		}
+	}
+}
+
+// FindRepro returns the reproduction command of the first failed test of
+// a run whose name contains name, or of the first failed test when name
+// is empty
+func FindRepro(run *TestRun, name string) (test, repro string, ok bool) {
+	if run == nil {
+		return "", "", false
+	}
+	for _, suite := range run.Suites {
+		for _, t := range suite.Tests {
+			if t.Repro != "" && strings.Contains(t.Name, name) {
+				return t.Name, t.Repro, true
+			}
+		}
+	}
+	return "", "", false
+}
@@ -0,0 +1,43 @@
+package cli
+
+import "testing"
+
+func TestReproCommand(t *testing.T) {
+	for _, tt := range []struct {
+		dir, test string
+		opts      RunOptions
+		want      string
+	}{
+		{"internal/db", "TestQuery", RunOptions{}, "cd internal/db && go test -run '^TestQuery$' -count=1"},
+		{".", "TestParse/empty_input", RunOptions{BuildFlags: []string{"-race", "-tags=integration e2e"}},
+			"go test -run '^TestParse$/^empty_input$' -count=1 -race '-tags=integration e2e'"},
+		{"pkg", "TestSum/1+1=2", RunOptions{Shuffle: true, Seed: 42}, "cd pkg && go test -run '^TestSum$/^1\\+1=2$' -count=1 -shuffle=42"},
+		{"it's", "TestX", RunOptions{}, `cd 'it'\''s' && go test -run '^TestX$' -count=1`},
+	} {
+		if got := ReproCommand(tt.dir, tt.test, tt.opts); got != tt.want {
+			t.Errorf("ReproCommand(%q, %q) = %s, want %s", tt.dir, tt.test, got, tt.want)
+		}
+	}
+}
+
+func TestFindRepro(t *testing.T) {
+	run := &TestRun{Suites: []*TestSuite{
+		{Package: "example.com/a", Tests: []*TestResult{{Name: "TestOK", Status: TestStatusPassed}}},
+		{Package: "example.com/b", Tests: []*TestResult{
+			{Name: "TestOne", Status: TestStatusFailed, Repro: "go test -run '^TestOne$' -count=1"},
+			{Name: "TestTwo", Status: TestStatusFailed, Repro: "go test -run '^TestTwo$' -count=1"},
+		}},
+	}}
+	if test, _, ok := FindRepro(run, ""); !ok || test != "TestOne" {
+		t.Errorf("FindRepro() = %q, %v; want the first failure", test, ok)
+	}
+	if test, repro, ok := FindRepro(run, "Two"); !ok || test != "TestTwo" || repro != "go test -run '^TestTwo$' -count=1" {
+		t.Errorf("FindRepro(Two) = %q, %q, %v", test, repro, ok)
+	}
+	if _, _, ok := FindRepro(run, "TestOK"); ok {
+		t.Error("FindRepro found a passing test")
+	}
+	if _, _, ok := FindRepro(nil, ""); ok {
+		t.Error("FindRepro found a failure without a run")
+	}
+}
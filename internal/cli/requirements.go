@@ -0,0 +1,220 @@
+package cli
+
+import (
+	"encoding/csv"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// requirementPrefix introduces a requirement annotation in a test's doc
+// comment or body, e.g. // sentinel:req=JIRA-123,JIRA-124
+const requirementPrefix = "sentinel:req="
+
+// TestRequirements maps package import paths to their top-level tests and
+// the requirements each one traces to
+type TestRequirements map[string]map[string][]string
+
+// ScanRequirements reads the requirement annotations of the tests in pkgs
+func ScanRequirements(pkgs []*PackageInfo) (TestRequirements, error) {
+	reqs := make(TestRequirements)
+	for _, pkg := range pkgs {
+		fset := token.NewFileSet()
+		files := append(append([]string{}, pkg.TestGoFiles...), pkg.XTestGoFiles...)
+		for _, name := range files {
+			f, err := parser.ParseFile(fset, filepath.Join(pkg.Dir, name), nil, parser.ParseComments|parser.SkipObjectResolution)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+			}
+			for _, decl := range f.Decls {
+				fn, ok := decl.(*ast.FuncDecl)
+				if !ok || fn.Recv != nil || !isRunnableTest(fn.Name.Name) {
+					continue
+				}
+				var found []string
+				for _, group := range f.Comments {
+					if group == fn.Doc || (group.Pos() >= fn.Pos() && group.End() <= fn.End()) {
+						found = append(found, requirementsIn(group)...)
+					}
+				}
+				if len(found) == 0 {
+					continue
+				}
+				if reqs[pkg.ImportPath] == nil {
+					reqs[pkg.ImportPath] = make(map[string][]string)
+				}
+				reqs[pkg.ImportPath][fn.Name.Name] = mergeTags(reqs[pkg.ImportPath][fn.Name.Name], found)
+			}
+		}
+	}
+	return reqs, nil
+}
+
+// requirementsIn returns the requirements annotated in a comment group
+func requirementsIn(group *ast.CommentGroup) []string {
+	var reqs []string
+	for _, c := range group.List {
+		text := strings.TrimSpace(strings.TrimPrefix(c.Text, "//"))
+		if !strings.HasPrefix(text, requirementPrefix) {
+			continue
+		}
+		for _, req := range strings.FieldsFunc(text[len(requirementPrefix):], func(r rune) bool { return r == ',' || r == ' ' }) {
+			reqs = append(reqs, req)
+		}
+	}
+	return reqs
+}
+
+// Select returns the packages and top-level tests tracing to any of reqs,
+// or nil when none do
+func (t TestRequirements) Select(reqs []string) *TestSelection {
+	sel := &TestSelection{}
+	for importPath, tests := range t {
+		matched := false
+		for test, testReqs := range tests {
+			for _, req := range reqs {
+				if containsString(testReqs, req) {
+					sel.Tests = append(sel.Tests, test)
+					matched = true
+					break
+				}
+			}
+		}
+		if matched {
+			sel.Packages = append(sel.Packages, importPath)
+		}
+	}
+	if len(sel.Packages) == 0 {
+		return nil
+	}
+	sort.Strings(sel.Packages)
+	sel.Tests = mergeTags(nil, sel.Tests)
+	return sel
+}
+
+// annotate sets the requirements of every test in run; subtests inherit
+// those of their top-level test
+func (t TestRequirements) annotate(run *TestRun) {
+	for _, suite := range run.Suites {
+		for _, test := range suite.Tests {
+			test.Requirements = t[suite.Package][topLevelTest(test.Name)]
+		}
+	}
+}
+
+// annotateRequirements stores the requirement annotations of the run's
+// tests with their results
+func (r *Runner) annotateRequirements(run *TestRun, opts RunOptions) {
+	pkgs, err := r.pkgCache.Get(opts.Packages)
+	if err != nil {
+		log.Printf("Error reading requirements: %v", err)
+		return
+	}
+	reqs, err := ScanRequirements(pkgs)
+	if err != nil {
+		log.Printf("Error reading requirements: %v", err)
+		return
+	}
+	reqs.annotate(run)
+}
+
+// selectRequirements narrows opts to the tests tracing to opts.Requirements
+func (r *Runner) selectRequirements(opts RunOptions) (RunOptions, error) {
+	pkgs, err := r.pkgCache.Get(opts.Packages)
+	if err != nil {
+		return opts, err
+	}
+	reqs, err := ScanRequirements(pkgs)
+	if err != nil {
+		return opts, err
+	}
+	sel := reqs.Select(opts.Requirements)
+	if sel == nil {
+		return opts, fmt.Errorf("no tests trace to %s", strings.Join(opts.Requirements, ", "))
+	}
+	opts.Packages = sel.Packages
+	opts.Tests = sel.RunPatterns()
+	return opts, nil
+}
+
+// TraceEntry is one row of a traceability matrix: a requirement and the
+// tests verifying it
+type TraceEntry struct {
+	Requirement string
+	Tests       []TraceTest
+}
+
+// TraceTest is a test and its outcome in the traced run
+type TraceTest struct {
+	Package string
+	Test    string
+	Status  TestStatus
+}
+
+// Verified reports whether every test of the requirement passed
+func (e *TraceEntry) Verified() bool {
+	for _, test := range e.Tests {
+		if test.Status != TestStatusPassed {
+			return false
+		}
+	}
+	return len(e.Tests) > 0
+}
+
+// TraceabilityMatrix lists the requirements traced by the top-level tests
+// of a recorded run, sorted by requirement, with the outcome of each test
+func TraceabilityMatrix(rec *HistoryRecord) []*TraceEntry {
+	byReq := make(map[string]*TraceEntry)
+	for _, pkg := range rec.Packages {
+		for _, test := range pkg.Tests {
+			if strings.Contains(test.Name, "/") {
+				continue
+			}
+			for _, req := range test.Requirements {
+				entry, ok := byReq[req]
+				if !ok {
+					entry = &TraceEntry{Requirement: req}
+					byReq[req] = entry
+				}
+				entry.Tests = append(entry.Tests, TraceTest{Package: pkg.Package, Test: test.Name, Status: test.Status})
+			}
+		}
+	}
+
+	entries := make([]*TraceEntry, 0, len(byReq))
+	for _, entry := range byReq {
+		sort.Slice(entry.Tests, func(i, j int) bool {
+			if entry.Tests[i].Package != entry.Tests[j].Package {
+				return entry.Tests[i].Package < entry.Tests[j].Package
+			}
+			return entry.Tests[i].Test < entry.Tests[j].Test
+		})
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Requirement < entries[j].Requirement })
+	return entries
+}
+
+// WriteTraceabilityCSV writes a traceability matrix as CSV, one row per
+// requirement and test, for audit records
+func WriteTraceabilityCSV(w io.Writer, rec *HistoryRecord, entries []*TraceEntry) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"requirement", "package", "test", "status", "run", "time"})
+	for _, entry := range entries {
+		for _, test := range entry.Tests {
+			cw.Write([]string{entry.Requirement, test.Package, test.Test, statusName(test.Status), rec.ID, rec.StartTime.Format(time.RFC3339)})
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("failed to write traceability matrix: %w", err)
+	}
+	return nil
+}
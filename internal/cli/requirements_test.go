@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const requirementsTestFile = `package calc
+
+import "testing"
+
+// TestAdd checks addition.
+// sentinel:req=REQ-1, REQ-2
+func TestAdd(t *testing.T) {}
+
+func TestSub(t *testing.T) {
+	// sentinel:req=REQ-2
+	t.Run("negative", func(t *testing.T) {})
+}
+
+// sentinel:req=REQ-3
+func helper() {}
+
+func TestMul(t *testing.T) {}
+`
+
+func TestScanRequirements(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "calc_test.go"), []byte(requirementsTestFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pkgs := []*PackageInfo{{ImportPath: "example.com/calc", Dir: dir, TestGoFiles: []string{"calc_test.go"}}}
+
+	reqs, err := ScanRequirements(pkgs)
+	if err != nil {
+		t.Fatalf("ScanRequirements failed: %v", err)
+	}
+	tests := reqs["example.com/calc"]
+	if got := strings.Join(tests["TestAdd"], ","); got != "REQ-1,REQ-2" {
+		t.Errorf("TestAdd requirements = %q, want REQ-1,REQ-2", got)
+	}
+	if got := strings.Join(tests["TestSub"], ","); got != "REQ-2" {
+		t.Errorf("TestSub requirements = %q, want REQ-2 from its body", got)
+	}
+	if _, ok := tests["helper"]; ok {
+		t.Error("Only tests should trace to requirements")
+	}
+	if _, ok := tests["TestMul"]; ok {
+		t.Error("TestMul has no annotation")
+	}
+
+	sel := reqs.Select([]string{"REQ-2"})
+	if sel == nil || strings.Join(sel.Tests, ",") != "TestAdd,TestSub" {
+		t.Errorf("Select(REQ-2) = %+v, want TestAdd and TestSub", sel)
+	}
+	if reqs.Select([]string{"REQ-9"}) != nil {
+		t.Error("Select should return nil when no test traces to the requirement")
+	}
+
+	run := &TestRun{Suites: []*TestSuite{{Package: "example.com/calc", Tests: []*TestResult{{Name: "TestSub/negative"}}}}}
+	reqs.annotate(run)
+	if got := run.Suites[0].Tests[0].Requirements; len(got) != 1 || got[0] != "REQ-2" {
+		t.Errorf("subtest requirements = %v, want those of TestSub", got)
+	}
+}
+
+func TestTraceabilityMatrix(t *testing.T) {
+	rec := &HistoryRecord{
+		ID:        "run-1",
+		StartTime: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		Packages: []*PackageRecord{{
+			Package: "example.com/calc",
+			Tests: []*TestRecord{
+				{Name: "TestAdd", Status: TestStatusPassed, Requirements: []string{"REQ-1", "REQ-2"}},
+				{Name: "TestSub", Status: TestStatusFailed, Requirements: []string{"REQ-2"}},
+				{Name: "TestSub/negative", Status: TestStatusFailed, Requirements: []string{"REQ-2"}},
+			},
+		}},
+	}
+
+	entries := TraceabilityMatrix(rec)
+	if len(entries) != 2 || entries[0].Requirement != "REQ-1" || entries[1].Requirement != "REQ-2" {
+		t.Fatalf("entries = %+v, want REQ-1 and REQ-2", entries)
+	}
+	if !entries[0].Verified() || entries[1].Verified() {
+		t.Error("REQ-1 should be verified and REQ-2 not, since TestSub failed")
+	}
+	if len(entries[1].Tests) != 2 {
+		t.Errorf("REQ-2 tests = %+v, want top-level tests only", entries[1].Tests)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteTraceabilityCSV(&buf, rec, entries); err != nil {
+		t.Fatalf("WriteTraceabilityCSV failed: %v", err)
+	}
+	want := "requirement,package,test,status,run,time\n" +
+		"REQ-1,example.com/calc,TestAdd,passed,run-1,2024-03-01T12:00:00Z\n" +
+		"REQ-2,example.com/calc,TestAdd,passed,run-1,2024-03-01T12:00:00Z\n" +
+		"REQ-2,example.com/calc,TestSub,failed,run-1,2024-03-01T12:00:00Z\n"
+	if buf.String() != want {
+		t.Errorf("CSV =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// maxVerboseReruns bounds the extra time spent gathering failure context
+const maxVerboseReruns = 5
+
+// VerboseRerun is the outcome of rerunning one failed test on its own
+type VerboseRerun struct {
+	Package string
+	Test    string
+	Passed  bool   // The test passed alone, hinting at order dependence or flakiness
+	Output  string // Plain go test -v output
+}
+
+// failedTopLevelTests returns the distinct top-level tests that failed in
+// run, by package, in result order
+func failedTopLevelTests(run *TestRun) [][2]string {
+	var failed [][2]string
+	seen := make(map[[2]string]bool)
+	for _, suite := range run.Suites {
+		for _, test := range suite.Tests {
+			if test.Status != TestStatusFailed || test.Name == "" {
+				continue
+			}
+			key := [2]string{suite.Package, topLevelTest(test.Name)}
+			if !seen[key] {
+				seen[key] = true
+				failed = append(failed, key)
+			}
+		}
+	}
+	return failed
+}
+
+// rerunFailures reruns each failed test alone with -v and -count=1, so
+// its full, uncached output can be shown beside the failure
+func (r *Runner) rerunFailures(run *TestRun, opts RunOptions) []*VerboseRerun {
+	failed := failedTopLevelTests(run)
+	if len(failed) > maxVerboseReruns {
+		failed = failed[:maxVerboseReruns]
+	}
+
+	var reruns []*VerboseRerun
+	for _, f := range failed {
+		args := []string{"test", "-v", "-count=1", "-run", "^" + regexp.QuoteMeta(f[1]) + "$"}
+		args = append(args, opts.BuildFlags...)
+		args = append(args, f[0])
+		cmd := exec.Command(goBinary, args...)
+		cmd.Dir = r.workDir
+		cmd.Env = opts.environ()
+
+		output, err := combinedOutput(cmd, opts.Priority, nil)
+		reruns = append(reruns, &VerboseRerun{
+			Package: f[0],
+			Test:    f[1],
+			Passed:  err == nil,
+			Output:  strings.TrimRight(string(output), "\n"),
+		})
+	}
+	return reruns
+}
@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFailedTopLevelTests(t *testing.T) {
+	run := &TestRun{Suites: []*TestSuite{
+		{Package: "example.com/a", Tests: []*TestResult{
+			{Name: "TestParse", Status: TestStatusFailed},
+			{Name: "TestParse/empty", Status: TestStatusFailed},
+			{Name: "TestParse/nested", Status: TestStatusFailed},
+			{Name: "TestOK", Status: TestStatusPassed},
+		}},
+		{Package: "example.com/b", Tests: []*TestResult{
+			{Name: "TestLoad/missing", Status: TestStatusFailed},
+			{Name: "TestParse", Status: TestStatusFailed},
+		}},
+	}}
+
+	want := [][2]string{
+		{"example.com/a", "TestParse"},
+		{"example.com/b", "TestLoad"},
+		{"example.com/b", "TestParse"},
+	}
+	if got := failedTopLevelTests(run); !reflect.DeepEqual(got, want) {
+		t.Errorf("failedTopLevelTests = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,234 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// resultCacheVersion changes whenever cached results gain fields or keys
+// change meaning, so older entries are not mistaken for current ones
+const resultCacheVersion = "v1"
+
+// ResultCache serves the results of packages that passed before with the
+// same sources, the same module dependencies, and the same test selection,
+// without running them again. It is keyed by content, like go test's own
+// cache, but also holds the tests selected by go-sentinel, so a narrowed
+// watch rerun is served as soon as it was run once.
+type ResultCache struct {
+	sourceHasher
+	dir string
+}
+
+// cachedSuite is a package result kept in the result cache
+type cachedSuite struct {
+	Version string     `json:"version"`
+	Suite   *TestSuite `json:"suite"`
+}
+
+// NewResultCache creates the result cache of the module in workDir, kept
+// with the package cache in the user cache directory
+func NewResultCache(workDir string) *ResultCache {
+	dir := defaultPackageCacheDir()
+	if dir == "" {
+		dir = filepath.Join(workDir, HistoryDir)
+	}
+	return &ResultCache{sourceHasher: newSourceHasher(workDir), dir: filepath.Join(dir, "results")}
+}
+
+// uncachedFlags are the go test flags asking for tests to be run again or
+// under other conditions, such as -count=1 to force a rerun or -count=50
+// to hunt a flaky test
+var uncachedFlags = []string{"count", "cpu", "benchtime"}
+
+// cacheable reports whether runs with opts can be served from the cache.
+// Randomized runs, benchmarks, coverage, build matrices, runs rewriting
+// fixtures or snapshots, and runs with uncachedFlags always run.
+func (c *ResultCache) cacheable(opts RunOptions) bool {
+	for _, flag := range opts.BuildFlags {
+		name, _, _ := strings.Cut(strings.TrimLeft(flag, "-"), "=")
+		if strings.HasPrefix(flag, "-") && containsString(uncachedFlags, strings.TrimPrefix(name, "test.")) {
+			return false
+		}
+	}
+	return !opts.Shuffle && opts.Seed == 0 && opts.Bench == "" && opts.Coverage == nil && len(opts.Matrix) == 0 &&
+		len(opts.Requirements) == 0 && opts.RefreshFixtures == "" && opts.UpdateSnapshots == ""
+}
+
+// Lookup splits the packages opts selects into those with cached results,
+// returned marked as cached, and the import paths of those to run.
+// Packages are listed with the run's build tags; those without test files
+// are left out of both, unless test files behind other build constraints
+// leave it unclear what the run would do.
+func (c *ResultCache) Lookup(opts RunOptions, env *EnvSnapshot) (hits []*TestSuite, misses []string, err error) {
+	pkgs, graph, err := c.packages(opts.Packages, opts.BuildFlags)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, pkg := range pkgs {
+		if !pkg.HasTests() {
+			if hasIgnoredTests(pkg) {
+				misses = append(misses, pkg.ImportPath)
+			}
+			continue
+		}
+		suite := c.load(c.key(pkg, opts, env, graph))
+		if suite == nil {
+			misses = append(misses, pkg.ImportPath)
+			continue
+		}
+		suite.Cached = true
+		hits = append(hits, suite)
+	}
+	return hits, misses, nil
+}
+
+// hasIgnoredTests reports whether build constraints exclude any of pkg's
+// test files
+func hasIgnoredTests(pkg *PackageInfo) bool {
+	for _, name := range pkg.IgnoredGoFiles {
+		if strings.HasSuffix(name, "_test.go") {
+			return true
+		}
+	}
+	return false
+}
+
+// Store caches the packages of run whose tests all passed at the first
+// attempt. Failures are never cached, and neither are flaky passes.
+func (c *ResultCache) Store(run *TestRun, opts RunOptions, env *EnvSnapshot) error {
+	suites := make(map[string]*TestSuite)
+	var paths []string
+	for _, suite := range run.Suites {
+		if cacheableSuite(suite) {
+			suites[suite.Package] = suite
+			paths = append(paths, suite.Package)
+		}
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+
+	pkgs, graph, err := c.packages(paths, opts.BuildFlags)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create result cache directory: %w", err)
+	}
+	for _, pkg := range pkgs {
+		suite, ok := suites[pkg.ImportPath]
+		if !ok {
+			continue
+		}
+		path := c.path(c.key(pkg, opts, env, graph))
+		if _, err := os.Stat(path); err == nil {
+			continue
+		}
+		data, err := json.Marshal(cachedSuite{Version: resultCacheVersion, Suite: suite})
+		if err != nil {
+			return fmt.Errorf("failed to encode cached result: %w", err)
+		}
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, data, 0600); err != nil {
+			return fmt.Errorf("failed to write cached result: %w", err)
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			os.Remove(tmp)
+			return fmt.Errorf("failed to write cached result: %w", err)
+		}
+	}
+	return nil
+}
+
+// cacheableSuite reports whether every test of suite passed or was
+// skipped at the first attempt
+func cacheableSuite(suite *TestSuite) bool {
+	if suite.NumFailed > 0 || suite.SetupFailed || suite.BuildOutput != "" || len(suite.Errors) > 0 || len(suite.Tests) == 0 {
+		return false
+	}
+	for _, test := range suite.Tests {
+		if test.PassedOnRetry || test.Status != TestStatusPassed && test.Status != TestStatusSkipped {
+			return false
+		}
+	}
+	return true
+}
+
+// key identifies a package result: the package's sources and those of the
+// module packages it depends on, the tests selected, the whole environment
+// the tests get, apart from the run context changing on every run, and the
+// go version and settings they ran with
+func (c *ResultCache) key(pkg *PackageInfo, opts RunOptions, env *EnvSnapshot, graph *DependencyGraph) string {
+	var environ []string
+	for _, kv := range opts.environ() {
+		if !isRunContextVar(kv) {
+			environ = append(environ, kv)
+		}
+	}
+	sort.Strings(environ)
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00", resultCacheVersion, pkg.ImportPath, c.sourceHash(pkg, opts.BuildFlags, graph))
+	fmt.Fprintf(h, "%s\x00%s\x00", strings.Join(opts.Tests, "|"), strings.Join(environ, "\x00"))
+	if env != nil {
+		fmt.Fprintf(h, "%s\x00%s\x00", env.GoVersion, env.VarsString())
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// path returns where the result with key is kept
+func (c *ResultCache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+// load returns the cached result with key, or nil; unreadable entries are
+// treated as missing
+func (c *ResultCache) load(key string) *TestSuite {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil
+	}
+	var entry cachedSuite
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Version != resultCacheVersion || entry.Suite == nil {
+		return nil
+	}
+	return entry.Suite
+}
+
+// executeCached serves the packages opts selects from the result cache
+// and runs only the rest
+func (r *Runner) executeCached(opts RunOptions) (*TestRun, string, error) {
+	if opts.ResultCache == nil || !opts.ResultCache.cacheable(opts) {
+		return r.execute(opts)
+	}
+	hits, misses, err := opts.ResultCache.Lookup(opts, r.envSnapshot(opts))
+	if err != nil {
+		log.Printf("Error reading result cache: %v", err)
+		return r.execute(opts)
+	}
+	if len(hits) == 0 {
+		return r.execute(opts)
+	}
+
+	var run *TestRun
+	var output string
+	if len(misses) == 0 {
+		run = NewTestRun()
+		run.ID = newRunID()
+	} else {
+		opts.Packages = misses
+		run, output, err = r.execute(opts)
+		if run == nil {
+			return nil, output, err
+		}
+	}
+	run.Suites = append(hits, run.Suites...)
+	recountRun(run)
+	return run, output, err
+}
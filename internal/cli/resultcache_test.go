@@ -0,0 +1,175 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResultCache(t *testing.T) {
+	h, dir := newTestSourceHasher(t)
+	c := &ResultCache{sourceHasher: h, dir: t.TempDir()}
+	env := &EnvSnapshot{GoVersion: "go1.24.1"}
+	opts := RunOptions{}
+
+	run := &TestRun{Suites: []*TestSuite{{Package: "example/b", NumPassed: 1, Tests: []*TestResult{
+		{Name: "TestB", Status: TestStatusPassed},
+	}}}}
+	if err := c.Store(run, opts, env); err != nil {
+		t.Fatal(err)
+	}
+
+	hits, misses, err := c.Lookup(opts, env)
+	if err != nil || len(hits) != 1 || len(misses) != 0 || !hits[0].Cached || hits[0].Tests[0].Name != "TestB" {
+		t.Fatalf("Lookup() = %v, %v, %v; want example/b served from the cache", hits, misses, err)
+	}
+	if hits, _, _ := c.Lookup(RunOptions{Tests: []string{"^TestB$"}}, env); len(hits) != 0 {
+		t.Errorf("Lookup() with other selected tests = %v, want a miss", hits)
+	}
+
+	// Any variable the tests inherit is part of the key
+	t.Setenv("SENTINEL_TEST_DATABASE_URL", "postgres://other")
+	if hits, _, _ := c.Lookup(opts, env); len(hits) != 0 {
+		t.Errorf("Lookup() after an inherited variable changed = %v, want a miss", hits)
+	}
+
+	// The tests of b import a, so an edit of a invalidates b
+	if err := os.WriteFile(filepath.Join(dir, "a", "a.go"), []byte("package a // edited\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if hits, misses, _ := c.Lookup(opts, env); len(hits) != 0 || len(misses) != 1 {
+		t.Errorf("Lookup() after an edit = %v, %v; want example/b to run", hits, misses)
+	}
+}
+
+func TestCacheableSuite(t *testing.T) {
+	passed := &TestSuite{Tests: []*TestResult{{Status: TestStatusPassed}, {Status: TestStatusSkipped}}}
+	flaky := &TestSuite{Tests: []*TestResult{{Status: TestStatusPassed, PassedOnRetry: true}}}
+	failed := &TestSuite{NumFailed: 1, Tests: []*TestResult{{Status: TestStatusFailed}}}
+	if !cacheableSuite(passed) || cacheableSuite(flaky) || cacheableSuite(failed) {
+		t.Errorf("cacheableSuite = %v, %v, %v; want only the passing suite cached",
+			cacheableSuite(passed), cacheableSuite(flaky), cacheableSuite(failed))
+	}
+}
+
+func TestResultCache_TestHelperDependencies(t *testing.T) {
+	// The tests of a import the helper tu, whose code imports b. go list
+	// leaves b out of a's Deps, since only a's tests reach it.
+	files := map[string]string{
+		"go.mod":      "module example\n",
+		"a/a.go":      "package a\n",
+		"a/a_test.go": "package a_test\n",
+		"tu/tu.go":    "package tu\n",
+		"b/b.go":      "package b\n",
+	}
+	h, dir := newTestModuleHasher(t, files, func(dir string) []*PackageInfo {
+		return []*PackageInfo{
+			{ImportPath: "example/a", Dir: filepath.Join(dir, "a"), GoFiles: []string{"a.go"},
+				XTestGoFiles: []string{"a_test.go"}, XTestImports: []string{"example/a", "example/tu"}},
+			{ImportPath: "example/b", Dir: filepath.Join(dir, "b"), GoFiles: []string{"b.go"}},
+			{ImportPath: "example/tu", Dir: filepath.Join(dir, "tu"), GoFiles: []string{"tu.go"},
+				Imports: []string{"example/b"}, Deps: []string{"example/b"}},
+		}
+	})
+	c := &ResultCache{sourceHasher: h, dir: t.TempDir()}
+	env := &EnvSnapshot{GoVersion: "go1.24.1"}
+	opts := RunOptions{Packages: []string{"./a"}}
+
+	run := &TestRun{Suites: []*TestSuite{{Package: "example/a", NumPassed: 1, Tests: []*TestResult{
+		{Name: "TestA", Status: TestStatusPassed},
+	}}}}
+	if err := c.Store(run, opts, env); err != nil {
+		t.Fatal(err)
+	}
+	if hits, _, _ := c.Lookup(opts, env); len(hits) != 1 {
+		t.Fatalf("Lookup() = %v, want example/a served from the cache", hits)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "b", "b.go"), []byte("package b // edited\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if hits, misses, _ := c.Lookup(opts, env); len(hits) != 0 || len(misses) != 1 {
+		t.Errorf("Lookup() after editing b = %v, %v; want example/a to run", hits, misses)
+	}
+}
+
+func TestResultCache_BuildTags(t *testing.T) {
+	files := map[string]string{
+		"go.mod":          "module example\n",
+		"a/a_test.go":     "package a\n",
+		"e2e/e2e_test.go": "//go:build e2e\n\npackage e2e\n",
+		"docs/doc.go":     "package docs\n",
+	}
+	var listed []string
+	h, dir := newTestModuleHasher(t, files, nil)
+	h.pkgCache.list = func(_ string, patterns []string) ([]*PackageInfo, error) {
+		listed = append(listed, patterns[0])
+		e2e := &PackageInfo{ImportPath: "example/e2e", Dir: filepath.Join(dir, "e2e"), IgnoredGoFiles: []string{"e2e_test.go"}}
+		if patterns[0] == "-tags=e2e" {
+			e2e = &PackageInfo{ImportPath: "example/e2e", Dir: filepath.Join(dir, "e2e"), TestGoFiles: []string{"e2e_test.go"}}
+		}
+		return []*PackageInfo{
+			{ImportPath: "example/a", Dir: filepath.Join(dir, "a"), TestGoFiles: []string{"a_test.go"}},
+			{ImportPath: "example/docs", Dir: filepath.Join(dir, "docs"), GoFiles: []string{"doc.go"}},
+			e2e,
+		}, nil
+	}
+	c := &ResultCache{sourceHasher: h, dir: t.TempDir()}
+	env := &EnvSnapshot{GoVersion: "go1.24.1"}
+
+	opts := RunOptions{BuildFlags: []string{"-tags", "e2e"}}
+	run := &TestRun{Suites: []*TestSuite{{Package: "example/a", NumPassed: 1, Tests: []*TestResult{{Name: "TestA", Status: TestStatusPassed}}}}}
+	if err := c.Store(run, opts, env); err != nil {
+		t.Fatal(err)
+	}
+	hits, misses, err := c.Lookup(opts, env)
+	if err != nil || len(hits) != 1 || strings.Join(misses, " ") != "example/e2e" {
+		t.Errorf("Lookup() with -tags e2e = %v, %v, %v; want example/a cached and example/e2e to run", hits, misses, err)
+	}
+	if listed[0] != "-tags=e2e" {
+		t.Errorf("packages listed with %q, want the run's tags", listed[0])
+	}
+
+	// Without the tags the tests behind them cannot be ruled out either
+	if _, misses, _ := c.Lookup(RunOptions{}, env); !strings.Contains(strings.Join(misses, " "), "example/e2e") {
+		t.Errorf("misses without tags = %v, want example/e2e", misses)
+	}
+}
+
+func TestResultCache_Cacheable(t *testing.T) {
+	c := &ResultCache{}
+	tests := []struct {
+		flags []string
+		want  bool
+	}{
+		{nil, true},
+		{[]string{"-race", "-tags", "e2e"}, true},
+		{[]string{"-count=1"}, false},
+		{[]string{"-count", "50"}, false},
+		{[]string{"--cpu=1,4"}, false},
+		{[]string{"-test.benchtime=2s"}, false},
+	}
+	for _, tt := range tests {
+		if got := c.cacheable(RunOptions{BuildFlags: tt.flags}); got != tt.want {
+			t.Errorf("cacheable(%q) = %v, want %v", tt.flags, got, tt.want)
+		}
+	}
+}
+
+func TestBuildFlagTags(t *testing.T) {
+	tests := []struct {
+		flags []string
+		want  string
+	}{
+		{nil, ""},
+		{[]string{"-race", "-tags=integration,e2e"}, "integration e2e"},
+		{[]string{"--tags", "a b"}, "a b"},
+		{[]string{"-tags=a", "-count=1", "-tags=b"}, "b"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(buildFlagTags(tt.flags), " "); got != tt.want {
+			t.Errorf("buildFlagTags(%q) = %q, want %q", tt.flags, got, tt.want)
+		}
+	}
+}
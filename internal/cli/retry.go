@@ -0,0 +1,267 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// QuarantineFileName is the list of tests that passed only on retry, kept
+// inside HistoryDir
+const QuarantineFileName = "quarantine.json"
+
+// quarantineVersion is the format version of the quarantine file
+const quarantineVersion = 1
+
+// retryTests reruns the failed top-level tests of run, package by package,
+// up to opts.Retries more times. Tests that pass on a later attempt replace
+// their failed results and are marked as passed after retry; the counts of
+// the run are updated to match.
+func (r *Runner) retryTests(run *TestRun, opts RunOptions) {
+	first := opts.Attempt
+	if first < 1 {
+		first = 1
+	}
+	retried := false
+	for attempt := first + 1; attempt <= first+opts.Retries; attempt++ {
+		failed := failedTopLevelTests(run)
+		if len(failed) == 0 {
+			break
+		}
+		retried = true
+		var pkgs []string
+		byPkg := make(map[string][]string)
+		for _, f := range failed {
+			if _, ok := byPkg[f[0]]; !ok {
+				pkgs = append(pkgs, f[0])
+			}
+			byPkg[f[0]] = append(byPkg[f[0]], f[1])
+		}
+
+		for _, pkg := range pkgs {
+			names := byPkg[pkg]
+			quoted := make([]string, len(names))
+			for i, name := range names {
+				quoted[i] = regexp.QuoteMeta(name)
+			}
+			retryOpts := opts
+			retryOpts.Packages = []string{pkg}
+			retryOpts.Tests = []string{"^(" + strings.Join(quoted, "|") + ")$"}
+			retryOpts.Attempt = attempt
+			retryOpts.Coverage = nil
+			retry, _, _ := r.execute(retryOpts)
+			if retry == nil {
+				continue
+			}
+			mergeRetry(run, retry, pkg, names)
+		}
+	}
+	if retried {
+		recountRun(run)
+	}
+}
+
+// mergeRetry replaces the results of the named top-level tests of a
+// package, and of their subtests, with those of a retry
+func mergeRetry(run, retry *TestRun, pkg string, names []string) {
+	suite := findSuite(run, pkg)
+	retried := findSuite(retry, pkg)
+	if suite == nil || retried == nil {
+		return
+	}
+	for _, name := range names {
+		var replacement []*TestResult
+		for _, test := range retried.Tests {
+			if topLevelTest(test.Name) == name {
+				replacement = append(replacement, test)
+			}
+		}
+		if len(replacement) == 0 {
+			continue // The retry did not get as far as the test
+		}
+		if top := replacement[0]; top.Name == name && top.Status == TestStatusPassed {
+			top.PassedOnRetry = true
+		}
+
+		var tests []*TestResult
+		replaced := false
+		for _, test := range suite.Tests {
+			if topLevelTest(test.Name) != name {
+				tests = append(tests, test)
+			} else if !replaced {
+				tests = append(tests, replacement...)
+				replaced = true
+			}
+		}
+		suite.Tests = tests
+	}
+}
+
+// findSuite returns the suite of a package in run, or nil
+func findSuite(run *TestRun, pkg string) *TestSuite {
+	for _, suite := range run.Suites {
+		if suite.Package == pkg {
+			return suite
+		}
+	}
+	return nil
+}
+
+// recountRun recomputes the counts and failed tests of a run from its
+// test results. Package-level failure lines only count while tests of the
+// package still fail, or when it failed to build or set up.
+func recountRun(run *TestRun) {
+	run.NumTotal, run.NumPassed, run.NumFailed, run.NumSkipped = 0, 0, 0, 0
+	run.FailedTests = nil
+	for _, suite := range run.Suites {
+		suite.NumTotal, suite.NumPassed, suite.NumFailed, suite.NumSkipped = len(suite.Tests), 0, 0, 0
+		for _, test := range suite.Tests {
+			switch test.Status {
+			case TestStatusPassed:
+				suite.NumPassed++
+			case TestStatusFailed:
+				suite.NumFailed++
+				run.FailedTests = append(run.FailedTests, test)
+			case TestStatusSkipped:
+				suite.NumSkipped++
+			}
+		}
+		if suite.NumFailed == 0 && !suite.SetupFailed && suite.BuildOutput == "" && !hasRunningTest(suite) {
+			suite.Errors = nil
+		}
+		suite.NumFailed += len(suite.Errors)
+
+		run.NumTotal += suite.NumTotal
+		run.NumPassed += suite.NumPassed
+		run.NumFailed += suite.NumFailed
+		run.NumSkipped += suite.NumSkipped
+	}
+}
+
+// hasRunningTest reports whether a test of the suite never finished, as
+// when its test binary crashed
+func hasRunningTest(suite *TestSuite) bool {
+	for _, test := range suite.Tests {
+		if test.Status == TestStatusRunning {
+			return true
+		}
+	}
+	return false
+}
+
+// RetriedTests returns the top-level tests of a run that failed and then
+// passed when rerun
+func RetriedTests(run *TestRun) []*TestResult {
+	var retried []*TestResult
+	for _, suite := range run.Suites {
+		for _, test := range suite.Tests {
+			if test.PassedOnRetry {
+				retried = append(retried, test)
+			}
+		}
+	}
+	return retried
+}
+
+// QuarantinedTest is a test that has passed only on retry
+type QuarantinedTest struct {
+	Package   string    `json:"package"`
+	Test      string    `json:"test"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+	Count     int       `json:"count"` // Runs in which the test passed only on retry
+}
+
+// Quarantine lists the tests that passed only on retry, so they can be
+// reviewed and fixed rather than trusted
+type Quarantine struct {
+	path  string
+	Tests []*QuarantinedTest
+}
+
+// quarantineFile is the on-disk form of a Quarantine
+type quarantineFile struct {
+	Version int                `json:"version"`
+	Tests   []*QuarantinedTest `json:"tests"`
+}
+
+// LoadQuarantine reads the quarantine list of the project at workDir. A
+// missing file yields an empty list.
+func LoadQuarantine(workDir string) (*Quarantine, error) {
+	q := &Quarantine{path: filepath.Join(workDir, HistoryDir, QuarantineFileName)}
+	data, err := os.ReadFile(q.path)
+	if os.IsNotExist(err) {
+		return q, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read quarantine: %w", err)
+	}
+	var file quarantineFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", QuarantineFileName, err)
+	}
+	q.Tests = file.Tests
+	return q, nil
+}
+
+// find returns the entry of a test, or nil
+func (q *Quarantine) find(pkg, test string) *QuarantinedTest {
+	for _, t := range q.Tests {
+		if t.Package == pkg && t.Test == test {
+			return t
+		}
+	}
+	return nil
+}
+
+// Record adds the tests of run that passed only on retry, returning those
+// not quarantined before
+func (q *Quarantine) Record(run *TestRun, at time.Time) []*QuarantinedTest {
+	var added []*QuarantinedTest
+	for _, test := range RetriedTests(run) {
+		if entry := q.find(test.Package, test.Name); entry != nil {
+			entry.LastSeen = at
+			entry.Count++
+			continue
+		}
+		entry := &QuarantinedTest{Package: test.Package, Test: test.Name, FirstSeen: at, LastSeen: at, Count: 1}
+		q.Tests = append(q.Tests, entry)
+		added = append(added, entry)
+	}
+	sort.Slice(q.Tests, func(i, j int) bool {
+		if q.Tests[i].Package != q.Tests[j].Package {
+			return q.Tests[i].Package < q.Tests[j].Package
+		}
+		return q.Tests[i].Test < q.Tests[j].Test
+	})
+	return added
+}
+
+// Save writes the quarantine file, replacing the previous one at once
+func (q *Quarantine) Save() error {
+	tests := q.Tests
+	if tests == nil {
+		tests = []*QuarantinedTest{}
+	}
+	data, err := json.MarshalIndent(quarantineFile{Version: quarantineVersion, Tests: tests}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode quarantine: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(q.path), 0755); err != nil {
+		return fmt.Errorf("failed to create quarantine directory: %w", err)
+	}
+	tmp := q.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write quarantine: %w", err)
+	}
+	if err := os.Rename(tmp, q.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write quarantine: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMergeRetry(t *testing.T) {
+	run := &TestRun{Suites: []*TestSuite{{
+		Package: "example.com/p",
+		Tests: []*TestResult{
+			{Name: "TestA", Package: "example.com/p", Status: TestStatusPassed},
+			{Name: "TestB", Package: "example.com/p", Status: TestStatusFailed},
+			{Name: "TestB/sub", Package: "example.com/p", Status: TestStatusFailed},
+			{Name: "TestC", Package: "example.com/p", Status: TestStatusFailed},
+		},
+		Errors: []*TestError{{Message: "FAIL\n"}},
+	}}}
+	retry := &TestRun{Suites: []*TestSuite{{
+		Package: "example.com/p",
+		Tests: []*TestResult{
+			{Name: "TestB", Package: "example.com/p", Status: TestStatusPassed, Attempts: 2},
+			{Name: "TestB/sub", Package: "example.com/p", Status: TestStatusPassed, Attempts: 2},
+		},
+	}}}
+
+	mergeRetry(run, retry, "example.com/p", []string{"TestB", "TestC"})
+	recountRun(run)
+
+	suite := run.Suites[0]
+	var names []string
+	for _, test := range suite.Tests {
+		names = append(names, test.Name)
+	}
+	if len(names) != 4 || names[1] != "TestB" || names[2] != "TestB/sub" {
+		t.Fatalf("tests = %v, want TestB and its subtest replaced in place", names)
+	}
+	if !suite.Tests[1].PassedOnRetry || suite.Tests[2].PassedOnRetry {
+		t.Error("want only the top-level test marked as passed after retry")
+	}
+	if suite.NumPassed != 3 || suite.NumFailed != 2 || run.NumFailed != 2 || len(run.FailedTests) != 1 {
+		t.Errorf("passed = %d, failed = %d (run %d, %d tests), want 3 passed and TestC plus the package line failed",
+			suite.NumPassed, suite.NumFailed, run.NumFailed, len(run.FailedTests))
+	}
+	if retried := RetriedTests(run); len(retried) != 1 || retried[0].Name != "TestB" {
+		t.Errorf("RetriedTests() = %v, want TestB", retried)
+	}
+}
+
+func TestRunner_Retries(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example\n\ngo 1.23\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// The test only passes on its second attempt
+	err := os.WriteFile(filepath.Join(dir, "flaky_test.go"), []byte(`package example
+
+import (
+	"os"
+	"testing"
+)
+
+func TestFlaky(t *testing.T) {
+	if os.Getenv("SENTINEL_ATTEMPT") == "1" {
+		t.Fatal("first attempt")
+	}
+}
+
+func TestStable(t *testing.T) {}
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runner, err := NewRunner(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer runner.Stop()
+	quarantine, err := LoadQuarantine(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := runner.RunOnce(RunOptions{Retries: 1, Quarantine: quarantine})
+	if err != nil {
+		t.Fatalf("RunOnce() error = %v, want the retried test to pass\n%s", err, output)
+	}
+	run := runner.lastRun
+	if run.NumFailed != 0 || run.NumPassed != 2 {
+		t.Errorf("failed = %d, passed = %d, want 0 and 2", run.NumFailed, run.NumPassed)
+	}
+	if retried := RetriedTests(run); len(retried) != 1 || retried[0].Name != "TestFlaky" || retried[0].Attempts != 2 {
+		t.Errorf("RetriedTests() = %+v, want TestFlaky on attempt 2", retried)
+	}
+
+	reloaded, err := LoadQuarantine(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reloaded.Tests) != 1 || reloaded.Tests[0].Package != "example" || reloaded.Tests[0].Test != "TestFlaky" {
+		t.Errorf("quarantine = %+v, want TestFlaky", reloaded.Tests)
+	}
+}
+
+func TestQuarantine_Record(t *testing.T) {
+	q, err := LoadQuarantine(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := &TestRun{Suites: []*TestSuite{{Tests: []*TestResult{
+		{Name: "TestB", Package: "example.com/p", Status: TestStatusPassed, PassedOnRetry: true},
+		{Name: "TestA", Package: "example.com/p", Status: TestStatusPassed, PassedOnRetry: true},
+	}}}}
+	first := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	if added := q.Record(run, first); len(added) != 2 {
+		t.Errorf("Record() added %d tests, want 2", len(added))
+	}
+	if added := q.Record(run, first.Add(time.Hour)); len(added) != 0 {
+		t.Errorf("Record() added %d tests again, want 0", len(added))
+	}
+	if q.Tests[0].Test != "TestA" || q.Tests[0].Count != 2 || !q.Tests[0].FirstSeen.Equal(first) || !q.Tests[0].LastSeen.Equal(first.Add(time.Hour)) {
+		t.Errorf("Tests[0] = %+v, want TestA seen twice", q.Tests[0])
+	}
+}
@@ -0,0 +1,143 @@
+package cli
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// minRootCauseFailures is how many failures must share a first error
+// before it is reported as their likely root cause
+const minRootCauseFailures = 3
+
+// maxRootCauses caps the root causes shown for a run
+const maxRootCauses = 3
+
+var (
+	// Prefixes that differ between failures with the same cause: the
+	// location of t.Errorf calls and the timestamp of log calls
+	rootCauseLocation  = regexp.MustCompile(`^[\w.-]+\.go:\d+: `)
+	rootCauseTimestamp = regexp.MustCompile(`^\d{4}/\d\d/\d\d \d\d:\d\d:\d\d(\.\d+)? `)
+)
+
+// rootCauseHints names the usual reason behind well-known errors. The
+// first matching hint wins, so specific patterns come before general ones.
+var rootCauseHints = []struct {
+	pattern *regexp.Regexp
+	hint    string
+}{
+	{regexp.MustCompile(`:5432\b.*connection refused|connection refused.*:5432\b`), "PostgreSQL is unavailable"},
+	{regexp.MustCompile(`:3306\b.*connection refused|connection refused.*:3306\b`), "MySQL is unavailable"},
+	{regexp.MustCompile(`:6379\b.*connection refused|connection refused.*:6379\b`), "Redis is unavailable"},
+	{regexp.MustCompile(`:27017\b.*connection refused|connection refused.*:27017\b`), "MongoDB is unavailable"},
+	{regexp.MustCompile(`connection refused`), "a service the tests need is not running"},
+	{regexp.MustCompile(`no such host|server misbehaving`), "DNS or network is unavailable"},
+	{regexp.MustCompile(`address already in use`), "another process holds a port the tests use"},
+	{regexp.MustCompile(`i/o timeout|context deadline exceeded|TLS handshake timeout`), "a dependency is not responding"},
+	{regexp.MustCompile(`no such file or directory`), "a fixture or file is missing"},
+	{regexp.MustCompile(`permission denied`), "the tests lack permission to a file or socket"},
+	{regexp.MustCompile(`nil pointer dereference`), "a shared value is nil, often from failed setup"},
+	{regexp.MustCompile(`(?i)docker|testcontainers`), "a test container failed to start"},
+}
+
+// RootCause is a first error shared by many failures
+type RootCause struct {
+	Error    string   // First error line of the first failure, without log timestamps
+	Hint     string   // Usual reason behind the error, empty when unknown
+	Tests    int      // Failed tests with this first error
+	Packages []string // Packages whose tests or setup failed with it
+
+	key      string
+	failures int // Failed tests and packages with this first error
+}
+
+// FindRootCauses returns the first errors shared by several failures in a
+// run, most common first. Failed tests and packages that failed outside
+// any test, as in TestMain, are grouped by their first descriptive error
+// line once the parts that vary between them are removed.
+func FindRootCauses(run *TestRun) []*RootCause {
+	causes := make(map[string]*RootCause)
+	add := func(output, pkg string, tests int) {
+		line := failureSummary(output)
+		key := rootCauseKey(line)
+		if key == "" {
+			return
+		}
+		cause := causes[key]
+		if cause == nil {
+			cause = &RootCause{Error: rootCauseTimestamp.ReplaceAllString(line, ""), Hint: rootCauseHint(line), key: key}
+			causes[key] = cause
+		}
+		cause.Tests += tests
+		cause.failures++
+		if !containsString(cause.Packages, pkg) {
+			cause.Packages = append(cause.Packages, pkg)
+		}
+	}
+
+	for _, suite := range run.Suites {
+		failedTests := false
+		for _, test := range suite.Tests {
+			if test.Status == TestStatusFailed && test.Error != nil {
+				failedTests = true
+				add(test.Error.Message, suiteName(suite), 1)
+			}
+		}
+		if !failedTests && suite.NumFailed > 0 {
+			add(suite.Output, suiteName(suite), 0)
+		}
+	}
+
+	var shared []*RootCause
+	for _, cause := range causes {
+		if cause.failures >= minRootCauseFailures {
+			shared = append(shared, cause)
+		}
+	}
+	sort.Slice(shared, func(i, j int) bool {
+		if shared[i].failures != shared[j].failures {
+			return shared[i].failures > shared[j].failures
+		}
+		return shared[i].Error < shared[j].Error
+	})
+	if len(shared) > maxRootCauses {
+		shared = shared[:maxRootCauses]
+	}
+	return shared
+}
+
+// Matches reports whether a failed test's first error is this cause
+func (c *RootCause) Matches(test *TestResult) bool {
+	return test.Error != nil && c.matchesOutput(test.Error.Message)
+}
+
+// MatchesSetup reports whether a package that failed before any test ran
+// failed with this cause
+func (c *RootCause) MatchesSetup(suite *TestSuite) bool {
+	return suite.SetupFailed && c.matchesOutput(suite.Output)
+}
+
+// matchesOutput reports whether the first error in output is this cause
+func (c *RootCause) matchesOutput(output string) bool {
+	return rootCauseKey(failureSummary(output)) == c.key
+}
+
+// rootCauseKey returns the part of a first error line shared by failures
+// with the same cause, or "" when the line says nothing
+func rootCauseKey(line string) string {
+	line = rootCauseTimestamp.ReplaceAllString(line, "")
+	line = rootCauseLocation.ReplaceAllString(line, "")
+	line = fingerprintTempDir.ReplaceAllString(line, "TMP")
+	line = fingerprintHex.ReplaceAllString(line, "0x")
+	return strings.TrimSpace(line)
+}
+
+// rootCauseHint returns the usual reason behind an error, or ""
+func rootCauseHint(line string) string {
+	for _, h := range rootCauseHints {
+		if h.pattern.MatchString(line) {
+			return h.hint
+		}
+	}
+	return ""
+}
@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFindRootCauses(t *testing.T) {
+	refused := func(file string) *TestError {
+		return &TestError{Message: "=== RUN   TestX\n    " + file + ": dial tcp 127.0.0.1:5432: connect: connection refused\n--- FAIL: TestX (0.00s)\n"}
+	}
+	run := &TestRun{NumFailed: 4, Suites: []*TestSuite{
+		{Package: "example.com/users", NumFailed: 2, Tests: []*TestResult{
+			{Name: "TestCreate", Status: TestStatusFailed, Error: refused("users_test.go:12")},
+			{Name: "TestDelete", Status: TestStatusFailed, Error: refused("users_test.go:40")},
+			{Name: "TestName", Status: TestStatusFailed, Error: &TestError{Message: "names_test.go:9: got bob, want alice\n"}},
+		}},
+		{Package: "example.com/orders", NumFailed: 1, Output: "2024/03/01 10:00:00 users_test.go:12: dial tcp 127.0.0.1:5432: connect: connection refused\nFAIL\texample.com/orders\t0.01s\n"},
+		{Package: "example.com/billing", Tests: []*TestResult{{Name: "TestPay", Status: TestStatusPassed}}},
+	}}
+
+	causes := FindRootCauses(run)
+	if len(causes) != 1 {
+		t.Fatalf("got %d root causes, want 1: %+v", len(causes), causes)
+	}
+	c := causes[0]
+	if c.Tests != 2 || len(c.Packages) != 2 || c.Hint != "PostgreSQL is unavailable" {
+		t.Errorf("root cause = %+v, want 2 tests in 2 packages with the PostgreSQL hint", c)
+	}
+	if !c.Matches(run.Suites[0].Tests[1]) || c.Matches(run.Suites[0].Tests[2]) {
+		t.Error("Matches should only accept failures with the shared first error")
+	}
+
+	// The cause is shown once and the failures refer to it
+	var buf bytes.Buffer
+	NewRendererWithStyle(&buf, false).RenderFailedTests(run)
+	out := buf.String()
+	if strings.Count(out, "connection refused") != 1 || strings.Count(out, "likely root cause 1 above") != 2 {
+		t.Errorf("Root cause should be rendered once and referenced by each failure:\n%s", out)
+	}
+	if !strings.Contains(out, "got bob, want alice") {
+		t.Errorf("Unrelated failures should keep their message:\n%s", out)
+	}
+}
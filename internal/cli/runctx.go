@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Environment variables exposing the run context to test processes
+const (
+	EnvRunID   = "SENTINEL_RUN_ID"
+	EnvShard   = "SENTINEL_SHARD"
+	EnvAttempt = "SENTINEL_ATTEMPT"
+	EnvSeed    = "SENTINEL_SEED"
+)
+
+// isRunContextVar reports whether an environment entry is one of the run
+// context variables, which differ between runs
+func isRunContextVar(kv string) bool {
+	name, _, _ := strings.Cut(kv, "=")
+	return name == EnvRunID || name == EnvShard || name == EnvAttempt || name == EnvSeed
+}
+
+// newRunID returns a unique, time-ordered identifier for a test run
+func newRunID() string {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return time.Now().UTC().Format("20060102T150405.000000000")
+	}
+	return time.Now().UTC().Format("20060102T150405") + "-" + hex.EncodeToString(suffix)
+}
+
+// NewSeed returns a random seed for a run's randomized behavior. Seeds are
+// positive so they can be passed to go test -shuffle.
+func NewSeed() int64 {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return time.Now().UnixNano()
+	}
+	return int64(binary.BigEndian.Uint64(b[:]) >> 1)
+}
+
+// runContextEnv returns the environment variables describing a run, so
+// tests and external systems can correlate resources with it. The shard is
+// reported as "index/total" with a zero-based index. The seed is only set
+// when the run has one.
+func runContextEnv(runID string, opts RunOptions) []string {
+	shardIndex, shardTotal := opts.ShardIndex, opts.ShardTotal
+	if shardTotal <= 0 {
+		shardIndex, shardTotal = 0, 1
+	}
+	attempt := opts.Attempt
+	if attempt <= 0 {
+		attempt = 1
+	}
+	env := []string{
+		EnvRunID + "=" + runID,
+		fmt.Sprintf("%s=%d/%d", EnvShard, shardIndex, shardTotal),
+		fmt.Sprintf("%s=%d", EnvAttempt, attempt),
+	}
+	if opts.Seed != 0 {
+		env = append(env, fmt.Sprintf("%s=%d", EnvSeed, opts.Seed))
+	}
+	return env
+}
@@ -2,11 +2,15 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -16,31 +20,102 @@ import (
 
 // Runner handles test execution and watch mode
 type Runner struct {
-	workDir string
-	watcher *fsnotify.Watcher
-	mu      sync.Mutex
+	workDir    string
+	watcher    *fsnotify.Watcher
+	pkgCache   *PackageCache
+	meta       *testMetadata
+	decls      *declSnapshots      // Declarations of the watched Go files as last seen, for narrowing reruns
+	ignore     []string            // Globs of files and directories watch mode ignores
+	generate   []GenerateStep      // Generation steps whose inputs watch mode also watches
+	lastRun    *TestRun            // Results of the most recent run, for watch mode commands
+	editedArgs map[string][]string // Arguments last typed for "edit & rerun", by package
+	mu         sync.Mutex
+
+	goVersion     string    // Version of the go command tests run with, for environment snapshots
+	goVersionOnce sync.Once // Guards goVersion, looked up on the first run
 }
 
 // RunOptions configures how tests are run
 type RunOptions struct {
-	OnlyFailed bool      // Only run previously failed tests
-	FailFast   bool      // Stop on first failure
-	Watch      bool      // Enable watch mode
-	Tests      []string  // Specific tests to run
-	Packages   []string  // Specific packages to test
-	Renderer   *Renderer // Custom renderer for test output
+	OnlyFailed      bool                // Only run previously failed tests
+	FailFast        bool                // Stop on first failure
+	Watch           bool                // Enable watch mode
+	ExplainSchedule bool                // Show how packages were selected, ordered, and assigned
+	Isolate         bool                // Run each package in its own temporary copy of its directory
+	UseDaemon       bool                // Run tests on the daemon serving the module, when one is running
+	Tests           []string            // Specific tests to run
+	Packages        []string            // Specific packages to test
+	ChangedFiles    []string            // Files whose changes triggered this run
+	BuildFlags      []string            // Extra go test build flags such as -race or -tags
+	Env             []string            // Extra environment variables for the test process
+	EnvPolicy       *EnvPolicy          // Which of go-sentinel's environment variables test processes see, nil for all
+	Attempt         int                 // Attempt number exposed to tests, starting at 1
+	ShardIndex      int                 // Zero-based shard index exposed to tests
+	ShardTotal      int                 // Total number of shards, 0 when not sharding
+	Seed            int64               // Seed for all randomized behavior, 0 when the run has none
+	Shuffle         bool                // Randomize test order with go test -shuffle, seeded by Seed
+	Bench           string              // Regular expression of benchmarks run with -benchmem after the tests, empty for none
+	Renderer        *Renderer           // Custom renderer for test output
+	Events          []EventHandler      // Consumers of run events besides the renderer, such as a JSON stream
+	History         HistoryBackend      // Where completed runs are recorded, nil to disable
+	Notify          *NotificationRouter // Routes failures to notification channels, nil to disable
+	Health          *WatchHealth        // Watch mode health checks, nil to disable
+	SlowTests       *SlowTestWarning    // Live warnings for unusually slow tests, nil to disable; needs History
+	Priority        *ProcessPriority    // Lowered priority for test processes, nil for normal priority
+	Parallelism     int                 // Packages tested at once (go test -p), 0 for the go default
+	Timeout         time.Duration       // Limit on each test binary (go test -timeout), 0 for the go default
+	SetupTimeout    time.Duration       // Limit on TestMain setup before the first test starts, 0 for none
+	PackageTimeouts []PackageTimeout    // Timeouts of the packages matching patterns, overriding Timeout
+	Deadline        time.Duration       // Limit on the whole run, 0 for none; packages still running then time out
+	Limits          *PackageLimits      // Memory, file descriptor, and CPU limits of test binaries, nil for none
+	Budgets         *DurationBudgets    // Duration budgets of packages, nil for none
+	Power           *PowerPolicy        // Watch mode throttling on battery, nil to disable
+	Desktop         *DesktopNotifier    // Desktop notifications when runs start or stop failing, nil to disable
+	Coverage        *CoverageTracker    // Coverage changes of saved files after watch reruns, nil to disable
+	RerunVerbose    bool                // Rerun failed tests alone with -v -count=1 and show their output
+	Retries         int                 // Times failed tests are rerun before they count as failed, 0 for none
+	Quarantine      *Quarantine         // Where tests that pass only on retry are recorded, nil to disable
+	Green           *GreenCache         // Where packages passing at the checked-out commit are recorded, nil to disable
+	ResultCache     *ResultCache        // Serves unchanged packages that passed before without running them, nil to disable
+	SelectTests     bool                // In watch mode, rerun only the tests affected by changed files
+	AffectedOnly    bool                // In watch mode, rerun only the changed packages and the packages importing them
+	JUnitReport     string              // Path a JUnit XML report is written to after each run, empty to disable
+	SARIFReport     string              // Path a SARIF report of the failures is written to after each run, empty to disable
+	Porcelain       io.Writer           // Receives each run in the porcelain format for scripts, nil to disable
+	PorcelainFormat string              // Porcelain format version, PorcelainVersion when empty
+	Summarizer      FailureSummarizer   // Explains the first failures of each run, nil to disable
+	Requirements    []string            // Only run tests annotated as tracing to these requirements
+	Stats           *WatchStats         // Watch session statistics, nil to disable
+	Trace           *WatchTrace         // Records why file events did or did not trigger runs, nil to disable
+	Keys            <-chan string       // Commands typed in watch mode, such as "s" for statistics or "e" to edit & rerun; nil when input is not a terminal
+	ChangedAt       time.Time           // When the change triggering this run was seen, zero for other runs
+	ReplayFixtures  bool                // Only replay httpreplay cassettes, failing tests whose cassette is missing
+	RefreshFixtures string              // Regular expression of tests whose httpreplay cassettes are re-recorded
+	UpdateSnapshots string              // Regular expression of tests whose differing snapshots are rewritten
+
+	selected bool // Narrowed to the tests affected by ChangedFiles
+
+	GenerateSteps []GenerateStep // Generation steps rerun when their inputs change
+	Matrix        []MatrixCell   // Build settings to run each package under
+	BeforeHooks   []HookConfig   // Commands run once before the tests, or the watch session
+	AfterHooks    []HookConfig   // Commands run once after them, even when tests or hooks failed
+
+	Parallel *ParallelRecommendations // Highest -parallel analyzed packages pass at, nil for the go default
 }
 
 // NewRunner creates a new test runner
 func NewRunner(workDir string) (*Runner, error) {
-	watcher, err := fsnotify.NewWatcher()
+	watcher, err := newWatcher()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create file watcher: %w", err)
+		return nil, err
 	}
 
 	return &Runner{
-		workDir: workDir,
-		watcher: watcher,
+		workDir:  workDir,
+		watcher:  watcher,
+		pkgCache: NewPackageCache(workDir, defaultPackageCacheDir()),
+		meta:     newTestMetadata(workDir),
+		decls:    newDeclSnapshots(),
 	}, nil
 }
 
@@ -51,9 +126,35 @@ func (r *Runner) Run(ctx context.Context, opts RunOptions) error {
 		opts.Renderer = NewRenderer(os.Stdout)
 	}
 
+	// Narrow the run to the tests of the requested requirements
+	if len(opts.Requirements) > 0 {
+		narrowed, err := r.selectRequirements(opts)
+		if err != nil {
+			return err
+		}
+		opts = narrowed
+	}
+
+	// Set up what the tests need, and tear it down whatever happens. A
+	// failed setup aborts the run; a failed teardown fails a passing one.
+	if err := r.runHooks(HookBefore, opts.BeforeHooks, opts); err != nil {
+		return errors.Join(err, r.runHooks(HookAfter, opts.AfterHooks, opts))
+	}
+	err := r.runTests(ctx, opts)
+	if hookErr := r.runHooks(HookAfter, opts.AfterHooks, opts); err == nil {
+		err = hookErr
+	}
+	return err
+}
+
+// runTests runs the tests once, under each matrix cell, or in watch mode
+func (r *Runner) runTests(ctx context.Context, opts RunOptions) error {
 	if opts.Watch {
 		return r.Watch(ctx, opts)
 	}
+	if len(opts.Matrix) > 0 {
+		return r.RunMatrix(opts)
+	}
 	_, err := r.RunOnce(opts)
 	return err
 }
@@ -63,13 +164,229 @@ func (r *Runner) RunOnce(opts RunOptions) (string, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	startTime := time.Now()
+	// Tell the renderer and other consumers that a run started
+	events := opts.eventPipeline()
+	events.emit(RunEvent{Type: EventRunStart})
+
+	run, outputStr, err := r.executeCached(opts)
+
+	// Give failed tests more attempts before they count as failed
+	if opts.Retries > 0 && run != nil && run.NumFailed > 0 {
+		r.retryTests(run, opts)
+		if run.NumFailed == 0 {
+			err = nil
+		}
+		if opts.Quarantine != nil && len(RetriedTests(run)) > 0 {
+			opts.Quarantine.Record(run, time.Now())
+			if saveErr := opts.Quarantine.Save(); saveErr != nil {
+				log.Printf("Error saving quarantine: %v", saveErr)
+			}
+		}
+	}
 
-	// Show test start message
+	if run != nil {
+		r.lastRun = run
+		run.Env = r.envSnapshot(opts)
+		if opts.ResultCache != nil && opts.ResultCache.cacheable(opts) {
+			if err := opts.ResultCache.Store(run, opts, run.Env); err != nil {
+				log.Printf("Error writing result cache: %v", err)
+			}
+		}
+		r.annotateTests(run, opts)
+		r.annotateRepros(run, opts)
+		if opts.Budgets != nil {
+			opts.Budgets.apply(run)
+		}
+		if opts.History != nil {
+			r.annotateRequirements(run, opts)
+		}
+		if opts.Green != nil {
+			if commit := HeadCommit(r.workDir); commit != "" {
+				if err := opts.Green.Record(commit, run, opts); err != nil {
+					log.Printf("Error recording green packages: %v", err)
+				}
+			}
+		}
+	}
+
+	// Report the results of each package
+	if run != nil {
+		events.emitResults(run)
+	}
+	if run != nil && opts.Bench != "" && opts.Renderer != nil {
+		opts.Renderer.RenderBenchmarks(run)
+	}
+
+	// Count the run toward the watch session's cadence
+	if opts.Stats != nil && run != nil {
+		opts.Stats.record(run, opts.ChangedAt, time.Now(), opts.selected)
+		if opts.Stats.MetricsFile != "" {
+			if statsErr := opts.Stats.writeMetricsFile(); statsErr != nil {
+				log.Printf("Error writing watch metrics: %v", statsErr)
+			}
+		}
+	}
+
+	// Prepare phase
+	prepareStart := time.Now()
+	if run != nil {
+		events.emit(RunEvent{Type: EventRunSummary, Run: run, Dirs: r.packageDirs(opts.Packages)})
+	}
+	if run != nil {
+		run.PrepareDuration = time.Since(prepareStart)
+	}
+
+	// Gather more context on failures by rerunning them on their own
+	if opts.RerunVerbose && run != nil && run.NumFailed > 0 {
+		reruns := r.rerunFailures(run, opts)
+		if opts.Renderer != nil {
+			opts.Renderer.RenderVerboseReruns(reruns)
+		}
+	}
+
+	// Ask the team's model what went wrong
+	if opts.Summarizer != nil && run != nil && run.NumFailed > 0 {
+		summaries := r.summarizeFailures(run, opts.Summarizer)
+		if opts.Renderer != nil {
+			opts.Renderer.RenderFailureSummaries(summaries)
+		}
+	}
+
+	// Show how the saved files' coverage moved
+	if opts.Coverage != nil && run != nil && run.Coverage != nil {
+		deltas := opts.Coverage.update(run.Coverage, r.coverageNames(opts.ChangedFiles, opts.Packages))
+		if opts.Renderer != nil {
+			opts.Renderer.RenderCoverageDeltas(deltas)
+		}
+	}
+
+	if run != nil {
+		r.reportRun(run, opts, r.packageDirs(opts.Packages))
+	}
+
+	// Explain how packages were scheduled
+	if opts.ExplainSchedule && opts.Renderer != nil && run != nil {
+		pkgs, listErr := r.pkgCache.Get(opts.Packages)
+		if listErr != nil {
+			log.Printf("Error explaining schedule: %v", listErr)
+		} else {
+			opts.Renderer.RenderSchedule(BuildSchedule(r.workDir, pkgs, opts, run, opts.parallelism()))
+		}
+	}
+
+	if err == nil && opts.Budgets != nil && run != nil {
+		return outputStr, opts.Budgets.check(run)
+	}
+	return outputStr, testError(outputStr, err)
+}
+
+// reportRun hands a finished run to the reports, history, and
+// notifications of a run. dirs are the package directories by import path.
+func (r *Runner) reportRun(run *TestRun, opts RunOptions, dirs map[string]string) {
+	// Leave reports for CI systems and code scanning to pick up
+	if opts.JUnitReport != "" {
+		reportErr := WriteBlobFile(opts.JUnitReport, func(path string) error {
+			return WriteJUnitFile(path, run)
+		})
+		if reportErr != nil {
+			log.Printf("Error writing JUnit report: %v", reportErr)
+		}
+	}
+	if opts.SARIFReport != "" {
+		reportErr := WriteBlobFile(opts.SARIFReport, func(path string) error {
+			return WriteSARIFFile(path, run, r.workDir, dirs)
+		})
+		if reportErr != nil {
+			log.Printf("Error writing SARIF report: %v", reportErr)
+		}
+	}
+
+	// Hand scripts the stable porcelain records
+	if opts.Porcelain != nil {
+		version := opts.PorcelainFormat
+		if version == "" {
+			version = PorcelainVersion
+		}
+		if porcelainErr := WritePorcelain(opts.Porcelain, run, version); porcelainErr != nil {
+			log.Printf("Error writing porcelain output: %v", porcelainErr)
+		}
+	}
+
+	// Record the run in history
+	if opts.History != nil {
+		trigger := TriggerRun
+		if opts.Watch {
+			trigger = TriggerWatch
+		}
+		rec := NewHistoryRecord(run, trigger)
+		rec.Branch = currentBranch(r.workDir)
+		if histErr := opts.History.Append(rec); histErr != nil {
+			log.Printf("Error recording history: %v", histErr)
+		}
+	}
+
+	// Tell the developer when the tests start failing or pass again
+	if opts.Desktop != nil {
+		opts.Desktop.Notify(run)
+	}
+
+	// Tell the owning teams about failures
+	if opts.Notify != nil {
+		for _, notifyErr := range opts.Notify.Route(run) {
+			log.Printf("Error sending notification: %v", notifyErr)
+		}
+	}
+}
+
+// environ returns the environment of the go commands and test processes
+// of a run
+func (opts RunOptions) environ() []string {
+	return offlineEnviron(append(opts.EnvPolicy.Environ(), opts.Env...))
+}
+
+// parallelism returns how many packages are tested at once
+func (opts RunOptions) parallelism() int {
+	if opts.Parallelism > 0 {
+		return opts.Parallelism
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// annotateTests resolves the per-test metadata shown by the renderer's
+// columns, plus owners when failures are routed to notification channels
+func (r *Runner) annotateTests(run *TestRun, opts RunOptions) {
+	var columns []Column
 	if opts.Renderer != nil {
-		opts.Renderer.RenderTestStart(nil)
+		columns = opts.Renderer.Columns()
+	}
+	if opts.Notify != nil {
+		columns = append(append([]Column{}, columns...), ColumnOwner)
+	}
+	if !hasColumn(columns, ColumnOwner) && !hasColumn(columns, ColumnTags) && !hasColumn(columns, ColumnLastChange) {
+		return
 	}
 
+	pkgs, err := r.pkgCache.Get(opts.Packages)
+	if err != nil {
+		log.Printf("Error resolving test metadata: %v", err)
+		return
+	}
+	dirs := make(map[string]string, len(pkgs))
+	for _, pkg := range pkgs {
+		dirs[pkg.ImportPath] = pkg.Dir
+	}
+	r.meta.annotate(run, columns, dirs)
+}
+
+// execute runs go test and parses its output. The returned run is nil when
+// the output could not be parsed; the error is the raw command error.
+func (r *Runner) execute(opts RunOptions) (*TestRun, string, error) {
+	startTime := time.Now()
+	runID := newRunID()
+	opts.Env = append(append([]string{}, opts.Env...), runContextEnv(runID, opts)...)
+	opts.Env = append(opts.Env, fixtureEnv(opts)...)
+	opts.Env = append(opts.Env, snapshotEnv(opts)...)
+
 	// Transform phase
 	transformStart := time.Now()
 	args := []string{"test"}
@@ -80,6 +397,50 @@ func (r *Runner) RunOnce(opts RunOptions) (string, error) {
 	if len(opts.Tests) > 0 {
 		args = append(args, "-run", strings.Join(opts.Tests, "|"))
 	}
+	if opts.Shuffle {
+		args = append(args, "-shuffle", strconv.FormatInt(opts.Seed, 10))
+	}
+	if opts.Bench != "" {
+		args = append(args, "-bench", opts.Bench, "-benchmem")
+	}
+	if opts.Parallelism > 0 {
+		args = append(args, "-p", strconv.Itoa(opts.Parallelism))
+	}
+	// Timeouts go-sentinel enforces stop only the package that hit them,
+	// so go test's own is turned off
+	if opts.enforcesTimeouts() {
+		args = append(args, "-timeout", "0")
+		timeoutsEnv, err := r.timeoutsEnv(opts, startTime)
+		if err != nil {
+			return nil, "", err
+		}
+		opts.Env = append(opts.Env, timeoutsEnv...)
+	} else if opts.Timeout > 0 {
+		args = append(args, "-timeout", opts.Timeout.String())
+	}
+	parallelEnv, err := r.parallelEnv(opts)
+	if err != nil {
+		return nil, "", err
+	}
+	opts.Env = append(opts.Env, parallelEnv...)
+	if (opts.SetupTimeout > 0 || opts.Limits != nil || len(parallelEnv) > 0 || opts.enforcesTimeouts()) && !opts.Isolate {
+		execArgs, err := setupExecArgs(opts.SetupTimeout)
+		if err != nil {
+			return nil, "", err
+		}
+		args = append(args, execArgs...)
+		limitsEnv, err := r.limitsEnv(opts)
+		if err != nil {
+			return nil, "", err
+		}
+		opts.Env = append(opts.Env, limitsEnv...)
+	}
+	var coverProfile string
+	if opts.Coverage != nil && !opts.Isolate {
+		coverProfile = filepath.Join(os.TempDir(), "go-sentinel-cover-"+runID+".out")
+		args = append(args, "-coverprofile="+coverProfile)
+	}
+	args = append(args, opts.BuildFlags...)
 	if len(opts.Packages) > 0 {
 		args = append(args, opts.Packages...)
 	} else {
@@ -89,14 +450,31 @@ func (r *Runner) RunOnce(opts RunOptions) (string, error) {
 
 	// Setup phase
 	setupStart := time.Now()
-	cmd := exec.Command("go", args...)
+	cmd := exec.Command(goBinary, args...)
 	cmd.Dir = r.workDir
-	cmd.Env = os.Environ()
+	cmd.Env = opts.environ()
 	setupDuration := time.Since(setupStart)
 
-	// Collection phase
+	// Collection phase, showing the run's progress as go test reports it
 	collectStart := time.Now()
-	output, err := cmd.CombinedOutput()
+	var tee io.Writer
+	if opts.Renderer.showsProgress() {
+		tee = opts.Renderer.startProgress(len(r.packageDirs(opts.Packages)))
+	}
+	var output []byte
+	if opts.UseDaemon && !opts.Isolate && opts.Bench == "" && opts.SetupTimeout == 0 && opts.Limits == nil && len(parallelEnv) == 0 && !opts.enforcesTimeouts() && coverProfile == "" && slowTestMonitorFor(opts) == nil {
+		output, err = r.runOnDaemon(opts)
+		if errors.Is(err, ErrNoDaemon) {
+			output, err = combinedOutput(cmd, opts.Priority, tee)
+		}
+	} else if opts.Isolate {
+		output, err = r.runIsolated(opts, startTime)
+	} else if monitor := slowTestMonitorFor(opts); monitor != nil {
+		output, err = monitor.run(cmd, opts.Priority, tee)
+	} else {
+		output, err = combinedOutput(cmd, opts.Priority, tee)
+	}
+	opts.Renderer.stopProgress()
 	outputStr := string(output)
 	collectDuration := time.Since(collectStart)
 
@@ -105,51 +483,74 @@ func (r *Runner) RunOnce(opts RunOptions) (string, error) {
 	parser := NewParser()
 	run, parseErr := parser.Parse(strings.NewReader(outputStr))
 	parseDuration := time.Since(parseStart)
-
-	if run != nil {
-		run.StartTime = startTime
-		run.EndTime = time.Now()
-		run.Duration = run.EndTime.Sub(startTime)
-		run.TransformDuration = transformDuration
-		run.SetupDuration = setupDuration
-		run.CollectDuration = collectDuration
-		run.ParseDuration = parseDuration
-
-		// Render test results as they come in
-		if opts.Renderer != nil {
-			for _, suite := range run.Suites {
-				opts.Renderer.RenderSuite(suite)
-			}
+	if parseErr != nil {
+		log.Printf("Error parsing test output: %v", parseErr)
+		if coverProfile != "" {
+			os.Remove(coverProfile)
 		}
+		return nil, outputStr, err
 	}
 
-	// Prepare phase
-	prepareStart := time.Now()
-	if parseErr == nil && opts.Renderer != nil && run != nil {
-		opts.Renderer.RenderFinalSummary(run)
+	run.ID = runID
+	run.Seed = opts.Seed
+	markTimeouts(run)
+	addBuildContext(run, r.workDir)
+	addPanicContext(run, r.workDir)
+	if coverProfile != "" {
+		run.Coverage, run.CoverageTotal = readCoverProfile(coverProfile)
 	}
-	if run != nil {
-		run.PrepareDuration = time.Since(prepareStart)
+	attempts := opts.Attempt
+	if attempts < 1 {
+		attempts = 1
 	}
+	for _, suite := range run.Suites {
+		for _, test := range suite.Tests {
+			test.Attempts = attempts
+		}
+	}
+	run.StartTime = startTime
+	run.EndTime = time.Now()
+	run.Duration = run.EndTime.Sub(startTime)
+	run.TransformDuration = transformDuration
+	run.SetupDuration = setupDuration
+	run.CollectDuration = collectDuration
+	run.ParseDuration = parseDuration
+	return run, outputStr, err
+}
 
-	// Return error for test failures
-	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			// Test failures have exit code 1
-			if exitErr.ExitCode() == 1 {
-				return outputStr, fmt.Errorf("tests failed: %s", outputStr)
-			}
-			return outputStr, fmt.Errorf("test execution failed with code %d: %s", exitErr.ExitCode(), outputStr)
+// ErrTestsFailed is returned, wrapped, when tests ran and some failed
+var ErrTestsFailed = errors.New("tests failed")
+
+// testError converts a go test command error into the error reported to callers
+func testError(output string, err error) error {
+	if err == nil {
+		return nil
+	}
+	// Runs on the daemon report their exit code the way go test does
+	var exitErr interface{ ExitCode() int }
+	if errors.As(err, &exitErr) {
+		// Test failures have exit code 1
+		if exitErr.ExitCode() == 1 {
+			return fmt.Errorf("%w: %s", ErrTestsFailed, output)
 		}
-		return outputStr, fmt.Errorf("failed to run tests: %w", err)
+		return fmt.Errorf("test execution failed with code %d: %s", exitErr.ExitCode(), output)
 	}
+	return fmt.Errorf("failed to run tests: %w", err)
+}
 
-	return outputStr, nil
+// watchError returns the errors that end a watch session. Failing tests
+// and budget overages are reported by the renderer and watching goes on.
+func watchError(err error) error {
+	if errors.Is(err, ErrTestsFailed) || errors.Is(err, ErrBudgetExceeded) {
+		return nil
+	}
+	return err
 }
 
 // Watch starts watching for file changes and runs tests
 func (r *Runner) Watch(ctx context.Context, opts RunOptions) error {
-	// Add watch paths
+	// Add watch paths, including the inputs of generation steps
+	r.generate = opts.GenerateSteps
 	if err := r.addWatchPaths(); err != nil {
 		return err
 	}
@@ -164,45 +565,367 @@ func (r *Runner) Watch(ctx context.Context, opts RunOptions) error {
 		opts.Renderer.RenderWatchHeader()
 	}
 
+	// Throttle while on battery, checking again periodically
+	parallelism, debounce := opts.Parallelism, time.Duration(0)
+	power := PowerUnknown
+	var powerTick <-chan time.Time
+	if opts.Power != nil {
+		power = opts.Power.detect()
+		opts.Parallelism, debounce = opts.Power.throttle(power, parallelism, 0)
+		if opts.Renderer != nil && power != PowerUnknown {
+			opts.Renderer.RenderPowerMode(power, opts.Parallelism, debounce)
+		}
+		if opts.Power.Interval > 0 {
+			ticker := time.NewTicker(opts.Power.Interval)
+			defer ticker.Stop()
+			powerTick = ticker.C
+		}
+	}
+
+	// Remember the declarations of the watched files, so the first save of
+	// each already narrows the rerun to the declarations it edited
+	if opts.SelectTests {
+		go func() {
+			if pkgs, err := r.pkgCache.Get(opts.Packages); err == nil {
+				r.decls.warm(pkgs)
+			}
+		}()
+	}
+
 	// Run tests initially
-	if _, err := r.RunOnce(opts); err != nil {
+	if _, err := r.RunOnce(opts); watchError(err) != nil {
 		return err
 	}
 
+	// An "edit & rerun" command waits for the next line typed
+	var editing *editPrompt
+
+	// Changes are batched while debouncing
+	var pending []string
+	var pendingSince time.Time
+	debounceTimer := time.NewTimer(time.Hour)
+	debounceTimer.Stop()
+	defer debounceTimer.Stop()
+
+	// Periodically check our own health so a degraded watcher is restarted
+	var healthTick <-chan time.Time
+	if opts.Health != nil && opts.Health.Interval > 0 {
+		ticker := time.NewTicker(opts.Health.Interval)
+		defer ticker.Stop()
+		healthTick = ticker.C
+	}
+
 	// Watch for changes
 	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
+		case <-powerTick:
+			if source := opts.Power.detect(); source != power {
+				power = source
+				opts.Parallelism, debounce = opts.Power.throttle(power, parallelism, 0)
+				if opts.Renderer != nil {
+					opts.Renderer.RenderPowerMode(power, opts.Parallelism, debounce)
+				}
+			}
+		case <-debounceTimer.C:
+			files := pending
+			pending = nil
+			if err := r.runChanged(opts, files, pendingSince); watchError(err) != nil {
+				return err
+			}
+		case key := <-opts.Keys:
+			switch {
+			case editing != nil:
+				prompt := editing
+				editing = nil
+				if err := r.rerunEdited(opts, prompt, key); watchError(err) != nil {
+					return err
+				}
+			case key == "e" || strings.HasPrefix(key, "e "):
+				editing = r.startEdit(opts, strings.TrimSpace(key[1:]))
+			case key == "s" && opts.Stats != nil && opts.Renderer != nil:
+				opts.Renderer.RenderWatchStats(opts.Stats.Snapshot())
+			case key == "t" && opts.Trace != nil && opts.Renderer != nil:
+				opts.Renderer.RenderWatchTrace(opts.Trace.Entries())
+			case key == "l" && opts.Renderer != nil:
+				r.mu.Lock()
+				last := r.lastRun
+				r.mu.Unlock()
+				opts.Renderer.RenderFailedTests(last)
+			case (key == "y" || strings.HasPrefix(key, "y ")) && opts.Renderer != nil:
+				r.mu.Lock()
+				last := r.lastRun
+				r.mu.Unlock()
+				opts.Renderer.CopyRepro(last, strings.TrimSpace(key[1:]))
+			case key == "c" && opts.Renderer != nil:
+				opts.Renderer.SetCollapseSubtests(!opts.Renderer.CollapseSubtests())
+				r.mu.Lock()
+				last := r.lastRun
+				r.mu.Unlock()
+				opts.Renderer.RenderTestTrees(last)
+			case (key == "o" || strings.HasPrefix(key, "o ")) && opts.Renderer != nil:
+				r.mu.Lock()
+				last := r.lastRun
+				r.mu.Unlock()
+				columns, toggled := toggleColumnNumbers(opts.Renderer.Columns(), strings.TrimSpace(key[1:]))
+				if toggled {
+					opts.Renderer.SetColumns(columns)
+					if last != nil {
+						r.annotateTests(last, opts)
+					}
+				} else {
+					last = nil
+				}
+				opts.Renderer.RenderColumnPicker(last)
+			}
+		case <-healthTick:
+			if problems, restart := opts.Health.check(r.watcher); restart {
+				if err := r.restartWatcher(opts, problems); err != nil {
+					return fmt.Errorf("failed to restart watcher: %w", err)
+				}
+			}
+			// A watcher that silently stopped delivering events is replaced
+			// right away, and the changes it missed are tested. Pending
+			// events are handled first so they are not mistaken for misses.
+			if len(r.watcher.Events) > 0 {
+				continue
+			}
+			if missed := opts.Health.missedChanges(r.workDir, time.Now()); len(missed) > 0 {
+				for _, file := range missed {
+					opts.Trace.add(TraceEvent, file, "missed by the watcher, found by the health check")
+				}
+				opts.Health.StuckRecoveries++
+				problem := fmt.Sprintf("watcher missed changes to %d %s", len(missed), pluralize("file", len(missed)))
+				if err := r.restartWatcher(opts, []string{problem}); err != nil {
+					return fmt.Errorf("failed to restart watcher: %w", err)
+				}
+				opts.ChangedFiles = missed
+				if _, err := r.RunOnce(opts); watchError(err) != nil {
+					return err
+				}
+			}
 		case event, ok := <-r.watcher.Events:
 			if !ok {
 				return nil
 			}
-			if r.shouldRunTests(event.Name) {
-				// Show file change notification
-				if opts.Renderer != nil {
-					opts.Renderer.RenderFileChange(event.Name)
+			if opts.Health != nil {
+				opts.Health.recordEvent(event.Name, time.Now())
+			}
+			opts.Trace.add(TraceEvent, event.Name, "%s", event.Op)
+			trigger, rule := r.triggerRule(event.Name)
+			opts.Trace.add(TraceRule, event.Name, "%s", rule)
+			if !trigger {
+				continue
+			}
+			if debounce > 0 {
+				if len(pending) == 0 {
+					pendingSince = time.Now()
 				}
-				if _, err := r.RunOnce(opts); err != nil {
-					return err
+				if !containsString(pending, event.Name) {
+					pending = append(pending, event.Name)
 				}
+				debounceTimer.Reset(debounce)
+				opts.Trace.add(TraceDebounce, event.Name, "waiting %s for more changes (%d pending)", debounce, len(pending))
+				continue
+			}
+			opts.Trace.add(TraceDebounce, event.Name, "no debounce, running now")
+			if err := r.runChanged(opts, []string{event.Name}, time.Now()); watchError(err) != nil {
+				return err
 			}
 		case err, ok := <-r.watcher.Errors:
 			if !ok {
 				return nil
 			}
-			return fmt.Errorf("watcher error: %w", err)
+			if opts.Health == nil {
+				return fmt.Errorf("watcher error: %w", err)
+			}
+			opts.Health.recordWatcherError()
+		}
+	}
+}
+
+// runChanged reruns tests after files changed at changedAt, regenerating
+// code first. Generation failures are reported and skip the run.
+func (r *Runner) runChanged(opts RunOptions, files []string, changedAt time.Time) error {
+	// Show file change notification
+	if opts.Renderer != nil {
+		for _, file := range files {
+			opts.Renderer.RenderFileChange(file)
+		}
+	}
+	opts.ChangedFiles = files
+	opts.ChangedAt = changedAt
+	if opts.Stats != nil {
+		opts.Stats.recordChanges(len(files))
+	}
+
+	// Regenerate code before rerunning the affected tests
+	if err := r.runGenerateSteps(opts.GenerateSteps, opts.ChangedFiles, opts.environ()); err != nil {
+		var genErr *GenerateError
+		if !errors.As(err, &genErr) {
+			return err
+		}
+		if opts.Renderer != nil {
+			opts.Renderer.RenderGenerateFailure(genErr)
+		}
+		opts.Trace.add(TraceRun, "", "skipped: code generation failed")
+		return nil
+	}
+
+	// Narrow the rerun to the affected tests when that is safe
+	if opts.SelectTests && len(opts.Tests) == 0 {
+		if sel := r.selectAffected(opts); sel != nil {
+			opts.Packages = sel.Packages
+			opts.Tests = sel.RunPatterns()
+			opts.selected = true
+			if opts.Renderer != nil {
+				opts.Renderer.RenderTestSelection(sel)
+			}
+		}
+	}
+
+	// Otherwise narrow it to the changed packages and their importers
+	if opts.AffectedOnly && !opts.selected {
+		if pkgs := r.affectedPackages(opts); pkgs != nil {
+			opts.Packages = pkgs
+			if opts.Renderer != nil {
+				opts.Renderer.RenderTestSelection(&TestSelection{Packages: pkgs})
+			}
+		}
+	}
+
+	switch {
+	case opts.selected:
+		opts.Trace.add(TraceRun, "", "running %s in %s for %d changed %s", strings.Join(opts.Tests, "|"), strings.Join(opts.Packages, ", "), len(files), pluralize("file", len(files)))
+	case len(opts.Packages) > 0:
+		opts.Trace.add(TraceRun, "", "running %s for %d changed %s", strings.Join(opts.Packages, ", "), len(files), pluralize("file", len(files)))
+	default:
+		opts.Trace.add(TraceRun, "", "running all packages for %d changed %s", len(files), pluralize("file", len(files)))
+	}
+	_, err := r.RunOnce(opts)
+	return err
+}
+
+// selectAffected maps the changed files to the tests they affect within
+// the packages the run covers, or returns nil to rerun everything
+func (r *Runner) selectAffected(opts RunOptions) *TestSelection {
+	pkgs, err := r.pkgCache.Get(opts.Packages)
+	if err != nil {
+		log.Printf("Error selecting affected tests: %v", err)
+		return nil
+	}
+	changed := make([]string, len(opts.ChangedFiles))
+	for i, file := range opts.ChangedFiles {
+		changed[i] = absPath(r.workDir, file)
+	}
+	return selectTests(pkgs, changed, r.decls)
+}
+
+// affectedPackages returns the packages the run covers that the changed
+// files can affect through the dependency graph, or nil to rerun them all
+func (r *Runner) affectedPackages(opts RunOptions) []string {
+	pkgs, err := r.pkgCache.Get(opts.Packages)
+	if err != nil {
+		log.Printf("Error selecting affected packages: %v", err)
+		return nil
+	}
+	changed := make([]string, len(opts.ChangedFiles))
+	for i, file := range opts.ChangedFiles {
+		changed[i] = absPath(r.workDir, file)
+	}
+	affected, ok := NewDependencyGraph(pkgs).Affected(changed)
+	if !ok {
+		return nil
+	}
+	return affected
+}
+
+// packageDirs maps the import paths of the packages matching patterns to
+// their directories, leaving it empty when they cannot be listed
+func (r *Runner) packageDirs(patterns []string) map[string]string {
+	dirs := make(map[string]string)
+	pkgs, err := r.pkgCache.Get(patterns)
+	if err != nil {
+		log.Printf("Error listing packages: %v", err)
+		return dirs
+	}
+	for _, pkg := range pkgs {
+		dirs[pkg.ImportPath] = pkg.Dir
+	}
+	return dirs
+}
+
+// SetBuildTags lists packages with the build tags of the active profile,
+// so watch mode maps changes in files behind those tags to their tests
+func (r *Runner) SetBuildTags(tags []string) {
+	r.pkgCache.SetBuildTags(tags)
+}
+
+// SetWatchIgnore sets globs, relative to the working directory, of files
+// and directories watch mode does not watch
+func (r *Runner) SetWatchIgnore(patterns []string) {
+	r.ignore = patterns
+}
+
+// ignored reports whether a file or directory matches an ignore glob
+func (r *Runner) ignored(path string) bool {
+	return r.ignoredBy(path) != ""
+}
+
+// ignoredBy returns the ignore glob a file or directory matches, or ""
+func (r *Runner) ignoredBy(path string) string {
+	if len(r.ignore) == 0 {
+		return ""
+	}
+	rel, err := filepath.Rel(r.workDir, absPath(r.workDir, path))
+	if err != nil {
+		return ""
+	}
+	rel = filepath.ToSlash(rel)
+	for _, pattern := range r.ignore {
+		if matchGlob(pattern, rel) {
+			return pattern
 		}
 	}
+	return ""
 }
 
 // shouldRunTests determines if tests should be run for a file change
 func (r *Runner) shouldRunTests(path string) bool {
-	// Only run tests for Go files
-	return strings.HasSuffix(path, ".go")
+	trigger, _ := r.triggerRule(path)
+	return trigger
+}
+
+// triggerRule reports whether a file change triggers tests and describes
+// the rule that decided it. Go files and inputs of generation steps that
+// are not ignored do.
+func (r *Runner) triggerRule(path string) (bool, string) {
+	step, input := r.generateInput(path)
+	if !strings.HasSuffix(path, ".go") && !input {
+		return false, "skipped: not a Go file or generate step input"
+	}
+	if pattern := r.ignoredBy(path); pattern != "" {
+		return false, fmt.Sprintf("skipped: ignored by %q", pattern)
+	}
+	if !strings.HasSuffix(path, ".go") {
+		return true, fmt.Sprintf("triggers tests: input of generate step %q", step.Name)
+	}
+	return true, "triggers tests: Go source file"
+}
+
+// generateInput returns the first generation step path is an input of
+func (r *Runner) generateInput(path string) (GenerateStep, bool) {
+	for _, step := range r.generate {
+		if r.stepTriggered(step, []string{path}) {
+			return step, true
+		}
+	}
+	return GenerateStep{}, false
 }
 
-// addWatchPaths adds Go source files to the watcher
+// addWatchPaths adds Go source files and the inputs of generation steps to
+// the watcher
 func (r *Runner) addWatchPaths() error {
 	return filepath.Walk(r.workDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -211,15 +934,15 @@ func (r *Runner) addWatchPaths() error {
 
 		// Skip directories
 		if info.IsDir() {
-			// Skip hidden directories and vendor
-			if strings.HasPrefix(info.Name(), ".") || info.Name() == "vendor" {
+			// Skip hidden, vendor, and ignored directories
+			if strings.HasPrefix(info.Name(), ".") || info.Name() == "vendor" || r.ignored(path) {
 				return filepath.SkipDir
 			}
 			return nil
 		}
 
-		// Only watch Go files
-		if !strings.HasSuffix(info.Name(), ".go") {
+		// Only watch the files whose changes trigger tests
+		if trigger, _ := r.triggerRule(path); !trigger {
 			return nil
 		}
 
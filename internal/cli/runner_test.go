@@ -3,8 +3,10 @@ package cli
 import (
 	"context"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -122,6 +124,17 @@ func TestRunner_ShouldRunTests(t *testing.T) {
 			}
 		})
 	}
+
+	t.Run("ignored file", func(t *testing.T) {
+		runner.SetWatchIgnore([]string{"**/testdata/**"})
+		defer runner.SetWatchIgnore(nil)
+		if runner.shouldRunTests(filepath.Join(runner.workDir, "pkg", "testdata", "fixture.go")) {
+			t.Error("shouldRunTests should skip files matching an ignore glob")
+		}
+		if !runner.shouldRunTests(filepath.Join(runner.workDir, "pkg", "fixture.go")) {
+			t.Error("shouldRunTests should keep files outside the ignore globs")
+		}
+	})
 }
 
 func TestRunner_WatchMode(t *testing.T) {
@@ -306,3 +319,66 @@ func TestSkip(t *testing.T) {
 		t.Errorf("Expected no error when running passing test, got: %v", err)
 	}
 }
+
+func TestRunner_RunContextEnv(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module example\n\ngo 1.23\n"), 0600); err != nil {
+		t.Fatalf("Failed to write go.mod: %v", err)
+	}
+
+	// The test fails unless the run context is visible to it
+	err := os.WriteFile(filepath.Join(tmpDir, "env_test.go"), []byte(`package example
+
+import (
+	"os"
+	"testing"
+)
+
+func TestRunContext(t *testing.T) {
+	if os.Getenv("SENTINEL_RUN_ID") == "" {
+		t.Error("SENTINEL_RUN_ID not set")
+	}
+	if got := os.Getenv("SENTINEL_SHARD"); got != "2/4" {
+		t.Errorf("SENTINEL_SHARD = %q, want 2/4", got)
+	}
+	if got := os.Getenv("SENTINEL_ATTEMPT"); got != "1" {
+		t.Errorf("SENTINEL_ATTEMPT = %q, want 1", got)
+	}
+	if got := os.Getenv("SENTINEL_SEED"); got != "42" {
+		t.Errorf("SENTINEL_SEED = %q, want 42", got)
+	}
+}
+`), 0600)
+	if err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	runner, err := NewRunner(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to create runner: %v", err)
+	}
+	defer runner.Stop()
+
+	run, output, err := runner.execute(RunOptions{ShardIndex: 2, ShardTotal: 4, Seed: 42, Shuffle: true})
+	if err != nil {
+		t.Fatalf("Expected run context to be injected, got: %v\n%s", err, output)
+	}
+	if run.ID == "" {
+		t.Error("Expected run ID to be recorded on the run")
+	}
+	if run.Seed != 42 {
+		t.Errorf("run.Seed = %d, want 42", run.Seed)
+	}
+	if !strings.Contains(output, "-test.shuffle 42") {
+		t.Errorf("Expected tests to be shuffled with the run's seed, got:\n%s", output)
+	}
+}
+
+func TestWatchError(t *testing.T) {
+	if err := watchError(fmt.Errorf("%w: output", ErrTestsFailed)); err != nil {
+		t.Errorf("Failing tests should not end a watch session, got %v", err)
+	}
+	if err := watchError(errors.New("go: cannot find main module")); err == nil {
+		t.Error("Other errors should end a watch session")
+	}
+}
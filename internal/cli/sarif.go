@@ -0,0 +1,320 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SARIF rule IDs, one per failure category, so code scanning can group
+// and filter the annotations
+const (
+	SARIFRuleAssertion     = "assertion-failure"
+	SARIFRulePanic         = "panic"
+	SARIFRuleTimeout       = "timeout"
+	SARIFRuleBuild         = "build-error"
+	SARIFRuleRace          = "data-race"
+	SARIFRuleResourceLimit = "resource-limit"
+)
+
+// sarifRules describes the rules in the order they are listed in reports
+var sarifRules = []struct {
+	id, category, description string
+}{
+	{SARIFRuleAssertion, CategoryAssertion, "A test reported a failed check"},
+	{SARIFRulePanic, CategoryPanic, "A test panicked"},
+	{SARIFRuleTimeout, CategoryTimeout, "A test binary ran past its timeout"},
+	{SARIFRuleBuild, CategoryBuild, "A package or its tests did not build"},
+	{SARIFRuleRace, CategoryRace, "The race detector found a data race"},
+	{SARIFRuleResourceLimit, CategoryResourceLimit, "A test binary exceeded a memory, file descriptor, or CPU limit"},
+}
+
+// sarifLocationRe finds file:line[:column] references in failure output,
+// including absolute paths in panic stack traces
+var sarifLocationRe = regexp.MustCompile(`((?:[A-Za-z]:)?[\w./\\-]+\.go):(\d+)(?::(\d+))?`)
+
+// sarifFingerprint names the partial fingerprint code scanning uses to
+// follow a failure across runs
+const sarifFingerprint = "goSentinelFailure/v1"
+
+// sarifLog is the root of a SARIF 2.1.0 file
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string          `json:"id"`
+	ShortDescription     sarifMessage    `json:"shortDescription"`
+	DefaultConfiguration sarifRuleConfig `json:"defaultConfiguration"`
+}
+
+type sarifRuleConfig struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	RuleIndex           int               `json:"ruleIndex"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations,omitempty"`
+	PartialFingerprints map[string]string `json:"partialFingerprints,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+// sarifPaths resolves the files named in failure output to paths relative
+// to the project root, which is where code scanning looks for them
+type sarifPaths struct {
+	root string
+	dirs map[string]string // Import path to package directory
+}
+
+// WriteSARIFReport writes the failures of run as SARIF 2.1.0, with a result
+// per failed test and per compiler error, so code scanning and editors can
+// annotate the lines that failed. File names in test output are resolved
+// against the package directories in dirs, keyed by import path; paths are
+// reported relative to root.
+func WriteSARIFReport(w io.Writer, run *TestRun, root string, dirs map[string]string) error {
+	paths := sarifPaths{root: root, dirs: dirs}
+	driver := sarifDriver{Name: "go-sentinel", InformationURI: "https://github.com/newbpydev/go-sentinel"}
+	for _, rule := range sarifRules {
+		driver.Rules = append(driver.Rules, sarifRule{
+			ID:                   rule.id,
+			ShortDescription:     sarifMessage{Text: rule.description},
+			DefaultConfiguration: sarifRuleConfig{Level: "error"},
+		})
+	}
+
+	results := []sarifResult{}
+	for _, suite := range run.Suites {
+		results = append(results, paths.suiteResults(suite)...)
+	}
+
+	report := sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Results: results}},
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return fmt.Errorf("failed to write SARIF report: %w", err)
+	}
+	return nil
+}
+
+// WriteSARIFFile writes the SARIF report of run at path, creating its
+// directory when needed and replacing any previous report at once
+func WriteSARIFFile(path string, run *TestRun, root string, dirs map[string]string) error {
+	var buf bytes.Buffer
+	if err := WriteSARIFReport(&buf, run, root, dirs); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create report directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write SARIF report: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write SARIF report: %w", err)
+	}
+	return nil
+}
+
+// suiteResults returns the results of one package: its compiler errors
+// when it did not build, or its failed tests, or the package failure when
+// no test failed
+func (p sarifPaths) suiteResults(suite *TestSuite) []sarifResult {
+	pkg := suiteName(suite)
+	if suite.BuildOutput != "" {
+		if results := p.buildResults(suite); len(results) > 0 {
+			return results
+		}
+	}
+
+	var results []sarifResult
+	for _, test := range suite.Tests {
+		if test.Status != TestStatusFailed || hasFailedSubtest(suite, test) {
+			continue
+		}
+		output := ""
+		if test.Error != nil {
+			output = test.Error.Message
+		}
+		message := test.Name + " failed"
+		if summary := failureSummary(output); summary != "" {
+			message += ": " + summary
+		}
+		result := newSARIFResult(FailureCategory(test), message, p.locate(pkg, output))
+		result.PartialFingerprints = map[string]string{sarifFingerprint: FailureFingerprint(pkg, test)}
+		results = append(results, result)
+	}
+
+	if len(results) == 0 && len(suite.Errors) > 0 {
+		output := packageFailureOutput(suite)
+		category := FailureCategory(&TestResult{Error: &TestError{Message: output}})
+		if suite.HitResourceLimit() {
+			// The limit is reported in the output of the test that was running
+			category = CategoryResourceLimit
+		}
+		message := pkg + " failed"
+		if summary := failureSummary(output); summary != "" {
+			message += ": " + summary
+		}
+		results = append(results, newSARIFResult(category, message, p.locate(pkg, output)))
+	}
+	return results
+}
+
+// buildResults returns a result per compiler error of a package that did
+// not build
+func (p sarifPaths) buildResults(suite *TestSuite) []sarifResult {
+	var results []sarifResult
+	for _, line := range strings.Split(suite.BuildOutput, "\n") {
+		m := compilerErrorRe.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		loc := p.location(suiteName(suite), m[1], m[2], m[3])
+		results = append(results, newSARIFResult(CategoryBuild, m[4], loc))
+	}
+	return results
+}
+
+// hasFailedSubtest reports whether a failed subtest of test is reported,
+// in which case the parent's failure only repeats it
+func hasFailedSubtest(suite *TestSuite, test *TestResult) bool {
+	for _, other := range suite.Tests {
+		if other.Status == TestStatusFailed && strings.HasPrefix(other.Name, test.Name+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// newSARIFResult returns an error-level result for a failure category
+func newSARIFResult(category, message string, loc *sarifLocation) sarifResult {
+	index := 0
+	for i, rule := range sarifRules {
+		if rule.category == category {
+			index = i
+		}
+	}
+	result := sarifResult{
+		RuleID:    sarifRules[index].id,
+		RuleIndex: index,
+		Level:     "error",
+		Message:   sarifMessage{Text: message},
+	}
+	if loc != nil {
+		result.Locations = []sarifLocation{*loc}
+	}
+	return result
+}
+
+// locate returns the first location in output that lies in the project,
+// skipping stack frames of the standard library and dependencies
+func (p sarifPaths) locate(pkg, output string) *sarifLocation {
+	for _, m := range sarifLocationRe.FindAllStringSubmatch(output, -1) {
+		if loc := p.location(pkg, m[1], m[2], m[3]); loc != nil {
+			return loc
+		}
+	}
+	return nil
+}
+
+// location resolves a file:line:column reference of package pkg, or
+// returns nil when the file is not in the project
+func (p sarifPaths) location(pkg, file, line, column string) *sarifLocation {
+	uri, ok := p.resolve(pkg, file)
+	if !ok {
+		return nil
+	}
+	region := &sarifRegion{}
+	region.StartLine, _ = strconv.Atoi(line)
+	region.StartColumn, _ = strconv.Atoi(column)
+	if region.StartLine <= 0 {
+		region = nil
+	}
+	return &sarifLocation{PhysicalLocation: sarifPhysicalLocation{
+		ArtifactLocation: sarifArtifactLocation{URI: uri, URIBaseID: "%SRCROOT%"},
+		Region:           region,
+	}}
+}
+
+// resolve returns a file named in the output of package pkg as a slash
+// path relative to the project root. Tests print bare file names of their
+// package, the compiler prints paths relative to the root, and stack
+// traces print absolute paths.
+func (p sarifPaths) resolve(pkg, file string) (string, bool) {
+	file = filepath.FromSlash(strings.ReplaceAll(file, `\`, "/"))
+	switch {
+	case filepath.IsAbs(file):
+	case !strings.ContainsRune(file, filepath.Separator):
+		dir, ok := p.dirs[pkg]
+		if !ok {
+			return "", false
+		}
+		file = filepath.Join(dir, file)
+	default:
+		file = filepath.Join(p.root, file)
+	}
+	rel, err := filepath.Rel(p.root, file)
+	if err != nil {
+		return "", false
+	}
+	rel = path.Clean(filepath.ToSlash(rel))
+	if rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", false
+	}
+	return rel, true
+}
@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteSARIFReport(t *testing.T) {
+	root := t.TempDir()
+	events := strings.Join([]string{
+		`{"ImportPath":"example.com/app/store [example.com/app/store.test]","Action":"build-output","Output":"# example.com/app/store\n"}`,
+		`{"ImportPath":"example.com/app/store [example.com/app/store.test]","Action":"build-output","Output":"store/db.go:12:5: undefined: conn\n"}`,
+		`{"Action":"start","Package":"example.com/app/store"}`,
+		`{"Action":"output","Package":"example.com/app/store","Output":"FAIL\texample.com/app/store [build failed]\n"}`,
+		`{"Action":"fail","Package":"example.com/app/store","FailedBuild":"example.com/app/store [example.com/app/store.test]"}`,
+		`{"Action":"start","Package":"example.com/app/api"}`,
+		`{"Action":"run","Package":"example.com/app/api","Test":"TestLogin"}`,
+		`{"Action":"output","Package":"example.com/app/api","Test":"TestLogin","Output":"    login_test.go:21: status = 500, want 200\n"}`,
+		`{"Action":"fail","Package":"example.com/app/api","Test":"TestLogin"}`,
+		`{"Action":"run","Package":"example.com/app/api","Test":"TestPanic"}`,
+		`{"Action":"output","Package":"example.com/app/api","Test":"TestPanic","Output":"panic: runtime error: index out of range\n"}`,
+		`{"Action":"output","Package":"example.com/app/api","Test":"TestPanic","Output":"\t/usr/local/go/src/testing/testing.go:1792 +0x1d\n"}`,
+		`{"Action":"output","Package":"example.com/app/api","Test":"TestPanic","Output":"\t` + filepath.ToSlash(filepath.Join(root, "api", "panic_test.go")) + `:9 +0x2e\n"}`,
+		`{"Action":"fail","Package":"example.com/app/api","Test":"TestPanic"}`,
+		`{"Action":"fail","Package":"example.com/app/api"}`,
+	}, "\n")
+	run, err := NewParser().Parse(strings.NewReader(events))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	dirs := map[string]string{"example.com/app/api": filepath.Join(root, "api")}
+	if err := WriteSARIFReport(&buf, run, root, dirs); err != nil {
+		t.Fatal(err)
+	}
+	var report sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("invalid SARIF: %v", err)
+	}
+
+	got := make(map[string]string)
+	for _, result := range report.Runs[0].Results {
+		if len(result.Locations) != 1 {
+			t.Fatalf("%s: locations = %+v", result.RuleID, result.Locations)
+		}
+		loc := result.Locations[0].PhysicalLocation
+		got[result.RuleID] = fmt.Sprintf("%s:%d", loc.ArtifactLocation.URI, loc.Region.StartLine)
+	}
+	want := map[string]string{
+		SARIFRuleBuild:     "store/db.go:12",
+		SARIFRuleAssertion: "api/login_test.go:21",
+		SARIFRulePanic:     "api/panic_test.go:9",
+	}
+	for rule, location := range want {
+		if got[rule] != location {
+			t.Errorf("%s at %q, want %q", rule, got[rule], location)
+		}
+	}
+	if len(report.Runs[0].Tool.Driver.Rules) != len(sarifRules) {
+		t.Errorf("rules = %+v", report.Runs[0].Tool.Driver.Rules)
+	}
+}
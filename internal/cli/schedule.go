@@ -0,0 +1,151 @@
+package cli
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SelectionReason describes why a package was included in a test run
+type SelectionReason string
+
+// Selection reason constants
+const (
+	// ReasonChangedFile indicates a file inside the package changed
+	ReasonChangedFile SelectionReason = "changed file"
+	// ReasonDependency indicates the package depends on a changed package
+	ReasonDependency SelectionReason = "dependency"
+	// ReasonForced indicates the package was explicitly requested
+	ReasonForced SelectionReason = "forced"
+	// ReasonPattern indicates the package matched a wildcard pattern such as ./...
+	ReasonPattern SelectionReason = "pattern"
+)
+
+// ScheduleEntry describes how a single package was selected and executed
+type ScheduleEntry struct {
+	Package   string
+	Reason    SelectionReason
+	Detail    string // Changed file or dependency that caused the selection
+	Order     int    // 1-based execution order, 0 if the package never started
+	Worker    int    // Worker slot reconstructed from observed start/end times
+	Cached    bool   // Result was served from the go test cache
+	StartTime time.Time
+	Duration  time.Duration
+}
+
+// Schedule describes how packages were ordered and assigned to workers in a run
+type Schedule struct {
+	Parallelism int
+	Entries     []*ScheduleEntry
+}
+
+// NumCached returns the number of packages served from the go test cache
+func (s *Schedule) NumCached() int {
+	cached := 0
+	for _, entry := range s.Entries {
+		if entry.Cached {
+			cached++
+		}
+	}
+	return cached
+}
+
+// BuildSchedule explains the selection, ordering, and worker assignment of the
+// packages in a completed run
+func BuildSchedule(workDir string, pkgs []*PackageInfo, opts RunOptions, run *TestRun, parallelism int) *Schedule {
+	schedule := &Schedule{Parallelism: parallelism}
+
+	// Index changed packages by directory so dependents can be identified
+	changedPkgs := make(map[string]string)
+	for _, file := range opts.ChangedFiles {
+		dir := filepath.Dir(absPath(workDir, file))
+		for _, pkg := range pkgs {
+			if pkg.Dir == dir {
+				changedPkgs[pkg.ImportPath] = file
+			}
+		}
+	}
+
+	suites := make(map[string]*TestSuite)
+	if run != nil {
+		for _, suite := range run.Suites {
+			suites[suite.Package] = suite
+		}
+	}
+
+	for _, pkg := range pkgs {
+		entry := &ScheduleEntry{Package: pkg.ImportPath}
+		entry.Reason, entry.Detail = selectionReason(workDir, pkg, opts.Packages, changedPkgs)
+		if suite, ok := suites[pkg.ImportPath]; ok {
+			entry.Cached = suite.Cached
+			entry.StartTime = suite.StartTime
+			entry.Duration = suite.Duration
+		}
+		schedule.Entries = append(schedule.Entries, entry)
+	}
+
+	// Order entries by observed start time; packages that never started go last
+	sort.SliceStable(schedule.Entries, func(i, j int) bool {
+		a, b := schedule.Entries[i], schedule.Entries[j]
+		if a.StartTime.IsZero() != b.StartTime.IsZero() {
+			return !a.StartTime.IsZero()
+		}
+		return a.StartTime.Before(b.StartTime)
+	})
+
+	// Reconstruct worker slots: each package takes the first slot that was
+	// free when it started
+	var slots []time.Time
+	for i, entry := range schedule.Entries {
+		if entry.StartTime.IsZero() {
+			continue
+		}
+		entry.Order = i + 1
+		end := entry.StartTime.Add(entry.Duration)
+		assigned := false
+		for slot, free := range slots {
+			if !free.After(entry.StartTime) {
+				entry.Worker = slot
+				slots[slot] = end
+				assigned = true
+				break
+			}
+		}
+		if !assigned {
+			entry.Worker = len(slots)
+			slots = append(slots, end)
+		}
+	}
+
+	return schedule
+}
+
+// selectionReason determines why a package was selected for the run
+func selectionReason(workDir string, pkg *PackageInfo, patterns []string, changedPkgs map[string]string) (SelectionReason, string) {
+	if file, ok := changedPkgs[pkg.ImportPath]; ok {
+		return ReasonChangedFile, filepath.Base(file)
+	}
+	for _, dep := range pkg.Deps {
+		if _, ok := changedPkgs[dep]; ok {
+			return ReasonDependency, dep
+		}
+	}
+	for _, pattern := range patterns {
+		if strings.Contains(pattern, "...") {
+			continue
+		}
+		if pattern == pkg.ImportPath || absPath(workDir, pattern) == pkg.Dir {
+			return ReasonForced, pattern
+		}
+	}
+	return ReasonPattern, ""
+}
+
+// absPath resolves path relative to workDir unless it is already absolute
+func absPath(workDir, path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(workDir, path)
+}
@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildSchedule(t *testing.T) {
+	workDir := "/work"
+	pkgs := []*PackageInfo{
+		{ImportPath: "example.com/api", Dir: "/work/api", Deps: []string{"example.com/store"}},
+		{ImportPath: "example.com/store", Dir: "/work/store"},
+		{ImportPath: "example.com/util", Dir: "/work/util"},
+		{ImportPath: "example.com/web", Dir: "/work/web"},
+	}
+
+	now := time.Now()
+	run := &TestRun{
+		Suites: []*TestSuite{
+			{Package: "example.com/store", StartTime: now, Duration: 100 * time.Millisecond},
+			{Package: "example.com/api", StartTime: now.Add(10 * time.Millisecond), Duration: 50 * time.Millisecond},
+			{Package: "example.com/util", StartTime: now.Add(200 * time.Millisecond), Duration: 10 * time.Millisecond, Cached: true},
+		},
+	}
+
+	opts := RunOptions{
+		Packages:     []string{"./...", "./util"},
+		ChangedFiles: []string{"/work/store/store.go"},
+	}
+
+	schedule := BuildSchedule(workDir, pkgs, opts, run, 2)
+
+	want := []struct {
+		pkg    string
+		reason SelectionReason
+		detail string
+		order  int
+		worker int
+		cached bool
+	}{
+		{"example.com/store", ReasonChangedFile, "store.go", 1, 0, false},
+		{"example.com/api", ReasonDependency, "example.com/store", 2, 1, false},
+		{"example.com/util", ReasonForced, "./util", 3, 0, true},
+		{"example.com/web", ReasonPattern, "", 0, 0, false},
+	}
+
+	if len(schedule.Entries) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(schedule.Entries), len(want))
+	}
+	for i, w := range want {
+		got := schedule.Entries[i]
+		if got.Package != w.pkg {
+			t.Errorf("entry %d: Package = %s, want %s", i, got.Package, w.pkg)
+		}
+		if got.Reason != w.reason {
+			t.Errorf("%s: Reason = %s, want %s", w.pkg, got.Reason, w.reason)
+		}
+		if got.Detail != w.detail {
+			t.Errorf("%s: Detail = %q, want %q", w.pkg, got.Detail, w.detail)
+		}
+		if got.Order != w.order {
+			t.Errorf("%s: Order = %d, want %d", w.pkg, got.Order, w.order)
+		}
+		if got.Worker != w.worker {
+			t.Errorf("%s: Worker = %d, want %d", w.pkg, got.Worker, w.worker)
+		}
+		if got.Cached != w.cached {
+			t.Errorf("%s: Cached = %v, want %v", w.pkg, got.Cached, w.cached)
+		}
+	}
+
+	if schedule.NumCached() != 1 {
+		t.Errorf("NumCached() = %d, want 1", schedule.NumCached())
+	}
+}
+
+func TestDecodePackageList(t *testing.T) {
+	input := `{"ImportPath":"example.com/a","Dir":"/work/a","TestGoFiles":["a_test.go"]}
+{"ImportPath":"example.com/b","Dir":"/work/b","Deps":["example.com/a"]}`
+
+	pkgs, err := decodePackageList(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("decodePackageList failed: %v", err)
+	}
+	if len(pkgs) != 2 {
+		t.Fatalf("got %d packages, want 2", len(pkgs))
+	}
+	if !pkgs[0].HasTests() {
+		t.Error("expected example.com/a to have tests")
+	}
+	if pkgs[1].HasTests() {
+		t.Error("expected example.com/b to have no tests")
+	}
+	if len(pkgs[1].Deps) != 1 || pkgs[1].Deps[0] != "example.com/a" {
+		t.Errorf("Deps = %v, want [example.com/a]", pkgs[1].Deps)
+	}
+}
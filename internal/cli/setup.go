@@ -0,0 +1,225 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// SetupExecCommand is the hidden go-sentinel command test binaries are run
+// through when a setup timeout or resource limits are set
+const SetupExecCommand = "setup-exec"
+
+// setupTimeoutMessage starts the line written when a test binary is
+// stopped for spending too long in TestMain setup
+const setupTimeoutMessage = "sentinel: TestMain setup timed out"
+
+// setupWatchdog returns the command running a test binary through the setup
+// watchdog of the current executable, which stops a TestMain that hangs
+// before its first test after timeout. go test's own -timeout only starts
+// once TestMain calls m.Run. A zero timeout only applies the resource
+// limits limitsEnv assigns to the binary's directory.
+func setupWatchdog(timeout time.Duration) ([]string, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the go-sentinel executable: %w", err)
+	}
+	return []string{executable, SetupExecCommand, "--timeout=" + timeout.String()}, nil
+}
+
+// setupExecArgs returns the go test flags running each test binary through
+// the setup watchdog
+func setupExecArgs(timeout time.Duration) ([]string, error) {
+	watchdog, err := setupWatchdog(timeout)
+	if err != nil {
+		return nil, err
+	}
+	watchdog[0] = quoteExecArg(watchdog[0])
+	return []string{"-exec", strings.Join(watchdog, " ")}, nil
+}
+
+// quoteExecArg quotes a path for go test -exec when it holds spaces
+func quoteExecArg(s string) string {
+	if !strings.ContainsAny(s, " \t'\"") {
+		return s
+	}
+	if !strings.Contains(s, "'") {
+		return "'" + s + "'"
+	}
+	return `"` + s + `"`
+}
+
+// RunSetupWatchdog runs a test binary with args under limits, passing its
+// output through to stdout and stderr, and stops it when no test has
+// started within timeout, unless timeout is zero. It also stops the binary
+// and every process it started at its package timeout or the run
+// deadline, after giving it a moment to dump its goroutines. Such a binary
+// runs in a process group of its own, out of reach of the terminal, so
+// interrupts and terminations sent to the watchdog are forwarded to the
+// group, which is killed once the binary exits. It returns the exit code
+// for the watchdog to exit with.
+func RunSetupWatchdog(timeout time.Duration, limits ResourceLimits, binaryTimeout BinaryTimeout, binary string, args []string, stdout, stderr io.Writer) (int, error) {
+	var runLimit time.Duration
+	var stoppedBy string
+	if !binaryTimeout.IsZero() {
+		runLimit, stoppedBy = binaryTimeout.limit(time.Now())
+		if runLimit <= 0 {
+			fmt.Fprintf(stdout, "%s: %s passed before it started\nFAIL\n", timeoutMessage, stoppedBy)
+			return 1, nil
+		}
+		args = withoutTestTimeout(args)
+	}
+
+	cmd := exec.Command(binary, args...)
+	cmd.Stdin = os.Stdin
+	if runLimit > 0 {
+		setProcessGroup(cmd)
+	}
+	var signals chan os.Signal
+	if runLimit > 0 {
+		signals = make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(signals)
+	}
+	watch := &limitWatch{}
+	cmd.Stderr = watch.watch(stderr)
+	pipe, err := cmd.StdoutPipe()
+	if err != nil {
+		return 1, fmt.Errorf("failed to run test binary: %w", err)
+	}
+	if err := startLimited(cmd, limits); err != nil {
+		return 1, fmt.Errorf("failed to run test binary: %w", err)
+	}
+
+	var mu sync.Mutex
+	started, stopped, timedOut, interrupted := false, false, false, false
+	if signals != nil {
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			select {
+			case sig := <-signals:
+				mu.Lock()
+				interrupted = true
+				mu.Unlock()
+				signalProcessGroup(cmd, sig)
+				time.AfterFunc(timeoutDumpGrace, func() { killProcessGroup(cmd) })
+			case <-done:
+			}
+		}()
+	}
+	if runLimit > 0 {
+		timer := time.AfterFunc(runLimit, func() {
+			mu.Lock()
+			timedOut = !stopped
+			mu.Unlock()
+			if timedOut {
+				stopProcessGroup(cmd, timeoutDumpGrace)
+			}
+		})
+		defer timer.Stop()
+	}
+	if timeout > 0 {
+		timer := time.AfterFunc(timeout, func() {
+			mu.Lock()
+			defer mu.Unlock()
+			if !started && !timedOut {
+				stopped = true
+				cmd.Process.Kill()
+			}
+		})
+		defer timer.Stop()
+	}
+
+	// Copy the output line by line, watching for the first test to start
+	reader := bufio.NewReader(pipe)
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			mu.Lock()
+			if !started && isTestStart(line) {
+				started = true
+			}
+			mu.Unlock()
+			watch.scan(line)
+			stdout.Write(line)
+		}
+		if readErr != nil {
+			break
+		}
+	}
+	err = cmd.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if stopped {
+		fmt.Fprintf(stdout, "%s after %s; no test started and the test binary was stopped\nFAIL\n", setupTimeoutMessage, timeout)
+		return 1, nil
+	}
+	if interrupted {
+		killProcessGroup(cmd)
+	}
+	if timedOut {
+		killProcessGroup(cmd)
+		fmt.Fprintf(stdout, "%s after %s (%s); its processes were stopped\nFAIL\n", timeoutMessage, runLimit.Round(time.Millisecond), stoppedBy)
+		return 1, nil
+	}
+	if err != nil && cmd.ProcessState != nil {
+		cpu := cmd.ProcessState.UserTime() + cmd.ProcessState.SystemTime()
+		if hit := watch.exceeded(limits, cpu); len(hit) > 0 {
+			fmt.Fprintf(stdout, "%s %s\nFAIL\n", resourceLimitMessage, strings.Join(hit, " and "))
+			return 1, nil
+		}
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return 1, fmt.Errorf("failed to run test binary: %w", err)
+	}
+	return 0, nil
+}
+
+// isTestStart reports whether a line of test binary output announces a
+// test, example, or benchmark, including the framing byte test2json mode
+// puts before it
+func isTestStart(line []byte) bool {
+	line = bytes.TrimPrefix(line, []byte{0x16})
+	return bytes.HasPrefix(line, []byte("=== RUN")) || bytes.HasPrefix(line, []byte("Benchmark"))
+}
+
+// isSetupFailure reports whether a package failed before any of its tests
+// ran, as when TestMain exits or panics during setup, rather than failing
+// to build
+func isSetupFailure(suite *TestSuite) bool {
+	if len(suite.Tests) > 0 {
+		return false
+	}
+	return !strings.Contains(suite.Output, "[build failed]") && !strings.Contains(suite.Output, "[setup failed]")
+}
+
+// SetupTimedOut reports whether a package was stopped by the setup timeout
+func (s *TestSuite) SetupTimedOut() bool {
+	return s.SetupFailed && strings.Contains(s.Output, setupTimeoutMessage)
+}
+
+// setupFailureReason describes how a package failed before any test ran
+func setupFailureReason(suite *TestSuite) string {
+	if suite.SetupTimedOut() {
+		return "setup timed out before any test ran"
+	}
+	if suite.HitResourceLimit() {
+		return "setup exceeded a resource limit before any test ran"
+	}
+	return "setup failed before any test ran"
+}
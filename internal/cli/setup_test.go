@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestSetupWatchdogHelper is the test binary the watchdog tests run: it
+// hangs or starts a test depending on GO_SENTINEL_SETUP_HELPER
+func TestSetupWatchdogHelper(t *testing.T) {
+	switch os.Getenv("GO_SENTINEL_SETUP_HELPER") {
+	case "hang":
+		time.Sleep(time.Minute)
+	case "start":
+		os.Stdout.WriteString("=== RUN   TestSlow\n")
+		time.Sleep(500 * time.Millisecond)
+		os.Stdout.WriteString("--- PASS: TestSlow (0.50s)\n")
+		os.Exit(0)
+	case "allocate":
+		var chunks [][]byte
+		for i := 0; i < 64; i++ {
+			chunks = append(chunks, bytes.Repeat([]byte{1}, 16<<20))
+		}
+		os.Stdout.WriteString(strconv.Itoa(len(chunks)) + " chunks allocated\n")
+		os.Exit(0)
+	case "exit":
+		os.Stdout.WriteString("setup failed\n")
+		os.Exit(3)
+	case "serve":
+		// Start a server that outlives interrupts, as a test's might
+		server := exec.Command(os.Args[0], "-test.run=^TestSetupWatchdogHelper$")
+		server.Env = append(os.Environ(), "GO_SENTINEL_SETUP_HELPER=server")
+		ready, _ := server.StdoutPipe()
+		if err := server.Start(); err != nil {
+			t.Fatal(err)
+		}
+		bufio.NewReader(ready).ReadString('\n')
+		os.Stdout.WriteString("server " + strconv.Itoa(server.Process.Pid) + "\n")
+		time.Sleep(time.Minute)
+	case "server":
+		signal.Ignore(os.Interrupt, syscall.SIGTERM)
+		os.Stdout.WriteString("ready\n")
+		time.Sleep(time.Minute)
+	}
+}
+
+func runSetupHelper(t *testing.T, mode string, timeout time.Duration) (int, string) {
+	t.Helper()
+	return runLimitedHelper(t, mode, timeout, ResourceLimits{})
+}
+
+func runLimitedHelper(t *testing.T, mode string, timeout time.Duration, limits ResourceLimits) (int, string) {
+	t.Helper()
+	t.Setenv("GO_SENTINEL_SETUP_HELPER", mode)
+	var stdout, stderr bytes.Buffer
+	code, err := RunSetupWatchdog(timeout, limits, BinaryTimeout{}, os.Args[0], []string{"-test.run=^TestSetupWatchdogHelper$"}, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("RunSetupWatchdog() error = %v", err)
+	}
+	return code, stdout.String()
+}
+
+func TestRunSetupWatchdog(t *testing.T) {
+	t.Run("stops hung setup", func(t *testing.T) {
+		start := time.Now()
+		code, out := runSetupHelper(t, "hang", 200*time.Millisecond)
+		if code != 1 {
+			t.Errorf("exit code = %d, want 1", code)
+		}
+		if !strings.Contains(out, setupTimeoutMessage) {
+			t.Errorf("output %q does not report the timeout", out)
+		}
+		if elapsed := time.Since(start); elapsed > 30*time.Second {
+			t.Errorf("watchdog took %v to stop the binary", elapsed)
+		}
+	})
+
+	t.Run("leaves started tests running", func(t *testing.T) {
+		code, out := runSetupHelper(t, "start", 200*time.Millisecond)
+		if code != 0 {
+			t.Errorf("exit code = %d, want 0", code)
+		}
+		if strings.Contains(out, setupTimeoutMessage) || !strings.Contains(out, "--- PASS: TestSlow") {
+			t.Errorf("output = %q, want the test to finish", out)
+		}
+	})
+
+	t.Run("passes exit code through", func(t *testing.T) {
+		code, out := runSetupHelper(t, "exit", time.Minute)
+		if code != 3 {
+			t.Errorf("exit code = %d, want 3", code)
+		}
+		if out != "setup failed\n" {
+			t.Errorf("output = %q", out)
+		}
+	})
+}
+
+func TestParser_SetupFailed(t *testing.T) {
+	events := strings.Join([]string{
+		`{"Action":"start","Package":"example.com/db"}`,
+		`{"Action":"output","Package":"example.com/db","Output":"panic: connection refused\n"}`,
+		`{"Action":"output","Package":"example.com/db","Output":"FAIL\texample.com/db\t0.004s\n"}`,
+		`{"Action":"fail","Package":"example.com/db","Elapsed":0.004}`,
+		`{"Action":"start","Package":"example.com/broken"}`,
+		`{"Action":"output","Package":"example.com/broken","Output":"FAIL\texample.com/broken [build failed]\n"}`,
+		`{"Action":"fail","Package":"example.com/broken","Elapsed":0}`,
+		`{"Action":"start","Package":"example.com/api"}`,
+		`{"Action":"run","Package":"example.com/api","Test":"TestA"}`,
+		`{"Action":"fail","Package":"example.com/api","Test":"TestA","Elapsed":0.1}`,
+		`{"Action":"fail","Package":"example.com/api","Elapsed":0.1}`,
+	}, "\n")
+	run, err := NewParser().Parse(strings.NewReader(events))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	want := map[string]bool{"example.com/db": true, "example.com/broken": false, "example.com/api": false}
+	var db *TestSuite
+	for _, suite := range run.Suites {
+		if suite.SetupFailed != want[suite.Package] {
+			t.Errorf("%s: SetupFailed = %v, want %v", suite.Package, suite.SetupFailed, want[suite.Package])
+		}
+		if suite.Package == "example.com/db" {
+			db = suite
+		}
+	}
+	if db == nil {
+		t.Fatal("example.com/db suite missing")
+	}
+
+	var buf bytes.Buffer
+	NewRenderer(&buf).RenderSuite(db)
+	if out := buf.String(); !strings.Contains(out, "setup failed before any test ran") || !strings.Contains(out, "panic: connection refused") {
+		t.Errorf("RenderSuite() = %q, want the setup failure and its output", out)
+	}
+}
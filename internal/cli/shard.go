@@ -0,0 +1,230 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Shard partitioning strategies
+const (
+	ShardByDuration = "duration" // Balanced by the durations recorded in history
+	ShardByHash     = "hash"     // Spread by a hash of the test name
+)
+
+// ShardPlan splits the tests of a suite among the machines of a CI job.
+// Tests are assigned by name: every package's test of that name lands on
+// the same shard, since a shard's -run pattern is shared by all of its
+// packages and would otherwise run a test of the same name elsewhere too.
+type ShardPlan struct {
+	Total  int      `json:"total"`
+	By     string   `json:"by"`
+	Shards []*Shard `json:"shards"`
+
+	Unplanned int `json:"-"` // Tests Complete added to a plan read from a file
+}
+
+// Shard is the part of the suite one machine runs
+type Shard struct {
+	Index    int              `json:"index"` // From 0
+	Tests    []*TestCandidate `json:"tests"` // Sorted by package, then name
+	Estimate time.Duration    `json:"estimate,omitempty"`
+}
+
+// Selection returns the packages and tests to run for the shard
+func (s *Shard) Selection() *TestSelection {
+	return NewTestSelection(s.Tests)
+}
+
+// PlanShards partitions candidates into total shards. With durations in
+// records, the longest tests are placed first, each on the shard with
+// the least work so far; otherwise tests are spread by a hash of their
+// name. Either way the plan only depends on its inputs, so every machine
+// computes the same one when it sees the same tests and history. Machines
+// whose histories differ must pass no records, or share one plan through
+// ReadShardPlan.
+func PlanShards(candidates []*TestCandidate, total int, records []*HistoryRecord) (*ShardPlan, error) {
+	if total < 1 {
+		return nil, fmt.Errorf("shard total must be at least 1, got %d", total)
+	}
+	plan := &ShardPlan{Total: total, By: ShardByHash}
+	for i := 0; i < total; i++ {
+		plan.Shards = append(plan.Shards, &Shard{Index: i})
+	}
+
+	// Units of assignment: the tests of one name across packages
+	byName := make(map[string][]*TestCandidate)
+	var names []string
+	for _, c := range candidates {
+		if _, ok := byName[c.Test]; !ok {
+			names = append(names, c.Test)
+		}
+		byName[c.Test] = append(byName[c.Test], c)
+	}
+	sort.Strings(names)
+
+	durations := meanTestDurations(records)
+	if len(durations) == 0 {
+		for _, name := range names {
+			shard := plan.Shards[shardByHash(name, total)]
+			shard.Tests = append(shard.Tests, byName[name]...)
+		}
+		sortShardTests(plan)
+		return plan, nil
+	}
+
+	// Tests without history are assumed to take the median time of those
+	// with it
+	plan.By = ShardByDuration
+	known := make([]time.Duration, 0, len(durations))
+	for _, d := range durations {
+		known = append(known, d)
+	}
+	sort.Slice(known, func(i, j int) bool { return known[i] < known[j] })
+	median := known[len(known)/2]
+
+	weights := make(map[string]time.Duration, len(names))
+	for _, name := range names {
+		for _, c := range byName[name] {
+			d, ok := durations[testKey(c.Package, c.Test)]
+			if !ok {
+				d = median
+			}
+			weights[name] += d
+		}
+	}
+	sort.SliceStable(names, func(i, j int) bool { return weights[names[i]] > weights[names[j]] })
+	for _, name := range names {
+		lightest := plan.Shards[0]
+		for _, shard := range plan.Shards[1:] {
+			if shard.Estimate < lightest.Estimate {
+				lightest = shard
+			}
+		}
+		lightest.Tests = append(lightest.Tests, byName[name]...)
+		lightest.Estimate += weights[name]
+	}
+	sortShardTests(plan)
+	return plan, nil
+}
+
+// shardByHash returns the shard tests of a name are spread to without
+// recorded durations
+func shardByHash(name string, total int) int {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return int(h.Sum32() % uint32(total))
+}
+
+// ReadShardPlan reads a plan written by shard plan --json, so every
+// machine of a CI job runs its part of the same plan
+func ReadShardPlan(path string) (*ShardPlan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read shard plan: %w", err)
+	}
+	var plan ShardPlan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("failed to parse shard plan %s: %w", path, err)
+	}
+	if plan.Total < 1 || len(plan.Shards) != plan.Total {
+		return nil, fmt.Errorf("shard plan %s has %d shards, want its total of %d", path, len(plan.Shards), plan.Total)
+	}
+	for i, shard := range plan.Shards {
+		if shard == nil || shard.Index != i {
+			return nil, fmt.Errorf("shard plan %s: shard %d is missing or out of order", path, i)
+		}
+	}
+	return &plan, nil
+}
+
+// Complete adds the candidates a plan read from a file lacks, such as
+// tests written since it was made: beside the tests of the same name when
+// it has any, else by a hash of the name, so every machine using the plan
+// adds them to the same shard. It returns how many tests were added.
+func (p *ShardPlan) Complete(candidates []*TestCandidate) int {
+	planned := make(map[string]bool)
+	shardOfName := make(map[string]*Shard)
+	for _, shard := range p.Shards {
+		for _, c := range shard.Tests {
+			planned[testKey(c.Package, c.Test)] = true
+			shardOfName[c.Test] = shard
+		}
+	}
+	added := 0
+	for _, c := range candidates {
+		if planned[testKey(c.Package, c.Test)] {
+			continue
+		}
+		shard, ok := shardOfName[c.Test]
+		if !ok {
+			shard = p.Shards[shardByHash(c.Test, p.Total)]
+			shardOfName[c.Test] = shard
+		}
+		shard.Tests = append(shard.Tests, c)
+		planned[testKey(c.Package, c.Test)] = true
+		added++
+	}
+	if added > 0 {
+		sortShardTests(p)
+	}
+	p.Unplanned = added
+	return added
+}
+
+// Hash identifies the assignment of tests to shards, so machines can tell
+// when they split a suite differently
+func (p *ShardPlan) Hash() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\x00", p.Total)
+	for _, shard := range p.Shards {
+		fmt.Fprintf(h, "shard %d\x00", shard.Index)
+		for _, c := range shard.Tests {
+			fmt.Fprintf(h, "%s\x00%s\x00", c.Package, c.Test)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
+
+// meanTestDurations returns the mean duration of each top-level test that
+// passed or failed in records, keyed by package and test
+func meanTestDurations(records []*HistoryRecord) map[string]time.Duration {
+	sums := make(map[string]time.Duration)
+	counts := make(map[string]int)
+	for _, rec := range records {
+		for _, pkg := range rec.Packages {
+			for _, test := range pkg.Tests {
+				if strings.Contains(test.Name, "/") || (test.Status != TestStatusPassed && test.Status != TestStatusFailed) {
+					continue
+				}
+				key := testKey(pkg.Package, test.Name)
+				sums[key] += test.Duration
+				counts[key]++
+			}
+		}
+	}
+	means := make(map[string]time.Duration, len(sums))
+	for key, sum := range sums {
+		means[key] = sum / time.Duration(counts[key])
+	}
+	return means
+}
+
+// sortShardTests orders each shard's tests by package, then name
+func sortShardTests(plan *ShardPlan) {
+	for _, shard := range plan.Shards {
+		sort.Slice(shard.Tests, func(i, j int) bool {
+			a, b := shard.Tests[i], shard.Tests[j]
+			if a.Package != b.Package {
+				return a.Package < b.Package
+			}
+			return a.Test < b.Test
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package cli
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func shardCandidates() []*TestCandidate {
+	return []*TestCandidate{
+		{Package: "example.com/app/api", Test: "TestLogin"},
+		{Package: "example.com/app/api", Test: "TestLogout"},
+		{Package: "example.com/app/api", Test: "TestMain"},
+		{Package: "example.com/app/store", Test: "TestMain"},
+		{Package: "example.com/app/store", Test: "TestQuery"},
+		{Package: "example.com/app/store", Test: "TestMigrate"},
+	}
+}
+
+// shardOf returns the shard holding pkg's test
+func shardOf(plan *ShardPlan, pkg, test string) int {
+	for _, shard := range plan.Shards {
+		for _, c := range shard.Tests {
+			if c.Package == pkg && c.Test == test {
+				return shard.Index
+			}
+		}
+	}
+	return -1
+}
+
+func TestPlanShards_Hash(t *testing.T) {
+	plan, err := PlanShards(shardCandidates(), 3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.By != ShardByHash || len(plan.Shards) != 3 {
+		t.Fatalf("plan = %d shards by %s, want 3 by %s", len(plan.Shards), plan.By, ShardByHash)
+	}
+
+	// The order tests are found in does not change the plan
+	reversed := shardCandidates()
+	for i, j := 0, len(reversed)-1; i < j; i, j = i+1, j-1 {
+		reversed[i], reversed[j] = reversed[j], reversed[i]
+	}
+	again, _ := PlanShards(reversed, 3, nil)
+	if !reflect.DeepEqual(plan, again) {
+		t.Error("plan depends on the order of the candidates")
+	}
+
+	total := 0
+	for _, shard := range plan.Shards {
+		total += len(shard.Tests)
+	}
+	if total != len(shardCandidates()) {
+		t.Errorf("shards hold %d tests, want %d", total, len(shardCandidates()))
+	}
+	if shardOf(plan, "example.com/app/api", "TestMain") != shardOf(plan, "example.com/app/store", "TestMain") {
+		t.Error("tests of the same name were split across shards")
+	}
+}
+
+func TestPlanShards_Duration(t *testing.T) {
+	pkg := func(name string, tests map[string]time.Duration) *PackageRecord {
+		p := &PackageRecord{Package: name}
+		for test, d := range tests {
+			p.Tests = append(p.Tests, &TestRecord{Name: test, Status: TestStatusPassed, Duration: d})
+		}
+		return p
+	}
+	records := []*HistoryRecord{
+		{Packages: []*PackageRecord{
+			pkg("example.com/app/api", map[string]time.Duration{"TestLogin": 8 * time.Second, "TestLogout": time.Second, "TestMain": time.Second}),
+			pkg("example.com/app/store", map[string]time.Duration{"TestMain": time.Second, "TestQuery": 4 * time.Second, "TestQuery/slow": time.Minute}),
+		}},
+		{Packages: []*PackageRecord{
+			pkg("example.com/app/api", map[string]time.Duration{"TestLogin": 6 * time.Second}),
+		}},
+	}
+	plan, err := PlanShards(shardCandidates(), 2, records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.By != ShardByDuration {
+		t.Fatalf("plan by %s, want %s", plan.By, ShardByDuration)
+	}
+
+	// TestLogin (7s mean) goes to one shard and TestQuery (4s), both
+	// TestMains (2s), and TestLogout (1s) to the other, leaving TestMigrate
+	// (the 1s median, without history) to the first shard of the tie
+	login := shardOf(plan, "example.com/app/api", "TestLogin")
+	if got := plan.Shards[login].Estimate; got != 8*time.Second {
+		t.Errorf("TestLogin's shard estimate = %s, want 8s", got)
+	}
+	if got := plan.Shards[1-login].Estimate; got != 7*time.Second {
+		t.Errorf("other shard estimate = %s, want 7s", got)
+	}
+	if shardOf(plan, "example.com/app/store", "TestMigrate") != 0 {
+		t.Error("TestMigrate, without history, not placed on the first of the tied shards")
+	}
+
+	sel := plan.Shards[1-login].Selection()
+	if want := []string{"example.com/app/api", "example.com/app/store"}; !reflect.DeepEqual(sel.Packages, want) {
+		t.Errorf("Selection().Packages = %v, want %v", sel.Packages, want)
+	}
+}
+
+func TestPlanShards_InvalidTotal(t *testing.T) {
+	if _, err := PlanShards(shardCandidates(), 0, nil); err == nil {
+		t.Error("PlanShards(0) succeeded, want an error")
+	}
+}
+
+func TestReadShardPlan(t *testing.T) {
+	plan, err := PlanShards(shardCandidates()[:4], 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "plan.json")
+	data, _ := json.Marshal(plan)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadShardPlan(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Hash() != plan.Hash() {
+		t.Errorf("read plan hash = %s, want %s", read.Hash(), plan.Hash())
+	}
+
+	// Tests written since the plan join those of their name, or are
+	// spread by hash
+	if added := read.Complete(shardCandidates()); added != 2 || read.Unplanned != 2 {
+		t.Errorf("Complete() added %d tests, want 2", added)
+	}
+	if read.Hash() == plan.Hash() {
+		t.Error("hash did not change with the added tests")
+	}
+	byHash, _ := PlanShards(shardCandidates(), 2, nil)
+	for _, c := range shardCandidates() {
+		if got, want := shardOf(read, c.Package, c.Test), shardOf(byHash, c.Package, c.Test); got != want {
+			t.Errorf("%s %s on shard %d, want %d", c.Package, c.Test, got, want)
+		}
+	}
+	if added := read.Complete(shardCandidates()); added != 0 {
+		t.Errorf("second Complete() added %d tests, want 0", added)
+	}
+
+	os.WriteFile(path, []byte(`{"total":3,"shards":[{"index":0}]}`), 0644)
+	if _, err := ReadShardPlan(path); err == nil {
+		t.Error("ReadShardPlan() accepted a plan missing shards")
+	}
+}
@@ -0,0 +1,214 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SlowTestWarning configures live warnings for tests running much longer
+// than their history suggests
+type SlowTestWarning struct {
+	Factor     float64       // Warn once a test exceeds its historical p95 by this factor
+	MinElapsed time.Duration // Never warn before a test has run this long
+	MinSamples int           // Passing runs in history needed before a test is judged
+}
+
+// DefaultSlowTestWarning returns the warning policy used when nothing is configured
+func DefaultSlowTestWarning() *SlowTestWarning {
+	return &SlowTestWarning{
+		Factor:     3,
+		MinElapsed: time.Second,
+		MinSamples: 3,
+	}
+}
+
+// slowCheckInterval is how often running tests are compared to their history
+const slowCheckInterval = 250 * time.Millisecond
+
+// TestDurationP95 returns the 95th percentile duration of each test that
+// passed at least minSamples times in records, keyed by package and test
+func TestDurationP95(records []*HistoryRecord, minSamples int) map[string]time.Duration {
+	samples := make(map[string][]time.Duration)
+	for _, rec := range records {
+		for _, pkg := range rec.Packages {
+			for _, test := range pkg.Tests {
+				if test.Status != TestStatusPassed {
+					continue
+				}
+				key := testKey(pkg.Package, test.Name)
+				samples[key] = append(samples[key], test.Duration)
+			}
+		}
+	}
+
+	result := make(map[string]time.Duration, len(samples))
+	for key, durations := range samples {
+		if len(durations) < minSamples {
+			continue
+		}
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+		// Nearest-rank percentile
+		rank := (95*len(durations) + 99) / 100
+		// Keep ratios against near-instant tests finite
+		result[key] = max(durations[rank-1], time.Millisecond)
+	}
+	return result
+}
+
+// testKey identifies a test across packages
+func testKey(pkg, test string) string {
+	return pkg + "\t" + test
+}
+
+// slowTestMonitor follows go test -json output as it is written and
+// reports tests that run far longer than usual while they are still running
+type slowTestMonitor struct {
+	policy *SlowTestWarning
+	p95    map[string]time.Duration
+
+	// warn is called at most once per running test
+	warn func(pkg, test string, elapsed, p95 time.Duration)
+
+	mu      sync.Mutex
+	partial []byte
+	running map[string]time.Time
+	warned  map[string]bool
+	done    chan struct{}
+	stopped chan struct{}
+	now     func() time.Time
+}
+
+// newSlowTestMonitor creates a monitor judging tests against p95
+func newSlowTestMonitor(policy *SlowTestWarning, p95 map[string]time.Duration, warn func(pkg, test string, elapsed, p95 time.Duration)) *slowTestMonitor {
+	return &slowTestMonitor{
+		policy:  policy,
+		p95:     p95,
+		warn:    warn,
+		running: make(map[string]time.Time),
+		warned:  make(map[string]bool),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+		now:     time.Now,
+	}
+}
+
+// Write consumes test output, tracking when tests start and finish. Lines
+// that are not JSON events are ignored.
+func (m *slowTestMonitor) Write(p []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.partial = append(m.partial, p...)
+	for {
+		i := bytes.IndexByte(m.partial, '\n')
+		if i < 0 {
+			break
+		}
+		line := m.partial[:i]
+		m.partial = m.partial[i+1:]
+
+		var event GoTestEvent
+		if err := json.Unmarshal(line, &event); err != nil || event.Test == "" {
+			continue
+		}
+		key := testKey(event.Package, event.Test)
+		switch event.Action {
+		case "run":
+			m.running[key] = m.now()
+		case "pass", "fail", "skip":
+			delete(m.running, key)
+		}
+	}
+	return len(p), nil
+}
+
+// slowTestMonitorFor returns a monitor for a run with opts, or nil when
+// warnings are disabled or no test has enough history to judge it by
+func slowTestMonitorFor(opts RunOptions) *slowTestMonitor {
+	if opts.SlowTests == nil || opts.History == nil || opts.Renderer == nil {
+		return nil
+	}
+	records, err := opts.History.Load()
+	if err != nil {
+		log.Printf("Error loading history for slow test warnings: %v", err)
+		return nil
+	}
+	p95 := TestDurationP95(records, opts.SlowTests.MinSamples)
+	if len(p95) == 0 {
+		return nil
+	}
+	return newSlowTestMonitor(opts.SlowTests, p95, opts.Renderer.RenderSlowTest)
+}
+
+// run runs cmd like combinedOutput, also copying its output to tee when
+// not nil, while watching it for slow tests. Warnings are only timely for
+// the package go test is currently streaming; output of packages running
+// alongside it is buffered by go test.
+func (m *slowTestMonitor) run(cmd *exec.Cmd, priority *ProcessPriority, tee io.Writer) ([]byte, error) {
+	m.start()
+	defer m.stop()
+	if tee != nil {
+		return combinedOutput(cmd, priority, io.MultiWriter(m, tee))
+	}
+	return combinedOutput(cmd, priority, m)
+}
+
+// start checks running tests periodically until stop is called
+func (m *slowTestMonitor) start() {
+	go func() {
+		defer close(m.stopped)
+		ticker := time.NewTicker(slowCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.done:
+				return
+			case <-ticker.C:
+				m.check()
+			}
+		}
+	}()
+}
+
+// stop ends periodic checks, waiting for a check underway to finish
+func (m *slowTestMonitor) stop() {
+	close(m.done)
+	<-m.stopped
+}
+
+// check warns about running tests that exceeded their threshold
+func (m *slowTestMonitor) check() {
+	m.mu.Lock()
+	type slow struct {
+		key          string
+		elapsed, p95 time.Duration
+	}
+	var found []slow
+	now := m.now()
+	for key, started := range m.running {
+		p95, ok := m.p95[key]
+		if !ok || m.warned[key] {
+			continue
+		}
+		elapsed := now.Sub(started)
+		if elapsed < m.policy.MinElapsed || float64(elapsed) < float64(p95)*m.policy.Factor {
+			continue
+		}
+		m.warned[key] = true
+		found = append(found, slow{key, elapsed, p95})
+	}
+	m.mu.Unlock()
+
+	sort.Slice(found, func(i, j int) bool { return found[i].key < found[j].key })
+	for _, s := range found {
+		pkg, test, _ := strings.Cut(s.key, "\t")
+		m.warn(pkg, test, s.elapsed, s.p95)
+	}
+}
@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTestDurationP95(t *testing.T) {
+	var records []*HistoryRecord
+	for i := 1; i <= 20; i++ {
+		records = append(records, &HistoryRecord{Packages: []*PackageRecord{{
+			Package: "example.com/pkg",
+			Tests: []*TestRecord{
+				{Name: "TestImportCSV", Status: TestStatusPassed, Duration: time.Duration(i) * 100 * time.Millisecond},
+				{Name: "TestRare", Status: TestStatusFailed, Duration: time.Second},
+			},
+		}}})
+	}
+	records[0].Packages[0].Tests = append(records[0].Packages[0].Tests,
+		&TestRecord{Name: "TestNew", Status: TestStatusPassed, Duration: time.Second})
+
+	p95 := TestDurationP95(records, 3)
+	if got, want := p95[testKey("example.com/pkg", "TestImportCSV")], 1900*time.Millisecond; got != want {
+		t.Errorf("p95 of TestImportCSV = %v, want %v", got, want)
+	}
+	if _, ok := p95[testKey("example.com/pkg", "TestRare")]; ok {
+		t.Error("Failed runs should not contribute durations")
+	}
+	if _, ok := p95[testKey("example.com/pkg", "TestNew")]; ok {
+		t.Error("Tests with too few samples should be skipped")
+	}
+}
+
+func TestSlowTestMonitor(t *testing.T) {
+	type warning struct {
+		test    string
+		elapsed time.Duration
+	}
+	var warnings []warning
+	p95 := map[string]time.Duration{
+		testKey("example.com/pkg", "TestImportCSV"): 2 * time.Second,
+		testKey("example.com/pkg", "TestFast"):      100 * time.Millisecond,
+	}
+	m := newSlowTestMonitor(DefaultSlowTestWarning(), p95, func(pkg, test string, elapsed, p95 time.Duration) {
+		warnings = append(warnings, warning{test, elapsed})
+	})
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+
+	// Events may arrive split across writes
+	m.Write([]byte(`{"Action":"run","Package":"example.com/pkg","Test":"TestImportCSV"}` + "\n" + `{"Action":"run","Package":"exa`))
+	m.Write([]byte(`mple.com/pkg","Test":"TestFast"}` + "\n" + "not json\n"))
+
+	now = now.Add(500 * time.Millisecond)
+	m.check()
+	if len(warnings) != 0 {
+		t.Fatalf("Expected no warnings before the minimum elapsed time, got %+v", warnings)
+	}
+
+	m.Write([]byte(`{"Action":"pass","Package":"example.com/pkg","Test":"TestFast"}` + "\n"))
+	now = now.Add(6 * time.Second)
+	m.check()
+	m.check()
+	if len(warnings) != 1 || warnings[0].test != "TestImportCSV" || warnings[0].elapsed != 6500*time.Millisecond {
+		t.Errorf("warnings = %+v, want one for TestImportCSV after 6.5s", warnings)
+	}
+}
@@ -0,0 +1,171 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/newbpydev/go-sentinel/pkg/snapshot"
+)
+
+// snapshotReviewModel steps through pending snapshots, showing the diff of
+// each and accepting, rejecting, or skipping it
+type snapshotReviewModel struct {
+	pending []*PendingSnapshot
+	current int
+	hunks   []snapshot.Hunk
+	offset  int // First visible diff line
+	height  int // Visible rows of the diff
+	err     error
+	done    bool
+
+	accepted, rejected, skipped int
+}
+
+// defaultReviewHeight is the number of diff lines shown before the
+// terminal size is known
+const defaultReviewHeight = 20
+
+// newSnapshotReviewModel creates a review of pending
+func newSnapshotReviewModel(pending []*PendingSnapshot) snapshotReviewModel {
+	m := snapshotReviewModel{pending: pending, height: defaultReviewHeight}
+	m.load()
+	return m
+}
+
+// load reads the diff of the current snapshot
+func (m *snapshotReviewModel) load() {
+	m.offset = 0
+	if m.current >= len(m.pending) {
+		m.done = true
+		return
+	}
+	lines, err := m.pending[m.current].Diff()
+	if err != nil {
+		m.err = err
+		m.done = true
+		return
+	}
+	m.hunks = snapshot.Hunks(lines, 3)
+}
+
+// Init implements tea.Model
+func (m snapshotReviewModel) Init() tea.Cmd {
+	return nil
+}
+
+// Update implements tea.Model
+func (m snapshotReviewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		// Leave room for the title, the progress line, and the help line
+		m.height = max(msg.Height-4, 1)
+		return m, nil
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "esc", "q":
+			m.done = true
+			return m, tea.Quit
+		case "a":
+			m.decide(m.pending[m.current].Accept, &m.accepted)
+		case "r":
+			m.decide(m.pending[m.current].Reject, &m.rejected)
+		case "s", "right", "n":
+			m.decide(nil, &m.skipped)
+		case "up", "k":
+			m.offset = max(m.offset-1, 0)
+		case "down", "j":
+			m.offset = min(m.offset+1, max(len(m.hunks)-m.height, 0))
+		case "pgup":
+			m.offset = max(m.offset-m.height, 0)
+		case "pgdown", " ":
+			m.offset = min(m.offset+m.height, max(len(m.hunks)-m.height, 0))
+		}
+		if m.done {
+			return m, tea.Quit
+		}
+	}
+	return m, nil
+}
+
+// decide applies a decision to the current snapshot and moves to the next
+func (m *snapshotReviewModel) decide(apply func() error, count *int) {
+	if apply != nil {
+		if err := apply(); err != nil {
+			m.err = err
+			m.done = true
+			return
+		}
+	}
+	*count++
+	m.current++
+	m.load()
+}
+
+// View implements tea.Model
+func (m snapshotReviewModel) View() string {
+	if m.done {
+		return ""
+	}
+	p := m.pending[m.current]
+	var b strings.Builder
+	b.WriteString(pickerCursorStyle.Render(p.Rel) + "\n")
+	b.WriteString(dimStyle.Render(fmt.Sprintf("  snapshot %d of %d · - snapshot + new value", m.current+1, len(m.pending))) + "\n")
+
+	end := min(m.offset+m.height, len(m.hunks))
+	for _, hunk := range m.hunks[m.offset:end] {
+		b.WriteString(renderHunk(hunk) + "\n")
+	}
+	b.WriteString(dimStyle.Render("  a accept · r reject · s skip · ↑/↓ scroll · q quit"))
+	return b.String()
+}
+
+// renderHunk renders a diff line in color
+func renderHunk(hunk snapshot.Hunk) string {
+	if hunk.Skipped > 0 {
+		return dimStyle.Render(fmt.Sprintf("  @@ %d unchanged %s @@", hunk.Skipped, pluralize("line", hunk.Skipped)))
+	}
+	line := fmt.Sprintf("%c %s", hunk.Line.Kind, hunk.Line.Text)
+	switch hunk.Line.Kind {
+	case snapshot.Removed:
+		return errorStyle.Render(line)
+	case snapshot.Added:
+		return successStyle.Render(line)
+	}
+	return line
+}
+
+// SnapshotReview counts the decisions of a snapshot review
+type SnapshotReview struct {
+	Accepted, Rejected, Skipped int
+}
+
+// ReviewSnapshots shows the diff of each pending snapshot and lets the
+// user accept, reject, or skip it. Snapshots not reached before the user
+// quits stay pending.
+func ReviewSnapshots(pending []*PendingSnapshot) (*SnapshotReview, error) {
+	if len(pending) == 0 {
+		return &SnapshotReview{}, nil
+	}
+	final, err := tea.NewProgram(newSnapshotReviewModel(pending)).Run()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run snapshot review: %w", err)
+	}
+	m := final.(snapshotReviewModel)
+	return &SnapshotReview{Accepted: m.accepted, Rejected: m.rejected, Skipped: m.skipped}, m.err
+}
+
+// FormatSnapshotDiff renders the diff of a pending snapshot in color, for
+// printing outside the review
+func FormatSnapshotDiff(p *PendingSnapshot) (string, error) {
+	lines, err := p.Diff()
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, hunk := range snapshot.Hunks(lines, 3) {
+		b.WriteString(renderHunk(hunk) + "\n")
+	}
+	return b.String(), nil
+}
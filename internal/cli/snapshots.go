@@ -0,0 +1,242 @@
+package cli
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/newbpydev/go-sentinel/pkg/sentinelio"
+	"github.com/newbpydev/go-sentinel/pkg/snapshot"
+)
+
+// Snapshot is a golden snapshot reported by a test through package
+// snapshot
+type Snapshot struct {
+	Package string
+	Test    string
+	Path    string // Relative to the package directory
+	State   string // snapshot.StateCreated, StateUpdated, or StateMismatch
+}
+
+// SnapshotSummary counts the snapshots a run created, updated, or found
+// to differ
+type SnapshotSummary struct {
+	Created    int
+	Updated    int
+	Mismatched []*Snapshot
+}
+
+// Empty reports whether the run reported no snapshots
+func (s *SnapshotSummary) Empty() bool {
+	return s.Created == 0 && s.Updated == 0 && len(s.Mismatched) == 0
+}
+
+// String describes the counts, e.g. "2 created, 1 mismatched"
+func (s *SnapshotSummary) String() string {
+	var parts []string
+	if s.Created > 0 {
+		parts = append(parts, fmt.Sprintf("%d created", s.Created))
+	}
+	if s.Updated > 0 {
+		parts = append(parts, fmt.Sprintf("%d updated", s.Updated))
+	}
+	if len(s.Mismatched) > 0 {
+		parts = append(parts, fmt.Sprintf("%d mismatched", len(s.Mismatched)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// SummarizeSnapshots collects the snapshots reported by the tests of a run
+func SummarizeSnapshots(run *TestRun) *SnapshotSummary {
+	summary := &SnapshotSummary{}
+	for _, suite := range run.Suites {
+		for _, test := range suite.Tests {
+			for _, a := range test.Annotations {
+				if a.Kind != sentinelio.KindSnapshot {
+					continue
+				}
+				switch a.State {
+				case snapshot.StateCreated:
+					summary.Created++
+				case snapshot.StateUpdated:
+					summary.Updated++
+				case snapshot.StateMismatch:
+					summary.Mismatched = append(summary.Mismatched, &Snapshot{Package: suite.Package, Test: test.Name, Path: a.Name, State: a.State})
+				}
+			}
+		}
+	}
+	sort.Slice(summary.Mismatched, func(i, j int) bool {
+		if summary.Mismatched[i].Package != summary.Mismatched[j].Package {
+			return summary.Mismatched[i].Package < summary.Mismatched[j].Package
+		}
+		return summary.Mismatched[i].Path < summary.Mismatched[j].Path
+	})
+	return summary
+}
+
+// snapshotEnv returns the environment variables setting the snapshot mode
+// of a run. Missing snapshots are always recorded under go-sentinel.
+func snapshotEnv(opts RunOptions) []string {
+	env := []string{snapshot.EnvMode + "=" + snapshot.ModeRecord}
+	if opts.UpdateSnapshots != "" {
+		env = append(env, snapshot.EnvUpdate+"="+opts.UpdateSnapshots)
+	}
+	return env
+}
+
+// PendingSnapshot is the new value of a mismatched snapshot, waiting to be
+// accepted or rejected
+type PendingSnapshot struct {
+	Path    string // The snapshot
+	NewPath string // The new value beside it
+	Rel     string // The snapshot, relative to the project directory
+	Name    string // The snapshot within its directory, without extension, e.g. TestInvoice/eu-totals
+}
+
+// FindPendingSnapshots returns the new values left by mismatched snapshots
+// under dir whose name matches run, or all of them when run is nil
+func FindPendingSnapshots(dir string, run *regexp.Regexp) ([]*PendingSnapshot, error) {
+	var pending []*PendingSnapshot
+	err := walkSnapshotDirs(dir, func(snapshotDir string) error {
+		return filepath.WalkDir(snapshotDir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !strings.HasSuffix(path, snapshot.NewExt) {
+				return err
+			}
+			file, err := filepath.Rel(snapshotDir, path)
+			if err != nil {
+				return err
+			}
+			name := strings.TrimSuffix(filepath.ToSlash(file), snapshot.NewExt)
+			if run != nil && !run.MatchString(name) {
+				return nil
+			}
+			p := &PendingSnapshot{
+				Path:    strings.TrimSuffix(path, snapshot.NewExt) + snapshot.Ext,
+				NewPath: path,
+				Name:    name,
+			}
+			if p.Rel, err = filepath.Rel(dir, p.Path); err != nil {
+				return err
+			}
+			pending = append(pending, p)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find pending snapshots: %w", err)
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Rel < pending[j].Rel })
+	return pending, nil
+}
+
+// Accept replaces the snapshot with its new value
+func (p *PendingSnapshot) Accept() error {
+	if err := os.Rename(p.NewPath, p.Path); err != nil {
+		return fmt.Errorf("failed to accept snapshot: %w", err)
+	}
+	return nil
+}
+
+// Reject discards the new value, keeping the snapshot
+func (p *PendingSnapshot) Reject() error {
+	if err := os.Remove(p.NewPath); err != nil {
+		return fmt.Errorf("failed to reject snapshot: %w", err)
+	}
+	return nil
+}
+
+// Diff returns the line diff from the snapshot to its new value
+func (p *PendingSnapshot) Diff() ([]snapshot.DiffLine, error) {
+	old, err := os.ReadFile(p.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	next, err := os.ReadFile(p.NewPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read new snapshot: %w", err)
+	}
+	return snapshot.Diff(string(old), string(next)), nil
+}
+
+// StaleSnapshot is a snapshot whose test no longer exists
+type StaleSnapshot struct {
+	Package string
+	Path    string
+	Rel     string // Relative to the project directory
+	Test    string // Top-level test the file is named after
+}
+
+// FindStaleSnapshots returns the snapshots of the packages matching
+// patterns whose top-level test is gone. Subtests are not checked, as they
+// are only known when their test runs.
+func FindStaleSnapshots(dir string, patterns []string) ([]*StaleSnapshot, error) {
+	catalogs, err := BuildCatalog(dir, patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	var stale []*StaleSnapshot
+	for _, catalog := range catalogs {
+		tests := make(map[string]bool, len(catalog.Tests))
+		for _, entry := range catalog.Tests {
+			tests[entry.Name] = true
+		}
+		snapshotDir := filepath.Join(catalog.Dir, filepath.FromSlash(snapshot.Dir))
+		err := filepath.WalkDir(snapshotDir, func(path string, d fs.DirEntry, err error) error {
+			if os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			if err != nil || d.IsDir() || !strings.HasSuffix(path, snapshot.Ext) {
+				return err
+			}
+			file, err := filepath.Rel(snapshotDir, path)
+			if err != nil {
+				return err
+			}
+			test := snapshot.TestName(file)
+			if tests[test] {
+				return nil
+			}
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			stale = append(stale, &StaleSnapshot{Package: catalog.ImportPath, Path: path, Rel: rel, Test: test})
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to find stale snapshots: %w", err)
+		}
+	}
+	return stale, nil
+}
+
+// walkSnapshotDirs calls fn for each snapshot directory under dir,
+// skipping hidden directories, vendor, and nested modules
+func walkSnapshotDirs(dir string, fn func(snapshotDir string) error) error {
+	suffix := string(filepath.Separator) + filepath.FromSlash(snapshot.Dir)
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() || path == dir {
+			return err
+		}
+		name := d.Name()
+		if strings.HasPrefix(name, ".") || name == "vendor" {
+			return filepath.SkipDir
+		}
+		if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+			return filepath.SkipDir
+		}
+		if strings.HasSuffix(path, suffix) {
+			if err := fn(path); err != nil {
+				return err
+			}
+			return filepath.SkipDir
+		}
+		return nil
+	})
+}
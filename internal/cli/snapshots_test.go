@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/newbpydev/go-sentinel/pkg/sentinelio"
+	"github.com/newbpydev/go-sentinel/pkg/snapshot"
+)
+
+// writePendingSnapshot writes a snapshot and a differing new value for it
+func writePendingSnapshot(t *testing.T, dir, name, old, next string) {
+	t.Helper()
+	path := filepath.Join(dir, filepath.FromSlash(snapshot.Dir), filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path+snapshot.Ext, []byte(old), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path+snapshot.NewExt, []byte(next), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindPendingSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	writePendingSnapshot(t, filepath.Join(dir, "billing"), "TestInvoice", "a\n", "b\n")
+	writePendingSnapshot(t, filepath.Join(dir, "billing"), "TestInvoice/eu-totals", "1\n", "2\n")
+	writePendingSnapshot(t, filepath.Join(dir, ".cache"), "TestHidden", "a\n", "b\n")
+
+	pending, err := FindPendingSnapshots(dir, nil)
+	if err != nil {
+		t.Fatalf("FindPendingSnapshots() error = %v", err)
+	}
+	if len(pending) != 2 || pending[0].Name != "TestInvoice" || pending[1].Name != "TestInvoice/eu-totals" {
+		t.Fatalf("FindPendingSnapshots() = %+v", pending)
+	}
+
+	filtered, err := FindPendingSnapshots(dir, regexp.MustCompile("totals"))
+	if err != nil || len(filtered) != 1 {
+		t.Fatalf("FindPendingSnapshots(totals) = %v, %v", filtered, err)
+	}
+
+	if err := pending[0].Accept(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(pending[0].Path); string(data) != "b\n" {
+		t.Errorf("accepted snapshot = %q", data)
+	}
+	if err := pending[1].Reject(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(pending[1].Path); string(data) != "1\n" {
+		t.Errorf("rejected snapshot = %q", data)
+	}
+	if left, _ := FindPendingSnapshots(dir, nil); len(left) != 0 {
+		t.Errorf("pending after accept and reject = %+v", left)
+	}
+}
+
+func TestSnapshotReviewModel(t *testing.T) {
+	dir := t.TempDir()
+	writePendingSnapshot(t, dir, "TestA", "a\n", "a2\n")
+	writePendingSnapshot(t, dir, "TestB", "b\n", "b2\n")
+	writePendingSnapshot(t, dir, "TestC", "c\n", "c2\n")
+	pending, err := FindPendingSnapshots(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var model tea.Model = newSnapshotReviewModel(pending)
+	for _, key := range []string{"a", "r", "s"} {
+		model, _ = model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+	}
+	m := model.(snapshotReviewModel)
+	if !m.done || m.err != nil || m.accepted != 1 || m.rejected != 1 || m.skipped != 1 {
+		t.Fatalf("review = done %v, err %v, %d accepted, %d rejected, %d skipped", m.done, m.err, m.accepted, m.rejected, m.skipped)
+	}
+	if left, _ := FindPendingSnapshots(dir, nil); len(left) != 1 || left[0].Name != "TestC" {
+		t.Errorf("pending after review = %+v, want only TestC", left)
+	}
+}
+
+func TestSummarizeSnapshots(t *testing.T) {
+	annotate := func(path, state string) sentinelio.Annotation {
+		return sentinelio.Annotation{Kind: sentinelio.KindSnapshot, Name: path, State: state}
+	}
+	run := &TestRun{Suites: []*TestSuite{{
+		Package: "example.com/billing",
+		Tests: []*TestResult{
+			{Name: "TestA", Annotations: []sentinelio.Annotation{annotate("testdata/snapshots/TestA.snap", snapshot.StateCreated)}},
+			{Name: "TestB", Annotations: []sentinelio.Annotation{annotate("testdata/snapshots/TestB.snap", snapshot.StateMismatch)}},
+		},
+	}}}
+	summary := SummarizeSnapshots(run)
+	if got := summary.String(); got != "1 created, 1 mismatched" {
+		t.Errorf("String() = %q", got)
+	}
+	if len(summary.Mismatched) != 1 || summary.Mismatched[0].Test != "TestB" {
+		t.Errorf("Mismatched = %+v", summary.Mismatched)
+	}
+}
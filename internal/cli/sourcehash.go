@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// sourceHasher hashes the sources the tests of a package are built from by
+// content, for caches that must notice any edit
+type sourceHasher struct {
+	workDir  string
+	pkgCache *PackageCache
+}
+
+// newSourceHasher creates a source hasher for the module in workDir
+func newSourceHasher(workDir string) sourceHasher {
+	return sourceHasher{workDir: workDir, pkgCache: NewPackageCache(workDir, defaultPackageCacheDir())}
+}
+
+// packages lists the packages matching patterns and the graph of the
+// module's packages, both as built with the -tags of buildFlags, so test
+// files behind those tags count as their packages' files
+func (c sourceHasher) packages(patterns, buildFlags []string) ([]*PackageInfo, *DependencyGraph, error) {
+	tags := buildFlagTags(buildFlags)
+	pkgs, err := c.pkgCache.getWithTags(tags, patterns)
+	if err != nil {
+		return nil, nil, err
+	}
+	module, err := c.pkgCache.getWithTags(tags, []string{"./..."})
+	if err != nil {
+		return nil, nil, err
+	}
+	return pkgs, NewDependencyGraph(module), nil
+}
+
+// sourceHash identifies what the tests of pkg are built and run from: the
+// module's go.mod and go.sum, the build flags, the files of the package,
+// including its testdata, and the files of the module packages its code
+// and tests depend on, through test helpers too. Files are hashed by
+// content and path within the module, so the hash is the same on every
+// machine with the same checkout.
+func (c sourceHasher) sourceHash(pkg *PackageInfo, buildFlags []string, graph *DependencyGraph) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00", c.pkgCache.modHash(), strings.Join(buildFlags, "\x00"))
+	dirs := []string{pkg.Dir}
+	deps := graph.TestDependencies(pkg.ImportPath)
+	deps = append(append(append(deps, pkg.Deps...), pkg.TestImports...), pkg.XTestImports...)
+	for _, dep := range deps {
+		if dir, ok := graph.Dir(dep); ok {
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+	hashFile := func(file string) {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return
+		}
+		rel, err := filepath.Rel(c.workDir, file)
+		if err != nil {
+			rel = file
+		}
+		fmt.Fprintf(h, "%s\x00%x\x00", filepath.ToSlash(rel), sha256.Sum256(data))
+	}
+	for i, dir := range dirs {
+		if i > 0 && dir == dirs[i-1] {
+			continue
+		}
+		files, _ := filepath.Glob(filepath.Join(dir, "*"))
+		for _, file := range files {
+			if info, err := os.Stat(file); err == nil && !info.IsDir() {
+				hashFile(file)
+			}
+		}
+	}
+	filepath.WalkDir(filepath.Join(pkg.Dir, "testdata"), func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.Type().IsRegular() {
+			hashFile(path)
+		}
+		return nil
+	})
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// buildFlagTags returns the build tags set by the -tags flags of buildFlags
+func buildFlagTags(buildFlags []string) []string {
+	var tags []string
+	for i := 0; i < len(buildFlags); i++ {
+		flag := strings.TrimPrefix(buildFlags[i], "-")
+		var value string
+		switch {
+		case strings.HasPrefix(flag, "-tags="), strings.HasPrefix(flag, "tags="):
+			_, value, _ = strings.Cut(flag, "=")
+		case (flag == "tags" || flag == "-tags") && i+1 < len(buildFlags):
+			i++
+			value = buildFlags[i]
+		default:
+			continue
+		}
+		// A later -tags replaces an earlier one, as it does for go build
+		tags = strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' })
+	}
+	return tags
+}
@@ -0,0 +1,366 @@
+package cli
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// stateVersion is the format version written to state archive manifests
+const stateVersion = 1
+
+// Top-level directories of a state archive
+const (
+	stateProjectDir = "project" // Contents of the project's HistoryDir
+	stateCacheDir   = "cache"   // User-level cache metadata for the project
+)
+
+// stateManifest describes a state archive
+type stateManifest struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"createdAt"`
+	WorkDir   string    `json:"workDir"` // Project location on the exporting machine
+}
+
+// StateLocation identifies the sentinel state of one project: everything
+// under its HistoryDir (history, baselines, quarantine files, ...) plus its
+// package cache metadata
+type StateLocation struct {
+	WorkDir  string
+	CacheDir string // User-level cache directory, empty to skip cache metadata
+}
+
+// NewStateLocation returns the state location of the project at workDir,
+// using the default user cache directory
+func NewStateLocation(workDir string) StateLocation {
+	return StateLocation{WorkDir: workDir, CacheDir: defaultPackageCacheDir()}
+}
+
+// projectDir returns the project-local state directory
+func (l StateLocation) projectDir() string {
+	return filepath.Join(l.WorkDir, HistoryDir)
+}
+
+// packageCacheFile returns the package cache file for the project, or ""
+func (l StateLocation) packageCacheFile() string {
+	if l.CacheDir == "" {
+		return ""
+	}
+	return packageCacheFile(l.WorkDir, l.CacheDir)
+}
+
+// ExportState writes the project's state to an archive at dest and returns
+// the number of files written. The compression is chosen from the file
+// extension: .tar.zst, .tar.gz/.tgz, or uncompressed .tar. The archive is
+// written beside dest and renamed into place, so a failed export leaves
+// no truncated archive behind.
+func ExportState(loc StateLocation, dest string) (int, error) {
+	tmp := dest + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create archive: %w", err)
+	}
+	count, err := writeStateArchive(loc, dest, f)
+	if closeErr := f.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to write archive: %w", closeErr)
+	}
+	if err == nil {
+		if renameErr := os.Rename(tmp, dest); renameErr != nil {
+			err = fmt.Errorf("failed to write archive: %w", renameErr)
+		}
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+	return count, nil
+}
+
+// writeStateArchive writes the archive of ExportState to out, compressed
+// as the archive name implies
+func writeStateArchive(loc StateLocation, name string, out io.Writer) (int, error) {
+	w, err := compressWriter(name, out)
+	if err != nil {
+		return 0, err
+	}
+	tw := tar.NewWriter(w)
+
+	manifest, err := json.Marshal(stateManifest{Version: stateVersion, CreatedAt: time.Now(), WorkDir: loc.WorkDir})
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := writeTarFile(tw, "manifest.json", manifest, time.Now()); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err = filepath.WalkDir(loc.projectDir(), func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && p == loc.projectDir() {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(loc.projectDir(), p)
+		if err != nil {
+			return err
+		}
+		if err := addTarFile(tw, path.Join(stateProjectDir, filepath.ToSlash(rel)), p); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to archive state: %w", err)
+	}
+
+	if cacheFile := loc.packageCacheFile(); cacheFile != "" {
+		err := addTarFile(tw, path.Join(stateCacheDir, "packages.json"), cacheFile)
+		if err == nil {
+			count++
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return 0, fmt.Errorf("failed to archive cache metadata: %w", err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return 0, fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return 0, fmt.Errorf("failed to write archive: %w", err)
+	}
+	return count, nil
+}
+
+// stagedFile is an archive entry extracted beside its destination, moved
+// there once the whole archive has been read
+type stagedFile struct {
+	tmp, dest string
+}
+
+// ImportState restores state from an archive written by ExportState and
+// returns the number of files restored. Existing files are only replaced
+// when overwrite is set. Nothing is restored unless the whole archive
+// reads cleanly.
+func ImportState(loc StateLocation, src string, overwrite bool) (int, error) {
+	staged, err := stageState(loc, src, overwrite)
+	if err != nil {
+		for _, file := range staged {
+			os.Remove(file.tmp)
+		}
+		return 0, err
+	}
+	for i, file := range staged {
+		if err := os.Rename(file.tmp, file.dest); err != nil {
+			for _, rest := range staged[i:] {
+				os.Remove(rest.tmp)
+			}
+			return i, fmt.Errorf("failed to restore %s: %w", file.dest, err)
+		}
+	}
+	return len(staged), nil
+}
+
+// stageState extracts the entries of the archive at src beside their
+// destinations. The files staged so far are returned with any error.
+func stageState(loc StateLocation, src string, overwrite bool) ([]stagedFile, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer f.Close()
+
+	r, err := decompressReader(src, f)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	tr := tar.NewReader(r)
+	var staged []stagedFile
+	sawManifest := false
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return staged, fmt.Errorf("failed to read archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := path.Clean(hdr.Name)
+		if name == "manifest.json" {
+			var manifest stateManifest
+			if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+				return staged, fmt.Errorf("invalid manifest: %w", err)
+			}
+			if manifest.Version > stateVersion {
+				return staged, fmt.Errorf("archive format version %d is newer than supported version %d", manifest.Version, stateVersion)
+			}
+			sawManifest = true
+			continue
+		}
+
+		dest, err := stateDestination(loc, name)
+		if err != nil {
+			return staged, err
+		}
+		if dest == "" {
+			continue
+		}
+		if !overwrite {
+			if _, err := os.Stat(dest); err == nil {
+				return staged, fmt.Errorf("%s already exists; use --force to replace existing state", dest)
+			}
+		}
+		tmp, err := extractTarFile(tr, dest, hdr)
+		if err != nil {
+			return staged, err
+		}
+		staged = append(staged, stagedFile{tmp: tmp, dest: dest})
+	}
+
+	if !sawManifest {
+		return staged, fmt.Errorf("%s is not a go-sentinel state archive", src)
+	}
+	return staged, nil
+}
+
+// stateDestination maps an archive entry to its location on this machine.
+// Unknown entries map to "" and are skipped; entries escaping their
+// directory are rejected.
+func stateDestination(loc StateLocation, name string) (string, error) {
+	if strings.HasPrefix(name, "../") || path.IsAbs(name) {
+		return "", fmt.Errorf("invalid archive entry %q", name)
+	}
+	switch {
+	case strings.HasPrefix(name, stateProjectDir+"/"):
+		rel := strings.TrimPrefix(name, stateProjectDir+"/")
+		return filepath.Join(loc.projectDir(), filepath.FromSlash(rel)), nil
+	case name == path.Join(stateCacheDir, "packages.json"):
+		// Cached entries are revalidated against this machine's files on use
+		return loc.packageCacheFile(), nil
+	}
+	return "", nil
+}
+
+// compressWriter wraps w with the compression implied by name
+func compressWriter(name string, w io.Writer) (io.WriteCloser, error) {
+	switch {
+	case strings.HasSuffix(name, ".tar.zst"):
+		zw, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create archive: %w", err)
+		}
+		return zw, nil
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return gzip.NewWriter(w), nil
+	case strings.HasSuffix(name, ".tar"):
+		return nopWriteCloser{w}, nil
+	}
+	return nil, fmt.Errorf("unsupported archive type %q: want .tar.zst, .tar.gz, or .tar", filepath.Base(name))
+}
+
+// decompressReader wraps r with the decompression implied by name
+func decompressReader(name string, r io.Reader) (io.ReadCloser, error) {
+	switch {
+	case strings.HasSuffix(name, ".tar.zst"):
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+		return zr.IOReadCloser(), nil
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+		return gr, nil
+	case strings.HasSuffix(name, ".tar"):
+		return io.NopCloser(r), nil
+	}
+	return nil, fmt.Errorf("unsupported archive type %q: want .tar.zst, .tar.gz, or .tar", filepath.Base(name))
+}
+
+// nopWriteCloser adds a no-op Close to a writer
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// addTarFile copies the file at src into the archive as name
+func addTarFile(tw *tar.Writer, name, src string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{Name: name, Mode: 0600, Size: info.Size(), ModTime: info.ModTime(), Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// writeTarFile writes data into the archive as name
+func writeTarFile(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	hdr := &tar.Header{Name: name, Mode: 0600, Size: int64(len(data)), ModTime: modTime, Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	return nil
+}
+
+// extractTarFile writes the current archive entry to a temporary file
+// beside dest and returns its path
+func extractTarFile(r io.Reader, dest string, hdr *tar.Header) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return "", fmt.Errorf("failed to restore %s: %w", dest, err)
+	}
+	f, err := os.CreateTemp(filepath.Dir(dest), filepath.Base(dest)+".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("failed to restore %s: %w", dest, err)
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to restore %s: %w", dest, err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to restore %s: %w", dest, err)
+	}
+	if err := os.Chtimes(f.Name(), hdr.ModTime, hdr.ModTime); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to restore %s: %w", dest, err)
+	}
+	return f.Name(), nil
+}
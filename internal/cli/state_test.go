@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"archive/tar"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestState_ExportImport(t *testing.T) {
+	for _, ext := range []string{".tar.zst", ".tar.gz", ".tar"} {
+		t.Run(ext, func(t *testing.T) {
+			src := StateLocation{WorkDir: t.TempDir(), CacheDir: t.TempDir()}
+			files := map[string]string{
+				filepath.Join(src.WorkDir, HistoryDir, HistoryFileName):            `{"id":"1"}` + "\n",
+				filepath.Join(src.WorkDir, HistoryDir, "baselines", "api.json"):    `{}`,
+				filepath.Join(src.CacheDir, filepath.Base(src.packageCacheFile())): `{"./...":{}}`,
+			}
+			for path, content := range files {
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("Failed to create dir: %v", err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatalf("Failed to write file: %v", err)
+				}
+			}
+
+			archive := filepath.Join(t.TempDir(), "state"+ext)
+			n, err := ExportState(src, archive)
+			if err != nil {
+				t.Fatalf("ExportState failed: %v", err)
+			}
+			if n != 3 {
+				t.Errorf("ExportState wrote %d files, want 3", n)
+			}
+
+			// Restore on a "new machine" with a different project path
+			dst := StateLocation{WorkDir: t.TempDir(), CacheDir: t.TempDir()}
+			if n, err := ImportState(dst, archive, false); err != nil || n != 3 {
+				t.Fatalf("ImportState = %d, %v, want 3 files", n, err)
+			}
+			data, err := os.ReadFile(filepath.Join(dst.WorkDir, HistoryDir, "baselines", "api.json"))
+			if err != nil || string(data) != `{}` {
+				t.Errorf("Restored baseline = %q, %v", data, err)
+			}
+			if _, err := os.Stat(dst.packageCacheFile()); err != nil {
+				t.Errorf("Expected package cache for the new project path: %v", err)
+			}
+
+			if _, err := ImportState(dst, archive, false); err == nil {
+				t.Error("Expected error when state already exists")
+			}
+			if _, err := ImportState(dst, archive, true); err != nil {
+				t.Errorf("ImportState with overwrite failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestState_UnsupportedArchive(t *testing.T) {
+	loc := StateLocation{WorkDir: t.TempDir()}
+	dir := t.TempDir()
+	if _, err := ExportState(loc, filepath.Join(dir, "state.zip")); err == nil {
+		t.Error("Expected error for unsupported archive type")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) > 0 {
+		t.Errorf("Failed export left %d files behind", len(entries))
+	}
+}
+
+func TestState_ImportRestoresNothingOnError(t *testing.T) {
+	// An archive whose state entries come before a missing manifest
+	archive := filepath.Join(t.TempDir(), "state.tar")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw := tar.NewWriter(f)
+	if err := writeTarFile(tw, stateProjectDir+"/baselines/api.json", []byte(`{}`), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	tw.Close()
+	f.Close()
+
+	dst := StateLocation{WorkDir: t.TempDir()}
+	if _, err := ImportState(dst, archive, false); err == nil {
+		t.Fatal("Expected error for an archive without a manifest")
+	}
+	var left []string
+	filepath.WalkDir(dst.WorkDir, func(p string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			left = append(left, p)
+		}
+		return nil
+	})
+	if len(left) > 0 {
+		t.Errorf("Failed import left files behind: %v", left)
+	}
+}
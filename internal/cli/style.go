@@ -129,10 +129,11 @@ var (
 
 // Style handles terminal styling and formatting
 type Style struct {
-	useColors bool
-	useIcons  bool
-	isWindows bool
-	useEmoji  bool
+	useColors  bool
+	useIcons   bool
+	isWindows  bool
+	useEmoji   bool
+	statusText bool // Label statuses with text as well as icons
 }
 
 // NewStyle creates a new style instance
@@ -143,6 +144,7 @@ func NewStyle(useColors bool) *Style {
 		isWindows: runtime.GOOS == "windows",
 		useEmoji:  true,
 	}
+	s.statusText = statusText
 	s.Detect()
 	return s
 }
@@ -220,10 +222,21 @@ func (s *Style) FormatTestSummary(label string, failed, passed, skipped, total i
 	return fmt.Sprintf("%s %s", formattedLabel, summary)
 }
 
-// FormatTimestamp formats a timestamp line with consistent padding
+// FormatCount formats a summary line holding a plain value
+func (s *Style) FormatCount(label string, value string) string {
+	formattedLabel := fmt.Sprintf("%-12s", label)
+	if s.useColors {
+		formattedLabel = summaryLabelStyle.Render(formattedLabel)
+		value = summaryValueStyle.Render(value)
+	}
+	return fmt.Sprintf("%s %s", formattedLabel, value)
+}
+
+// FormatTimestamp formats a timestamp line with consistent padding. The
+// time is shown in its own location, with its UTC offset.
 func (s *Style) FormatTimestamp(label string, t time.Time) string {
 	labelPart := fmt.Sprintf("%12s  ", summaryLabelStyle.Render(label))
-	timeStr := summaryValueStyle.Render(t.Format("15:04:05"))
+	timeStr := summaryValueStyle.Render(t.Format(timeLayout))
 	return fmt.Sprintf("%s%s", labelPart, timeStr)
 }
 
@@ -258,7 +271,7 @@ func (s *Style) FormatErrorHeader(text string) string {
 			Background(lipgloss.Color("#2D1414")). // Dark red background
 			Render(text)
 	}
-	return "FAILED Tests"
+	return strings.TrimSpace(text)
 }
 
 // FormatFailedSuite formats a failed test suite path
@@ -274,9 +287,7 @@ func (s *Style) FormatFailedTest(name string) string {
 // FormatErrorMessage formats an error message
 func (s *Style) FormatErrorMessage(msg string) string {
 	if s.useColors {
-		return errorStyle.Copy().
-			Foreground(lipgloss.Color(ColorError)).
-			Render(msg)
+		return errorMessageStyle.Render(msg)
 	}
 	return msg
 }
@@ -319,8 +330,12 @@ func (s *Style) FormatErrorValue(value string) string {
 	return value
 }
 
-// StatusIcon returns an icon for the test status
+// StatusIcon returns an icon for the test status, followed by a text
+// label when status text is enabled
 func (s *Style) StatusIcon(status TestStatus) string {
+	if s.statusText {
+		return s.statusLabel(status)
+	}
 	if !s.useIcons || s.isWindows {
 		switch status {
 		case TestStatusPassed:
@@ -352,6 +367,16 @@ func (s *Style) StatusIcon(status TestStatus) string {
 
 // Detect checks terminal capabilities and adjusts settings accordingly
 func (s *Style) Detect() {
+	// --color overrides the environment
+	switch colorMode {
+	case ColorNever:
+		s.useColors = false
+		return
+	case ColorAlways:
+		s.useColors = true
+		return
+	}
+
 	// Check if colors are forced
 	if os.Getenv("FORCE_COLOR") != "" {
 		s.useColors = true
@@ -359,6 +384,11 @@ func (s *Style) Detect() {
 		return
 	}
 
+	// CI logs get plain text
+	if ciMode {
+		s.useColors = false
+	}
+
 	// Check if colors are disabled
 	if os.Getenv("NO_COLOR") != "" {
 		s.useColors = false
@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"strings"
+	"time"
+)
+
+// TestNode is a test with its subtests nested below it, as reported by
+// t.Run. Statuses and durations roll up from the subtests.
+type TestNode struct {
+	Name     string      // Full test name, such as "TestParent/Sub1"
+	Test     *TestResult // Result of the test itself, nil when only its subtests were reported
+	Children []*TestNode
+
+	Status   TestStatus    // Failed when the test or any subtest failed
+	Duration time.Duration // The test's own duration, or its subtests' total without one
+
+	// Subtests at every level below the node, by status
+	Passed  int
+	Failed  int
+	Skipped int
+}
+
+// Subtests returns the number of subtests at every level below the node
+func (n *TestNode) Subtests() int {
+	total := 0
+	for _, child := range n.Children {
+		total += 1 + child.Subtests()
+	}
+	return total
+}
+
+// BuildTestTree nests each subtest under its parent. Top-level tests keep
+// the order in which they were first reported, as do the subtests of each
+// parent, even when parallel tests interleaved their results.
+func BuildTestTree(tests []*TestResult) []*TestNode {
+	var roots []*TestNode
+	nodes := make(map[string]*TestNode)
+	var node func(name string) *TestNode
+	node = func(name string) *TestNode {
+		if n, ok := nodes[name]; ok {
+			return n
+		}
+		n := &TestNode{Name: name}
+		nodes[name] = n
+		if i := strings.LastIndex(name, "/"); i >= 0 {
+			parent := node(name[:i])
+			parent.Children = append(parent.Children, n)
+		} else {
+			roots = append(roots, n)
+		}
+		return n
+	}
+	for _, test := range tests {
+		node(test.Name).Test = test
+	}
+	for _, root := range roots {
+		root.rollUp()
+	}
+	return roots
+}
+
+// rollUp computes the status, duration, and subtest counts of the node
+// from its result and its subtests
+func (n *TestNode) rollUp() {
+	var childDuration time.Duration
+	failed, running, allSkipped := false, false, len(n.Children) > 0
+	for _, child := range n.Children {
+		child.rollUp()
+		childDuration += child.Duration
+		n.Passed += child.Passed
+		n.Failed += child.Failed
+		n.Skipped += child.Skipped
+		switch child.Status {
+		case TestStatusPassed:
+			n.Passed++
+		case TestStatusFailed:
+			n.Failed++
+			failed = true
+		case TestStatusSkipped:
+			n.Skipped++
+		case TestStatusRunning:
+			running = true
+		}
+		if child.Status != TestStatusSkipped {
+			allSkipped = false
+		}
+	}
+
+	switch {
+	case failed || n.Test != nil && n.Test.Status == TestStatusFailed:
+		n.Status = TestStatusFailed
+	case n.Test != nil:
+		n.Status = n.Test.Status
+	case running:
+		n.Status = TestStatusRunning
+	case allSkipped:
+		n.Status = TestStatusSkipped
+	default:
+		n.Status = TestStatusPassed
+	}
+
+	n.Duration = childDuration
+	if n.Test != nil && n.Test.Duration > 0 {
+		n.Duration = n.Test.Duration
+	}
+}
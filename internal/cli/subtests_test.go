@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildTestTree(t *testing.T) {
+	// Parallel tests interleave, and TestB's own result never arrived
+	tests := []*TestResult{
+		{Name: "TestA", Status: TestStatusFailed, Duration: 30 * time.Millisecond},
+		{Name: "TestB/one", Status: TestStatusPassed, Duration: 5 * time.Millisecond},
+		{Name: "TestA/ok", Status: TestStatusPassed, Duration: 10 * time.Millisecond},
+		{Name: "TestA/bad", Status: TestStatusFailed, Duration: 15 * time.Millisecond},
+		{Name: "TestA/bad/deep", Status: TestStatusFailed},
+		{Name: "TestB/two", Status: TestStatusSkipped, Duration: 2 * time.Millisecond},
+	}
+	roots := BuildTestTree(tests)
+	if len(roots) != 2 || roots[0].Name != "TestA" || roots[1].Name != "TestB" {
+		t.Fatalf("roots = %v, want TestA and TestB", roots)
+	}
+
+	a := roots[0]
+	if len(a.Children) != 2 || a.Children[0].Name != "TestA/ok" || a.Children[1].Name != "TestA/bad" {
+		t.Fatalf("TestA children = %v, want ok then bad", a.Children)
+	}
+	if a.Subtests() != 3 || a.Passed != 1 || a.Failed != 2 || a.Duration != 30*time.Millisecond {
+		t.Errorf("TestA = %d subtests, %d passed, %d failed, %s; want 3, 1, 2, 30ms",
+			a.Subtests(), a.Passed, a.Failed, a.Duration)
+	}
+
+	b := roots[1]
+	if b.Test != nil || b.Status != TestStatusPassed || b.Duration != 7*time.Millisecond || b.Skipped != 1 {
+		t.Errorf("TestB = %+v, want a passed parent of 7ms rolled up from its subtests", b)
+	}
+}
+
+func TestRenderer_CollapseSubtests(t *testing.T) {
+	suite := &TestSuite{Package: "example.com/p", Tests: []*TestResult{
+		{Name: "TestPass", Status: TestStatusPassed},
+		{Name: "TestPass/Hidden", Status: TestStatusPassed},
+		{Name: "TestFail", Status: TestStatusFailed},
+		{Name: "TestFail/Shown", Status: TestStatusFailed},
+	}}
+
+	var buf bytes.Buffer
+	r := NewRenderer(&buf)
+	r.RenderSuite(suite)
+	if out := buf.String(); !strings.Contains(out, "Hidden") || !strings.Contains(out, "(1 subtest, 1 failed)") {
+		t.Errorf("expanded output = %q, want every subtest and the rollup", out)
+	}
+
+	buf.Reset()
+	r.SetCollapseSubtests(true)
+	r.RenderSuite(suite)
+	out := buf.String()
+	if strings.Contains(out, "Hidden") || !strings.Contains(out, "(1 subtest)") {
+		t.Errorf("collapsed output = %q, want TestPass without its subtest", out)
+	}
+	if !strings.Contains(out, "    x Shown") {
+		t.Errorf("collapsed output = %q, want the failed subtest nested under its parent", out)
+	}
+}
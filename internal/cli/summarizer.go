@@ -0,0 +1,218 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Limits on the work and data spent summarizing failures
+const (
+	maxSummarizedFailures = 3
+	maxSummaryDiffBytes   = 32 << 10
+	defaultSummaryTimeout = 30 * time.Second
+)
+
+// FailureContext is what a summarizer is told about a failure. It is sent
+// as JSON to summarizer commands and endpoints.
+type FailureContext struct {
+	Package  string `json:"package"`
+	Test     string `json:"test"`
+	Message  string `json:"message"`            // Failure output
+	Location string `json:"location,omitempty"` // file:line of the failure, when known
+	Snippet  string `json:"snippet,omitempty"`  // Source around the failure, when known
+	Diff     string `json:"diff,omitempty"`     // Recent changes to the project, truncated
+}
+
+// FailureSummary is a summarizer's explanation of a failure. Summarizers
+// reply with this as JSON; a command printing plain text is taken as the
+// summary alone.
+type FailureSummary struct {
+	Package    string `json:"-"`
+	Test       string `json:"-"`
+	Summary    string `json:"summary"`
+	Suggestion string `json:"suggestion,omitempty"`
+	Err        error  `json:"-"` // Why the failure could not be summarized
+}
+
+// FailureSummarizer explains test failures, typically by asking a model the
+// team runs or subscribes to
+type FailureSummarizer interface {
+	Summarize(ctx context.Context, fc *FailureContext) (*FailureSummary, error)
+}
+
+// CommandSummarizer runs a command for each failure, writing the
+// FailureContext to its stdin and reading the summary from its stdout
+type CommandSummarizer struct {
+	Command []string
+	Dir     string
+}
+
+// Summarize runs the command for one failure
+func (s *CommandSummarizer) Summarize(ctx context.Context, fc *FailureContext) (*FailureSummary, error) {
+	input, err := json.Marshal(fc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode failure: %w", err)
+	}
+	cmd := exec.CommandContext(ctx, s.Command[0], s.Command[1:]...)
+	cmd.Dir = s.Dir
+	cmd.Stdin = bytes.NewReader(input)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("summarizer failed: %w: %s", err, msg)
+		}
+		return nil, fmt.Errorf("summarizer failed: %w", err)
+	}
+	return parseFailureSummary(out)
+}
+
+// HTTPSummarizer posts each FailureContext to an endpoint and reads the
+// summary from the JSON response
+type HTTPSummarizer struct {
+	URL     string
+	Headers map[string]string // Extra request headers, e.g. Authorization
+	Client  *http.Client
+}
+
+// Summarize posts one failure to the endpoint
+func (s *HTTPSummarizer) Summarize(ctx context.Context, fc *FailureContext) (*FailureSummary, error) {
+	body, err := json.Marshal(fc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode failure: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create summarizer request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range s.Headers {
+		req.Header.Set(name, value)
+	}
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("summarizer failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read summarizer response: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("summarizer failed: %s", resp.Status)
+	}
+	return parseFailureSummary(data)
+}
+
+// parseFailureSummary reads a summarizer's reply: a FailureSummary in JSON,
+// or plain text taken as the summary
+func parseFailureSummary(data []byte) (*FailureSummary, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil, fmt.Errorf("summarizer returned nothing")
+	}
+	if trimmed[0] != '{' {
+		return &FailureSummary{Summary: string(trimmed)}, nil
+	}
+	var summary FailureSummary
+	if err := json.Unmarshal(trimmed, &summary); err != nil {
+		return nil, fmt.Errorf("invalid summarizer response: %w", err)
+	}
+	if summary.Summary == "" && summary.Suggestion == "" {
+		return nil, fmt.Errorf("summarizer returned nothing")
+	}
+	return &summary, nil
+}
+
+// failureContexts describes the first failed top-level tests of run, up to
+// limit, with the project's recent changes
+func (r *Runner) failureContexts(run *TestRun, limit int) []*FailureContext {
+	var contexts []*FailureContext
+	var diff string
+	for _, suite := range run.Suites {
+		for _, test := range suite.Tests {
+			if len(contexts) == limit {
+				return contexts
+			}
+			if test.Status != TestStatusFailed || test.Error == nil || FailureFingerprint(suite.Package, test) == "" {
+				continue // Parents of failed subtests have nothing of their own to explain
+			}
+			if diff == "" {
+				diff = recentDiff(r.workDir)
+			}
+			fc := &FailureContext{
+				Package: suite.Package,
+				Test:    test.Name,
+				Message: strings.TrimSpace(test.Error.Message),
+				Snippet: test.Error.Snippet,
+				Diff:    diff,
+			}
+			if loc := test.Error.Location; loc != nil {
+				fc.Location = fmt.Sprintf("%s:%d", loc.File, loc.Line)
+				if fc.Snippet == "" {
+					fc.Snippet = loc.Snippet
+				}
+			}
+			contexts = append(contexts, fc)
+		}
+	}
+	return contexts
+}
+
+// summarizeFailures asks the summarizer about each of the first failures of
+// run. Failures the summarizer could not explain carry the error instead.
+func (r *Runner) summarizeFailures(run *TestRun, summarizer FailureSummarizer) []*FailureSummary {
+	var summaries []*FailureSummary
+	for _, fc := range r.failureContexts(run, maxSummarizedFailures) {
+		ctx, cancel := context.WithTimeout(context.Background(), defaultSummaryTimeout)
+		summary, err := summarizer.Summarize(ctx, fc)
+		cancel()
+		if err != nil {
+			summary = &FailureSummary{Err: err}
+		}
+		summary.Package = fc.Package
+		summary.Test = fc.Test
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
+
+// recentDiff returns the uncommitted changes in workDir, or the last
+// commit's changes when the tree is clean, truncated to
+// maxSummaryDiffBytes. It is empty outside a git repository.
+func recentDiff(workDir string) string {
+	diff := gitOutput(workDir, "diff", "HEAD", "--")
+	if diff == "" {
+		diff = gitOutput(workDir, "show", "--format=", "HEAD")
+	}
+	if len(diff) > maxSummaryDiffBytes {
+		diff = diff[:maxSummaryDiffBytes] + "\n[diff truncated]\n"
+	}
+	return diff
+}
+
+// gitOutput runs git in dir and returns its output, empty on failure
+func gitOutput(dir string, args ...string) string {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_PAGER=cat")
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return string(out)
+}
@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestHTTPSummarizer(t *testing.T) {
+	var received FailureContext
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("Authorization = %q", got)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("invalid request body: %v", err)
+		}
+		w.Write([]byte(`{"summary": "Off by one in Div", "suggestion": "Round down"}`))
+	}))
+	defer server.Close()
+
+	t.Setenv("SUMMARIZER_TOKEN", "secret")
+	summarizer := SummarizerConfig{URL: server.URL, TokenEnv: "SUMMARIZER_TOKEN"}.FailureSummarizer(t.TempDir())
+	summary, err := summarizer.Summarize(context.Background(), &FailureContext{
+		Package: "example.com/calc",
+		Test:    "TestDiv",
+		Message: "want 2, got 3",
+		Diff:    "+return a/b + 1",
+	})
+	if err != nil {
+		t.Fatalf("Summarize failed: %v", err)
+	}
+	if summary.Summary != "Off by one in Div" || summary.Suggestion != "Round down" {
+		t.Errorf("summary = %+v", summary)
+	}
+	if received.Test != "TestDiv" || received.Diff != "+return a/b + 1" {
+		t.Errorf("endpoint received %+v", received)
+	}
+}
+
+func TestCommandSummarizer_PlainText(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+	summarizer := &CommandSummarizer{Command: []string{"sh", "-c", `grep -o '"test":"[^"]*"'`}, Dir: t.TempDir()}
+	summary, err := summarizer.Summarize(context.Background(), &FailureContext{Test: "TestDiv"})
+	if err != nil {
+		t.Fatalf("Summarize failed: %v", err)
+	}
+	if summary.Summary != `"test":"TestDiv"` {
+		t.Errorf("command did not receive the failure on stdin: %q", summary.Summary)
+	}
+}
+
+func TestParseFailureSummary_Empty(t *testing.T) {
+	for _, reply := range []string{"", "  \n", `{"summary": ""}`} {
+		if _, err := parseFailureSummary([]byte(reply)); err == nil {
+			t.Errorf("parseFailureSummary(%q) succeeded, want error", reply)
+		}
+	}
+}
+
+func TestFailureContexts_SkipsFramingOnlyParents(t *testing.T) {
+	run := &TestRun{Suites: []*TestSuite{{
+		Package: "example.com/calc",
+		Tests: []*TestResult{
+			{Name: "TestDiv", Status: TestStatusFailed, Error: &TestError{Message: "=== RUN   TestDiv\n--- FAIL: TestDiv (0.00s)\n"}},
+			{Name: "TestDiv/zero", Status: TestStatusFailed, Error: &TestError{
+				Message:  "    calc_test.go:20: division by zero\n",
+				Location: &SourceLocation{File: "calc_test.go", Line: 20, Snippet: "if got != want {"},
+			}},
+			{Name: "TestAdd", Status: TestStatusPassed},
+		},
+	}}}
+
+	r := &Runner{workDir: t.TempDir()}
+	contexts := r.failureContexts(run, maxSummarizedFailures)
+	if len(contexts) != 1 {
+		t.Fatalf("got %d failure contexts, want 1", len(contexts))
+	}
+	fc := contexts[0]
+	if fc.Test != "TestDiv/zero" || fc.Location != "calc_test.go:20" || !strings.Contains(fc.Snippet, "got != want") {
+		t.Errorf("context = %+v", fc)
+	}
+}
@@ -0,0 +1,303 @@
+package cli
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Files go-sentinel keeps about itself in the user cache directory, for
+// support bundles
+const (
+	logFileName    = "go-sentinel.log"
+	logFileMaxSize = 1 << 20 // Size at which the log is rotated, keeping one older file
+	crashDirName   = "crashes"
+	maxCrashes     = 5 // Crash reports kept, newest first
+)
+
+// logFile receives a copy of every log message once StartLogFile is called
+var (
+	logMu      sync.Mutex
+	logFile    *os.File
+	logConsole io.Writer = os.Stderr
+)
+
+// logWriter sends log messages to the console and the log file
+type logWriter struct{}
+
+func (logWriter) Write(p []byte) (int, error) {
+	logMu.Lock()
+	defer logMu.Unlock()
+	if logFile != nil {
+		logFile.Write(p)
+	}
+	return logConsole.Write(p)
+}
+
+// StartLogFile keeps a copy of go-sentinel's log messages in a log file in
+// the user cache directory, prefixed by the command line, so they can be
+// attached to bug reports. Messages still go to standard error unless
+// SetLogConsole hides them. It is meant to be called once at startup.
+func StartLogFile() {
+	dir := defaultPackageCacheDir()
+	if dir == "" {
+		return
+	}
+	name := filepath.Join(dir, "logs", logFileName)
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return
+	}
+	if info, err := os.Stat(name); err == nil && info.Size() > logFileMaxSize {
+		os.Rename(name, name+".1")
+	}
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(f, "%s go-sentinel %s\n", time.Now().Format(time.RFC3339), scrubLog([]byte(strings.Join(os.Args[1:], " "))))
+
+	logMu.Lock()
+	logFile = f
+	logMu.Unlock()
+	log.SetOutput(logWriter{})
+}
+
+// SetLogConsole sets where log messages are shown besides the log file,
+// io.Discard to hide them
+func SetLogConsole(w io.Writer) {
+	logMu.Lock()
+	logConsole = w
+	logMu.Unlock()
+	log.SetOutput(logWriter{})
+}
+
+// RecordCrash saves a report of a panic about to end go-sentinel, with
+// the version, command line, and stack, and returns its path, or an empty
+// string when it could not be saved. Only the latest reports are kept.
+func RecordCrash(value any, stack []byte) string {
+	dir := defaultPackageCacheDir()
+	if dir == "" {
+		return ""
+	}
+	dir = filepath.Join(dir, crashDirName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return ""
+	}
+	now := time.Now()
+	report := fmt.Sprintf("go-sentinel crashed at %s\n\n%s\nCommand: go-sentinel %s\n\npanic: %v\n\n%s",
+		now.Format(time.RFC3339), VersionInfo(), strings.Join(os.Args[1:], " "), value, stack)
+	name := filepath.Join(dir, "crash-"+now.Format("20060102-150405.000000000")+".txt")
+	if err := os.WriteFile(name, []byte(report), 0600); err != nil {
+		return ""
+	}
+	crashes := crashReports(dir)
+	for _, old := range crashes[min(len(crashes), maxCrashes):] {
+		os.Remove(old)
+	}
+	return name
+}
+
+// crashReports returns the crash reports in dir, newest first
+func crashReports(dir string) []string {
+	names, _ := filepath.Glob(filepath.Join(dir, "crash-*.txt"))
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	return names
+}
+
+// VersionInfo describes the go-sentinel build: module version, commit,
+// and the Go version it was built with
+func VersionInfo() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "go-sentinel (unknown version), " + runtime.Version()
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "go-sentinel %s, built with %s for %s/%s\n", info.Main.Version, info.GoVersion, runtime.GOOS, runtime.GOARCH)
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision", "vcs.time", "vcs.modified", "-tags", "CGO_ENABLED":
+			fmt.Fprintf(&b, "%s: %s\n", setting.Key, setting.Value)
+		}
+	}
+	return b.String()
+}
+
+// configSecretKeys globs the configuration keys whose values are redacted
+// in support bundles, besides the names of secretEnv: URLs, since webhook
+// URLs are credentials themselves
+var configSecretKeys = []string{"URL", "*URL", "DSN", "*DSN"}
+
+// configLineRe splits a YAML or JSON line into its key and value
+var configLineRe = regexp.MustCompile(`^(\s*(?:-\s*)?"?([A-Za-z0-9_.-]+)"?\s*:\s*)(\S.*?)(,?)$`)
+
+// scrubConfig redacts the secrets of a configuration file: the values of
+// keys that name secrets or URLs, and the credentials in any other URL
+func scrubConfig(data []byte) []byte {
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		m := configLineRe.FindStringSubmatch(line)
+		if m != nil {
+			key := strings.ToUpper(m[2])
+			if matchesEnvGlob(secretEnv, key) || matchesEnvGlob(configSecretKeys, key) {
+				lines[i] = m[1] + `"` + redactedValue + `"` + m[4]
+				continue
+			}
+		}
+		lines[i] = urlUserinfoRe.ReplaceAllString(line, "://"+redactedValue+"@")
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// logURLRe finds URLs in log messages, with their credentials and the
+// part after the host
+var logURLRe = regexp.MustCompile(`([A-Za-z][A-Za-z0-9+.-]*://)(?:[^/@\s"']+@)?([^/?#\s"']+)([^\s"']*)`)
+
+// logAssignRe finds flags with their values and name=value pairs in log
+// messages and command lines
+var logAssignRe = regexp.MustCompile(`(--?([A-Za-z][A-Za-z0-9_.-]*)(?:=| +)|([A-Za-z_][A-Za-z0-9_.-]*)=)([^\s"']+)`)
+
+// scrubLog redacts the secrets of log messages and crash reports: the
+// values of flags and variables that name secrets or URLs, the
+// credentials of URLs, and everything after their host, since webhook
+// URLs carry their secret in the path
+func scrubLog(data []byte) []byte {
+	out := logAssignRe.ReplaceAllStringFunc(string(data), func(match string) string {
+		m := logAssignRe.FindStringSubmatch(match)
+		key := strings.ToUpper(m[2] + m[3])
+		if !matchesEnvGlob(secretEnv, key) && !matchesEnvGlob(configSecretKeys, key) {
+			return match
+		}
+		return m[1] + redactedValue
+	})
+	out = logURLRe.ReplaceAllStringFunc(out, func(match string) string {
+		m := logURLRe.FindStringSubmatch(match)
+		if m[3] == "" || m[3] == "/" {
+			return m[1] + m[2] + m[3]
+		}
+		return m[1] + m[2] + "/" + redactedValue
+	})
+	return []byte(out)
+}
+
+// environmentDiagnostics describes the machine and go toolchain
+// go-sentinel runs with, secrets redacted
+func environmentDiagnostics(workDir string) []byte {
+	env := offlineEnviron(os.Environ())
+	var b strings.Builder
+	snap := newEnvSnapshot(env, goVersion(workDir, env))
+	fmt.Fprintf(&b, "Environment: %s\n", snap)
+	fmt.Fprintf(&b, "CPUs: %d\n", snap.NumCPU)
+	fmt.Fprintf(&b, "Offline: %t\n", Offline())
+	if toolchain, err := CheckGoToolchain(); err != nil {
+		fmt.Fprintf(&b, "Go toolchain: %v\n", err)
+	} else {
+		fmt.Fprintf(&b, "Go toolchain: %s at %s\n", toolchain.Version, toolchain.Path)
+	}
+
+	cmd := exec.Command(goBinary, "env")
+	cmd.Dir = workDir
+	cmd.Env = env
+	if out, err := cmd.Output(); err == nil {
+		b.WriteString("\ngo env:\n")
+		for _, line := range strings.Split(strings.TrimRight(string(out), "\n"), "\n") {
+			line = strings.TrimPrefix(line, "set ")
+			if name, value, ok := strings.Cut(line, "="); ok {
+				line = name + "=" + scrubEnvValue(name, value)
+			}
+			b.WriteString(line + "\n")
+		}
+	}
+	return []byte(b.String())
+}
+
+// WriteSupportBundle writes what is needed to diagnose a problem with
+// go-sentinel itself to an archive at dest: version information,
+// environment diagnostics, and the project's configuration files, recent
+// log messages, and last crash reports, all with secrets redacted. The
+// compression is chosen from the file extension, as for ExportState. It
+// returns the names of the files in the archive.
+func WriteSupportBundle(dest, workDir string) ([]string, error) {
+	f, err := os.Create(dest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create archive: %w", err)
+	}
+	defer f.Close()
+	w, err := compressWriter(dest, f)
+	if err != nil {
+		f.Close()
+		os.Remove(dest)
+		return nil, err
+	}
+	tw := tar.NewWriter(w)
+
+	now := time.Now()
+	var names []string
+	add := func(name string, data []byte) error {
+		names = append(names, name)
+		return writeTarFile(tw, name, data, now)
+	}
+	if err := add("version.txt", []byte(VersionInfo())); err != nil {
+		return nil, err
+	}
+	if err := add("environment.txt", environmentDiagnostics(workDir)); err != nil {
+		return nil, err
+	}
+
+	configs := append(append([]string{}, configFileNames...), NotifyFileName)
+	for _, name := range configs {
+		data, err := os.ReadFile(filepath.Join(workDir, name))
+		if err != nil {
+			continue
+		}
+		if err := add(path.Join("config", name), scrubConfig(data)); err != nil {
+			return nil, err
+		}
+	}
+
+	if dir := defaultPackageCacheDir(); dir != "" {
+		logs := filepath.Join(dir, "logs", logFileName)
+		files := []string{logs + ".1", logs}
+		files = append(files, crashReports(filepath.Join(dir, crashDirName))...)
+		for _, file := range files {
+			name := path.Join("logs", filepath.Base(file))
+			if strings.HasPrefix(filepath.Base(file), "crash-") {
+				name = path.Join(crashDirName, filepath.Base(file))
+			}
+			data, err := os.ReadFile(file)
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to archive %s: %w", file, err)
+			}
+			if err := add(name, scrubLog(data)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write archive: %w", err)
+	}
+	return names, nil
+}
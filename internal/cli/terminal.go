@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-isatty"
+	"github.com/muesli/termenv"
+)
+
+// Color modes, as chosen with --color
+const (
+	ColorAuto   = "auto"   // Color on terminals, unless in CI mode or NO_COLOR is set
+	ColorAlways = "always" // Color even when output is piped or logged
+	ColorNever  = "never"  // No escape sequences at all
+)
+
+var (
+	colorMode = ColorAuto
+	ciMode    bool
+)
+
+// ParseColorMode parses a --color value. true and false, from when the
+// flag was a switch, mean auto and never.
+func ParseColorMode(value string) (string, error) {
+	switch value {
+	case ColorAuto, ColorAlways, ColorNever:
+		return value, nil
+	case "true":
+		return ColorAuto, nil
+	case "false":
+		return ColorNever, nil
+	}
+	return "", fmt.Errorf("unknown color mode %q, want %s, %s, or %s", value, ColorAuto, ColorAlways, ColorNever)
+}
+
+// Interactive reports whether a person is likely watching the output:
+// stdout is a terminal and no CI system is detected
+func Interactive() bool {
+	if detectCI() != "" {
+		return false
+	}
+	return isatty.IsTerminal(os.Stdout.Fd()) || isatty.IsCygwinTerminal(os.Stdout.Fd())
+}
+
+// SetTerminal chooses how output is colored, and whether it is in CI
+// mode: plain lines without escape sequences, the packages of GitHub
+// Actions runs folded into log groups, and progress printed now and then
+// instead of redrawn. It is meant to be called once at startup, before
+// anything is rendered.
+func SetTerminal(color string, ci bool) {
+	colorMode, ciMode = color, ci
+	switch {
+	case color == ColorAlways:
+		if lipgloss.ColorProfile() == termenv.Ascii {
+			lipgloss.SetColorProfile(termenv.ANSI256)
+		}
+	case color == ColorNever, ci:
+		lipgloss.SetColorProfile(termenv.Ascii)
+	}
+}
+
+// CIMode reports whether output is in CI mode
+func CIMode() bool {
+	return ciMode
+}
+
+// ColorsEnabled reports whether styles may color output, before the
+// terminal itself is considered
+func ColorsEnabled() bool {
+	return colorMode != ColorNever
+}
+
+// githubLogGroups reports whether output can be folded into GitHub Actions
+// log groups
+func githubLogGroups() bool {
+	return ciMode && detectCI() == "github-actions"
+}
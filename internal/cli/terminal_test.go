@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+func TestParseColorMode(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "auto", want: ColorAuto},
+		{value: "always", want: ColorAlways},
+		{value: "never", want: ColorNever},
+		{value: "true", want: ColorAuto},
+		{value: "false", want: ColorNever},
+		{value: "sometimes", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseColorMode(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseColorMode(%q) = %q, %v, want %q", tt.value, got, err, tt.want)
+		}
+	}
+}
+
+// setTerminal applies SetTerminal for the duration of a test
+func setTerminal(t *testing.T, color string, ci bool) {
+	profile := lipgloss.ColorProfile()
+	t.Cleanup(func() {
+		colorMode, ciMode = ColorAuto, false
+		lipgloss.SetColorProfile(profile)
+	})
+	SetTerminal(color, ci)
+}
+
+func TestSetTerminal(t *testing.T) {
+	t.Setenv("FORCE_COLOR", "1")
+	setTerminal(t, ColorNever, false)
+	if NewStyle(true).useColors {
+		t.Error("--color=never left colors on under FORCE_COLOR")
+	}
+
+	t.Setenv("FORCE_COLOR", "")
+	SetTerminal(ColorAuto, true)
+	if NewStyle(true).useColors {
+		t.Error("CI mode left colors on")
+	}
+	if got := dimStyle.Render("plain"); got != "plain" {
+		t.Errorf("CI mode rendered %q, want no escape sequences", got)
+	}
+
+	SetTerminal(ColorAlways, true)
+	if !NewStyle(false).useColors {
+		t.Error("--color=always left colors off in CI mode")
+	}
+}
+
+func TestRenderSuite_GitHubGroups(t *testing.T) {
+	t.Setenv("GITHUB_ACTIONS", "true")
+	setTerminal(t, ColorAuto, true)
+
+	var buf bytes.Buffer
+	r := NewRendererWithStyle(&buf, false)
+	r.RenderSuite(&TestSuite{Package: "example.com/app/ok", NumTotal: 1, NumPassed: 1,
+		Tests: []*TestResult{{Name: "TestOK", Status: TestStatusPassed}}})
+	r.RenderSuite(&TestSuite{Package: "example.com/app/broken", NumTotal: 1, NumFailed: 1,
+		Tests: []*TestResult{{Name: "TestBroken", Status: TestStatusFailed, Error: &TestError{Message: "boom"}}}})
+
+	out := buf.String()
+	if !strings.HasPrefix(out, "::group:: example.com/app/ok ") || strings.Count(out, "::endgroup::") != 1 {
+		t.Errorf("passing package not folded:\n%s", out)
+	}
+	if i := strings.Index(out, "broken"); i < strings.Index(out, "::endgroup::") || strings.Contains(out, "::group:: example.com/app/broken") {
+		t.Errorf("failing package folded:\n%s", out)
+	}
+}
@@ -0,0 +1,153 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// Lines of plain go test output, with or without -v
+	textRunRe     = regexp.MustCompile(`^=== (RUN|PAUSE|CONT|NAME)\s+(\S+)`)
+	textResultRe  = regexp.MustCompile(`^\s*--- (PASS|FAIL|SKIP): (\S+) \((\d+(?:\.\d+)?)s\)`)
+	textPackageRe = regexp.MustCompile(`^(ok|FAIL|\?) *\t(\S+)(?:\s+(.*))?$`)
+	textElapsedRe = regexp.MustCompile(`^(\d+(?:\.\d+)?)s\b`)
+
+	// CI systems such as GitHub Actions prefix each log line with a timestamp
+	textTimestampRe = regexp.MustCompile(`^(\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d(?:\.\d+)?(?:Z|[+-]\d\d:\d\d)) `)
+)
+
+// parseGoTestText converts plain go test output, such as a CI log, to a
+// test run. The lines are turned into the events go test -json would have
+// written, the way go tool test2json does, and parsed by the usual parser.
+// Without -v only failed and skipped tests appear in the output.
+func parseGoTestText(data []byte) (*TestRun, error) {
+	events, elapsed, err := goTestTextEvents(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, fmt.Errorf("no go test output found")
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, event := range events {
+		if err := enc.Encode(event); err != nil {
+			return nil, fmt.Errorf("failed to convert test output: %w", err)
+		}
+	}
+	run, err := parseGoTestJSON(buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	// Without timestamps, durations come from the package result lines
+	if run.StartTime.IsZero() {
+		run.Duration = 0
+		for _, suite := range run.Suites {
+			if d, ok := elapsed[suite.Package]; ok {
+				suite.Duration = d
+			}
+			run.Duration += suite.Duration
+		}
+	}
+	return run, nil
+}
+
+// goTestTextEvents converts go test output lines to events, and returns
+// the elapsed time reported for each package. A package's name is only
+// printed after its tests, so events are held back until its result line.
+func goTestTextEvents(data []byte) ([]*GoTestEvent, map[string]time.Duration, error) {
+	var events, pending []*GoTestEvent
+	elapsed := make(map[string]time.Duration)
+	current := "" // Test the following output belongs to
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		var at time.Time
+		if m := textTimestampRe.FindStringSubmatch(line); m != nil {
+			at, _ = time.Parse(time.RFC3339Nano, m[1])
+			line = line[len(m[0]):]
+		}
+		output := &GoTestEvent{Time: at, Action: "output", Output: line + "\n"}
+
+		if m := textPackageRe.FindStringSubmatch(line); m != nil {
+			pkg, action := m[2], "pass"
+			switch m[1] {
+			case "FAIL":
+				action = "fail"
+			case "?":
+				action = "skip"
+			}
+			if e := textElapsedRe.FindStringSubmatch(m[3]); e != nil {
+				seconds, _ := strconv.ParseFloat(e[1], 64)
+				elapsed[pkg] = DurationFromSeconds(seconds)
+			}
+
+			start := at
+			if len(pending) > 0 {
+				start = pending[0].Time
+			}
+			events = append(events, &GoTestEvent{Time: start, Action: "start", Package: pkg})
+			for _, event := range pending {
+				event.Package = pkg
+				events = append(events, event)
+			}
+			output.Package = pkg
+			events = append(events, output, &GoTestEvent{Time: at, Action: action, Package: pkg, Elapsed: elapsed[pkg].Seconds()})
+			pending, current = nil, ""
+			continue
+		}
+
+		if m := textRunRe.FindStringSubmatch(line); m != nil {
+			current = m[2]
+			if m[1] == "RUN" {
+				pending = append(pending, &GoTestEvent{Time: at, Action: "run", Test: current})
+			}
+			output.Test = current
+			pending = append(pending, output)
+			continue
+		}
+
+		if m := textResultRe.FindStringSubmatch(line); m != nil {
+			current = m[2]
+			seconds, _ := strconv.ParseFloat(m[3], 64)
+			// Without -v, failed tests are only reported by their result line
+			if !pendingRun(pending, current) {
+				pending = append(pending, &GoTestEvent{Time: at, Action: "run", Test: current})
+			}
+			output.Test = current
+			pending = append(pending, output, &GoTestEvent{Time: at, Action: strings.ToLower(m[1]), Test: current, Elapsed: seconds})
+			continue
+		}
+
+		// The package verdict and coverage are not part of the last test
+		if line == "PASS" || line == "FAIL" || strings.HasPrefix(line, "coverage: ") {
+			current = ""
+		}
+		output.Test = current
+		pending = append(pending, output)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read test output: %w", err)
+	}
+	return events, elapsed, nil
+}
+
+// pendingRun reports whether events include the start of a test
+func pendingRun(events []*GoTestEvent, test string) bool {
+	for _, event := range events {
+		if event.Action == "run" && event.Test == test {
+			return true
+		}
+	}
+	return false
+}
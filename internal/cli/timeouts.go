@@ -0,0 +1,221 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// timeoutMessage starts the line written when a test binary is stopped at
+// its package timeout or the run deadline
+const timeoutMessage = "sentinel: test binary timed out"
+
+// timeoutsEnv passes the timeout of each package directory and the run
+// deadline to the go test -exec wrapper, as limitsEnv does for resource
+// limits
+const timeoutsEnv = "GO_SENTINEL_TIMEOUTS"
+
+// defaultTestTimeout is go test's own limit on each test binary, which
+// packages without an override keep when go-sentinel enforces timeouts
+const defaultTestTimeout = 10 * time.Minute
+
+// timeoutDumpGrace is how long a stopped test binary is given to dump its
+// goroutines before its process group is killed
+const timeoutDumpGrace = 2 * time.Second
+
+// PackageTimeout overrides the timeout of the packages matching a pattern,
+// in the forms duration budgets accept
+type PackageTimeout struct {
+	Pattern string
+	Timeout time.Duration
+}
+
+// BinaryTimeout is when the go test -exec wrapper stops a test binary
+type BinaryTimeout struct {
+	Timeout  time.Duration `json:"timeout,omitempty"`  // Limit on the binary, 0 for none
+	Deadline time.Time     `json:"deadline,omitempty"` // End of the whole run, zero for none
+}
+
+// IsZero reports whether the binary runs without a limit
+func (t BinaryTimeout) IsZero() bool {
+	return t.Timeout == 0 && t.Deadline.IsZero()
+}
+
+// limit returns how long a binary starting now may run, and what stops
+// it then
+func (t BinaryTimeout) limit(now time.Time) (time.Duration, string) {
+	if !t.Deadline.IsZero() {
+		if remaining := t.Deadline.Sub(now); t.Timeout == 0 || remaining < t.Timeout {
+			return max(remaining, 0), "run deadline"
+		}
+	}
+	return t.Timeout, "package timeout"
+}
+
+// enforcesTimeouts reports whether the go test -exec wrapper stops test
+// binaries at their timeouts, rather than go test
+func (opts RunOptions) enforcesTimeouts() bool {
+	return len(opts.PackageTimeouts) > 0 || opts.Deadline > 0
+}
+
+// packageTimeout returns the timeout of a package: the timeout of the
+// longest pattern matching it, else the run's timeout
+func (opts RunOptions) packageTimeout(importPath string) time.Duration {
+	timeout, best := opts.Timeout, -1
+	if timeout == 0 {
+		timeout = defaultTestTimeout
+	}
+	for _, pkg := range opts.PackageTimeouts {
+		if len(pkg.Pattern) > best && budgetMatches(pkg.Pattern, importPath) {
+			best, timeout = len(pkg.Pattern), pkg.Timeout
+		}
+	}
+	return timeout
+}
+
+// isolatedTimeout returns the -test.timeout of a test binary run directly
+// in isolation, where go test does not apply its default. It is zero when
+// the wrapper enforces timeouts.
+func (opts RunOptions) isolatedTimeout() time.Duration {
+	if opts.enforcesTimeouts() {
+		return 0
+	}
+	if opts.Timeout == 0 {
+		return defaultTestTimeout
+	}
+	return opts.Timeout
+}
+
+// binaryTimeout returns when the test binary of a package in a run started
+// at start is stopped
+func (opts RunOptions) binaryTimeout(importPath string, start time.Time) BinaryTimeout {
+	timeout := BinaryTimeout{Timeout: opts.packageTimeout(importPath)}
+	if opts.Deadline > 0 {
+		timeout.Deadline = start.Add(opts.Deadline)
+	}
+	return timeout
+}
+
+// timeoutsEnv returns the environment assigning the test binaries of a run
+// started at start the timeouts of their packages and the run deadline
+func (r *Runner) timeoutsEnv(opts RunOptions, start time.Time) ([]string, error) {
+	pkgs, err := r.pkgCache.Get(opts.Packages)
+	if err != nil {
+		return nil, err
+	}
+	byDir := make(map[string]BinaryTimeout, len(pkgs))
+	for _, pkg := range pkgs {
+		byDir[pkg.Dir] = opts.binaryTimeout(pkg.ImportPath, start)
+	}
+	env, err := timeoutsEnvFor(byDir)
+	if err != nil {
+		return nil, err
+	}
+	return []string{env}, nil
+}
+
+// timeoutsEnvFor encodes the timeouts of test binaries by the directory
+// they run in
+func timeoutsEnvFor(byDir map[string]BinaryTimeout) (string, error) {
+	data, err := json.Marshal(byDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode timeouts: %w", err)
+	}
+	return timeoutsEnv + "=" + string(data), nil
+}
+
+// TimeoutFromEnv returns the timeout go-sentinel assigned to the test
+// binary of a package directory when it started go test
+func TimeoutFromEnv(dir string) BinaryTimeout {
+	var byDir map[string]BinaryTimeout
+	if err := json.Unmarshal([]byte(os.Getenv(timeoutsEnv)), &byDir); err != nil {
+		return BinaryTimeout{}
+	}
+	return byDir[dir]
+}
+
+// withoutTestTimeout removes -test.timeout from the arguments of a test
+// binary whose timeout the wrapper enforces
+func withoutTestTimeout(args []string) []string {
+	kept := args[:0:0]
+	for i := 0; i < len(args); i++ {
+		if args[i] == "-test.timeout" && i+1 < len(args) {
+			i++
+			continue
+		}
+		if !strings.HasPrefix(args[i], "-test.timeout=") {
+			kept = append(kept, args[i])
+		}
+	}
+	return kept
+}
+
+// TimedOut reports whether a package was stopped at its timeout or the
+// run deadline
+func (s *TestSuite) TimedOut() bool {
+	if strings.Contains(s.Output, timeoutMessage) {
+		return true
+	}
+	for _, test := range s.Tests {
+		if test.Error != nil && strings.Contains(test.Error.Message, timeoutMessage) {
+			return true
+		}
+	}
+	return false
+}
+
+// TimedOutSuites returns the packages of a run that timed out
+func TimedOutSuites(run *TestRun) []*TestSuite {
+	var timedOut []*TestSuite
+	for _, suite := range run.Suites {
+		if suite.TimedOut() {
+			timedOut = append(timedOut, suite)
+		}
+	}
+	return timedOut
+}
+
+// markTimeouts fails the tests a timed out package was still running, so
+// the run counts them and shows them with the timeout
+func markTimeouts(run *TestRun) {
+	for _, suite := range run.Suites {
+		if !suite.TimedOut() {
+			continue
+		}
+		for _, test := range suite.Tests {
+			if test.Status != TestStatusRunning {
+				continue
+			}
+			test.Status = TestStatusFailed
+			if test.Error == nil {
+				test.Error = &TestError{}
+			}
+			if !strings.Contains(test.Error.Message, timeoutMessage) {
+				test.Error.Message += timeoutLine(suite)
+			}
+			suite.NumFailed++
+			run.NumFailed++
+			run.FailedTests = append(run.FailedTests, test)
+		}
+	}
+}
+
+// timeoutLine returns the line of output saying why a package was stopped
+func timeoutLine(suite *TestSuite) string {
+	outputs := []string{suite.Output}
+	for _, test := range suite.Tests {
+		if test.Error != nil {
+			outputs = append(outputs, test.Error.Message)
+		}
+	}
+	for _, output := range outputs {
+		for _, line := range strings.Split(output, "\n") {
+			if strings.Contains(line, timeoutMessage) {
+				return strings.TrimSpace(line) + "\n"
+			}
+		}
+	}
+	return timeoutMessage + "\n"
+}
@@ -0,0 +1,159 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPackageTimeout(t *testing.T) {
+	opts := RunOptions{
+		Timeout: time.Minute,
+		PackageTimeouts: []PackageTimeout{
+			{Pattern: "example.com/...", Timeout: 2 * time.Minute},
+			{Pattern: "example.com/db/...", Timeout: 5 * time.Minute},
+		},
+	}
+	tests := map[string]time.Duration{
+		"example.com/api":       2 * time.Minute,
+		"example.com/db/schema": 5 * time.Minute,
+		"other.org/tool":        time.Minute,
+	}
+	for pkg, want := range tests {
+		if got := opts.packageTimeout(pkg); got != want {
+			t.Errorf("packageTimeout(%q) = %v, want %v", pkg, got, want)
+		}
+	}
+	if got := (RunOptions{}).packageTimeout("example.com/api"); got != defaultTestTimeout {
+		t.Errorf("packageTimeout() without a timeout = %v, want %v", got, defaultTestTimeout)
+	}
+}
+
+func TestRunOptions_IsolatedTimeout(t *testing.T) {
+	tests := []struct {
+		name string
+		opts RunOptions
+		want time.Duration
+	}{
+		{"default", RunOptions{}, defaultTestTimeout},
+		{"run timeout", RunOptions{Timeout: time.Minute}, time.Minute},
+		{"enforced by the wrapper", RunOptions{Timeout: time.Minute, Deadline: time.Hour}, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.opts.isolatedTimeout(); got != tt.want {
+			t.Errorf("%s: isolatedTimeout() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBinaryTimeout(t *testing.T) {
+	start := time.Now()
+	opts := RunOptions{
+		Timeout:         time.Minute,
+		PackageTimeouts: []PackageTimeout{{Pattern: "example.com/db", Timeout: 5 * time.Minute}},
+	}
+	if got, want := opts.binaryTimeout("example.com/db", start), (BinaryTimeout{Timeout: 5 * time.Minute}); got != want {
+		t.Errorf("binaryTimeout() without a deadline = %+v, want %+v", got, want)
+	}
+	opts.Deadline = 10 * time.Minute
+	want := BinaryTimeout{Timeout: time.Minute, Deadline: start.Add(10 * time.Minute)}
+	if got := opts.binaryTimeout("example.com/api", start); got != want {
+		t.Errorf("binaryTimeout() = %+v, want %+v", got, want)
+	}
+}
+
+func TestBinaryTimeoutLimit(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		timeout BinaryTimeout
+		limit   time.Duration
+		reason  string
+	}{
+		{"package timeout", BinaryTimeout{Timeout: time.Minute}, time.Minute, "package timeout"},
+		{"deadline first", BinaryTimeout{Timeout: time.Minute, Deadline: now.Add(time.Second)}, time.Second, "run deadline"},
+		{"timeout first", BinaryTimeout{Timeout: time.Second, Deadline: now.Add(time.Minute)}, time.Second, "package timeout"},
+		{"deadline only", BinaryTimeout{Deadline: now.Add(time.Minute)}, time.Minute, "run deadline"},
+		{"deadline passed", BinaryTimeout{Timeout: time.Minute, Deadline: now.Add(-time.Second)}, 0, "run deadline"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit, reason := tt.timeout.limit(now)
+			if limit != tt.limit || reason != tt.reason {
+				t.Errorf("limit() = %v, %q, want %v, %q", limit, reason, tt.limit, tt.reason)
+			}
+		})
+	}
+}
+
+func TestWithoutTestTimeout(t *testing.T) {
+	args := []string{"-test.v", "-test.timeout", "10m", "-test.run=^TestA$", "-test.timeout=1m"}
+	want := []string{"-test.v", "-test.run=^TestA$"}
+	if got := withoutTestTimeout(args); !reflect.DeepEqual(got, want) {
+		t.Errorf("withoutTestTimeout() = %q, want %q", got, want)
+	}
+}
+
+func TestMarkTimeouts(t *testing.T) {
+	hung := &TestResult{Name: "TestHang", Status: TestStatusRunning}
+	passed := &TestResult{Name: "TestOK", Status: TestStatusPassed}
+	suite := &TestSuite{
+		Package:   "example.com/slow",
+		Tests:     []*TestResult{passed, hung},
+		NumPassed: 1,
+		Output:    timeoutMessage + " after 1s (package timeout); its processes were stopped\nFAIL\n",
+	}
+	fast := &TestSuite{Package: "example.com/fast", Tests: []*TestResult{{Name: "TestFast", Status: TestStatusPassed}}}
+	run := &TestRun{Suites: []*TestSuite{suite, fast}}
+
+	markTimeouts(run)
+
+	if hung.Status != TestStatusFailed || hung.Error == nil || !strings.Contains(hung.Error.Message, "after 1s (package timeout)") {
+		t.Errorf("hung test = %+v, want it failed with the timeout", hung)
+	}
+	if passed.Status != TestStatusPassed {
+		t.Errorf("finished test status = %v, want passed", passed.Status)
+	}
+	if suite.NumFailed != 1 || run.NumFailed != 1 || len(run.FailedTests) != 1 {
+		t.Errorf("failures = %d in suite, %d in run, want 1", suite.NumFailed, run.NumFailed)
+	}
+	if got := TimedOutSuites(run); len(got) != 1 || got[0] != suite {
+		t.Errorf("TimedOutSuites() = %v, want the slow package", got)
+	}
+}
+
+func TestRunSetupWatchdog_BinaryTimeout(t *testing.T) {
+	t.Setenv("GO_SENTINEL_SETUP_HELPER", "hang")
+	run := func(timeout BinaryTimeout) (int, string) {
+		var stdout, stderr bytes.Buffer
+		code, err := RunSetupWatchdog(time.Minute, ResourceLimits{}, timeout, os.Args[0], []string{"-test.run=^TestSetupWatchdogHelper$"}, &stdout, &stderr)
+		if err != nil {
+			t.Fatalf("RunSetupWatchdog() error = %v", err)
+		}
+		return code, stdout.String()
+	}
+
+	t.Run("stops hung binary", func(t *testing.T) {
+		start := time.Now()
+		code, out := run(BinaryTimeout{Timeout: 200 * time.Millisecond})
+		if code != 1 {
+			t.Errorf("exit code = %d, want 1", code)
+		}
+		if !strings.Contains(out, timeoutMessage+" after 200ms (package timeout)") {
+			t.Errorf("output %q does not report the timeout", out)
+		}
+		if elapsed := time.Since(start); elapsed > 30*time.Second {
+			t.Errorf("watchdog took %v to stop the binary", elapsed)
+		}
+	})
+
+	t.Run("fails after deadline", func(t *testing.T) {
+		code, out := run(BinaryTimeout{Deadline: time.Now().Add(-time.Second)})
+		if code != 1 || !strings.Contains(out, "run deadline passed before it started") {
+			t.Errorf("exit code = %d, output = %q, want the deadline reported", code, out)
+		}
+	})
+}
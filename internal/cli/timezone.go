@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Timestamp layouts used in reports. Both carry the UTC offset so reports
+// from machines in different zones can be compared.
+const (
+	timeLayout     = "15:04:05 -07:00"
+	dateTimeLayout = "2006-01-02 15:04 -07:00"
+)
+
+// LoadTimezone resolves a configured time zone: "" or "local" for the
+// machine's zone, "UTC", or an IANA name such as "Europe/Berlin"
+func LoadTimezone(name string) (*time.Location, error) {
+	switch strings.ToLower(name) {
+	case "", "local":
+		return time.Local, nil
+	case "utc":
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", name)
+	}
+	return loc, nil
+}
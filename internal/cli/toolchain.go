@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"go/version"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// MinGoVersion is the oldest go toolchain go-sentinel runs tests with, the
+// first whose go test -json reports when each package starts
+const MinGoVersion = "go1.20"
+
+// goBinary is the go command used to list, build, and run tests
+var goBinary = "go"
+
+// SetGoBinary changes the go command go-sentinel runs, by name on the PATH
+// or by path. It is meant to be called once at startup; an empty value
+// keeps "go".
+func SetGoBinary(path string) {
+	if path != "" {
+		goBinary = path
+	}
+}
+
+// GoToolchain is the go toolchain tests are run with
+type GoToolchain struct {
+	Path    string // Resolved path of the go command
+	Version string // Version such as "go1.22.3"
+}
+
+// ErrGoToolchain is returned, wrapped, when the go toolchain is missing or
+// too old to run tests with
+var ErrGoToolchain = errors.New("unusable go toolchain")
+
+// CheckGoToolchain verifies that the go command exists and is at least
+// MinGoVersion. Its errors say what was found, what is required, and how
+// to point go-sentinel at another toolchain.
+func CheckGoToolchain() (*GoToolchain, error) {
+	path, err := exec.LookPath(goBinary)
+	if err != nil {
+		if !strings.ContainsRune(goBinary, filepath.Separator) && !strings.ContainsRune(goBinary, '/') {
+			return nil, toolchainError("go command %q not found in PATH", goBinary)
+		}
+		return nil, toolchainError("go command %s not found", goBinary)
+	}
+	output, err := exec.Command(path, "version").Output()
+	if err != nil {
+		return nil, toolchainError("%s did not report its version: %v", path, err)
+	}
+	found, lang := parseGoVersion(string(output))
+	if !version.IsValid(lang) {
+		return nil, toolchainError("%s reported an unrecognized version %q", path, found)
+	}
+	if version.Compare(lang, MinGoVersion) < 0 {
+		return nil, toolchainError("%s is %s, older than the required %s", path, found, MinGoVersion)
+	}
+	return &GoToolchain{Path: path, Version: found}, nil
+}
+
+// parseGoVersion reads the output of go version, such as "go version
+// go1.22.3 linux/amd64", returning the version and its language version.
+// Development builds report "devel go1.24-abcdef ..." instead.
+func parseGoVersion(output string) (found, lang string) {
+	fields := strings.Fields(strings.TrimPrefix(strings.TrimSpace(output), "go version "))
+	if len(fields) == 0 {
+		return "", ""
+	}
+	found = fields[0]
+	if found == "devel" && len(fields) > 1 {
+		found = "devel " + fields[1]
+		return found, strings.SplitN(fields[1], "-", 2)[0]
+	}
+	return found, found
+}
+
+// toolchainError describes an unusable toolchain and how to fix it
+func toolchainError(format string, args ...any) error {
+	return fmt.Errorf("%w: %s\n\ngo-sentinel needs %s or newer to run tests. Install it from https://go.dev/dl/,\n"+
+		"or choose the go command with --go-bin /path/to/go or goBin in the configuration file.",
+		ErrGoToolchain, fmt.Sprintf(format, args...), MinGoVersion)
+}
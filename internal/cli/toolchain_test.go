@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestParseGoVersion(t *testing.T) {
+	tests := []struct {
+		output, found, lang string
+	}{
+		{"go version go1.22.3 linux/amd64\n", "go1.22.3", "go1.22.3"},
+		{"go version go1.21rc2 darwin/arm64", "go1.21rc2", "go1.21rc2"},
+		{"go version devel go1.24-abcdef Tue Jan 7 10:00:00 2025 +0000 linux/amd64", "devel go1.24-abcdef", "go1.24"},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		found, lang := parseGoVersion(tt.output)
+		if found != tt.found || lang != tt.lang {
+			t.Errorf("parseGoVersion(%q) = %q, %q, want %q, %q", tt.output, found, lang, tt.found, tt.lang)
+		}
+	}
+}
+
+func TestCheckGoToolchain(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses shell scripts as go commands")
+	}
+	defer func(saved string) { goBinary = saved }(goBinary)
+
+	fakeGo := func(version string) string {
+		path := filepath.Join(t.TempDir(), "go")
+		script := "#!/bin/sh\necho 'go version " + version + " linux/amd64'\n"
+		if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	SetGoBinary(fakeGo("go1.23.4"))
+	toolchain, err := CheckGoToolchain()
+	if err != nil || toolchain.Version != "go1.23.4" {
+		t.Errorf("CheckGoToolchain() = %+v, %v, want go1.23.4", toolchain, err)
+	}
+
+	SetGoBinary(fakeGo("go1.19.13"))
+	_, err = CheckGoToolchain()
+	if !errors.Is(err, ErrGoToolchain) || !strings.Contains(err.Error(), "is go1.19.13, older than the required "+MinGoVersion) ||
+		!strings.Contains(err.Error(), "--go-bin") {
+		t.Errorf("CheckGoToolchain() error = %v, want the found and required versions", err)
+	}
+
+	SetGoBinary(filepath.Join(t.TempDir(), "missing"))
+	if _, err := CheckGoToolchain(); !errors.Is(err, ErrGoToolchain) || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("CheckGoToolchain() error = %v, want not found", err)
+	}
+}
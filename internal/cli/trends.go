@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Trend bucket sizes
+const (
+	TrendByRun  = "run"
+	TrendByDay  = "day"
+	TrendByWeek = "week"
+)
+
+// TrendPoint summarizes the runs that started within one bucket of time
+type TrendPoint struct {
+	Start        time.Time     `json:"start"`
+	Runs         int           `json:"runs"`
+	Passed       int           `json:"passed"`
+	Failed       int           `json:"failed"`
+	PassRate     float64       `json:"passRate"`     // Passed tests as a fraction of passed and failed ones
+	MeanDuration time.Duration `json:"meanDuration"` // Mean run duration, or package duration when filtered
+}
+
+// Trends returns pass-rate and duration series of the recorded runs,
+// oldest first, optionally restricted to one package. Buckets are runs,
+// days, or weeks in loc; identical runs collapsed into one record count
+// once per run.
+func Trends(records []*HistoryRecord, pkg, bucket string, loc *time.Location) ([]*TrendPoint, error) {
+	start, err := trendBucket(bucket, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	points := make(map[time.Time]*TrendPoint)
+	durations := make(map[time.Time]time.Duration)
+	for _, rec := range records {
+		if rec.Trigger == TriggerRestart {
+			continue
+		}
+		pkgs, duration := rec.Packages, rec.Duration
+		if pkg != "" {
+			p := findPackageRecord(rec, pkg)
+			if p == nil {
+				continue
+			}
+			pkgs, duration = []*PackageRecord{p}, p.Duration
+		}
+		passed, failed := 0, 0
+		for _, p := range pkgs {
+			for _, test := range p.Tests {
+				switch test.Status {
+				case TestStatusPassed:
+					passed++
+				case TestStatusFailed:
+					failed++
+				}
+			}
+		}
+
+		key := start(rec.StartTime)
+		point := points[key]
+		if point == nil {
+			point = &TrendPoint{Start: key}
+			points[key] = point
+		}
+		runs := rec.Runs()
+		point.Runs += runs
+		point.Passed += passed * runs
+		point.Failed += failed * runs
+		durations[key] += duration * time.Duration(runs)
+	}
+
+	series := make([]*TrendPoint, 0, len(points))
+	for key, point := range points {
+		if point.Passed+point.Failed > 0 {
+			point.PassRate = float64(point.Passed) / float64(point.Passed+point.Failed)
+		}
+		point.MeanDuration = durations[key] / time.Duration(point.Runs)
+		series = append(series, point)
+	}
+	sort.Slice(series, func(i, j int) bool { return series[i].Start.Before(series[j].Start) })
+	return series, nil
+}
+
+// trendBucket returns the function mapping a time to the start of its bucket
+func trendBucket(bucket string, loc *time.Location) (func(time.Time) time.Time, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+	day := func(t time.Time) time.Time {
+		t = t.In(loc)
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	}
+	switch bucket {
+	case TrendByRun:
+		return func(t time.Time) time.Time { return t }, nil
+	case "", TrendByDay:
+		return day, nil
+	case TrendByWeek:
+		// Weeks start on Monday
+		return func(t time.Time) time.Time {
+			d := day(t)
+			return d.AddDate(0, 0, -((int(d.Weekday()) + 6) % 7))
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown trend bucket %q, want %q, %q, or %q", bucket, TrendByRun, TrendByDay, TrendByWeek)
+}
+
+// findPackageRecord returns the record of a package in a run, or nil
+func findPackageRecord(rec *HistoryRecord, pkg string) *PackageRecord {
+	for _, p := range rec.Packages {
+		if p.Package == pkg {
+			return p
+		}
+	}
+	return nil
+}
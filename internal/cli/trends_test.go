@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTrends(t *testing.T) {
+	day := func(d, h int) time.Time { return time.Date(2024, 3, d, h, 0, 0, 0, time.UTC) }
+	run := func(start time.Time, duration time.Duration, statuses ...TestStatus) *HistoryRecord {
+		pkg := &PackageRecord{Package: "example.com/calc", Duration: duration / 2}
+		for _, status := range statuses {
+			pkg.Tests = append(pkg.Tests, &TestRecord{Name: "TestX", Status: status})
+		}
+		return &HistoryRecord{StartTime: start, Duration: duration, Packages: []*PackageRecord{pkg, {Package: "example.com/other"}}}
+	}
+	records := []*HistoryRecord{
+		run(day(4, 9), time.Second, TestStatusPassed, TestStatusFailed),
+		run(day(4, 17), 3*time.Second, TestStatusPassed, TestStatusPassed, TestStatusSkipped),
+		{StartTime: day(4, 18), Trigger: TriggerRestart},
+		run(day(11, 9), 2*time.Second, TestStatusFailed),
+	}
+	records[1].RepeatCount = 1
+
+	points, err := Trends(records, "", TrendByDay, time.UTC)
+	if err != nil {
+		t.Fatalf("Trends failed: %v", err)
+	}
+	if len(points) != 2 {
+		t.Fatalf("got %d points, want 2", len(points))
+	}
+	// The collapsed repeat counts as a second run of the 17:00 record
+	if p := points[0]; p.Runs != 3 || p.Passed != 5 || p.Failed != 1 || p.MeanDuration != 7*time.Second/3 {
+		t.Errorf("first day = %+v, want 3 runs, 5 passed, 1 failed", p)
+	}
+	if points[1].PassRate != 0 {
+		t.Errorf("second day pass rate = %v, want 0", points[1].PassRate)
+	}
+
+	weeks, err := Trends(records, "example.com/calc", TrendByWeek, time.UTC)
+	if err != nil {
+		t.Fatalf("Trends failed: %v", err)
+	}
+	if len(weeks) != 2 || !weeks[0].Start.Equal(day(4, 0)) || weeks[0].MeanDuration != 7*time.Second/6 {
+		t.Errorf("weeks = %+v, want two Monday-aligned weeks with package durations", weeks)
+	}
+
+	if _, err := Trends(records, "", "month", time.UTC); err == nil {
+		t.Error("Expected error for an unknown bucket")
+	}
+}
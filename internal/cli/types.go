@@ -1,6 +1,11 @@
 package cli
 
-import "time"
+import (
+	"strings"
+	"time"
+
+	"github.com/newbpydev/go-sentinel/pkg/sentinelio"
+)
 
 // TestStatus represents the state of a test
 type TestStatus int
@@ -19,6 +24,23 @@ const (
 	TestStatusSkipped
 )
 
+// statusName returns the lowercase name of a status for reports
+func statusName(s TestStatus) string {
+	switch s {
+	case TestStatusPending:
+		return "pending"
+	case TestStatusRunning:
+		return "running"
+	case TestStatusPassed:
+		return "passed"
+	case TestStatusFailed:
+		return "failed"
+	case TestStatusSkipped:
+		return "skipped"
+	}
+	return "unknown"
+}
+
 // SourceLocation represents a location in source code
 type SourceLocation struct {
 	File      string
@@ -39,13 +61,27 @@ type TestError struct {
 
 // TestResult represents the result of a single test
 type TestResult struct {
-	Name      string
-	Status    TestStatus
-	Duration  time.Duration
-	Error     *TestError
-	Depth     int // For subtests
-	StartTime time.Time
-	EndTime   time.Time
+	Name       string
+	Package    string // Import path of the package the test belongs to
+	Status     TestStatus
+	Duration   time.Duration
+	Error      *TestError
+	Depth      int // For subtests
+	StartTime  time.Time
+	EndTime    time.Time
+	Attempts   int // Number of times the test was run in this run
+	Assertions int // Assertions reported by the test, 0 when not detectable
+
+	PassedOnRetry bool // Failed at first and passed when rerun
+
+	Owner        string     // Owners of the test, when requested
+	Tags         []string   // Tags assigned to the test or its package, when requested
+	Requirements []string   // Requirements the test traces to, from sentinel:req annotations
+	LastChange   time.Time  // Last commit touching the test's package, when requested
+	Repro        string     // Shell command rerunning the test alone, set when it failed
+	Panic        *TestPanic // Panic the test died of, with its condensed stack
+
+	Annotations []sentinelio.Annotation // Structured metadata emitted by the test
 }
 
 // TestSuite represents a collection of tests from a package
@@ -62,10 +98,41 @@ type TestSuite struct {
 	Duration    time.Duration
 	StartTime   time.Time
 	EndTime     time.Time
+	Cached      bool          // Results were served from the go test cache or go-sentinel's result cache
+	Output      string        // Output printed outside any test, such as by TestMain
+	SetupFailed bool          // Package failed before any test ran, as in TestMain
+	BuildOutput string        // Compiler output when the package did not build
+	BuildErrors []*BuildError // Compiler errors parsed from BuildOutput
+	Budget      time.Duration // Configured duration budget of the package, 0 for none
+	Benchmarks  []*Benchmark  // Results of go test -bench, in the order reported
+
+	Annotations []sentinelio.Annotation // Metadata emitted outside any test (e.g. TestMain)
+}
+
+// NumTestFuncs returns the number of top-level test functions in the suite
+func (s *TestSuite) NumTestFuncs() int {
+	n := 0
+	for _, test := range s.Tests {
+		if !strings.Contains(test.Name, "/") {
+			n++
+		}
+	}
+	return n
+}
+
+// NumAssertions returns the number of assertions reported by the suite's tests
+func (s *TestSuite) NumAssertions() int {
+	n := 0
+	for _, test := range s.Tests {
+		n += test.Assertions
+	}
+	return n
 }
 
 // TestRun represents a complete test run
 type TestRun struct {
+	ID                string // Unique run identifier, also exposed to tests as SENTINEL_RUN_ID
+	Seed              int64  // Seed of randomized behavior, also exposed to tests as SENTINEL_SEED; 0 when unused
 	StartTime         time.Time
 	EndTime           time.Time
 	Duration          time.Duration
@@ -80,7 +147,10 @@ type TestRun struct {
 	NumFailed         int
 	NumSkipped        int
 	Suites            []*TestSuite
-	FailedTests       []*TestResult // Track failed tests for later use
+	FailedTests       []*TestResult      // Track failed tests for later use
+	Coverage          map[string]float64 // Statement coverage percentage by profile file name, when collected
+	CoverageTotal     float64            // Statement coverage percentage of all files, valid when Coverage is set
+	Env               *EnvSnapshot       // Environment the tests ran in
 }
 
 // NewTestRun creates a new test run with initialized fields
@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"bytes"
 	"fmt"
 	"time"
 
@@ -19,6 +20,7 @@ type watchModel struct {
 	err         error
 	quitting    bool
 	fileChanged string
+	showStats   bool // Session statistics are shown
 }
 
 // newWatchModel creates a new watch mode model
@@ -31,7 +33,7 @@ func newWatchModel(runner *Runner, opts RunOptions) watchModel {
 		runner:    runner,
 		opts:      opts,
 		spinner:   s,
-		keyPrompt: "\nPress 'a' to run all tests\nPress 'f' to run only failed tests\nPress 'q' to quit",
+		keyPrompt: "\nPress 'a' to run all tests\nPress 'f' to run only failed tests\nPress 's' for session statistics\nPress 'q' to quit",
 	}
 }
 
@@ -57,6 +59,9 @@ func (m watchModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case "f":
 			m.opts.OnlyFailed = true
 			return m, m.runTests
+		case "s":
+			m.showStats = !m.showStats && m.opts.Stats != nil
+			return m, nil
 		}
 
 	case spinner.TickMsg:
@@ -115,6 +120,13 @@ func (m watchModel) View() string {
 			Render(fmt.Sprintf("\nError: %v\n", m.err))
 	}
 
+	// Session statistics, on demand
+	if m.showStats {
+		var buf bytes.Buffer
+		NewRendererWithStyle(&buf, true).RenderWatchStats(m.opts.Stats.Snapshot())
+		s += "\n" + buf.String()
+	}
+
 	// Key prompt
 	if !m.quitting {
 		s += lipgloss.NewStyle().
@@ -0,0 +1,200 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// WatchStats tracks the cadence of a watch session: how often tests ran,
+// how quickly results followed a save, how much go test served from its
+// cache, and how much time running only the affected tests saved
+type WatchStats struct {
+	MetricsFile string // Where metrics are written after each run, empty to disable
+
+	mu           sync.Mutex
+	start        time.Time
+	runs         int
+	reruns       int           // Runs triggered by a change
+	latency      time.Duration // Sum of save-to-result times of reruns
+	packages     int
+	cached       int
+	selectedRuns int           // Reruns narrowed to the affected tests
+	saved        time.Duration // Estimated time saved by narrowing
+	fullRun      time.Duration // Duration of the last run that was not narrowed
+	tests        int           // Tests executed, subtests included
+	failures     int           // Tests that failed
+	changes      int           // Changed files that triggered reruns
+}
+
+// WatchStatsSnapshot is a point-in-time copy of a session's statistics
+type WatchStatsSnapshot struct {
+	Uptime         time.Duration
+	Runs           int
+	Reruns         int
+	AverageLatency time.Duration // Mean time from a save to its results, 0 before any rerun
+	CacheHitRate   float64       // Fraction of packages served from the go test cache
+	SelectedRuns   int
+	TimeSaved      time.Duration
+	Tests          int // Tests executed, subtests included
+	Failures       int // Tests that failed
+	FileChanges    int // Changed files that triggered reruns
+}
+
+// NewWatchStats starts tracking a session
+func NewWatchStats() *WatchStats {
+	return &WatchStats{start: time.Now()}
+}
+
+// record adds a completed run. changedAt is when the change that triggered
+// it was seen, zero for runs not triggered by a change; selected reports
+// whether the run was narrowed to the affected tests.
+func (s *WatchStats) record(run *TestRun, changedAt, finished time.Time, selected bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.runs++
+	if !changedAt.IsZero() {
+		s.reruns++
+		s.latency += finished.Sub(changedAt)
+	}
+	for _, suite := range run.Suites {
+		s.packages++
+		if suite.Cached {
+			s.cached++
+		}
+		// Counted from the tests, since suite counters include package
+		// failure lines
+		for _, test := range suite.Tests {
+			switch test.Status {
+			case TestStatusPassed, TestStatusSkipped:
+				s.tests++
+			case TestStatusFailed:
+				s.tests++
+				s.failures++
+			}
+		}
+	}
+	// A narrowed run saves the difference from the last full run; without
+	// one there is nothing to compare against
+	switch {
+	case !selected:
+		s.fullRun = run.Duration
+	case s.fullRun > run.Duration:
+		s.selectedRuns++
+		s.saved += s.fullRun - run.Duration
+	default:
+		s.selectedRuns++
+	}
+}
+
+// recordChanges counts changed files that triggered a rerun
+func (s *WatchStats) recordChanges(files int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.changes += files
+}
+
+// Snapshot returns the statistics so far
+func (s *WatchStats) Snapshot() WatchStatsSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snap := WatchStatsSnapshot{
+		Uptime:       time.Since(s.start),
+		Runs:         s.runs,
+		Reruns:       s.reruns,
+		SelectedRuns: s.selectedRuns,
+		TimeSaved:    s.saved,
+		Tests:        s.tests,
+		Failures:     s.failures,
+		FileChanges:  s.changes,
+	}
+	if s.reruns > 0 {
+		snap.AverageLatency = s.latency / time.Duration(s.reruns)
+	}
+	if s.packages > 0 {
+		snap.CacheHitRate = float64(s.cached) / float64(s.packages)
+	}
+	return snap
+}
+
+// WriteMetrics writes the statistics in the Prometheus text format
+func (s *WatchStats) WriteMetrics(w io.Writer) error {
+	snap := s.Snapshot()
+	metrics := []struct {
+		name, kind, help string
+		value            float64
+	}{
+		{"sentinel_watch_runs_total", "counter", "Test runs in this watch session.", float64(snap.Runs)},
+		{"sentinel_watch_reruns_total", "counter", "Test runs triggered by a file change.", float64(snap.Reruns)},
+		{"sentinel_watch_rerun_latency_seconds", "gauge", "Mean time from a file change to its test results.", snap.AverageLatency.Seconds()},
+		{"sentinel_watch_cache_hit_ratio", "gauge", "Fraction of package runs served from the go test cache.", snap.CacheHitRate},
+		{"sentinel_watch_selected_runs_total", "counter", "Reruns narrowed to the tests affected by the change.", float64(snap.SelectedRuns)},
+		{"sentinel_watch_time_saved_seconds_total", "counter", "Estimated time saved by running only affected tests.", snap.TimeSaved.Seconds()},
+		{"sentinel_watch_tests_total", "counter", "Tests executed, subtests included.", float64(snap.Tests)},
+		{"sentinel_watch_test_failures_total", "counter", "Tests that failed.", float64(snap.Failures)},
+		{"sentinel_watch_file_changes_total", "counter", "Changed files that triggered test runs.", float64(snap.FileChanges)},
+		{"sentinel_watch_uptime_seconds", "gauge", "Time since the watch session started.", snap.Uptime.Seconds()},
+	}
+	for _, m := range metrics {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %g\n", m.name, m.help, m.name, m.kind, m.name, m.value); err != nil {
+			return fmt.Errorf("failed to write metrics: %w", err)
+		}
+	}
+	return nil
+}
+
+// writeMetricsFile replaces the metrics file at once, so a collector
+// reading it never sees a partial file
+func (s *WatchStats) writeMetricsFile() error {
+	var buf bytes.Buffer
+	if err := s.WriteMetrics(&buf); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.MetricsFile), 0755); err != nil {
+		return fmt.Errorf("failed to create metrics directory: %w", err)
+	}
+	tmp := s.MetricsFile + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write metrics: %w", err)
+	}
+	if err := os.Rename(tmp, s.MetricsFile); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write metrics: %w", err)
+	}
+	return nil
+}
+
+// ServeHTTP serves the statistics in the Prometheus text format, so a
+// scraper can collect them from a running watch session
+func (s *WatchStats) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	if err := s.WriteMetrics(&buf); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+// ServeMetrics serves the statistics at /metrics on addr in the
+// background. The listener is opened before returning, so a port already
+// in use is reported right away; close the returned server to stop.
+func (s *WatchStats) ServeMetrics(addr string) (*http.Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serve metrics: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", s)
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go server.Serve(ln)
+	return server, nil
+}
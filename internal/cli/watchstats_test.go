@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchStats_Record(t *testing.T) {
+	s := NewWatchStats()
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	// Initial full run, then a narrowed rerun and a full rerun after saves
+	full := &TestRun{Duration: 10 * time.Second, Suites: []*TestSuite{{Cached: true}, {}}}
+	s.record(full, time.Time{}, base, false)
+	narrowed := &TestRun{Duration: 2 * time.Second, Suites: []*TestSuite{{}}}
+	s.record(narrowed, base, base.Add(3*time.Second), true)
+	rerun := &TestRun{Duration: 10 * time.Second, Suites: []*TestSuite{{Cached: true}}}
+	s.record(rerun, base, base.Add(time.Second), false)
+
+	snap := s.Snapshot()
+	if snap.Runs != 3 || snap.Reruns != 2 {
+		t.Errorf("runs = %d, reruns = %d, want 3 and 2", snap.Runs, snap.Reruns)
+	}
+	if snap.AverageLatency != 2*time.Second {
+		t.Errorf("AverageLatency = %v, want 2s", snap.AverageLatency)
+	}
+	if snap.CacheHitRate != 0.5 {
+		t.Errorf("CacheHitRate = %v, want 0.5", snap.CacheHitRate)
+	}
+	if snap.SelectedRuns != 1 || snap.TimeSaved != 8*time.Second {
+		t.Errorf("SelectedRuns = %d, TimeSaved = %v, want 1 and 8s", snap.SelectedRuns, snap.TimeSaved)
+	}
+
+	var buf bytes.Buffer
+	if err := s.WriteMetrics(&buf); err != nil {
+		t.Fatalf("WriteMetrics failed: %v", err)
+	}
+	for _, want := range []string{
+		"# TYPE sentinel_watch_runs_total counter\nsentinel_watch_runs_total 3\n",
+		"sentinel_watch_rerun_latency_seconds 2\n",
+		"sentinel_watch_cache_hit_ratio 0.5\n",
+		"sentinel_watch_time_saved_seconds_total 8\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("metrics missing %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestWatchStats_ServeHTTP(t *testing.T) {
+	s := NewWatchStats()
+	s.recordChanges(2)
+	s.record(&TestRun{Suites: []*TestSuite{{Tests: []*TestResult{
+		{Name: "TestA", Status: TestStatusPassed},
+		{Name: "TestB", Status: TestStatusFailed},
+		{Name: "TestB/sub", Status: TestStatusFailed},
+	}}}}, time.Now(), time.Now(), false)
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q, want the Prometheus text format", rec.Header().Get("Content-Type"))
+	}
+	for _, want := range []string{
+		"sentinel_watch_tests_total 3\n",
+		"sentinel_watch_test_failures_total 2\n",
+		"sentinel_watch_file_changes_total 2\n",
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("metrics missing %q:\n%s", want, rec.Body.String())
+		}
+	}
+
+	// A port in use is reported instead of failing in the background
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("Cannot listen: %v", err)
+	}
+	defer ln.Close()
+	if server, err := s.ServeMetrics(ln.Addr().String()); err == nil {
+		server.Close()
+		t.Error("Expected an error serving on a port in use")
+	}
+}
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// defaultTraceSize is how many trace entries a WatchTrace keeps
+const defaultTraceSize = 200
+
+// WatchTrace keeps the most recent watch mode decisions in a ring buffer:
+// each raw file event, the rule it matched, the debounce decision, and what
+// was run, so a save that did not trigger tests can be explained
+type WatchTrace struct {
+	mu      sync.Mutex
+	entries []WatchTraceEntry
+	next    int
+	full    bool
+}
+
+// WatchTraceEntry is one traced decision
+type WatchTraceEntry struct {
+	Time    time.Time
+	Path    string // File the decision is about, empty for run decisions
+	Stage   string // TraceEvent, TraceRule, TraceDebounce, or TraceRun
+	Message string
+}
+
+// Trace stages
+const (
+	TraceEvent    = "event"
+	TraceRule     = "rule"
+	TraceDebounce = "debounce"
+	TraceRun      = "run"
+)
+
+// NewWatchTrace returns a trace keeping the last size entries, or a
+// default number when size is not positive
+func NewWatchTrace(size int) *WatchTrace {
+	if size <= 0 {
+		size = defaultTraceSize
+	}
+	return &WatchTrace{entries: make([]WatchTraceEntry, size)}
+}
+
+// add records an entry; a nil trace records nothing
+func (t *WatchTrace) add(stage, path, format string, args ...interface{}) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.entries[t.next] = WatchTraceEntry{Time: time.Now(), Path: path, Stage: stage, Message: fmt.Sprintf(format, args...)}
+	t.next = (t.next + 1) % len(t.entries)
+	if t.next == 0 {
+		t.full = true
+	}
+}
+
+// Entries returns the recorded entries, oldest first
+func (t *WatchTrace) Entries() []WatchTraceEntry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.full {
+		return append([]WatchTraceEntry(nil), t.entries[:t.next]...)
+	}
+	return append(append([]WatchTraceEntry(nil), t.entries[t.next:]...), t.entries[:t.next]...)
+}
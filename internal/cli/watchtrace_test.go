@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWatchTrace(t *testing.T) {
+	trace := NewWatchTrace(3)
+	for i := 0; i < 5; i++ {
+		trace.add(TraceEvent, fmt.Sprintf("f%d.go", i), "WRITE")
+	}
+	entries := trace.Entries()
+	if len(entries) != 3 || entries[0].Path != "f2.go" || entries[2].Path != "f4.go" {
+		t.Errorf("entries = %+v, want the last three oldest first", entries)
+	}
+
+	var none *WatchTrace
+	none.add(TraceRule, "f.go", "ignored") // A disabled trace records nothing
+}
+
+func TestRunner_TriggerRule(t *testing.T) {
+	runner, err := NewRunner(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewRunner failed: %v", err)
+	}
+	defer runner.Stop()
+	runner.SetWatchIgnore([]string{"gen/**"})
+	runner.generate = []GenerateStep{{Name: "protos", Inputs: []string{"proto/*.proto", "gen/*.proto"}}}
+
+	tests := []struct {
+		path    string
+		trigger bool
+		rule    string
+	}{
+		{"calc.go", true, "Go source file"},
+		{"notes.txt", false, "not a Go file"},
+		{filepath.Join("proto", "api.proto"), true, `input of generate step "protos"`},
+		{filepath.Join("gen", "api.proto"), false, `ignored by "gen/**"`},
+		{filepath.Join("gen", "mock.go"), false, `ignored by "gen/**"`},
+	}
+	for _, tt := range tests {
+		trigger, rule := runner.triggerRule(filepath.Join(runner.workDir, tt.path))
+		if trigger != tt.trigger || !strings.Contains(rule, tt.rule) {
+			t.Errorf("triggerRule(%s) = %v, %q, want %v and a rule containing %q", tt.path, trigger, rule, tt.trigger, tt.rule)
+		}
+	}
+}
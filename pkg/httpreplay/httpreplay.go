@@ -0,0 +1,317 @@
+// Package httpreplay records the HTTP traffic of a test to a cassette file
+// and replays it on later runs, so tests of HTTP clients run without the
+// network:
+//
+//	func TestFetchUser(t *testing.T) {
+//		client := httpreplay.New(t).Client()
+//		user, err := api.FetchUser(client, "octocat")
+//		...
+//	}
+//
+// Cassettes are stored as testdata/cassettes/<test name>.json in the
+// package directory. The mode is set by go-sentinel through environment
+// variables:
+//
+//   - In replay mode, the default under plain go test, requests are answered
+//     from the cassette and a missing cassette fails the test.
+//   - In record mode, set by go-sentinel run, missing cassettes are recorded
+//     from the real server and existing ones are replayed.
+//   - Tests matching the refresh pattern, set by --refresh-fixtures, always
+//     re-record their cassettes.
+//
+// A cassette is stale when a request has no recorded response or recorded
+// responses go unused. Created, refreshed, and stale cassettes are reported
+// to go-sentinel and counted in the run summary.
+package httpreplay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/newbpydev/go-sentinel/pkg/sentinelio"
+)
+
+// Environment variables through which go-sentinel sets the mode
+const (
+	EnvMode    = "SENTINEL_HTTP_MODE"    // ModeRecord or ModeReplay
+	EnvRefresh = "SENTINEL_HTTP_REFRESH" // Regular expression of tests whose cassettes are re-recorded
+)
+
+// Modes
+const (
+	ModeReplay = "replay"
+	ModeRecord = "record"
+)
+
+// Cassette states reported to go-sentinel
+const (
+	StateCreated   = "created"
+	StateRefreshed = "refreshed"
+	StateStale     = "stale"
+)
+
+// Dir is where cassettes are stored, relative to the package directory
+const Dir = "testdata/cassettes"
+
+// TB is the subset of testing.TB used by a recorder
+type TB interface {
+	sentinelio.TB
+	Name() string
+	Failed() bool
+	Errorf(format string, args ...any)
+	Cleanup(func())
+}
+
+// Cassette is the recorded traffic of a test
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Interaction is a request and the response it received. Request headers
+// are not recorded, as they often carry credentials.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+
+	used bool
+}
+
+// Request identifies a recorded request
+type Request struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Body   string `json:"body,omitempty"`
+}
+
+// Response is a recorded response
+type Response struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// Recorder is an http.RoundTripper that records or replays the traffic of
+// one test
+type Recorder struct {
+	// Transport sends requests while recording, http.DefaultTransport if nil
+	Transport http.RoundTripper
+
+	t         TB
+	path      string
+	recording bool
+	refresh   bool
+	missing   bool
+
+	mu       sync.Mutex
+	cassette *Cassette
+	stale    bool
+}
+
+// New returns a recorder for the current test, using its cassette at
+// testdata/cassettes/<test name>.json
+func New(t TB) *Recorder {
+	t.Helper()
+	return NewCassette(t, filepath.Join(Dir, cassetteName(t.Name())+".json"))
+}
+
+// NewCassette returns a recorder for the current test using the cassette
+// at path
+func NewCassette(t TB, path string) *Recorder {
+	t.Helper()
+	r := &Recorder{t: t, path: path, cassette: &Cassette{}}
+
+	if pattern := os.Getenv(EnvRefresh); pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			t.Errorf("httpreplay: invalid %s: %v", EnvRefresh, err)
+		} else if re.MatchString(t.Name()) {
+			r.refresh = true
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case r.refresh:
+		r.recording = true
+	case os.IsNotExist(err):
+		if os.Getenv(EnvMode) == ModeRecord {
+			r.recording = true
+		} else {
+			r.missing = true
+			t.Errorf("httpreplay: no cassette at %s; run the test with go-sentinel to record it", path)
+		}
+	case err != nil:
+		t.Errorf("httpreplay: failed to read cassette: %v", err)
+	default:
+		if err := json.Unmarshal(data, r.cassette); err != nil {
+			t.Errorf("httpreplay: failed to parse cassette %s: %v", path, err)
+		}
+	}
+
+	t.Cleanup(r.finish)
+	return r
+}
+
+// Client returns an HTTP client whose requests go through the recorder
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// RoundTrip answers a request from the cassette, or sends it and records
+// the response when recording
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	recorded, err := newRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.recording {
+		return r.record(req, recorded)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, in := range r.cassette.Interactions {
+		if !in.used && in.Request == recorded {
+			in.used = true
+			return in.Response.httpResponse(req), nil
+		}
+	}
+	r.stale = true
+	return nil, fmt.Errorf("httpreplay: no recorded response for %s %s in %s", recorded.Method, recorded.URL, r.path)
+}
+
+// record sends a request and adds the exchange to the cassette
+func (r *Recorder) record(req *http.Request, recorded Request) (*http.Response, error) {
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("httpreplay: failed to read response: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, &Interaction{
+		Request:  recorded,
+		Response: Response{StatusCode: resp.StatusCode, Header: resp.Header.Clone(), Body: string(body)},
+		used:     true,
+	})
+	return resp, nil
+}
+
+// finish saves a recorded cassette, unless the test failed, and reports
+// what happened to it
+func (r *Recorder) finish() {
+	r.t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.recording {
+		for _, in := range r.cassette.Interactions {
+			if !in.used {
+				r.stale = true
+			}
+		}
+		if r.stale && !r.missing {
+			r.report(StateStale)
+		}
+		return
+	}
+	if r.t.Failed() {
+		return
+	}
+	if err := r.save(); err != nil {
+		r.t.Errorf("httpreplay: %v", err)
+		return
+	}
+	if r.refresh {
+		r.report(StateRefreshed)
+	} else {
+		r.report(StateCreated)
+	}
+}
+
+// save writes the cassette
+func (r *Recorder) save() error {
+	data, err := json.MarshalIndent(r.cassette, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cassette: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return fmt.Errorf("failed to create cassette directory: %w", err)
+	}
+	if err := os.WriteFile(r.path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	return nil
+}
+
+// report tells go-sentinel the state of the cassette
+func (r *Recorder) report(state string) {
+	r.t.Helper()
+	r.t.Log(sentinelio.Format(sentinelio.Annotation{Kind: sentinelio.KindCassette, Name: r.path, State: state}))
+}
+
+// newRequest returns the recorded form of a request, leaving its body
+// readable
+func newRequest(req *http.Request) (Request, error) {
+	recorded := Request{Method: req.Method, URL: req.URL.String()}
+	if req.Body == nil || req.Body == http.NoBody {
+		return recorded, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return Request{}, fmt.Errorf("httpreplay: failed to read request body: %w", err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	recorded.Body = string(body)
+	return recorded, nil
+}
+
+// httpResponse builds the response to a replayed request
+func (resp Response) httpResponse(req *http.Request) *http.Response {
+	header := resp.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode)),
+		StatusCode:    resp.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(resp.Body)),
+		ContentLength: int64(len(resp.Body)),
+		Request:       req,
+	}
+}
+
+// cassetteName turns a test name into a file name, keeping subtests in
+// subdirectories
+func cassetteName(test string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '\\', ':', '*', '?', '"', '<', '>', '|', ' ':
+			return '_'
+		}
+		return r
+	}, test)
+}
@@ -0,0 +1,124 @@
+package httpreplay
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/newbpydev/go-sentinel/pkg/sentinelio"
+)
+
+// fakeT collects what a recorder reports and runs its cleanups on finish
+type fakeT struct {
+	name     string
+	logs     []string
+	errors   []string
+	cleanups []func()
+}
+
+func (f *fakeT) Helper()                           {}
+func (f *fakeT) Name() string                      { return f.name }
+func (f *fakeT) Failed() bool                      { return len(f.errors) > 0 }
+func (f *fakeT) Log(args ...any)                   { f.logs = append(f.logs, fmt.Sprint(args...)) }
+func (f *fakeT) Errorf(format string, args ...any) { f.errors = append(f.errors, fmt.Sprintf(format, args...)) }
+func (f *fakeT) Cleanup(fn func())                 { f.cleanups = append(f.cleanups, fn) }
+
+func (f *fakeT) finish() {
+	for i := len(f.cleanups) - 1; i >= 0; i-- {
+		f.cleanups[i]()
+	}
+}
+
+// states returns the cassette states reported to go-sentinel
+func (f *fakeT) states() []string {
+	var states []string
+	for _, line := range f.logs {
+		if a, ok := sentinelio.Parse(line); ok && a.Kind == sentinelio.KindCassette {
+			states = append(states, a.State)
+		}
+	}
+	return states
+}
+
+// server counts the requests it answers
+type server struct{ hits int }
+
+func (s *server) RoundTrip(req *http.Request) (*http.Response, error) {
+	s.hits++
+	w := httptest.NewRecorder()
+	fmt.Fprintf(w, "hello %s", req.URL.Path)
+	return w.Result(), nil
+}
+
+func get(t *testing.T, ft *fakeT, path, cassette string, transport http.RoundTripper) (string, error) {
+	t.Helper()
+	rec := NewCassette(ft, cassette)
+	rec.Transport = transport
+	defer ft.finish()
+	resp, err := rec.Client().Get("http://api.example" + path)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return string(body), err
+}
+
+func TestRecorder(t *testing.T) {
+	cassette := filepath.Join(t.TempDir(), "TestHello.json")
+	srv := &server{}
+
+	// Replay mode, the default, refuses to record
+	ft := &fakeT{name: "TestHello"}
+	if _, err := get(t, ft, "/hello", cassette, srv); err == nil || !ft.Failed() || srv.hits != 0 {
+		t.Fatalf("replay without a cassette: err = %v, errors = %v, hits = %d", err, ft.errors, srv.hits)
+	}
+
+	// Record mode records a missing cassette
+	t.Setenv(EnvMode, ModeRecord)
+	ft = &fakeT{name: "TestHello"}
+	if body, err := get(t, ft, "/hello", cassette, srv); err != nil || body != "hello /hello" {
+		t.Fatalf("record: body = %q, err = %v", body, err)
+	}
+	if got := ft.states(); len(got) != 1 || got[0] != StateCreated {
+		t.Errorf("record states = %v, want created", got)
+	}
+
+	// An existing cassette is replayed without the server
+	ft = &fakeT{name: "TestHello"}
+	if body, err := get(t, ft, "/hello", cassette, srv); err != nil || body != "hello /hello" || srv.hits != 1 {
+		t.Fatalf("replay: body = %q, err = %v, hits = %d", body, err, srv.hits)
+	}
+	if got := ft.states(); len(got) != 0 {
+		t.Errorf("replay states = %v, want none", got)
+	}
+
+	// A request the cassette does not hold makes it stale
+	ft = &fakeT{name: "TestHello"}
+	if _, err := get(t, ft, "/bye", cassette, srv); err == nil || !strings.Contains(err.Error(), "no recorded response") {
+		t.Fatalf("stale: err = %v, want no recorded response", err)
+	}
+	if got := ft.states(); len(got) != 1 || got[0] != StateStale {
+		t.Errorf("stale states = %v, want stale", got)
+	}
+
+	// Refreshing re-records the cassette
+	t.Setenv(EnvRefresh, "^TestHel")
+	ft = &fakeT{name: "TestHello"}
+	if body, err := get(t, ft, "/bye", cassette, srv); err != nil || body != "hello /bye" || srv.hits != 2 {
+		t.Fatalf("refresh: body = %q, err = %v, hits = %d", body, err, srv.hits)
+	}
+	if got := ft.states(); len(got) != 1 || got[0] != StateRefreshed {
+		t.Errorf("refresh states = %v, want refreshed", got)
+	}
+}
+
+func TestCassetteName(t *testing.T) {
+	if got := cassetteName("TestAPI/get user: ok"); got != "TestAPI/get_user__ok" {
+		t.Errorf("cassetteName = %q", got)
+	}
+}
@@ -0,0 +1,211 @@
+// Package sentinelio lets tests attach structured metadata (links, metrics,
+// and custom sections) to their results. Annotations are written to the test
+// log using an in-band protocol that go-sentinel recognizes and strips from
+// the regular output:
+//
+//	##sentinel[<kind>] <json payload>
+//
+// For example:
+//
+//	func TestImport(t *testing.T) {
+//		sentinelio.Link(t, "Dashboard", "https://grafana.example.com/d/import")
+//		sentinelio.Metric(t, "rows", 1200, "rows")
+//	}
+//
+// Assertion counts are reported with Assertions, or collected automatically
+// for testify-style assertions by passing a counter in place of t:
+//
+//	a := sentinelio.CountAssertions(t)
+//	assert.Equal(a, want, got)
+package sentinelio
+
+import (
+	"encoding/json"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Prefix marks the start of an annotation in test output
+const Prefix = "##sentinel["
+
+// Annotation kinds
+const (
+	KindLink    = "link"
+	KindMetric  = "metric"
+	KindSection = "section"
+
+	// KindAssertions reports the number of assertions a test made
+	KindAssertions = "assertions"
+
+	// KindCassette reports an HTTP fixture written or found stale by
+	// package httpreplay
+	KindCassette = "cassette"
+
+	// KindSnapshot reports a golden snapshot written or found to differ by
+	// package snapshot
+	KindSnapshot = "snapshot"
+)
+
+// Annotation is a piece of structured metadata emitted by a test
+type Annotation struct {
+	Kind  string  `json:"-"`
+	Name  string  `json:"name,omitempty"`
+	URL   string  `json:"url,omitempty"`
+	Value float64 `json:"value,omitempty"`
+	Unit  string  `json:"unit,omitempty"`
+	Title string  `json:"title,omitempty"`
+	Body  string  `json:"body,omitempty"`
+	State string  `json:"state,omitempty"`
+}
+
+// TB is the subset of testing.TB used to emit annotations
+type TB interface {
+	Helper()
+	Log(args ...any)
+}
+
+// Link attaches a named URL to the current test
+func Link(t TB, name, url string) {
+	t.Helper()
+	emit(t, Annotation{Kind: KindLink, Name: name, URL: url})
+}
+
+// Metric attaches a named measurement to the current test
+func Metric(t TB, name string, value float64, unit string) {
+	t.Helper()
+	emit(t, Annotation{Kind: KindMetric, Name: name, Value: value, Unit: unit})
+}
+
+// Section attaches a titled block of free-form text to the current test
+func Section(t TB, title, body string) {
+	t.Helper()
+	emit(t, Annotation{Kind: KindSection, Title: title, Body: body})
+}
+
+// Assertions reports that the current test made n assertions. Counts from
+// repeated calls are added together.
+func Assertions(t TB, n int) {
+	t.Helper()
+	emit(t, Annotation{Kind: KindAssertions, Value: float64(n)})
+}
+
+// CleanupTB is the subset of testing.TB needed to count assertions
+type CleanupTB interface {
+	TB
+	Errorf(format string, args ...any)
+	FailNow()
+	Cleanup(func())
+}
+
+// AssertionCounter wraps a test and counts the assertions made through it.
+// It satisfies the TestingT interfaces of testify's assert and require
+// packages, which mark every assertion by calling Helper. Assertions made
+// by other assertions, as require.Equal calls assert.Equal and a failing
+// assertion calls assert.Fail, count toward the outermost one only. The
+// count is reported when the test finishes.
+type AssertionCounter struct {
+	t       CleanupTB
+	mu      sync.Mutex
+	helpers map[string]bool // Functions that called Helper
+	count   int
+}
+
+// CountAssertions returns a counter to pass to assertion functions in place of t
+func CountAssertions(t CleanupTB) *AssertionCounter {
+	c := &AssertionCounter{t: t, helpers: make(map[string]bool)}
+	t.Cleanup(func() {
+		if n := c.Count(); n > 0 {
+			Assertions(t, n)
+		}
+	})
+	return c
+}
+
+// Count returns the number of assertions made so far
+func (c *AssertionCounter) Count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.count
+}
+
+// Helper marks the calling function as a test helper and counts an
+// assertion, unless the function was called by another one that called
+// Helper
+func (c *AssertionCounter) Helper() {
+	c.t.Helper()
+
+	pcs := make([]uintptr, 64)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	caller, more := frames.Next()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for more {
+		var frame runtime.Frame
+		frame, more = frames.Next()
+		if c.helpers[frame.Function] {
+			return
+		}
+	}
+	c.helpers[caller.Function] = true
+	c.count++
+}
+
+// Errorf reports a failed assertion to the wrapped test
+func (c *AssertionCounter) Errorf(format string, args ...any) {
+	c.t.Helper()
+	c.t.Errorf(format, args...)
+}
+
+// FailNow stops the wrapped test
+func (c *AssertionCounter) FailNow() {
+	c.t.FailNow()
+}
+
+// Log writes to the wrapped test's log
+func (c *AssertionCounter) Log(args ...any) {
+	c.t.Helper()
+	c.t.Log(args...)
+}
+
+// emit writes an annotation to the test log
+func emit(t TB, a Annotation) {
+	t.Helper()
+	t.Log(Format(a))
+}
+
+// Format encodes an annotation as a single protocol line
+func Format(a Annotation) string {
+	payload, err := json.Marshal(a)
+	if err != nil {
+		payload = []byte("{}")
+	}
+	return Prefix + a.Kind + "] " + string(payload)
+}
+
+// Parse extracts an annotation from a line of test output. Leading text such
+// as the "file_test.go:12:" prefix added by t.Log is ignored.
+func Parse(line string) (Annotation, bool) {
+	idx := strings.Index(line, Prefix)
+	if idx < 0 {
+		return Annotation{}, false
+	}
+	rest := line[idx+len(Prefix):]
+
+	end := strings.Index(rest, "]")
+	if end <= 0 {
+		return Annotation{}, false
+	}
+	kind := rest[:end]
+
+	var a Annotation
+	payload := strings.TrimSpace(rest[end+1:])
+	if payload != "" {
+		if err := json.Unmarshal([]byte(payload), &a); err != nil {
+			return Annotation{}, false
+		}
+	}
+	a.Kind = kind
+	return a, true
+}
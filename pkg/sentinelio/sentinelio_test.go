@@ -0,0 +1,150 @@
+package sentinelio
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// recorder captures log lines written through TB
+type recorder struct {
+	lines []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Log(args ...any) {
+	r.lines = append(r.lines, fmt.Sprint(args...))
+}
+
+func TestRoundTrip(t *testing.T) {
+	rec := &recorder{}
+	Link(rec, "Dashboard", "https://example.com/d/1")
+	Metric(rec, "rows", 1200, "rows")
+	Section(rec, "Response", "line one\nline two")
+
+	want := []Annotation{
+		{Kind: KindLink, Name: "Dashboard", URL: "https://example.com/d/1"},
+		{Kind: KindMetric, Name: "rows", Value: 1200, Unit: "rows"},
+		{Kind: KindSection, Title: "Response", Body: "line one\nline two"},
+	}
+	if len(rec.lines) != len(want) {
+		t.Fatalf("got %d lines, want %d", len(rec.lines), len(want))
+	}
+	for i, line := range rec.lines {
+		// Simulate the file:line prefix added by t.Log
+		got, ok := Parse("    import_test.go:12: " + line + "\n")
+		if !ok {
+			t.Fatalf("Parse(%q) failed", line)
+		}
+		if got != want[i] {
+			t.Errorf("Parse(%q) = %+v, want %+v", line, got, want[i])
+		}
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, line := range []string{
+		"plain output",
+		"##sentinel[",
+		"##sentinel[] {}",
+		"##sentinel[link] {not json",
+	} {
+		if _, ok := Parse(line); ok {
+			t.Errorf("Parse(%q) succeeded, want failure", line)
+		}
+	}
+}
+
+// fakeT records the calls an AssertionCounter forwards
+type fakeT struct {
+	recorder
+	errors   int
+	cleanups []func()
+}
+
+func (f *fakeT) Errorf(string, ...any) { f.errors++ }
+func (f *fakeT) FailNow()              {}
+func (f *fakeT) Cleanup(fn func())     { f.cleanups = append(f.cleanups, fn) }
+
+// assertT is the part of testify's TestingT the assertions below use
+type assertT interface {
+	Helper()
+	Errorf(string, ...any)
+	FailNow()
+}
+
+// The assertions below mimic testify's: each marks itself a helper, and
+// they are built from one another the same way
+
+func fail(t assertT, msg string) bool {
+	t.Helper()
+	t.Errorf("%s", msg)
+	return false
+}
+
+func assertTrue(t assertT, ok bool) bool {
+	t.Helper()
+	if !ok {
+		return fail(t, "expected true")
+	}
+	return true
+}
+
+func requireTrue(t assertT, ok bool) {
+	t.Helper()
+	if !assertTrue(t, ok) {
+		t.FailNow()
+	}
+}
+
+func assertError(t assertT, err error) bool {
+	t.Helper()
+	if err == nil {
+		return fail(t, "expected an error")
+	}
+	return true
+}
+
+func assertEqualError(t assertT, err error, want string) bool {
+	t.Helper()
+	if !assertError(t, err) {
+		return false
+	}
+	if err.Error() != want {
+		return fail(t, "error mismatch")
+	}
+	return true
+}
+
+func TestCountAssertions(t *testing.T) {
+	ft := &fakeT{}
+	a := CountAssertions(ft)
+	assertTrue(a, true)
+	assertTrue(a, false)
+	requireTrue(a, true)
+	requireTrue(a, false)
+	assertEqualError(a, errors.New("boom"), "boom")
+	assertEqualError(a, nil, "boom")
+	for i := 0; i < 2; i++ {
+		assertTrue(a, true)
+	}
+
+	if got := a.Count(); got != 8 {
+		t.Errorf("Count() = %d, want 8", got)
+	}
+	if ft.errors != 3 {
+		t.Errorf("forwarded %d errors, want 3", ft.errors)
+	}
+
+	for _, fn := range ft.cleanups {
+		fn()
+	}
+	if len(ft.lines) != 1 {
+		t.Fatalf("got %d lines after cleanup, want 1", len(ft.lines))
+	}
+	got, ok := Parse(ft.lines[0])
+	if !ok || got.Kind != KindAssertions || got.Value != 8 {
+		t.Errorf("Parse(%q) = %+v, want 8 assertions", ft.lines[0], got)
+	}
+}
@@ -0,0 +1,179 @@
+package snapshot
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kinds of diff lines
+const (
+	Same    = ' '
+	Removed = '-'
+	Added   = '+'
+)
+
+// DiffLine is one line of a line diff
+type DiffLine struct {
+	Kind byte // Same, Removed, or Added
+	Text string
+}
+
+// maxDiffCells bounds the work of a diff; larger inputs are shown as the
+// whole of one replaced by the whole of the other
+const maxDiffCells = 4_000_000
+
+// diffContext is the number of unchanged lines kept around changes by
+// FormatDiff
+const diffContext = 3
+
+// Diff returns the line diff turning old into new, from their longest
+// common subsequence of lines
+func Diff(old, new string) []DiffLine {
+	a, b := splitLines(old), splitLines(new)
+
+	// Strip the common prefix and suffix, which is most of a typical
+	// snapshot, before the quadratic part
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var lines []DiffLine
+	for _, line := range a[:prefix] {
+		lines = append(lines, DiffLine{Same, line})
+	}
+	lines = append(lines, diffMiddle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		lines = append(lines, DiffLine{Same, line})
+	}
+	return lines
+}
+
+// diffMiddle diffs the differing middle of two inputs
+func diffMiddle(a, b []string) []DiffLine {
+	var lines []DiffLine
+	if len(a)*len(b) > maxDiffCells {
+		for _, line := range a {
+			lines = append(lines, DiffLine{Removed, line})
+		}
+		for _, line := range b {
+			lines = append(lines, DiffLine{Added, line})
+		}
+		return lines
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, DiffLine{Same, a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, DiffLine{Removed, a[i]})
+			i++
+		default:
+			lines = append(lines, DiffLine{Added, b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, DiffLine{Removed, a[i]})
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, DiffLine{Added, b[j]})
+	}
+	return lines
+}
+
+// FormatDiff renders a diff as text, keeping a few unchanged lines around
+// each change and eliding the rest
+func FormatDiff(lines []DiffLine) string {
+	var b strings.Builder
+	for _, hunk := range Hunks(lines, diffContext) {
+		if hunk.Skipped > 0 {
+			fmt.Fprintf(&b, "@@ %d unchanged %s @@\n", hunk.Skipped, plural("line", hunk.Skipped))
+			continue
+		}
+		fmt.Fprintf(&b, "%c %s\n", hunk.Line.Kind, hunk.Line.Text)
+	}
+	return b.String()
+}
+
+// Hunk is a line of a diff to show, or a run of unchanged lines left out
+type Hunk struct {
+	Line    DiffLine
+	Skipped int // Unchanged lines left out in place of Line, 0 for a line
+}
+
+// Hunks returns the lines of a diff to show, keeping context unchanged
+// lines around each change and replacing longer unchanged runs with a
+// count
+func Hunks(lines []DiffLine, context int) []Hunk {
+	keep := make([]bool, len(lines))
+	for i, line := range lines {
+		if line.Kind == Same {
+			continue
+		}
+		for k := max(i-context, 0); k <= min(i+context, len(lines)-1); k++ {
+			keep[k] = true
+		}
+	}
+
+	var hunks []Hunk
+	for i := 0; i < len(lines); {
+		if keep[i] {
+			hunks = append(hunks, Hunk{Line: lines[i]})
+			i++
+			continue
+		}
+		start := i
+		for i < len(lines) && !keep[i] {
+			i++
+		}
+		hunks = append(hunks, Hunk{Skipped: i - start})
+	}
+	return hunks
+}
+
+// splitLines splits text into lines, marking a missing final newline so
+// it shows in the diff
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.Split(s, "\n")
+	if lines[len(lines)-1] == "" {
+		return lines[:len(lines)-1]
+	}
+	lines[len(lines)-1] += " (no newline at end)"
+	return lines
+}
+
+// plural returns word with an s when n is not 1
+func plural(word string, n int) string {
+	if n == 1 {
+		return word
+	}
+	return word + "s"
+}
@@ -0,0 +1,213 @@
+// Package snapshot compares values produced by a test with golden
+// snapshots stored beside the package, so large outputs can be checked
+// without writing them into the test:
+//
+//	func TestRenderInvoice(t *testing.T) {
+//		snapshot.Match(t, render(invoice))
+//		snapshot.MatchNamed(t, "totals", invoice.Totals)
+//	}
+//
+// Strings and byte slices are stored as they are; other values are stored
+// as indented JSON. Snapshots live in testdata/snapshots as <test
+// name>.snap, or <test name>-<name>.snap for named snapshots, with
+// subtests in subdirectories.
+//
+// The mode is set by go-sentinel through environment variables:
+//
+//   - Under plain go test, a missing snapshot fails the test.
+//   - In record mode, set by go-sentinel run, missing snapshots are written.
+//   - Tests matching the update pattern, set by --update-snapshots, rewrite
+//     their snapshots when they differ.
+//
+// A mismatch fails the test with a diff and leaves the new value beside the
+// snapshot as <snapshot>.new, for go-sentinel snapshot review or update to
+// accept. Created, updated, and mismatched snapshots are reported to
+// go-sentinel and counted in the run summary.
+package snapshot
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/newbpydev/go-sentinel/pkg/sentinelio"
+)
+
+// Environment variables through which go-sentinel sets the mode
+const (
+	EnvMode   = "SENTINEL_SNAPSHOT_MODE"   // ModeRecord to write missing snapshots
+	EnvUpdate = "SENTINEL_SNAPSHOT_UPDATE" // Regular expression of tests whose snapshots are rewritten
+)
+
+// ModeRecord writes missing snapshots instead of failing
+const ModeRecord = "record"
+
+// Snapshot states reported to go-sentinel
+const (
+	StateCreated  = "created"
+	StateUpdated  = "updated"
+	StateMismatch = "mismatch"
+)
+
+// Dir is where snapshots are stored, relative to the package directory
+const Dir = "testdata/snapshots"
+
+// File extensions of snapshots and of the new values of mismatched ones
+const (
+	Ext    = ".snap"
+	NewExt = ".snap.new"
+)
+
+// TB is the subset of testing.TB used to match snapshots
+type TB interface {
+	sentinelio.TB
+	Name() string
+	Errorf(format string, args ...any)
+}
+
+// Match compares got with the test's snapshot at
+// testdata/snapshots/<test name>.snap
+func Match(t TB, got any) {
+	t.Helper()
+	MatchFile(t, filepath.Join(Dir, FileName(t.Name(), "")), got)
+}
+
+// MatchNamed compares got with the test's snapshot called name, for tests
+// checking more than one value
+func MatchNamed(t TB, name string, got any) {
+	t.Helper()
+	MatchFile(t, filepath.Join(Dir, FileName(t.Name(), name)), got)
+}
+
+// MatchFile compares got with the snapshot at path
+func MatchFile(t TB, path string, got any) {
+	t.Helper()
+	data, err := encode(got)
+	if err != nil {
+		t.Errorf("snapshot: %v", err)
+		return
+	}
+
+	update := false
+	if pattern := os.Getenv(EnvUpdate); pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			t.Errorf("snapshot: invalid %s: %v", EnvUpdate, err)
+		} else if re.MatchString(t.Name()) {
+			update = true
+		}
+	}
+
+	want, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		if os.Getenv(EnvMode) != ModeRecord && !update {
+			t.Errorf("snapshot: no snapshot at %s; run the test with go-sentinel to record it", path)
+			return
+		}
+		if err := write(path, data); err != nil {
+			t.Errorf("snapshot: %v", err)
+			return
+		}
+		report(t, path, StateCreated)
+		return
+	case err != nil:
+		t.Errorf("snapshot: failed to read snapshot: %v", err)
+		return
+	}
+
+	if bytes.Equal(want, data) {
+		os.Remove(NewPath(path))
+		return
+	}
+	if update {
+		if err := write(path, data); err != nil {
+			t.Errorf("snapshot: %v", err)
+			return
+		}
+		os.Remove(NewPath(path))
+		report(t, path, StateUpdated)
+		return
+	}
+
+	if err := write(NewPath(path), data); err != nil {
+		t.Errorf("snapshot: %v", err)
+	}
+	report(t, path, StateMismatch)
+	t.Errorf("snapshot: %s does not match (-snapshot +got):\n%s", path, FormatDiff(Diff(string(want), string(data))))
+}
+
+// FileName returns the snapshot file of a test, relative to Dir. Subtests
+// are kept in subdirectories, and a named snapshot adds -<name>.
+func FileName(test, name string) string {
+	file := sanitize(test)
+	if name != "" {
+		file += "-" + sanitize(strings.ReplaceAll(name, "/", "_"))
+	}
+	return file + Ext
+}
+
+// TestName returns the top-level test a snapshot file relative to Dir
+// belongs to. Test function names cannot hold '-' or '.', so the name ends
+// at the first of them or at the first subtest directory.
+func TestName(file string) string {
+	file = filepath.ToSlash(file)
+	if i := strings.IndexAny(file, "/-."); i >= 0 {
+		return file[:i]
+	}
+	return file
+}
+
+// NewPath returns where the new value of a mismatched snapshot is kept
+func NewPath(path string) string {
+	return strings.TrimSuffix(path, Ext) + NewExt
+}
+
+// encode returns the stored form of a value
+func encode(v any) ([]byte, error) {
+	switch v := v.(type) {
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode value: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// write saves data at path, creating its directory
+func write(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return nil
+}
+
+// report tells go-sentinel the state of a snapshot
+func report(t TB, path, state string) {
+	t.Helper()
+	t.Log(sentinelio.Format(sentinelio.Annotation{Kind: sentinelio.KindSnapshot, Name: path, State: state}))
+}
+
+// sanitize turns a test name into a file name, keeping subtests in
+// subdirectories
+func sanitize(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '\\', ':', '*', '?', '"', '<', '>', '|', ' ':
+			return '_'
+		}
+		return r
+	}, name)
+}
@@ -0,0 +1,149 @@
+package snapshot
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/newbpydev/go-sentinel/pkg/sentinelio"
+)
+
+// fakeT collects what a snapshot match reports
+type fakeT struct {
+	name   string
+	logs   []string
+	errors []string
+}
+
+func (f *fakeT) Helper()         {}
+func (f *fakeT) Name() string    { return f.name }
+func (f *fakeT) Log(args ...any) { f.logs = append(f.logs, fmt.Sprint(args...)) }
+func (f *fakeT) Errorf(format string, args ...any) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+// states returns the snapshot states reported to go-sentinel
+func (f *fakeT) states() []string {
+	var states []string
+	for _, line := range f.logs {
+		if a, ok := sentinelio.Parse(line); ok && a.Kind == sentinelio.KindSnapshot {
+			states = append(states, a.State)
+		}
+	}
+	return states
+}
+
+func TestMatchFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "TestInvoice.snap")
+
+	// Plain go test refuses to create snapshots
+	ft := &fakeT{name: "TestInvoice"}
+	MatchFile(ft, path, "total: 10\n")
+	if len(ft.errors) == 0 {
+		t.Fatal("missing snapshot did not fail the test")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("snapshot written outside record mode: %v", err)
+	}
+
+	// Record mode writes it
+	t.Setenv(EnvMode, ModeRecord)
+	ft = &fakeT{name: "TestInvoice"}
+	MatchFile(ft, path, "total: 10\n")
+	if len(ft.errors) > 0 || !reflect.DeepEqual(ft.states(), []string{StateCreated}) {
+		t.Fatalf("record: errors = %v, states = %v", ft.errors, ft.states())
+	}
+
+	// A matching value passes silently
+	ft = &fakeT{name: "TestInvoice"}
+	MatchFile(ft, path, "total: 10\n")
+	if len(ft.errors) > 0 || len(ft.states()) > 0 {
+		t.Fatalf("match: errors = %v, states = %v", ft.errors, ft.states())
+	}
+
+	// A different value fails with a diff and keeps the new value
+	ft = &fakeT{name: "TestInvoice"}
+	MatchFile(ft, path, "total: 12\n")
+	if len(ft.errors) != 1 || !strings.Contains(ft.errors[0], "- total: 10\n+ total: 12\n") {
+		t.Fatalf("mismatch: errors = %v", ft.errors)
+	}
+	if got, _ := os.ReadFile(NewPath(path)); string(got) != "total: 12\n" {
+		t.Errorf("new value = %q", got)
+	}
+	if !reflect.DeepEqual(ft.states(), []string{StateMismatch}) {
+		t.Errorf("mismatch states = %v", ft.states())
+	}
+
+	// Updating rewrites the snapshot and drops the pending new value
+	t.Setenv(EnvUpdate, "^TestInvoice$")
+	ft = &fakeT{name: "TestInvoice"}
+	MatchFile(ft, path, "total: 12\n")
+	if len(ft.errors) > 0 || !reflect.DeepEqual(ft.states(), []string{StateUpdated}) {
+		t.Fatalf("update: errors = %v, states = %v", ft.errors, ft.states())
+	}
+	if got, _ := os.ReadFile(path); string(got) != "total: 12\n" {
+		t.Errorf("updated snapshot = %q", got)
+	}
+	if _, err := os.Stat(NewPath(path)); !os.IsNotExist(err) {
+		t.Errorf("new value left after update: %v", err)
+	}
+}
+
+func TestMatchFile_JSON(t *testing.T) {
+	t.Setenv(EnvMode, ModeRecord)
+	path := filepath.Join(t.TempDir(), "TestUser.snap")
+	ft := &fakeT{name: "TestUser"}
+	MatchFile(ft, path, map[string]any{"name": "Ada", "admin": true})
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "{\n  \"admin\": true,\n  \"name\": \"Ada\"\n}\n"; string(got) != want {
+		t.Errorf("snapshot = %q, want %q", got, want)
+	}
+}
+
+func TestFileName(t *testing.T) {
+	tests := []struct {
+		test, name, want string
+	}{
+		{"TestInvoice", "", "TestInvoice.snap"},
+		{"TestInvoice", "totals", "TestInvoice-totals.snap"},
+		{"TestInvoice/with tax", "", "TestInvoice/with_tax.snap"},
+		{"TestInvoice/eu", "a/b", "TestInvoice/eu-a_b.snap"},
+	}
+	for _, tt := range tests {
+		file := FileName(tt.test, tt.name)
+		if file != tt.want {
+			t.Errorf("FileName(%q, %q) = %q, want %q", tt.test, tt.name, file, tt.want)
+		}
+		if got := TestName(file); got != "TestInvoice" {
+			t.Errorf("TestName(%q) = %q, want TestInvoice", file, got)
+		}
+	}
+}
+
+func TestFormatDiff(t *testing.T) {
+	var old, new []string
+	for i := 1; i <= 20; i++ {
+		old = append(old, fmt.Sprintf("line %d", i))
+		new = append(new, fmt.Sprintf("line %d", i))
+	}
+	new[9] = "changed"
+	new = append(new[:15], new[16:]...)
+
+	want := "@@ 6 unchanged lines @@\n" +
+		"  line 7\n  line 8\n  line 9\n" +
+		"- line 10\n+ changed\n" +
+		"  line 11\n  line 12\n  line 13\n  line 14\n  line 15\n" +
+		"- line 16\n" +
+		"  line 17\n  line 18\n  line 19\n" +
+		"@@ 1 unchanged line @@\n"
+	got := FormatDiff(Diff(strings.Join(old, "\n")+"\n", strings.Join(new, "\n")+"\n"))
+	if got != want {
+		t.Errorf("FormatDiff() =\n%s\nwant\n%s", got, want)
+	}
+}